@@ -0,0 +1,247 @@
+// Package apiclient is a Go client for the Claude Greyzone REST API.
+//
+// Methods in client_gen.go are generated from handlers.APIOperations, the same
+// table that backs /api/openapi.json; run `go generate ./apiclient` after
+// changing routes.
+package apiclient
+
+//go:generate go run ./internal/gen
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"claude-web-ui/handlers"
+)
+
+// Client calls a Greyzone server
+type Client struct {
+	// BaseURL is the server root including any base path, e.g. https://host:43210/claude
+	BaseURL string
+	// Token is sent as a Bearer token (from Login or an API key); empty when auth is disabled
+	Token string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// New returns a client for baseURL
+func New(baseURL string, token string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/"), Token: token}
+}
+
+// APIError is a non-2xx response
+type APIError struct {
+	StatusCode int
+	Code       handlers.ErrorCode `json:"code"` // e.g. handlers.ErrSessionBusy
+	Message    string             `json:"message"`
+	Details    interface{}        `json:"details"`
+	RequestID  string             `json:"requestId"`
+}
+
+func (e *APIError) Error() string {
+	if e.Details != nil {
+		return fmt.Sprintf("%d %s: %v", e.StatusCode, e.Message, e.Details)
+	}
+	return fmt.Sprintf("%d %s", e.StatusCode, e.Message)
+}
+
+// newRequest builds a request for path (e.g. /api/sessions) with optional JSON body
+func (c *Client) newRequest(ctx context.Context, method string, path string, query url.Values, body interface{}) (*http.Request, error) {
+	u := c.BaseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return req, nil
+}
+
+// send executes req and converts error responses to *APIError
+func (c *Client) send(req *http.Request) (*http.Response, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if json.Unmarshal(data, apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+			if apiErr.Message == "" {
+				apiErr.Message = resp.Status
+			}
+		}
+		return nil, apiErr
+	}
+	return resp, nil
+}
+
+// do sends a JSON request and decodes the JSON response into out
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.send(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// stream sends a request and returns the raw response (SSE streams, file downloads)
+// The caller must close the body
+func (c *Client) stream(ctx context.Context, method string, path string, query url.Values, body interface{}) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+// UploadFile calls POST /api/upload with the contents of r as a multipart file
+func (c *Client) UploadFile(ctx context.Context, filename string, r io.Reader) (*handlers.UploadResponse, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/api/upload", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(&buf)
+	req.ContentLength = int64(buf.Len())
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out handlers.UploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportSessions calls POST /api/sessions/import with the contents of r as a multipart file
+// filename's extension helps tell a .jsonl transcript from an export; query sets workDir, format, and conversation
+func (c *Client) ImportSessions(ctx context.Context, filename string, r io.Reader, query url.Values) (*handlers.ImportSessionsResponse, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, err := w.CreateFormFile("file", filepath.Base(filename))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, http.MethodPost, "/api/sessions/import", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(&buf)
+	req.ContentLength = int64(buf.Len())
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out handlers.ImportSessionsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreBackup calls POST /api/restore with an archive from CreateBackup read from r
+func (c *Client) RestoreBackup(ctx context.Context, r io.Reader, overwrite bool) (*handlers.RestoreResponse, error) {
+	query := url.Values{"overwrite": {strconv.FormatBool(overwrite)}}
+	req, err := c.newRequest(ctx, http.MethodPost, "/api/restore", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(r)
+	req.Header.Set("Content-Type", "application/gzip")
+	resp, err := c.send(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var out handlers.RestoreResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FollowChatPoll long-polls a chat run started with a RunID until it is done,
+// passing each event's data to handle in order. It is the fallback for networks
+// that cut the SSE stream of Chat; an error from handle stops the loop
+func (c *Client) FollowChatPoll(ctx context.Context, runID string, handle func(data json.RawMessage) error) error {
+	var cursor int64
+	for {
+		query := url.Values{"runId": {runID}, "cursor": {strconv.FormatInt(cursor, 10)}}
+		resp, err := c.PollChat(ctx, query)
+		if err != nil {
+			return err
+		}
+		for _, event := range resp.Events {
+			if err := handle(event.Data); err != nil {
+				return err
+			}
+		}
+		cursor = resp.Cursor
+		if resp.Done {
+			return nil
+		}
+	}
+}
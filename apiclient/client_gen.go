@@ -0,0 +1,1954 @@
+// Code generated by apiclient/internal/gen; DO NOT EDIT.
+
+package apiclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"claude-web-ui/handlers"
+)
+
+// SuccessResponse is returned by endpoints that only report success
+type SuccessResponse struct {
+	Success bool `json:"success"`
+}
+
+// AuthStatusResponse is the response of GET /api/auth/status
+type AuthStatusResponse struct {
+	Mode          string             `json:"mode"`
+	Authenticated bool               `json:"authenticated"`
+	User          *handlers.UserInfo `json:"user"`
+}
+
+// LoginResponse is the response of POST /api/auth/login
+type LoginResponse struct {
+	Token string            `json:"token"`
+	User  handlers.UserInfo `json:"user"`
+}
+
+// GetCurrentUserResponse is the response of GET /api/auth/me
+type GetCurrentUserResponse struct {
+	User        *handlers.UserInfo `json:"user"`
+	Mode        string             `json:"mode"`
+	ApiKeyScope string             `json:"apiKeyScope"`
+}
+
+// ListUsersResponse is the response of GET /api/users
+type ListUsersResponse struct {
+	Users []handlers.UserInfo `json:"users"`
+}
+
+// ListAPIKeysResponse is the response of GET /api/keys
+type ListAPIKeysResponse struct {
+	Keys []handlers.APIKey `json:"keys"`
+}
+
+// ListSnapshotsResponse is the response of GET /api/session/:id/snapshots
+type ListSnapshotsResponse struct {
+	SessionID string                     `json:"sessionId"`
+	Snapshots []handlers.SessionSnapshot `json:"snapshots"`
+}
+
+// GetSessionFeedbackResponse is the response of GET /api/session/:id/feedback
+type GetSessionFeedbackResponse struct {
+	SessionID string                     `json:"sessionId"`
+	Feedback  []handlers.MessageFeedback `json:"feedback"`
+}
+
+// ExportFeedbackResponse is the response of GET /api/feedback
+type ExportFeedbackResponse struct {
+	Feedback []handlers.MessageFeedback `json:"feedback"`
+}
+
+// GetSessionMtimeResponse is the response of GET /api/session/:id/mtime
+type GetSessionMtimeResponse struct {
+	SessionID string `json:"sessionId"`
+	Mtime     int64  `json:"mtime"`
+}
+
+// DeleteSessionResponse is the response of DELETE /api/session/:id
+type DeleteSessionResponse struct {
+	Success   bool   `json:"success"`
+	SessionID string `json:"sessionId"`
+}
+
+// PinSessionResponse is the response of POST /api/session/:id/pin
+type PinSessionResponse struct {
+	SessionID string `json:"sessionId"`
+	Pinned    bool   `json:"pinned"`
+}
+
+// UnpinSessionResponse is the response of DELETE /api/session/:id/pin
+type UnpinSessionResponse struct {
+	SessionID string `json:"sessionId"`
+	Pinned    bool   `json:"pinned"`
+}
+
+// ListSharesResponse is the response of GET /api/shares
+type ListSharesResponse struct {
+	Shares []handlers.ShareLink `json:"shares"`
+}
+
+// ListApprovalsResponse is the response of GET /api/approvals
+type ListApprovalsResponse struct {
+	Approvals []handlers.PendingApproval `json:"approvals"`
+}
+
+// DecideApprovalResponse is the response of POST /api/approvals/:id
+type DecideApprovalResponse struct {
+	ApprovalID string `json:"approvalId"`
+	Approved   bool   `json:"approved"`
+}
+
+// ListBatchesResponse is the response of GET /api/chat/batch
+type ListBatchesResponse struct {
+	Batches []handlers.BatchStatus `json:"batches"`
+}
+
+// ListProcessesResponse is the response of GET /api/processes
+type ListProcessesResponse struct {
+	Processes []handlers.ActiveProcessInfo `json:"processes"`
+}
+
+// GetTodosResponse is the response of GET /api/todos
+type GetTodosResponse struct {
+	Sessions []handlers.SessionTodos `json:"sessions"`
+}
+
+// ListBudgetsResponse is the response of GET /api/budgets
+type ListBudgetsResponse struct {
+	Budgets []handlers.BudgetInfo `json:"budgets"`
+}
+
+// ListTerminalsResponse is the response of GET /api/terminals
+type ListTerminalsResponse struct {
+	Terminals []handlers.TerminalInfo `json:"terminals"`
+}
+
+// ListLanguageServersResponse is the response of GET /api/lsp/servers
+type ListLanguageServersResponse struct {
+	Servers []handlers.LanguageServerInfo    `json:"servers"`
+	Running []handlers.LanguageServerProcess `json:"running"`
+}
+
+// ListRemoteHostsResponse is the response of GET /api/remote-hosts
+type ListRemoteHostsResponse struct {
+	Hosts []handlers.RemoteHostInfo `json:"hosts"`
+}
+
+// ListBackendsResponse is the response of GET /api/backends
+type ListBackendsResponse struct {
+	Backends []handlers.ExecBackendInfo `json:"backends"`
+}
+
+// ListChatBackendsResponse is the response of GET /api/chat-backends
+type ListChatBackendsResponse struct {
+	Backends []handlers.ChatBackendInfo `json:"backends"`
+}
+
+// PurgeTrashItemResponse is the response of DELETE /api/trash/:id
+type PurgeTrashItemResponse struct {
+	Purged int   `json:"purged"`
+	Size   int64 `json:"size"`
+}
+
+// PurgeTrashResponse is the response of DELETE /api/trash
+type PurgeTrashResponse struct {
+	Purged int   `json:"purged"`
+	Size   int64 `json:"size"`
+}
+
+// UnlockFileResponse is the response of POST /api/file/unlock
+type UnlockFileResponse struct {
+	Released bool `json:"released"`
+}
+
+// GetPushConfigResponse is the response of GET /api/push
+type GetPushConfigResponse struct {
+	PublicKey     string                   `json:"publicKey"`
+	Preferences   handlers.PushPreferences `json:"preferences"`
+	Subscriptions int                      `json:"subscriptions"`
+}
+
+// TestPushResponse is the response of POST /api/push/test
+type TestPushResponse struct {
+	Success bool `json:"success"`
+	Sent    int  `json:"sent"`
+}
+
+// ListWebhooksResponse is the response of GET /api/webhooks
+type ListWebhooksResponse struct {
+	Webhooks []handlers.WebhookInfo `json:"webhooks"`
+}
+
+// ListSchedulesResponse is the response of GET /api/schedules
+type ListSchedulesResponse struct {
+	Schedules []handlers.ScheduleInfo `json:"schedules"`
+}
+
+// GetScheduleRunsResponse is the response of GET /api/schedules/:id/runs
+type GetScheduleRunsResponse struct {
+	Runs []handlers.ScheduleRun `json:"runs"`
+}
+
+// ListTemplatesResponse is the response of GET /api/templates
+type ListTemplatesResponse struct {
+	Templates []handlers.PromptTemplate `json:"templates"`
+}
+
+// ListCommandsResponse is the response of GET /api/commands
+type ListCommandsResponse struct {
+	Commands []handlers.Command      `json:"commands"`
+	Digests  handlers.CommandDigests `json:"digests"`
+}
+
+// ListAgentsResponse is the response of GET /api/agents
+type ListAgentsResponse struct {
+	Agents []handlers.Asset `json:"agents"`
+}
+
+// ListSkillsResponse is the response of GET /api/skills
+type ListSkillsResponse struct {
+	Skills []handlers.Asset `json:"skills"`
+}
+
+// GetConfigResponse is the response of GET /api/config
+type GetConfigResponse struct {
+	Configs []handlers.Config `json:"configs"`
+}
+
+// ListPluginsResponse is the response of GET /api/plugins
+type ListPluginsResponse struct {
+	Plugins []handlers.Plugin `json:"plugins"`
+}
+
+// UninstallPluginResponse is the response of DELETE /api/plugins/:name
+type UninstallPluginResponse struct {
+	Success bool   `json:"success"`
+	Name    string `json:"name"`
+}
+
+// GetMCPServersResponse is the response of GET /api/mcp
+type GetMCPServersResponse struct {
+	Servers []handlers.MCPServer `json:"servers"`
+}
+
+// DeleteMCPServerResponse is the response of DELETE /api/mcp/:name
+type DeleteMCPServerResponse struct {
+	Success bool   `json:"success"`
+	Name    string `json:"name"`
+}
+
+// GetSettingsResponse is the response of GET /api/settings
+type GetSettingsResponse struct {
+	Settings []handlers.SettingsFile `json:"settings"`
+}
+
+// GetHooksResponse is the response of GET /api/hooks
+type GetHooksResponse struct {
+	Hooks []handlers.HooksConfig `json:"hooks"`
+}
+
+// GetHookLogResponse is the response of GET /api/hooks/log
+type GetHookLogResponse struct {
+	Executions []handlers.HookExecution `json:"executions"`
+}
+
+// GetHookEventsResponse is the response of GET /api/hooks/events
+type GetHookEventsResponse struct {
+	Events []handlers.HookEvent `json:"events"`
+}
+
+// GetServerConfigResponse is the response of GET /api/server/config
+type GetServerConfigResponse struct {
+	Path   string                `json:"path"`
+	Config handlers.ServerConfig `json:"config"`
+}
+
+// GetServerLogsResponse is the response of GET /api/server/logs
+type GetServerLogsResponse struct {
+	Entries []json.RawMessage `json:"entries"`
+	Level   string            `json:"level"`
+}
+
+// GetAuditLogResponse is the response of GET /api/audit
+type GetAuditLogResponse struct {
+	Entries []handlers.AuditEntry `json:"entries"`
+}
+
+// AuthStatus calls GET /api/auth/status
+// Authentication mode and current user
+func (c *Client) AuthStatus(ctx context.Context) (*AuthStatusResponse, error) {
+	var out AuthStatusResponse
+	if err := c.do(ctx, http.MethodGet, "/api/auth/status", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Login calls POST /api/auth/login
+// Log in with username and password
+func (c *Client) Login(ctx context.Context, body handlers.LoginRequest) (*LoginResponse, error) {
+	var out LoginResponse
+	if err := c.do(ctx, http.MethodPost, "/api/auth/login", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Logout calls POST /api/auth/logout
+// End the current session
+func (c *Client) Logout(ctx context.Context) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/auth/logout", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCurrentUser calls GET /api/auth/me
+// Current user
+func (c *Client) GetCurrentUser(ctx context.Context) (*GetCurrentUserResponse, error) {
+	var out GetCurrentUserResponse
+	if err := c.do(ctx, http.MethodGet, "/api/auth/me", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OIDCLogin calls GET /api/auth/oidc/login
+// Redirect to the OIDC provider
+func (c *Client) OIDCLogin(ctx context.Context) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/auth/oidc/login", nil, nil)
+}
+
+// OIDCCallback calls GET /api/auth/oidc/callback
+// OIDC redirect target
+// Query parameters: code, state
+func (c *Client) OIDCCallback(ctx context.Context, query url.Values) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/auth/oidc/callback", query, nil)
+}
+
+// GetClaudeAuthStatus calls GET /api/auth/claude-status
+// Whether the claude CLI has credentials, and the assisted login in progress
+// Query parameters: probe
+func (c *Client) GetClaudeAuthStatus(ctx context.Context, query url.Values) (*handlers.ClaudeAuthStatus, error) {
+	var out handlers.ClaudeAuthStatus
+	if err := c.do(ctx, http.MethodGet, "/api/auth/claude-status", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartClaudeLogin calls POST /api/auth/claude-login
+// Start claude setup-token and return the sign-in URL
+func (c *Client) StartClaudeLogin(ctx context.Context) (*handlers.ClaudeLogin, error) {
+	var out handlers.ClaudeLogin
+	if err := c.do(ctx, http.MethodPost, "/api/auth/claude-login", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubmitClaudeLoginCode calls POST /api/auth/claude-login/code
+// Send the code from the sign-in page to the waiting login
+func (c *Client) SubmitClaudeLoginCode(ctx context.Context, body handlers.ClaudeLoginCodeRequest) (*handlers.ClaudeLogin, error) {
+	var out handlers.ClaudeLogin
+	if err := c.do(ctx, http.MethodPost, "/api/auth/claude-login/code", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelClaudeLogin calls DELETE /api/auth/claude-login
+// Cancel the assisted login
+func (c *Client) CancelClaudeLogin(ctx context.Context) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/auth/claude-login", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListUsers calls GET /api/users
+// List users
+func (c *Client) ListUsers(ctx context.Context) (*ListUsersResponse, error) {
+	var out ListUsersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/users", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateUser calls POST /api/users
+// Create a user
+func (c *Client) CreateUser(ctx context.Context, body handlers.UserRequest) (*handlers.UserInfo, error) {
+	var out handlers.UserInfo
+	if err := c.do(ctx, http.MethodPost, "/api/users", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateUser calls PUT /api/users/:id
+// Update a user (users may change their own password)
+func (c *Client) UpdateUser(ctx context.Context, id string, body handlers.UserRequest) (*handlers.UserInfo, error) {
+	var out handlers.UserInfo
+	if err := c.do(ctx, http.MethodPut, "/api/users/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteUser calls DELETE /api/users/:id
+// Delete a user
+func (c *Client) DeleteUser(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/users/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListAPIKeys calls GET /api/keys
+// API keys of the current user (every user's for admins)
+func (c *Client) ListAPIKeys(ctx context.Context) (*ListAPIKeysResponse, error) {
+	var out ListAPIKeysResponse
+	if err := c.do(ctx, http.MethodGet, "/api/keys", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateAPIKey calls POST /api/keys
+// Create a scoped API key; the key is only returned here
+func (c *Client) CreateAPIKey(ctx context.Context, body handlers.APIKeyRequest) (*handlers.APIKeyResponse, error) {
+	var out handlers.APIKeyResponse
+	if err := c.do(ctx, http.MethodPost, "/api/keys", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeAPIKey calls DELETE /api/keys/:id
+// Revoke an API key
+func (c *Client) RevokeAPIKey(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/keys/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSessions calls GET /api/sessions
+// List sessions
+// Query parameters: work_dir, feedback, since, until, min_messages, branch, has_errors, include_sidechains, q, limit
+func (c *Client) ListSessions(ctx context.Context, query url.Values) (*handlers.SessionsResponse, error) {
+	var out handlers.SessionsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/sessions", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CheckSessionsDirty calls POST /api/sessions/dirty-check
+// Report sessions changed since the given mtimes
+func (c *Client) CheckSessionsDirty(ctx context.Context, body handlers.SessionDirtyCheckRequest) (*handlers.SessionDirtyCheckResponse, error) {
+	var out handlers.SessionDirtyCheckResponse
+	if err := c.do(ctx, http.MethodPost, "/api/sessions/dirty-check", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSession calls GET /api/session/:id/info
+// Session metadata
+func (c *Client) GetSession(ctx context.Context, id string) (*handlers.Session, error) {
+	var out handlers.Session
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/info", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSessionHistory calls GET /api/session/:id/history
+// Session messages
+// Query parameters: project, limit, offset, since_uuid, since_timestamp
+func (c *Client) GetSessionHistory(ctx context.Context, id string, query url.Values) (*handlers.HistoryResponse, error) {
+	var out handlers.HistoryResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/history", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetMessageContext calls GET /api/session/:id/messages/:uuid/context
+// A window of messages around one, for deep links into long sessions
+// Query parameters: before, after
+func (c *Client) GetMessageContext(ctx context.Context, id string, uuid string, query url.Values) (*handlers.MessageContextResponse, error) {
+	var out handlers.MessageContextResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/messages/"+url.PathEscape(uuid)+"/context", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetMessageFeedback calls POST /api/session/:id/messages/:uuid/feedback
+// Rate, note, or label a message; empty clears it
+func (c *Client) SetMessageFeedback(ctx context.Context, id string, uuid string, body handlers.FeedbackRequest) (*handlers.MessageFeedback, error) {
+	var out handlers.MessageFeedback
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/messages/"+url.PathEscape(uuid)+"/feedback", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetArtifacts calls GET /api/session/:id/artifacts
+// Code blocks from the session's assistant messages, with the files they were annotated with
+// Query parameters: language, withPath
+func (c *Client) GetArtifacts(ctx context.Context, id string, query url.Values) (*handlers.ArtifactsResponse, error) {
+	var out handlers.ArtifactsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/artifacts", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportGist calls POST /api/session/:id/export/gist
+// Publish selected messages and artifacts to a GitHub gist or the configured pastebin
+func (c *Client) ExportGist(ctx context.Context, id string, body handlers.GistExportRequest) (*handlers.SessionExport, error) {
+	var out handlers.SessionExport
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/export/gist", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSnapshots calls GET /api/session/:id/snapshots
+// Snapshots of the working directory taken before the session's runs, newest first
+func (c *Client) ListSnapshots(ctx context.Context, id string) (*ListSnapshotsResponse, error) {
+	var out ListSnapshotsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/snapshots", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RollbackSession calls POST /api/session/:id/rollback
+// Restore the working directory to a snapshot (default the latest), removing files created since unless keepNewFiles
+func (c *Client) RollbackSession(ctx context.Context, id string, body handlers.RollbackRequest) (*handlers.RollbackResponse, error) {
+	var out handlers.RollbackResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/rollback", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSessionDirs calls GET /api/session/:id/dirs
+// The session's working directory and additional directories, as file explorer roots
+func (c *Client) GetSessionDirs(ctx context.Context, id string) (*handlers.SessionDirsResponse, error) {
+	var out handlers.SessionDirsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/dirs", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSessionDirs calls PUT /api/session/:id/dirs
+// Replace the additional directories (--add-dir) the session's next runs get
+func (c *Client) UpdateSessionDirs(ctx context.Context, id string, body handlers.SessionDirsRequest) (*handlers.SessionDirsResponse, error) {
+	var out handlers.SessionDirsResponse
+	if err := c.do(ctx, http.MethodPut, "/api/session/"+url.PathEscape(id)+"/dirs", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSessionFeedback calls GET /api/session/:id/feedback
+// Feedback on a session's messages
+func (c *Client) GetSessionFeedback(ctx context.Context, id string) (*GetSessionFeedbackResponse, error) {
+	var out GetSessionFeedbackResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/feedback", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExportFeedback calls GET /api/feedback
+// Feedback on visible sessions with the rated messages' text (format=jsonl downloads it)
+// Query parameters: rating, label, format
+func (c *Client) ExportFeedback(ctx context.Context, query url.Values) (*ExportFeedbackResponse, error) {
+	var out ExportFeedbackResponse
+	if err := c.do(ctx, http.MethodGet, "/api/feedback", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SearchSession calls GET /api/session/:id/search
+// Find messages in a session
+// Query parameters: q, limit
+func (c *Client) SearchSession(ctx context.Context, id string, query url.Values) (*handlers.SessionSearchResponse, error) {
+	var out handlers.SessionSearchResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/search", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSessionMtime calls GET /api/session/:id/mtime
+// Session file modification time
+func (c *Client) GetSessionMtime(ctx context.Context, id string) (*GetSessionMtimeResponse, error) {
+	var out GetSessionMtimeResponse
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/mtime", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSession calls DELETE /api/session/:id
+// Delete a session
+// Query parameters: project
+func (c *Client) DeleteSession(ctx context.Context, id string, query url.Values) (*DeleteSessionResponse, error) {
+	var out DeleteSessionResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/session/"+url.PathEscape(id), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CompactSession calls POST /api/session/:id/compact
+// Run /compact on a session and stream the turn
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) CompactSession(ctx context.Context, id string, body handlers.CompactRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/compact", nil, body)
+}
+
+// SummarizeSession calls POST /api/session/:id/summarize
+// Generate or return the cached session summary
+func (c *Client) SummarizeSession(ctx context.Context, id string, body handlers.SummarizeRequest) (*handlers.SummarizeResponse, error) {
+	var out handlers.SummarizeResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/summarize", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PinSession calls POST /api/session/:id/pin
+// Pin a session so storage cleanup keeps it
+func (c *Client) PinSession(ctx context.Context, id string) (*PinSessionResponse, error) {
+	var out PinSessionResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/pin", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnpinSession calls DELETE /api/session/:id/pin
+// Unpin a session
+func (c *Client) UnpinSession(ctx context.Context, id string) (*UnpinSessionResponse, error) {
+	var out UnpinSessionResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/session/"+url.PathEscape(id)+"/pin", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDraft calls GET /api/session/:id/draft
+// The session's unsent prompt
+func (c *Client) GetDraft(ctx context.Context, id string) (*handlers.SessionDraft, error) {
+	var out handlers.SessionDraft
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/draft", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PutDraft calls PUT /api/session/:id/draft
+// Save or clear the session's unsent prompt
+func (c *Client) PutDraft(ctx context.Context, id string, body handlers.SessionDraft) (*handlers.SessionDraft, error) {
+	var out handlers.SessionDraft
+	if err := c.do(ctx, http.MethodPut, "/api/session/"+url.PathEscape(id)+"/draft", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateShare calls POST /api/session/:id/share
+// Create an expiring read-only link to a session with secrets masked
+func (c *Client) CreateShare(ctx context.Context, id string, body handlers.ShareRequest) (*handlers.ShareResponse, error) {
+	var out handlers.ShareResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/share", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestRedaction calls POST /api/session/:id/redaction-report
+// Report what the redaction rules would mask when sharing a session
+func (c *Client) TestRedaction(ctx context.Context, id string, body handlers.RedactionTestRequest) (*handlers.RedactionReport, error) {
+	var out handlers.RedactionReport
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/redaction-report", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListShares calls GET /api/shares
+// Active share links
+// Query parameters: sessionId
+func (c *Client) ListShares(ctx context.Context, query url.Values) (*ListSharesResponse, error) {
+	var out ListSharesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/shares", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeShare calls DELETE /api/shares/:id
+// Revoke a share link
+func (c *Client) RevokeShare(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/shares/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSharedTranscript calls GET /api/shared/:token
+// A shared transcript (public; the token is the credential)
+func (c *Client) GetSharedTranscript(ctx context.Context, token string) (*handlers.SharedTranscript, error) {
+	var out handlers.SharedTranscript
+	if err := c.do(ctx, http.MethodGet, "/api/shared/"+url.PathEscape(token), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RewindSession calls POST /api/session/:id/rewind
+// Truncate or fork a session at a message
+func (c *Client) RewindSession(ctx context.Context, id string, body handlers.RewindRequest) (*handlers.RewindResponse, error) {
+	var out handlers.RewindResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/rewind", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ValidateSession calls GET /api/session/:id/validate
+// Find truncated or corrupt lines in a session file
+func (c *Client) ValidateSession(ctx context.Context, id string) (*handlers.SessionValidation, error) {
+	var out handlers.SessionValidation
+	if err := c.do(ctx, http.MethodGet, "/api/session/"+url.PathEscape(id)+"/validate", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RepairSession calls POST /api/session/:id/repair
+// Recover the parseable records of a corrupt session into a copy or in place
+func (c *Client) RepairSession(ctx context.Context, id string, body handlers.RepairRequest) (*handlers.RepairResponse, error) {
+	var out handlers.RepairResponse
+	if err := c.do(ctx, http.MethodPost, "/api/session/"+url.PathEscape(id)+"/repair", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Chat calls POST /api/chat
+// Run claude and stream its output
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) Chat(ctx context.Context, body handlers.ChatRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/chat", nil, body)
+}
+
+// InterruptChat calls DELETE /api/chat
+// Interrupt the process running a session
+// Query parameters: sessionId
+func (c *Client) InterruptChat(ctx context.Context, query url.Values) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/chat", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListApprovals calls GET /api/approvals
+// Tool calls of approval-mode runs waiting for a decision
+func (c *Client) ListApprovals(ctx context.Context) (*ListApprovalsResponse, error) {
+	var out ListApprovalsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/approvals", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DecideApproval calls POST /api/approvals/:id
+// Approve or deny a held tool call
+func (c *Client) DecideApproval(ctx context.Context, id string, body handlers.ApprovalDecision) (*DecideApprovalResponse, error) {
+	var out DecideApprovalResponse
+	if err := c.do(ctx, http.MethodPost, "/api/approvals/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResumeChatStream calls GET /api/chat/stream/:processId
+// Resume a chat stream after Last-Event-ID
+// Query parameters: lastEventId
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) ResumeChatStream(ctx context.Context, processId string, query url.Values) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/chat/stream/"+url.PathEscape(processId), query, nil)
+}
+
+// PollChat calls GET /api/chat/poll
+// Long-poll the events of a chat run, for clients whose network cuts streams
+// Query parameters: runId, cursor, wait
+func (c *Client) PollChat(ctx context.Context, query url.Values) (*handlers.ChatPollResponse, error) {
+	var out handlers.ChatPollResponse
+	if err := c.do(ctx, http.MethodGet, "/api/chat/poll", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartBatch calls POST /api/chat/batch
+// Run one prompt across working directories (or prompts in one), a few at a time
+func (c *Client) StartBatch(ctx context.Context, body handlers.BatchRequest) (*handlers.BatchStatus, error) {
+	var out handlers.BatchStatus
+	if err := c.do(ctx, http.MethodPost, "/api/chat/batch", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBatches calls GET /api/chat/batch
+// Recent batches without their runs
+func (c *Client) ListBatches(ctx context.Context) (*ListBatchesResponse, error) {
+	var out ListBatchesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/chat/batch", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetBatch calls GET /api/chat/batch/:id
+// A batch with the progress and results of its runs
+func (c *Client) GetBatch(ctx context.Context, id string) (*handlers.BatchStatus, error) {
+	var out handlers.BatchStatus
+	if err := c.do(ctx, http.MethodGet, "/api/chat/batch/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CancelBatch calls DELETE /api/chat/batch/:id
+// Cancel a batch's queued and running runs
+func (c *Client) CancelBatch(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/chat/batch/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CompareRuns calls GET /api/runs/compare
+// Two sessions aligned prompt by prompt: answers, durations, tokens, and files touched
+// Query parameters: a, b
+func (c *Client) CompareRuns(ctx context.Context, query url.Values) (*handlers.RunComparison, error) {
+	var out handlers.RunComparison
+	if err := c.do(ctx, http.MethodGet, "/api/runs/compare", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ChatInteractive calls POST /api/chat/interactive
+// Run claude, optionally continuing the last session
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) ChatInteractive(ctx context.Context, body handlers.ChatRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/chat/interactive", nil, body)
+}
+
+// CreateWSTicket calls POST /api/ws-ticket
+// Single-use ticket authenticating one WebSocket connection
+func (c *Client) CreateWSTicket(ctx context.Context) (*handlers.WSTicketResponse, error) {
+	var out handlers.WSTicketResponse
+	if err := c.do(ctx, http.MethodPost, "/api/ws-ticket", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListProcesses calls GET /api/processes
+// Running claude processes
+func (c *Client) ListProcesses(ctx context.Context) (*ListProcessesResponse, error) {
+	var out ListProcessesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/processes", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProcess calls GET /api/processes/:id
+// A running process with its recent output and current activity
+// Query parameters: lines
+func (c *Client) GetProcess(ctx context.Context, id string, query url.Values) (*handlers.ProcessDetail, error) {
+	var out handlers.ProcessDetail
+	if err := c.do(ctx, http.MethodGet, "/api/processes/"+url.PathEscape(id), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDiagnostics calls GET /api/diagnostics
+// Recognized claude CLI failures with suggested remediation
+// Query parameters: since, kind, sessionId
+func (c *Client) GetDiagnostics(ctx context.Context, query url.Values) (*handlers.DiagnosticsResponse, error) {
+	var out handlers.DiagnosticsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/diagnostics", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTodos calls GET /api/todos
+// Agent todo lists extracted from TodoWrite calls
+// Query parameters: sessionId, project, status
+func (c *Client) GetTodos(ctx context.Context, query url.Values) (*GetTodosResponse, error) {
+	var out GetTodosResponse
+	if err := c.do(ctx, http.MethodGet, "/api/todos", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUsage calls GET /api/usage
+// Monthly cost by working directory, with budgets
+// Query parameters: month, project
+func (c *Client) GetUsage(ctx context.Context, query url.Values) (*handlers.UsageResponse, error) {
+	var out handlers.UsageResponse
+	if err := c.do(ctx, http.MethodGet, "/api/usage", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetActivity calls GET /api/analytics/activity
+// Messages, runs, and cost per day, a weekday/hour heatmap, and tool, model, and project breakdowns
+// Query parameters: from, to, tz, project, limit
+func (c *Client) GetActivity(ctx context.Context, query url.Values) (*handlers.ActivityResponse, error) {
+	var out handlers.ActivityResponse
+	if err := c.do(ctx, http.MethodGet, "/api/analytics/activity", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDigest calls GET /api/digest
+// The activity digest the user would be mailed
+// Query parameters: period
+func (c *Client) GetDigest(ctx context.Context, query url.Values) (*handlers.Digest, error) {
+	var out handlers.Digest
+	if err := c.do(ctx, http.MethodGet, "/api/digest", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SendDigest calls POST /api/digest/send
+// Mail the configured digests now
+func (c *Client) SendDigest(ctx context.Context) (*handlers.DigestSendResponse, error) {
+	var out handlers.DigestSendResponse
+	if err := c.do(ctx, http.MethodPost, "/api/digest/send", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStorage calls GET /api/storage
+// Disk used by sessions per project, uploads, logs, and rewind backups
+func (c *Client) GetStorage(ctx context.Context) (*handlers.StorageReport, error) {
+	var out handlers.StorageReport
+	if err := c.do(ctx, http.MethodGet, "/api/storage", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CleanupStorage calls POST /api/storage/cleanup
+// Preview or delete files matching a cleanup policy
+func (c *Client) CleanupStorage(ctx context.Context, body handlers.StorageCleanupRequest) (*handlers.StorageCleanupResponse, error) {
+	var out handlers.StorageCleanupResponse
+	if err := c.do(ctx, http.MethodPost, "/api/storage/cleanup", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetBackupStatus calls GET /api/backup
+// Backup parts, upload schedule, and the last upload
+func (c *Client) GetBackupStatus(ctx context.Context) (*handlers.BackupStatus, error) {
+	var out handlers.BackupStatus
+	if err := c.do(ctx, http.MethodGet, "/api/backup", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateBackup calls POST /api/backup
+// Download a tar.gz of sessions, settings, commands, and server data, or upload it to backup.target
+func (c *Client) CreateBackup(ctx context.Context, body handlers.BackupRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/backup", nil, body)
+}
+
+// ListBudgets calls GET /api/budgets
+// Project budgets with this month's spend
+func (c *Client) ListBudgets(ctx context.Context) (*ListBudgetsResponse, error) {
+	var out ListBudgetsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/budgets", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateBudget calls POST /api/budgets
+// Add a monthly project budget
+func (c *Client) CreateBudget(ctx context.Context, body handlers.BudgetRequest) (*handlers.BudgetInfo, error) {
+	var out handlers.BudgetInfo
+	if err := c.do(ctx, http.MethodPost, "/api/budgets", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateBudget calls PUT /api/budgets/:id
+// Update a project budget
+func (c *Client) UpdateBudget(ctx context.Context, id string, body handlers.BudgetRequest) (*handlers.BudgetInfo, error) {
+	var out handlers.BudgetInfo
+	if err := c.do(ctx, http.MethodPut, "/api/budgets/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteBudget calls DELETE /api/budgets/:id
+// Delete a project budget
+func (c *Client) DeleteBudget(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/budgets/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTerminals calls GET /api/terminals
+// The user's terminals opened with a terminalId, attached or not
+func (c *Client) ListTerminals(ctx context.Context) (*ListTerminalsResponse, error) {
+	var out ListTerminalsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/terminals", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTerminalScrollback calls GET /api/terminals/:id/scrollback
+// Buffered output of a terminal, to restore before reattaching
+// Query parameters: lines
+func (c *Client) GetTerminalScrollback(ctx context.Context, id string, query url.Values) (*handlers.TerminalScrollback, error) {
+	var out handlers.TerminalScrollback
+	if err := c.do(ctx, http.MethodGet, "/api/terminals/"+url.PathEscape(id)+"/scrollback", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListLanguageServers calls GET /api/lsp/servers
+// Configured language servers, and those running in the user's directories
+func (c *Client) ListLanguageServers(ctx context.Context) (*ListLanguageServersResponse, error) {
+	var out ListLanguageServersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/lsp/servers", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListDirectories calls POST /api/directories
+// List subdirectories
+func (c *Client) ListDirectories(ctx context.Context, body handlers.ListDirectoriesRequest) (*handlers.ListDirectoriesResponse, error) {
+	var out handlers.ListDirectoriesResponse
+	if err := c.do(ctx, http.MethodPost, "/api/directories", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListFiles calls POST /api/files
+// List files and directories
+func (c *Client) ListFiles(ctx context.Context, body handlers.ListFilesRequest) (*handlers.ListFilesResponse, error) {
+	var out handlers.ListFilesResponse
+	if err := c.do(ctx, http.MethodPost, "/api/files", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListRemoteHosts calls GET /api/remote-hosts
+// SSH hosts usable as host:path working directories
+func (c *Client) ListRemoteHosts(ctx context.Context) (*ListRemoteHostsResponse, error) {
+	var out ListRemoteHostsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/remote-hosts", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListBackends calls GET /api/backends
+// Execution backends a chat can run in
+func (c *Client) ListBackends(ctx context.Context) (*ListBackendsResponse, error) {
+	var out ListBackendsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/backends", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListChatBackends calls GET /api/chat-backends
+// Chat backends (claude CLI, Anthropic API, agent CLIs) a chat can select
+func (c *Client) ListChatBackends(ctx context.Context) (*ListChatBackendsResponse, error) {
+	var out ListChatBackendsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/chat-backends", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetStreamPool calls GET /api/chat/pool
+// Processes kept by the stream chat backend, and how turns found theirs
+func (c *Client) GetStreamPool(ctx context.Context) (*handlers.StreamPoolStatus, error) {
+	var out handlers.StreamPoolStatus
+	if err := c.do(ctx, http.MethodGet, "/api/chat/pool", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetRecentFiles calls GET /api/projects/:id/recent-files
+// Files the user recently opened in the project, most recent first
+// Query parameters: limit
+func (c *Client) GetRecentFiles(ctx context.Context, id string, query url.Values) (*handlers.RecentFilesResponse, error) {
+	var out handlers.RecentFilesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/projects/"+url.PathEscape(id)+"/recent-files", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProjectContext calls GET /api/projects/:id/context
+// Files a project's recent sessions touched, the user opened, or git shows as changed
+// Query parameters: sessions, limit
+func (c *Client) GetProjectContext(ctx context.Context, id string, query url.Values) (*handlers.ProjectContextResponse, error) {
+	var out handlers.ProjectContextResponse
+	if err := c.do(ctx, http.MethodGet, "/api/projects/"+url.PathEscape(id)+"/context", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetProjectSettings calls GET /api/projects/:id/settings
+// A project's chat defaults
+// Query parameters: path
+func (c *Client) GetProjectSettings(ctx context.Context, id string, query url.Values) (*handlers.ProjectSettingsResponse, error) {
+	var out handlers.ProjectSettingsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/projects/"+url.PathEscape(id)+"/settings", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateProjectSettings calls PUT /api/projects/:id/settings
+// Replace a project's chat defaults
+// Query parameters: path
+func (c *Client) UpdateProjectSettings(ctx context.Context, id string, query url.Values, body handlers.ProjectSettings) (*handlers.ProjectSettingsResponse, error) {
+	var out handlers.ProjectSettingsResponse
+	if err := c.do(ctx, http.MethodPut, "/api/projects/"+url.PathEscape(id)+"/settings", query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReadFile calls POST /api/file/read
+// Read a text file
+func (c *Client) ReadFile(ctx context.Context, body handlers.ReadFileRequest) (*handlers.ReadFileResponse, error) {
+	var out handlers.ReadFileResponse
+	if err := c.do(ctx, http.MethodPost, "/api/file/read", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FileOutline calls POST /api/file/outline
+// Functions, types, and headings of a text file, for an outline or minimap
+func (c *Client) FileOutline(ctx context.Context, body handlers.OutlineRequest) (*handlers.OutlineResponse, error) {
+	var out handlers.OutlineResponse
+	if err := c.do(ctx, http.MethodPost, "/api/file/outline", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WriteFile calls POST /api/file/write
+// Write a text file, refusing if it changed since it was read
+func (c *Client) WriteFile(ctx context.Context, body handlers.WriteFileRequest) (*handlers.WriteFileResponse, error) {
+	var out handlers.WriteFileResponse
+	if err := c.do(ctx, http.MethodPost, "/api/file/write", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteFile calls POST /api/file/delete
+// Move a file or directory to the trash
+func (c *Client) DeleteFile(ctx context.Context, body handlers.DeleteFileRequest) (*handlers.TrashItem, error) {
+	var out handlers.TrashItem
+	if err := c.do(ctx, http.MethodPost, "/api/file/delete", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTrash calls GET /api/trash
+// Deleted files kept in the trash
+// Query parameters: project
+func (c *Client) ListTrash(ctx context.Context, query url.Values) (*handlers.TrashResponse, error) {
+	var out handlers.TrashResponse
+	if err := c.do(ctx, http.MethodGet, "/api/trash", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RestoreTrash calls POST /api/trash/:id/restore
+// Move a deleted file back to its original path
+func (c *Client) RestoreTrash(ctx context.Context, id string, body handlers.RestoreTrashRequest) (*handlers.TrashItem, error) {
+	var out handlers.TrashItem
+	if err := c.do(ctx, http.MethodPost, "/api/trash/"+url.PathEscape(id)+"/restore", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PurgeTrashItem calls DELETE /api/trash/:id
+// Delete a trashed file for good
+func (c *Client) PurgeTrashItem(ctx context.Context, id string) (*PurgeTrashItemResponse, error) {
+	var out PurgeTrashItemResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/trash/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PurgeTrash calls DELETE /api/trash
+// Empty the trash, optionally only for a project
+// Query parameters: project
+func (c *Client) PurgeTrash(ctx context.Context, query url.Values) (*PurgeTrashResponse, error) {
+	var out PurgeTrashResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/trash", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// LockFile calls POST /api/file/lock
+// Take or renew an advisory lock on a file before editing it
+func (c *Client) LockFile(ctx context.Context, body handlers.FileLockRequest) (*handlers.FileLock, error) {
+	var out handlers.FileLock
+	if err := c.do(ctx, http.MethodPost, "/api/file/lock", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnlockFile calls POST /api/file/unlock
+// Release a file lock
+func (c *Client) UnlockFile(ctx context.Context, body handlers.FileUnlockRequest) (*UnlockFileResponse, error) {
+	var out UnlockFileResponse
+	if err := c.do(ctx, http.MethodPost, "/api/file/unlock", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListFileLocks calls GET /api/file/locks
+// Active file locks, optionally under a directory
+// Query parameters: path
+func (c *Client) ListFileLocks(ctx context.Context, query url.Values) (*handlers.FileLocksResponse, error) {
+	var out handlers.FileLocksResponse
+	if err := c.do(ctx, http.MethodGet, "/api/file/locks", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExtractUpload calls POST /api/upload/extract
+// Unpack a zip, tar, or tar.gz archive (multipart field "file" or raw body) into a directory
+// Query parameters: path, overwrite, strip
+func (c *Client) ExtractUpload(ctx context.Context, query url.Values) (*handlers.ExtractResponse, error) {
+	var out handlers.ExtractResponse
+	if err := c.do(ctx, http.MethodPost, "/api/upload/extract", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetUploadedFile calls GET /api/upload/:filename
+// Download an uploaded file, or a thumbnail with w
+// Query parameters: w
+func (c *Client) GetUploadedFile(ctx context.Context, filename string, query url.Values) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/upload/"+url.PathEscape(filename), query, nil)
+}
+
+// DeleteUploadedFile calls DELETE /api/upload/:filename
+// Delete an uploaded file
+func (c *Client) DeleteUploadedFile(ctx context.Context, filename string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/upload/"+url.PathEscape(filename), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetPushConfig calls GET /api/push
+// VAPID public key and notification preferences
+func (c *Client) GetPushConfig(ctx context.Context) (*GetPushConfigResponse, error) {
+	var out GetPushConfigResponse
+	if err := c.do(ctx, http.MethodGet, "/api/push", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubscribePush calls POST /api/push/subscriptions
+// Register a browser push subscription
+func (c *Client) SubscribePush(ctx context.Context, body handlers.PushSubscription) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/push/subscriptions", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UnsubscribePush calls DELETE /api/push/subscriptions
+// Remove a push subscription
+// Query parameters: endpoint
+func (c *Client) UnsubscribePush(ctx context.Context, query url.Values) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/push/subscriptions", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdatePushPreferences calls PUT /api/push/preferences
+// Choose which events send notifications
+func (c *Client) UpdatePushPreferences(ctx context.Context, body handlers.PushPreferences) (*handlers.PushPreferences, error) {
+	var out handlers.PushPreferences
+	if err := c.do(ctx, http.MethodPut, "/api/push/preferences", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestPush calls POST /api/push/test
+// Send a test notification to all of the user's devices
+func (c *Client) TestPush(ctx context.Context) (*TestPushResponse, error) {
+	var out TestPushResponse
+	if err := c.do(ctx, http.MethodPost, "/api/push/test", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListWebhooks calls GET /api/webhooks
+// Outbound webhooks
+func (c *Client) ListWebhooks(ctx context.Context) (*ListWebhooksResponse, error) {
+	var out ListWebhooksResponse
+	if err := c.do(ctx, http.MethodGet, "/api/webhooks", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateWebhook calls POST /api/webhooks
+// Add a webhook
+func (c *Client) CreateWebhook(ctx context.Context, body handlers.WebhookRequest) (*handlers.WebhookInfo, error) {
+	var out handlers.WebhookInfo
+	if err := c.do(ctx, http.MethodPost, "/api/webhooks", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateWebhook calls PUT /api/webhooks/:id
+// Update a webhook
+func (c *Client) UpdateWebhook(ctx context.Context, id string, body handlers.WebhookRequest) (*handlers.WebhookInfo, error) {
+	var out handlers.WebhookInfo
+	if err := c.do(ctx, http.MethodPut, "/api/webhooks/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteWebhook calls DELETE /api/webhooks/:id
+// Delete a webhook
+func (c *Client) DeleteWebhook(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/webhooks/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestWebhook calls POST /api/webhooks/:id/test
+// Send a test delivery
+func (c *Client) TestWebhook(ctx context.Context, id string) (*handlers.WebhookDelivery, error) {
+	var out handlers.WebhookDelivery
+	if err := c.do(ctx, http.MethodPost, "/api/webhooks/"+url.PathEscape(id)+"/test", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSchedules calls GET /api/schedules
+// Scheduled prompts visible to the user
+func (c *Client) ListSchedules(ctx context.Context) (*ListSchedulesResponse, error) {
+	var out ListSchedulesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/schedules", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateSchedule calls POST /api/schedules
+// Schedule a recurring prompt
+func (c *Client) CreateSchedule(ctx context.Context, body handlers.ScheduleRequest) (*handlers.ScheduleInfo, error) {
+	var out handlers.ScheduleInfo
+	if err := c.do(ctx, http.MethodPost, "/api/schedules", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSchedule calls GET /api/schedules/:id
+// Schedule details
+func (c *Client) GetSchedule(ctx context.Context, id string) (*handlers.ScheduleInfo, error) {
+	var out handlers.ScheduleInfo
+	if err := c.do(ctx, http.MethodGet, "/api/schedules/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSchedule calls PUT /api/schedules/:id
+// Update or enable/disable a schedule
+func (c *Client) UpdateSchedule(ctx context.Context, id string, body handlers.ScheduleRequest) (*handlers.ScheduleInfo, error) {
+	var out handlers.ScheduleInfo
+	if err := c.do(ctx, http.MethodPut, "/api/schedules/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteSchedule calls DELETE /api/schedules/:id
+// Delete a schedule
+func (c *Client) DeleteSchedule(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/schedules/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunSchedule calls POST /api/schedules/:id/run
+// Run a schedule now
+func (c *Client) RunSchedule(ctx context.Context, id string) (*handlers.ScheduleRun, error) {
+	var out handlers.ScheduleRun
+	if err := c.do(ctx, http.MethodPost, "/api/schedules/"+url.PathEscape(id)+"/run", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetScheduleRuns calls GET /api/schedules/:id/runs
+// Run history, newest first
+// Query parameters: limit
+func (c *Client) GetScheduleRuns(ctx context.Context, id string, query url.Values) (*GetScheduleRunsResponse, error) {
+	var out GetScheduleRunsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/schedules/"+url.PathEscape(id)+"/runs", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListTemplates calls GET /api/templates
+// Own and shared prompt templates
+func (c *Client) ListTemplates(ctx context.Context) (*ListTemplatesResponse, error) {
+	var out ListTemplatesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/templates", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateTemplate calls POST /api/templates
+// Add a prompt template
+func (c *Client) CreateTemplate(ctx context.Context, body handlers.TemplateRequest) (*handlers.PromptTemplate, error) {
+	var out handlers.PromptTemplate
+	if err := c.do(ctx, http.MethodPost, "/api/templates", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetTemplate calls GET /api/templates/:id
+// Prompt template
+func (c *Client) GetTemplate(ctx context.Context, id string) (*handlers.PromptTemplate, error) {
+	var out handlers.PromptTemplate
+	if err := c.do(ctx, http.MethodGet, "/api/templates/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateTemplate calls PUT /api/templates/:id
+// Update a prompt template
+func (c *Client) UpdateTemplate(ctx context.Context, id string, body handlers.TemplateRequest) (*handlers.PromptTemplate, error) {
+	var out handlers.PromptTemplate
+	if err := c.do(ctx, http.MethodPut, "/api/templates/"+url.PathEscape(id), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteTemplate calls DELETE /api/templates/:id
+// Delete a prompt template
+func (c *Client) DeleteTemplate(ctx context.Context, id string) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/templates/"+url.PathEscape(id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RenderTemplate calls POST /api/templates/:id/render
+// Substitute variables into a template
+func (c *Client) RenderTemplate(ctx context.Context, id string, body handlers.TemplateRenderRequest) (*handlers.TemplateRenderResponse, error) {
+	var out handlers.TemplateRenderResponse
+	if err := c.do(ctx, http.MethodPost, "/api/templates/"+url.PathEscape(id)+"/render", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunTemplate calls POST /api/templates/:id/run
+// Render a template and stream a chat with it
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) RunTemplate(ctx context.Context, id string, body handlers.TemplateRunRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/templates/"+url.PathEscape(id)+"/run", nil, body)
+}
+
+// ListGitHubIssues calls GET /api/integrations/github/issues
+// Open issues of a configured GitHub repository
+// Query parameters: repo, labels, limit
+func (c *Client) ListGitHubIssues(ctx context.Context, query url.Values) (*handlers.GitHubIssuesResponse, error) {
+	var out handlers.GitHubIssuesResponse
+	if err := c.do(ctx, http.MethodGet, "/api/integrations/github/issues", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunGitHubIssue calls POST /api/integrations/github/issues/:id/run
+// Stream a chat seeded with a GitHub issue in its repository's workDir
+// Query parameters: repo
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) RunGitHubIssue(ctx context.Context, id string, query url.Values, body handlers.GitHubIssueRunRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/integrations/github/issues/"+url.PathEscape(id)+"/run", query, body)
+}
+
+// LinearWebhook calls POST /api/integrations/linear/webhook
+// Start the schedule runs a signed Linear issue event matches
+func (c *Client) LinearWebhook(ctx context.Context, body json.RawMessage) (*handlers.TicketWebhookResponse, error) {
+	var out handlers.TicketWebhookResponse
+	if err := c.do(ctx, http.MethodPost, "/api/integrations/linear/webhook", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// JiraWebhook calls POST /api/integrations/jira/webhook
+// Start the schedule runs a Jira issue event matches
+// Query parameters: secret
+func (c *Client) JiraWebhook(ctx context.Context, query url.Values, body json.RawMessage) (*handlers.TicketWebhookResponse, error) {
+	var out handlers.TicketWebhookResponse
+	if err := c.do(ctx, http.MethodPost, "/api/integrations/jira/webhook", query, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SlackEvents calls POST /api/integrations/slack/events
+// Slack Events API request URL; messages to the app are prompts
+func (c *Client) SlackEvents(ctx context.Context, body json.RawMessage) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/integrations/slack/events", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TelegramWebhook calls POST /api/integrations/telegram/webhook
+// Telegram bot webhook; messages to the bot are prompts
+func (c *Client) TelegramWebhook(ctx context.Context, body json.RawMessage) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/integrations/telegram/webhook", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListCommands calls GET /api/commands
+// Slash commands
+// Query parameters: work_dir
+func (c *Client) ListCommands(ctx context.Context, query url.Values) (*ListCommandsResponse, error) {
+	var out ListCommandsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/commands", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetCommand calls GET /api/commands/:name
+// Slash command detail
+// Query parameters: work_dir
+func (c *Client) GetCommand(ctx context.Context, name string, query url.Values) (*handlers.CommandDetail, error) {
+	var out handlers.CommandDetail
+	if err := c.do(ctx, http.MethodGet, "/api/commands/"+url.PathEscape(name), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RunCommand calls POST /api/commands/:name/run
+// Run a slash command
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) RunCommand(ctx context.Context, name string, body handlers.RunCommandRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/commands/"+url.PathEscape(name)+"/run", nil, body)
+}
+
+// ListAgents calls GET /api/agents
+// Agent definitions
+// Query parameters: work_dir
+func (c *Client) ListAgents(ctx context.Context, query url.Values) (*ListAgentsResponse, error) {
+	var out ListAgentsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/agents", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAgent calls GET /api/agents/:name
+// Agent definition
+// Query parameters: work_dir
+func (c *Client) GetAgent(ctx context.Context, name string, query url.Values) (*handlers.AssetDetail, error) {
+	var out handlers.AssetDetail
+	if err := c.do(ctx, http.MethodGet, "/api/agents/"+url.PathEscape(name), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListSkills calls GET /api/skills
+// Skill definitions
+// Query parameters: work_dir
+func (c *Client) ListSkills(ctx context.Context, query url.Values) (*ListSkillsResponse, error) {
+	var out ListSkillsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/skills", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSkill calls GET /api/skills/:name
+// Skill definition
+// Query parameters: work_dir
+func (c *Client) GetSkill(ctx context.Context, name string, query url.Values) (*handlers.AssetDetail, error) {
+	var out handlers.AssetDetail
+	if err := c.do(ctx, http.MethodGet, "/api/skills/"+url.PathEscape(name), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetConfig calls GET /api/config
+// CLAUDE.md and related config files
+// Query parameters: work_dir
+func (c *Client) GetConfig(ctx context.Context, query url.Values) (*GetConfigResponse, error) {
+	var out GetConfigResponse
+	if err := c.do(ctx, http.MethodGet, "/api/config", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ListPlugins calls GET /api/plugins
+// Installed plugins
+func (c *Client) ListPlugins(ctx context.Context) (*ListPluginsResponse, error) {
+	var out ListPluginsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/plugins", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// InstallPlugin calls POST /api/plugins/install
+// Install a plugin (streams progress)
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) InstallPlugin(ctx context.Context, body handlers.InstallPluginRequest) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/plugins/install", nil, body)
+}
+
+// UninstallPlugin calls DELETE /api/plugins/:name
+// Uninstall a plugin
+func (c *Client) UninstallPlugin(ctx context.Context, name string) (*UninstallPluginResponse, error) {
+	var out UninstallPluginResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/plugins/"+url.PathEscape(name), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdatePlugin calls POST /api/plugins/:name/update
+// Update a plugin (streams progress)
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) UpdatePlugin(ctx context.Context, name string) (*http.Response, error) {
+	return c.stream(ctx, http.MethodPost, "/api/plugins/"+url.PathEscape(name)+"/update", nil, nil)
+}
+
+// GetMCPServers calls GET /api/mcp
+// MCP servers
+// Query parameters: work_dir
+func (c *Client) GetMCPServers(ctx context.Context, query url.Values) (*GetMCPServersResponse, error) {
+	var out GetMCPServersResponse
+	if err := c.do(ctx, http.MethodGet, "/api/mcp", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddMCPServer calls POST /api/mcp/:name
+// Add an MCP server
+func (c *Client) AddMCPServer(ctx context.Context, name string, body handlers.MCPServerRequest) (*handlers.MCPServer, error) {
+	var out handlers.MCPServer
+	if err := c.do(ctx, http.MethodPost, "/api/mcp/"+url.PathEscape(name), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateMCPServer calls PUT /api/mcp/:name
+// Update an MCP server
+func (c *Client) UpdateMCPServer(ctx context.Context, name string, body handlers.MCPServerRequest) (*handlers.MCPServer, error) {
+	var out handlers.MCPServer
+	if err := c.do(ctx, http.MethodPut, "/api/mcp/"+url.PathEscape(name), nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteMCPServer calls DELETE /api/mcp/:name
+// Delete an MCP server
+// Query parameters: scope
+func (c *Client) DeleteMCPServer(ctx context.Context, name string, query url.Values) (*DeleteMCPServerResponse, error) {
+	var out DeleteMCPServerResponse
+	if err := c.do(ctx, http.MethodDelete, "/api/mcp/"+url.PathEscape(name), query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TestMCPServer calls POST /api/mcp/:name/test
+// Connect to an MCP server and list its tools
+// Query parameters: work_dir
+func (c *Client) TestMCPServer(ctx context.Context, name string, query url.Values) (*handlers.MCPTestResult, error) {
+	var out handlers.MCPTestResult
+	if err := c.do(ctx, http.MethodPost, "/api/mcp/"+url.PathEscape(name)+"/test", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetSettings calls GET /api/settings
+// Claude settings files
+// Query parameters: work_dir
+func (c *Client) GetSettings(ctx context.Context, query url.Values) (*GetSettingsResponse, error) {
+	var out GetSettingsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/settings", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateSettings calls PUT /api/settings
+// Write a settings file
+func (c *Client) UpdateSettings(ctx context.Context, body handlers.UpdateSettingsRequest) (*handlers.SettingsFile, error) {
+	var out handlers.SettingsFile
+	if err := c.do(ctx, http.MethodPut, "/api/settings", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHooks calls GET /api/hooks
+// Hook configuration
+// Query parameters: work_dir
+func (c *Client) GetHooks(ctx context.Context, query url.Values) (*GetHooksResponse, error) {
+	var out GetHooksResponse
+	if err := c.do(ctx, http.MethodGet, "/api/hooks", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateHooks calls PUT /api/hooks
+// Write hook configuration
+func (c *Client) UpdateHooks(ctx context.Context, body handlers.UpdateHooksRequest) (*handlers.HooksConfig, error) {
+	var out handlers.HooksConfig
+	if err := c.do(ctx, http.MethodPut, "/api/hooks", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHookLog calls GET /api/hooks/log
+// Recent hook executions
+// Query parameters: session_id, limit
+func (c *Client) GetHookLog(ctx context.Context, query url.Values) (*GetHookLogResponse, error) {
+	var out GetHookLogResponse
+	if err := c.do(ctx, http.MethodGet, "/api/hooks/log", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// IngestHookEvent calls POST /api/hooks/ingest
+// Record a claude hook's input, sent by a hook script with X-Greyzone-Hook-Secret
+func (c *Client) IngestHookEvent(ctx context.Context, body json.RawMessage) (*SuccessResponse, error) {
+	var out SuccessResponse
+	if err := c.do(ctx, http.MethodPost, "/api/hooks/ingest", nil, body, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetHookEvents calls GET /api/hooks/events
+// Hook events ingested for a session
+// Query parameters: session_id, limit
+func (c *Client) GetHookEvents(ctx context.Context, query url.Values) (*GetHookEventsResponse, error) {
+	var out GetHookEventsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/hooks/events", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetServerConfig calls GET /api/server/config
+// Effective server configuration
+func (c *Client) GetServerConfig(ctx context.Context) (*GetServerConfigResponse, error) {
+	var out GetServerConfigResponse
+	if err := c.do(ctx, http.MethodGet, "/api/server/config", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetServerLogs calls GET /api/server/logs
+// Recent log entries
+// Query parameters: limit, level, requestId, q
+func (c *Client) GetServerLogs(ctx context.Context, query url.Values) (*GetServerLogsResponse, error) {
+	var out GetServerLogsResponse
+	if err := c.do(ctx, http.MethodGet, "/api/server/logs", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetDoctor calls GET /api/server/doctor
+// Check the claude CLI and required tools
+// Query parameters: refresh
+func (c *Client) GetDoctor(ctx context.Context, query url.Values) (*handlers.DoctorReport, error) {
+	var out handlers.DoctorReport
+	if err := c.do(ctx, http.MethodGet, "/api/server/doctor", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetAuditLog calls GET /api/audit
+// Audit log
+// Query parameters: user, action, sessionId, since, until, limit
+func (c *Client) GetAuditLog(ctx context.Context, query url.Values) (*GetAuditLogResponse, error) {
+	var out GetAuditLogResponse
+	if err := c.do(ctx, http.MethodGet, "/api/audit", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetVersion calls GET /api/version
+// Server, claude CLI, and runtime versions
+// Query parameters: checkUpdates
+func (c *Client) GetVersion(ctx context.Context, query url.Values) (*handlers.VersionInfo, error) {
+	var out handlers.VersionInfo
+	if err := c.do(ctx, http.MethodGet, "/api/version", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetLocaleInfo calls GET /api/i18n
+// The locale of server-generated strings for this request, and those available
+// Query parameters: lang
+func (c *Client) GetLocaleInfo(ctx context.Context, query url.Values) (*handlers.LocaleInfo, error) {
+	var out handlers.LocaleInfo
+	if err := c.do(ctx, http.MethodGet, "/api/i18n", query, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// GetOpenAPISpec calls GET /api/openapi.json
+// This document
+func (c *Client) GetOpenAPISpec(ctx context.Context) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/openapi.json", nil, nil)
+}
+
+// GetState calls GET /api/state
+// Session processing state
+func (c *Client) GetState(ctx context.Context) (*handlers.AppState, error) {
+	var out handlers.AppState
+	if err := c.do(ctx, http.MethodGet, "/api/state", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SubscribeState calls GET /api/state/subscribe
+// Session processing state updates
+// The response body is a server-sent event stream; the caller must close it
+func (c *Client) SubscribeState(ctx context.Context) (*http.Response, error) {
+	return c.stream(ctx, http.MethodGet, "/api/state/subscribe", nil, nil)
+}
@@ -0,0 +1,174 @@
+// Command gen writes apiclient/client_gen.go from handlers.APIOperations
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"claude-web-ui/handlers"
+)
+
+// manual operations are hand-written in client.go or not plain HTTP
+var manual = map[string]bool{
+	"UploadFile":     true, // multipart body
+	"RestoreBackup":  true, // raw archive body
+	"ImportSessions": true, // multipart body
+}
+
+type generator struct {
+	imports map[string]bool
+	types   bytes.Buffer
+}
+
+// typeExpr renders t as Go source, qualifying named types with their package
+func (g *generator) typeExpr(t reflect.Type) string {
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		g.imports["encoding/json"] = true
+		return "json.RawMessage"
+	}
+	if t.Name() != "" && t.PkgPath() != "" {
+		g.imports[t.PkgPath()] = true
+		return path.Base(t.PkgPath()) + "." + t.Name()
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeExpr(t.Elem())
+	case reflect.Map:
+		return "map[" + g.typeExpr(t.Key()) + "]" + g.typeExpr(t.Elem())
+	case reflect.Interface:
+		return "interface{}"
+	case reflect.Struct:
+		var b strings.Builder
+		b.WriteString("struct {\n")
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			fmt.Fprintf(&b, "\t%s %s `%s`\n", f.Name, g.typeExpr(f.Type), f.Tag)
+		}
+		b.WriteString("}")
+		return b.String()
+	}
+	return t.Name()
+}
+
+// responseType returns the Go type an operation decodes into
+func (g *generator) responseType(op handlers.APIOperation) string {
+	t := reflect.TypeOf(op.Response)
+	if t.Kind() == reflect.Struct && t.Name() == "" {
+		name := op.OperationID + "Response"
+		fmt.Fprintf(&g.types, "// %s is the response of %s %s\ntype %s %s\n\n", name, op.Method, op.Path, name, g.typeExpr(t))
+		return name
+	}
+	if t.Name() == "successResponse" {
+		return "SuccessResponse"
+	}
+	return g.typeExpr(t)
+}
+
+func (g *generator) method(b *bytes.Buffer, op handlers.APIOperation) {
+	// Path parameters become string arguments
+	args := []string{"ctx context.Context"}
+	pathExpr := `"` + op.Path + `"`
+	var parts []string
+	for _, part := range strings.Split(op.Path, "/") {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			name := part[1:]
+			args = append(args, name+" string")
+			parts = append(parts, `" + url.PathEscape(`+name+`) + "`)
+			continue
+		}
+		parts = append(parts, part)
+	}
+	pathExpr = `"` + strings.Join(parts, "/") + `"`
+	pathExpr = strings.ReplaceAll(pathExpr, ` + ""`, "")
+
+	queryArg := "nil"
+	if len(op.Query) > 0 {
+		args = append(args, "query url.Values")
+		queryArg = "query"
+	}
+	bodyArg := "nil"
+	if op.Request != nil {
+		args = append(args, "body "+g.typeExpr(reflect.TypeOf(op.Request)))
+		bodyArg = "body"
+	}
+
+	fmt.Fprintf(b, "// %s calls %s %s\n", op.OperationID, op.Method, op.Path)
+	if op.Summary != "" {
+		fmt.Fprintf(b, "// %s\n", op.Summary)
+	}
+	if len(op.Query) > 0 {
+		fmt.Fprintf(b, "// Query parameters: %s\n", strings.Join(op.Query, ", "))
+	}
+	method := "http.Method" + strings.ToUpper(op.Method[:1]) + strings.ToLower(op.Method[1:])
+
+	if op.Response == nil {
+		if op.Stream != "" {
+			b.WriteString("// The response body is a server-sent event stream; the caller must close it\n")
+		}
+		fmt.Fprintf(b, "func (c *Client) %s(%s) (*http.Response, error) {\n", op.OperationID, strings.Join(args, ", "))
+		fmt.Fprintf(b, "\treturn c.stream(ctx, %s, %s, %s, %s)\n}\n\n", method, pathExpr, queryArg, bodyArg)
+		return
+	}
+
+	out := g.responseType(op)
+	fmt.Fprintf(b, "func (c *Client) %s(%s) (*%s, error) {\n", op.OperationID, strings.Join(args, ", "), out)
+	fmt.Fprintf(b, "\tvar out %s\n", out)
+	fmt.Fprintf(b, "\tif err := c.do(ctx, %s, %s, %s, %s, &out); err != nil {\n\t\treturn nil, err\n\t}\n", method, pathExpr, queryArg, bodyArg)
+	b.WriteString("\treturn &out, nil\n}\n\n")
+}
+
+func main() {
+	g := &generator{imports: map[string]bool{"context": true, "net/http": true, "net/url": true}}
+	var methods bytes.Buffer
+	for _, op := range handlers.APIOperations {
+		if manual[op.OperationID] || op.Stream == "websocket" {
+			continue
+		}
+		g.method(&methods, op)
+	}
+
+	// Standard library imports first, then module packages
+	var std, local []string
+	for imp := range g.imports {
+		if strings.Contains(strings.Split(imp, "/")[0], ".") || strings.HasPrefix(imp, "claude-web-ui/") {
+			local = append(local, imp)
+		} else {
+			std = append(std, imp)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(local)
+
+	var src bytes.Buffer
+	src.WriteString("// Code generated by apiclient/internal/gen; DO NOT EDIT.\n\npackage apiclient\n\nimport (\n")
+	for _, imp := range std {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	src.WriteString("\n")
+	for _, imp := range local {
+		fmt.Fprintf(&src, "\t%q\n", imp)
+	}
+	src.WriteString(")\n\n")
+	src.WriteString("// SuccessResponse is returned by endpoints that only report success\ntype SuccessResponse struct {\n\tSuccess bool `json:\"success\"`\n}\n\n")
+	src.Write(g.types.Bytes())
+	src.Write(methods.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		os.WriteFile("client_gen.go", src.Bytes(), 0644)
+		log.Fatalf("gofmt: %v", err)
+	}
+	if err := os.WriteFile("client_gen.go", formatted, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
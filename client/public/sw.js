@@ -0,0 +1,37 @@
+// Service worker for Web Push notifications from the Greyzone server.
+// Notifications are skipped while a Greyzone tab is focused.
+
+self.addEventListener('push', (event) => {
+  if (!event.data) return;
+  const data = event.data.json();
+
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((clients) => {
+      if (data.event !== 'test' && clients.some((c) => c.focused)) return;
+      return self.registration.showNotification(data.title, {
+        body: data.body,
+        tag: data.sessionId || data.event,
+        renotify: true,
+        requireInteraction: data.event === 'permission',
+        data: { url: data.url },
+      });
+    })
+  );
+});
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  const url = new URL(event.notification.data?.url || '/', self.location.origin).href;
+
+  event.waitUntil(
+    self.clients.matchAll({ type: 'window', includeUncontrolled: true }).then((clients) => {
+      for (const client of clients) {
+        if (client.url.startsWith(self.location.origin)) {
+          client.navigate(url);
+          return client.focus();
+        }
+      }
+      return self.clients.openWindow(url);
+    })
+  );
+});
@@ -5,6 +5,23 @@ import { WebLinksAddon } from '@xterm/addon-web-links';
 import { X, Terminal as TerminalIcon } from 'lucide-react';
 import 'xterm/css/xterm.css';
 
+// Opcodes of the framed terminal protocol (?protocol=framed): the first byte of every message
+const FRAME_DATA = 0x00;
+const FRAME_RESIZE = 0x01;
+const FRAME_ERROR = 0x05;
+const FRAME_EXIT = 0x06;
+const FRAME_WARNING = 0x07;
+
+const encoder = new TextEncoder();
+const decoder = new TextDecoder();
+
+function frame(op: number, payload: Uint8Array): Uint8Array {
+  const out = new Uint8Array(payload.length + 1);
+  out[0] = op;
+  out.set(payload, 1);
+  return out;
+}
+
 interface TerminalProps {
   isOpen: boolean;
   onClose: () => void;
@@ -72,10 +89,11 @@ export function Terminal({ isOpen, onClose }: TerminalProps) {
 
     // Setup WebSocket connection
     const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-    const wsUrl = `${protocol}//${window.location.host}/api/terminal`;
+    const wsUrl = `${protocol}//${window.location.host}/api/terminal?protocol=framed`;
 
     try {
       const ws = new WebSocket(wsUrl);
+      ws.binaryType = 'arraybuffer';
       wsRef.current = ws;
 
       ws.onopen = () => {
@@ -86,7 +104,25 @@ export function Terminal({ isOpen, onClose }: TerminalProps) {
       };
 
       ws.onmessage = (event) => {
-        term.write(event.data);
+        const data = new Uint8Array(event.data as ArrayBuffer);
+        if (data.length === 0) return;
+        const payload = data.subarray(1);
+        switch (data[0]) {
+          case FRAME_DATA:
+            term.write(payload);
+            break;
+          case FRAME_ERROR:
+            setConnectionError(decoder.decode(payload));
+            break;
+          case FRAME_EXIT:
+            term.writeln('');
+            term.writeln(`\x1b[33m● Process exited with code ${decoder.decode(payload)}\x1b[0m`);
+            break;
+          case FRAME_WARNING:
+            term.writeln('');
+            term.writeln(`\x1b[33m● ${decoder.decode(payload)}\x1b[0m`);
+            break;
+        }
       };
 
       ws.onerror = () => {
@@ -103,7 +139,7 @@ export function Terminal({ isOpen, onClose }: TerminalProps) {
       // Handle terminal input
       term.onData((data) => {
         if (ws.readyState === WebSocket.OPEN) {
-          ws.send(data);
+          ws.send(frame(FRAME_DATA, encoder.encode(data)));
         }
       });
     } catch (err) {
@@ -121,11 +157,10 @@ export function Terminal({ isOpen, onClose }: TerminalProps) {
           if (wsRef.current?.readyState === WebSocket.OPEN) {
             const dims = fitAddonRef.current.proposeDimensions();
             if (dims) {
-              wsRef.current.send(JSON.stringify({
-                type: 'resize',
-                cols: dims.cols,
-                rows: dims.rows,
-              }));
+              const size = new DataView(new ArrayBuffer(4));
+              size.setUint16(0, dims.cols);
+              size.setUint16(2, dims.rows);
+              wsRef.current.send(frame(FRAME_RESIZE, new Uint8Array(size.buffer)));
             }
           }
         } catch (err) {
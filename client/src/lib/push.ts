@@ -0,0 +1,77 @@
+// Web Push subscription helpers (see client/public/sw.js and /api/push)
+
+export interface PushPreferences {
+  onComplete: boolean;
+  onError: boolean;
+  onPermission: boolean;
+  minDurationSeconds: number;
+}
+
+interface PushConfig {
+  publicKey: string;
+  preferences: PushPreferences;
+  subscriptions: number;
+}
+
+const basePath = (window as { __BASE_PATH__?: string }).__BASE_PATH__ || '';
+
+export const pushSupported = () =>
+  'serviceWorker' in navigator && 'PushManager' in window && window.isSecureContext;
+
+function decodeKey(base64url: string): Uint8Array {
+  const base64 = base64url.replace(/-/g, '+').replace(/_/g, '/');
+  const raw = atob(base64 + '='.repeat((4 - (base64.length % 4)) % 4));
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+
+export async function getPushConfig(): Promise<PushConfig> {
+  const response = await fetch('/api/push');
+  if (!response.ok) throw new Error('Failed to load notification settings');
+  return response.json();
+}
+
+/** Asks for permission and registers this browser for notifications */
+export async function enablePushNotifications(): Promise<void> {
+  if (!pushSupported()) throw new Error('Notifications are not supported in this browser');
+  if ((await Notification.requestPermission()) !== 'granted') {
+    throw new Error('Notification permission was denied');
+  }
+
+  const { publicKey } = await getPushConfig();
+  const registration = await navigator.serviceWorker.register(`${basePath}/sw.js`);
+  await navigator.serviceWorker.ready;
+  const subscription =
+    (await registration.pushManager.getSubscription()) ||
+    (await registration.pushManager.subscribe({
+      userVisibleOnly: true,
+      applicationServerKey: decodeKey(publicKey),
+    }));
+
+  const response = await fetch('/api/push/subscriptions', {
+    method: 'POST',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(subscription.toJSON()),
+  });
+  if (!response.ok) throw new Error('Failed to register for notifications');
+}
+
+/** Unregisters this browser */
+export async function disablePushNotifications(): Promise<void> {
+  const registration = await navigator.serviceWorker.getRegistration(`${basePath}/sw.js`);
+  const subscription = await registration?.pushManager.getSubscription();
+  if (!subscription) return;
+  await fetch(`/api/push/subscriptions?endpoint=${encodeURIComponent(subscription.endpoint)}`, {
+    method: 'DELETE',
+  });
+  await subscription.unsubscribe();
+}
+
+export async function updatePushPreferences(preferences: PushPreferences): Promise<PushPreferences> {
+  const response = await fetch('/api/push/preferences', {
+    method: 'PUT',
+    headers: { 'Content-Type': 'application/json' },
+    body: JSON.stringify(preferences),
+  });
+  if (!response.ok) throw new Error('Failed to save notification preferences');
+  return response.json();
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+
+	"claude-web-ui/handlers"
+)
+
+// flagOverrides applies explicitly set command-line flags on top of the config file
+var flagOverrides = map[string]func(cfg *handlers.ServerConfig){}
+
+func stringFlag(name string, usage string, apply func(cfg *handlers.ServerConfig, v string)) {
+	v := flag.String(name, "", usage)
+	flagOverrides[name] = func(cfg *handlers.ServerConfig) { apply(cfg, *v) }
+}
+
+func intFlag(name string, usage string, apply func(cfg *handlers.ServerConfig, v int)) {
+	v := flag.Int(name, 0, usage)
+	flagOverrides[name] = func(cfg *handlers.ServerConfig) { apply(cfg, *v) }
+}
+
+func boolFlag(name string, usage string, apply func(cfg *handlers.ServerConfig, v bool)) {
+	v := flag.Bool(name, false, usage)
+	flagOverrides[name] = func(cfg *handlers.ServerConfig) { apply(cfg, *v) }
+}
+
+func listFlag(name string, usage string, apply func(cfg *handlers.ServerConfig, v []string)) {
+	stringFlag(name, usage, func(cfg *handlers.ServerConfig, v string) { apply(cfg, splitList(v)) })
+}
+
+// registerFlags declares every command-line flag
+func registerFlags() {
+	intFlag("port", "Server port (default 43210)", func(cfg *handlers.ServerConfig, v int) { cfg.Port = v })
+	stringFlag("host", "Bind address (default 127.0.0.1); use 0.0.0.0 to listen on all interfaces", func(cfg *handlers.ServerConfig, v string) { cfg.Host = v })
+	stringFlag("listen", "Full listen address host:port, overrides --host/--port", func(cfg *handlers.ServerConfig, v string) { cfg.Listen = v })
+	listFlag("trusted-proxies", "Comma-separated proxy IPs/CIDRs whose X-Forwarded-For is trusted", func(cfg *handlers.ServerConfig, v []string) { cfg.TrustedProxies = v })
+	listFlag("allowed-origins", "Comma-separated cross-origin URLs allowed to call the API, e.g. http://localhost:5173 (default: same-origin only)", func(cfg *handlers.ServerConfig, v []string) { cfg.AllowedOrigins = v })
+	stringFlag("base-path", "URL path prefix when served behind a reverse proxy, e.g. /claude", func(cfg *handlers.ServerConfig, v string) { cfg.BasePath = v })
+	stringFlag("log-dir", "Log directory (default ./logs)", func(cfg *handlers.ServerConfig, v string) { cfg.LogDir = v })
+	stringFlag("log-level", "Log level: debug, info, warn, error (default info)", func(cfg *handlers.ServerConfig, v string) { cfg.Logging.Level = v })
+	stringFlag("log-format", "Console log format: json or text (default json)", func(cfg *handlers.ServerConfig, v string) { cfg.Logging.Format = v })
+	stringFlag("data-dir", "Server data directory (default ~/.config/claude-web-ui)", func(cfg *handlers.ServerConfig, v string) { cfg.DataDir = v })
+	stringFlag("static-dir", "Serve the client from this directory instead of the embedded bundle (e.g. ./client/dist)", func(cfg *handlers.ServerConfig, v string) { cfg.StaticDir = v })
+
+	stringFlag("auth", "Authentication mode: none, local, or oidc (default none)", func(cfg *handlers.ServerConfig, v string) { cfg.Auth.Mode = v })
+	stringFlag("oidc-issuer", "OIDC issuer URL", func(cfg *handlers.ServerConfig, v string) { cfg.Auth.OIDCIssuer = v })
+	stringFlag("oidc-client-id", "OIDC client ID", func(cfg *handlers.ServerConfig, v string) { cfg.Auth.OIDCClientID = v })
+	stringFlag("oidc-client-secret", "OIDC client secret (prefer GREYZONE_OIDC_CLIENT_SECRET)", func(cfg *handlers.ServerConfig, v string) { cfg.Auth.OIDCClientSecret = v })
+	stringFlag("oidc-redirect-url", "OIDC redirect URL (…/api/auth/oidc/callback)", func(cfg *handlers.ServerConfig, v string) { cfg.Auth.OIDCRedirectURL = v })
+	listFlag("oidc-admin-emails", "Comma-separated emails granted the admin role", func(cfg *handlers.ServerConfig, v []string) { cfg.Auth.OIDCAdminEmails = v })
+
+	stringFlag("tls-cert", "TLS certificate file, generated if missing (default cert.pem)", func(cfg *handlers.ServerConfig, v string) { cfg.TLS.Cert = v })
+	stringFlag("tls-key", "TLS private key file, generated if missing (default key.pem)", func(cfg *handlers.ServerConfig, v string) { cfg.TLS.Key = v })
+	boolFlag("no-tls", "Serve plain HTTP instead of HTTPS", func(cfg *handlers.ServerConfig, v bool) { cfg.TLS.Disabled = v })
+	listFlag("autocert-domains", "Comma-separated domains for Let's Encrypt certificates", func(cfg *handlers.ServerConfig, v []string) { cfg.TLS.AutocertDomains = v })
+	stringFlag("autocert-cache", "Directory for Let's Encrypt certificates (default ./certs)", func(cfg *handlers.ServerConfig, v string) { cfg.TLS.AutocertCache = v })
+	stringFlag("autocert-email", "Contact email for Let's Encrypt", func(cfg *handlers.ServerConfig, v string) { cfg.TLS.AutocertEmail = v })
+	intFlag("write-timeout", "Seconds allowed to write a response, streams exempt (0 = none)", func(cfg *handlers.ServerConfig, v int) { cfg.HTTP.WriteTimeout = v })
+	intFlag("idle-timeout", "Seconds before idle keep-alive connections close (default 120)", func(cfg *handlers.ServerConfig, v int) { cfg.HTTP.IdleTimeout = v })
+	boolFlag("no-http2", "Serve HTTP/1.1 only over TLS", func(cfg *handlers.ServerConfig, v bool) { cfg.HTTP.DisableHTTP2 = v })
+
+	listFlag("allowed-roots", "Comma-separated directories the UI may access", func(cfg *handlers.ServerConfig, v []string) { cfg.AllowedRoots = v })
+	stringFlag("model", "Default model for chats", func(cfg *handlers.ServerConfig, v string) { cfg.Claude.DefaultModel = v })
+	stringFlag("permission-mode", "Default permission mode: default, acceptEdits, plan, bypassPermissions", func(cfg *handlers.ServerConfig, v string) { cfg.Claude.PermissionMode = v })
+	intFlag("max-concurrent-chats", "Maximum concurrent claude processes (0 = unlimited)", func(cfg *handlers.ServerConfig, v int) { cfg.Limits.MaxConcurrentChats = v })
+	intFlag("max-chats-per-user", "Maximum concurrent claude processes per user (0 = unlimited)", func(cfg *handlers.ServerConfig, v int) { cfg.Limits.MaxChatsPerUser = v })
+	intFlag("upload-max-size", "Maximum upload size in MB (default 10)", func(cfg *handlers.ServerConfig, v int) { cfg.Uploads.MaxSizeMB = v })
+}
+
+// loadConfig builds the effective configuration: defaults < config file < env < flags
+func loadConfig() (handlers.ServerConfig, string, error) {
+	configPath := flag.String("config", os.Getenv("GREYZONE_CONFIG"), "Config file (default ~/.config/claude-web-ui/config.yaml, or GREYZONE_CONFIG)")
+	registerFlags()
+	flag.Parse()
+
+	// --data-dir moves the default config location, so apply it first
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	cfg := handlers.DefaultServerConfig()
+	if dir := os.Getenv("GREYZONE_DATA_DIR"); dir != "" {
+		handlers.SetServerDataDir(dir)
+	}
+	if explicit["data-dir"] {
+		flagOverrides["data-dir"](&cfg)
+		handlers.SetServerDataDir(cfg.DataDir)
+	}
+
+	path := *configPath
+	required := path != ""
+	if path == "" {
+		path = handlers.DefaultConfigPath()
+	}
+	if err := handlers.LoadServerConfig(path, &cfg, required); err != nil {
+		return cfg, path, err
+	}
+	if err := cfg.ApplyEnv(); err != nil {
+		return cfg, path, err
+	}
+	for name := range explicit {
+		if apply, ok := flagOverrides[name]; ok {
+			apply(&cfg)
+		}
+	}
+
+	cfg.BasePath = normalizeBasePath(cfg.BasePath)
+	cfg.Auth.Mode = strings.ToLower(cfg.Auth.Mode)
+	if err := cfg.Validate(); err != nil {
+		return cfg, path, err
+	}
+	return cfg, path, nil
+}
@@ -0,0 +1,380 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AgentCLI is another coding agent CLI chats can run instead of claude
+// Command is a template; {prompt}, {sessionId} and {workDir} are replaced in each argument
+type AgentCLI struct {
+	Name    string   `yaml:"name" json:"name"`
+	Command []string `yaml:"command" json:"command"`
+	// ResumeCommand continues the agent's own session ({sessionId} is its session ID); default: Command
+	ResumeCommand []string `yaml:"resumeCommand" json:"resumeCommand,omitempty"`
+	// PlanArgs are appended in plan mode; without them plan mode is refused
+	PlanArgs []string `yaml:"planArgs" json:"planArgs,omitempty"`
+	// Format is how stdout is read: stream-json (claude-compatible), jsonl, or text
+	Format string `yaml:"format" json:"format"`
+	// TextField and SessionIDField are dot paths into jsonl lines, e.g. item.text
+	TextField      string   `yaml:"textField" json:"textField,omitempty"`
+	SessionIDField string   `yaml:"sessionIdField" json:"sessionIdField,omitempty"`
+	Env            []string `yaml:"env" json:"env,omitempty"` // KEY=VALUE added to the server environment
+}
+
+// ChatBackendInfo is a chat backend as shown to clients
+type ChatBackendInfo struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"` // cli, stream, api, or agent
+	Default bool   `json:"default,omitempty"`
+}
+
+// validateAgentCLIs checks the agentCLIs section
+func validateAgentCLIs(agents []AgentCLI) error {
+	seen := make(map[string]bool)
+	for i, a := range agents {
+		if a.Name == "" || chatBackendNames[a.Name] {
+			return fmt.Errorf("agentCLIs[%d]: name is required and must not be cli or api", i)
+		}
+		if seen[a.Name] {
+			return fmt.Errorf("agentCLIs[%d]: duplicate name %q", i, a.Name)
+		}
+		seen[a.Name] = true
+		if len(a.Command) == 0 {
+			return fmt.Errorf("agentCLIs[%d]: command is required", i)
+		}
+		switch a.Format {
+		case "stream-json", "text":
+		case "jsonl":
+			if a.TextField == "" {
+				return fmt.Errorf("agentCLIs[%d]: jsonl format needs textField", i)
+			}
+		default:
+			return fmt.Errorf("agentCLIs[%d]: format must be stream-json, jsonl, or text", i)
+		}
+	}
+	return nil
+}
+
+// findAgentCLI returns the configured agent CLI called name, or nil
+func findAgentCLI(agents []AgentCLI, name string) *AgentCLI {
+	for i := range agents {
+		if agents[i].Name == name {
+			return &agents[i]
+		}
+	}
+	return nil
+}
+
+// agentChatBackend runs an AgentCLI; stream-json output passes through, other formats are
+// converted to claude stream-json and recorded in ~/.claude/projects so sessions list alongside claude's
+type agentChatBackend struct {
+	agent AgentCLI
+}
+
+func (b agentChatBackend) Name() string { return b.agent.Name }
+
+func (b agentChatBackend) Start(turn ChatTurn) (ChatProcess, error) {
+	a := b.agent
+	if turn.Backend != "" {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s can't run on execution backend %s", a.Name, turn.Backend))
+	}
+	if _, _, ok := parseRemotePath(turn.WorkDir); ok {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s can't run in remote directories", a.Name))
+	}
+	if len(turn.MCPServers) > 0 || len(turn.ImagePaths) > 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("images and MCP servers need the cli chat backend"))
+	}
+	if turn.PlanMode && len(a.PlanArgs) == 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s has no plan mode", a.Name))
+	}
+	if turn.Prompt == "" {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("prompt is required"))
+	}
+
+	// stream-json agents keep their own sessions; the others get a transcript here
+	var stream *transcriptStream
+	agentSessionID := turn.SessionID
+	if a.Format != "stream-json" {
+		sessionID, path, err := resolveTranscript(turn)
+		if err != nil {
+			return nil, err
+		}
+		owner, nativeID := agentTranscriptOwner(path)
+		if owner != "" && owner != a.Name {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("session %s belongs to %s", sessionID, owner))
+		}
+		agentSessionID = nativeID
+		stream = &transcriptStream{
+			workDir:   turn.WorkDir,
+			sessionID: sessionID,
+			path:      path,
+			extra:     map[string]interface{}{"agent": a.Name},
+		}
+		if nativeID != "" {
+			stream.extra["agentSessionId"] = nativeID
+		}
+	}
+
+	template := a.Command
+	if agentSessionID != "" && len(a.ResumeCommand) > 0 {
+		template = a.ResumeCommand
+	}
+	args := expandAgentArgs(template, map[string]string{
+		"{prompt}":    turn.Prompt,
+		"{sessionId}": agentSessionID,
+		"{workDir}":   turn.WorkDir,
+	})
+	if turn.PlanMode {
+		args = append(args, a.PlanArgs...)
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = turn.WorkDir
+	cmd.Env = append(append(os.Environ(), a.Env...), turn.Env...)
+	if turn.Logger != nil {
+		turn.Logger.Info("Executing agent CLI", "agent", a.Name, "args", strings.Join(args, " "), "workDir", turn.WorkDir, "sessionId", turn.SessionID)
+	}
+
+	fail := func(what string, err error) (ChatProcess, error) {
+		return nil, withCode(http.StatusInternalServerError, errorCodeFor(err, ErrInternal), fmt.Errorf("%s: %w", what, err))
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fail("failed to create stdout pipe", err)
+	}
+
+	if stream == nil {
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return fail("failed to create stderr pipe", err)
+		}
+		startInProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			return fail("failed to start "+a.Name, err)
+		}
+		limitProcessResources(cmd)
+		return &cliProcess{cmd: cmd, stdout: stdout, stderr: stderr, cleanup: func() {}}, nil
+	}
+
+	// exec copies stderr into the pipe, so Wait can run before the handler has read it all
+	stderrR, stderrW := io.Pipe()
+	cmd.Stderr = stderrW
+	startInProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fail("failed to start "+a.Name, err)
+	}
+	limitProcessResources(cmd)
+
+	outR, outW := io.Pipe()
+	stream.out = outW
+	p := &agentProcess{cmd: cmd, stdout: outR, stderr: stderrR, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		err := a.convert(stream, stdout, cmd, turn.Prompt)
+		stderrW.Close()
+		outW.Close()
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+	}()
+	return p, nil
+}
+
+// convert reads the agent's stdout as claude stream-json and a transcript, then waits for it to exit
+func (a AgentCLI) convert(stream *transcriptStream, stdout io.Reader, cmd *exec.Cmd, prompt string) error {
+	started := time.Now()
+	stream.emit(map[string]interface{}{
+		"type":        "system",
+		"subtype":     "init",
+		"cwd":         stream.workDir,
+		"model":       a.Name,
+		"agent":       a.Name,
+		"tools":       []string{},
+		"mcp_servers": []interface{}{},
+	})
+	if err := stream.record("user", map[string]interface{}{"role": "user", "content": prompt}); err != nil {
+		log.Printf("[Agent] %s: failed to write transcript: %v", a.Name, err)
+	}
+
+	var parts []string
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text, nativeID := a.parseLine(scanner.Text())
+		if nativeID != "" {
+			stream.extra["agentSessionId"] = nativeID
+		}
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		parts = append(parts, text)
+		stream.emit(map[string]interface{}{
+			"type":               "assistant",
+			"message":            agentMessage(text),
+			"parent_tool_use_id": nil,
+		})
+	}
+	// Drain whatever is left so the agent never blocks on a full pipe
+	io.Copy(io.Discard, stdout)
+	err := cmd.Wait()
+
+	result := strings.Join(parts, "\n")
+	if result != "" {
+		if err := stream.record("assistant", agentMessage(result)); err != nil {
+			log.Printf("[Agent] %s: failed to write transcript: %v", a.Name, err)
+		}
+	}
+	subtype := "success"
+	if err != nil {
+		subtype = "error_during_execution"
+	}
+	stream.emit(map[string]interface{}{
+		"type":        "result",
+		"subtype":     subtype,
+		"is_error":    err != nil,
+		"duration_ms": time.Since(started).Milliseconds(),
+		"num_turns":   1,
+		"result":      result,
+	})
+	return err
+}
+
+// parseLine extracts the text and the agent's own session ID from one line of output
+func (a AgentCLI) parseLine(line string) (text string, sessionID string) {
+	if a.Format == "text" {
+		return line, ""
+	}
+	var v interface{}
+	if json.Unmarshal([]byte(line), &v) != nil {
+		return "", ""
+	}
+	return jsonPathString(v, a.TextField), jsonPathString(v, a.SessionIDField)
+}
+
+// agentMessage is an assistant message with a single text block
+func agentMessage(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"role":    "assistant",
+		"content": []apiBlock{{Type: "text", Text: text}},
+	}
+}
+
+// jsonPathString follows a dot path through JSON objects and returns the string found, or ""
+func jsonPathString(v interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		v = obj[key]
+	}
+	s, _ := v.(string)
+	return s
+}
+
+// expandAgentArgs substitutes placeholders in a command template
+// Without a {prompt} placeholder the prompt is passed as the last argument
+func expandAgentArgs(template []string, values map[string]string) []string {
+	args := make([]string, 0, len(template)+1)
+	hasPrompt := false
+	for _, arg := range template {
+		if strings.Contains(arg, "{prompt}") {
+			hasPrompt = true
+		}
+		for placeholder, value := range values {
+			arg = strings.ReplaceAll(arg, placeholder, value)
+		}
+		args = append(args, arg)
+	}
+	if !hasPrompt {
+		args = append(args, values["{prompt}"])
+	}
+	return args
+}
+
+// agentTranscriptOwner returns which agent wrote a transcript ("" for claude or a new one) and its last agent session ID
+func agentTranscriptOwner(path string) (agent string, sessionID string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Type           string `json:"type"`
+			Agent          string `json:"agent"`
+			AgentSessionID string `json:"agentSessionId"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || (line.Type != "user" && line.Type != "assistant") {
+			continue
+		}
+		if line.Agent == "" {
+			return "claude", ""
+		}
+		agent = line.Agent
+		if line.AgentSessionID != "" {
+			sessionID = line.AgentSessionID
+		}
+	}
+	return agent, sessionID
+}
+
+// agentProcess is a running agent CLI whose output is being converted
+type agentProcess struct {
+	cmd    *exec.Cmd
+	stdout *io.PipeReader
+	stderr *io.PipeReader
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (p *agentProcess) Stdout() io.Reader { return p.stdout }
+func (p *agentProcess) Stderr() io.Reader { return p.stderr }
+func (p *agentProcess) Stdin() io.Writer  { return nil }
+
+func (p *agentProcess) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *agentProcess) Interrupt() error { return killProcessGroup(p.cmd) }
+
+// ListChatBackends handles GET /api/chat-backends
+// Returns what a chat (per tab) can select as chatBackend
+func ListChatBackends(c *gin.Context) {
+	cfg := getServerConfig()
+	def := cfg.Claude.ChatBackend
+	if def == "" {
+		def = "cli"
+	}
+	backends := []ChatBackendInfo{
+		{Name: "cli", Type: "cli", Default: def == "cli"},
+		{Name: "stream", Type: "stream", Default: def == "stream"},
+		{Name: "api", Type: "api", Default: def == "api"},
+	}
+	for _, a := range cfg.AgentCLIs {
+		backends = append(backends, ChatBackendInfo{Name: a.Name, Type: "agent", Default: def == a.Name})
+	}
+	c.JSON(http.StatusOK, gin.H{"backends": backends})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Asset represents an agent or skill definition with parsed frontmatter
+type Asset struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Tools       []string `json:"tools,omitempty"`
+	Source      string   `json:"source"` // "global", "project", or plugin namespace
+	Path        string   `json:"path"`
+}
+
+// AssetDetail is an asset with its full markdown content
+type AssetDetail struct {
+	Asset
+	Frontmatter map[string]interface{} `json:"frontmatter"`
+	Content     string                 `json:"content"`
+}
+
+// skillFileNames are the accepted skill definition file names inside a skill directory
+var skillFileNames = []string{"SKILL.md", "skill.md"}
+
+// parseAssetFile reads an agent or skill markdown file into an AssetDetail
+func parseAssetFile(path string, defaultName string, source string) (*AssetDetail, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := parseFrontmatter(string(content))
+
+	name := frontmatterString(fields, "name")
+	if name == "" {
+		name = defaultName
+	}
+	tools := frontmatterStringList(fields, "tools")
+	if len(tools) == 0 {
+		tools = frontmatterStringList(fields, "allowed-tools")
+	}
+
+	return &AssetDetail{
+		Asset: Asset{
+			Name:        name,
+			Description: frontmatterString(fields, "description"),
+			Model:       frontmatterString(fields, "model"),
+			Tools:       tools,
+			Source:      source,
+			Path:        path,
+		},
+		Frontmatter: fields,
+		Content:     string(content),
+	}, nil
+}
+
+// scanAgentsInDir returns agents defined as *.md files in dir
+func scanAgentsInDir(dir string, source string) []AssetDetail {
+	var agents []AssetDetail
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return agents
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".md") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		if agent, err := parseAssetFile(path, strings.TrimSuffix(entry.Name(), ".md"), source); err == nil {
+			agents = append(agents, *agent)
+		}
+	}
+	return agents
+}
+
+// scanSkillsInDir returns skills defined as {name}/SKILL.md directories in dir
+func scanSkillsInDir(dir string, source string) []AssetDetail {
+	var skills []AssetDetail
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return skills
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		for _, fileName := range skillFileNames {
+			path := filepath.Join(dir, entry.Name(), fileName)
+			if skill, err := parseAssetFile(path, entry.Name(), source); err == nil {
+				skills = append(skills, *skill)
+				break
+			}
+		}
+	}
+	return skills
+}
+
+// collectAssets scans global, project, and plugin scopes for a kind ("agents" or "skills")
+func collectAssets(kind string, workDir string) []AssetDetail {
+	scan := scanAgentsInDir
+	if kind == "skills" {
+		scan = scanSkillsInDir
+	}
+
+	var all []AssetDetail
+	all = append(all, scan(filepath.Join(getClaudeDir(), kind), "global")...)
+	all = append(all, scan(filepath.Join(workDir, ".claude", kind), "project")...)
+
+	forEachInstalledPlugin(func(pluginName string, entry InstalledPluginEntry) {
+		pluginAssets := scan(filepath.Join(entry.InstallPath, kind), pluginName)
+		for i := range pluginAssets {
+			pluginAssets[i].Name = pluginName + ":" + pluginAssets[i].Name
+		}
+		all = append(all, pluginAssets...)
+	})
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+// listAssets handles GET /api/agents and GET /api/skills
+func listAssets(c *gin.Context, kind string) {
+	workDir := c.Query("work_dir")
+	if workDir == "" {
+		workDir = "."
+	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+	watchProject(currentUser(c), workDir)
+
+	assets := make([]Asset, 0)
+	for _, detail := range collectAssets(kind, workDir) {
+		assets = append(assets, detail.Asset)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		kind: assets,
+	})
+}
+
+// getAsset handles GET /api/agents/:name and GET /api/skills/:name
+func getAsset(c *gin.Context, kind string) {
+	workDir := c.Query("work_dir")
+	if workDir == "" {
+		workDir = "."
+	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+	name := c.Param("name")
+
+	// Later scopes (project, plugins) shadow global definitions of the same name
+	var found *AssetDetail
+	for _, detail := range collectAssets(kind, workDir) {
+		if detail.Name == name {
+			d := detail
+			found = &d
+		}
+	}
+	if found == nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, strings.TrimSuffix(kind, "s")+" not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, found)
+}
+
+// ListAgents returns all agent definitions from global, project, and plugin sources
+func ListAgents(c *gin.Context) {
+	listAssets(c, "agents")
+}
+
+// GetAgent returns a single agent definition with its full content
+func GetAgent(c *gin.Context) {
+	getAsset(c, "agents")
+}
+
+// ListSkills returns all skill definitions from global, project, and plugin sources
+func ListSkills(c *gin.Context) {
+	listAssets(c, "skills")
+}
+
+// GetSkill returns a single skill definition with its full content
+func GetSkill(c *gin.Context) {
+	getAsset(c, "skills")
+}
@@ -0,0 +1,536 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// activityDefaultDays is the range GET /api/analytics/activity covers without from
+	activityDefaultDays = 30
+	// activityMaxDays bounds the range of one request
+	activityMaxDays = 366
+	// activityProjectLimit is the default number of busiest projects returned
+	activityProjectLimit = 10
+	// runStatsDays is how long daily run counts are kept
+	runStatsDays = activityMaxDays + 31
+)
+
+// activityHour is what one transcript did in one clock hour (UTC)
+type activityHour struct {
+	user      int            // prompts typed by a person
+	assistant int            // API responses (lines sharing a message id count once)
+	tools     map[string]int // tool name -> calls
+	models    map[string]int // model -> responses
+}
+
+// activityIndex aggregates a transcript by hour; like historyIndex it is extended
+// as the file grows, so repeat requests only read appended lines
+type activityIndex struct {
+	mu          sync.Mutex
+	size        int64
+	sessionID   string
+	project     string
+	lastMessage string // id of the last assistant response, whose lines repeat
+	hours       map[int64]*activityHour
+}
+
+var (
+	activityMu      sync.Mutex
+	activityIndexes = make(map[string]*activityIndex) // keyed by .jsonl path
+)
+
+// update indexes lines appended since the last call; a file that shrank is reindexed from the start
+func (idx *activityIndex) update(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < idx.size || idx.hours == nil {
+		idx.size, idx.lastMessage = 0, ""
+		idx.hours = make(map[int64]*activityHour)
+	}
+	if info.Size() == idx.size {
+		return nil
+	}
+	if _, err := file.Seek(idx.size, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	offset := idx.size
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break // a partial line is still being written
+		}
+		offset += int64(len(line))
+
+		var msg Message
+		if json.Unmarshal(line, &msg) != nil || !isHistoryMessage(msg.Type) || msg.IsSidechain {
+			continue
+		}
+		if idx.project == "" && msg.CWD != "" {
+			idx.project = msg.CWD
+		}
+		stamp, err := time.Parse(time.RFC3339Nano, msg.Timestamp)
+		if err != nil {
+			continue
+		}
+		hourKey := stamp.Unix() / 3600
+		hour := idx.hours[hourKey]
+		if hour == nil {
+			hour = &activityHour{}
+			idx.hours[hourKey] = hour
+		}
+
+		if msg.Type != "assistant" {
+			if _, ok := userPromptText(msg.Message["content"]); ok {
+				hour.user++
+			}
+			continue
+		}
+		id, _ := msg.Message["id"].(string)
+		if id == "" || id != idx.lastMessage {
+			hour.assistant++
+			if model, ok := msg.Message["model"].(string); ok && model != "" && model != "<synthetic>" {
+				if hour.models == nil {
+					hour.models = make(map[string]int)
+				}
+				hour.models[model]++
+			}
+		}
+		idx.lastMessage = id
+		blocks, _ := msg.Message["content"].([]interface{})
+		for _, item := range blocks {
+			block, _ := item.(map[string]interface{})
+			if block["type"] != "tool_use" {
+				continue
+			}
+			name, _ := block["name"].(string)
+			if hour.tools == nil {
+				hour.tools = make(map[string]int)
+			}
+			hour.tools[name]++
+		}
+	}
+	idx.size = offset
+	return nil
+}
+
+// updateActivityIndexes brings the index of every transcript up to date and returns them
+// Indexes of deleted transcripts are dropped
+func updateActivityIndexes() ([]*activityIndex, error) {
+	projectsDir := getProjectsDir()
+	dirs, err := os.ReadDir(projectsDir)
+	if err != nil {
+		return nil, err
+	}
+	type transcript struct{ path, dirName string }
+	var files []transcript
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(projectsDir, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".jsonl") {
+				files = append(files, transcript{filepath.Join(projectsDir, dir.Name(), entry.Name()), dir.Name()})
+			}
+		}
+	}
+
+	indexes := make([]*activityIndex, len(files))
+	activityMu.Lock()
+	keep := make(map[string]bool, len(files))
+	for i, f := range files {
+		keep[f.path] = true
+		idx, ok := activityIndexes[f.path]
+		if !ok {
+			idx = &activityIndex{sessionID: strings.TrimSuffix(filepath.Base(f.path), ".jsonl")}
+			activityIndexes[f.path] = idx
+		}
+		indexes[i] = idx
+	}
+	for path := range activityIndexes {
+		if !keep[path] {
+			delete(activityIndexes, path)
+		}
+	}
+	activityMu.Unlock()
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < maxSessionScanWorkers && w < len(files); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				idx := indexes[i]
+				idx.mu.Lock()
+				if err := idx.update(files[i].path); err != nil {
+					idx.hours = nil // reindexed on the next request
+				}
+				if idx.project == "" {
+					idx.project = projectPathFromDir(files[i].dirName)
+				}
+				idx.mu.Unlock()
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return indexes, nil
+}
+
+// ActivityDay is one day of the range
+type ActivityDay struct {
+	Date              string `json:"date"` // YYYY-MM-DD in the requested time zone
+	Messages          int    `json:"messages"`
+	UserMessages      int    `json:"userMessages"`
+	AssistantMessages int    `json:"assistantMessages"`
+	ToolCalls         int    `json:"toolCalls"`
+	Sessions          int    `json:"sessions"` // sessions with a message that day
+	RunStats
+}
+
+// ActivityCount is a name and how often it occurred
+type ActivityCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// ProjectActivity is the activity of one working directory in the range
+type ProjectActivity struct {
+	Project    string `json:"project"`
+	Messages   int    `json:"messages"`
+	ToolCalls  int    `json:"toolCalls"`
+	Sessions   int    `json:"sessions"`
+	LastActive string `json:"lastActive"` // date of its last message
+}
+
+// ActivityTotals sums the range
+type ActivityTotals struct {
+	Messages   int `json:"messages"`
+	ToolCalls  int `json:"toolCalls"`
+	Sessions   int `json:"sessions"`
+	ActiveDays int `json:"activeDays"`
+	RunStats
+}
+
+// ActivityResponse is the response of GET /api/analytics/activity
+type ActivityResponse struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	TimeZone string            `json:"timeZone"`
+	Totals   ActivityTotals    `json:"totals"`
+	Days     []ActivityDay     `json:"days"`     // every day of the range, oldest first
+	Heatmap  [7][24]int        `json:"heatmap"`  // messages by weekday (0 = Sunday) and hour
+	Tools    []ActivityCount   `json:"tools"`    // most used first
+	Models   []ActivityCount   `json:"models"`   // by assistant responses
+	Projects []ProjectActivity `json:"projects"` // busiest first, by messages
+}
+
+// sortedCounts turns a count map into a list, largest first
+func sortedCounts(counts map[string]int) []ActivityCount {
+	list := make([]ActivityCount, 0, len(counts))
+	for name, count := range counts {
+		list = append(list, ActivityCount{Name: name, Count: count})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+// GetActivity handles GET /api/analytics/activity
+// Per-day message counts, runs, and cost, a weekday/hour heatmap, and tool, model, and project breakdowns
+// Transcripts are indexed incrementally, so only lines appended since the last request are read
+// Users see their own sessions; admins see all
+// Query parameters:
+//   - from, to: YYYY-MM-DD, inclusive (default: the last 30 days)
+//   - tz: IANA time zone for days and hours (default: the server's)
+//   - project: only this working directory
+//   - limit: busiest projects to return (default 10)
+func GetActivity(c *gin.Context) {
+	loc := time.Local
+	if tz := c.Query("tz"); tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid tz parameter")
+			return
+		}
+	}
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if value := c.Query("to"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid to parameter (expected YYYY-MM-DD)")
+			return
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, 1-activityDefaultDays)
+	if value := c.Query("from"); value != "" {
+		t, err := time.ParseInLocation("2006-01-02", value, loc)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid from parameter (expected YYYY-MM-DD)")
+			return
+		}
+		from = t
+	}
+	if from.After(to) || from.AddDate(0, 0, activityMaxDays).Before(to) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "from must be before to and at most 366 days apart")
+		return
+	}
+	limit := activityProjectLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+			return
+		}
+		limit = n
+	}
+
+	response, err := buildActivity(currentUser(c), from, to, loc, c.Query("project"), limit)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// buildActivity aggregates what the sessions user can see did from one day through another
+func buildActivity(user *User, from time.Time, to time.Time, loc *time.Location, project string, limit int) (ActivityResponse, error) {
+	indexes, err := updateActivityIndexes()
+	if err != nil {
+		return ActivityResponse{}, err
+	}
+
+	start, end := from.Unix(), to.AddDate(0, 0, 1).Unix()
+	response := ActivityResponse{
+		From:     from.Format("2006-01-02"),
+		To:       to.Format("2006-01-02"),
+		TimeZone: loc.String(),
+		Days:     []ActivityDay{},
+	}
+	days := make(map[string]*ActivityDay)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		response.Days = append(response.Days, ActivityDay{Date: d.Format("2006-01-02")})
+	}
+	for i := range response.Days {
+		days[response.Days[i].Date] = &response.Days[i]
+	}
+	tools, models := make(map[string]int), make(map[string]int)
+	projects := make(map[string]*ProjectActivity)
+
+	for _, idx := range indexes {
+		idx.mu.Lock()
+		if idx.hours == nil || (project != "" && idx.project != project) || !userCanAccessSession(user, idx.sessionID) {
+			idx.mu.Unlock()
+			continue
+		}
+		sessionDays := make(map[string]bool)
+		var p *ProjectActivity
+		for key, hour := range idx.hours {
+			at := key * 3600
+			if at < start || at >= end {
+				continue
+			}
+			local := time.Unix(at, 0).In(loc)
+			date := local.Format("2006-01-02")
+			day := days[date]
+			if day == nil {
+				continue
+			}
+			messages := hour.user + hour.assistant
+			calls := 0
+			for name, n := range hour.tools {
+				tools[name] += n
+				calls += n
+			}
+			for model, n := range hour.models {
+				models[model] += n
+			}
+			day.UserMessages += hour.user
+			day.AssistantMessages += hour.assistant
+			day.Messages += messages
+			day.ToolCalls += calls
+			response.Heatmap[local.Weekday()][local.Hour()] += messages
+			sessionDays[date] = true
+
+			if p == nil {
+				if p = projects[idx.project]; p == nil {
+					p = &ProjectActivity{Project: idx.project}
+					projects[idx.project] = p
+				}
+				p.Sessions++
+			}
+			p.Messages += messages
+			p.ToolCalls += calls
+			if date > p.LastActive {
+				p.LastActive = date
+			}
+		}
+		idx.mu.Unlock()
+		for date := range sessionDays {
+			days[date].Sessions++
+		}
+		if len(sessionDays) > 0 {
+			response.Totals.Sessions++
+		}
+	}
+
+	for _, day := range response.Days {
+		response.Totals.Messages += day.Messages
+		response.Totals.ToolCalls += day.ToolCalls
+		if day.Messages > 0 {
+			response.Totals.ActiveDays++
+		}
+	}
+	response.Tools = sortedCounts(tools)
+	response.Models = sortedCounts(models)
+	response.Projects = make([]ProjectActivity, 0, len(projects))
+	for _, p := range projects {
+		response.Projects = append(response.Projects, *p)
+	}
+	sort.Slice(response.Projects, func(i, j int) bool {
+		if response.Projects[i].Messages != response.Projects[j].Messages {
+			return response.Projects[i].Messages > response.Projects[j].Messages
+		}
+		return response.Projects[i].Project < response.Projects[j].Project
+	})
+	if len(response.Projects) > limit {
+		response.Projects = response.Projects[:limit]
+	}
+	addRunStats(&response, user, project)
+	return response, nil
+}
+
+// RunStats counts finished claude runs of every transport
+type RunStats struct {
+	Runs       int     `json:"runs"`
+	FailedRuns int     `json:"failedRuns"`
+	CostUSD    float64 `json:"costUsd"`
+}
+
+func (r *RunStats) add(o RunStats) {
+	r.Runs += o.Runs
+	r.FailedRuns += o.FailedRuns
+	r.CostUSD += o.CostUSD
+}
+
+var (
+	runStatsMu     sync.Mutex
+	runStats       map[string]map[string]map[string]*RunStats // date (server time) -> owner -> workDir -> stats
+	runStatsLoaded bool
+)
+
+func runStatsPath() string {
+	return serverDataPath("run-stats.json")
+}
+
+// loadRunStats reads run counts once; caller must hold runStatsMu
+func loadRunStats() error {
+	if runStatsLoaded {
+		return nil
+	}
+	runStats = make(map[string]map[string]map[string]*RunStats)
+	if err := loadJSONFile(runStatsPath(), &runStats); err != nil {
+		return err
+	}
+	runStatsLoaded = true
+	return nil
+}
+
+// recordRunStats counts a finished run for its day, dropping days older than runStatsDays
+func recordRunStats(owner string, workDir string, outcome string, result *resultEvent) {
+	stats := RunStats{Runs: 1}
+	if outcome == "error" || (result != nil && result.IsError) {
+		stats.FailedRuns = 1
+	}
+	if result != nil {
+		stats.CostUSD = result.TotalCostUSD
+	}
+	now := time.Now()
+	date := now.Format("2006-01-02")
+
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	if err := loadRunStats(); err != nil {
+		log.Printf("[Analytics] Failed to load run stats: %v", err)
+		return
+	}
+	if runStats[date] == nil {
+		runStats[date] = make(map[string]map[string]*RunStats)
+	}
+	if runStats[date][owner] == nil {
+		runStats[date][owner] = make(map[string]*RunStats)
+	}
+	if runStats[date][owner][workDir] == nil {
+		runStats[date][owner][workDir] = &RunStats{}
+	}
+	runStats[date][owner][workDir].add(stats)
+	oldest := now.AddDate(0, 0, -runStatsDays).Format("2006-01-02")
+	for d := range runStats {
+		if d < oldest {
+			delete(runStats, d)
+		}
+	}
+	if err := writeJSONFileAtomicMode(runStatsPath(), runStats, 0600); err != nil {
+		log.Printf("[Analytics] Failed to save run stats: %v", err)
+	}
+}
+
+// addRunStats fills in the runs user can see for each day of the response
+// Runs are counted by the server's day, whatever time zone the response uses
+func addRunStats(response *ActivityResponse, user *User, project string) {
+	runStatsMu.Lock()
+	defer runStatsMu.Unlock()
+	if err := loadRunStats(); err != nil {
+		log.Printf("[Analytics] Failed to load run stats: %v", err)
+		return
+	}
+	for i := range response.Days {
+		day := &response.Days[i]
+		for owner, dirs := range runStats[day.Date] {
+			if !userCanAccessOwner(user, owner) {
+				continue
+			}
+			for workDir, stats := range dirs {
+				if project == "" || workDir == project {
+					day.RunStats.add(*stats)
+				}
+			}
+		}
+		response.Totals.RunStats.add(day.RunStats)
+	}
+}
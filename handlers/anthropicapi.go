@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// anthropicVersion is the Messages API version the api chat backend speaks
+const anthropicVersion = "2023-06-01"
+
+// apiChatBackend talks to the Anthropic Messages API directly, for hosts without the claude CLI
+// It has no tools; turns are written to ~/.claude/projects in the CLI's transcript format
+// so sessions, history and the context meter work the same as for CLI chats
+type apiChatBackend struct{}
+
+func (apiChatBackend) Name() string { return "api" }
+
+// apiBlock is a Messages API content block
+type apiBlock struct {
+	Type   string          `json:"type"`
+	Text   string          `json:"text,omitempty"`
+	Source *apiImageSource `json:"source,omitempty"`
+}
+
+type apiImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+// apiMessage is a Messages API message
+type apiMessage struct {
+	Role    string     `json:"role"`
+	Content []apiBlock `json:"content"`
+}
+
+// apiUsage is the usage object of Messages API responses
+type apiUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+}
+
+func (apiChatBackend) Start(turn ChatTurn) (ChatProcess, error) {
+	if turn.Backend != "" {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("the api chat backend can't run on execution backend %s", turn.Backend))
+	}
+	if _, _, ok := parseRemotePath(turn.WorkDir); ok {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("the api chat backend can't run in remote directories"))
+	}
+	if len(turn.MCPServers) > 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("MCP servers need the cli chat backend"))
+	}
+	key := os.Getenv("ANTHROPIC_API_KEY")
+	if key == "" {
+		return nil, withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("the api chat backend needs ANTHROPIC_API_KEY"))
+	}
+
+	sessionID, transcript, err := resolveTranscript(turn)
+	if err != nil {
+		return nil, err
+	}
+
+	history, parentUUID, err := loadAPIHistory(transcript)
+	if err != nil {
+		return nil, withCode(http.StatusInternalServerError, ErrInternal, fmt.Errorf("failed to read session: %w", err))
+	}
+
+	var content []apiBlock
+	for _, path := range turn.ImagePaths {
+		block, err := apiImageBlock(path)
+		if err != nil {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, err)
+		}
+		content = append(content, block)
+	}
+	if turn.Prompt != "" {
+		content = append(content, apiBlock{Type: "text", Text: turn.Prompt})
+	}
+	if len(content) == 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("prompt is required"))
+	}
+	if n := len(history); n > 0 && history[n-1].Role == "user" {
+		// The previous turn never got an answer (e.g. it was interrupted)
+		history[n-1].Content = append(history[n-1].Content, content...)
+	} else {
+		history = append(history, apiMessage{Role: "user", Content: content})
+	}
+
+	cfg := getServerConfig().Claude.API
+	ctx, cancel := context.WithCancel(context.Background())
+	stdoutR, stdoutW := io.Pipe()
+	p := &apiProcess{
+		stdout: stdoutR,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	run := &apiRun{
+		transcriptStream: &transcriptStream{
+			out:        stdoutW,
+			workDir:    turn.WorkDir,
+			sessionID:  sessionID,
+			path:       transcript,
+			parentUUID: parentUUID,
+		},
+		key:      key,
+		cfg:      cfg,
+		messages: history,
+		prompt:   content,
+	}
+	if turn.Logger != nil {
+		turn.Logger.Info("Calling Anthropic API", "model", cfg.Model, "workDir", turn.WorkDir, "sessionId", sessionID, "messages", len(history))
+	}
+	go func() {
+		defer close(p.done)
+		err := run.run(ctx)
+		if ctx.Err() != nil {
+			err = nil // interrupted
+		}
+		p.mu.Lock()
+		p.err = err
+		p.mu.Unlock()
+		stdoutW.Close()
+	}()
+	return p, nil
+}
+
+// apiProcess is a running Messages API request
+type apiProcess struct {
+	stdout *io.PipeReader
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+func (p *apiProcess) Stdout() io.Reader { return p.stdout }
+func (p *apiProcess) Stderr() io.Reader { return strings.NewReader("") }
+func (p *apiProcess) Stdin() io.Writer  { return nil }
+
+func (p *apiProcess) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+func (p *apiProcess) Interrupt() error {
+	p.cancel()
+	return nil
+}
+
+// apiRun is the state of one api chat turn
+type apiRun struct {
+	*transcriptStream
+	key      string
+	cfg      AnthropicAPIConfig
+	messages []apiMessage
+	prompt   []apiBlock
+}
+
+func (r *apiRun) run(ctx context.Context) error {
+	started := time.Now()
+	if err := r.emit(map[string]interface{}{
+		"type":           "system",
+		"subtype":        "init",
+		"cwd":            r.workDir,
+		"model":          r.cfg.Model,
+		"tools":          []string{},
+		"mcp_servers":    []interface{}{},
+		"permissionMode": "default",
+		"apiKeySource":   "ANTHROPIC_API_KEY",
+	}); err != nil {
+		return err
+	}
+	if err := r.record("user", apiMessage{Role: "user", Content: r.prompt}); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      r.cfg.Model,
+		"max_tokens": r.cfg.MaxTokens,
+		"messages":   r.messages,
+		"stream":     true,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(r.cfg.BaseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", r.key)
+	req.Header.Set("anthropic-version", anthropicVersion)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return r.fail(started, withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API request failed: %w", err)))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var apiErr struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		msg := strings.TrimSpace(string(data))
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			msg = apiErr.Error.Type + ": " + apiErr.Error.Message
+		}
+		return r.fail(started, withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API returned %d: %s", resp.StatusCode, msg)))
+	}
+
+	// Accumulate the streamed message; text and thinking blocks are all a tool-less turn produces
+	var message struct {
+		ID         string                   `json:"id"`
+		Type       string                   `json:"type"`
+		Role       string                   `json:"role"`
+		Model      string                   `json:"model"`
+		Content    []map[string]interface{} `json:"content"`
+		StopReason string                   `json:"stop_reason"`
+		Usage      apiUsage                 `json:"usage"`
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		var event struct {
+			Type         string                 `json:"type"`
+			Index        int                    `json:"index"`
+			Message      json.RawMessage        `json:"message"`
+			ContentBlock map[string]interface{} `json:"content_block"`
+			Delta        struct {
+				Type       string `json:"type"`
+				Text       string `json:"text"`
+				Thinking   string `json:"thinking"`
+				Signature  string `json:"signature"`
+				StopReason string `json:"stop_reason"`
+			} `json:"delta"`
+			Usage *apiUsage `json:"usage"`
+			Error *struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			continue
+		}
+		switch event.Type {
+		case "message_start":
+			json.Unmarshal(event.Message, &message)
+		case "content_block_start":
+			if event.Index == len(message.Content) {
+				message.Content = append(message.Content, event.ContentBlock)
+			}
+		case "content_block_delta":
+			if event.Index >= len(message.Content) {
+				continue
+			}
+			block := message.Content[event.Index]
+			switch event.Delta.Type {
+			case "text_delta":
+				block["text"] = fmt.Sprint(block["text"]) + event.Delta.Text
+			case "thinking_delta":
+				block["thinking"] = fmt.Sprint(block["thinking"]) + event.Delta.Thinking
+			case "signature_delta":
+				block["signature"] = event.Delta.Signature
+			}
+		case "message_delta":
+			message.StopReason = event.Delta.StopReason
+			if event.Usage != nil {
+				message.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "error":
+			if event.Error != nil {
+				return r.fail(started, withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API error: %s: %s", event.Error.Type, event.Error.Message)))
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return r.fail(started, withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API stream failed: %w", err)))
+	}
+
+	if err := r.record("assistant", message); err != nil {
+		return fmt.Errorf("failed to write transcript: %w", err)
+	}
+	if err := r.emit(map[string]interface{}{
+		"type":               "assistant",
+		"message":            message,
+		"parent_tool_use_id": nil,
+	}); err != nil {
+		return err
+	}
+
+	var result strings.Builder
+	for _, block := range message.Content {
+		if block["type"] == "text" {
+			result.WriteString(fmt.Sprint(block["text"]))
+		}
+	}
+	return r.emit(map[string]interface{}{
+		"type":           "result",
+		"subtype":        "success",
+		"is_error":       false,
+		"duration_ms":    time.Since(started).Milliseconds(),
+		"num_turns":      1,
+		"result":         result.String(),
+		"total_cost_usd": 0,
+		"usage":          message.Usage,
+	})
+}
+
+// fail reports err as an error result event and returns it
+func (r *apiRun) fail(started time.Time, err error) error {
+	r.emit(map[string]interface{}{
+		"type":        "result",
+		"subtype":     "error_during_execution",
+		"is_error":    true,
+		"duration_ms": time.Since(started).Milliseconds(),
+		"num_turns":   1,
+		"result":      err.Error(),
+	})
+	return err
+}
+
+// loadAPIHistory rebuilds Messages API history from a session transcript
+// Only text survives: tool calls and results from CLI turns can't be replayed without the tools
+func loadAPIHistory(transcript string) ([]apiMessage, string, error) {
+	f, err := os.Open(transcript)
+	if os.IsNotExist(err) {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	defer f.Close()
+
+	var messages []apiMessage
+	lastUUID := ""
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Type        string `json:"type"`
+			UUID        string `json:"uuid"`
+			IsSidechain bool   `json:"isSidechain"`
+			IsMeta      bool   `json:"isMeta"`
+			Message     struct {
+				Role    string          `json:"role"`
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil || (line.Type != "user" && line.Type != "assistant") {
+			continue
+		}
+		if line.UUID != "" {
+			lastUUID = line.UUID
+		}
+		if line.IsSidechain || line.IsMeta {
+			continue
+		}
+		text := transcriptText(line.Message.Content)
+		if text == "" {
+			continue
+		}
+		if n := len(messages); n > 0 && messages[n-1].Role == line.Type {
+			messages[n-1].Content = append(messages[n-1].Content, apiBlock{Type: "text", Text: text})
+		} else if n > 0 || line.Type == "user" {
+			messages = append(messages, apiMessage{Role: line.Type, Content: []apiBlock{{Type: "text", Text: text}}})
+		}
+	}
+	return messages, lastUUID, scanner.Err()
+}
+
+// transcriptText joins the text blocks of a transcript message's content
+func transcriptText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.TrimSpace(s)
+	}
+	var blocks []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &blocks) != nil {
+		return ""
+	}
+	var parts []string
+	for _, b := range blocks {
+		if b.Type == "text" && strings.TrimSpace(b.Text) != "" {
+			parts = append(parts, b.Text)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// apiImageBlock reads an attached image as a base64 image block
+func apiImageBlock(path string) (apiBlock, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return apiBlock{}, fmt.Errorf("failed to read image: %w", err)
+	}
+	mediaType := http.DetectContentType(data)
+	switch mediaType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+	default:
+		return apiBlock{}, fmt.Errorf("unsupported image type %s: %s", mediaType, filepath.Base(path))
+	}
+	return apiBlock{Type: "image", Source: &apiImageSource{
+		Type:      "base64",
+		MediaType: mediaType,
+		Data:      base64.StdEncoding.EncodeToString(data),
+	}}, nil
+}
+
+// newUUID returns a random (version 4) UUID, the form claude uses for session IDs
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,310 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// apiKeyPrefix marks a bearer token as an API key rather than a login token
+	apiKeyPrefix = "gzk_"
+	// apiKeyMaxDays bounds expiresInDays; 0 means the key never expires
+	apiKeyMaxDays = 3650
+	// apiKeyUsedInterval is how stale lastUsedAt may get on disk, so busy keys don't rewrite the store per request
+	apiKeyUsedInterval = time.Minute
+	// apiKeyContextKey holds the scope of the key a request authenticated with
+	apiKeyContextKey = "apiKeyScope"
+)
+
+// apiKeyScopes ranks the scopes; each includes the ones below it
+//   - read: GET requests, without WebSocket connections
+//   - chat: everything the owner can do except admin routes
+//   - admin: everything the owner can do
+var apiKeyScopes = map[string]int{"read": 1, "chat": 2, "admin": 3}
+
+// APIKey is a long-lived token for scripts, cron jobs, and CI
+// It acts as its owner, limited to its scope
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scope      string     `json:"scope"` // read, chat, or admin
+	Owner      string     `json:"owner,omitempty"`
+	Prefix     string     `json:"prefix"` // the start of the key, to tell keys apart
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// APIKeyRequest is the body of POST /api/keys
+type APIKeyRequest struct {
+	Name          string `json:"name"`
+	Scope         string `json:"scope"`                   // read, chat, or admin
+	ExpiresInDays int    `json:"expiresInDays,omitempty"` // 0 never expires
+}
+
+// APIKeyResponse is the response of POST /api/keys; the key itself is only shown here
+type APIKeyResponse struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// apiKeyRecord is an API key as api-keys.json stores it: only the hash of the secret is kept
+type apiKeyRecord struct {
+	APIKey
+	Hash string `json:"hash"`
+}
+
+var (
+	apiKeysMu     sync.Mutex
+	apiKeys       map[string]*apiKeyRecord
+	apiKeysLoaded bool
+)
+
+func apiKeysPath() string {
+	return serverDataPath("api-keys.json")
+}
+
+// loadAPIKeys reads the store once; caller must hold apiKeysMu
+func loadAPIKeys() error {
+	if apiKeysLoaded {
+		return nil
+	}
+	if err := loadJSONFile(apiKeysPath(), &apiKeys); err != nil {
+		return err
+	}
+	if apiKeys == nil {
+		apiKeys = make(map[string]*apiKeyRecord)
+	}
+	apiKeysLoaded = true
+	return nil
+}
+
+// saveAPIKeys drops expired keys and writes the store; caller must hold apiKeysMu
+func saveAPIKeys() error {
+	now := time.Now()
+	for id, key := range apiKeys {
+		if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+			delete(apiKeys, id)
+		}
+	}
+	return writeJSONFileAtomicMode(apiKeysPath(), apiKeys, 0600)
+}
+
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// lookupAPIKey resolves a key to its record and owner
+// The owner comes back with the user role unless the key has the admin scope
+func lookupAPIKey(token string) (*APIKey, *User) {
+	id, secret, ok := strings.Cut(strings.TrimPrefix(token, apiKeyPrefix), ".")
+	if !ok {
+		return nil, nil
+	}
+	apiKeysMu.Lock()
+	if err := loadAPIKeys(); err != nil {
+		apiKeysMu.Unlock()
+		log.Printf("[APIKeys] Failed to load API keys: %v", err)
+		return nil, nil
+	}
+	record := apiKeys[id]
+	now := time.Now()
+	if record == nil || (record.ExpiresAt != nil && now.After(*record.ExpiresAt)) ||
+		subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hashAPIKeySecret(secret))) != 1 {
+		apiKeysMu.Unlock()
+		return nil, nil
+	}
+	persist := record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyUsedInterval
+	if persist {
+		record.LastUsedAt = &now
+		if err := saveAPIKeys(); err != nil {
+			log.Printf("[APIKeys] Failed to save API keys: %v", err)
+		}
+	}
+	key := record.APIKey
+	apiKeysMu.Unlock()
+
+	user := userByID(key.Owner)
+	if user == nil {
+		return nil, nil
+	}
+	if key.Scope != "admin" && user.IsAdmin() {
+		user.Role = "user"
+	}
+	return &key, user
+}
+
+// apiKeyScopeAllows reports whether a key's scope covers the request; admin routes also check it in requireAdmin
+func apiKeyScopeAllows(scope string, r *http.Request) bool {
+	if apiKeyScopes[scope] > apiKeyScopes["read"] {
+		return true
+	}
+	return (r.Method == http.MethodGet || r.Method == http.MethodHead) && !websocket.IsWebSocketUpgrade(r)
+}
+
+// requestAPIKeyScope is the scope of the API key the request authenticated with, or "" for a login
+func requestAPIKeyScope(c *gin.Context) string {
+	return c.GetString(apiKeyContextKey)
+}
+
+// forgetAPIKeys revokes the keys of a deleted user
+func forgetAPIKeys(userID string) {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	if err := loadAPIKeys(); err != nil {
+		return
+	}
+	changed := false
+	for id, key := range apiKeys {
+		if key.Owner == userID {
+			delete(apiKeys, id)
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveAPIKeys(); err != nil {
+			log.Printf("[APIKeys] Failed to save API keys: %v", err)
+		}
+	}
+}
+
+// requireKeyAuth rejects key management while authentication is off; every request is trusted then
+func requireKeyAuth(c *gin.Context) bool {
+	if !authEnabled() {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "API keys require authentication to be enabled")
+		return false
+	}
+	return true
+}
+
+// ListAPIKeys handles GET /api/keys
+// Users see their own keys; admins see everyone's
+func ListAPIKeys(c *gin.Context) {
+	if !requireKeyAuth(c) {
+		return
+	}
+	user := currentUser(c)
+	apiKeysMu.Lock()
+	err := loadAPIKeys()
+	keys := []APIKey{}
+	now := time.Now()
+	for _, key := range apiKeys {
+		if (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) || !userCanAccessOwner(user, key.Owner) {
+			continue
+		}
+		keys = append(keys, key.APIKey)
+	}
+	apiKeysMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load API keys", err.Error())
+		return
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	c.JSON(http.StatusOK, gin.H{"keys": keys})
+}
+
+// CreateAPIKey handles POST /api/keys
+// The key is returned once; the server keeps only its hash
+func CreateAPIKey(c *gin.Context) {
+	if !requireKeyAuth(c) {
+		return
+	}
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "name is required (at most 100 characters)")
+		return
+	}
+	if apiKeyScopes[req.Scope] == 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "scope must be read, chat, or admin")
+		return
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > apiKeyMaxDays {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("expiresInDays must be between 0 and %d", apiKeyMaxDays))
+		return
+	}
+	user := currentUser(c)
+	if req.Scope == "admin" && !user.IsAdmin() {
+		respondError(c, http.StatusForbidden, ErrForbidden, "Admin role required")
+		return
+	}
+	// A key can't mint a key with more access than its own
+	if scope := requestAPIKeyScope(c); scope != "" && apiKeyScopes[req.Scope] > apiKeyScopes[scope] {
+		respondError(c, http.StatusForbidden, ErrForbidden, fmt.Sprintf("An API key with the %s scope cannot create %s keys", scope, req.Scope))
+		return
+	}
+
+	now := time.Now()
+	secret := randomToken(32)
+	record := &apiKeyRecord{
+		APIKey: APIKey{
+			ID:        randomToken(12),
+			Name:      req.Name,
+			Scope:     req.Scope,
+			Owner:     ownerID(user),
+			CreatedAt: now,
+		},
+		Hash: hashAPIKeySecret(secret),
+	}
+	if req.ExpiresInDays > 0 {
+		expires := now.Add(time.Duration(req.ExpiresInDays) * 24 * time.Hour)
+		record.ExpiresAt = &expires
+	}
+	key := apiKeyPrefix + record.ID + "." + secret
+	record.Prefix = key[:len(apiKeyPrefix)+6]
+
+	apiKeysMu.Lock()
+	err := loadAPIKeys()
+	if err == nil {
+		apiKeys[record.ID] = record
+		err = saveAPIKeys()
+	}
+	apiKeysMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save API key", err.Error())
+		return
+	}
+	log.Printf("[APIKeys] %s created key %q (%s, scope %s)", auditUser(user), record.Name, record.ID, record.Scope)
+	c.JSON(http.StatusOK, APIKeyResponse{APIKey: record.APIKey, Key: key})
+}
+
+// RevokeAPIKey handles DELETE /api/keys/:id
+func RevokeAPIKey(c *gin.Context) {
+	if !requireKeyAuth(c) {
+		return
+	}
+	id := c.Param("id")
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	if err := loadAPIKeys(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load API keys", err.Error())
+		return
+	}
+	key := apiKeys[id]
+	if key == nil || !userCanAccessOwner(currentUser(c), key.Owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "API key not found")
+		return
+	}
+	delete(apiKeys, id)
+	if err := saveAPIKeys(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save API keys", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// approvalTokenHeader carries the run's token from its approval hook
+	approvalTokenHeader = "X-Greyzone-Approval-Token"
+	// approvalHookGrace is how much longer the CLI waits for the hook than the server waits for a decision
+	approvalHookGrace = 30 * time.Second
+)
+
+// approvalToolRegex matches a tool name that can go in a hook matcher
+var approvalToolRegex = regexp.MustCompile(`^[\w-]+$`)
+
+// PendingApproval is a tool call held until a client approves or denies it
+type PendingApproval struct {
+	ID        string          `json:"id"`
+	SessionID string          `json:"sessionId,omitempty"`
+	WorkDir   string          `json:"workDir"`
+	Tool      string          `json:"tool"`
+	ToolUseID string          `json:"toolUseId,omitempty"`
+	Detail    string          `json:"detail,omitempty"` // the most telling argument, e.g. the command
+	Input     json.RawMessage `json:"input"`
+	CreatedAt string          `json:"createdAt"`
+	ExpiresAt string          `json:"expiresAt"` // denied when still pending then
+
+	gate     *approvalGate
+	decision chan ApprovalDecision
+}
+
+// ApprovalDecision is the body of POST /api/approvals/:id and the payload of a WebSocket approve message
+type ApprovalDecision struct {
+	ApprovalID string `json:"approvalId,omitempty"` // WebSocket messages only
+	Approve    bool   `json:"approve"`
+	Reason     string `json:"reason,omitempty"` // passed to claude when denied
+	User       string `json:"-"`
+}
+
+// approvalGate holds the gated tool calls of one chat run
+type approvalGate struct {
+	token   string
+	owner   string
+	workDir string
+	tools   []string
+	done    chan struct{}
+
+	mu     sync.Mutex
+	notify func(event string, data map[string]interface{})
+}
+
+var (
+	approvalsMu      sync.Mutex
+	approvalGates    = make(map[string]*approvalGate)    // run token -> gate
+	pendingApprovals = make(map[string]*PendingApproval) // approval ID -> call
+
+	approvalSocketOnce sync.Once
+	approvalSocketErr  error
+)
+
+// approvalSocketPath is the Unix socket approval hooks reach the server on
+func approvalSocketPath() string {
+	return serverDataPath("approvals.sock")
+}
+
+// startApprovalSocket serves approval hooks on approvalSocketPath, once
+// A socket only the server's user can open keeps hooks off the network, its TLS, and its authentication
+func startApprovalSocket() error {
+	approvalSocketOnce.Do(func() {
+		path := approvalSocketPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			approvalSocketErr = err
+			return
+		}
+		os.Remove(path)
+		ln, err := net.Listen("unix", path)
+		if err != nil {
+			approvalSocketErr = err
+			return
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			ln.Close()
+			approvalSocketErr = err
+			return
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/hook", serveApprovalHook)
+		go func() {
+			if err := http.Serve(ln, mux); err != nil {
+				log.Printf("[Approvals] Hook socket stopped: %v", err)
+			}
+		}()
+	})
+	return approvalSocketErr
+}
+
+// newApprovalGate prepares approval mode for a run of chatBackend in workDir; tools defaults to claude.approvalTools
+func newApprovalGate(owner, workDir, backend, chatBackend string, tools []string) (*approvalGate, error) {
+	if chatBackend != "cli" {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("approval mode needs the cli chat backend"))
+	}
+	if !runsLocally(workDir, backend) {
+		return nil, localOnlyError("approval prompts", workDir, backend)
+	}
+	if len(tools) == 0 {
+		tools = getServerConfig().Claude.ApprovalTools
+	}
+	for _, tool := range tools {
+		if !approvalToolRegex.MatchString(tool) {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("invalid tool name in approvalTools: %q", tool))
+		}
+	}
+	if err := startApprovalSocket(); err != nil {
+		return nil, withCode(http.StatusInternalServerError, ErrInternal, fmt.Errorf("failed to start the approval socket: %w", err))
+	}
+	gate := &approvalGate{token: randomToken(24), owner: owner, workDir: workDir, tools: tools, done: make(chan struct{})}
+	approvalsMu.Lock()
+	approvalGates[gate.token] = gate
+	approvalsMu.Unlock()
+	return gate, nil
+}
+
+// settingsArgs writes the settings that install the run's approval hook and returns the claude arguments loading them
+func (g *approvalGate) settingsArgs() ([]string, func(), error) {
+	noop := func() {}
+	timeout := time.Duration(getServerConfig().Claude.ApprovalTimeoutSeconds)*time.Second + approvalHookGrace
+	// curl fails on anything but a decision, and exit code 2 makes claude skip the call
+	command := fmt.Sprintf("curl -sS --fail --max-time %d --unix-socket %s -H %s --data-binary @- http://approvals/hook"+
+		" || { echo 'The approval server could not be reached' >&2; exit 2; }",
+		int(timeout.Seconds()), shellQuote(approvalSocketPath()), shellQuote(approvalTokenHeader+": "+g.token))
+	settings := map[string]interface{}{
+		"hooks": map[string]interface{}{
+			"PreToolUse": []interface{}{map[string]interface{}{
+				"matcher": strings.Join(g.tools, "|"),
+				"hooks": []interface{}{map[string]interface{}{
+					"type":    "command",
+					"command": command,
+					"timeout": int(timeout.Seconds()),
+				}},
+			}},
+		},
+	}
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, noop, err
+	}
+	tmpFile, err := os.CreateTemp("", "claude-approval-*.json")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create approval settings file: %w", err)
+	}
+	defer tmpFile.Close()
+	if _, err := tmpFile.Write(data); err != nil {
+		os.Remove(tmpFile.Name())
+		return nil, noop, fmt.Errorf("failed to write approval settings file: %w", err)
+	}
+	path := tmpFile.Name()
+	return []string{"--settings", path}, func() { os.Remove(path) }, nil
+}
+
+// setNotify sets how the run's clients hear about approval requests and decisions,
+// and sends them the calls already waiting
+func (g *approvalGate) setNotify(notify func(event string, data map[string]interface{})) {
+	g.mu.Lock()
+	g.notify = notify
+	g.mu.Unlock()
+	for _, a := range listApprovals(func(a *PendingApproval) bool { return a.gate == g }) {
+		notify("approvalRequest", map[string]interface{}{"approval": a})
+	}
+}
+
+func (g *approvalGate) send(event string, data map[string]interface{}) {
+	g.mu.Lock()
+	notify := g.notify
+	g.mu.Unlock()
+	if notify != nil {
+		notify(event, data)
+	}
+}
+
+// close ends the run's approvals; calls still waiting are denied
+func (g *approvalGate) close() {
+	if g == nil {
+		return
+	}
+	approvalsMu.Lock()
+	delete(approvalGates, g.token)
+	approvalsMu.Unlock()
+	close(g.done)
+}
+
+// hold waits for a decision on a call; it is denied when none comes in claude.approvalTimeoutSeconds
+func (g *approvalGate) hold(ctx context.Context, a *PendingApproval) ApprovalDecision {
+	timeout := time.Duration(getServerConfig().Claude.ApprovalTimeoutSeconds) * time.Second
+	now := time.Now().UTC()
+	a.ID = generateID()
+	a.CreatedAt = now.Format(time.RFC3339)
+	a.ExpiresAt = now.Add(timeout).Format(time.RFC3339)
+	a.gate = g
+	a.decision = make(chan ApprovalDecision, 1)
+	approvalsMu.Lock()
+	pendingApprovals[a.ID] = a
+	approvalsMu.Unlock()
+	g.send("approvalRequest", map[string]interface{}{"approval": a})
+
+	var decision ApprovalDecision
+	select {
+	case decision = <-a.decision:
+	case <-time.After(timeout):
+		decision.Reason = fmt.Sprintf("Not approved within %d seconds", int(timeout.Seconds()))
+	case <-g.done:
+		decision.Reason = "The run ended"
+	case <-ctx.Done():
+		decision.Reason = "The approval hook went away"
+	}
+	approvalsMu.Lock()
+	delete(pendingApprovals, a.ID)
+	approvalsMu.Unlock()
+	g.send("approvalResolved", map[string]interface{}{
+		"approvalId": a.ID,
+		"approved":   decision.Approve,
+		"reason":     decision.Reason,
+		"user":       decision.User,
+	})
+	return decision
+}
+
+// serveApprovalHook answers a run's PreToolUse hook once its call is approved or denied
+func serveApprovalHook(w http.ResponseWriter, r *http.Request) {
+	approvalsMu.Lock()
+	gate := approvalGates[r.Header.Get(approvalTokenHeader)]
+	approvalsMu.Unlock()
+	if gate == nil {
+		http.Error(w, "unknown run", http.StatusForbidden)
+		return
+	}
+	var input struct {
+		SessionID string          `json:"session_id"`
+		ToolName  string          `json:"tool_name"`
+		ToolInput json.RawMessage `json:"tool_input"`
+		ToolUseID string          `json:"tool_use_id"`
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, hookEventMaxSize)).Decode(&input); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var args map[string]interface{}
+	json.Unmarshal(input.ToolInput, &args)
+	var decision ApprovalDecision
+	if lock := toolFileLock(gate.workDir, args); lock != nil {
+		// Someone is editing the file in the web UI; claude is told to wait rather than clobber it
+		decision.Reason = fmt.Sprintf("%s is locked for editing by %s; try again once it is released", lock.Path, fileLockHolder(lock))
+	} else {
+		decision = gate.hold(r.Context(), &PendingApproval{
+			SessionID: input.SessionID,
+			WorkDir:   gate.workDir,
+			Tool:      input.ToolName,
+			ToolUseID: input.ToolUseID,
+			Detail:    toolActivityDetail(args),
+			Input:     input.ToolInput,
+		})
+	}
+
+	permission, reason := "deny", decision.Reason
+	if decision.Approve {
+		permission = "allow"
+		if reason == "" {
+			reason = "Approved"
+		}
+	} else if reason == "" {
+		reason = "Denied"
+	}
+	if decision.User != "" {
+		reason += " by " + decision.User
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"hookSpecificOutput": map[string]interface{}{
+			"hookEventName":            "PreToolUse",
+			"permissionDecision":       permission,
+			"permissionDecisionReason": reason,
+		},
+	})
+}
+
+// listApprovals returns the waiting calls keep accepts, oldest first
+func listApprovals(keep func(*PendingApproval) bool) []PendingApproval {
+	approvalsMu.Lock()
+	list := []PendingApproval{}
+	for _, a := range pendingApprovals {
+		if keep(a) {
+			list = append(list, *a)
+		}
+	}
+	approvalsMu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt < list[j].CreatedAt })
+	return list
+}
+
+// decideApproval approves or denies a waiting call of a run user can see
+func decideApproval(user *User, id string, decision ApprovalDecision) error {
+	approvalsMu.Lock()
+	a := pendingApprovals[id]
+	if a == nil || !userCanAccessOwner(user, a.gate.owner) {
+		approvalsMu.Unlock()
+		return withCode(http.StatusNotFound, ErrNotFound, fmt.Errorf("approval not found or already decided"))
+	}
+	delete(pendingApprovals, id)
+	approvalsMu.Unlock()
+	if user != nil {
+		decision.User = user.Username
+	}
+	decision.Reason = strings.TrimSpace(decision.Reason)
+	a.decision <- decision
+	return nil
+}
+
+// ListApprovals handles GET /api/approvals
+// Returns the tool calls waiting for a decision in the user's runs, so a client that reconnects can show them again
+func ListApprovals(c *gin.Context) {
+	user := currentUser(c)
+	approvals := listApprovals(func(a *PendingApproval) bool { return userCanAccessOwner(user, a.gate.owner) })
+	c.JSON(http.StatusOK, gin.H{"approvals": approvals})
+}
+
+// DecideApproval handles POST /api/approvals/:id
+// Lets a held tool call run, or denies it with a reason claude is told
+func DecideApproval(c *gin.Context) {
+	var decision ApprovalDecision
+	if err := c.ShouldBindJSON(&decision); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if err := decideApproval(currentUser(c), c.Param("id"), decision); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"approvalId": c.Param("id"), "approved": decision.Approve})
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// fenceRegex matches the opening line of a fenced code block and its info string
+	fenceRegex = regexp.MustCompile("^\\s{0,3}(`{3,}|~{3,})\\s*(.*)$")
+	// artifactPathRegex matches a file path: no spaces, and an extension or a directory
+	artifactPathRegex = regexp.MustCompile(`^[\w.~/-]*[\w-]\.[\w]+$|^[\w.~-]*/[\w./-]*[\w-]$`)
+	// artifactTitleRegex finds title="path" or file=path in an info string
+	artifactTitleRegex = regexp.MustCompile(`(?:title|file|filename|path)=["']?([^"'\s]+)`)
+	// artifactLabelRegex matches a line naming the file of the block after it, e.g. **`src/app.go`**: or File: src/app.go
+	artifactLabelRegex = regexp.MustCompile("^[#>*_\\s-]*(?:(?i:file|path|filename)\\s*:\\s*)?[*_`]*([^\\s*_`:]+)[*_`]*\\s*:?\\s*$")
+	// artifactCommentRegex matches a first code line naming its file, e.g. // file: src/app.go
+	artifactCommentRegex = regexp.MustCompile(`^\s*(?://|#|--|/\*|<!--)\s*(?i:file(?:name)?\s*:\s*)?([^\s*]+?)\s*(?:\*/|-->)?\s*$`)
+)
+
+// Artifact is a fenced code block from an assistant message
+type Artifact struct {
+	ID          string `json:"id"` // message uuid and block number
+	MessageUUID string `json:"messageUuid"`
+	Timestamp   string `json:"timestamp"`
+	Language    string `json:"language,omitempty"`
+	// Path is the file the block was annotated with, absolute against the session's working directory;
+	// write it with POST /api/file/write
+	Path    string `json:"path,omitempty"`
+	Content string `json:"content"`
+	Lines   int    `json:"lines"`
+}
+
+// ArtifactsResponse is the response of GET /api/session/:id/artifacts
+type ArtifactsResponse struct {
+	SessionID string     `json:"sessionId"`
+	WorkDir   string     `json:"workDir,omitempty"`
+	Artifacts []Artifact `json:"artifacts"`
+}
+
+// artifactCache holds a transcript's artifacts until it changes
+type artifactCache struct {
+	size      int64
+	workDir   string
+	artifacts []Artifact
+}
+
+var (
+	artifactsMu    sync.Mutex
+	artifactCaches = make(map[string]*artifactCache) // keyed by .jsonl path
+)
+
+// artifactPath returns a path-looking candidate, or ""
+func artifactPath(s string) string {
+	s = strings.Trim(s, "`'\"")
+	if artifactPathRegex.MatchString(s) && !strings.Contains(s, "://") {
+		return s
+	}
+	return ""
+}
+
+// extractArtifacts returns the fenced code blocks of a message's text
+// A block's file comes from its info string (go title="app.go", go:app.go, or app.go), the line
+// before it (**app.go**, `app.go`:, File: app.go), or a comment on its first line (// app.go)
+func extractArtifacts(text string) []Artifact {
+	var artifacts []Artifact
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		m := fenceRegex.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		fence, info := m[1], strings.TrimSpace(m[2])
+		end := i + 1
+		for end < len(lines) && !(strings.HasPrefix(strings.TrimSpace(lines[end]), fence) && strings.Trim(strings.TrimSpace(lines[end]), fence[:1]) == "") {
+			end++
+		}
+		body := lines[i+1 : end]
+
+		artifact := Artifact{}
+		if fields := strings.Fields(info); len(fields) > 0 {
+			lang := fields[0]
+			if l, p, ok := strings.Cut(lang, ":"); ok && artifactPath(p) != "" {
+				lang, artifact.Path = l, p
+			} else if p := artifactPath(lang); p != "" && strings.ContainsAny(p, "./") {
+				lang, artifact.Path = "", p
+			}
+			artifact.Language = lang
+			if t := artifactTitleRegex.FindStringSubmatch(info); t != nil && artifactPath(t[1]) != "" {
+				artifact.Path = t[1]
+			}
+		}
+		if artifact.Path == "" {
+			for j := i - 1; j >= 0 && j >= i-2; j-- {
+				if strings.TrimSpace(lines[j]) == "" {
+					continue
+				}
+				if l := artifactLabelRegex.FindStringSubmatch(lines[j]); l != nil {
+					artifact.Path = artifactPath(l[1])
+				}
+				break
+			}
+		}
+		if artifact.Path == "" && len(body) > 0 {
+			if l := artifactCommentRegex.FindStringSubmatch(body[0]); l != nil {
+				artifact.Path = artifactPath(l[1])
+			}
+		}
+		if artifact.Language == "" && artifact.Path != "" {
+			artifact.Language = langMap[strings.ToLower(filepath.Ext(artifact.Path))]
+		}
+		artifact.Content = strings.Join(body, "\n")
+		if len(body) > 0 {
+			artifact.Content += "\n"
+		}
+		artifact.Lines = len(body)
+		if artifact.Lines > 0 {
+			artifacts = append(artifacts, artifact)
+		}
+		i = end
+	}
+	return artifacts
+}
+
+// sessionArtifacts returns the artifacts of a transcript, reparsed only when it grew or shrank
+func sessionArtifacts(path string) (*artifactCache, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	artifactsMu.Lock()
+	cached := artifactCaches[path]
+	artifactsMu.Unlock()
+	if cached != nil && cached.size == info.Size() {
+		return cached, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cache := &artifactCache{size: info.Size(), artifacts: []Artifact{}}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 32*1024*1024)
+	for scanner.Scan() {
+		var line struct {
+			Type      string `json:"type"`
+			UUID      string `json:"uuid"`
+			Timestamp string `json:"timestamp"`
+			CWD       string `json:"cwd"`
+			Message   struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &line) != nil {
+			continue
+		}
+		if line.CWD != "" && cache.workDir == "" {
+			cache.workDir = line.CWD
+		}
+		if line.Type != "assistant" || line.UUID == "" {
+			continue
+		}
+		for n, artifact := range extractArtifacts(transcriptText(line.Message.Content)) {
+			artifact.ID = fmt.Sprintf("%s-%d", line.UUID, n)
+			artifact.MessageUUID = line.UUID
+			artifact.Timestamp = line.Timestamp
+			switch {
+			case strings.HasPrefix(artifact.Path, "~"):
+				artifact.Path = expandHome(artifact.Path)
+			case artifact.Path != "" && !filepath.IsAbs(artifact.Path) && cache.workDir != "":
+				artifact.Path = filepath.Join(cache.workDir, artifact.Path)
+			}
+			cache.artifacts = append(cache.artifacts, artifact)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	artifactsMu.Lock()
+	artifactCaches[path] = cache
+	artifactsMu.Unlock()
+	return cache, nil
+}
+
+// GetArtifacts handles GET /api/session/:id/artifacts
+// Returns the code blocks of the session's assistant messages, oldest first
+// Query parameters:
+//   - language: only blocks in this language
+//   - withPath: "true" for only blocks annotated with a file
+func GetArtifacts(c *gin.Context) {
+	sessionID := c.Param("id")
+	path := ""
+	if userCanAccessSession(currentUser(c), sessionID) {
+		path = findSessionFile(sessionID)
+	}
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	cache, err := sessionArtifacts(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	language, withPath := c.Query("language"), c.Query("withPath") == "true"
+	resp := ArtifactsResponse{SessionID: sessionID, WorkDir: cache.workDir, Artifacts: []Artifact{}}
+	for _, artifact := range cache.artifacts {
+		if (language != "" && !strings.EqualFold(artifact.Language, language)) || (withPath && artifact.Path == "") {
+			continue
+		}
+		resp.Artifacts = append(resp.Artifacts, artifact)
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditEntry is one line of the append-only audit log
+type AuditEntry struct {
+	Time      time.Time         `json:"time"`
+	User      string            `json:"user"`
+	Action    string            `json:"action"` // e.g. chat.execute, session.delete, upload.create
+	Target    string            `json:"target,omitempty"`
+	SessionID string            `json:"sessionId,omitempty"`
+	WorkDir   string            `json:"workDir,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	ClientIP  string            `json:"clientIp,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+}
+
+// auditPromptLimit bounds how much of a prompt is kept in the audit log
+const auditPromptLimit = 200
+
+var auditMu sync.Mutex
+
+func auditLogPath() string {
+	return serverDataPath("audit.jsonl")
+}
+
+// newAuditEntry fills the who/when fields from the request
+func newAuditEntry(c *gin.Context, action string) AuditEntry {
+	return AuditEntry{
+		Time:      time.Now(),
+		User:      auditUser(currentUser(c)),
+		Action:    action,
+		ClientIP:  c.ClientIP(),
+		RequestID: requestID(c),
+	}
+}
+
+// auditUser names the actor; "local" when authentication is disabled
+func auditUser(u *User) string {
+	if u == nil {
+		return "local"
+	}
+	return u.Username
+}
+
+// auditPrompt truncates a prompt for the audit log
+func auditPrompt(prompt string) string {
+	return clipText(prompt, auditPromptLimit)
+}
+
+// recordAudit appends entry to the audit log
+// Failures are logged but never block the audited action
+func recordAudit(entry AuditEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("[Audit] Failed to encode entry: %v", err)
+		return
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	path := auditLogPath()
+	if err := os.MkdirAll(getServerDataDir(), 0700); err != nil {
+		log.Printf("[Audit] Failed to create data dir: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		log.Printf("[Audit] Failed to open %s: %v", path, err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		log.Printf("[Audit] Failed to write entry: %v", err)
+	}
+}
+
+// scanLinesBackward calls fn with each non-empty line of f, last line first, until fn returns false
+// The line is only valid during the call
+func scanLinesBackward(f *os.File, fn func(line []byte) bool) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, 64*1024)
+	var partial []byte // the start of the line the previous chunk began in
+	for off := info.Size(); off > 0; {
+		n := int64(len(buf))
+		if off < n {
+			n = off
+		}
+		off -= n
+		if _, err := f.ReadAt(buf[:n], off); err != nil {
+			return err
+		}
+		data := append(buf[:n:n], partial...)
+		for i := bytes.LastIndexByte(data, '\n'); i >= 0; i = bytes.LastIndexByte(data, '\n') {
+			if line := data[i+1:]; len(line) > 0 && !fn(line) {
+				return nil
+			}
+			data = data[:i]
+		}
+		partial = append(partial[:0:0], data...)
+	}
+	if len(partial) > 0 {
+		fn(partial)
+	}
+	return nil
+}
+
+// Audited records action once the wrapped route succeeds; the target is the
+// route's path parameter (e.g. :name or :id)
+func Audited(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if status := c.Writer.Status(); status < 200 || status >= 300 {
+			return
+		}
+		entry := newAuditEntry(c, action)
+		if len(c.Params) > 0 {
+			entry.Target = c.Params[0].Value
+		}
+		if strings.HasPrefix(action, "session.") {
+			entry.SessionID = entry.Target
+		}
+		recordAudit(entry)
+	}
+}
+
+// GetAuditLog handles GET /api/audit
+// Query parameters:
+//   - user: only entries by this username
+//   - action: action name or prefix (e.g. "chat" matches chat.execute and chat.interrupt)
+//   - sessionId: only entries for this session
+//   - since, until: RFC 3339 timestamps bounding the entry time
+//   - limit: maximum entries to return, newest first (default: 200)
+func GetAuditLog(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "200"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+	var since, until time.Time
+	if v := c.Query("since"); v != "" {
+		if since, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid since parameter (expected RFC 3339)")
+			return
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if until, err = time.Parse(time.RFC3339, v); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid until parameter (expected RFC 3339)")
+			return
+		}
+	}
+	user := c.Query("user")
+	action := c.Query("action")
+	sessionID := c.Query("sessionId")
+
+	auditMu.Lock()
+	f, err := os.Open(auditLogPath())
+	if os.IsNotExist(err) {
+		auditMu.Unlock()
+		c.JSON(http.StatusOK, gin.H{"entries": []AuditEntry{}})
+		return
+	}
+	if err != nil {
+		auditMu.Unlock()
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to open audit log", err.Error())
+		return
+	}
+
+	// The log is append-only, so reading it from the end yields the newest entries first
+	// and stops as soon as limit of them match
+	var entries []AuditEntry
+	err = scanLinesBackward(f, func(line []byte) bool {
+		var entry AuditEntry
+		if json.Unmarshal(line, &entry) != nil {
+			return true
+		}
+		if user != "" && entry.User != user {
+			return true
+		}
+		if action != "" && entry.Action != action && !strings.HasPrefix(entry.Action, action+".") {
+			return true
+		}
+		if sessionID != "" && entry.SessionID != sessionID {
+			return true
+		}
+		if !since.IsZero() && entry.Time.Before(since) {
+			return true
+		}
+		if !until.IsZero() && entry.Time.After(until) {
+			return true
+		}
+		entries = append(entries, entry)
+		return len(entries) < limit
+	})
+	f.Close()
+	auditMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read audit log", err.Error())
+		return
+	}
+	if entries == nil {
+		entries = []AuditEntry{}
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
@@ -0,0 +1,938 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// authCookieName is the cookie carrying the login session token
+	authCookieName = "greyzone_session"
+	// authSessionTTL is how long a login stays valid
+	authSessionTTL = 30 * 24 * time.Hour
+	// oidcStateTTL bounds how long an OIDC login may take
+	oidcStateTTL = 10 * time.Minute
+)
+
+// AuthConfig configures how users authenticate
+type AuthConfig struct {
+	Mode             string   `yaml:"mode" json:"mode"`                       // "none", "local", or "oidc"
+	OIDCIssuer       string   `yaml:"oidcIssuer" json:"oidcIssuer,omitempty"` // e.g. https://accounts.google.com
+	OIDCClientID     string   `yaml:"oidcClientId" json:"oidcClientId,omitempty"`
+	OIDCClientSecret string   `yaml:"oidcClientSecret" json:"-"`
+	OIDCRedirectURL  string   `yaml:"oidcRedirectUrl" json:"oidcRedirectUrl,omitempty"` // e.g. https://host:43210/api/auth/oidc/callback
+	OIDCAdminEmails  []string `yaml:"oidcAdminEmails" json:"oidcAdminEmails,omitempty"` // emails granted the admin role on first login
+	LoginRedirect    string   `yaml:"-" json:"-"`                                       // where the browser lands after OIDC login (default "/")
+}
+
+// User is a server account
+// Sessions, processes, and uploads are owned by a user ID
+type User struct {
+	ID           string   `json:"id"`
+	Username     string   `json:"username"`
+	Email        string   `json:"email,omitempty"`
+	PasswordHash string   `json:"passwordHash,omitempty"`
+	OIDCSubject  string   `json:"oidcSubject,omitempty"`
+	Role         string   `json:"role"`                   // "admin" or "user"
+	ProjectRoots []string `json:"projectRoots,omitempty"` // empty = unrestricted
+	CreatedAt    string   `json:"createdAt"`
+}
+
+// UserInfo is the public view of a User
+type UserInfo struct {
+	ID           string   `json:"id"`
+	Username     string   `json:"username"`
+	Email        string   `json:"email,omitempty"`
+	Role         string   `json:"role"`
+	ProjectRoots []string `json:"projectRoots"`
+	CreatedAt    string   `json:"createdAt"`
+}
+
+// UserRequest represents the request body for creating or updating a user
+type UserRequest struct {
+	Username     string    `json:"username"`
+	Email        string    `json:"email"`
+	Password     string    `json:"password"`
+	Role         string    `json:"role"`
+	ProjectRoots *[]string `json:"projectRoots"`
+	// CurrentPassword is required to change your own password
+	CurrentPassword string `json:"currentPassword,omitempty"`
+}
+
+// LoginRequest represents the request body for POST /api/auth/login
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// authSession is an authenticated login
+type authSession struct {
+	UserID  string
+	Expires time.Time
+}
+
+// oidcProvider holds the discovered OIDC endpoints
+type oidcProvider struct {
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// AuthManager owns users, login sessions, and session ownership
+type AuthManager struct {
+	config        AuthConfig
+	users         []User
+	usersMu       sync.RWMutex
+	sessions      map[string]authSession
+	sessionsMu    sync.Mutex
+	oidcStates    map[string]time.Time
+	oidc          *oidcProvider
+	sessionOwners map[string]string // Claude session ID -> user ID
+	ownersMu      sync.RWMutex
+}
+
+var authManager = &AuthManager{
+	config:        AuthConfig{Mode: "none"},
+	sessions:      make(map[string]authSession),
+	oidcStates:    make(map[string]time.Time),
+	sessionOwners: make(map[string]string),
+}
+
+// ConfigureAuth loads users and session ownership and enables authentication
+func ConfigureAuth(cfg AuthConfig) error {
+	if cfg.Mode == "" {
+		cfg.Mode = "none"
+	}
+	switch cfg.Mode {
+	case "none", "local", "oidc":
+	default:
+		return fmt.Errorf("invalid auth mode: %s", cfg.Mode)
+	}
+
+	am := authManager
+	am.config = cfg
+
+	if err := loadJSONFile(serverDataPath("users.json"), &am.users); err != nil {
+		return fmt.Errorf("failed to load users: %w", err)
+	}
+	if err := loadJSONFile(serverDataPath("session-owners.json"), &am.sessionOwners); err != nil {
+		return fmt.Errorf("failed to load session owners: %w", err)
+	}
+
+	if cfg.Mode == "oidc" {
+		if cfg.OIDCIssuer == "" || cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "" {
+			return fmt.Errorf("oidc mode requires issuer, client ID, and redirect URL")
+		}
+		provider, err := discoverOIDC(cfg.OIDCIssuer)
+		if err != nil {
+			return fmt.Errorf("OIDC discovery failed: %w", err)
+		}
+		am.oidc = provider
+	}
+
+	// Bootstrap an admin account for local auth on first run
+	if cfg.Mode == "local" && len(am.users) == 0 {
+		password := os.Getenv("GREYZONE_ADMIN_PASSWORD")
+		generated := password == ""
+		if generated {
+			password = randomToken(12)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		am.users = append(am.users, User{
+			ID:           generateID(),
+			Username:     "admin",
+			PasswordHash: string(hash),
+			Role:         "admin",
+			CreatedAt:    time.Now().UTC().Format(time.RFC3339),
+		})
+		if err := am.saveUsers(); err != nil {
+			return err
+		}
+		if generated {
+			// Logs can be read through /api/server/logs, so the password goes to a file only the server's user can read
+			path := serverDataPath("initial-admin-password")
+			if err := os.WriteFile(path, []byte(password+"\n"), 0600); err != nil {
+				return fmt.Errorf("failed to write the initial admin password: %w", err)
+			}
+			log.Printf("[Auth] Created initial admin account: username=admin, password in %s", path)
+		} else {
+			log.Printf("[Auth] Created initial admin account from GREYZONE_ADMIN_PASSWORD")
+		}
+	}
+
+	log.Printf("[Auth] Mode: %s (%d users)", cfg.Mode, len(am.users))
+	return nil
+}
+
+// authEnabled reports whether requests must be authenticated
+func authEnabled() bool {
+	return authManager.config.Mode != "none"
+}
+
+// randomToken returns a URL-safe random string with n bytes of entropy
+func randomToken(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func (am *AuthManager) saveUsers() error {
+	return writeJSONFileAtomicMode(serverDataPath("users.json"), am.users, 0600)
+}
+
+func (u *User) info() UserInfo {
+	roots := u.ProjectRoots
+	if roots == nil {
+		roots = []string{}
+	}
+	return UserInfo{
+		ID:           u.ID,
+		Username:     u.Username,
+		Email:        u.Email,
+		Role:         u.Role,
+		ProjectRoots: roots,
+		CreatedAt:    u.CreatedAt,
+	}
+}
+
+// IsAdmin reports whether the user has the admin role
+func (u *User) IsAdmin() bool {
+	return u.Role == "admin"
+}
+
+func (am *AuthManager) findUser(match func(*User) bool) *User {
+	am.usersMu.RLock()
+	defer am.usersMu.RUnlock()
+	for i := range am.users {
+		if match(&am.users[i]) {
+			u := am.users[i]
+			return &u
+		}
+	}
+	return nil
+}
+
+// usernameTakenLocked reports whether a user other than exceptID has username; caller must hold usersMu
+func (am *AuthManager) usernameTakenLocked(username, exceptID string) bool {
+	for _, existing := range am.users {
+		if existing.Username == username && existing.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+// userByID returns the user with the given ID (nil when auth is disabled or the user is gone)
+func userByID(id string) *User {
+	if id == "" {
+		return nil
+	}
+	return authManager.findUser(func(u *User) bool { return u.ID == id })
+}
+
+// createSession issues a login token for a user
+func (am *AuthManager) createSession(userID string) string {
+	token := randomToken(32)
+	am.sessionsMu.Lock()
+	defer am.sessionsMu.Unlock()
+	am.sessions[token] = authSession{UserID: userID, Expires: time.Now().Add(authSessionTTL)}
+	return token
+}
+
+// lookupSession resolves a login token to its user
+func (am *AuthManager) lookupSession(token string) *User {
+	if token == "" {
+		return nil
+	}
+	am.sessionsMu.Lock()
+	session, ok := am.sessions[token]
+	if ok && time.Now().After(session.Expires) {
+		delete(am.sessions, token)
+		ok = false
+	}
+	am.sessionsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return am.findUser(func(u *User) bool { return u.ID == session.UserID })
+}
+
+// revokeUserSessions logs a user out everywhere
+func (am *AuthManager) revokeUserSessions(userID string) {
+	am.sessionsMu.Lock()
+	defer am.sessionsMu.Unlock()
+	for token, session := range am.sessions {
+		if session.UserID == userID {
+			delete(am.sessions, token)
+		}
+	}
+}
+
+// requestToken extracts the login token or API key from the cookie or Authorization header
+func requestToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ")
+	}
+	if cookie, err := c.Cookie(authCookieName); err == nil {
+		return cookie
+	}
+	return ""
+}
+
+// isPublicPath reports whether a path can be accessed without logging in
+func isPublicPath(path string) bool {
+	if !strings.HasPrefix(path, "/api/") {
+		return true // SPA assets and health checks
+	}
+	switch path {
+	case "/api/auth/login", "/api/auth/status", "/api/auth/oidc/login", "/api/auth/oidc/callback":
+		return true
+	case "/api/hooks/ingest":
+		return true // hook scripts authenticate with hookIngest.secret
+	case "/api/integrations/linear/webhook", "/api/integrations/jira/webhook":
+		return true // trackers sign with integrations.<tracker>.webhookSecret
+	case "/api/integrations/slack/events", "/api/integrations/telegram/webhook":
+		return true // messengers authenticate with their signing or webhook secret
+	}
+	// Share links carry their own signed token
+	return strings.HasPrefix(path, "/api/shared/")
+}
+
+// AuthMiddleware authenticates API requests and stores the user in the context
+func AuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !authEnabled() {
+			c.Next()
+			return
+		}
+
+		token := requestToken(c)
+		user := authManager.lookupSession(token)
+		if user == nil && strings.HasPrefix(token, apiKeyPrefix) {
+			var key *APIKey
+			if key, user = lookupAPIKey(token); key != nil {
+				if !apiKeyScopeAllows(key.Scope, c.Request) {
+					abortError(c, http.StatusForbidden, ErrForbidden, fmt.Sprintf("API key scope %s does not allow this request", key.Scope))
+					return
+				}
+				c.Set(apiKeyContextKey, key.Scope)
+			}
+		}
+		if user == nil && websocket.IsWebSocketUpgrade(c.Request) {
+			user = wsRequestUser(c)
+		}
+		if user != nil {
+			c.Set("user", user)
+		} else if !isPublicPath(c.Request.URL.Path) {
+			abortError(c, http.StatusUnauthorized, ErrUnauthorized, "Authentication required")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// currentUser returns the authenticated user, or nil when auth is disabled
+func currentUser(c *gin.Context) *User {
+	if v, ok := c.Get("user"); ok {
+		if u, ok := v.(*User); ok {
+			return u
+		}
+	}
+	return nil
+}
+
+// ownerID returns the owner ID for resources created by this request ("" when auth is disabled)
+func ownerID(u *User) string {
+	if u == nil {
+		return ""
+	}
+	return u.ID
+}
+
+// userCanAccessPath reports whether a path is inside the user's project roots
+func userCanAccessPath(u *User, path string) bool {
+	if h, remotePath, ok := parseRemotePath(path); ok {
+		return userCanAccessRemotePath(u, h, remotePath)
+	}
+	if !withinAllowedRoots(path) {
+		return false
+	}
+	if u == nil || u.IsAdmin() || len(u.ProjectRoots) == 0 {
+		return true
+	}
+	cleanPath := filepath.Clean(path)
+	for _, root := range u.ProjectRoots {
+		root = filepath.Clean(root)
+		if cleanPath == root || strings.HasPrefix(cleanPath, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// userCanAccessSession reports whether a user owns a Claude session
+// Sessions without an owner (created outside the server) are admin-only
+func userCanAccessSession(u *User, sessionID string) bool {
+	if u == nil || u.IsAdmin() {
+		return true
+	}
+	authManager.ownersMu.RLock()
+	defer authManager.ownersMu.RUnlock()
+	return authManager.sessionOwners[sessionID] == u.ID
+}
+
+// userCanAccessOwner reports whether a user can see a resource with the given owner
+func userCanAccessOwner(u *User, owner string) bool {
+	return u == nil || u.IsAdmin() || owner == u.ID
+}
+
+// recordSessionOwner remembers which user created a Claude session
+// The file is saved under ownersMu so a save can't be overtaken by an older one
+func recordSessionOwner(sessionID string, owner string) {
+	if sessionID == "" || owner == "" {
+		return
+	}
+	am := authManager
+	am.ownersMu.Lock()
+	defer am.ownersMu.Unlock()
+	if _, taken := am.sessionOwners[sessionID]; taken {
+		return
+	}
+	am.sessionOwners[sessionID] = owner
+
+	if err := writeJSONFileAtomic(serverDataPath("session-owners.json"), am.sessionOwners); err != nil {
+		log.Printf("[Auth] Failed to save session owners: %v", err)
+	}
+}
+
+// extractInitSessionID returns the session_id from a system/init stream event
+func extractInitSessionID(line string) string {
+	if !strings.Contains(line, `"init"`) {
+		return ""
+	}
+	var event struct {
+		Type      string `json:"type"`
+		Subtype   string `json:"subtype"`
+		SessionID string `json:"session_id"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return ""
+	}
+	if event.Type != "system" || event.Subtype != "init" {
+		return ""
+	}
+	return event.SessionID
+}
+
+// requireAdmin aborts the request unless the user is an admin (or auth is disabled)
+// An API key also needs the admin scope, whoever owns it
+func requireAdmin(c *gin.Context) bool {
+	if u := currentUser(c); u != nil && !u.IsAdmin() {
+		respondError(c, http.StatusForbidden, ErrForbidden, "Admin role required")
+		return false
+	}
+	if scope := requestAPIKeyScope(c); scope != "" && scope != "admin" {
+		respondError(c, http.StatusForbidden, ErrForbidden, "API key scope admin required")
+		return false
+	}
+	return true
+}
+
+// AdminOnly is route middleware restricting server-wide configuration changes to admins
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !requireAdmin(c) {
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// denyPath writes a 403 response for a path outside the user's project roots
+func denyPath(c *gin.Context, path string) {
+	respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Access to %s is not allowed", path))
+}
+
+// requireWorkDirAccess denies the request unless the user can access workDir, whose .claude configuration it reads
+// A relative workDir (the "." default) is resolved against the server's directory
+func requireWorkDirAccess(c *gin.Context, workDir string) bool {
+	path := workDir
+	if !isRemotePath(path) {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+	}
+	if !userCanAccessPath(currentUser(c), path) {
+		denyPath(c, workDir)
+		return false
+	}
+	return true
+}
+
+func setAuthCookie(c *gin.Context, token string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     authCookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// === HTTP Handlers ===
+
+// AuthStatus handles GET /api/auth/status
+func AuthStatus(c *gin.Context) {
+	token := requestToken(c)
+	user := authManager.lookupSession(token)
+	if user == nil && strings.HasPrefix(token, apiKeyPrefix) {
+		_, user = lookupAPIKey(token)
+	}
+	resp := gin.H{
+		"mode":          authManager.config.Mode,
+		"authenticated": user != nil || !authEnabled(),
+	}
+	if user != nil {
+		resp["user"] = user.info()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// Login handles POST /api/auth/login
+func Login(c *gin.Context) {
+	if authManager.config.Mode != "local" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Password login is not enabled")
+		return
+	}
+
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+
+	user := authManager.findUser(func(u *User) bool {
+		return subtle.ConstantTimeCompare([]byte(u.Username), []byte(req.Username)) == 1
+	})
+	if user == nil || user.PasswordHash == "" ||
+		bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)) != nil {
+		log.Printf("[Auth] Failed login for %q from %s", req.Username, c.ClientIP())
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token := authManager.createSession(user.ID)
+	setAuthCookie(c, token, int(authSessionTTL.Seconds()))
+	log.Printf("[Auth] %s logged in from %s", user.Username, c.ClientIP())
+
+	c.JSON(http.StatusOK, gin.H{
+		"token": token,
+		"user":  user.info(),
+	})
+}
+
+// Logout handles POST /api/auth/logout
+func Logout(c *gin.Context) {
+	token := requestToken(c)
+	authManager.sessionsMu.Lock()
+	delete(authManager.sessions, token)
+	authManager.sessionsMu.Unlock()
+	setAuthCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetCurrentUser handles GET /api/auth/me
+func GetCurrentUser(c *gin.Context) {
+	user := currentUser(c)
+	if user == nil {
+		c.JSON(http.StatusOK, gin.H{"user": nil, "mode": authManager.config.Mode})
+		return
+	}
+	resp := gin.H{"user": user.info(), "mode": authManager.config.Mode}
+	if scope := requestAPIKeyScope(c); scope != "" {
+		resp["apiKeyScope"] = scope
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// discoverOIDC fetches the provider's OpenID configuration
+func discoverOIDC(issuer string) (*oidcProvider, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery returned HTTP %d", resp.StatusCode)
+	}
+	var provider oidcProvider
+	if err := json.NewDecoder(resp.Body).Decode(&provider); err != nil {
+		return nil, err
+	}
+	if provider.AuthorizationEndpoint == "" || provider.TokenEndpoint == "" || provider.UserinfoEndpoint == "" {
+		return nil, fmt.Errorf("provider configuration is missing required endpoints")
+	}
+	return &provider, nil
+}
+
+// OIDCLogin handles GET /api/auth/oidc/login by redirecting to the provider
+func OIDCLogin(c *gin.Context) {
+	am := authManager
+	if am.config.Mode != "oidc" || am.oidc == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "OIDC login is not enabled")
+		return
+	}
+
+	state := randomToken(16)
+	am.sessionsMu.Lock()
+	for s, expires := range am.oidcStates {
+		if time.Now().After(expires) {
+			delete(am.oidcStates, s)
+		}
+	}
+	am.oidcStates[state] = time.Now().Add(oidcStateTTL)
+	am.sessionsMu.Unlock()
+
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {am.config.OIDCClientID},
+		"redirect_uri":  {am.config.OIDCRedirectURL},
+		"scope":         {"openid email profile"},
+		"state":         {state},
+	}
+	c.Redirect(http.StatusFound, am.oidc.AuthorizationEndpoint+"?"+params.Encode())
+}
+
+// OIDCCallback handles GET /api/auth/oidc/callback
+// Exchanges the code, resolves the user via the userinfo endpoint, and
+// provisions an account on first login
+func OIDCCallback(c *gin.Context) {
+	am := authManager
+	if am.config.Mode != "oidc" || am.oidc == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "OIDC login is not enabled")
+		return
+	}
+
+	state := c.Query("state")
+	am.sessionsMu.Lock()
+	expires, ok := am.oidcStates[state]
+	delete(am.oidcStates, state)
+	am.sessionsMu.Unlock()
+	if !ok || time.Now().After(expires) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid or expired login state")
+		return
+	}
+
+	code := c.Query("code")
+	if code == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Missing authorization code")
+		return
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	tokenResp, err := client.PostForm(am.oidc.TokenEndpoint, url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {am.config.OIDCRedirectURL},
+		"client_id":     {am.config.OIDCClientID},
+		"client_secret": {am.config.OIDCClientSecret},
+	})
+	if err != nil {
+		respondErrorDetails(c, http.StatusBadGateway, ErrUpstream, "Token exchange failed", err.Error())
+		return
+	}
+	defer tokenResp.Body.Close()
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(tokenResp.Body).Decode(&tokens); err != nil || tokens.AccessToken == "" {
+		respondError(c, http.StatusBadGateway, ErrUpstream, "Token exchange returned no access token")
+		return
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, am.oidc.UserinfoEndpoint, nil)
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	infoResp, err := client.Do(req)
+	if err != nil {
+		respondErrorDetails(c, http.StatusBadGateway, ErrUpstream, "Userinfo request failed", err.Error())
+		return
+	}
+	defer infoResp.Body.Close()
+	var claims struct {
+		Subject           string `json:"sub"`
+		Email             string `json:"email"`
+		PreferredUsername string `json:"preferred_username"`
+	}
+	if err := json.NewDecoder(infoResp.Body).Decode(&claims); err != nil || claims.Subject == "" {
+		respondError(c, http.StatusBadGateway, ErrUpstream, "Userinfo response is missing the subject")
+		return
+	}
+
+	user := am.findUser(func(u *User) bool { return u.OIDCSubject == claims.Subject })
+	if user == nil {
+		username := claims.PreferredUsername
+		if username == "" {
+			username = claims.Email
+		}
+		if username == "" {
+			username = claims.Subject
+		}
+		role := "user"
+		for _, email := range am.config.OIDCAdminEmails {
+			if claims.Email != "" && strings.EqualFold(email, claims.Email) {
+				role = "admin"
+			}
+		}
+		newUser := User{
+			ID:          generateID(),
+			Username:    username,
+			Email:       claims.Email,
+			OIDCSubject: claims.Subject,
+			Role:        role,
+			CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+		}
+		am.usersMu.Lock()
+		if am.usernameTakenLocked(username, "") {
+			am.usersMu.Unlock()
+			log.Printf("[Auth] Not provisioning OIDC user %s: the username is taken", username)
+			respondError(c, http.StatusConflict, ErrConflict, fmt.Sprintf("User %s already exists", username))
+			return
+		}
+		am.users = append(am.users, newUser)
+		err := am.saveUsers()
+		am.usersMu.Unlock()
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to save user")
+			return
+		}
+		log.Printf("[Auth] Provisioned OIDC user %s (%s)", username, role)
+		user = &newUser
+	}
+
+	token := am.createSession(user.ID)
+	setAuthCookie(c, token, int(authSessionTTL.Seconds()))
+	redirect := am.config.LoginRedirect
+	if redirect == "" {
+		redirect = "/"
+	}
+	c.Redirect(http.StatusFound, redirect)
+}
+
+// ListUsers handles GET /api/users (admin only)
+func ListUsers(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	authManager.usersMu.RLock()
+	users := make([]UserInfo, 0, len(authManager.users))
+	for i := range authManager.users {
+		users = append(users, authManager.users[i].info())
+	}
+	authManager.usersMu.RUnlock()
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+// CreateUser handles POST /api/users (admin only)
+func CreateUser(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Username == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "username is required")
+		return
+	}
+	if req.Role == "" {
+		req.Role = "user"
+	}
+	if req.Role != "user" && req.Role != "admin" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "role must be user or admin")
+		return
+	}
+
+	user := User{
+		ID:        generateID(),
+		Username:  req.Username,
+		Email:     req.Email,
+		Role:      req.Role,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if req.ProjectRoots != nil {
+		user.ProjectRoots = *req.ProjectRoots
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid password")
+			return
+		}
+		user.PasswordHash = string(hash)
+	}
+
+	am := authManager
+	am.usersMu.Lock()
+	if am.usernameTakenLocked(req.Username, "") {
+		am.usersMu.Unlock()
+		respondError(c, http.StatusConflict, ErrConflict, fmt.Sprintf("User %s already exists", req.Username))
+		return
+	}
+	am.users = append(am.users, user)
+	err := am.saveUsers()
+	am.usersMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save users", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, user.info())
+}
+
+// UpdateUser handles PUT /api/users/:id
+// Admins can change anything; users can only change their own password, given the current one
+func UpdateUser(c *gin.Context) {
+	id := c.Param("id")
+	caller := currentUser(c)
+	isSelf := caller != nil && caller.ID == id
+	if !isSelf && !requireAdmin(c) {
+		return
+	}
+
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	callerIsAdmin := caller == nil || caller.IsAdmin()
+	if !callerIsAdmin && (req.Role != "" || req.ProjectRoots != nil || req.Username != "") {
+		respondError(c, http.StatusForbidden, ErrForbidden, "Only admins can change roles, usernames, or project roots")
+		return
+	}
+	if req.Role != "" && req.Role != "user" && req.Role != "admin" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "role must be user or admin")
+		return
+	}
+
+	am := authManager
+	am.usersMu.Lock()
+	if req.Username != "" && am.usernameTakenLocked(req.Username, id) {
+		am.usersMu.Unlock()
+		respondError(c, http.StatusConflict, ErrConflict, fmt.Sprintf("User %s already exists", req.Username))
+		return
+	}
+	var updated *User
+	for i := range am.users {
+		if am.users[i].ID != id {
+			continue
+		}
+		u := &am.users[i]
+		if isSelf && req.Password != "" &&
+			bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(req.CurrentPassword)) != nil {
+			am.usersMu.Unlock()
+			respondError(c, http.StatusForbidden, ErrForbidden, "Current password is incorrect")
+			return
+		}
+		if req.Username != "" {
+			u.Username = req.Username
+		}
+		if req.Email != "" {
+			u.Email = req.Email
+		}
+		if req.Role != "" {
+			u.Role = req.Role
+		}
+		if req.ProjectRoots != nil {
+			u.ProjectRoots = *req.ProjectRoots
+		}
+		if req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				am.usersMu.Unlock()
+				respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid password")
+				return
+			}
+			u.PasswordHash = string(hash)
+		}
+		copied := *u
+		updated = &copied
+		break
+	}
+	if updated == nil {
+		am.usersMu.Unlock()
+		respondError(c, http.StatusNotFound, ErrNotFound, "User not found")
+		return
+	}
+	err := am.saveUsers()
+	am.usersMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save users", err.Error())
+		return
+	}
+
+	if req.Password != "" {
+		am.revokeUserSessions(id)
+	}
+	c.JSON(http.StatusOK, updated.info())
+}
+
+// DeleteUser handles DELETE /api/users/:id (admin only)
+func DeleteUser(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	id := c.Param("id")
+	if caller := currentUser(c); caller != nil && caller.ID == id {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "You cannot delete your own account")
+		return
+	}
+
+	am := authManager
+	am.usersMu.Lock()
+	found := false
+	users := make([]User, 0, len(am.users))
+	for _, u := range am.users {
+		if u.ID == id {
+			found = true
+			continue
+		}
+		users = append(users, u)
+	}
+	if !found {
+		am.usersMu.Unlock()
+		respondError(c, http.StatusNotFound, ErrNotFound, "User not found")
+		return
+	}
+	am.users = users
+	err := am.saveUsers()
+	am.usersMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save users", err.Error())
+		return
+	}
+
+	am.revokeUserSessions(id)
+	forgetAPIKeys(id)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
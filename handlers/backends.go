@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExecBackend runs claude inside a container instead of the server's own environment
+// Chats pick one by name; without one claude runs locally (or over ssh for host:path directories)
+type ExecBackend struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"` // docker or devcontainer
+
+	// docker: exec into this running container, or start a throwaway one from Image with the project mounted
+	Container string `yaml:"container" json:"container,omitempty"`
+	Image     string `yaml:"image" json:"image,omitempty"`
+	// Workspace is where the project appears in the container (default: the same path as on the host)
+	// With Container it maps MountRoot, the host directory bind-mounted there
+	Workspace string `yaml:"workspace" json:"workspace,omitempty"`
+	MountRoot string `yaml:"mountRoot" json:"mountRoot,omitempty"`
+
+	User       string   `yaml:"user" json:"user,omitempty"`
+	Env        []string `yaml:"env" json:"env,omitempty"`               // server environment variables passed through, e.g. ANTHROPIC_API_KEY
+	ClaudePath string   `yaml:"claudePath" json:"claudePath,omitempty"` // default "claude"
+}
+
+// ExecBackendInfo is a configured backend as shown to clients
+type ExecBackendInfo struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Image string `json:"image,omitempty"`
+}
+
+// validateExecBackends checks the backends section and normalizes paths
+func validateExecBackends(backends []ExecBackend) error {
+	seen := make(map[string]bool)
+	for i := range backends {
+		b := &backends[i]
+		if b.Name == "" || b.Name == "local" {
+			return fmt.Errorf("backends[%d]: name is required and must not be \"local\"", i)
+		}
+		if seen[b.Name] {
+			return fmt.Errorf("backends[%d]: duplicate name %q", i, b.Name)
+		}
+		seen[b.Name] = true
+		switch b.Type {
+		case "docker":
+			if (b.Container == "") == (b.Image == "") {
+				return fmt.Errorf("backends[%d]: docker backends need exactly one of container or image", i)
+			}
+			if b.Container != "" && b.Workspace != "" && b.MountRoot == "" {
+				return fmt.Errorf("backends[%d]: mountRoot is required when workspace is set for a container", i)
+			}
+		case "devcontainer":
+			if b.Container != "" || b.Image != "" || b.Workspace != "" || b.MountRoot != "" {
+				return fmt.Errorf("backends[%d]: devcontainer backends are located by the project's devcontainer.json", i)
+			}
+		default:
+			return fmt.Errorf("backends[%d]: type must be docker or devcontainer", i)
+		}
+		if b.Workspace != "" {
+			if !strings.HasPrefix(b.Workspace, "/") {
+				return fmt.Errorf("backends[%d]: workspace must be an absolute path", i)
+			}
+			b.Workspace = filepath.Clean(b.Workspace)
+		}
+		if b.MountRoot != "" {
+			if !filepath.IsAbs(b.MountRoot) {
+				return fmt.Errorf("backends[%d]: mountRoot must be an absolute path", i)
+			}
+			b.MountRoot = filepath.Clean(b.MountRoot)
+		}
+	}
+	return nil
+}
+
+// findExecBackend looks up a configured backend; "" and "local" mean none
+func findExecBackend(name string) (*ExecBackend, error) {
+	if name == "" || name == "local" {
+		return nil, nil
+	}
+	for _, b := range getServerConfig().Backends {
+		if b.Name == name {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown execution backend: %s", name)
+}
+
+// checkBackend validates a chat's backend selection against its working directory
+func checkBackend(name string, workDir string) error {
+	b, err := findExecBackend(name)
+	if err != nil || b == nil {
+		return err
+	}
+	if isRemotePath(workDir) {
+		return fmt.Errorf("execution backends are not available for remote hosts")
+	}
+	_, err = b.containerDir(workDir)
+	return err
+}
+
+// runsLocally reports whether a run in workDir with backend executes on this host,
+// so host-side files, environment, and processes are available to it
+func runsLocally(workDir string, backend string) bool {
+	return !isRemotePath(workDir) && (backend == "" || backend == "local")
+}
+
+// localOnlyError is the error for a feature (e.g. "snapshots") used in a run that doesn't execute on this host
+func localOnlyError(feature string, workDir string, backend string) error {
+	if isRemotePath(workDir) {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s are not available on remote hosts", feature))
+	}
+	return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s are not available in container backends", feature))
+}
+
+// localOnlyReason explains why host-side files (images, MCP configs) can't be used in workDir
+func localOnlyReason(workDir string, backend string) string {
+	if isRemotePath(workDir) {
+		return "Image attachments and MCP server selection are not available on remote hosts"
+	}
+	if !runsLocally(workDir, backend) {
+		return "Image attachments and MCP server selection are not available in container backends"
+	}
+	return ""
+}
+
+// containerDir maps a host working directory to its path inside the container
+func (b *ExecBackend) containerDir(workDir string) (string, error) {
+	switch {
+	case b.Image != "" && b.Workspace != "":
+		return b.Workspace, nil
+	case b.Image != "" || b.Workspace == "":
+		return workDir, nil
+	}
+	if !pathWithin(workDir, b.MountRoot) {
+		return "", fmt.Errorf("%s is not mounted in backend %s (mountRoot %s)", workDir, b.Name, b.MountRoot)
+	}
+	rel, err := filepath.Rel(b.MountRoot, workDir)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(filepath.Join(b.Workspace, rel)), nil
+}
+
+// envArgs passes the configured environment variables and the run marker to the container
+// docker reads bare names from its own environment, which keeps secrets out of the process list
+func (b *ExecBackend) envArgs(flag string, runID string) []string {
+	args := []string{flag, "GREYZONE_RUN=" + runID}
+	for _, name := range b.Env {
+		if v, ok := os.LookupEnv(name); ok {
+			if b.Type == "docker" {
+				args = append(args, flag, name)
+			} else {
+				args = append(args, flag, name+"="+v)
+			}
+		}
+	}
+	return args
+}
+
+// killRunScript kills processes in the container carrying the run marker
+func killRunScript(runID string) string {
+	return `for p in /proc/[0-9]*; do grep -qz 'GREYZONE_RUN=` + runID + `' "$p/environ" 2>/dev/null && kill "${p#/proc/}"; done; true`
+}
+
+// command builds the claude invocation inside the container
+// The stop function removes what a killed docker client leaves running in the container
+func (b *ExecBackend) command(workDir string, args []string) (*exec.Cmd, func(), error) {
+	dir, err := b.containerDir(workDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	claudePath := b.ClaudePath
+	if claudePath == "" {
+		claudePath = "claude"
+	}
+	runID := generateID()
+
+	var cmdArgs []string
+	var stop func()
+	switch {
+	case b.Type == "devcontainer":
+		cmdArgs = []string{"devcontainer", "exec", "--workspace-folder", workDir}
+		cmdArgs = append(cmdArgs, b.envArgs("--remote-env", runID)...)
+		stop = func() {
+			exec.Command("devcontainer", "exec", "--workspace-folder", workDir, "sh", "-c", killRunScript(runID)).Run()
+		}
+	case b.Image != "":
+		name := "greyzone-" + runID
+		cmdArgs = []string{"docker", "run", "--rm", "-i", "--init", "--name", name, "-v", workDir + ":" + dir, "-w", dir}
+		if b.User != "" {
+			cmdArgs = append(cmdArgs, "-u", b.User)
+		}
+		cmdArgs = append(cmdArgs, b.envArgs("-e", runID)...)
+		cmdArgs = append(cmdArgs, b.Image)
+		stop = func() {
+			exec.Command("docker", "rm", "-f", name).Run()
+		}
+	default:
+		cmdArgs = []string{"docker", "exec", "-i", "-w", dir}
+		if b.User != "" {
+			cmdArgs = append(cmdArgs, "-u", b.User)
+		}
+		cmdArgs = append(cmdArgs, b.envArgs("-e", runID)...)
+		cmdArgs = append(cmdArgs, b.Container)
+		stop = func() {
+			exec.Command("docker", "exec", b.Container, "sh", "-c", killRunScript(runID)).Run()
+		}
+	}
+	cmdArgs = append(cmdArgs, claudePath)
+	cmdArgs = append(cmdArgs, args...)
+
+	cmd := exec.Command(cmdArgs[0], cmdArgs[1:]...)
+	cmd.Dir = workDir
+	// Only a killed run can leave a process behind; clean up in the background
+	return cmd, func() {
+		if cmd.ProcessState == nil || cmd.ProcessState.ExitCode() == -1 {
+			go stop()
+		}
+	}, nil
+}
+
+// claudeCommand builds the claude invocation for workDir on the selected backend
+// host:path directories run claude over ssh; stdout streams back unchanged either way
+// Call stop once the command (or whatever wraps it) has exited
+func claudeCommand(workDir string, backend string, args []string) (cmd *exec.Cmd, stop func(), err error) {
+	b, err := findExecBackend(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	if b != nil {
+		return b.command(workDir, args)
+	}
+	if h, dir, ok := parseRemotePath(workDir); ok {
+		return remoteClaudeCommand(h, dir, args), func() {}, nil
+	}
+	if _, err := exec.LookPath("claude"); err != nil {
+		return nil, nil, withCode(http.StatusInternalServerError, ErrCLINotFound, fmt.Errorf("claude CLI not found on PATH"))
+	}
+	cmd = exec.Command("claude", args...)
+	cmd.Dir = workDir
+	return cmd, func() {}, nil
+}
+
+// ListBackends handles GET /api/backends
+// Returns the execution backends a chat can select (local is always available)
+func ListBackends(c *gin.Context) {
+	backends := []ExecBackendInfo{{Name: "local", Type: "local"}}
+	for _, b := range getServerConfig().Backends {
+		backends = append(backends, ExecBackendInfo{Name: b.Name, Type: b.Type, Image: b.Image})
+	}
+	c.JSON(http.StatusOK, gin.H{"backends": backends})
+}
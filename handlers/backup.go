@@ -0,0 +1,650 @@
+package handlers
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// backupManifestName is the first entry of every archive
+	backupManifestName = "manifest.json"
+	// backupFormatVersion is bumped when the archive layout changes
+	backupFormatVersion = 1
+	// backupUploadTimeout bounds one upload to the configured target
+	backupUploadTimeout = 30 * time.Minute
+)
+
+// backupParts are the archive paths each part covers
+// claude/ is ~/.claude, claude.json is ~/.claude.json, and server/ is the server data directory
+var backupParts = map[string][]string{
+	"sessions":    {"claude/projects", "claude/todos"},
+	"settings":    {"claude/settings.json", "claude/settings.local.json", "claude/CLAUDE.md", "claude.json"},
+	"commands":    {"claude/commands", "claude/agents"},
+	"plugins":     {"claude/plugins"},
+	"credentials": {"claude/.credentials.json", "server/claude-token.json"},
+	"server":      {"server"},
+}
+
+// defaultBackupParts leave out plugins, which can be reinstalled, and credentials
+var defaultBackupParts = []string{"sessions", "settings", "commands", "server"}
+
+// backupSkipped are paths inside archived directories that are left out: rewind backups
+// only matter on the machine that made them, and the token belongs to the credentials part
+var backupSkipped = map[string]bool{
+	"server/rewind-backups":    true,
+	"server/claude-token.json": true,
+}
+
+var backupHostUnsafe = regexp.MustCompile(`[^A-Za-z0-9.-]+`)
+
+// BackupConfig uploads archives to a WebDAV or S3 target on a schedule
+type BackupConfig struct {
+	Schedule string       `yaml:"schedule" json:"schedule,omitempty"` // cron expression in server time; empty = no scheduled backups
+	Parts    []string     `yaml:"parts" json:"parts,omitempty"`       // default sessions, settings, commands, server
+	Target   BackupTarget `yaml:"target" json:"target"`
+}
+
+// BackupTarget is where uploaded backups go
+type BackupTarget struct {
+	Type string `yaml:"type" json:"type,omitempty"` // webdav or s3
+	// URL is the WebDAV collection, or the S3 endpoint (e.g. https://s3.eu-west-1.amazonaws.com)
+	URL      string `yaml:"url" json:"url,omitempty"`
+	Username string `yaml:"username" json:"username,omitempty"` // WebDAV basic auth
+	Password string `yaml:"password" json:"-"`
+	Bucket   string `yaml:"bucket" json:"bucket,omitempty"` // S3, addressed path-style
+	Region   string `yaml:"region" json:"region,omitempty"`
+	Prefix   string `yaml:"prefix" json:"prefix,omitempty"` // prepended to the archive name, e.g. greyzone/
+	// AccessKeyID and SecretAccessKey default to AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY
+	AccessKeyID     string `yaml:"accessKeyId" json:"accessKeyId,omitempty"`
+	SecretAccessKey string `yaml:"secretAccessKey" json:"-"`
+}
+
+// BackupManifest describes an archive
+type BackupManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"createdAt"`
+	Host      string    `json:"host"`
+	Parts     []string  `json:"parts"`
+}
+
+// BackupRequest is the body of POST /api/backup
+type BackupRequest struct {
+	Parts  []string `json:"parts,omitempty"`  // sessions, settings, commands, plugins, credentials, server
+	Upload bool     `json:"upload,omitempty"` // send to backup.target instead of downloading
+}
+
+// BackupResult is the outcome of an uploaded backup
+type BackupResult struct {
+	Name     string    `json:"name"`
+	Location string    `json:"location,omitempty"` // where the target stored it
+	Files    int       `json:"files"`
+	Size     int64     `json:"size"` // compressed bytes
+	Started  time.Time `json:"started"`
+	Duration int64     `json:"durationMs"`
+	Trigger  string    `json:"trigger"` // api or schedule
+	Error    string    `json:"error,omitempty"`
+}
+
+// BackupStatus is the response of GET /api/backup
+type BackupStatus struct {
+	Parts      []string      `json:"parts"`    // every part an archive can hold
+	Defaults   []string      `json:"defaults"` // the parts used when none are given
+	Schedule   string        `json:"schedule,omitempty"`
+	NextRun    *time.Time    `json:"nextRun,omitempty"`
+	TargetType string        `json:"targetType,omitempty"`
+	LastUpload *BackupResult `json:"lastUpload,omitempty"`
+}
+
+// RestoreResponse is the response of POST /api/restore
+type RestoreResponse struct {
+	Manifest    BackupManifest `json:"manifest"`
+	Restored    int            `json:"restored"`    // files that didn't exist
+	Overwritten int            `json:"overwritten"` // existing files replaced, with overwrite
+	Skipped     int            `json:"skipped"`     // existing files kept
+	Bytes       int64          `json:"bytes"`
+	DryRun      bool           `json:"dryRun"`
+	// RestartRequired is set when server metadata (users, keys, schedules, ...) changed; it is read at startup
+	RestartRequired bool `json:"restartRequired"`
+}
+
+var (
+	backupMu         sync.Mutex // one backup or restore at a time
+	lastBackupUpload *BackupResult
+	backupSchedOnce  sync.Once
+)
+
+// backupLocalPath maps an archive path to where it lives on this machine
+func backupLocalPath(name string) (string, bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case name == "claude.json":
+		return filepath.Join(home, ".claude.json"), true
+	case strings.HasPrefix(name, "claude/"):
+		return filepath.Join(getClaudeDir(), filepath.FromSlash(strings.TrimPrefix(name, "claude/"))), true
+	case name == "server":
+		return getServerDataDir(), true
+	case strings.HasPrefix(name, "server/"):
+		return filepath.Join(getServerDataDir(), filepath.FromSlash(strings.TrimPrefix(name, "server/"))), true
+	}
+	return "", false
+}
+
+// validateBackupParts returns the parts to archive, or the defaults for none
+func validateBackupParts(parts []string) ([]string, error) {
+	if len(parts) == 0 {
+		return defaultBackupParts, nil
+	}
+	for _, part := range parts {
+		if backupParts[part] == nil {
+			return nil, fmt.Errorf("unknown backup part %q (expected sessions, settings, commands, plugins, credentials, or server)", part)
+		}
+	}
+	return parts, nil
+}
+
+// backupName is the file name of an archive made now
+func backupName(now time.Time) string {
+	host, _ := os.Hostname()
+	host = strings.Trim(backupHostUnsafe.ReplaceAllString(host, "-"), "-")
+	if host == "" {
+		host = "server"
+	}
+	return fmt.Sprintf("greyzone-backup-%s-%s.tar.gz", host, now.UTC().Format("20060102T150405Z"))
+}
+
+// writeBackup writes a tar.gz of the parts to w and returns how many files it holds
+// Symlinks and special files are left out
+func writeBackup(w io.Writer, parts []string) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	host, _ := os.Hostname()
+	manifest, _ := json.MarshalIndent(BackupManifest{Version: backupFormatVersion, CreatedAt: time.Now().UTC(), Host: host, Parts: parts}, "", "  ")
+	if err := tw.WriteHeader(&tar.Header{Name: backupManifestName, Mode: 0644, Size: int64(len(manifest)), ModTime: time.Now()}); err != nil {
+		return 0, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return 0, err
+	}
+
+	files := 0
+	seen := make(map[string]bool)
+	for _, part := range parts {
+		for _, name := range backupParts[part] {
+			root, ok := backupLocalPath(name)
+			if !ok {
+				continue
+			}
+			err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					if os.IsNotExist(err) {
+						return nil
+					}
+					return err
+				}
+				rel, _ := filepath.Rel(root, p)
+				entry := path.Join(name, filepath.ToSlash(rel))
+				if p != root && backupSkipped[entry] {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if !d.Type().IsRegular() || seen[entry] {
+					return nil
+				}
+				seen[entry] = true
+				info, err := d.Info()
+				if err != nil {
+					return nil // removed since the directory was read
+				}
+				return addBackupFile(tw, p, entry, info)
+			})
+			if err != nil {
+				return files, err
+			}
+		}
+		files = len(seen)
+	}
+	if err := tw.Close(); err != nil {
+		return files, err
+	}
+	return files, gz.Close()
+}
+
+// addBackupFile copies one file into the archive
+// A transcript can grow while it is read; only the size it had when listed is archived
+func addBackupFile(tw *tar.Writer, localPath string, name string, info fs.FileInfo) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Uid, header.Gid, header.Uname, header.Gname = 0, 0, "", ""
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(tw, file, header.Size); err != nil {
+		return fmt.Errorf("%s: %w", localPath, err)
+	}
+	return nil
+}
+
+// restoreBackup extracts an archive made by writeBackup
+// Entries outside the known roots, and anything but regular files, are ignored
+func restoreBackup(r io.Reader, overwrite bool, dryRun bool) (*RestoreResponse, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("not a gzip archive: %w", err))
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	notBackup := withCode(http.StatusBadRequest, ErrInvalidRequest, errors.New("not a backup archive: manifest.json must come first"))
+
+	header, err := tr.Next()
+	if err != nil || header.Name != backupManifestName {
+		return nil, notBackup
+	}
+	resp := &RestoreResponse{DryRun: dryRun}
+	if err := json.NewDecoder(io.LimitReader(tr, 1<<20)).Decode(&resp.Manifest); err != nil || resp.Manifest.Version == 0 {
+		return nil, notBackup
+	}
+	if resp.Manifest.Version > backupFormatVersion {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("backup format %d is newer than this server understands", resp.Manifest.Version))
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return resp, nil
+		}
+		if err != nil {
+			return resp, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("corrupt archive: %w", err))
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(header.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		target, ok := backupLocalPath(name)
+		if !ok {
+			continue
+		}
+
+		_, statErr := os.Stat(target)
+		exists := statErr == nil
+		if exists && !overwrite {
+			resp.Skipped++
+			continue
+		}
+		if !dryRun {
+			if err := extractBackupFile(tr, target, header); err != nil {
+				return resp, err
+			}
+		}
+		if exists {
+			resp.Overwritten++
+		} else {
+			resp.Restored++
+		}
+		resp.Bytes += header.Size
+		if strings.HasPrefix(name, "server/") {
+			resp.RestartRequired = true
+		}
+	}
+}
+
+// extractBackupFile writes one entry through a temp file, keeping its mode and modification time
+func extractBackupFile(r io.Reader, target string, header *tar.Header) error {
+	dirMode := os.FileMode(0755)
+	if strings.HasPrefix(target, getServerDataDir()+string(filepath.Separator)) {
+		dirMode = 0700
+	}
+	if err := os.MkdirAll(filepath.Dir(target), dirMode); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".restore-*")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, r)
+	if err == nil {
+		err = tmp.Chmod(os.FileMode(header.Mode).Perm() | 0600)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), target)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("%s: %w", header.Name, err)
+	}
+	os.Chtimes(target, header.ModTime, header.ModTime)
+	return nil
+}
+
+// uploadBackup sends an archive to the configured target and returns where it went
+func uploadBackup(target BackupTarget, file *os.File, size int64, name string) (string, error) {
+	var location string
+	switch target.Type {
+	case "webdav":
+		location = strings.TrimSuffix(target.URL, "/") + "/" + target.Prefix + name
+	case "s3":
+		location = strings.TrimSuffix(target.URL, "/") + "/" + target.Bucket + "/" + target.Prefix + name
+	default:
+		return "", fmt.Errorf("backup.target.type must be webdav or s3")
+	}
+	req, err := http.NewRequest(http.MethodPut, location, io.NopCloser(file))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	if target.Type == "webdav" {
+		if target.Username != "" {
+			req.SetBasicAuth(target.Username, target.Password)
+		}
+	} else {
+		keyID, secret := target.AccessKeyID, target.SecretAccessKey
+		if keyID == "" {
+			keyID, secret = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+		}
+		signS3Request(req, keyID, secret, target.Region, time.Now())
+	}
+
+	client := &http.Client{Timeout: backupUploadTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("%s returned %s: %s", target.Type, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return location, nil
+}
+
+// signS3Request adds an AWS Signature Version 4 to a request; the payload is sent unsigned
+func signS3Request(req *http.Request, keyID string, secret string, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonical := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:UNSIGNED-PAYLOAD\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	mac := func(key []byte, data string) []byte {
+		h := hmac.New(sha256.New, key)
+		h.Write([]byte(data))
+		return h.Sum(nil)
+	}
+	key := mac([]byte("AWS4"+secret), date)
+	for _, part := range []string{region, "s3", "aws4_request"} {
+		key = mac(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		keyID, scope, signedHeaders, hex.EncodeToString(mac(key, toSign))))
+}
+
+// runBackupUpload archives the parts to a temp file and uploads it to the configured target
+func runBackupUpload(parts []string, trigger string) BackupResult {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	started := time.Now()
+	result := BackupResult{Name: backupName(started), Started: started, Trigger: trigger}
+	defer func() {
+		result.Duration = time.Since(started).Milliseconds()
+		copied := result
+		lastBackupUpload = &copied
+		if result.Error != "" {
+			log.Printf("[Backup] Upload of %s failed: %s", result.Name, result.Error)
+		} else {
+			log.Printf("[Backup] Uploaded %s (%d files, %d bytes) to %s", result.Name, result.Files, result.Size, result.Location)
+		}
+	}()
+
+	tmp, err := os.CreateTemp("", "greyzone-backup-*.tar.gz")
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if result.Files, err = writeBackup(tmp, parts); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if result.Size, err = tmp.Seek(0, io.SeekCurrent); err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	if result.Location, err = uploadBackup(getServerConfig().Backup.Target, tmp, result.Size, result.Name); err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// validateBackupConfig checks the backup section of config.yaml
+func validateBackupConfig(cfg BackupConfig) error {
+	if _, err := validateBackupParts(cfg.Parts); err != nil {
+		return fmt.Errorf("backup.parts: %w", err)
+	}
+	if cfg.Schedule == "" && cfg.Target.Type == "" {
+		return nil
+	}
+	if cfg.Schedule != "" {
+		if _, err := parseCron(cfg.Schedule); err != nil {
+			return fmt.Errorf("backup.schedule: %w", err)
+		}
+	}
+	switch cfg.Target.Type {
+	case "webdav":
+	case "s3":
+		if cfg.Target.Bucket == "" || cfg.Target.Region == "" {
+			return fmt.Errorf("backup.target needs bucket and region for s3")
+		}
+	default:
+		return fmt.Errorf("backup.target.type must be webdav or s3")
+	}
+	if !strings.HasPrefix(cfg.Target.URL, "https://") && !strings.HasPrefix(cfg.Target.URL, "http://") {
+		return fmt.Errorf("backup.target.url must be an http(s) URL")
+	}
+	return nil
+}
+
+// StartBackupScheduler uploads a backup whenever backup.schedule matches, checked at the top of every minute
+func StartBackupScheduler() {
+	backupSchedOnce.Do(func() {
+		go func() {
+			for {
+				now := time.Now()
+				tick := now.Truncate(time.Minute).Add(time.Minute)
+				time.Sleep(tick.Sub(now))
+				cfg := getServerConfig().Backup
+				if cfg.Schedule == "" || cfg.Target.Type == "" {
+					continue
+				}
+				cron, err := parseCron(cfg.Schedule)
+				if err != nil || !cron.matches(tick) {
+					continue
+				}
+				parts, _ := validateBackupParts(cfg.Parts)
+				go runBackupUpload(parts, "schedule")
+			}
+		}()
+	})
+}
+
+// GetBackupStatus handles GET /api/backup
+// Returns the available parts, the upload schedule, and the outcome of the last upload
+func GetBackupStatus(c *gin.Context) {
+	cfg := getServerConfig().Backup
+	status := BackupStatus{
+		Parts:      []string{"sessions", "settings", "commands", "plugins", "credentials", "server"},
+		Defaults:   defaultBackupParts,
+		Schedule:   cfg.Schedule,
+		TargetType: cfg.Target.Type,
+	}
+	if cron, err := parseCron(cfg.Schedule); err == nil && cfg.Schedule != "" && cfg.Target.Type != "" {
+		next := cron.next(time.Now())
+		status.NextRun = &next
+	}
+	backupMu.Lock()
+	status.LastUpload = lastBackupUpload
+	backupMu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+// CreateBackup handles POST /api/backup
+// Streams a tar.gz of the selected parts of ~/.claude and the server data directory,
+// or with upload sends it to backup.target and returns the result
+func CreateBackup(c *gin.Context) {
+	var req BackupRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	parts, err := validateBackupParts(req.Parts)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	// Archiving a large history takes longer than the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	if req.Upload {
+		if getServerConfig().Backup.Target.Type == "" {
+			respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "No backup target is configured")
+			return
+		}
+		result := runBackupUpload(parts, "api")
+		if result.Error != "" {
+			respondErrorDetails(c, http.StatusBadGateway, ErrUpstream, "Backup upload failed", result.Error)
+			return
+		}
+		c.JSON(http.StatusOK, result)
+		return
+	}
+
+	backupMu.Lock()
+	defer backupMu.Unlock()
+	name := backupName(time.Now())
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Status(http.StatusOK)
+	files, err := writeBackup(c.Writer, parts)
+	if err != nil {
+		// The archive is cut short; a client sees a truncated gzip stream
+		log.Printf("[Backup] Download of %s failed after %d files: %v", name, files, err)
+		return
+	}
+	log.Printf("[Backup] %s downloaded %s (%d files)", auditUser(currentUser(c)), name, files)
+}
+
+// RestoreBackup handles POST /api/restore
+// Takes an archive from POST /api/backup as the request body, or as the multipart field "file"
+// Query parameters:
+//   - overwrite: replace files that already exist (default: keep them)
+//   - dryRun: report what would be restored without writing anything
+func RestoreBackup(c *gin.Context) {
+	overwrite, _ := strconv.ParseBool(c.Query("overwrite"))
+	dryRun, _ := strconv.ParseBool(c.Query("dryRun"))
+	if overwrite && !dryRun {
+		processLock.RLock()
+		running := len(activeProcesses)
+		processLock.RUnlock()
+		if running > 0 {
+			respondError(c, http.StatusConflict, ErrConflict, "Stop running chats before restoring with overwrite")
+			return
+		}
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, ErrInvalidRequest, "No file provided")
+				return
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+			part.Close()
+		}
+	}
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	backupMu.Lock()
+	resp, err := restoreBackup(body, overwrite, dryRun)
+	backupMu.Unlock()
+	if err != nil {
+		if resp != nil && resp.Restored+resp.Overwritten > 0 {
+			respondCheckError(c, fmt.Errorf("restore stopped after %d files: %w", resp.Restored+resp.Overwritten, err))
+			return
+		}
+		respondCheckError(c, err)
+		return
+	}
+	log.Printf("[Backup] %s restored a backup from %s (%d new, %d overwritten, %d kept, dryRun=%v)",
+		auditUser(currentUser(c)), resp.Manifest.Host, resp.Restored, resp.Overwritten, resp.Skipped, dryRun)
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,576 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// batchMaxRuns caps the runs of one batch
+	batchMaxRuns = 50
+	// batchDefaultParallel and batchMaxParallel bound how many runs of a batch go at once
+	batchDefaultParallel = 3
+	batchMaxParallel     = 10
+	// batchKeep is how many finished batches stay queryable
+	batchKeep = 50
+	// batchSlotWait is how often a run waiting for the chat limits checks again
+	batchSlotWait = 2 * time.Second
+	// batchSummaryLimit truncates the result text kept per run
+	batchSummaryLimit = 2000
+	// batchStderrLines is how much stderr a failed run reports
+	batchStderrLines = 10
+)
+
+// BatchRequest is the body of POST /api/chat/batch
+// Either prompt with workDirs (one prompt across repositories) or prompts with workDir
+type BatchRequest struct {
+	Prompt   string   `json:"prompt,omitempty"`
+	WorkDirs []string `json:"workDirs,omitempty"`
+	Prompts  []string `json:"prompts,omitempty"`
+	WorkDir  string   `json:"workDir,omitempty"`
+	Parallel int      `json:"parallel,omitempty"` // runs at once; default 3, max 10
+	// Applied to every run; empty fields fall back to the project settings, then the server defaults
+	Model          string   `json:"model,omitempty"`
+	PermissionMode string   `json:"permissionMode,omitempty"`
+	AllowedTools   []string `json:"allowedTools,omitempty"`
+	Backend        string   `json:"backend,omitempty"`
+	ChatBackend    string   `json:"chatBackend,omitempty"`
+}
+
+// BatchRun is one claude run of a batch
+type BatchRun struct {
+	Index      int        `json:"index"`
+	WorkDir    string     `json:"workDir"`
+	Prompt     string     `json:"prompt"`
+	Status     string     `json:"status"` // queued, running, success, error, interrupted, or canceled
+	ProcessID  int        `json:"processId,omitempty"`
+	SessionID  string     `json:"sessionId,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Summary    string     `json:"summary,omitempty"` // the final result text
+	CostUSD    float64    `json:"costUsd,omitempty"`
+	NumTurns   int        `json:"numTurns,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// BatchStatus is a batch with the progress of its runs
+type BatchStatus struct {
+	ID         string     `json:"id"`
+	Owner      string     `json:"owner,omitempty"`
+	Status     string     `json:"status"` // running, done, or canceled
+	Parallel   int        `json:"parallel"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Total      int        `json:"total"`
+	Queued     int        `json:"queued"`
+	Running    int        `json:"running"`
+	Succeeded  int        `json:"succeeded"`
+	Failed     int        `json:"failed"` // error or interrupted
+	Canceled   int        `json:"canceled"`
+	CostUSD    float64    `json:"costUsd"`
+	Runs       []BatchRun `json:"runs,omitempty"`
+}
+
+// chatBatch is a batch in progress or kept after finishing
+type chatBatch struct {
+	mu       sync.Mutex
+	status   BatchStatus
+	req      BatchRequest
+	user     *User
+	logger   *slog.Logger
+	canceled bool
+	procs    map[int]func() error // run index -> interrupt, while running
+}
+
+var (
+	chatBatchesMu sync.Mutex
+	chatBatches   = make(map[string]*chatBatch)
+)
+
+// snapshot copies the batch with its counts brought up to date; caller must hold b.mu
+func (b *chatBatch) snapshot(withRuns bool) BatchStatus {
+	s := b.status
+	s.Queued, s.Running, s.Succeeded, s.Failed, s.Canceled, s.CostUSD = 0, 0, 0, 0, 0, 0
+	for _, run := range b.status.Runs {
+		switch run.Status {
+		case "queued":
+			s.Queued++
+		case "running":
+			s.Running++
+		case "success":
+			s.Succeeded++
+		case "canceled":
+			s.Canceled++
+		default:
+			s.Failed++
+		}
+		s.CostUSD += run.CostUSD
+	}
+	if withRuns {
+		s.Runs = append([]BatchRun(nil), b.status.Runs...)
+	} else {
+		s.Runs = nil
+	}
+	return s
+}
+
+// updateRun changes one run and sends the batch's progress to its owner's WebSocket connections
+func (b *chatBatch) updateRun(index int, fn func(run *BatchRun)) {
+	b.mu.Lock()
+	fn(&b.status.Runs[index])
+	run := b.status.Runs[index]
+	progress := b.snapshot(false)
+	b.mu.Unlock()
+	sessionHub.BroadcastOwner(progress.Owner, map[string]interface{}{
+		"type":  "batchProgress",
+		"batch": progress,
+		"run":   run,
+	})
+}
+
+// resolveBatchRuns expands a request into its runs
+func resolveBatchRuns(req BatchRequest) ([]BatchRun, error) {
+	var runs []BatchRun
+	switch {
+	case req.Prompt != "" && len(req.WorkDirs) > 0 && len(req.Prompts) == 0 && req.WorkDir == "":
+		for _, workDir := range req.WorkDirs {
+			runs = append(runs, BatchRun{WorkDir: workDir, Prompt: req.Prompt})
+		}
+	case len(req.Prompts) > 0 && req.WorkDir != "" && req.Prompt == "" && len(req.WorkDirs) == 0:
+		for _, prompt := range req.Prompts {
+			if strings.TrimSpace(prompt) == "" {
+				return nil, fmt.Errorf("prompts must not be empty")
+			}
+			runs = append(runs, BatchRun{WorkDir: req.WorkDir, Prompt: prompt})
+		}
+	default:
+		return nil, fmt.Errorf("give either prompt with workDirs, or prompts with workDir")
+	}
+	if len(runs) > batchMaxRuns {
+		return nil, fmt.Errorf("a batch has at most %d runs", batchMaxRuns)
+	}
+	for i := range runs {
+		runs[i].Index = i
+		runs[i].Status = "queued"
+	}
+	return runs, nil
+}
+
+// StartBatch handles POST /api/chat/batch
+// Starts one claude run per working directory (or per prompt), a few at a time,
+// and returns the batch at once. Progress goes to the owner's chat WebSocket connections
+// as batchProgress messages; GET /api/chat/batch/:id has the same data
+func StartBatch(c *gin.Context) {
+	var req BatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	runs, err := resolveBatchRuns(req)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if req.Parallel <= 0 {
+		req.Parallel = batchDefaultParallel
+	}
+	if req.Parallel > batchMaxParallel {
+		req.Parallel = batchMaxParallel
+	}
+	if req.PermissionMode != "" && !validPermissionModes[req.PermissionMode] {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid permissionMode: %s", req.PermissionMode))
+		return
+	}
+	if _, err := chatBackendFor(req.ChatBackend); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+
+	user := currentUser(c)
+	checked := make(map[string]bool)
+	for _, run := range runs {
+		if checked[run.WorkDir] {
+			continue
+		}
+		checked[run.WorkDir] = true
+		if !userCanAccessPath(user, run.WorkDir) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", run.WorkDir))
+			return
+		}
+		if err := checkWorkDir(run.WorkDir); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+		if err := checkBackend(req.Backend, run.WorkDir); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+	}
+
+	b := &chatBatch{
+		status: BatchStatus{
+			ID:        generateID(),
+			Owner:     ownerID(user),
+			Status:    "running",
+			Parallel:  req.Parallel,
+			CreatedAt: time.Now(),
+			Total:     len(runs),
+			Runs:      runs,
+		},
+		req:   req,
+		user:  user,
+		procs: make(map[int]func() error),
+	}
+	b.logger = requestLogger(c).With("transport", "batch", "batchId", b.status.ID)
+	chatBatchesMu.Lock()
+	chatBatches[b.status.ID] = b
+	pruneChatBatches()
+	chatBatchesMu.Unlock()
+
+	log.Printf("[Batch] %s started by %s: %d runs, %d at a time", b.status.ID, auditUser(user), len(runs), req.Parallel)
+	go b.execute()
+
+	b.mu.Lock()
+	status := b.snapshot(true)
+	b.mu.Unlock()
+	c.JSON(http.StatusAccepted, status)
+}
+
+// pruneChatBatches drops the oldest finished batches beyond batchKeep; caller must hold chatBatchesMu
+func pruneChatBatches() {
+	var finished []*chatBatch
+	for _, b := range chatBatches {
+		b.mu.Lock()
+		if b.status.FinishedAt != nil {
+			finished = append(finished, b)
+		}
+		b.mu.Unlock()
+	}
+	if len(finished) <= batchKeep {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].status.CreatedAt.Before(finished[j].status.CreatedAt) })
+	for _, b := range finished[:len(finished)-batchKeep] {
+		delete(chatBatches, b.status.ID)
+	}
+}
+
+// execute runs the batch with at most Parallel runs at once
+func (b *chatBatch) execute() {
+	slots := make(chan struct{}, b.status.Parallel)
+	var wg sync.WaitGroup
+	for i := range b.status.Runs {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(index int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			b.executeRun(index)
+		}(i)
+	}
+	wg.Wait()
+
+	b.mu.Lock()
+	now := time.Now()
+	b.status.FinishedAt = &now
+	if b.canceled {
+		b.status.Status = "canceled"
+	} else {
+		b.status.Status = "done"
+	}
+	progress := b.snapshot(false)
+	b.mu.Unlock()
+	sessionHub.BroadcastOwner(progress.Owner, map[string]interface{}{
+		"type":  "batchProgress",
+		"batch": progress,
+	})
+	log.Printf("[Batch] %s %s: %d succeeded, %d failed, %d canceled", progress.ID, progress.Status, progress.Succeeded, progress.Failed, progress.Canceled)
+}
+
+// waitForChatSlot blocks while the chat limits are reached; false when the batch was canceled
+func (b *chatBatch) waitForChatSlot() bool {
+	for {
+		b.mu.Lock()
+		canceled := b.canceled
+		b.mu.Unlock()
+		if canceled {
+			return false
+		}
+		if checkChatLimits(b.status.Owner) == nil {
+			return true
+		}
+		time.Sleep(batchSlotWait)
+	}
+}
+
+// executeRun runs claude headlessly for one run of the batch
+func (b *chatBatch) executeRun(index int) {
+	b.mu.Lock()
+	run := b.status.Runs[index]
+	b.mu.Unlock()
+	finish := func(status, errMsg string) {
+		now := time.Now()
+		b.updateRun(index, func(r *BatchRun) {
+			r.Status, r.Error, r.FinishedAt = status, errMsg, &now
+		})
+	}
+
+	if !b.waitForChatSlot() {
+		finish("canceled", "")
+		return
+	}
+	if err := checkBudget(run.WorkDir); err != nil {
+		finish("error", err.Error())
+		return
+	}
+	if err := checkResources(run.WorkDir); err != nil {
+		finish("error", err.Error())
+		return
+	}
+	chatBackend, err := chatBackendFor(b.req.ChatBackend)
+	if err != nil {
+		finish("error", err.Error())
+		return
+	}
+	turn := ChatTurn{
+		WorkDir:        run.WorkDir,
+		Prompt:         run.Prompt,
+		Backend:        b.req.Backend,
+		Logger:         b.logger.With("chatBackend", chatBackend.Name(), "run", index),
+		Model:          b.req.Model,
+		PermissionMode: b.req.PermissionMode,
+		AllowedTools:   b.req.AllowedTools,
+	}
+	if err := inheritProjectSettings(&turn); err != nil {
+		finish("error", err.Error())
+		return
+	}
+
+	b.mu.Lock()
+	if b.canceled {
+		b.mu.Unlock()
+		finish("canceled", "")
+		return
+	}
+	proc, err := chatBackend.Start(turn)
+	if err != nil {
+		b.mu.Unlock()
+		finish("error", err.Error())
+		return
+	}
+	b.procs[index] = proc.Interrupt
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.procs, index)
+		b.mu.Unlock()
+	}()
+
+	startTime := time.Now()
+	processID := getNextProcessID()
+	info := &ProcessInfo{
+		Interrupt:   proc.Interrupt,
+		WorkDir:     run.WorkDir,
+		StartTime:   startTime.Unix(),
+		Mode:        "batch",
+		Backend:     b.req.Backend,
+		ChatBackend: chatBackend.Name(),
+		Owner:       b.status.Owner,
+		pid:         chatProcessPID(proc),
+	}
+	registerProcess(processID, info)
+	b.updateRun(index, func(r *BatchRun) {
+		r.Status, r.ProcessID, r.StartedAt = "running", processID, &startTime
+	})
+
+	recordAudit(AuditEntry{
+		Time:    startTime,
+		User:    auditUser(b.user),
+		Action:  "chat.execute",
+		Target:  b.status.ID,
+		WorkDir: run.WorkDir,
+		Details: map[string]string{"transport": "batch", "prompt": auditPrompt(run.Prompt)},
+	})
+	fireChatStarted(b.user, "batch", "", run.WorkDir, run.Prompt)
+
+	var lastResult *resultEvent
+	var sessionID string
+	diagnostics := newDiagnosticRun("batch", processID, run.WorkDir, b.status.Owner)
+	stdoutDone := make(chan struct{})
+	go func() {
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(proc.Stdout())
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 1024*1024)
+		inputNotified := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+			recordStreamBytes("batch", len(line))
+			if newSessionID := extractInitSessionID(line); newSessionID != "" && sessionID == "" {
+				sessionID = newSessionID
+				recordSessionOwner(sessionID, b.status.Owner)
+				processLock.Lock()
+				info.SessionID = sessionID
+				processLock.Unlock()
+				SetSessionLoading(sessionID, true)
+				SetSessionProcessID(sessionID, &processID)
+				b.updateRun(index, func(r *BatchRun) { r.SessionID = sessionID })
+			}
+			if result := parseResultEvent(line); result != nil {
+				recordResultUsage(result)
+				recordProjectCost(b.status.Owner, run.WorkDir, result)
+				diagnostics.checkResult(result)
+				lastResult = result
+			}
+			trackInputRequest(b.status.Owner, sessionID, line, &inputNotified)
+			trackTodos(sessionID, run.WorkDir, line)
+			trackContextUsage(sessionID, line)
+			recordProcessOutput(processID, line)
+		}
+	}()
+
+	var stderrTail []string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(proc.Stderr())
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				diagnostics.check(line)
+				stderrTail = append(stderrTail, line)
+				if len(stderrTail) > batchStderrLines {
+					stderrTail = stderrTail[1:]
+				}
+			}
+		}
+	}()
+
+	// Wait closes the pipes, so let the readers drain them first
+	<-stdoutDone
+	<-stderrDone
+	err = proc.Wait()
+	unregisterProcess(processID)
+	if sessionID != "" {
+		SetSessionLoading(sessionID, false)
+		SetSessionProcessID(sessionID, nil)
+	}
+
+	duration := time.Since(startTime)
+	outcome := chatOutcome(err)
+	recordChatFinished("batch", startTime, outcome)
+	notifyChatFinished(b.status.Owner, sessionID, run.WorkDir, outcome, duration)
+	fireChatFinished(b.user, "batch", sessionID, run.WorkDir, outcome, duration, lastResult)
+
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		if len(stderrTail) > 0 {
+			errMsg += ": " + strings.Join(stderrTail, "\n")
+		}
+	}
+	b.mu.Lock()
+	canceled := b.canceled
+	b.mu.Unlock()
+	if canceled && outcome != "success" {
+		outcome = "canceled"
+	}
+	now := time.Now()
+	b.updateRun(index, func(r *BatchRun) {
+		if lastResult != nil {
+			r.CostUSD = lastResult.TotalCostUSD
+			r.NumTurns = lastResult.NumTurns
+			if lastResult.IsError {
+				outcome = "error"
+				if errMsg == "" {
+					errMsg = lastResult.Subtype
+				}
+			} else {
+				r.Summary = clipText(lastResult.Result, batchSummaryLimit)
+			}
+		}
+		r.Status, r.Error, r.FinishedAt = outcome, errMsg, &now
+	})
+}
+
+// visibleBatch looks up :id for the current user, writing the error response when not found
+func visibleBatch(c *gin.Context) *chatBatch {
+	chatBatchesMu.Lock()
+	b := chatBatches[c.Param("id")]
+	chatBatchesMu.Unlock()
+	if b == nil || !userCanAccessOwner(currentUser(c), b.status.Owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Batch not found")
+		return nil
+	}
+	return b
+}
+
+// ListBatches handles GET /api/chat/batch
+// Returns the user's batches, newest first, without their runs
+func ListBatches(c *gin.Context) {
+	user := currentUser(c)
+	chatBatchesMu.Lock()
+	batches := make([]BatchStatus, 0, len(chatBatches))
+	for _, b := range chatBatches {
+		if !userCanAccessOwner(user, b.status.Owner) {
+			continue
+		}
+		b.mu.Lock()
+		batches = append(batches, b.snapshot(false))
+		b.mu.Unlock()
+	}
+	chatBatchesMu.Unlock()
+	sort.Slice(batches, func(i, j int) bool { return batches[i].CreatedAt.After(batches[j].CreatedAt) })
+	c.JSON(http.StatusOK, gin.H{"batches": batches})
+}
+
+// GetBatch handles GET /api/chat/batch/:id
+func GetBatch(c *gin.Context) {
+	b := visibleBatch(c)
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	status := b.snapshot(true)
+	b.mu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+// CancelBatch handles DELETE /api/chat/batch/:id
+// Queued runs are dropped and running ones interrupted; finished runs keep their results
+func CancelBatch(c *gin.Context) {
+	b := visibleBatch(c)
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.status.FinishedAt != nil {
+		b.mu.Unlock()
+		respondError(c, http.StatusConflict, ErrConflict, "Batch already finished")
+		return
+	}
+	b.canceled = true
+	interrupts := make([]func() error, 0, len(b.procs))
+	for _, interrupt := range b.procs {
+		interrupts = append(interrupts, interrupt)
+	}
+	b.mu.Unlock()
+	for _, interrupt := range interrupts {
+		interrupt()
+	}
+	log.Printf("[Batch] %s canceled by %s, interrupted %d runs", b.status.ID, auditUser(currentUser(c)), len(interrupts))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodyTooLargeKey is set on the context once a request body has gone past its limit
+const bodyTooLargeKey = "bodyTooLarge"
+
+// bodyLimitOverrides are routes with a limit of their own instead of http.maxBodyMB
+var bodyLimitOverrides = map[string]func() int64{
+	"POST /api/upload":          func() int64 { return maxUploadSize() + uploadMultipartOverhead },
+	"POST /api/upload/extract":  func() int64 { return maxArchiveSize() + uploadMultipartOverhead },
+	"POST /api/restore":         func() int64 { return 0 }, // a backup of a long history runs to gigabytes
+	"POST /api/sessions/import": func() int64 { return sessionImportMaxSize + uploadMultipartOverhead },
+}
+
+// limitedBody is a request body capped by http.MaxBytesReader that remembers hitting the cap
+type limitedBody struct {
+	io.ReadCloser
+	c *gin.Context
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.c.Set(bodyTooLargeKey, tooLarge.Limit)
+	}
+	return n, err
+}
+
+// BodyLimitMiddleware caps request bodies at http.maxBodyMB (0 = unlimited), so a large
+// or endless body can't exhaust memory while a handler decodes it
+// Bodies declaring a larger Content-Length are refused before anything is read
+func BodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int64(getServerConfig().HTTP.MaxBodyMB) * 1024 * 1024
+		if override, ok := bodyLimitOverrides[c.Request.Method+" "+c.FullPath()]; ok {
+			limit = override()
+		}
+		if limit > 0 && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			if c.Request.ContentLength > limit {
+				abortError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, bodyTooLargeMessage(limit))
+				return
+			}
+			c.Request.Body = &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, c.Request.Body, limit), c: c}
+		}
+		c.Next()
+	}
+}
+
+func bodyTooLargeMessage(limit int64) string {
+	return fmt.Sprintf("Request body too large (max %dMB)", limit/(1024*1024))
+}
+
+// bodyLimitError turns the 400 a handler reports for an unreadable body into a 413 when the body was cut off at its limit
+func bodyLimitError(c *gin.Context, status int, code ErrorCode, message string) (int, ErrorCode, string) {
+	if status != http.StatusBadRequest {
+		return status, code, message
+	}
+	if limit, ok := c.Get(bodyTooLargeKey); ok {
+		return http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, bodyTooLargeMessage(limit.(int64))
+	}
+	return status, code, message
+}
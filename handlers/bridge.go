@@ -0,0 +1,713 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// bridgeFlushInterval is how often a running prompt's new output is sent to the messenger
+	bridgeFlushInterval = 5 * time.Second
+	// bridgeMessageLimit splits replies below Telegram's 4096 characters per message
+	bridgeMessageLimit = 3500
+	// bridgeRequestMaxSize bounds an inbound messenger event
+	bridgeRequestMaxSize = 1 << 20
+	// slackRequestMaxAge rejects replayed Slack requests
+	slackRequestMaxAge = 5 * time.Minute
+)
+
+// slackMentionRegex matches <@U123> mentions of the bot in Slack message text
+var slackMentionRegex = regexp.MustCompile(`<@[A-Z0-9]+>`)
+
+// SlackConfig enables POST /api/integrations/slack/events for a Slack app
+type SlackConfig struct {
+	// BotToken (xoxb-...) posts replies; defaults to GREYZONE_SLACK_BOT_TOKEN
+	BotToken string `yaml:"botToken" json:"-"`
+	// SigningSecret checks X-Slack-Signature; defaults to GREYZONE_SLACK_SIGNING_SECRET
+	SigningSecret string `yaml:"signingSecret" json:"-"`
+	APIURL        string `yaml:"apiURL" json:"apiURL"` // https://slack.com/api
+	// Users maps Slack user IDs to the usernames they chat as; nobody else can use the app
+	Users   map[string]string `yaml:"users" json:"users"`
+	WorkDir string            `yaml:"workDir" json:"workDir"` // default for new sessions
+}
+
+// TelegramConfig enables POST /api/integrations/telegram/webhook for a Telegram bot
+type TelegramConfig struct {
+	// BotToken sends replies; defaults to GREYZONE_TELEGRAM_BOT_TOKEN
+	BotToken string `yaml:"botToken" json:"-"`
+	// WebhookSecret is the secret_token given to setWebhook, checked against
+	// X-Telegram-Bot-Api-Secret-Token; defaults to GREYZONE_TELEGRAM_WEBHOOK_SECRET
+	WebhookSecret string `yaml:"webhookSecret" json:"-"`
+	APIURL        string `yaml:"apiURL" json:"apiURL"` // https://api.telegram.org
+	// Users maps Telegram user IDs to the usernames they chat as; nobody else can use the bot
+	Users   map[string]string `yaml:"users" json:"users"`
+	WorkDir string            `yaml:"workDir" json:"workDir"` // default for new sessions
+}
+
+// bridgeConversation is where a messenger conversation is in claude, kept in bridge-sessions.json
+type bridgeConversation struct {
+	SessionID string    `json:"sessionId,omitempty"`
+	WorkDir   string    `json:"workDir,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// bridgeMessage is a message received through a messenger
+type bridgeMessage struct {
+	Key     string // the conversation, e.g. telegram:<chat id>
+	Sender  string // the messenger's user ID
+	Text    string
+	Users   map[string]string
+	WorkDir string
+	Logger  *slog.Logger
+	reply   func(text string) error
+}
+
+var (
+	bridgeMu            sync.Mutex
+	bridgeConversations map[string]bridgeConversation
+	bridgeLoaded        bool
+	// bridgeRuns holds the interrupt of each conversation's running prompt
+	bridgeRuns = make(map[string]func() error)
+)
+
+func bridgeSessionsPath() string {
+	return serverDataPath("bridge-sessions.json")
+}
+
+// loadBridgeConversations reads conversations once; caller must hold bridgeMu
+func loadBridgeConversations() error {
+	if bridgeLoaded {
+		return nil
+	}
+	bridgeConversations = make(map[string]bridgeConversation)
+	if err := loadJSONFile(bridgeSessionsPath(), &bridgeConversations); err != nil {
+		return err
+	}
+	bridgeLoaded = true
+	return nil
+}
+
+// setBridgeConversation stores a conversation, or forgets it when conv is nil
+func setBridgeConversation(key string, conv *bridgeConversation) {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+	if err := loadBridgeConversations(); err != nil {
+		log.Printf("[Bridge] Failed to load conversations: %v", err)
+		return
+	}
+	if conv == nil {
+		delete(bridgeConversations, key)
+	} else {
+		conv.UpdatedAt = time.Now()
+		bridgeConversations[key] = *conv
+	}
+	if err := writeJSONFileAtomicMode(bridgeSessionsPath(), bridgeConversations, 0600); err != nil {
+		log.Printf("[Bridge] Failed to save conversations: %v", err)
+	}
+}
+
+// getBridgeConversation returns a conversation's session and working directory
+func getBridgeConversation(key string) bridgeConversation {
+	bridgeMu.Lock()
+	defer bridgeMu.Unlock()
+	if err := loadBridgeConversations(); err != nil {
+		log.Printf("[Bridge] Failed to load conversations: %v", err)
+	}
+	return bridgeConversations[key]
+}
+
+// splitBridgeText cuts text into messenger-sized parts, at line breaks where possible
+func splitBridgeText(text string) []string {
+	var parts []string
+	for len(text) > bridgeMessageLimit {
+		cut := strings.LastIndex(text[:bridgeMessageLimit], "\n")
+		if cut <= 0 {
+			cut = bridgeMessageLimit
+		}
+		parts = append(parts, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	if strings.TrimSpace(text) != "" {
+		parts = append(parts, text)
+	}
+	return parts
+}
+
+// send replies with text, split as needed
+func (m bridgeMessage) send(text string) {
+	for _, part := range splitBridgeText(text) {
+		if err := m.reply(part); err != nil {
+			log.Printf("[Bridge] Failed to reply in %s: %v", m.Key, err)
+			return
+		}
+	}
+}
+
+// bridgeHelp is the reply to /help
+const bridgeHelp = `Send a message to prompt claude; the conversation continues the same session.
+/new [dir]: start a new session, optionally in another working directory
+/stop: interrupt the running prompt
+/status: show the session and working directory`
+
+// handleBridgeMessage runs a command or a prompt from a messenger
+func handleBridgeMessage(m bridgeMessage) {
+	username, ok := m.Users[m.Sender]
+	if !ok {
+		m.send(fmt.Sprintf("You're not allowed to use this bot (user ID %s).", m.Sender))
+		return
+	}
+	var user *User
+	if authEnabled() {
+		user = authManager.findUser(func(u *User) bool { return u.Username == username })
+		if user == nil {
+			m.send(fmt.Sprintf("User %q does not exist on the server.", username))
+			return
+		}
+	}
+
+	text := strings.TrimSpace(m.Text)
+	if text == "" {
+		return
+	}
+	if text[0] != '/' && text[0] != '!' {
+		runBridgePrompt(m, user, text)
+		return
+	}
+	fields := strings.Fields(text[1:])
+	command := ""
+	if len(fields) > 0 {
+		// Telegram addresses commands in groups as /new@botname
+		command = strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	}
+	conv := getBridgeConversation(m.Key)
+	switch command {
+	case "new":
+		next := bridgeConversation{}
+		if len(fields) > 1 {
+			next.WorkDir = expandHome(strings.Join(fields[1:], " "))
+			if !userCanAccessPath(user, next.WorkDir) {
+				m.send(fmt.Sprintf("Working directory is outside your projects: %s", next.WorkDir))
+				return
+			}
+			if err := checkWorkDir(next.WorkDir); err != nil {
+				m.send(err.Error())
+				return
+			}
+		} else {
+			next.WorkDir = conv.WorkDir
+		}
+		setBridgeConversation(m.Key, &next)
+		if next.WorkDir != "" {
+			m.send("Started a new session in " + next.WorkDir + ".")
+		} else {
+			m.send("Started a new session.")
+		}
+	case "stop":
+		bridgeMu.Lock()
+		interrupt := bridgeRuns[m.Key]
+		bridgeMu.Unlock()
+		if interrupt == nil {
+			m.send("Nothing is running.")
+			return
+		}
+		if err := interrupt(); err != nil {
+			m.send(fmt.Sprintf("Failed to stop: %v", err))
+		}
+	case "status":
+		bridgeMu.Lock()
+		_, running := bridgeRuns[m.Key]
+		bridgeMu.Unlock()
+		status := "No session yet"
+		if conv.SessionID != "" {
+			status = "Session " + conv.SessionID
+		}
+		if conv.WorkDir != "" {
+			status += " in " + conv.WorkDir
+		}
+		if running {
+			status += ", running"
+		}
+		m.send(status + ".")
+	default:
+		m.send(bridgeHelp)
+	}
+}
+
+// bridgeOutput collects a run's assistant text and tool calls between flushes
+type bridgeOutput struct {
+	mu      sync.Mutex
+	pending strings.Builder
+}
+
+// add appends what a stream line says
+func (o *bridgeOutput) add(line string) {
+	if !strings.Contains(line, `"assistant"`) {
+		return
+	}
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+				Name string `json:"name"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal([]byte(line), &event) != nil || event.Type != "assistant" {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, block := range event.Message.Content {
+		switch block.Type {
+		case "text":
+			if text := strings.TrimSpace(block.Text); text != "" {
+				o.pending.WriteString(text + "\n\n")
+			}
+		case "tool_use":
+			o.pending.WriteString("› " + block.Name + "\n")
+		}
+	}
+}
+
+// take returns and clears the collected output
+func (o *bridgeOutput) take() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	text := strings.TrimSpace(o.pending.String())
+	o.pending.Reset()
+	return text
+}
+
+// runBridgePrompt runs a prompt in the conversation's session, sending its output as it comes
+// One prompt runs per conversation at a time
+func runBridgePrompt(m bridgeMessage, user *User, prompt string) {
+	owner := ownerID(user)
+	bridgeMu.Lock()
+	if _, running := bridgeRuns[m.Key]; running {
+		bridgeMu.Unlock()
+		m.send("Still working on the previous message; send /stop to interrupt it.")
+		return
+	}
+	bridgeRuns[m.Key] = nil
+	bridgeMu.Unlock()
+	defer func() {
+		bridgeMu.Lock()
+		delete(bridgeRuns, m.Key)
+		bridgeMu.Unlock()
+	}()
+
+	conv := getBridgeConversation(m.Key)
+	workDir := conv.WorkDir
+	if workDir == "" && conv.SessionID != "" {
+		workDir = GetSessionWorkDir(conv.SessionID)
+	}
+	if workDir == "" {
+		workDir = expandHome(m.WorkDir)
+	}
+	if workDir == "" && user != nil && len(user.ProjectRoots) > 0 {
+		workDir = user.ProjectRoots[0]
+	}
+	if workDir == "" {
+		workDir, _ = os.UserHomeDir()
+	}
+	if !userCanAccessPath(user, workDir) {
+		m.send(fmt.Sprintf("Working directory is outside your projects: %s", workDir))
+		return
+	}
+	if conv.SessionID != "" && IsSessionLoading(conv.SessionID) {
+		m.send("This session is already processing a request.")
+		return
+	}
+	for _, check := range []func() error{
+		func() error { return checkWorkDir(workDir) },
+		func() error { return checkChatLimits(owner) },
+		func() error { return checkBudget(workDir) },
+		func() error { return checkResources(workDir) },
+	} {
+		if err := check(); err != nil {
+			m.send(err.Error())
+			return
+		}
+	}
+	chatBackend, err := chatBackendFor("")
+	if err != nil {
+		m.send(err.Error())
+		return
+	}
+	turn := ChatTurn{
+		WorkDir:   workDir,
+		SessionID: conv.SessionID,
+		Prompt:    prompt,
+		Logger:    m.Logger.With("chatBackend", chatBackend.Name()),
+	}
+	if err := inheritProjectSettings(&turn); err != nil {
+		m.send(err.Error())
+		return
+	}
+	proc, err := chatBackend.Start(turn)
+	if err != nil {
+		m.send(err.Error())
+		return
+	}
+
+	startTime := time.Now()
+	processID := getNextProcessID()
+	info := &ProcessInfo{
+		Interrupt:   proc.Interrupt,
+		SessionID:   conv.SessionID,
+		WorkDir:     workDir,
+		StartTime:   startTime.Unix(),
+		Mode:        "bridge",
+		ChatBackend: chatBackend.Name(),
+		Owner:       owner,
+		pid:         chatProcessPID(proc),
+	}
+	registerProcess(processID, info)
+	bridgeMu.Lock()
+	bridgeRuns[m.Key] = proc.Interrupt
+	bridgeMu.Unlock()
+
+	recordAudit(AuditEntry{
+		Time:      startTime,
+		User:      auditUser(user),
+		Action:    "chat.execute",
+		SessionID: conv.SessionID,
+		WorkDir:   workDir,
+		Details:   map[string]string{"transport": "bridge", "conversation": m.Key, "prompt": auditPrompt(prompt)},
+	})
+	fireChatStarted(user, "bridge", conv.SessionID, workDir, prompt)
+
+	sessionID := conv.SessionID
+	if sessionID != "" {
+		SetSessionLoading(sessionID, true)
+		SetSessionProcessID(sessionID, &processID)
+	}
+	output := &bridgeOutput{}
+	flushDone := make(chan struct{})
+	stopFlush := make(chan struct{})
+	go func() {
+		defer close(flushDone)
+		ticker := time.NewTicker(bridgeFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if text := output.take(); text != "" {
+					m.send(text)
+				}
+			case <-stopFlush:
+				return
+			}
+		}
+	}()
+
+	var lastResult *resultEvent
+	diagnostics := newDiagnosticRun("bridge", processID, workDir, owner)
+	stdoutDone := make(chan struct{})
+	go func() {
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(proc.Stdout())
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 1024*1024)
+		inputNotified := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+			recordStreamBytes("bridge", len(line))
+			if newSessionID := extractInitSessionID(line); newSessionID != "" && newSessionID != sessionID {
+				if sessionID != "" {
+					SetSessionLoading(sessionID, false)
+					SetSessionProcessID(sessionID, nil)
+				}
+				sessionID = newSessionID
+				recordSessionOwner(sessionID, owner)
+				processLock.Lock()
+				info.SessionID = sessionID
+				processLock.Unlock()
+				SetSessionLoading(sessionID, true)
+				SetSessionProcessID(sessionID, &processID)
+			}
+			if result := parseResultEvent(line); result != nil {
+				recordResultUsage(result)
+				recordProjectCost(owner, workDir, result)
+				diagnostics.checkResult(result)
+				lastResult = result
+			}
+			output.add(line)
+			trackInputRequest(owner, sessionID, line, &inputNotified)
+			trackTodos(sessionID, workDir, line)
+			trackContextUsage(sessionID, line)
+			recordProcessOutput(processID, line)
+		}
+	}()
+	var stderrTail []string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(proc.Stderr())
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				diagnostics.check(line)
+				stderrTail = append(stderrTail, line)
+				if len(stderrTail) > scheduleStderrLines {
+					stderrTail = stderrTail[1:]
+				}
+			}
+		}
+	}()
+
+	// Wait closes the pipes, so let the readers drain them first
+	<-stdoutDone
+	<-stderrDone
+	err = proc.Wait()
+	unregisterProcess(processID)
+	if sessionID != "" {
+		SetSessionLoading(sessionID, false)
+		SetSessionProcessID(sessionID, nil)
+		setBridgeConversation(m.Key, &bridgeConversation{SessionID: sessionID, WorkDir: workDir})
+	}
+	close(stopFlush)
+	<-flushDone
+
+	duration := time.Since(startTime)
+	outcome := chatOutcome(err)
+	recordChatFinished("bridge", startTime, outcome)
+	fireChatFinished(user, "bridge", sessionID, workDir, outcome, duration, lastResult)
+
+	reply := output.take()
+	switch {
+	case outcome == "interrupted":
+		reply += "\n\nStopped."
+	case lastResult != nil && !lastResult.IsError:
+		reply += fmt.Sprintf("\n\nDone in %s, $%.2f.", duration.Round(time.Second), lastResult.TotalCostUSD)
+	default:
+		msg := "claude failed"
+		if err != nil {
+			msg += ": " + err.Error()
+		} else if lastResult != nil {
+			msg += ": " + lastResult.Subtype
+		}
+		if len(stderrTail) > 0 {
+			msg += "\n" + strings.Join(stderrTail, "\n")
+		}
+		reply += "\n\n" + msg
+	}
+	m.send(strings.TrimSpace(reply))
+}
+
+// readBridgeRequest reads a messenger event, writing an error response when it's too large
+func readBridgeRequest(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, bridgeRequestMaxSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return nil, false
+	}
+	if len(body) > bridgeRequestMaxSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("events are limited to %d MB", bridgeRequestMaxSize>>20))
+		return nil, false
+	}
+	return body, true
+}
+
+// bridgePost POSTs a JSON body to a messenger API, failing on non-2xx statuses
+func bridgePost(endpoint string, token string, body interface{}) ([]byte, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("User-Agent", "claude-greyzone/"+Version)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	out, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(out)))
+	}
+	return out, nil
+}
+
+// validSlackSignature checks X-Slack-Signature, v0= the HMAC-SHA256 of v0:timestamp:body
+func validSlackSignature(secret string, c *gin.Context, body []byte) bool {
+	ts := c.GetHeader("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Since(time.Unix(sec, 0)).Abs() > slackRequestMaxAge {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + ts + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Slack-Signature")), []byte(expected)) == 1
+}
+
+// SlackEvents handles POST /api/integrations/slack/events, the Slack app's Events API request URL
+// Direct messages and mentions of the app are prompts; replies go to the message's thread
+func SlackEvents(c *gin.Context) {
+	cfg := getServerConfig().Integrations.Slack
+	if cfg.SigningSecret == "" || cfg.BotToken == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Slack is not configured")
+		return
+	}
+	body, ok := readBridgeRequest(c)
+	if !ok {
+		return
+	}
+	if !validSlackSignature(cfg.SigningSecret, c, body) {
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid Slack signature")
+		return
+	}
+	var payload struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Event     struct {
+			Type     string `json:"type"`
+			Subtype  string `json:"subtype"`
+			BotID    string `json:"bot_id"`
+			User     string `json:"user"`
+			Text     string `json:"text"`
+			Channel  string `json:"channel"`
+			TS       string `json:"ts"`
+			ThreadTS string `json:"thread_ts"`
+		} `json:"event"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid event: %v", err))
+		return
+	}
+	if payload.Type == "url_verification" {
+		c.JSON(http.StatusOK, gin.H{"challenge": payload.Challenge})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+
+	// Slack retries events it didn't get a response for in 3 seconds; the first delivery is already running
+	ev := payload.Event
+	if c.GetHeader("X-Slack-Retry-Num") != "" || payload.Type != "event_callback" ||
+		(ev.Type != "message" && ev.Type != "app_mention") || ev.Subtype != "" || ev.BotID != "" || ev.User == "" {
+		return
+	}
+	thread := ev.ThreadTS
+	if thread == "" {
+		thread = ev.TS
+	}
+	endpoint := strings.TrimSuffix(cfg.APIURL, "/")
+	if endpoint == "" {
+		endpoint = "https://slack.com/api"
+	}
+	m := bridgeMessage{
+		Key:     "slack:" + ev.Channel + ":" + thread,
+		Sender:  ev.User,
+		Text:    strings.TrimSpace(slackMentionRegex.ReplaceAllString(ev.Text, "")),
+		Users:   cfg.Users,
+		WorkDir: cfg.WorkDir,
+		Logger:  requestLogger(c).With("transport", "bridge", "messenger", "slack"),
+		reply: func(text string) error {
+			out, err := bridgePost(endpoint+"/chat.postMessage", cfg.BotToken,
+				map[string]string{"channel": ev.Channel, "thread_ts": thread, "text": text})
+			if err != nil {
+				return err
+			}
+			var resp struct {
+				OK    bool   `json:"ok"`
+				Error string `json:"error"`
+			}
+			if json.Unmarshal(out, &resp) == nil && !resp.OK {
+				return fmt.Errorf("slack: %s", resp.Error)
+			}
+			return nil
+		},
+	}
+	go handleBridgeMessage(m)
+}
+
+// TelegramWebhook handles POST /api/integrations/telegram/webhook, set as the bot's webhook
+// Each chat with the bot is a conversation that continues one session
+func TelegramWebhook(c *gin.Context) {
+	cfg := getServerConfig().Integrations.Telegram
+	if cfg.WebhookSecret == "" || cfg.BotToken == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Telegram is not configured")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Telegram-Bot-Api-Secret-Token")), []byte(cfg.WebhookSecret)) != 1 {
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid webhook secret")
+		return
+	}
+	body, ok := readBridgeRequest(c)
+	if !ok {
+		return
+	}
+	var update struct {
+		Message *struct {
+			Text string `json:"text"`
+			From struct {
+				ID int64 `json:"id"`
+			} `json:"from"`
+			Chat struct {
+				ID int64 `json:"id"`
+			} `json:"chat"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(body, &update); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid update: %v", err))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+	if update.Message == nil || update.Message.Text == "" {
+		return
+	}
+
+	endpoint := strings.TrimSuffix(cfg.APIURL, "/")
+	if endpoint == "" {
+		endpoint = "https://api.telegram.org"
+	}
+	chatID := update.Message.Chat.ID
+	m := bridgeMessage{
+		Key:     "telegram:" + strconv.FormatInt(chatID, 10),
+		Sender:  strconv.FormatInt(update.Message.From.ID, 10),
+		Text:    update.Message.Text,
+		Users:   cfg.Users,
+		WorkDir: cfg.WorkDir,
+		Logger:  requestLogger(c).With("transport", "bridge", "messenger", "telegram"),
+		reply: func(text string) error {
+			_, err := bridgePost(endpoint+"/bot"+cfg.BotToken+"/sendMessage", "",
+				map[string]interface{}{"chat_id": chatID, "text": text})
+			return err
+		},
+	}
+	go handleBridgeMessage(m)
+}
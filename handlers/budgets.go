@@ -0,0 +1,463 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultBudgetWarnAt are the spend percentages that trigger notifications
+var defaultBudgetWarnAt = []int{80, 100}
+
+// Budget is a monthly spending limit for a project directory (including subdirectories)
+type Budget struct {
+	ID         string    `json:"id"`
+	Project    string    `json:"project"`
+	MonthlyUSD float64   `json:"monthlyUsd"`
+	WarnAt     []int     `json:"warnAt"`  // percentages of MonthlyUSD that notify
+	Enforce    bool      `json:"enforce"` // refuse new chats once the budget is spent
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// BudgetInfo is a budget with the current month's spend
+type BudgetInfo struct {
+	Budget
+	SpentUSD  float64 `json:"spentUsd"`
+	Percent   float64 `json:"percent"`
+	Exhausted bool    `json:"exhausted"`
+}
+
+// BudgetRequest is the body of POST/PUT /api/budgets
+// On update, nil fields are left unchanged
+type BudgetRequest struct {
+	Project    *string  `json:"project"`
+	MonthlyUSD *float64 `json:"monthlyUsd"`
+	WarnAt     *[]int   `json:"warnAt"`
+	Enforce    *bool    `json:"enforce"`
+}
+
+// ProjectUsage is the accumulated cost of claude runs in one working directory for a month
+type ProjectUsage struct {
+	Project      string    `json:"project"`
+	CostUSD      float64   `json:"costUsd"`
+	Runs         int       `json:"runs"`
+	InputTokens  float64   `json:"inputTokens"`
+	OutputTokens float64   `json:"outputTokens"`
+	LastRunAt    time.Time `json:"lastRunAt"`
+}
+
+// UsageResponse is the body of GET /api/usage
+type UsageResponse struct {
+	Month        string         `json:"month"`
+	TotalCostUSD float64        `json:"totalCostUsd"`
+	Projects     []ProjectUsage `json:"projects"`
+	Budgets      []BudgetInfo   `json:"budgets"`
+}
+
+// budgetStore is the on-disk format of budgets.json
+type budgetStore struct {
+	Budgets []Budget                            `json:"budgets"`
+	Usage   map[string]map[string]*ProjectUsage `json:"usage"`  // month -> workDir -> usage
+	Warned  map[string]map[string]int           `json:"warned"` // month -> budget ID -> highest threshold notified
+}
+
+var (
+	budgetsMu     sync.Mutex
+	budgets       budgetStore
+	budgetsLoaded bool
+)
+
+func budgetsPath() string {
+	return serverDataPath("budgets.json")
+}
+
+// loadBudgets reads budgets and usage once; caller must hold budgetsMu
+func loadBudgets() error {
+	if budgetsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(budgetsPath(), &budgets); err != nil {
+		return err
+	}
+	if budgets.Usage == nil {
+		budgets.Usage = make(map[string]map[string]*ProjectUsage)
+	}
+	if budgets.Warned == nil {
+		budgets.Warned = make(map[string]map[string]int)
+	}
+	budgetsLoaded = true
+	return nil
+}
+
+func saveBudgets() error {
+	return writeJSONFileAtomic(budgetsPath(), budgets)
+}
+
+// usageMonth returns the accounting month for t, e.g. "2026-01"
+func usageMonth(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+// pathWithin reports whether path is dir or inside it
+func pathWithin(path string, dir string) bool {
+	path, dir = filepath.Clean(path), filepath.Clean(dir)
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// spent sums the month's cost of every working directory under the budget's project; caller must hold budgetsMu
+func (b Budget) spent(month string) float64 {
+	total := 0.0
+	for workDir, u := range budgets.Usage[month] {
+		if pathWithin(workDir, b.Project) {
+			total += u.CostUSD
+		}
+	}
+	return total
+}
+
+// info adds the month's spend; caller must hold budgetsMu
+func (b Budget) info(month string) BudgetInfo {
+	info := BudgetInfo{Budget: b, SpentUSD: b.spent(month)}
+	if b.MonthlyUSD > 0 {
+		info.Percent = info.SpentUSD / b.MonthlyUSD * 100
+	}
+	info.Exhausted = info.SpentUSD >= b.MonthlyUSD
+	return info
+}
+
+// checkBudget returns an error if workDir falls under an enforced budget that is already spent
+func checkBudget(workDir string) error {
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		log.Printf("[Budgets] Failed to load budgets: %v", err)
+		return nil
+	}
+	month := usageMonth(time.Now())
+	for _, b := range budgets.Budgets {
+		if !b.Enforce || !pathWithin(workDir, b.Project) {
+			continue
+		}
+		if spent := b.spent(month); spent >= b.MonthlyUSD {
+			return withCode(http.StatusTooManyRequests, ErrBudgetExceeded, fmt.Errorf("monthly budget for %s is exhausted ($%.2f of $%.2f)", b.Project, spent, b.MonthlyUSD))
+		}
+	}
+	return nil
+}
+
+// budgetAlert is a threshold crossing found while recording cost
+type budgetAlert struct {
+	info      BudgetInfo
+	threshold int
+}
+
+// recordProjectCost adds a run's cost to its working directory and notifies on budget thresholds
+func recordProjectCost(owner string, workDir string, result *resultEvent) {
+	if result == nil || workDir == "" {
+		return
+	}
+	workDir = filepath.Clean(workDir)
+	now := time.Now()
+	month := usageMonth(now)
+
+	budgetsMu.Lock()
+	if err := loadBudgets(); err != nil {
+		budgetsMu.Unlock()
+		log.Printf("[Budgets] Failed to load budgets: %v", err)
+		return
+	}
+	if budgets.Usage[month] == nil {
+		budgets.Usage[month] = make(map[string]*ProjectUsage)
+	}
+	u := budgets.Usage[month][workDir]
+	if u == nil {
+		u = &ProjectUsage{Project: workDir}
+		budgets.Usage[month][workDir] = u
+	}
+	u.CostUSD += result.TotalCostUSD
+	u.Runs++
+	u.InputTokens += result.Usage.InputTokens
+	u.OutputTokens += result.Usage.OutputTokens
+	u.LastRunAt = now
+
+	var alerts []budgetAlert
+	if budgets.Warned[month] == nil {
+		budgets.Warned[month] = make(map[string]int)
+	}
+	for _, b := range budgets.Budgets {
+		if !pathWithin(workDir, b.Project) || b.MonthlyUSD <= 0 {
+			continue
+		}
+		info := b.info(month)
+		crossed := 0
+		for _, pct := range b.WarnAt {
+			if info.Percent >= float64(pct) && pct > crossed {
+				crossed = pct
+			}
+		}
+		// Exhaustion always alerts, even without a 100% threshold
+		if info.Exhausted && crossed < 100 {
+			crossed = 100
+		}
+		if crossed > budgets.Warned[month][b.ID] {
+			budgets.Warned[month][b.ID] = crossed
+			alerts = append(alerts, budgetAlert{info: info, threshold: crossed})
+		}
+	}
+	if err := saveBudgets(); err != nil {
+		log.Printf("[Budgets] Failed to save usage: %v", err)
+	}
+	budgetsMu.Unlock()
+
+	for _, a := range alerts {
+		notifyBudget(owner, a.info, a.threshold)
+	}
+}
+
+// notifyBudget warns the run's owner and, once the budget is spent, fires budget.exceeded
+func notifyBudget(owner string, b BudgetInfo, threshold int) {
+	project := filepath.Base(b.Project)
+	log.Printf("[Budgets] %s reached %d%% of its monthly budget ($%.2f of $%.2f)", b.Project, threshold, b.SpentUSD, b.MonthlyUSD)
+
+	title := fmt.Sprintf("%s reached %d%% of its budget", project, threshold)
+	if b.Exhausted {
+		title = fmt.Sprintf("%s budget exhausted", project)
+	}
+	body := fmt.Sprintf("$%.2f of $%.2f this month", b.SpentUSD, b.MonthlyUSD)
+	if b.Exhausted && b.Enforce {
+		body += "; new chats are blocked"
+	}
+	go pushManager.notify(owner, PushNotification{Event: "budget", Title: title, Body: body})
+
+	if b.Exhausted {
+		fireWebhooks(WebhookPayload{
+			Event:     WebhookBudgetExceeded,
+			User:      auditUser(userByID(owner)),
+			WorkDir:   b.Project,
+			CostUSD:   b.SpentUSD,
+			BudgetUSD: b.MonthlyUSD,
+			Summary:   body,
+		})
+	}
+}
+
+func validateBudget(b Budget) error {
+	if b.Project == "" || !filepath.IsAbs(b.Project) {
+		return fmt.Errorf("project must be an absolute path")
+	}
+	if b.MonthlyUSD <= 0 {
+		return fmt.Errorf("monthlyUsd must be positive")
+	}
+	for _, pct := range b.WarnAt {
+		if pct <= 0 || pct > 1000 {
+			return fmt.Errorf("warnAt percentages must be between 1 and 1000")
+		}
+	}
+	return nil
+}
+
+func applyBudgetRequest(b *Budget, req BudgetRequest) {
+	if req.Project != nil {
+		b.Project = filepath.Clean(*req.Project)
+	}
+	if req.MonthlyUSD != nil {
+		b.MonthlyUSD = *req.MonthlyUSD
+	}
+	if req.WarnAt != nil {
+		b.WarnAt = append([]int(nil), *req.WarnAt...)
+		sort.Ints(b.WarnAt)
+	}
+	if req.Enforce != nil {
+		b.Enforce = *req.Enforce
+	}
+}
+
+// findBudget returns the index of id; caller must hold budgetsMu
+func findBudget(id string) int {
+	for i, b := range budgets.Budgets {
+		if b.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ListBudgets handles GET /api/budgets
+func ListBudgets(c *gin.Context) {
+	user := currentUser(c)
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load budgets", err.Error())
+		return
+	}
+	month := usageMonth(time.Now())
+	list := make([]BudgetInfo, 0, len(budgets.Budgets))
+	for _, b := range budgets.Budgets {
+		if userCanAccessPath(user, b.Project) {
+			list = append(list, b.info(month))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"budgets": list})
+}
+
+// CreateBudget handles POST /api/budgets
+func CreateBudget(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	now := time.Now()
+	b := Budget{ID: generateID(), WarnAt: defaultBudgetWarnAt, CreatedAt: now, UpdatedAt: now}
+	applyBudgetRequest(&b, req)
+	if err := validateBudget(b); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load budgets", err.Error())
+		return
+	}
+	for _, existing := range budgets.Budgets {
+		if existing.Project == b.Project {
+			respondErrorDetails(c, http.StatusConflict, ErrConflict, "A budget for this project already exists", existing.ID)
+			return
+		}
+	}
+	budgets.Budgets = append(budgets.Budgets, b)
+	if err := saveBudgets(); err != nil {
+		budgets.Budgets = budgets.Budgets[:len(budgets.Budgets)-1]
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save budgets", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, b.info(usageMonth(now)))
+}
+
+// UpdateBudget handles PUT /api/budgets/:id
+func UpdateBudget(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req BudgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load budgets", err.Error())
+		return
+	}
+	i := findBudget(c.Param("id"))
+	if i < 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Budget not found")
+		return
+	}
+	b := budgets.Budgets[i]
+	applyBudgetRequest(&b, req)
+	if err := validateBudget(b); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	b.UpdatedAt = time.Now()
+	month := usageMonth(b.UpdatedAt)
+	previous := budgets.Budgets[i]
+	budgets.Budgets[i] = b
+	// Re-arm notifications so a raised limit warns again
+	warned := budgets.Warned[month][b.ID]
+	delete(budgets.Warned[month], b.ID)
+	if err := saveBudgets(); err != nil {
+		budgets.Budgets[i] = previous
+		if warned > 0 {
+			budgets.Warned[month][b.ID] = warned
+		}
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save budgets", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, b.info(month))
+}
+
+// DeleteBudget handles DELETE /api/budgets/:id
+func DeleteBudget(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load budgets", err.Error())
+		return
+	}
+	i := findBudget(c.Param("id"))
+	if i < 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Budget not found")
+		return
+	}
+	previous := budgets.Budgets
+	budgets.Budgets = append(append([]Budget(nil), previous[:i]...), previous[i+1:]...)
+	if err := saveBudgets(); err != nil {
+		budgets.Budgets = previous
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save budgets", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetUsage handles GET /api/usage
+// Query parameters:
+//   - month: accounting month as YYYY-MM (default: current month)
+//   - project: only working directories under this path
+func GetUsage(c *gin.Context) {
+	user := currentUser(c)
+	month := c.DefaultQuery("month", usageMonth(time.Now()))
+	if _, err := time.Parse("2006-01", month); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "month must be YYYY-MM")
+		return
+	}
+	project := c.Query("project")
+
+	budgetsMu.Lock()
+	defer budgetsMu.Unlock()
+	if err := loadBudgets(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load usage", err.Error())
+		return
+	}
+	resp := UsageResponse{Month: month, Projects: []ProjectUsage{}, Budgets: []BudgetInfo{}}
+	for workDir, u := range budgets.Usage[month] {
+		if project != "" && !pathWithin(workDir, project) {
+			continue
+		}
+		if !userCanAccessPath(user, workDir) {
+			continue
+		}
+		resp.Projects = append(resp.Projects, *u)
+		resp.TotalCostUSD += u.CostUSD
+	}
+	sort.Slice(resp.Projects, func(i, j int) bool { return resp.Projects[i].CostUSD > resp.Projects[j].CostUSD })
+	for _, b := range budgets.Budgets {
+		if project != "" && !pathWithin(b.Project, project) && !pathWithin(project, b.Project) {
+			continue
+		}
+		if userCanAccessPath(user, b.Project) {
+			resp.Budgets = append(resp.Budgets, b.info(month))
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
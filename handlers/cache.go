@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseCacheTTL bounds staleness for files the config watcher doesn't cover (e.g. plugin contents)
+const responseCacheTTL = 30 * time.Second
+
+// cacheInvalidators maps a cached endpoint to the config change kinds that invalidate it
+var cacheInvalidators = map[string][]string{
+	"commands": {"commands", "plugins"},
+	"config":   {"claudeMd"},
+	"plugins":  {"plugins", "commands", "agents", "skills"},
+	"mcp":      {"mcp"},
+}
+
+type cachedResponse struct {
+	name        string
+	workDir     string
+	body        []byte
+	contentType string
+	etag        string
+	expires     time.Time
+}
+
+var (
+	responseCacheMu sync.Mutex
+	responseCache   = make(map[string]*cachedResponse)
+)
+
+// cacheWriter holds back a handler's response so the ETag can be set before the body is sent
+type cacheWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// serveCached writes entry, or 304 when the client already has it
+func serveCached(c *gin.Context, entry *cachedResponse, state string) {
+	c.Header("ETag", entry.etag)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Cache", state)
+	if c.GetHeader("If-None-Match") == entry.etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, entry.contentType, entry.body)
+}
+
+// Cached serves a GET endpoint from memory, keyed by query string, until the TTL expires
+// or the config watcher reports a change of a kind listed in cacheInvalidators
+// Responses carry an ETag; If-None-Match gets 304 Not Modified
+func Cached(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// A cached response may have been built for another user; check access before serving it
+		workDir := c.Query("work_dir")
+		if workDir == "" {
+			workDir = "."
+		}
+		if !requireWorkDirAccess(c, workDir) {
+			c.Abort()
+			return
+		}
+		key := name + "?" + c.Request.URL.RawQuery
+
+		responseCacheMu.Lock()
+		entry, ok := responseCache[key]
+		if ok && time.Now().After(entry.expires) {
+			delete(responseCache, key)
+			ok = false
+		}
+		responseCacheMu.Unlock()
+		if ok {
+			serveCached(c, entry, "HIT")
+			c.Abort()
+			return
+		}
+
+		original := c.Writer
+		writer := &cacheWriter{ResponseWriter: original}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		if original.Status() != http.StatusOK {
+			original.Write(writer.body.Bytes())
+			return
+		}
+		sum := sha256.Sum256(writer.body.Bytes())
+		entry = &cachedResponse{
+			name:        name,
+			workDir:     filepath.Clean(workDir),
+			body:        writer.body.Bytes(),
+			contentType: original.Header().Get("Content-Type"),
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+			expires:     time.Now().Add(responseCacheTTL),
+		}
+		responseCacheMu.Lock()
+		responseCache[key] = entry
+		responseCacheMu.Unlock()
+		serveCached(c, entry, "MISS")
+	}
+}
+
+// invalidateResponseCache drops cached responses affected by a config change
+// User-scope changes affect every workDir; project-scope changes only their own
+func invalidateResponseCache(change ConfigChange) {
+	responseCacheMu.Lock()
+	defer responseCacheMu.Unlock()
+	for key, entry := range responseCache {
+		affected := false
+		for _, kind := range cacheInvalidators[entry.name] {
+			if kind == change.Kind {
+				affected = true
+				break
+			}
+		}
+		if !affected || (change.Scope == "project" && entry.workDir != change.WorkDir) {
+			continue
+		}
+		delete(responseCache, key)
+	}
+}
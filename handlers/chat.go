@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"os/exec"
@@ -14,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,10 +24,17 @@ var imagePathRegex = regexp.MustCompile(`\[Image:\s*([^\]]+)\]`)
 
 // ProcessInfo holds information about an active process
 type ProcessInfo struct {
-	Cmd       *exec.Cmd `json:"-"`
-	SessionID string    `json:"sessionId"`
-	WorkDir   string    `json:"workDir"`
-	StartTime int64     `json:"startTime"`
+	Interrupt   func() error `json:"-"` // stops the process and anything it spawned
+	SessionID   string       `json:"sessionId"`
+	WorkDir     string       `json:"workDir"`
+	StartTime   int64        `json:"startTime"`
+	Mode        string       `json:"mode"` // "chat" or "terminal"
+	Backend     string       `json:"backend,omitempty"`
+	ChatBackend string       `json:"chatBackend,omitempty"`
+	Owner       string       `json:"-"` // user ID when auth is enabled
+
+	output *processOutput // streamed lines and current activity; nil for terminals
+	pid    int            // local OS process for resource telemetry; 0 for remote, container, and API runs
 }
 
 // Process management for interruption
@@ -46,6 +53,9 @@ func getNextProcessID() int {
 }
 
 func registerProcess(id int, info *ProcessInfo) {
+	if info.Mode != "terminal" {
+		info.output = newProcessOutput()
+	}
 	processLock.Lock()
 	defer processLock.Unlock()
 	activeProcesses[id] = info
@@ -57,51 +67,129 @@ func unregisterProcess(id int) {
 	delete(activeProcesses, id)
 }
 
-func getProcess(id int) *exec.Cmd {
+// processKillGrace is how long an interrupted process group gets to exit before SIGKILL
+const processKillGrace = 3 * time.Second
+
+// startInProcessGroup makes cmd the leader of a new process group so killProcessGroup reaches its children
+func startInProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup sends SIGTERM to cmd's process group, then SIGKILL to whatever is left after processKillGrace
+// script forwards SIGTERM to the claude it runs on its pty, which is in a session of its own
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	pgid := cmd.Process.Pid
+	if err := syscall.Kill(-pgid, syscall.SIGTERM); err != nil {
+		if err == syscall.ESRCH {
+			return nil
+		}
+		return cmd.Process.Kill()
+	}
+	go func() {
+		time.Sleep(processKillGrace)
+		if syscall.Kill(-pgid, 0) == nil {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		}
+	}()
+	return nil
+}
+
+// StopProcesses interrupts every running chat, e.g. on server shutdown
+// Chats run in their own process groups, so they don't receive the terminal's SIGINT
+func StopProcesses() {
 	processLock.RLock()
 	defer processLock.RUnlock()
-	if info, ok := activeProcesses[id]; ok {
-		return info.Cmd
+	for _, info := range activeProcesses {
+		if info.Interrupt != nil {
+			info.Interrupt()
+		}
 	}
-	return nil
+	stopStreamSessions()
 }
 
 // ActiveProcessInfo is the public struct for API responses
 type ActiveProcessInfo struct {
-	ProcessID int    `json:"processId"`
-	SessionID string `json:"sessionId"`
-	WorkDir   string `json:"workDir"`
-	StartTime int64  `json:"startTime"`
+	ProcessID   int    `json:"processId"`
+	SessionID   string `json:"sessionId"`
+	WorkDir     string `json:"workDir"`
+	StartTime   int64  `json:"startTime"`
+	Mode        string `json:"mode"`
+	ChatBackend string `json:"chatBackend,omitempty"`
+	// Resources is the CPU and memory use of a local process and its children
+	Resources *ProcessResources `json:"resources,omitempty"`
 }
 
-// GetActiveProcesses returns info about all active processes
-func GetActiveProcesses() []ActiveProcessInfo {
+// GetActiveProcesses returns info about all active processes visible to user
+// A nil user (auth disabled) sees every process
+func GetActiveProcesses(user *User) []ActiveProcessInfo {
+	resources := sampleProcessResources()
 	processLock.RLock()
 	defer processLock.RUnlock()
 	result := make([]ActiveProcessInfo, 0, len(activeProcesses))
 	for id, info := range activeProcesses {
+		if !userCanAccessOwner(user, info.Owner) {
+			continue
+		}
 		result = append(result, ActiveProcessInfo{
-			ProcessID: id,
-			SessionID: info.SessionID,
-			WorkDir:   info.WorkDir,
-			StartTime: info.StartTime,
+			ProcessID:   id,
+			SessionID:   info.SessionID,
+			WorkDir:     info.WorkDir,
+			StartTime:   info.StartTime,
+			Mode:        info.Mode,
+			ChatBackend: info.ChatBackend,
 		})
+		if r, ok := resources[id]; ok {
+			result[len(result)-1].Resources = &r
+		}
 	}
 	return result
 }
 
+// ListProcesses handles GET /api/processes
+func ListProcesses(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"processes": GetActiveProcesses(currentUser(c)),
+	})
+}
+
 // ChatRequest represents the request body for chat endpoints
 type ChatRequest struct {
-	Prompt    string `json:"prompt"`
-	SessionID string `json:"sessionId"`
-	WorkDir   string `json:"workDir"`
-	Continue  bool   `json:"continue"`
-	PlanMode  bool   `json:"planMode"`
+	Prompt      string   `json:"prompt"`
+	SessionID   string   `json:"sessionId"`
+	WorkDir     string   `json:"workDir"`
+	Continue    bool     `json:"continue"`
+	PlanMode    bool     `json:"planMode"`
+	MCPServers  []string `json:"mcpServers,omitempty"`  // restrict the run to these MCP servers
+	Backend     string   `json:"backend,omitempty"`     // execution backend name; empty runs locally
+	ChatBackend string   `json:"chatBackend,omitempty"` // cli or api; empty uses claude.chatBackend
+	// Empty fields fall back to the project settings, then the server defaults
+	Model          string   `json:"model,omitempty"`
+	PermissionMode string   `json:"permissionMode,omitempty"`
+	AllowedTools   []string `json:"allowedTools,omitempty"`
+	// RunID is a client-chosen ID for following the run with GET /api/chat/poll
+	RunID string `json:"runId,omitempty"`
+	// StopPatterns interrupt the run when one matches its assistant text or a tool call ("Bash: rm -rf build")
+	StopPatterns []string `json:"stopPatterns,omitempty"`
+	// Approval holds calls of ApprovalTools (default claude.approvalTools) until approved with POST /api/approvals/:id
+	Approval      bool     `json:"approval,omitempty"`
+	ApprovalTools []string `json:"approvalTools,omitempty"`
+	// Snapshot records the working directory first, for POST /api/session/:id/rollback
+	Snapshot bool `json:"snapshot,omitempty"`
+	// AddDirs are directories claude may work in besides the working directory; the session keeps them for
+	// later runs, and an empty list clears them
+	AddDirs []string `json:"addDirs,omitempty"`
 }
 
 // SSEMessage represents a Server-Sent Event message
 type SSEMessage struct {
 	Type    string                 `json:"type"`
+	Code    ErrorCode              `json:"code,omitempty"` // error events only
 	Message string                 `json:"message,omitempty"`
 	Data    map[string]interface{} `json:"data,omitempty"`
 }
@@ -110,7 +198,7 @@ type SSEMessage struct {
 func Chat(c *gin.Context) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
 		return
 	}
 
@@ -121,7 +209,7 @@ func Chat(c *gin.Context) {
 func ChatInteractive(c *gin.Context) {
 	var req ChatRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
 		return
 	}
 
@@ -131,45 +219,49 @@ func ChatInteractive(c *gin.Context) {
 // InterruptChat handles interrupting an active chat process
 func InterruptChat(c *gin.Context) {
 	sessionID := c.Query("sessionId")
-	log.Printf("[InterruptChat] Called with sessionId=%s", sessionID)
+	logger := requestLogger(c)
+	logger.Info("Interrupt requested", "sessionId", sessionID)
 
 	if sessionID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "sessionId is required"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "sessionId is required")
 		return
 	}
 
 	var processID int
-	var cmd *exec.Cmd
+	var interrupt func() error
+	user := currentUser(c)
 
 	// Find by session ID
 	processLock.RLock()
 	for pid, info := range activeProcesses {
-		log.Printf("[InterruptChat] Checking process %d: sessionID=%s", pid, info.SessionID)
-		if info.SessionID == sessionID {
+		logger.Debug("Checking process", "processId", pid, "sessionId", info.SessionID)
+		if info.SessionID == sessionID && userCanAccessOwner(user, info.Owner) {
 			processID = pid
-			cmd = info.Cmd
+			interrupt = info.Interrupt
 			break
 		}
 	}
 	processLock.RUnlock()
 
-	if cmd == nil {
-		log.Printf("[InterruptChat] Process not found for session %s", sessionID)
-		c.JSON(http.StatusNotFound, gin.H{"error": "process not found"})
+	if interrupt == nil {
+		logger.Info("Interrupt: process not found", "sessionId", sessionID)
+		respondError(c, http.StatusNotFound, ErrNotFound, "process not found")
 		return
 	}
 
-	log.Printf("[InterruptChat] Found process %d, killing...", processID)
+	logger.Info("Interrupt: killing process", "processId", processID, "sessionId", sessionID)
 
-	// Kill the process
-	if cmd.Process != nil {
-		if err := cmd.Process.Kill(); err != nil {
-			log.Printf("[InterruptChat] Failed to kill process: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to kill process: %v", err)})
-			return
-		}
-		log.Printf("[InterruptChat] Process killed successfully")
+	// Kill the process group, which includes anything claude spawned
+	if err := interrupt(); err != nil {
+		logger.Error("Interrupt: failed to kill process", "processId", processID, "error", err)
+		respondError(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("failed to kill process: %v", err))
+		return
 	}
+	logger.Info("Interrupt: process killed", "processId", processID)
+
+	entry := newAuditEntry(c, "chat.interrupt")
+	entry.SessionID = sessionID
+	recordAudit(entry)
 
 	unregisterProcess(processID)
 
@@ -182,41 +274,81 @@ func InterruptChat(c *gin.Context) {
 
 // executeChatStream executes the claude CLI command and streams output via SSE
 func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
+	user := currentUser(c)
+	if req.SessionID != "" && !userCanAccessSession(user, req.SessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+
 	// Check if this session is already loading
 	if req.SessionID != "" && IsSessionLoading(req.SessionID) {
-		c.Header("Content-Type", "text/event-stream")
-		c.Header("Cache-Control", "no-cache")
-		sendSSEError(c, "This session is already processing a request")
+		respondError(c, http.StatusConflict, ErrSessionBusy, "This session is already processing a request")
 		return
 	}
 
-	// Set SSE headers
-	c.Header("Content-Type", "text/event-stream")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Connection", "keep-alive")
-	c.Header("Transfer-Encoding", "chunked")
-
 	// Determine working directory - priority: request > session metadata > home
 	workDir := req.WorkDir
 	if workDir == "" && req.SessionID != "" {
 		// Get workDir from Claude CLI session metadata
 		workDir = GetSessionWorkDir(req.SessionID)
 	}
+	if workDir == "" && user != nil && len(user.ProjectRoots) > 0 {
+		workDir = user.ProjectRoots[0]
+	}
 	if workDir == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			sendSSEError(c, fmt.Sprintf("Failed to get home directory: %v", err))
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to get home directory", err.Error())
 			return
 		}
 		workDir = homeDir
 	}
+	if !userCanAccessPath(user, workDir) {
+		respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", workDir))
+		return
+	}
 
 	// Validate working directory
-	if _, err := os.Stat(workDir); os.IsNotExist(err) {
-		sendSSEError(c, fmt.Sprintf("Working directory does not exist: %s", workDir))
+	if err := checkWorkDir(workDir); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if err := checkBackend(req.Backend, workDir); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	addDirs, err := chatAddDirs(user, req.SessionID, workDir, req.Backend, req.AddDirs)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	watch, err := newWatchdog(req.StopPatterns)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
 		return
 	}
 
+	if err := checkChatLimits(ownerID(user)); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if err := checkBudget(workDir); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if err := checkResources(workDir); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if req.RunID != "" {
+		if err := reserveChatRunID(req.RunID); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+		// A no-op once the run's stream has taken the ID over
+		defer releaseChatRunID(req.RunID)
+	}
+
 	// Extract image paths from prompt and prepare clean prompt
 	prompt := req.Prompt
 	var imagePaths []string
@@ -225,6 +357,9 @@ func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
 	for _, match := range matches {
 		if len(match) > 1 {
 			path := strings.TrimSpace(match[1])
+			if !userCanAttachFile(user, path) {
+				continue
+			}
 			// Verify file exists
 			if _, err := os.Stat(path); err == nil {
 				imagePaths = append(imagePaths, path)
@@ -238,76 +373,85 @@ func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
 
 	// If only images were sent, add a default prompt
 	if cleanPrompt == "" && len(imagePaths) > 0 {
-		cleanPrompt = "이 이미지를 분석해줘"
+		cleanPrompt = localize(c, defaultImagePrompt)
 	}
 
-	// Build claude command arguments
-	args := []string{
-		"-p",
-		"--output-format", "stream-json",
-		"--verbose",
-		"--dangerously-skip-permissions",
-	}
-
-	// Add session ID if provided
-	if req.SessionID != "" {
-		args = append(args, "--resume", req.SessionID)
-	}
-
-	// Add continue flag if requested or if no prompt provided
-	if withContinue || (cleanPrompt == "" && len(imagePaths) == 0) {
-		args = append(args, "--continue")
-	}
-
-	// Add image files if any
-	for _, imgPath := range imagePaths {
-		args = append(args, "--files", imgPath)
+	chatBackend, err := chatBackendFor(req.ChatBackend)
+	if err != nil {
+		respondCheckError(c, err)
+		return
 	}
 
-	// Add prompt only if not empty
-	if cleanPrompt != "" {
-		args = append(args, cleanPrompt)
+	logger := requestLogger(c).With("transport", "sse", "chatBackend", chatBackend.Name())
+	var gate *approvalGate
+	if req.Approval {
+		if gate, err = newApprovalGate(ownerID(user), workDir, req.Backend, chatBackend.Name(), req.ApprovalTools); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+		defer gate.close()
 	}
-
-	// Create command
-	cmd := exec.Command("claude", args...)
-	cmd.Dir = workDir
-
-	// Log the command for debugging
-	log.Printf("[CHAT] Executing: claude %s (workDir: %s)", strings.Join(args, " "), workDir)
-
-	// Set up environment
-	cmd.Env = os.Environ()
-
-	// Get stdout pipe
-	stdout, err := cmd.StdoutPipe()
+	snapshot, err := newRunSnapshot(req.Snapshot, workDir, req.Backend)
 	if err != nil {
-		sendSSEError(c, fmt.Sprintf("Failed to create stdout pipe: %v", err))
+		respondCheckError(c, err)
 		return
 	}
-
-	// Get stderr pipe
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		sendSSEError(c, fmt.Sprintf("Failed to create stderr pipe: %v", err))
+	defer snapshot.finish()
+	snapshot.attach(req.SessionID)
+	turn := ChatTurn{
+		WorkDir:        workDir,
+		SessionID:      req.SessionID,
+		Prompt:         cleanPrompt,
+		ImagePaths:     imagePaths,
+		Continue:       withContinue,
+		PlanMode:       req.PlanMode,
+		MCPServers:     req.MCPServers,
+		Backend:        req.Backend,
+		Logger:         logger,
+		Model:          req.Model,
+		PermissionMode: req.PermissionMode,
+		AllowedTools:   req.AllowedTools,
+		Approval:       gate,
+		AddDirs:        addDirs,
+	}
+	if err := inheritProjectSettings(&turn); err != nil {
+		respondCheckError(c, err)
 		return
 	}
-
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		sendSSEError(c, fmt.Sprintf("Failed to start claude command: %v", err))
+	proc, err := chatBackend.Start(turn)
+	if err != nil {
+		respondCheckError(c, err)
 		return
 	}
 
+	// Set SSE headers; errors up to here were plain JSON responses
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("Transfer-Encoding", "chunked")
+
 	// Register process for potential interruption
+	startTime := time.Now()
 	processID := getNextProcessID()
 	registerProcess(processID, &ProcessInfo{
-		Cmd:       cmd,
-		SessionID: req.SessionID,
-		WorkDir:   workDir,
-		StartTime: time.Now().Unix(),
+		Interrupt:   proc.Interrupt,
+		SessionID:   req.SessionID,
+		WorkDir:     workDir,
+		StartTime:   time.Now().Unix(),
+		Mode:        "chat",
+		Backend:     req.Backend,
+		ChatBackend: chatBackend.Name(),
+		Owner:       ownerID(user),
+		pid:         chatProcessPID(proc),
 	})
 
+	entry := newAuditEntry(c, "chat.execute")
+	entry.SessionID = req.SessionID
+	entry.WorkDir = workDir
+	entry.Details = map[string]string{"transport": "sse", "prompt": auditPrompt(req.Prompt)}
+	recordAudit(entry)
+	fireChatStarted(user, "sse", req.SessionID, workDir, req.Prompt)
+
 	// Track the session ID that will be assigned (for new sessions)
 	activeSessionID := req.SessionID
 
@@ -326,23 +470,45 @@ func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
 		}
 	}()
 
+	// Events go through a resumable stream, so a client that drops can reattach
+	// with GET /api/chat/stream/:processId, or poll GET /api/chat/poll, while the run keeps going
+	stream := newChatStream(processID, ownerID(user), req.RunID)
+	followDone := make(chan struct{})
+	go func() {
+		defer close(followDone)
+		stream.follow(c.Request.Context(), c.Writer, 0)
+	}()
+
 	// Send process ID to client
-	sendSSEMessage(c, SSEMessage{
+	stream.send(SSEMessage{
 		Type:    "processId",
 		Message: strconv.Itoa(processID),
 	})
+	if gate != nil {
+		gate.setNotify(func(event string, data map[string]interface{}) {
+			stream.send(SSEMessage{Type: event, Data: data})
+		})
+	}
+	if snapshot != nil {
+		stream.send(SSEMessage{
+			Type: "snapshot",
+			Data: map[string]interface{}{"snapshot": snapshot.snap},
+		})
+	}
 
 	// Create channels for handling output and errors
 	doneChan := make(chan error, 1)
-	flusher, ok := c.Writer.(http.Flusher)
-	if !ok {
-		sendSSEError(c, "Streaming not supported")
-		return
-	}
+	diagnostics := newDiagnosticRun("sse", processID, workDir, ownerID(user))
+	locale := requestLocale(c)
 
 	// Read stdout in a goroutine
+	inputNotified := false
+	var lastResult *resultEvent
+	runUsage := newUsageTracker()
+	stdoutDone := make(chan struct{})
 	go func() {
-		scanner := bufio.NewScanner(stdout)
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(proc.Stdout())
 		// Increase buffer size for large lines
 		buf := make([]byte, 0, 64*1024)
 		scanner.Buffer(buf, 1024*1024)
@@ -351,48 +517,105 @@ func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
 			line := scanner.Text()
 			if line != "" {
 				// Forward the line as SSE data
-				if _, err := fmt.Fprintf(c.Writer, "data: %s\n\n", line); err != nil {
-					return
+				stream.publish([]byte(line))
+				recordStreamBytes("sse", len(line))
+				if result := parseResultEvent(line); result != nil {
+					recordResultUsage(result)
+					recordProjectCost(ownerID(user), workDir, result)
+					lastResult = result
+					if d, ok := diagnostics.checkResult(result); ok {
+						stream.send(diagnosticMessage(d, locale))
+					}
+				}
+				trackInputRequest(ownerID(user), activeSessionID, line, &inputNotified)
+				trackTodos(activeSessionID, workDir, line)
+				trackContextUsage(activeSessionID, line)
+				if usage, ok := runUsage.track(line); ok {
+					stream.send(SSEMessage{
+						Type: "usage",
+						Data: map[string]interface{}{"usage": usage},
+					})
+				}
+				if trigger, ok := watch.check(line); ok {
+					logger.Info("Stop pattern matched, interrupting", "processId", processID, "pattern", trigger.Pattern, "match", trigger.Match)
+					stream.send(SSEMessage{
+						Type: "watchdogTriggered",
+						Data: map[string]interface{}{"watchdog": trigger},
+					})
+					if err := proc.Interrupt(); err != nil {
+						logger.Error("Failed to interrupt process", "processId", processID, "error", err)
+					}
+				}
+				recordProcessOutput(processID, line)
+
+				// Remember who created new sessions
+				if newSessionID := extractInitSessionID(line); newSessionID != "" {
+					recordSessionOwner(newSessionID, ownerID(user))
+					snapshot.attach(newSessionID)
+					keepChatAddDirs(newSessionID, req.AddDirs, addDirs, user)
+				}
+
+				// Surface which MCP servers the CLI actually loaded
+				if servers, ok := extractInitMCPServers(line); ok {
+					stream.send(SSEMessage{
+						Type: "mcpServers",
+						Data: map[string]interface{}{"servers": servers},
+					})
 				}
-				flusher.Flush()
 			}
 		}
 
 		if err := scanner.Err(); err != nil {
-			sendSSEMessage(c, SSEMessage{
+			stream.send(SSEMessage{
 				Type:    "error",
+				Code:    ErrInternal,
 				Message: fmt.Sprintf("Error reading stdout: %v", err),
 			})
-			flusher.Flush()
 		}
 	}()
 
 	// Read stderr in a goroutine
 	go func() {
-		scanner := bufio.NewScanner(stderr)
+		scanner := bufio.NewScanner(proc.Stderr())
 		buf := make([]byte, 0, 64*1024)
 		scanner.Buffer(buf, 1024*1024)
 
 		for scanner.Scan() {
 			line := scanner.Text()
-			if line != "" {
-				// Send stderr as error messages
-				sendSSEMessage(c, SSEMessage{
-					Type:    "stderr",
-					Message: line,
-				})
-				flusher.Flush()
+			if line == "" {
+				continue
+			}
+			// Known CLI failures go out once as a diagnostic; other lines as raw stderr
+			if d, recognized, first := diagnostics.check(line); recognized {
+				if first {
+					stream.send(diagnosticMessage(d, locale))
+				}
+				continue
 			}
+			stream.send(SSEMessage{
+				Type:    "stderr",
+				Message: line,
+			})
 		}
 	}()
 
 	// Wait for command to finish
 	go func() {
-		doneChan <- cmd.Wait()
+		// Wait closes the pipes, so let the reader drain stdout first
+		<-stdoutDone
+		doneChan <- proc.Wait()
 	}()
 
 	// Handle completion or error
 	err = <-doneChan
+	defer func() {
+		stream.finish()
+		<-followDone
+	}()
+	recordChatFinished("sse", startTime, chatOutcome(err))
+	notifyChatFinished(ownerID(user), activeSessionID, workDir, chatOutcome(err), time.Since(startTime))
+	fireChatFinished(user, "sse", activeSessionID, workDir, chatOutcome(err), time.Since(startTime), lastResult)
+	logger.Info("Claude process finished", "processId", processID, "sessionId", activeSessionID, "outcome", chatOutcome(err), "duration", time.Since(startTime).String())
 	if err != nil {
 		exitErr, ok := err.(*exec.ExitError)
 		if ok {
@@ -402,30 +625,30 @@ func executeChatStream(c *gin.Context, req ChatRequest, withContinue bool) {
 			// Exit code 130 means SIGINT (Ctrl+C)
 			if exitCode == 1 || exitCode == -1 || exitCode == 130 || exitCode == 137 {
 				// Treat as normal termination, not an error
-				sendSSEMessage(c, SSEMessage{
+				stream.send(SSEMessage{
 					Type: "done",
 				})
 			} else {
-				sendSSEMessage(c, SSEMessage{
+				stream.send(SSEMessage{
 					Type:    "error",
+					Code:    ErrCLIFailed,
 					Message: fmt.Sprintf("Command exited with error: %v (exit code: %d)", err, exitCode),
 				})
 			}
 		} else {
-			sendSSEMessage(c, SSEMessage{
+			stream.send(SSEMessage{
 				Type:    "error",
+				Code:    errorCodeFor(err, ErrCLIFailed),
 				Message: fmt.Sprintf("Command execution failed: %v", err),
 			})
 		}
-		flusher.Flush()
 		return
 	}
 
 	// Send completion message
-	sendSSEMessage(c, SSEMessage{
+	stream.send(SSEMessage{
 		Type: "done",
 	})
-	flusher.Flush()
 }
 
 // sendSSEMessage sends a structured SSE message
@@ -439,9 +662,10 @@ func sendSSEMessage(c *gin.Context, msg SSEMessage) {
 }
 
 // sendSSEError sends an error message and closes the stream
-func sendSSEError(c *gin.Context, message string) {
+func sendSSEError(c *gin.Context, code ErrorCode, message string) {
 	sendSSEMessage(c, SSEMessage{
 		Type:    "error",
+		Code:    code,
 		Message: message,
 	})
 	if flusher, ok := c.Writer.(http.Flusher); ok {
@@ -501,12 +725,7 @@ func ParseStreamJSON(line string) (map[string]interface{}, error) {
 
 // BuildClaudeCommand constructs the claude CLI command with appropriate flags
 func BuildClaudeCommand(prompt, sessionID, workDir string, withContinue bool) *exec.Cmd {
-	args := []string{
-		"-p",
-		"--output-format", "stream-json",
-		"--verbose",
-		"--dangerously-skip-permissions",
-	}
+	args := claudeBaseArgs(false)
 
 	if sessionID != "" {
 		args = append(args, "--resume", sessionID)
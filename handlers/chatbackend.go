@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ChatTurn is one prompt handed to a ChatBackend
+type ChatTurn struct {
+	WorkDir    string
+	SessionID  string // resume this session; empty starts a new one
+	Prompt     string // [Image: ...] markers already removed
+	ImagePaths []string
+	Continue   bool
+	PlanMode   bool
+	MCPServers []string
+	Backend    string // execution backend name (see backends.go); empty runs locally
+	PTY        bool   // run under a pseudo-terminal with stdin open, as WebSocket chats do
+	Logger     *slog.Logger
+
+	// Model, PermissionMode, and AllowedTools override the server defaults for the claude CLI
+	Model          string
+	PermissionMode string
+	AllowedTools   []string
+	Env            []string // KEY=value, added to local runs
+	AddDirs        []string // directories claude may work in besides WorkDir, local runs only
+	// Approval holds the run's gated tool calls for a decision; nil runs without approval mode
+	Approval *approvalGate
+}
+
+// ChatBackend runs chat turns and streams claude stream-json lines
+type ChatBackend interface {
+	Name() string
+	Start(turn ChatTurn) (ChatProcess, error)
+}
+
+// ChatProcess is a started chat turn
+// Wait returns once the turn is over; read Stdout to EOF first
+type ChatProcess interface {
+	Stdout() io.Reader
+	Stderr() io.Reader
+	Stdin() io.Writer // nil when the backend takes no input
+	Wait() error
+	Interrupt() error
+}
+
+// chatBackendNames are the built-in chat backends; agentCLIs add more
+var chatBackendNames = map[string]bool{"cli": true, "stream": true, "api": true}
+
+// chatBackendFor returns the named chat backend, or the configured default for ""
+func chatBackendFor(name string) (ChatBackend, error) {
+	if name == "" {
+		name = getServerConfig().Claude.ChatBackend
+	}
+	switch name {
+	case "", "cli":
+		return cliChatBackend{}, nil
+	case "stream":
+		return streamChatBackend{}, nil
+	case "api":
+		return apiChatBackend{}, nil
+	}
+	if a := findAgentCLI(getServerConfig().AgentCLIs, name); a != nil {
+		return agentChatBackend{agent: *a}, nil
+	}
+	return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("unknown chat backend: %s (see GET /api/chat-backends)", name))
+}
+
+// addDirArgs returns the claude arguments granting access to additional directories
+func addDirArgs(dirs []string) []string {
+	var args []string
+	for _, dir := range dirs {
+		args = append(args, "--add-dir", dir)
+	}
+	return args
+}
+
+// cliChatBackend runs the claude CLI, locally, over ssh, or on an execution backend
+type cliChatBackend struct{}
+
+func (cliChatBackend) Name() string { return "cli" }
+
+func (cliChatBackend) Start(turn ChatTurn) (ChatProcess, error) {
+	cfg := getServerConfig().Claude
+	mode, model := turn.PermissionMode, turn.Model
+	if mode == "" {
+		mode = cfg.PermissionMode
+	}
+	if turn.PlanMode {
+		mode = "plan"
+	}
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+	args := claudeArgs(mode, model)
+	if len(turn.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(turn.AllowedTools, ","))
+	}
+	args = append(args, addDirArgs(turn.AddDirs)...)
+
+	if turn.SessionID != "" {
+		args = append(args, "--resume", turn.SessionID)
+	}
+
+	// Continue if requested or if no prompt was provided
+	if turn.Continue || (turn.Prompt == "" && len(turn.ImagePaths) == 0) {
+		args = append(args, "--continue")
+	}
+
+	if reason := localOnlyReason(turn.WorkDir, turn.Backend); reason != "" && (len(turn.ImagePaths) > 0 || len(turn.MCPServers) > 0) {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s", reason))
+	}
+
+	imgArgs, prompt := imageArgs(turn.ImagePaths, turn.Prompt)
+	args = append(args, imgArgs...)
+
+	// Restrict MCP servers if a subset was selected
+	mcpArgs, cleanupMCP, err := prepareMCPConfig(turn.MCPServers, turn.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, mcpArgs...)
+
+	// Install the approval hook
+	if turn.Approval != nil {
+		approvalArgs, cleanupApproval, err := turn.Approval.settingsArgs()
+		if err != nil {
+			cleanupMCP()
+			return nil, err
+		}
+		args = append(args, approvalArgs...)
+		cleanupOptions := cleanupMCP
+		cleanupMCP = func() {
+			cleanupOptions()
+			cleanupApproval()
+		}
+	}
+
+	if prompt != "" {
+		args = append(args, prompt)
+	}
+
+	// Create command on the selected backend (over ssh for host:path working directories)
+	invocation, stopBackend, err := claudeCommand(turn.WorkDir, turn.Backend, args)
+	if err != nil {
+		cleanupMCP()
+		return nil, err
+	}
+	cleanup := func() {
+		stopBackend()
+		cleanupMCP()
+	}
+
+	cmd := invocation
+	if turn.PTY {
+		// script -q -c "command" /dev/null forces PTY mode without saving a typescript
+		cmd = exec.Command("script", "-q", "-c", shellJoin(invocation.Args), "/dev/null")
+		cmd.Dir = invocation.Dir
+	}
+	cmd.Env = os.Environ()
+	if runsLocally(turn.WorkDir, turn.Backend) {
+		cmd.Env = append(cmd.Env, turn.Env...)
+	}
+
+	if turn.Logger != nil {
+		turn.Logger.Info("Executing claude", "args", strings.Join(args, " "), "workDir", turn.WorkDir, "sessionId", turn.SessionID, "backend", turn.Backend, "pty", turn.PTY)
+	}
+
+	p := &cliProcess{cmd: cmd, cleanup: cleanup, remote: !runsLocally(turn.WorkDir, turn.Backend)}
+	fail := func(what string, err error) (ChatProcess, error) {
+		cleanup()
+		return nil, withCode(http.StatusInternalServerError, errorCodeFor(err, ErrInternal), fmt.Errorf("%s: %w", what, err))
+	}
+	if p.stdout, err = cmd.StdoutPipe(); err != nil {
+		return fail("failed to create stdout pipe", err)
+	}
+	if p.stderr, err = cmd.StderrPipe(); err != nil {
+		return fail("failed to create stderr pipe", err)
+	}
+	// claude -p waits for stdin to close, so only the PTY wrapper gets one
+	if turn.PTY {
+		if p.stdin, err = cmd.StdinPipe(); err != nil {
+			return fail("failed to create stdin pipe", err)
+		}
+	}
+
+	startInProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fail("failed to start claude command", err)
+	}
+	limitProcessResources(cmd)
+	return p, nil
+}
+
+// cliProcess is a running claude (or script wrapping it)
+type cliProcess struct {
+	cmd     *exec.Cmd
+	stdout  io.Reader
+	stderr  io.Reader
+	stdin   io.WriteCloser
+	cleanup func()
+	remote  bool // the local process is ssh or a container client, not claude
+}
+
+func (p *cliProcess) Stdout() io.Reader { return p.stdout }
+func (p *cliProcess) Stderr() io.Reader { return p.stderr }
+
+func (p *cliProcess) Stdin() io.Writer {
+	if p.stdin == nil {
+		return nil
+	}
+	return p.stdin
+}
+
+func (p *cliProcess) Wait() error {
+	defer p.cleanup()
+	return p.cmd.Wait()
+}
+
+func (p *cliProcess) Interrupt() error { return killProcessGroup(p.cmd) }
+
+// Pid is the local process for resource telemetry; 0 when claude runs on another host or in a container
+func (p *cliProcess) Pid() int {
+	if p.remote || p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
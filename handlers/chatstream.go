@@ -0,0 +1,344 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// chatReplayEvents and chatReplayBytes bound the events a chat stream keeps for resuming
+	chatReplayEvents = 2000
+	chatReplayBytes  = 8 * 1024 * 1024
+
+	// chatStreamLinger is how long a finished chat stream stays resumable
+	chatStreamLinger = 2 * time.Minute
+
+	// chatPollWait and chatPollMaxWait bound how long GET /api/chat/poll holds a request open
+	chatPollWait    = 25 * time.Second
+	chatPollMaxWait = 55 * time.Second
+	// chatPollStarting is how long a poll for a run that hasn't started yet waits before answering
+	chatPollStarting = time.Second
+)
+
+// runIDPattern is the form of client-chosen run IDs
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9_\-]{8,64}$`)
+
+// chatStreamEvent is one SSE data payload of a chat run
+type chatStreamEvent struct {
+	id   int64
+	data []byte
+}
+
+// chatStream buffers a chat run's SSE events so a client that lost its connection
+// can pick up where it left off with GET /api/chat/stream/:processId and Last-Event-ID
+// The run publishes whether or not anyone is following
+type chatStream struct {
+	processID int
+	owner     string
+	runID     string // client-chosen ID for GET /api/chat/poll; may be empty
+
+	mu      sync.Mutex
+	events  []chatStreamEvent
+	size    int
+	nextID  int64
+	done    bool
+	changed chan struct{} // closed and replaced whenever an event is published
+}
+
+var (
+	chatStreamsMu sync.Mutex
+	chatStreams   = make(map[int]*chatStream) // process ID -> stream
+	// chatRuns maps client-chosen run IDs to their streams; nil while the run is starting
+	chatRuns = make(map[string]*chatStream)
+)
+
+// newChatStream registers the stream of a chat run
+func newChatStream(processID int, owner string, runID string) *chatStream {
+	s := &chatStream{processID: processID, owner: owner, runID: runID, nextID: 1, changed: make(chan struct{})}
+	chatStreamsMu.Lock()
+	chatStreams[processID] = s
+	if runID != "" {
+		chatRuns[runID] = s
+	}
+	chatStreamsMu.Unlock()
+	return s
+}
+
+// reserveChatRunID claims a client-chosen run ID before the run starts, so polls
+// that arrive first wait instead of failing
+func reserveChatRunID(runID string) error {
+	if !runIDPattern.MatchString(runID) {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("runId must be 8-64 letters, digits, - or _"))
+	}
+	chatStreamsMu.Lock()
+	defer chatStreamsMu.Unlock()
+	if _, ok := chatRuns[runID]; ok {
+		return withCode(http.StatusConflict, ErrConflict, fmt.Errorf("runId %s is already in use", runID))
+	}
+	chatRuns[runID] = nil
+	return nil
+}
+
+// releaseChatRunID drops a reservation whose run never started
+func releaseChatRunID(runID string) {
+	chatStreamsMu.Lock()
+	if s, ok := chatRuns[runID]; ok && s == nil {
+		delete(chatRuns, runID)
+	}
+	chatStreamsMu.Unlock()
+}
+
+// publish appends an event, dropping the oldest once the buffer is full
+func (s *chatStream) publish(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done {
+		return
+	}
+	s.events = append(s.events, chatStreamEvent{id: s.nextID, data: data})
+	s.nextID++
+	s.size += len(data)
+	for len(s.events) > 1 && (len(s.events) > chatReplayEvents || s.size > chatReplayBytes) {
+		s.size -= len(s.events[0].data)
+		s.events = s.events[1:]
+	}
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// send publishes a structured message
+func (s *chatStream) send(msg SSEMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		data = []byte(`{"type":"error","message":"Failed to encode message"}`)
+	}
+	s.publish(data)
+}
+
+// finish marks the run over; followers return after writing the rest,
+// and the stream stays resumable for chatStreamLinger
+func (s *chatStream) finish() {
+	s.mu.Lock()
+	s.done = true
+	close(s.changed)
+	s.changed = make(chan struct{})
+	s.mu.Unlock()
+
+	time.AfterFunc(chatStreamLinger, func() {
+		chatStreamsMu.Lock()
+		if chatStreams[s.processID] == s {
+			delete(chatStreams, s.processID)
+		}
+		if s.runID != "" && chatRuns[s.runID] == s {
+			delete(chatRuns, s.runID)
+		}
+		chatStreamsMu.Unlock()
+	})
+}
+
+// follow writes the events after lastID to w as they are published, until the run
+// finishes or ctx is done
+func (s *chatStream) follow(ctx context.Context, w gin.ResponseWriter, lastID int64) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return
+	}
+	for {
+		s.mu.Lock()
+		var pending []chatStreamEvent
+		if len(s.events) > 0 && s.events[0].id > lastID+1 && lastID > 0 {
+			// Some events were dropped from the buffer before this client came back
+			gap, _ := json.Marshal(SSEMessage{Type: "gap", Message: fmt.Sprintf("%d events were lost", s.events[0].id-lastID-1)})
+			pending = append(pending, chatStreamEvent{data: gap})
+		}
+		for _, event := range s.events {
+			if event.id > lastID {
+				pending = append(pending, event)
+			}
+		}
+		done, changed := s.done, s.changed
+		s.mu.Unlock()
+
+		for _, event := range pending {
+			if event.id > 0 {
+				if _, err := fmt.Fprintf(w, "id: %d\n", event.id); err != nil {
+					return
+				}
+				lastID = event.id
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", event.data); err != nil {
+				return
+			}
+		}
+		if len(pending) > 0 {
+			flusher.Flush()
+		}
+		if done {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+		}
+	}
+}
+
+// lastEventID reads the Last-Event-ID header, or the lastEventId query parameter
+// for clients that can't set headers; 0 when absent
+func lastEventID(c *gin.Context) int64 {
+	value := c.GetHeader("Last-Event-ID")
+	if value == "" {
+		value = c.Query("lastEventId")
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// ResumeChatStream handles GET /api/chat/stream/:processId
+// Replays a chat run's events after Last-Event-ID, then follows it until it finishes
+// Runs stay resumable for a couple of minutes after they finish
+// Query parameters:
+//   - lastEventId: the Last-Event-ID header, for clients that can't set it
+func ResumeChatStream(c *gin.Context) {
+	processID, err := strconv.Atoi(c.Param("processId"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid process ID")
+		return
+	}
+	chatStreamsMu.Lock()
+	stream := chatStreams[processID]
+	chatStreamsMu.Unlock()
+	if stream == nil || !userCanAccessOwner(currentUser(c), stream.owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Chat stream not found or expired")
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	stream.follow(c.Request.Context(), c.Writer, lastEventID(c))
+}
+
+// ChatPollEvent is one event of a chat run, as the SSE stream would send it
+type ChatPollEvent struct {
+	ID   int64           `json:"id,omitempty"` // absent for gap notices
+	Data json.RawMessage `json:"data"`
+}
+
+// ChatPollResponse is the response of GET /api/chat/poll
+type ChatPollResponse struct {
+	RunID     string          `json:"runId"`
+	ProcessID int             `json:"processId,omitempty"` // 0 while the run is starting
+	Events    []ChatPollEvent `json:"events"`
+	Cursor    int64           `json:"cursor"` // pass as cursor on the next poll
+	Done      bool            `json:"done"`   // the run is over and every event was returned
+}
+
+// poll returns the events after cursor, waiting up to wait for the first one
+func (s *chatStream) poll(ctx context.Context, cursor int64, wait time.Duration) (events []ChatPollEvent, next int64, done bool) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	next = cursor
+	for {
+		s.mu.Lock()
+		if len(s.events) > 0 && s.events[0].id > cursor+1 && cursor > 0 {
+			// Some events were dropped from the buffer before this client came back
+			gap, _ := json.Marshal(SSEMessage{Type: "gap", Message: fmt.Sprintf("%d events were lost", s.events[0].id-cursor-1)})
+			events = append(events, ChatPollEvent{Data: gap})
+		}
+		for _, event := range s.events {
+			if event.id > cursor {
+				events = append(events, ChatPollEvent{ID: event.id, Data: event.data})
+				next = event.id
+			}
+		}
+		done, changed := s.done, s.changed
+		s.mu.Unlock()
+
+		if len(events) > 0 || done {
+			return events, next, done
+		}
+		select {
+		case <-ctx.Done():
+			return events, next, false
+		case <-timer.C:
+			return events, next, false
+		case <-changed:
+		}
+	}
+}
+
+// PollChat handles GET /api/chat/poll
+// Long-polling transport for networks that cut SSE and WebSocket connections: returns a chat
+// run's events after cursor as soon as there are any, or an empty list after the wait.
+// Start the run with POST /api/chat and a runId of your choosing; the run keeps going whether
+// or not that request's stream survives
+// Query parameters:
+//   - runId: the runId of the chat request, or its process ID
+//   - cursor: the last event ID received (default 0, from the start)
+//   - wait: seconds to wait for new events (default 25, max 55, 0 to return at once)
+func PollChat(c *gin.Context) {
+	runID := c.Query("runId")
+	cursor, err := strconv.ParseInt(c.DefaultQuery("cursor", "0"), 10, 64)
+	if err != nil || cursor < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid cursor")
+		return
+	}
+	wait := chatPollWait
+	if value := c.Query("wait"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid wait")
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+		if wait > chatPollMaxWait {
+			wait = chatPollMaxWait
+		}
+	}
+
+	chatStreamsMu.Lock()
+	stream, reserved := chatRuns[runID]
+	if !reserved {
+		if processID, err := strconv.Atoi(runID); err == nil {
+			stream = chatStreams[processID]
+		}
+	}
+	chatStreamsMu.Unlock()
+
+	response := ChatPollResponse{RunID: runID, Events: []ChatPollEvent{}, Cursor: cursor}
+	if stream == nil && reserved {
+		// The chat request is still starting claude
+		time.Sleep(chatPollStarting)
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if stream == nil || !userCanAccessOwner(currentUser(c), stream.owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Chat run not found or expired")
+		return
+	}
+
+	// Like the SSE streams, a long poll outlasts the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+	events, next, done := stream.poll(c.Request.Context(), cursor, wait)
+	response.ProcessID = stream.processID
+	response.Events = append(response.Events, events...)
+	response.Cursor = next
+	response.Done = done
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
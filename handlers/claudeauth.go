@@ -0,0 +1,538 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// claudeProbeTimeout bounds the one-turn prompt that checks credentials against the API
+	claudeProbeTimeout = 60 * time.Second
+	// claudeProbeReuse is how long a probe result answers repeated ?probe=true requests
+	claudeProbeReuse = time.Minute
+	// claudeLoginTimeout kills a login the admin never finished
+	claudeLoginTimeout = 10 * time.Minute
+	// claudeLoginOutputKeep bounds the login output kept for display
+	claudeLoginOutputKeep = 4096
+)
+
+var (
+	// terminalEscape matches the CSI and OSC sequences the CLI's TUI writes
+	terminalEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[()][A-Z0-9]|\x1b[=>]`)
+	// loginURLPattern finds the authorization URL in the login output
+	loginURLPattern = regexp.MustCompile(`https://[^\s"'<>]+`)
+	// oauthTokenPattern finds the long-lived token claude setup-token prints
+	oauthTokenPattern = regexp.MustCompile(`sk-ant-oat[0-9]*-[A-Za-z0-9_\-]+`)
+)
+
+// ClaudeAuthProbe is the outcome of running a one-turn prompt with the CLI's credentials
+type ClaudeAuthProbe struct {
+	OK         bool        `json:"ok"`
+	Diagnostic *Diagnostic `json:"diagnostic,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	DurationMS int64       `json:"durationMs"`
+	CheckedAt  time.Time   `json:"checkedAt"`
+}
+
+// ClaudeAuthStatus is the response of GET /api/auth/claude-status
+type ClaudeAuthStatus struct {
+	CLIFound      bool `json:"cliFound"`
+	Authenticated bool `json:"authenticated"`
+	// api-key-env, oauth-token-env, server-token, oauth, api-key, or api-key-helper
+	Method       string           `json:"method,omitempty"`
+	Account      string           `json:"account,omitempty"`      // admins only
+	Organization string           `json:"organization,omitempty"` // admins only
+	Subscription string           `json:"subscription,omitempty"`
+	ExpiresAt    *time.Time       `json:"expiresAt,omitempty"`
+	Message      string           `json:"message"`
+	Remediation  string           `json:"remediation,omitempty"`
+	Probe        *ClaudeAuthProbe `json:"probe,omitempty"`
+	Login        *ClaudeLogin     `json:"login,omitempty"` // the assisted login, admins only
+}
+
+// ClaudeLogin is an assisted login run by POST /api/auth/claude-login
+type ClaudeLogin struct {
+	// starting, waiting_for_code, verifying, succeeded, failed, or canceled
+	State      string     `json:"state"`
+	URL        string     `json:"url,omitempty"` // open it, sign in, and paste the code it shows
+	Output     string     `json:"output"`        // the CLI's output without escapes, tokens masked
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	StartedBy  string     `json:"startedBy,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// ClaudeLoginCodeRequest is the body of POST /api/auth/claude-login/code
+type ClaudeLoginCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// claudeToken is the token a login stored, kept in claude-token.json
+type claudeToken struct {
+	Token     string    `json:"token"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy,omitempty"`
+}
+
+// claudeLoginRun is the claude setup-token process behind a ClaudeLogin
+type claudeLoginRun struct {
+	mu      sync.Mutex
+	login   ClaudeLogin
+	raw     strings.Builder // output with escapes stripped, unmasked
+	token   string
+	ptmx    *os.File
+	cmd     *exec.Cmd
+	changed chan struct{} // closed and replaced whenever the login changes
+}
+
+var (
+	claudeAuthMu     sync.Mutex
+	claudeLastProbe  *ClaudeAuthProbe
+	claudeLoginState *claudeLoginRun
+	// claudeTokenFromServer is set once a stored token was put in the environment
+	claudeTokenFromServer bool
+)
+
+func claudeTokenPath() string {
+	return serverDataPath("claude-token.json")
+}
+
+// LoadClaudeToken exports a token stored by an earlier assisted login as CLAUDE_CODE_OAUTH_TOKEN,
+// so every claude the server starts uses it; a token already in the environment wins
+func LoadClaudeToken() {
+	if os.Getenv("CLAUDE_CODE_OAUTH_TOKEN") != "" {
+		return
+	}
+	var stored claudeToken
+	if err := loadJSONFile(claudeTokenPath(), &stored); err != nil {
+		log.Printf("[ClaudeAuth] Failed to load stored token: %v", err)
+		return
+	}
+	if stored.Token == "" {
+		return
+	}
+	os.Setenv("CLAUDE_CODE_OAUTH_TOKEN", stored.Token)
+	claudeAuthMu.Lock()
+	claudeTokenFromServer = true
+	claudeAuthMu.Unlock()
+	log.Printf("[ClaudeAuth] Using the claude token stored on %s", stored.CreatedAt.Format("2006-01-02"))
+}
+
+// checkClaudeCredentials looks for the credentials the CLI would use, without running it
+// Keychain-stored credentials (macOS) can't be seen this way; the probe covers them
+func checkClaudeCredentials(status *ClaudeAuthStatus) {
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		status.Authenticated, status.Method = true, "api-key-env"
+		return
+	}
+	if os.Getenv("CLAUDE_CODE_OAUTH_TOKEN") != "" {
+		status.Authenticated, status.Method = true, "oauth-token-env"
+		claudeAuthMu.Lock()
+		if claudeTokenFromServer {
+			status.Method = "server-token"
+		}
+		claudeAuthMu.Unlock()
+		return
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	// Account details live in ~/.claude.json whichever way the CLI logged in
+	var config struct {
+		PrimaryAPIKey string `json:"primaryApiKey"`
+		OAuthAccount  struct {
+			EmailAddress     string `json:"emailAddress"`
+			OrganizationName string `json:"organizationName"`
+		} `json:"oauthAccount"`
+	}
+	if err := loadJSONFile(filepath.Join(homeDir, ".claude.json"), &config); err == nil {
+		status.Account = config.OAuthAccount.EmailAddress
+		status.Organization = config.OAuthAccount.OrganizationName
+	}
+
+	var credentials struct {
+		ClaudeAiOauth *struct {
+			AccessToken      string `json:"accessToken"`
+			RefreshToken     string `json:"refreshToken"`
+			ExpiresAt        int64  `json:"expiresAt"` // unix milliseconds
+			SubscriptionType string `json:"subscriptionType"`
+		} `json:"claudeAiOauth"`
+	}
+	if err := loadJSONFile(filepath.Join(homeDir, ".claude", ".credentials.json"), &credentials); err == nil && credentials.ClaudeAiOauth != nil && credentials.ClaudeAiOauth.AccessToken != "" {
+		oauth := credentials.ClaudeAiOauth
+		status.Method = "oauth"
+		status.Subscription = oauth.SubscriptionType
+		status.Authenticated = true
+		if oauth.ExpiresAt > 0 {
+			expires := time.UnixMilli(oauth.ExpiresAt)
+			status.ExpiresAt = &expires
+			// The CLI refreshes expired access tokens itself when it has a refresh token
+			status.Authenticated = oauth.RefreshToken != "" || time.Now().Before(expires)
+		}
+		return
+	}
+	if config.PrimaryAPIKey != "" {
+		status.Authenticated, status.Method = true, "api-key"
+		return
+	}
+
+	var settings struct {
+		APIKeyHelper string `json:"apiKeyHelper"`
+	}
+	if err := loadJSONFile(filepath.Join(homeDir, ".claude", "settings.json"), &settings); err == nil && settings.APIKeyHelper != "" {
+		status.Authenticated, status.Method = true, "api-key-helper"
+	}
+}
+
+// probeClaudeAuth runs a one-turn prompt and classifies how it failed, if it did
+func probeClaudeAuth() *ClaudeAuthProbe {
+	start := time.Now()
+	probe := &ClaudeAuthProbe{CheckedAt: start}
+	ctx, cancel := context.WithTimeout(context.Background(), claudeProbeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "claude", "-p", "Reply with OK", "--output-format", "json", "--max-turns", "1")
+	cmd.Dir = os.TempDir()
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	probe.DurationMS = time.Since(start).Milliseconds()
+
+	result := parseResultEvent(strings.TrimSpace(string(out)))
+	if err == nil && result != nil && !result.IsError {
+		probe.OK = true
+		return probe
+	}
+
+	candidates := strings.Split(stderr.String(), "\n")
+	if result != nil {
+		candidates = append([]string{result.Result}, candidates...)
+	}
+	for _, line := range candidates {
+		if d, ok := classifyDiagnostic(line); ok {
+			probe.Diagnostic = &d
+			break
+		}
+	}
+	switch {
+	case ctx.Err() != nil:
+		probe.Error = "claude did not answer within " + claudeProbeTimeout.String()
+	case result != nil && result.Result != "":
+		probe.Error = clipText(result.Result, activityDetailLimit)
+	case err != nil:
+		probe.Error = err.Error()
+		if tail := strings.TrimSpace(stderr.String()); tail != "" {
+			probe.Error += ": " + clipText(tail, activityDetailLimit)
+		}
+	default:
+		probe.Error = "claude returned no result"
+	}
+	return probe
+}
+
+// GetClaudeAuthStatus handles GET /api/auth/claude-status
+// Reports whether the claude CLI the server runs has credentials, from its config files,
+// and for admins the assisted login in progress
+// Query parameters:
+//   - probe: "true" to also run a one-turn prompt against the API (admins only; reused for a minute)
+func GetClaudeAuthStatus(c *gin.Context) {
+	user := currentUser(c)
+	admin := user == nil || user.IsAdmin()
+	probe := c.Query("probe") == "true"
+	if probe && !requireAdmin(c) {
+		return
+	}
+
+	status := ClaudeAuthStatus{}
+	if _, err := exec.LookPath("claude"); err == nil {
+		status.CLIFound = true
+		checkClaudeCredentials(&status)
+	}
+
+	if status.CLIFound && probe {
+		claudeAuthMu.Lock()
+		last := claudeLastProbe
+		claudeAuthMu.Unlock()
+		if last == nil || time.Since(last.CheckedAt) > claudeProbeReuse {
+			last = probeClaudeAuth()
+			claudeAuthMu.Lock()
+			claudeLastProbe = last
+			claudeAuthMu.Unlock()
+		}
+		status.Probe = last
+		status.Authenticated = last.OK
+	}
+
+	switch {
+	case !status.CLIFound:
+		status.Message = "claude CLI not found on PATH"
+		status.Remediation = "Install it with `npm install -g @anthropic-ai/claude-code` for the user the server runs as."
+	case status.Probe != nil && !status.Probe.OK:
+		status.Message = "claude could not reach the API: " + status.Probe.Error
+		if d := status.Probe.Diagnostic; d != nil {
+			status.Message, status.Remediation = d.Title, d.Remediation
+		}
+	case status.Authenticated:
+		status.Message = "claude is authenticated"
+	case status.Method == "oauth":
+		status.Message = "claude's login has expired"
+		status.Remediation = "Sign in again with POST /api/auth/claude-login, or run `claude` on the server and use /login."
+	default:
+		status.Message = "claude is not logged in"
+		status.Remediation = "Sign in with POST /api/auth/claude-login, run `claude` on the server and use /login, or set ANTHROPIC_API_KEY."
+	}
+
+	status.Message, status.Remediation = localize(c, status.Message), localize(c, status.Remediation)
+	if status.Probe != nil && status.Probe.Diagnostic != nil {
+		probe := *status.Probe
+		d := probe.Diagnostic.localized(requestLocale(c))
+		probe.Diagnostic = &d
+		status.Probe = &probe
+	}
+
+	if admin {
+		claudeAuthMu.Lock()
+		if run := claudeLoginState; run != nil {
+			login := run.snapshot()
+			status.Login = &login
+		}
+		claudeAuthMu.Unlock()
+	} else {
+		status.Account, status.Organization = "", ""
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// snapshot copies the login for a response
+func (r *claudeLoginRun) snapshot() ClaudeLogin {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.login
+}
+
+// update changes the login under its lock and wakes waiters
+func (r *claudeLoginRun) update(fn func(l *ClaudeLogin)) {
+	r.mu.Lock()
+	fn(&r.login)
+	close(r.changed)
+	r.changed = make(chan struct{})
+	r.mu.Unlock()
+}
+
+// wait blocks until done reports true for the login, or timeout passes
+func (r *claudeLoginRun) wait(timeout time.Duration, done func(l ClaudeLogin) bool) ClaudeLogin {
+	deadline := time.After(timeout)
+	for {
+		r.mu.Lock()
+		login, changed := r.login, r.changed
+		r.mu.Unlock()
+		if done(login) {
+			return login
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			return login
+		}
+	}
+}
+
+// finished reports whether a login state is final
+func (l ClaudeLogin) finished() bool {
+	return l.State == "succeeded" || l.State == "failed" || l.State == "canceled"
+}
+
+// readOutput collects the login output until the CLI exits
+func (r *claudeLoginRun) readOutput() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.ptmx.Read(buf)
+		if n > 0 {
+			r.mu.Lock()
+			r.raw.WriteString(terminalEscape.ReplaceAllString(string(buf[:n]), ""))
+			if r.raw.Len() > 16*claudeLoginOutputKeep {
+				// Spinners redraw endlessly; the URL and token were picked up already
+				tail := clipTail(r.raw.String(), 4*claudeLoginOutputKeep)
+				r.raw.Reset()
+				r.raw.WriteString(tail)
+			}
+			text := strings.ReplaceAll(r.raw.String(), "\r", "")
+			if token := oauthTokenPattern.FindString(text); token != "" {
+				r.token = token
+			}
+			r.login.Output = clipTail(oauthTokenPattern.ReplaceAllString(text, "[REDACTED:oauth-token]"), claudeLoginOutputKeep)
+			if r.login.URL == "" {
+				if url := loginURLPattern.FindString(text); url != "" {
+					r.login.URL = url
+					if r.login.State == "starting" {
+						r.login.State = "waiting_for_code"
+					}
+				}
+			}
+			close(r.changed)
+			r.changed = make(chan struct{})
+			r.mu.Unlock()
+		}
+		if err != nil {
+			// EIO once the CLI exits and the pty closes
+			return
+		}
+	}
+}
+
+// clipTail keeps the last limit bytes of s without splitting a character
+func clipTail(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	start := len(s) - limit
+	for start < len(s) && (s[start]&0xC0) == 0x80 {
+		start++
+	}
+	return "…" + s[start:]
+}
+
+// runLogin waits for claude setup-token to exit and stores the token it printed
+func (r *claudeLoginRun) runLogin() {
+	timer := time.AfterFunc(claudeLoginTimeout, func() {
+		log.Printf("[ClaudeAuth] Login not finished within %s, stopping it", claudeLoginTimeout)
+		killProcessGroup(r.cmd)
+	})
+	r.readOutput()
+	err := r.cmd.Wait()
+	timer.Stop()
+	r.ptmx.Close()
+
+	r.mu.Lock()
+	token, startedBy, canceled := r.token, r.login.StartedBy, r.login.State == "canceled"
+	r.mu.Unlock()
+	if canceled {
+		return
+	}
+	if err == nil && token != "" {
+		err = writeJSONFileAtomicMode(claudeTokenPath(), claudeToken{Token: token, CreatedAt: time.Now(), CreatedBy: startedBy}, 0600)
+		if err == nil {
+			os.Setenv("CLAUDE_CODE_OAUTH_TOKEN", token)
+			claudeAuthMu.Lock()
+			claudeTokenFromServer = true
+			claudeLastProbe = nil
+			claudeAuthMu.Unlock()
+		}
+	}
+
+	now := time.Now()
+	r.update(func(l *ClaudeLogin) {
+		l.FinishedAt = &now
+		if err != nil {
+			l.State, l.Error = "failed", err.Error()
+		} else {
+			l.State = "succeeded"
+		}
+	})
+	if err != nil {
+		log.Printf("[ClaudeAuth] Login failed: %v", err)
+	} else {
+		log.Printf("[ClaudeAuth] Login by %s succeeded", startedBy)
+	}
+}
+
+// StartClaudeLogin handles POST /api/auth/claude-login
+// Runs claude setup-token and returns the URL to sign in at; the code the page shows
+// goes to POST /api/auth/claude-login/code. The token it creates is stored on the server
+// and used by every claude the server starts. A login in progress is returned as is
+func StartClaudeLogin(c *gin.Context) {
+	if _, err := exec.LookPath("claude"); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrCLINotFound, "claude CLI not found on PATH")
+		return
+	}
+
+	claudeAuthMu.Lock()
+	run := claudeLoginState
+	if run == nil || run.snapshot().finished() {
+		cmd := exec.Command("claude", "setup-token")
+		cmd.Dir = os.TempDir()
+		// Wide enough that the URL and token are never wrapped
+		ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: 1000, Rows: 50})
+		if err != nil {
+			claudeAuthMu.Unlock()
+			respondErrorDetails(c, http.StatusInternalServerError, ErrCLIFailed, "Failed to start claude setup-token", err.Error())
+			return
+		}
+		run = &claudeLoginRun{
+			login:   ClaudeLogin{State: "starting", StartedAt: time.Now(), StartedBy: auditUser(currentUser(c))},
+			ptmx:    ptmx,
+			cmd:     cmd,
+			changed: make(chan struct{}),
+		}
+		claudeLoginState = run
+		go run.runLogin()
+		log.Printf("[ClaudeAuth] Login started by %s", run.login.StartedBy)
+	}
+	claudeAuthMu.Unlock()
+
+	login := run.wait(15*time.Second, func(l ClaudeLogin) bool { return l.State != "starting" })
+	c.JSON(http.StatusOK, login)
+}
+
+// SubmitClaudeLoginCode handles POST /api/auth/claude-login/code
+// Types the code from the sign-in page into the waiting login and returns once it finishes
+func SubmitClaudeLoginCode(c *gin.Context) {
+	var req ClaudeLoginCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	code := strings.TrimSpace(req.Code)
+	if code == "" || strings.ContainsAny(code, "\r\n\x1b") {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid code")
+		return
+	}
+
+	claudeAuthMu.Lock()
+	run := claudeLoginState
+	claudeAuthMu.Unlock()
+	if run == nil || run.snapshot().State != "waiting_for_code" {
+		respondError(c, http.StatusConflict, ErrConflict, "No login is waiting for a code")
+		return
+	}
+	run.update(func(l *ClaudeLogin) { l.State = "verifying" })
+	if _, err := run.ptmx.Write([]byte(code + "\r")); err != nil && !errors.Is(err, os.ErrClosed) {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrCLIFailed, "Failed to send the code", err.Error())
+		return
+	}
+
+	login := run.wait(30*time.Second, ClaudeLogin.finished)
+	c.JSON(http.StatusOK, login)
+}
+
+// CancelClaudeLogin handles DELETE /api/auth/claude-login
+func CancelClaudeLogin(c *gin.Context) {
+	claudeAuthMu.Lock()
+	run := claudeLoginState
+	claudeAuthMu.Unlock()
+	if run == nil || run.snapshot().finished() {
+		respondError(c, http.StatusNotFound, ErrNotFound, "No login in progress")
+		return
+	}
+	now := time.Now()
+	run.update(func(l *ClaudeLogin) {
+		l.State, l.FinishedAt = "canceled", &now
+	})
+	killProcessGroup(run.cmd)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CommandDigests identify the content of the commands GET /api/commands lists, by scope
+// A commandsUpdated event carries the new digest of one scope; clients refetch only when it differs
+type CommandDigests struct {
+	User    string `json:"user"`    // ~/.claude/commands and plugin commands
+	Project string `json:"project"` // <workDir>/.claude/commands
+}
+
+var (
+	commandDigestsMu sync.Mutex
+	// commandDigests are the last digests sent or served, keyed by "user" or "project|<workDir>"
+	commandDigests = make(map[string]string)
+)
+
+// pluginCommandDirs maps each installed plugin to its commands directory
+func pluginCommandDirs() map[string]string {
+	dirs := make(map[string]string)
+	data, err := os.ReadFile(installedPluginsPath())
+	if err != nil {
+		return dirs
+	}
+	var pluginsData InstalledPluginsFile
+	if err := json.Unmarshal(data, &pluginsData); err != nil {
+		return dirs
+	}
+	for pluginName, entries := range pluginsData.Plugins {
+		if len(entries) > 0 {
+			dirs[pluginName] = filepath.Join(entries[0].InstallPath, "commands")
+		}
+	}
+	return dirs
+}
+
+// hashCommandDir writes the names and contents of the command files in dir to h, in order
+func hashCommandDir(h hash.Hash, label string, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries { // ReadDir sorts by name
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			path = filepath.Join(path, "skill.md")
+		} else if !strings.HasSuffix(entry.Name(), ".md") || entry.Name() == "skill.md" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		io.WriteString(h, label+"/"+entry.Name()+"\x00")
+		io.Copy(h, file)
+		h.Write([]byte{0})
+		file.Close()
+	}
+}
+
+// userCommandsDigest hashes the global and plugin commands
+func userCommandsDigest() string {
+	h := sha256.New()
+	hashCommandDir(h, "global", filepath.Join(getClaudeDir(), "commands"))
+	plugins := pluginCommandDirs()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hashCommandDir(h, name, plugins[name])
+	}
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// projectCommandsDigest hashes the commands of a project
+func projectCommandsDigest(workDir string) string {
+	h := sha256.New()
+	hashCommandDir(h, "project", filepath.Join(workDir, ".claude", "commands"))
+	return hex.EncodeToString(h.Sum(nil)[:12])
+}
+
+// currentCommandDigests computes and remembers the digests for a workDir
+func currentCommandDigests(workDir string) CommandDigests {
+	digests := CommandDigests{User: userCommandsDigest(), Project: projectCommandsDigest(workDir)}
+	commandDigestsMu.Lock()
+	commandDigests["user"] = digests.User
+	commandDigests["project|"+filepath.Clean(workDir)] = digests.Project
+	commandDigestsMu.Unlock()
+	return digests
+}
+
+// notifyCommandsUpdated sends commandsUpdated when a command or plugin change altered
+// the content of a scope's commands; touches and rewrites of the same content send nothing
+func notifyCommandsUpdated(change ConfigChange) {
+	if change.Kind != "commands" && change.Kind != "plugins" {
+		return
+	}
+	key, digest := "user", ""
+	if change.Scope == "project" {
+		key, digest = "project|"+change.WorkDir, projectCommandsDigest(change.WorkDir)
+	} else {
+		digest = userCommandsDigest()
+	}
+
+	commandDigestsMu.Lock()
+	previous, known := commandDigests[key]
+	commandDigests[key] = digest
+	commandDigestsMu.Unlock()
+	if known && previous == digest {
+		return
+	}
+	broadcastStateEvent("commandsUpdated", map[string]interface{}{
+		"scope":   change.Scope,
+		"workDir": change.WorkDir,
+		"digest":  digest,
+		"kind":    change.Kind,
+	})
+}
@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// compareAnswerLimit truncates the answers a comparison returns
+const compareAnswerLimit = 4000
+
+// TokenUsage sums the usage of a run's API calls
+type TokenUsage struct {
+	Input         int `json:"input"`
+	Output        int `json:"output"`
+	CacheRead     int `json:"cacheRead"`
+	CacheCreation int `json:"cacheCreation"`
+	Total         int `json:"total"`
+}
+
+func (u *TokenUsage) add(m *messageUsage) {
+	u.Input += m.InputTokens
+	u.Output += m.OutputTokens
+	u.CacheRead += m.CacheReadInputTokens
+	u.CacheCreation += m.CacheCreationInputTokens
+	u.Total = u.Input + u.Output + u.CacheRead + u.CacheCreation
+}
+
+// TurnResult is what one run did with one prompt
+type TurnResult struct {
+	Answer     string     `json:"answer"` // the last text the assistant wrote
+	StartedAt  string     `json:"startedAt,omitempty"`
+	DurationMS int64      `json:"durationMs"` // prompt to last message of the turn
+	Usage      TokenUsage `json:"usage"`
+	ToolCalls  int        `json:"toolCalls"`
+	Files      []string   `json:"files,omitempty"` // read or edited in this turn, relative to the working directory
+}
+
+// RunSummary is the totals of one run
+type RunSummary struct {
+	SessionID   string         `json:"sessionId"`
+	Title       string         `json:"title"`
+	WorkDir     string         `json:"workDir,omitempty"`
+	Models      []string       `json:"models"`
+	StartedAt   string         `json:"startedAt,omitempty"`
+	EndedAt     string         `json:"endedAt,omitempty"`
+	DurationMS  int64          `json:"durationMs"` // sum of the turns' durations, without idle time between them
+	Turns       int            `json:"turns"`
+	Usage       TokenUsage     `json:"usage"`
+	ToolCalls   int            `json:"toolCalls"`
+	Tools       map[string]int `json:"tools"` // tool name -> calls
+	FilesRead   []string       `json:"filesRead"`
+	FilesEdited []string       `json:"filesEdited"`
+	FinalAnswer string         `json:"finalAnswer"`
+}
+
+// ComparedTurn is the nth prompt of both runs side by side
+type ComparedTurn struct {
+	Index      int         `json:"index"`
+	Prompt     string      `json:"prompt"`            // the prompt of run a
+	PromptB    string      `json:"promptB,omitempty"` // the prompt of run b, when it differs
+	SamePrompt bool        `json:"samePrompt"`
+	A          *TurnResult `json:"a"` // nil when run a has fewer turns
+	B          *TurnResult `json:"b"`
+}
+
+// FileComparison splits the files the runs edited by which run edited them
+type FileComparison struct {
+	Both  []string `json:"both"`
+	OnlyA []string `json:"onlyA"`
+	OnlyB []string `json:"onlyB"`
+}
+
+// RunComparison is the response of GET /api/runs/compare
+type RunComparison struct {
+	A             RunSummary     `json:"a"`
+	B             RunSummary     `json:"b"`
+	SamePrompts   bool           `json:"samePrompts"` // every aligned prompt matches and both have as many turns
+	Turns         []ComparedTurn `json:"turns"`
+	EditedFiles   FileComparison `json:"editedFiles"`
+	DurationDelta int64          `json:"durationDeltaMs"` // b minus a
+	TokenDelta    int            `json:"tokenDelta"`      // b minus a, total tokens
+}
+
+// runTurn is a prompt of a transcript and what followed it
+type runTurn struct {
+	prompt string
+	result TurnResult
+	first  time.Time
+	last   time.Time
+	files  map[string]bool
+}
+
+// userPromptText returns the text of a user message typed by a person; tool results are not prompts
+func userPromptText(content interface{}) (string, bool) {
+	switch content := content.(type) {
+	case string:
+		return strings.TrimSpace(content), true
+	case []interface{}:
+		var texts []string
+		for _, item := range content {
+			block, _ := item.(map[string]interface{})
+			switch block["type"] {
+			case "tool_result":
+				return "", false
+			case "text":
+				if text, ok := block["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.TrimSpace(strings.Join(texts, "\n\n")), len(texts) > 0
+	}
+	return "", false
+}
+
+// summarizeRun reads a transcript into per-prompt turns and run totals
+func summarizeRun(sessionID, path string) (RunSummary, []runTurn, error) {
+	summary := RunSummary{SessionID: sessionID, Models: []string{}, Tools: make(map[string]int), FilesRead: []string{}, FilesEdited: []string{}}
+	file, err := os.Open(path)
+	if err != nil {
+		return summary, nil, err
+	}
+	defer file.Close()
+
+	var turns []runTurn
+	models := make(map[string]bool)
+	read, edited := make(map[string]bool), make(map[string]bool)
+	seenUsage := make(map[string]bool) // an API response spans several lines that repeat its usage
+	var first, last time.Time
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		var msg Message
+		if len(line) > 0 && json.Unmarshal(line, &msg) == nil && isHistoryMessage(msg.Type) && !msg.IsSidechain {
+			if summary.WorkDir == "" {
+				summary.WorkDir = msg.CWD
+			}
+			stamp, _ := time.Parse(time.RFC3339Nano, msg.Timestamp)
+			if !stamp.IsZero() {
+				if first.IsZero() {
+					first = stamp
+				}
+				last = stamp
+			}
+
+			if msg.Type != "assistant" {
+				if prompt, ok := userPromptText(msg.Message["content"]); ok {
+					turns = append(turns, runTurn{prompt: prompt, first: stamp, last: stamp, files: make(map[string]bool)})
+					turns[len(turns)-1].result.StartedAt = msg.Timestamp
+					if summary.Title == "" {
+						summary.Title = clipText(strings.Join(strings.Fields(prompt), " "), 100)
+					}
+					continue
+				}
+			}
+			if len(turns) == 0 {
+				// Lines before the first prompt (e.g. a resumed summary) belong to no turn
+				continue
+			}
+			turn := &turns[len(turns)-1]
+			if !stamp.IsZero() {
+				turn.last = stamp
+			}
+			if msg.Type != "assistant" {
+				continue
+			}
+
+			if model, ok := msg.Message["model"].(string); ok && model != "" && model != "<synthetic>" {
+				models[model] = true
+			}
+			if raw, ok := msg.Message["usage"]; ok {
+				id, _ := msg.Message["id"].(string)
+				if id == "" || !seenUsage[id] {
+					seenUsage[id] = id != ""
+					var usage messageUsage
+					if data, err := json.Marshal(raw); err == nil && json.Unmarshal(data, &usage) == nil {
+						turn.result.Usage.add(&usage)
+						summary.Usage.add(&usage)
+					}
+				}
+			}
+			blocks, _ := msg.Message["content"].([]interface{})
+			for _, item := range blocks {
+				block, _ := item.(map[string]interface{})
+				switch block["type"] {
+				case "text":
+					if text, ok := block["text"].(string); ok && strings.TrimSpace(text) != "" {
+						turn.result.Answer = clipText(strings.TrimSpace(text), compareAnswerLimit)
+					}
+				case "tool_use":
+					name, _ := block["name"].(string)
+					turn.result.ToolCalls++
+					summary.ToolCalls++
+					summary.Tools[name]++
+					reason, ok := contextFileTools[name]
+					if !ok {
+						continue
+					}
+					input, _ := block["input"].(map[string]interface{})
+					for _, key := range []string{"file_path", "notebook_path"} {
+						if p, ok := input[key].(string); ok && p != "" {
+							// Relative to the run's directory, so runs in different checkouts line up
+							if rel, err := filepath.Rel(summary.WorkDir, p); err == nil && summary.WorkDir != "" && !strings.HasPrefix(rel, "..") {
+								p = rel
+							}
+							turn.files[p] = true
+							if reason == "edited" {
+								edited[p] = true
+							} else {
+								read[p] = true
+							}
+						}
+					}
+				}
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+
+	for i := range turns {
+		turn := &turns[i]
+		if !turn.first.IsZero() {
+			turn.result.DurationMS = turn.last.Sub(turn.first).Milliseconds()
+		}
+		summary.DurationMS += turn.result.DurationMS
+		turn.result.Files = sortedKeys(turn.files)
+	}
+	summary.Turns = len(turns)
+	if len(turns) > 0 {
+		summary.FinalAnswer = turns[len(turns)-1].result.Answer
+	}
+	if !first.IsZero() {
+		summary.StartedAt = first.Format(time.RFC3339)
+		summary.EndedAt = last.Format(time.RFC3339)
+	}
+	summary.Models = append(summary.Models, sortedKeys(models)...)
+	summary.FilesRead = append(summary.FilesRead, sortedKeys(read)...)
+	summary.FilesEdited = append(summary.FilesEdited, sortedKeys(edited)...)
+	if s := getSessionSummary(sessionID); s != nil && s.Summary != "" {
+		summary.Title = s.Summary
+	}
+	return summary, turns, nil
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CompareRuns handles GET /api/runs/compare
+// Aligns two sessions prompt by prompt, e.g. the same prompt run with different models,
+// and compares their answers, durations, token usage, and the files they touched
+// Query parameters:
+//   - a, b: the session IDs to compare
+func CompareRuns(c *gin.Context) {
+	user := currentUser(c)
+	ids := [2]string{c.Query("a"), c.Query("b")}
+	var summaries [2]RunSummary
+	var turns [2][]runTurn
+	for i, id := range ids {
+		if id == "" {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "a and b are required")
+			return
+		}
+		path := ""
+		if userCanAccessSession(user, id) {
+			path = findSessionFile(id)
+		}
+		if path == "" {
+			respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found: "+id)
+			return
+		}
+		var err error
+		summaries[i], turns[i], err = summarizeRun(id, path)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+			return
+		}
+	}
+
+	comparison := RunComparison{
+		A:             summaries[0],
+		B:             summaries[1],
+		SamePrompts:   len(turns[0]) == len(turns[1]),
+		Turns:         []ComparedTurn{},
+		DurationDelta: summaries[1].DurationMS - summaries[0].DurationMS,
+		TokenDelta:    summaries[1].Usage.Total - summaries[0].Usage.Total,
+	}
+	for i := 0; i < len(turns[0]) || i < len(turns[1]); i++ {
+		turn := ComparedTurn{Index: i}
+		if i < len(turns[0]) {
+			result := turns[0][i].result
+			turn.A, turn.Prompt = &result, turns[0][i].prompt
+		}
+		if i < len(turns[1]) {
+			result := turns[1][i].result
+			turn.B = &result
+			if turn.A == nil {
+				turn.Prompt = turns[1][i].prompt
+			} else if turns[1][i].prompt != turn.Prompt {
+				turn.PromptB = turns[1][i].prompt
+			}
+		}
+		turn.SamePrompt = turn.A != nil && turn.B != nil && turn.PromptB == ""
+		comparison.SamePrompts = comparison.SamePrompts && turn.SamePrompt
+		comparison.Turns = append(comparison.Turns, turn)
+	}
+
+	inA := make(map[string]bool)
+	for _, f := range summaries[0].FilesEdited {
+		inA[f] = true
+	}
+	files := FileComparison{Both: []string{}, OnlyA: []string{}, OnlyB: []string{}}
+	for _, f := range summaries[1].FilesEdited {
+		if inA[f] {
+			files.Both = append(files.Both, f)
+			delete(inA, f)
+		} else {
+			files.OnlyB = append(files.OnlyB, f)
+		}
+	}
+	files.OnlyA = append(files.OnlyA, sortedKeys(inA)...)
+	comparison.EditedFiles = files
+	c.JSON(http.StatusOK, comparison)
+}
@@ -2,21 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
 )
 
 // Command represents a command definition with metadata
 type Command struct {
-	Name         string `json:"name"`
-	Description  string `json:"description,omitempty"`
-	ArgumentHint string `json:"argumentHint,omitempty"`
-	Source       string `json:"source"` // "global", "project", or plugin namespace
+	Name                   string   `json:"name"`
+	Description            string   `json:"description,omitempty"`
+	ArgumentHint           string   `json:"argumentHint,omitempty"`
+	AllowedTools           []string `json:"allowedTools,omitempty"`
+	Model                  string   `json:"model,omitempty"`
+	DisableModelInvocation bool     `json:"disableModelInvocation,omitempty"`
+	Source                 string   `json:"source"` // "global", "project", or plugin namespace
+	// Arguments describes the input the command takes, for rendering a form before running it
+	Arguments CommandArgumentSchema `json:"arguments"`
+}
+
+// CommandArgumentSchema is what a command's body does with its arguments
+type CommandArgumentSchema struct {
+	// text ($ARGUMENTS), positional ($1..$9), mixed (both), or appended (no placeholders;
+	// arguments, if any, are added after the body)
+	Mode       string            `json:"mode"`
+	Positional []CommandArgument `json:"positional"`
+	Tools      []ToolRule        `json:"tools"` // allowed-tools, split into tool and pattern
+	// Shell lists the !`command` lines claude runs before reading the prompt; they need Bash in allowed-tools
+	Shell []string `json:"shell"`
+}
+
+// CommandArgument is one $N placeholder
+type CommandArgument struct {
+	Position int    `json:"position"`       // N in $N
+	Name     string `json:"name"`           // from argument-hint, or argN
+	Hint     string `json:"hint,omitempty"` // the argument-hint token, e.g. [pr-number]
+}
+
+// ToolRule is an allowed-tools entry, e.g. Bash(git add:*) is tool Bash with pattern "git add:*"
+type ToolRule struct {
+	Tool    string `json:"tool"`
+	Pattern string `json:"pattern,omitempty"`
 }
 
 // Config represents a CLAUDE.md configuration file
@@ -53,37 +85,166 @@ type InstalledPluginsFile struct {
 	Plugins map[string][]InstalledPluginEntry `json:"plugins"`
 }
 
-// parseFrontmatter extracts metadata from markdown frontmatter
-func parseFrontmatter(content string) (description string, argumentHint string) {
-	lines := strings.Split(content, "\n")
-	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
-		return "", ""
+// parseFrontmatter parses the YAML frontmatter of a markdown file into a map
+// Files whose frontmatter isn't valid YAML (e.g. unquoted colons in a
+// description) fall back to line-based `key: value` parsing
+func parseFrontmatter(content string) map[string]interface{} {
+	frontmatter, _ := splitFrontmatter(content)
+	fields := make(map[string]interface{})
+	if strings.TrimSpace(frontmatter) == "" {
+		return fields
 	}
 
-	inFrontmatter := true
-	for i := 1; i < len(lines); i++ {
-		line := strings.TrimSpace(lines[i])
-		if line == "---" {
-			break
+	if err := yaml.Unmarshal([]byte(frontmatter), &fields); err == nil {
+		return fields
+	}
+
+	fields = make(map[string]interface{})
+	for key, value := range parseFrontmatterFields(frontmatter) {
+		fields[key] = value
+	}
+	return fields
+}
+
+// frontmatterString returns a frontmatter value as a trimmed string
+func frontmatterString(fields map[string]interface{}, key string) string {
+	switch v := fields[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// frontmatterBool returns a frontmatter value as a boolean
+func frontmatterBool(fields map[string]interface{}, key string) bool {
+	switch v := fields[key].(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(strings.TrimSpace(v), "true")
+	default:
+		return false
+	}
+}
+
+// frontmatterStringList returns a frontmatter value that may be a YAML list
+// or a comma separated string (e.g. "allowed-tools: Bash(git:*), Read")
+func frontmatterStringList(fields map[string]interface{}, key string) []string {
+	var items []string
+	switch v := fields[key].(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := strings.TrimSpace(fmt.Sprint(item)); s != "" {
+				items = append(items, s)
+			}
 		}
-		if !inFrontmatter {
-			continue
+	case string:
+		for _, item := range splitTopLevelCommas(v) {
+			if item = strings.Trim(strings.TrimSpace(item), `"'`); item != "" {
+				items = append(items, item)
+			}
 		}
+	}
+	return items
+}
+
+// splitTopLevelCommas splits on commas that are not inside parentheses
+func splitTopLevelCommas(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// commandFromFrontmatter builds a Command from parsed frontmatter and the markdown body
+func commandFromFrontmatter(name string, source string, fields map[string]interface{}, body string) Command {
+	cmd := Command{
+		Name:                   name,
+		Description:            frontmatterString(fields, "description"),
+		ArgumentHint:           frontmatterString(fields, "argument-hint"),
+		AllowedTools:           frontmatterStringList(fields, "allowed-tools"),
+		Model:                  frontmatterString(fields, "model"),
+		DisableModelInvocation: frontmatterBool(fields, "disable-model-invocation"),
+		Source:                 source,
+	}
+	cmd.Arguments = commandArgumentSchema(body, cmd.ArgumentHint, cmd.AllowedTools)
+	return cmd
+}
 
-		if strings.HasPrefix(line, "description:") {
-			desc := strings.TrimPrefix(line, "description:")
-			desc = strings.TrimSpace(desc)
-			desc = strings.Trim(desc, `"'`)
-			description = desc
-		} else if strings.HasPrefix(line, "argument-hint:") {
-			hint := strings.TrimPrefix(line, "argument-hint:")
-			hint = strings.TrimSpace(hint)
-			hint = strings.Trim(hint, `"'`)
-			argumentHint = hint
+var (
+	// argumentHintToken matches one argument in an argument-hint: [name], <name>, or a bare word
+	argumentHintToken = regexp.MustCompile(`\[([^\]]*)\]|<([^>]*)>|(\S+)`)
+	// shellLineRegex matches !`command` lines, whose output claude sees with the prompt
+	shellLineRegex = regexp.MustCompile("!`([^`\n]+)`")
+	// toolRuleRegex splits an allowed-tools entry into tool and pattern
+	toolRuleRegex = regexp.MustCompile(`^([A-Za-z0-9_\-]+(?:__[A-Za-z0-9_\-]+)*)\((.*)\)$`)
+)
+
+// commandArgumentSchema reads the placeholders of a command body
+// argument-hint names the positional arguments in order; its tokens are only labels
+func commandArgumentSchema(body string, hint string, allowedTools []string) CommandArgumentSchema {
+	schema := CommandArgumentSchema{Positional: []CommandArgument{}, Tools: []ToolRule{}, Shell: []string{}}
+
+	var names, hints []string
+	for _, m := range argumentHintToken.FindAllStringSubmatch(hint, -1) {
+		names = append(names, strings.TrimSpace(m[1]+m[2]+m[3]))
+		hints = append(hints, m[0])
+	}
+	highest := 0
+	for _, m := range positionalArgRegex.FindAllStringSubmatch(body, -1) {
+		if n := int(m[1][0] - '0'); n > highest {
+			highest = n
 		}
 	}
+	// Every position up to the highest one used takes a value, even ones the body skips
+	for n := 1; n <= highest; n++ {
+		arg := CommandArgument{Position: n, Name: fmt.Sprintf("arg%d", n)}
+		if n <= len(names) && names[n-1] != "" {
+			arg.Name, arg.Hint = names[n-1], hints[n-1]
+		}
+		schema.Positional = append(schema.Positional, arg)
+	}
+
+	text := strings.Contains(body, "$ARGUMENTS")
+	switch {
+	case text && highest > 0:
+		schema.Mode = "mixed"
+	case text:
+		schema.Mode = "text"
+	case highest > 0:
+		schema.Mode = "positional"
+	default:
+		schema.Mode = "appended"
+	}
 
-	return description, argumentHint
+	for _, m := range shellLineRegex.FindAllStringSubmatch(body, -1) {
+		schema.Shell = append(schema.Shell, strings.TrimSpace(m[1]))
+	}
+	for _, entry := range allowedTools {
+		if m := toolRuleRegex.FindStringSubmatch(entry); m != nil {
+			schema.Tools = append(schema.Tools, ToolRule{Tool: m[1], Pattern: m[2]})
+		} else {
+			schema.Tools = append(schema.Tools, ToolRule{Tool: entry})
+		}
+	}
+	return schema
 }
 
 // scanCommandsInDir scans a directory for *.md and */skill.md files
@@ -106,26 +267,18 @@ func scanCommandsInDir(dir string, source string) []Command {
 			// Check for skill.md in subdirectory
 			skillPath := filepath.Join(dir, entry.Name(), "skill.md")
 			if content, err := os.ReadFile(skillPath); err == nil {
-				desc, argHint := parseFrontmatter(string(content))
-				commands = append(commands, Command{
-					Name:         entry.Name(),
-					Description:  desc,
-					ArgumentHint: argHint,
-					Source:       source,
-				})
+				fields := parseFrontmatter(string(content))
+				_, body := splitFrontmatter(string(content))
+				commands = append(commands, commandFromFrontmatter(entry.Name(), source, fields, body))
 			}
 		} else if strings.HasSuffix(entry.Name(), ".md") && entry.Name() != "skill.md" {
 			// Regular .md file (not skill.md)
 			filePath := filepath.Join(dir, entry.Name())
 			if content, err := os.ReadFile(filePath); err == nil {
-				desc, argHint := parseFrontmatter(string(content))
+				fields := parseFrontmatter(string(content))
+				_, body := splitFrontmatter(string(content))
 				name := strings.TrimSuffix(entry.Name(), ".md")
-				commands = append(commands, Command{
-					Name:         name,
-					Description:  desc,
-					ArgumentHint: argHint,
-					Source:       source,
-				})
+				commands = append(commands, commandFromFrontmatter(name, source, fields, body))
 			}
 		}
 	}
@@ -139,6 +292,10 @@ func ListCommands(c *gin.Context) {
 	if workDir == "" {
 		workDir = "."
 	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+	watchProject(currentUser(c), workDir)
 
 	var allCommands []Command
 	homeDir, _ := os.UserHomeDir()
@@ -152,27 +309,15 @@ func ListCommands(c *gin.Context) {
 	allCommands = append(allCommands, scanCommandsInDir(projectCommandsDir, "project")...)
 
 	// 3. Plugin commands: from installed_plugins.json
-	pluginsFile := filepath.Join(homeDir, ".claude", "plugins", "installed_plugins.json")
-	if data, err := os.ReadFile(pluginsFile); err == nil {
-		var pluginsData InstalledPluginsFile
-		if err := json.Unmarshal(data, &pluginsData); err == nil {
-			for pluginName, entries := range pluginsData.Plugins {
-				if len(entries) == 0 {
-					continue
-				}
-				entry := entries[0]
-
-				commandsDir := filepath.Join(entry.InstallPath, "commands")
-				pluginCommands := scanCommandsInDir(commandsDir, pluginName)
-
-				// Prefix plugin commands with namespace:name
-				for i := range pluginCommands {
-					pluginCommands[i].Name = pluginName + ":" + pluginCommands[i].Name
-				}
-
-				allCommands = append(allCommands, pluginCommands...)
-			}
+	for pluginName, commandsDir := range pluginCommandDirs() {
+		pluginCommands := scanCommandsInDir(commandsDir, pluginName)
+
+		// Prefix plugin commands with namespace:name
+		for i := range pluginCommands {
+			pluginCommands[i].Name = pluginName + ":" + pluginCommands[i].Name
 		}
+
+		allCommands = append(allCommands, pluginCommands...)
 	}
 
 	// Sort by name
@@ -182,6 +327,7 @@ func ListCommands(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"commands": allCommands,
+		"digests":  currentCommandDigests(workDir),
 	})
 }
 
@@ -191,6 +337,10 @@ func GetConfig(c *gin.Context) {
 	if workDir == "" {
 		workDir = "."
 	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+	watchProject(currentUser(c), workDir)
 
 	var configs []Config
 	homeDir, _ := os.UserHomeDir()
@@ -275,9 +425,7 @@ func ListPlugins(c *gin.Context) {
 
 	var pluginsData InstalledPluginsFile
 	if err := json.Unmarshal(data, &pluginsData); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to parse installed_plugins.json: " + err.Error(),
-		})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to parse installed_plugins.json: "+err.Error())
 		return
 	}
 
@@ -304,3 +452,214 @@ func ListPlugins(c *gin.Context) {
 		"plugins": plugins,
 	})
 }
+
+// CommandDetail is a command with its full markdown body and frontmatter
+type CommandDetail struct {
+	Command
+	Path        string                 `json:"path"`
+	Frontmatter map[string]interface{} `json:"frontmatter"`
+	Body        string                 `json:"body"`
+}
+
+// RunCommandRequest represents the request body for POST /api/commands/:name/run
+type RunCommandRequest struct {
+	Arguments string `json:"arguments"`
+	// Positional fills $1..$9 one value each, so values may contain spaces; $ARGUMENTS
+	// becomes the values joined with spaces. Takes precedence over Arguments
+	Positional []string `json:"positional,omitempty"`
+	SessionID  string   `json:"sessionId"`
+	WorkDir    string   `json:"workDir"`
+	PlanMode   bool     `json:"planMode"`
+	MCPServers []string `json:"mcpServers,omitempty"`
+}
+
+// positionalArgRegex matches $1..$9 placeholders in command templates
+var positionalArgRegex = regexp.MustCompile(`\$([1-9])`)
+
+// splitFrontmatter separates the frontmatter block from the markdown body
+func splitFrontmatter(content string) (frontmatter string, body string) {
+	lines := strings.Split(content, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return "", content
+	}
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			return strings.Join(lines[1:i], "\n"), strings.TrimLeft(strings.Join(lines[i+1:], "\n"), "\n")
+		}
+	}
+	// Unterminated frontmatter - treat everything as body
+	return "", content
+}
+
+// parseFrontmatterFields parses simple `key: value` frontmatter lines into a map
+// Used as a fallback when the frontmatter is not valid YAML
+func parseFrontmatterFields(frontmatter string) map[string]string {
+	fields := make(map[string]string)
+	for _, line := range strings.Split(frontmatter, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+		fields[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	return fields
+}
+
+// findCommandFile resolves a command name to its markdown file
+// Plugin commands use the "plugin:name" form; plain names check project then global
+func findCommandFile(name string, workDir string) (path string, source string, ok bool) {
+	homeDir, _ := os.UserHomeDir()
+
+	// lookup checks dir/{name}.md and dir/{name}/skill.md
+	lookup := func(dir string, cmdName string) (string, bool) {
+		if cmdName == "" || strings.ContainsAny(cmdName, `/\`) || cmdName == ".." {
+			return "", false
+		}
+		for _, candidate := range []string{
+			filepath.Join(dir, cmdName+".md"),
+			filepath.Join(dir, cmdName, "skill.md"),
+		} {
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, true
+			}
+		}
+		return "", false
+	}
+
+	if pluginName, cmdName, isPlugin := strings.Cut(name, ":"); isPlugin {
+		entry, err := findInstalledPlugin(pluginName)
+		if err != nil {
+			return "", "", false
+		}
+		if p, ok := lookup(filepath.Join(entry.InstallPath, "commands"), cmdName); ok {
+			return p, pluginName, true
+		}
+		return "", "", false
+	}
+
+	if p, ok := lookup(filepath.Join(workDir, ".claude", "commands"), name); ok {
+		return p, "project", true
+	}
+	if p, ok := lookup(filepath.Join(homeDir, ".claude", "commands"), name); ok {
+		return p, "global", true
+	}
+	return "", "", false
+}
+
+// loadCommandDetail reads and parses a command's markdown file
+func loadCommandDetail(name string, workDir string) (*CommandDetail, error) {
+	path, source, ok := findCommandFile(name, workDir)
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, body := splitFrontmatter(string(content))
+	fields := parseFrontmatter(string(content))
+	return &CommandDetail{
+		Command:     commandFromFrontmatter(name, source, fields, body),
+		Path:        path,
+		Frontmatter: fields,
+		Body:        body,
+	}, nil
+}
+
+// expandCommandTemplate substitutes $ARGUMENTS and $1..$9 placeholders
+// If the template has no placeholders, arguments are appended to the body
+// positional, when given, fills $1..$9 instead of splitting arguments on spaces
+func expandCommandTemplate(body string, arguments string, positional []string) string {
+	if positional != nil {
+		arguments = strings.Join(positional, " ")
+	} else {
+		positional = strings.Fields(arguments)
+	}
+	hasPlaceholder := strings.Contains(body, "$ARGUMENTS") || positionalArgRegex.MatchString(body)
+
+	expanded := strings.ReplaceAll(body, "$ARGUMENTS", arguments)
+	expanded = positionalArgRegex.ReplaceAllStringFunc(expanded, func(m string) string {
+		idx := int(m[1] - '1')
+		if idx < len(positional) {
+			return positional[idx]
+		}
+		return ""
+	})
+
+	if !hasPlaceholder && strings.TrimSpace(arguments) != "" {
+		expanded = strings.TrimRight(expanded, "\n") + "\n\n" + arguments
+	}
+	return strings.TrimSpace(expanded)
+}
+
+// GetCommand handles GET /api/commands/:name
+// Query parameters:
+//   - work_dir: project directory for project commands
+func GetCommand(c *gin.Context) {
+	workDir := c.Query("work_dir")
+	if workDir == "" {
+		workDir = "."
+	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+
+	detail, err := loadCommandDetail(c.Param("name"), workDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondError(c, http.StatusNotFound, ErrNotFound, "Command not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, detail)
+}
+
+// RunCommand handles POST /api/commands/:name/run
+// Expands the command template with the given arguments and starts a chat,
+// streaming output via SSE exactly like POST /api/chat
+func RunCommand(c *gin.Context) {
+	var req RunCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+
+	workDir := req.WorkDir
+	if workDir == "" && req.SessionID != "" {
+		workDir = GetSessionWorkDir(req.SessionID)
+	}
+	if workDir == "" {
+		workDir = "."
+	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+
+	detail, err := loadCommandDetail(c.Param("name"), workDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondError(c, http.StatusNotFound, ErrNotFound, "Command not found")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+
+	prompt := expandCommandTemplate(detail.Body, req.Arguments, req.Positional)
+	if prompt == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Command expanded to an empty prompt")
+		return
+	}
+
+	executeChatStream(c, ChatRequest{
+		Prompt:     prompt,
+		SessionID:  req.SessionID,
+		WorkDir:    req.WorkDir,
+		PlanMode:   req.PlanMode,
+		MCPServers: req.MCPServers,
+	}, false)
+}
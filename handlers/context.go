@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContextUsage is how much of the model's context window a session's last turn used
+type ContextUsage struct {
+	Tokens         int       `json:"tokens"`
+	Window         int       `json:"window"`
+	Percent        float64   `json:"percent"`
+	SuggestCompact bool      `json:"suggestCompact"`
+	Model          string    `json:"model,omitempty"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// CompactRequest is the optional body of POST /api/session/:id/compact
+type CompactRequest struct {
+	Instructions string `json:"instructions,omitempty"` // what the summary should focus on
+	WorkDir      string `json:"workDir,omitempty"`
+	Backend      string `json:"backend,omitempty"`
+}
+
+// Live usage seen in stream output, for sessions whose transcript isn't on this machine
+var (
+	contextMu    sync.Mutex
+	contextUsage = make(map[string]ContextUsage)
+)
+
+// messageUsage is the usage block of an assistant message
+type messageUsage struct {
+	InputTokens              int `json:"input_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+}
+
+// contextLine is the subset of a stream event or transcript line that carries context usage
+type contextLine struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	SessionID   string `json:"session_id"`
+	IsSidechain bool   `json:"isSidechain"`
+	ParentTool  string `json:"parent_tool_use_id"` // set on subagent turns in stream output
+	Message     struct {
+		Model string        `json:"model"`
+		Usage *messageUsage `json:"usage"`
+	} `json:"message"`
+}
+
+// newContextUsage sizes a token count against the model's window and the compaction threshold
+func newContextUsage(tokens int, model string, at time.Time) ContextUsage {
+	cfg := getServerConfig().Claude
+	window := cfg.ContextWindow
+	if strings.Contains(model, "[1m]") {
+		window = 1000000
+	}
+	u := ContextUsage{Tokens: tokens, Window: window, Model: model, UpdatedAt: at}
+	if window > 0 {
+		u.Percent = float64(tokens) * 100 / float64(window)
+		u.SuggestCompact = cfg.CompactAtPercent > 0 && u.Percent >= float64(cfg.CompactAtPercent)
+	}
+	return u
+}
+
+// parseContextLine returns the context size after an assistant turn
+// A compact boundary reports reset; other lines report ok=false
+func parseContextLine(line string) (event contextLine, tokens int, ok bool, reset bool) {
+	if !strings.Contains(line, `"usage"`) && !strings.Contains(line, "compact_boundary") {
+		return event, 0, false, false
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return event, 0, false, false
+	}
+	if event.Type == "system" && event.Subtype == "compact_boundary" {
+		return event, 0, false, true
+	}
+	if event.Type != "assistant" || event.IsSidechain || event.ParentTool != "" || event.Message.Usage == nil {
+		return event, 0, false, false
+	}
+	usage := event.Message.Usage
+	tokens = usage.InputTokens + usage.CacheCreationInputTokens + usage.CacheReadInputTokens + usage.OutputTokens
+	return event, tokens, true, false
+}
+
+// trackContextUsage records context usage from a claude output line and pushes it to clients
+// sessionID is used when the event doesn't carry one
+func trackContextUsage(sessionID string, line string) {
+	event, tokens, ok, reset := parseContextLine(line)
+	if !ok && !reset {
+		return
+	}
+	if event.SessionID != "" {
+		sessionID = event.SessionID
+	}
+	if sessionID == "" {
+		return
+	}
+
+	contextMu.Lock()
+	if reset {
+		delete(contextUsage, sessionID)
+		contextMu.Unlock()
+		broadcastSessionEvent(sessionID, "context", map[string]interface{}{"contextUsage": nil})
+		return
+	}
+	if prev, seen := contextUsage[sessionID]; seen && prev.Tokens == tokens {
+		contextMu.Unlock()
+		return
+	}
+	usage := newContextUsage(tokens, event.Message.Model, time.Now())
+	contextUsage[sessionID] = usage
+	contextMu.Unlock()
+
+	broadcastSessionEvent(sessionID, "context", map[string]interface{}{"contextUsage": usage})
+}
+
+// sessionContextUsage reads the last main-thread turn's usage from a transcript
+// Falls back to usage seen live when there is no local transcript; nil if unknown or just compacted
+func sessionContextUsage(sessionID string, sessionFile string) *ContextUsage {
+	if sessionFile == "" {
+		contextMu.Lock()
+		defer contextMu.Unlock()
+		if usage, ok := contextUsage[sessionID]; ok {
+			return &usage
+		}
+		return nil
+	}
+
+	file, err := os.Open(sessionFile)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var last *ContextUsage
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		event, tokens, ok, reset := parseContextLine(scanner.Text())
+		if reset {
+			last = nil
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var stamp struct {
+			Timestamp time.Time `json:"timestamp"`
+		}
+		json.Unmarshal(scanner.Bytes(), &stamp)
+		usage := newContextUsage(tokens, event.Message.Model, stamp.Timestamp)
+		last = &usage
+	}
+	return last
+}
+
+// forgetContextUsage drops live usage for a deleted session
+func forgetContextUsage(sessionID string) {
+	contextMu.Lock()
+	defer contextMu.Unlock()
+	delete(contextUsage, sessionID)
+}
+
+// CompactSession handles POST /api/session/:id/compact
+// Runs claude's /compact on the session, streaming output via SSE like POST /api/chat
+func CompactSession(c *gin.Context) {
+	var req CompactRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+			return
+		}
+	}
+
+	prompt := "/compact"
+	if instructions := strings.TrimSpace(req.Instructions); instructions != "" {
+		prompt += " " + instructions
+	}
+	// /compact is a CLI command, so it never goes through the api chat backend
+	executeChatStream(c, ChatRequest{
+		Prompt:      prompt,
+		SessionID:   c.Param("id"),
+		WorkDir:     req.WorkDir,
+		Backend:     req.Backend,
+		ChatBackend: "cli",
+	}, false)
+}
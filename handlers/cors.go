@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// IsSameOrigin reports whether origin matches the host the request was sent to
+// Requests are compared by host only so TLS-terminating proxies still match.
+// Host is chosen by the client, so without authentication it must also be trusted:
+// a DNS-rebinding page would otherwise send a matching Origin and Host of its own
+func IsSameOrigin(r *http.Request, origin string) bool {
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host) && (authEnabled() || trustedRequestHost(r.Host))
+}
+
+// hostName returns host without its port and IPv6 brackets
+func hostName(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(strings.Trim(host, "[]"))
+}
+
+// trustedRequestHost reports whether a Host header names this server rather than a name an attacker controls:
+// a loopback name, an IP address (which DNS rebinding can't produce), the configured listen host,
+// or the host of an allowedOrigins entry
+func trustedRequestHost(host string) bool {
+	name := hostName(host)
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") || net.ParseIP(name) != nil {
+		return true
+	}
+	cfg := getServerConfig()
+	listenHost := cfg.Host
+	if cfg.Listen != "" {
+		listenHost = cfg.Listen
+	}
+	if listen := hostName(listenHost); listen != "" && listen == name {
+		return true
+	}
+	for _, allowed := range cfg.AllowedOrigins {
+		_, allowedHost, _ := strings.Cut(allowed, "://")
+		allowedHost = hostName(allowedHost)
+		if suffix, ok := strings.CutPrefix(allowedHost, "*."); ok {
+			if strings.HasSuffix(name, "."+suffix) {
+				return true
+			}
+		} else if allowedHost == name {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeOrigin validates an allowed-origins entry and returns it as scheme://host[:port]
+// A leading "*." in the host matches any subdomain, e.g. https://*.example.com
+func normalizeOrigin(origin string) (string, error) {
+	if origin == "*" {
+		return "", fmt.Errorf("wildcard origin is not allowed with credentials; list origins explicitly")
+	}
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid origin %q (expected scheme://host[:port])", origin)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.User != nil {
+		return "", fmt.Errorf("invalid origin %q (must not contain a path)", origin)
+	}
+	return strings.ToLower(u.Scheme + "://" + u.Host), nil
+}
+
+// matchOrigin compares a browser Origin against one normalized allowlist entry
+func matchOrigin(origin string, allowed string) bool {
+	origin = strings.ToLower(origin)
+	if origin == allowed {
+		return true
+	}
+	scheme, host, ok := strings.Cut(allowed, "://*.")
+	if !ok {
+		return false
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme != scheme {
+		return false
+	}
+	return strings.HasSuffix(u.Host, "."+host)
+}
+
+// OriginAllowed reports whether a browser request from origin may access the API
+// Requests without an Origin (curl, native clients) and same-origin requests are
+// allowed; cross-origin requests must match the allowedOrigins config. Without
+// authentication, browser requests (those with Sec-Fetch-Site) must be sent to a trusted host
+func OriginAllowed(r *http.Request, origin string) bool {
+	if origin == "" {
+		return authEnabled() || r.Header.Get("Sec-Fetch-Site") == "" || trustedRequestHost(r.Host)
+	}
+	if IsSameOrigin(r, origin) {
+		return true
+	}
+	for _, allowed := range getServerConfig().AllowedOrigins {
+		if matchOrigin(origin, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOrigin is the CheckOrigin function shared by the WebSocket upgraders
+func checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if OriginAllowed(r, origin) {
+		return true
+	}
+	log.Printf("[WS] Rejected connection from origin: %s (path %s)", origin, r.URL.Path)
+	return false
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// diagnosticsKeep is how many recent diagnostics GET /api/diagnostics can list
+const diagnosticsKeep = 200
+
+// diagnosticRule recognizes one kind of claude CLI failure in its stderr or error result
+type diagnosticRule struct {
+	kind        string
+	severity    string // error or warning
+	title       string
+	remediation string
+	re          *regexp.Regexp
+}
+
+// diagnosticRules are checked in order; the first match wins
+var diagnosticRules = []diagnosticRule{
+	{
+		kind:        "not_logged_in",
+		severity:    "error",
+		title:       "Claude CLI is not logged in",
+		remediation: "Sign in with POST /api/auth/claude-login, run `claude` on the server and use /login, or set ANTHROPIC_API_KEY for the server process.",
+		re:          regexp.MustCompile(`(?i)not logged in|please run /login|invalid api key|authentication_error|oauth token (?:has )?expired|could not resolve authentication`),
+	},
+	{
+		kind:        "credit_exhausted",
+		severity:    "error",
+		title:       "Account has no credit left",
+		remediation: "Add credit or raise the spend limit of the account the CLI uses.",
+		re:          regexp.MustCompile(`(?i)credit balance is too low|insufficient.?credit`),
+	},
+	{
+		kind:        "rate_limited",
+		severity:    "warning",
+		title:       "Rate limited",
+		remediation: "Wait for the limit to reset, lower concurrent runs, or switch to a smaller model.",
+		re:          regexp.MustCompile(`(?i)rate.?limit|too many requests|usage limit reached|\b429\b`),
+	},
+	{
+		kind:        "overloaded",
+		severity:    "warning",
+		title:       "Model is overloaded",
+		remediation: "The API is temporarily overloaded; retry in a minute or use another model.",
+		re:          regexp.MustCompile(`(?i)overloaded|\b529\b`),
+	},
+	{
+		kind:        "invalid_model",
+		severity:    "error",
+		title:       "Model not available",
+		remediation: "Check the model name in the request, project settings, or claude.defaultModel.",
+		re:          regexp.MustCompile(`(?i)invalid model|model[^.]*not found|not_found_error.*model|model.*does not exist`),
+	},
+	{
+		kind:        "invalid_flag",
+		severity:    "error",
+		title:       "Claude CLI rejected a flag",
+		remediation: "The installed claude CLI may be too old or too new for this server; run `claude update` or check claude.extraArgs.",
+		re:          regexp.MustCompile(`(?i)unknown option|unknown argument|unexpected argument|invalid value for|error: option .* argument missing|too many arguments`),
+	},
+	{
+		kind:        "context_too_long",
+		severity:    "error",
+		title:       "Conversation is too long",
+		remediation: "Run /compact in the session or start a new one.",
+		re:          regexp.MustCompile(`(?i)prompt is too long|context (?:length|window) exceeded|maximum context length`),
+	},
+	{
+		kind:        "network",
+		severity:    "warning",
+		title:       "Network error reaching the API",
+		remediation: "Check the server's connectivity and proxy settings (HTTPS_PROXY).",
+		re:          regexp.MustCompile(`(?i)ECONNREFUSED|ECONNRESET|ENOTFOUND|ETIMEDOUT|EAI_AGAIN|getaddrinfo|fetch failed|network error`),
+	},
+}
+
+// Diagnostic is a recognized claude CLI failure and what to do about it
+type Diagnostic struct {
+	Kind        string `json:"kind"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Remediation string `json:"remediation"`
+	Line        string `json:"line"` // the stderr line or error result it was recognized in
+}
+
+// DiagnosticEvent is a diagnostic as it happened
+type DiagnosticEvent struct {
+	Diagnostic
+	Time      int64  `json:"time"`   // unix seconds
+	Source    string `json:"source"` // sse, ws, or schedule
+	ProcessID int    `json:"processId,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	WorkDir   string `json:"workDir,omitempty"`
+	Owner     string `json:"owner,omitempty"`
+}
+
+// DiagnosticSummary aggregates the diagnostics of one kind
+type DiagnosticSummary struct {
+	Kind        string `json:"kind"`
+	Severity    string `json:"severity"`
+	Title       string `json:"title"`
+	Remediation string `json:"remediation"`
+	Count       int    `json:"count"`
+	FirstSeen   int64  `json:"firstSeen"`
+	LastSeen    int64  `json:"lastSeen"`
+	LastLine    string `json:"lastLine"`
+}
+
+// DiagnosticsResponse is the response of GET /api/diagnostics
+type DiagnosticsResponse struct {
+	Summary []DiagnosticSummary `json:"summary"` // most recent first
+	Recent  []DiagnosticEvent   `json:"recent"`  // newest first
+}
+
+var (
+	diagnosticsMu     sync.Mutex
+	diagnosticsRecent []DiagnosticEvent // oldest first
+)
+
+// classifyDiagnostic matches a line against diagnosticRules
+func classifyDiagnostic(line string) (Diagnostic, bool) {
+	for _, rule := range diagnosticRules {
+		if rule.re.MatchString(line) {
+			return Diagnostic{
+				Kind:        rule.kind,
+				Severity:    rule.severity,
+				Title:       rule.title,
+				Remediation: rule.remediation,
+				Line:        clipText(line, processLineLimit),
+			}, true
+		}
+	}
+	return Diagnostic{}, false
+}
+
+// diagnosticRun classifies the output of one claude run
+// A kind is reported once per run; repeats (retries, stack traces) are dropped
+type diagnosticRun struct {
+	source    string
+	processID int
+	workDir   string
+	owner     string
+
+	mu   sync.Mutex
+	seen map[string]bool
+}
+
+func newDiagnosticRun(source string, processID int, workDir, owner string) *diagnosticRun {
+	return &diagnosticRun{source: source, processID: processID, workDir: workDir, owner: owner, seen: make(map[string]bool)}
+}
+
+// check classifies a line; recognized is false for lines no rule matches,
+// and first is false when the run already reported the kind
+func (r *diagnosticRun) check(line string) (d Diagnostic, recognized, first bool) {
+	d, recognized = classifyDiagnostic(line)
+	if !recognized {
+		return d, false, false
+	}
+	r.mu.Lock()
+	first = !r.seen[d.Kind]
+	r.seen[d.Kind] = true
+	r.mu.Unlock()
+	if first {
+		recordDiagnostic(DiagnosticEvent{
+			Diagnostic: d,
+			Time:       time.Now().Unix(),
+			Source:     r.source,
+			ProcessID:  r.processID,
+			SessionID:  processSessionID(r.processID),
+			WorkDir:    r.workDir,
+			Owner:      r.owner,
+		})
+	}
+	return d, true, first
+}
+
+// checkResult classifies the text of an error result, which carries API errors
+func (r *diagnosticRun) checkResult(result *resultEvent) (Diagnostic, bool) {
+	if result == nil || !result.IsError || result.Result == "" {
+		return Diagnostic{}, false
+	}
+	d, _, first := r.check(result.Result)
+	return d, first
+}
+
+// processSessionID returns the session a running process is working on, if known yet
+func processSessionID(processID int) string {
+	processLock.RLock()
+	info := activeProcesses[processID]
+	var sessionID string
+	if info != nil {
+		sessionID = info.SessionID
+	}
+	processLock.RUnlock()
+	if sessionID != "" || info == nil || info.output == nil {
+		return sessionID
+	}
+	info.output.mu.Lock()
+	defer info.output.mu.Unlock()
+	return info.output.sessionID
+}
+
+// recordDiagnostic keeps a diagnostic for GET /api/diagnostics
+func recordDiagnostic(event DiagnosticEvent) {
+	diagnosticsMu.Lock()
+	diagnosticsRecent = append(diagnosticsRecent, event)
+	if len(diagnosticsRecent) > diagnosticsKeep {
+		diagnosticsRecent = diagnosticsRecent[len(diagnosticsRecent)-diagnosticsKeep:]
+	}
+	diagnosticsMu.Unlock()
+	recordDiagnosticMetric(event.Kind)
+}
+
+// localized returns d with its title and remediation in locale
+func (d Diagnostic) localized(locale string) Diagnostic {
+	d.Title, d.Remediation = translate(locale, d.Title), translate(locale, d.Remediation)
+	return d
+}
+
+// diagnosticMessage is the structured event sent to chat clients in place of the stderr line
+func diagnosticMessage(d Diagnostic, locale string) SSEMessage {
+	d = d.localized(locale)
+	return SSEMessage{
+		Type:    "diagnostic",
+		Message: d.Title,
+		Data: map[string]interface{}{
+			"kind":        d.Kind,
+			"severity":    d.Severity,
+			"remediation": d.Remediation,
+			"line":        d.Line,
+		},
+	}
+}
+
+// wsDiagnosticMessage is diagnosticMessage for WebSocket clients
+func wsDiagnosticMessage(d Diagnostic, locale string) map[string]interface{} {
+	d = d.localized(locale)
+	return map[string]interface{}{
+		"type":       "diagnostic",
+		"message":    d.Title,
+		"diagnostic": d,
+	}
+}
+
+// GetDiagnostics handles GET /api/diagnostics
+// Aggregates the claude CLI failures recognized in recent runs
+// Users see their own runs; admins see all
+// Query parameters:
+//   - since: only diagnostics at or after this unix time
+//   - kind: only diagnostics of this kind
+//   - sessionId: only diagnostics of this session
+func GetDiagnostics(c *gin.Context) {
+	var since int64
+	if value := c.Query("since"); value != "" {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid since parameter")
+			return
+		}
+	}
+	kind, sessionID := c.Query("kind"), c.Query("sessionId")
+	user := currentUser(c)
+	locale := requestLocale(c)
+
+	diagnosticsMu.Lock()
+	events := make([]DiagnosticEvent, 0, len(diagnosticsRecent))
+	for i := len(diagnosticsRecent) - 1; i >= 0; i-- {
+		event := diagnosticsRecent[i]
+		if event.Time < since || (kind != "" && event.Kind != kind) || (sessionID != "" && event.SessionID != sessionID) {
+			continue
+		}
+		if !userCanAccessOwner(user, event.Owner) {
+			continue
+		}
+		event.Diagnostic = event.localized(locale)
+		events = append(events, event)
+	}
+	diagnosticsMu.Unlock()
+
+	byKind := make(map[string]*DiagnosticSummary)
+	for _, event := range events {
+		summary := byKind[event.Kind]
+		if summary == nil {
+			summary = &DiagnosticSummary{
+				Kind:        event.Kind,
+				Severity:    event.Severity,
+				Title:       event.Title,
+				Remediation: event.Remediation,
+				LastSeen:    event.Time,
+				LastLine:    event.Line,
+			}
+			byKind[event.Kind] = summary
+		}
+		summary.Count++
+		summary.FirstSeen = event.Time
+	}
+	response := DiagnosticsResponse{Summary: []DiagnosticSummary{}, Recent: events}
+	for _, summary := range byKind {
+		response.Summary = append(response.Summary, *summary)
+	}
+	sort.Slice(response.Summary, func(i, j int) bool {
+		if response.Summary[i].LastSeen != response.Summary[j].LastSeen {
+			return response.Summary[i].LastSeen > response.Summary[j].LastSeen
+		}
+		return response.Summary[i].Kind < response.Summary[j].Kind
+	})
+	c.JSON(http.StatusOK, response)
+}
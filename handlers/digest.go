@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// digestProjectLimit is how many of the busiest projects a digest lists
+	digestProjectLimit = 5
+	// digestFailureLimit is how many failed schedule runs a digest lists
+	digestFailureLimit = 10
+)
+
+// DigestConfig mails a summary of activity on a schedule
+type DigestConfig struct {
+	Period string `yaml:"period" json:"period"` // daily or weekly; empty turns the digest off
+	// Schedule is a cron expression in server time; defaults to 08:00 every day, or on Mondays for weekly
+	Schedule string     `yaml:"schedule" json:"schedule,omitempty"`
+	To       []string   `yaml:"to" json:"to"`           // receive the digest of everyone's activity
+	PerUser  bool       `yaml:"perUser" json:"perUser"` // also mail each user with an email address a digest of their own
+	SMTP     SMTPConfig `yaml:"smtp" json:"smtp"`
+}
+
+// SMTPConfig is the mail server digests are sent through
+type SMTPConfig struct {
+	Host string `yaml:"host" json:"host"`
+	Port int    `yaml:"port" json:"port"` // 587 when unset; 465 uses implicit TLS, others STARTTLS when offered
+	// Username and Password authenticate with PLAIN; the password defaults to GREYZONE_SMTP_PASSWORD
+	Username string `yaml:"username" json:"username,omitempty"`
+	Password string `yaml:"password" json:"-"`
+	From     string `yaml:"from" json:"from"`
+}
+
+// Digest summarizes the sessions, runs, and cost of one period, and what's waiting on the user now
+type Digest struct {
+	Period   string            `json:"period"`
+	From     string            `json:"from"` // YYYY-MM-DD, server time
+	To       string            `json:"to"`
+	Totals   ActivityTotals    `json:"totals"`
+	Projects []ProjectActivity `json:"projects"` // busiest first
+	Failures []DigestFailure   `json:"failures"` // failed schedule runs, newest first
+	Waiting  []DigestWaiting   `json:"waiting"`  // sessions blocked on a permission prompt or question
+}
+
+// DigestFailure is a schedule run that ended in an error
+type DigestFailure struct {
+	Schedule string    `json:"schedule"`
+	Time     time.Time `json:"time"`
+	Error    string    `json:"error"`
+}
+
+// DigestWaiting is a session waiting for input
+type DigestWaiting struct {
+	SessionID string `json:"sessionId"`
+	InputRequest
+}
+
+// DigestSendResponse is the response of POST /api/digest/send
+type DigestSendResponse struct {
+	Recipients []string `json:"recipients"`
+}
+
+var (
+	digestSchedOnce sync.Once
+	// digestMu keeps scheduled and manual sends from overlapping
+	digestMu sync.Mutex
+)
+
+// schedule returns the cron expression digests are sent on
+func (d DigestConfig) schedule() string {
+	if d.Schedule != "" {
+		return d.Schedule
+	}
+	if d.Period == "weekly" {
+		return "0 8 * * 1"
+	}
+	return "0 8 * * *"
+}
+
+// validateDigestConfig checks the digest section of config.yaml
+func validateDigestConfig(cfg DigestConfig) error {
+	switch cfg.Period {
+	case "":
+		return nil
+	case "daily", "weekly":
+	default:
+		return fmt.Errorf("digest.period must be daily or weekly")
+	}
+	if _, err := parseCron(cfg.schedule()); err != nil {
+		return fmt.Errorf("digest.schedule: %w", err)
+	}
+	if cfg.SMTP.Host == "" || cfg.SMTP.From == "" {
+		return fmt.Errorf("digest.smtp needs host and from")
+	}
+	if len(cfg.To) == 0 && !cfg.PerUser {
+		return fmt.Errorf("digest needs recipients in to, or perUser")
+	}
+	return nil
+}
+
+// buildDigest summarizes the period before now for user (everyone when nil or an admin):
+// yesterday for daily, the seven days through yesterday for weekly
+func buildDigest(user *User, period string, now time.Time) (Digest, error) {
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local).AddDate(0, 0, -1)
+	from := to
+	if period == "weekly" {
+		from = to.AddDate(0, 0, -6)
+	}
+	activity, err := buildActivity(user, from, to, time.Local, "", digestProjectLimit)
+	if err != nil {
+		return Digest{}, err
+	}
+	d := Digest{
+		Period:   period,
+		From:     activity.From,
+		To:       activity.To,
+		Totals:   activity.Totals,
+		Projects: activity.Projects,
+		Failures: []DigestFailure{},
+		Waiting:  []DigestWaiting{},
+	}
+
+	end := to.AddDate(0, 0, 1)
+	schedulesMu.Lock()
+	if err := loadSchedules(); err == nil {
+		for _, s := range schedules.Schedules {
+			if !userCanAccessOwner(user, s.Owner) {
+				continue
+			}
+			for _, run := range schedules.Runs[s.ID] {
+				if run.Status == "error" && !run.StartedAt.Before(from) && run.StartedAt.Before(end) {
+					d.Failures = append(d.Failures, DigestFailure{Schedule: s.Name, Time: run.StartedAt, Error: run.Error})
+				}
+			}
+		}
+	}
+	schedulesMu.Unlock()
+	sort.Slice(d.Failures, func(i, j int) bool { return d.Failures[i].Time.After(d.Failures[j].Time) })
+	if len(d.Failures) > digestFailureLimit {
+		d.Failures = d.Failures[:digestFailureLimit]
+	}
+
+	for id, session := range filterState(stateManager.getState(), user).Sessions {
+		if session.WaitingForInput && session.InputRequest != nil {
+			d.Waiting = append(d.Waiting, DigestWaiting{SessionID: id, InputRequest: *session.InputRequest})
+		}
+	}
+	sort.Slice(d.Waiting, func(i, j int) bool { return d.Waiting[i].SessionID < d.Waiting[j].SessionID })
+	return d, nil
+}
+
+// empty reports whether nothing happened and nothing is waiting
+func (d Digest) empty() bool {
+	return d.Totals.Sessions == 0 && d.Totals.Runs == 0 && len(d.Waiting) == 0
+}
+
+// subject is the digest email's subject line
+func (d Digest) subject() string {
+	if d.From == d.To {
+		return "Claude activity on " + d.From
+	}
+	return fmt.Sprintf("Claude activity, %s to %s", d.From, d.To)
+}
+
+// text renders the digest as a plain-text email body
+func (d Digest) text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n\n", d.subject())
+	fmt.Fprintf(&b, "Sessions: %d\nMessages: %d\nRuns: %d (%d failed)\nCost: $%.2f\n",
+		d.Totals.Sessions, d.Totals.Messages, d.Totals.Runs, d.Totals.FailedRuns, d.Totals.CostUSD)
+	if len(d.Projects) > 0 {
+		b.WriteString("\nBusiest projects:\n")
+		for _, p := range d.Projects {
+			fmt.Fprintf(&b, "  %s: %d sessions, %d messages\n", p.Project, p.Sessions, p.Messages)
+		}
+	}
+	if len(d.Failures) > 0 {
+		b.WriteString("\nFailed schedule runs:\n")
+		for _, f := range d.Failures {
+			fmt.Fprintf(&b, "  %s %s: %s\n", f.Time.Local().Format("2006-01-02 15:04"), f.Schedule, f.Error)
+		}
+	}
+	if len(d.Waiting) > 0 {
+		b.WriteString("\nWaiting for you:\n")
+		for _, w := range d.Waiting {
+			what := "a question"
+			if w.Kind == "permission" {
+				what = "permission"
+				if w.Tool != "" {
+					what += " to use " + w.Tool
+				}
+			} else if w.Detail != "" {
+				what += ": " + w.Detail
+			}
+			fmt.Fprintf(&b, "  %s needs %s\n", w.SessionID, what)
+		}
+	}
+	return b.String()
+}
+
+// sendMail sends a plain-text message through the SMTP server
+func sendMail(cfg SMTPConfig, to []string, subject string, body string) error {
+	port := cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", cfg.From, strings.Join(to, ", "), subject, time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	if port != 465 {
+		return smtp.SendMail(addr, auth, cfg.From, to, []byte(msg.String()))
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 15 * time.Second}, "tcp", addr, &tls.Config{ServerName: cfg.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write([]byte(msg.String())); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// sendDigests mails everyone's digest to digest.to and, with perUser, each user their own
+// Users whose period was empty aren't mailed; the first error is returned after trying everyone
+func sendDigests(cfg DigestConfig) ([]string, error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+	now := time.Now()
+	var sent []string
+	var firstErr error
+	send := func(user *User, to []string) {
+		d, err := buildDigest(user, cfg.Period, now)
+		if err == nil && user != nil && d.empty() {
+			return
+		}
+		if err == nil {
+			err = sendMail(cfg.SMTP, to, d.subject(), d.text())
+		}
+		if err != nil {
+			log.Printf("[Digest] Failed to mail %s: %v", strings.Join(to, ", "), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		sent = append(sent, to...)
+	}
+	if len(cfg.To) > 0 {
+		send(nil, cfg.To)
+	}
+	if cfg.PerUser && authEnabled() {
+		authManager.usersMu.RLock()
+		users := append([]User(nil), authManager.users...)
+		authManager.usersMu.RUnlock()
+		for i := range users {
+			if users[i].Email != "" {
+				send(&users[i], []string{users[i].Email})
+			}
+		}
+	}
+	log.Printf("[Digest] Sent the %s digest to %d recipients", cfg.Period, len(sent))
+	return sent, firstErr
+}
+
+// StartDigestScheduler mails digests whenever digest.schedule matches, checked at the top of every minute
+func StartDigestScheduler() {
+	digestSchedOnce.Do(func() {
+		go func() {
+			for {
+				now := time.Now()
+				tick := now.Truncate(time.Minute).Add(time.Minute)
+				time.Sleep(tick.Sub(now))
+				cfg := getServerConfig().Digest
+				if cfg.Period == "" {
+					continue
+				}
+				cron, err := parseCron(cfg.schedule())
+				if err != nil || !cron.matches(tick) {
+					continue
+				}
+				go sendDigests(cfg)
+			}
+		}()
+	})
+}
+
+// GetDigest handles GET /api/digest
+// Returns the digest the user would be mailed; admins get everyone's
+// Query parameters:
+//   - period: daily or weekly (default: digest.period, or daily)
+func GetDigest(c *gin.Context) {
+	period := c.DefaultQuery("period", getServerConfig().Digest.Period)
+	if period == "" {
+		period = "daily"
+	}
+	if period != "daily" && period != "weekly" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "period must be daily or weekly")
+		return
+	}
+	d, err := buildDigest(currentUser(c), period, time.Now())
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, d)
+}
+
+// SendDigest handles POST /api/digest/send, mailing the configured digests now
+func SendDigest(c *gin.Context) {
+	cfg := getServerConfig().Digest
+	if cfg.Period == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Digests are not configured")
+		return
+	}
+	sent, err := sendDigests(cfg)
+	if err != nil {
+		respondErrorDetails(c, http.StatusBadGateway, ErrUpstream, "Failed to send digest", err.Error())
+		return
+	}
+	if sent == nil {
+		sent = []string{}
+	}
+	c.JSON(http.StatusOK, DigestSendResponse{Recipients: sent})
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// probedFlags are the claude CLI flags the argument builders depend on
+var probedFlags = []string{
+	"--print",
+	"--output-format",
+	"--verbose",
+	"--model",
+	"--resume",
+	"--continue",
+	"--permission-mode",
+	"--dangerously-skip-permissions",
+	"--files",
+	"--mcp-config",
+	"--strict-mcp-config",
+	"--input-format",
+}
+
+// helpFlagRegex matches long options in `claude --help` output
+var helpFlagRegex = regexp.MustCompile(`--[a-z][a-z0-9-]*`)
+
+// CLICapabilities is what probing the claude binary found
+type CLICapabilities struct {
+	Path      string          `json:"path,omitempty"`
+	Version   string          `json:"version,omitempty"`
+	Flags     map[string]bool `json:"flags,omitempty"` // probedFlags -> listed in --help
+	Error     string          `json:"error,omitempty"`
+	CheckedAt time.Time       `json:"checkedAt"`
+}
+
+// DoctorCheck is one item of GET /api/server/doctor
+type DoctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"` // ok, warn, or error
+	Message string `json:"message"`
+}
+
+// DoctorReport is the response of GET /api/server/doctor
+type DoctorReport struct {
+	OK     bool            `json:"ok"` // no check is in error
+	Claude CLICapabilities `json:"claude"`
+	Checks []DoctorCheck   `json:"checks"`
+}
+
+var (
+	cliMu   sync.Mutex
+	cliCaps *CLICapabilities // nil until the first probe
+)
+
+// probeClaudeCLI locates claude, records its version, and lists which probedFlags its --help mentions
+func probeClaudeCLI() CLICapabilities {
+	caps := CLICapabilities{CheckedAt: time.Now()}
+	path, err := exec.LookPath("claude")
+	if err != nil {
+		caps.Error = "claude CLI not found on PATH"
+	} else {
+		caps.Path = path
+		ctx, cancel := context.WithTimeout(context.Background(), versionCmdLimit)
+		defer cancel()
+		if out, err := exec.CommandContext(ctx, path, "--version").Output(); err != nil {
+			caps.Error = fmt.Sprintf("claude --version failed: %v", err)
+		} else {
+			caps.Version = strings.TrimSpace(string(out))
+		}
+		if out, err := exec.CommandContext(ctx, path, "--help").Output(); err != nil {
+			if caps.Error == "" {
+				caps.Error = fmt.Sprintf("claude --help failed: %v", err)
+			}
+		} else {
+			listed := make(map[string]bool)
+			for _, flag := range helpFlagRegex.FindAllString(string(out), -1) {
+				listed[flag] = true
+			}
+			caps.Flags = make(map[string]bool, len(probedFlags))
+			for _, flag := range probedFlags {
+				caps.Flags[flag] = listed[flag]
+			}
+		}
+	}
+
+	cliMu.Lock()
+	cliCaps = &caps
+	cliMu.Unlock()
+	return caps
+}
+
+// ProbeClaudeCLI probes the claude binary at startup and logs what the server will adapt to
+func ProbeClaudeCLI() {
+	caps := probeClaudeCLI()
+	if caps.Error != "" {
+		log.Printf("[Doctor] %s", caps.Error)
+		return
+	}
+	var missing []string
+	for _, flag := range probedFlags {
+		if caps.Flags != nil && !caps.Flags[flag] {
+			missing = append(missing, flag)
+		}
+	}
+	log.Printf("[Doctor] claude %s at %s", caps.Version, caps.Path)
+	if len(missing) > 0 {
+		log.Printf("[Doctor] claude does not list %s; falling back where possible", strings.Join(missing, ", "))
+	}
+}
+
+// claudeSupports reports whether the local claude lists flag in its --help
+// Unknown (not probed yet, or the probe failed) counts as supported so arguments stay as they were
+func claudeSupports(flag string) bool {
+	cliMu.Lock()
+	defer cliMu.Unlock()
+	if cliCaps == nil || cliCaps.Flags == nil {
+		return true
+	}
+	return cliCaps.Flags[flag]
+}
+
+// imageArgs attaches images with --files, or as @path mentions in the prompt when the CLI has no --files
+// Images are only accepted for local runs, so the probed binary is the one that will see them
+func imageArgs(imagePaths []string, prompt string) ([]string, string) {
+	if len(imagePaths) == 0 {
+		return nil, prompt
+	}
+	if claudeSupports("--files") {
+		var args []string
+		for _, p := range imagePaths {
+			args = append(args, "--files", p)
+		}
+		return args, prompt
+	}
+	mentions := make([]string, len(imagePaths))
+	for i, p := range imagePaths {
+		mentions[i] = "@" + p
+	}
+	return nil, strings.TrimSpace(prompt + "\n\n" + strings.Join(mentions, " "))
+}
+
+// GetDoctor handles GET /api/server/doctor
+// Checks the claude CLI and the directories and tools the configuration depends on
+// Query parameters:
+//   - refresh: "true" to probe the claude binary again instead of using the last result
+func GetDoctor(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	cliMu.Lock()
+	cached := cliCaps
+	cliMu.Unlock()
+	var caps CLICapabilities
+	if cached == nil || c.Query("refresh") == "true" {
+		caps = probeClaudeCLI()
+	} else {
+		caps = *cached
+	}
+
+	report := DoctorReport{OK: true, Claude: caps}
+	add := func(name string, status string, message string) {
+		report.Checks = append(report.Checks, DoctorCheck{Name: name, Status: status, Message: message})
+		if status == "error" {
+			report.OK = false
+		}
+	}
+
+	switch {
+	case caps.Path == "":
+		add("claude", "error", caps.Error+"; install it with `npm install -g @anthropic-ai/claude-code`")
+	case caps.Error != "":
+		add("claude", "error", caps.Error)
+	default:
+		add("claude", "ok", fmt.Sprintf("%s at %s", caps.Version, caps.Path))
+		var missing []string
+		for _, flag := range probedFlags {
+			if !caps.Flags[flag] {
+				missing = append(missing, flag)
+			}
+		}
+		if len(missing) > 0 {
+			add("claudeFlags", "warn", "Not listed in claude --help: "+strings.Join(missing, ", "))
+		} else {
+			add("claudeFlags", "ok", "All flags used by the server are supported")
+		}
+	}
+
+	if info, err := os.Stat(getClaudeDir()); err != nil || !info.IsDir() {
+		add("claudeDir", "warn", fmt.Sprintf("%s not found; claude hasn't been run as this user yet", getClaudeDir()))
+	} else {
+		add("claudeDir", "ok", getClaudeDir())
+	}
+
+	if _, err := exec.LookPath("script"); err != nil {
+		add("script", "error", "script (util-linux) not found; WebSocket chats need it")
+	} else {
+		add("script", "ok", "found")
+	}
+
+	cfg := getServerConfig()
+	if len(cfg.RemoteHosts) > 0 {
+		if _, err := exec.LookPath("ssh"); err != nil {
+			add("ssh", "error", "ssh not found; remote hosts are configured")
+		} else {
+			add("ssh", "ok", fmt.Sprintf("%d remote host(s) configured", len(cfg.RemoteHosts)))
+		}
+	}
+	for _, tool := range []string{"docker", "devcontainer"} {
+		configured := 0
+		for _, b := range cfg.Backends {
+			if b.Type == tool {
+				configured++
+			}
+		}
+		if configured == 0 {
+			continue
+		}
+		if _, err := exec.LookPath(tool); err != nil {
+			add(tool, "error", fmt.Sprintf("%s not found; %d backend(s) use it", tool, configured))
+		} else {
+			add(tool, "ok", fmt.Sprintf("%d backend(s) configured", configured))
+		}
+	}
+
+	for _, a := range cfg.AgentCLIs {
+		if path, err := exec.LookPath(a.Command[0]); err != nil {
+			add("agent:"+a.Name, "error", fmt.Sprintf("%s not found", a.Command[0]))
+		} else {
+			add("agent:"+a.Name, "ok", path)
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// draftTextLimit bounds a draft's text
+	draftTextLimit = 256 << 10
+	// draftAttachmentLimit bounds a draft's attachments
+	draftAttachmentLimit = 20
+)
+
+// SessionDraft is a prompt not sent yet, shared between the devices working on a session
+type SessionDraft struct {
+	Text        string           `json:"text"`
+	Attachments []UploadResponse `json:"attachments"`      // uploads, as POST /api/upload returned them
+	Device      string           `json:"device,omitempty"` // set by the client so a device can ignore its own updates
+	UpdatedAt   string           `json:"updatedAt,omitempty"`
+}
+
+// Drafts are kept until sent (saved empty) or their session is deleted
+var (
+	draftsMu     sync.Mutex
+	draftStore   map[string]SessionDraft // session ID -> draft
+	draftsLoaded bool
+)
+
+func draftsPath() string {
+	return serverDataPath("drafts.json")
+}
+
+// loadDrafts reads the draft store once; caller must hold draftsMu
+func loadDrafts() error {
+	if draftsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(draftsPath(), &draftStore); err != nil {
+		return err
+	}
+	if draftStore == nil {
+		draftStore = make(map[string]SessionDraft)
+	}
+	draftsLoaded = true
+	return nil
+}
+
+// saveDraft stores a session's draft, or drops it when it is empty
+func saveDraft(sessionID string, draft SessionDraft) error {
+	draftsMu.Lock()
+	defer draftsMu.Unlock()
+	if err := loadDrafts(); err != nil {
+		return err
+	}
+	if draft.Text == "" && len(draft.Attachments) == 0 {
+		if _, ok := draftStore[sessionID]; !ok {
+			return nil
+		}
+		delete(draftStore, sessionID)
+	} else {
+		draftStore[sessionID] = draft
+	}
+	return writeJSONFileAtomicMode(draftsPath(), draftStore, 0600)
+}
+
+// forgetDraft drops a deleted session's draft
+func forgetDraft(sessionID string) {
+	if err := saveDraft(sessionID, SessionDraft{}); err != nil {
+		log.Printf("[Drafts] Failed to save drafts: %v", err)
+	}
+}
+
+// draftAttachmentPaths returns the uploads attached to drafts, which upload cleanup keeps
+func draftAttachmentPaths() map[string]bool {
+	draftsMu.Lock()
+	defer draftsMu.Unlock()
+	paths := make(map[string]bool)
+	if err := loadDrafts(); err != nil {
+		log.Printf("[Drafts] Failed to load drafts: %v", err)
+		return paths
+	}
+	for _, draft := range draftStore {
+		for _, a := range draft.Attachments {
+			paths[filepath.Clean(a.FilePath)] = true
+		}
+	}
+	return paths
+}
+
+// GetDraft handles GET /api/session/:id/draft
+// Returns an empty draft when there is none
+func GetDraft(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	draftsMu.Lock()
+	err := loadDrafts()
+	draft := draftStore[sessionID]
+	draftsMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load drafts", err.Error())
+		return
+	}
+	if draft.Attachments == nil {
+		draft.Attachments = []UploadResponse{}
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// PutDraft handles PUT /api/session/:id/draft
+// Replaces the session's draft and sends it as a draft event on the state channel;
+// an empty text without attachments clears it, which clients do once the prompt is sent
+func PutDraft(c *gin.Context) {
+	sessionID := c.Param("id")
+	user := currentUser(c)
+	if !userCanAccessSession(user, sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var draft SessionDraft
+	if err := c.ShouldBindJSON(&draft); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if len(draft.Text) > draftTextLimit {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("drafts are limited to %d KB", draftTextLimit>>10))
+		return
+	}
+	if len(draft.Attachments) > draftAttachmentLimit {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("drafts are limited to %d attachments", draftAttachmentLimit))
+		return
+	}
+	for _, a := range draft.Attachments {
+		if !userCanAttachFile(user, a.FilePath) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Cannot attach %s", a.FilePath))
+			return
+		}
+		if _, err := os.Stat(a.FilePath); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("Attachment no longer exists: %s", a.FileName))
+			return
+		}
+	}
+	if draft.Attachments == nil {
+		draft.Attachments = []UploadResponse{}
+	}
+	draft.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := saveDraft(sessionID, draft); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save draft", err.Error())
+		return
+	}
+	broadcastSessionEvent(sessionID, "draft", map[string]interface{}{"draft": draft})
+	c.JSON(http.StatusOK, draft)
+}
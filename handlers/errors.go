@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"os/exec"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrorCode is a machine-readable error identifier; clients branch on it instead of the message
+type ErrorCode string
+
+const (
+	ErrInvalidRequest    ErrorCode = "INVALID_REQUEST"    // 400: malformed body or parameters
+	ErrUnauthorized      ErrorCode = "UNAUTHORIZED"       // 401: not signed in
+	ErrForbidden         ErrorCode = "FORBIDDEN"          // 403: signed in but not allowed
+	ErrPathForbidden     ErrorCode = "PATH_FORBIDDEN"     // 403: path outside the allowed roots
+	ErrNotFound          ErrorCode = "NOT_FOUND"          // 404
+	ErrSessionNotFound   ErrorCode = "SESSION_NOT_FOUND"  // 404: unknown (or another user's) session
+	ErrConflict          ErrorCode = "CONFLICT"           // 409: already exists or changed concurrently
+	ErrSessionBusy       ErrorCode = "SESSION_BUSY"       // 409: the session is already processing a request
+	ErrFileLocked        ErrorCode = "FILE_LOCKED"        // 423: another editor holds the file's lock; details has the lock
+	ErrPayloadTooLarge   ErrorCode = "PAYLOAD_TOO_LARGE"  // 413
+	ErrUnsupportedMedia  ErrorCode = "UNSUPPORTED_MEDIA"  // 415: binary file or disallowed upload type
+	ErrValidationFailed  ErrorCode = "VALIDATION_FAILED"  // 422: well-formed but rejected; details lists the problems
+	ErrBudgetExceeded    ErrorCode = "BUDGET_EXCEEDED"    // 429: spending limit reached
+	ErrRateLimited       ErrorCode = "RATE_LIMITED"       // 429
+	ErrInternal          ErrorCode = "INTERNAL"           // 500
+	ErrCLINotFound       ErrorCode = "CLI_NOT_FOUND"      // 500: the claude binary isn't installed or on PATH
+	ErrCLIFailed         ErrorCode = "CLI_FAILED"         // stream event: claude (or a plugin command) exited with an error
+	ErrRemoteUnavailable ErrorCode = "REMOTE_UNAVAILABLE" // 502: remote host or container unreachable
+	ErrUpstream          ErrorCode = "UPSTREAM_ERROR"     // 502: an external service failed
+	ErrUnavailable       ErrorCode = "UNAVAILABLE"        // 503: feature disabled or not configured
+	ErrResourceBusy      ErrorCode = "RESOURCE_BUSY"      // 503: the host is short of CPU, memory, or disk; details has the readings
+)
+
+// errorCodes lists every ErrorCode for the OpenAPI spec
+var errorCodes = []ErrorCode{
+	ErrInvalidRequest, ErrUnauthorized, ErrForbidden, ErrPathForbidden, ErrNotFound, ErrSessionNotFound,
+	ErrConflict, ErrSessionBusy, ErrFileLocked, ErrPayloadTooLarge, ErrUnsupportedMedia, ErrValidationFailed,
+	ErrBudgetExceeded, ErrRateLimited, ErrInternal, ErrCLINotFound, ErrCLIFailed, ErrRemoteUnavailable,
+	ErrUpstream, ErrUnavailable, ErrResourceBusy,
+}
+
+// APIError is the body of every error response
+type APIError struct {
+	Code      ErrorCode   `json:"code"`
+	Message   string      `json:"message"`
+	Details   interface{} `json:"details,omitempty"` // usually a string; a list for validation failures
+	RequestID string      `json:"requestId,omitempty"`
+	Error     string      `json:"error"` // same as Message, for clients of the older {"error": "..."} shape
+}
+
+// NewAPIError builds the error envelope for the current request
+func NewAPIError(c *gin.Context, code ErrorCode, message string, details interface{}) APIError {
+	message = localize(c, message)
+	return APIError{Code: code, Message: message, Details: details, RequestID: requestID(c), Error: message}
+}
+
+// respondError writes an error response
+func respondError(c *gin.Context, status int, code ErrorCode, message string) {
+	status, code, message = bodyLimitError(c, status, code, message)
+	c.JSON(status, NewAPIError(c, code, message, nil))
+}
+
+// respondErrorDetails writes an error response with details, typically err.Error()
+func respondErrorDetails(c *gin.Context, status int, code ErrorCode, message string, details interface{}) {
+	status, code, message = bodyLimitError(c, status, code, message)
+	c.JSON(status, NewAPIError(c, code, message, details))
+}
+
+// abortError writes an error response and stops the handler chain (for middleware)
+func abortError(c *gin.Context, status int, code ErrorCode, message string) {
+	c.AbortWithStatusJSON(status, NewAPIError(c, code, message, nil))
+}
+
+// codedError carries the code and status a shared check (e.g. checkBudget) wants reported
+type codedError struct {
+	status  int
+	code    ErrorCode
+	err     error
+	details interface{}
+}
+
+func (e *codedError) Error() string { return e.err.Error() }
+func (e *codedError) Unwrap() error { return e.err }
+
+// withCode attaches an API error code and HTTP status to err
+func withCode(status int, code ErrorCode, err error) error {
+	return &codedError{status: status, code: code, err: err}
+}
+
+// withDetails is withCode with details for the error response
+func withDetails(status int, code ErrorCode, err error, details interface{}) error {
+	return &codedError{status: status, code: code, err: err, details: details}
+}
+
+// respondCheckError writes err with the status and code attached by withCode, or 400 INVALID_REQUEST
+func respondCheckError(c *gin.Context, err error) {
+	var coded *codedError
+	if errors.As(err, &coded) {
+		respondErrorDetails(c, coded.status, coded.code, err.Error(), coded.details)
+		return
+	}
+	respondError(c, http.StatusBadRequest, errorCodeFor(err, ErrInvalidRequest), err.Error())
+}
+
+// errorCodeFor classifies a Go error, falling back to fallback
+func errorCodeFor(err error, fallback ErrorCode) ErrorCode {
+	var coded *codedError
+	switch {
+	case errors.As(err, &coded):
+		return coded.code
+	case errors.Is(err, exec.ErrNotFound):
+		return ErrCLINotFound
+	case errors.Is(err, os.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, os.ErrPermission):
+		return ErrPathForbidden
+	}
+	return fallback
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// extractMaxFiles bounds the entries of an extracted archive
+const extractMaxFiles = 20000
+
+// ExtractedFile is a file written by POST /api/upload/extract
+type ExtractedFile struct {
+	Path string `json:"path"` // relative to the destination
+	Size int64  `json:"size"`
+}
+
+// ExtractResponse is the response of POST /api/upload/extract
+type ExtractResponse struct {
+	Path    string          `json:"path"`
+	Format  string          `json:"format"` // zip, tar, or tar.gz
+	Files   []ExtractedFile `json:"files"`
+	Dirs    int             `json:"dirs"`
+	Bytes   int64           `json:"bytes"`
+	Skipped []string        `json:"skipped"` // existing files left alone, links, and special files
+}
+
+// archiveEntry is one entry of an uploaded archive
+type archiveEntry struct {
+	name string // slash-separated, as stored
+	mode fs.FileMode
+	size int64
+	time time.Time
+	open func() (io.ReadCloser, error)
+}
+
+// maxArchiveSize returns the configured archive size limit in bytes
+func maxArchiveSize() int64 {
+	return int64(getServerConfig().Uploads.MaxArchiveMB) * 1024 * 1024
+}
+
+// archiveFormat tells an archive's format from its first bytes
+func archiveFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return "zip"
+	case bytes.HasPrefix(head, []byte{0x1f, 0x8b}):
+		return "tar.gz"
+	case len(head) > 262 && string(head[257:262]) == "ustar":
+		return "tar"
+	}
+	return ""
+}
+
+// walkArchive calls fn for each entry of the archive in f
+func walkArchive(f *os.File, size int64, format string, fn func(archiveEntry) error) error {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if format == "zip" {
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("corrupt zip archive: %w", err))
+		}
+		for _, zf := range zr.File {
+			zf := zf
+			entry := archiveEntry{name: zf.Name, mode: zf.Mode(), size: int64(zf.UncompressedSize64), time: zf.Modified, open: zf.Open}
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var r io.Reader = bufio.NewReader(f)
+	if format == "tar.gz" {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("corrupt gzip archive: %w", err))
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("corrupt tar archive: %w", err))
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		entry := archiveEntry{name: header.Name, mode: header.FileInfo().Mode(), size: header.Size, time: header.ModTime,
+			open: func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveEntryPath returns where an entry goes relative to the destination, with strip leading directories removed
+// ok is false for an entry that strip removes entirely; a name that escapes the destination is an error
+func archiveEntryPath(name string, strip int) (rel string, ok bool, err error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || filepath.VolumeName(name) != "" || strings.Contains(name, "\x00") {
+		return "", false, fmt.Errorf("absolute path in archive: %s", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false, fmt.Errorf("path traversal in archive: %s", name)
+		}
+	}
+	parts := strings.Split(strings.Trim(path.Clean(name), "/"), "/")
+	if len(parts) <= strip || parts[0] == "." {
+		return "", false, nil
+	}
+	return filepath.FromSlash(strings.Join(parts[strip:], "/")), true, nil
+}
+
+// checkArchive validates every entry's path and the archive's totals before anything is written
+func checkArchive(f *os.File, size int64, format string, strip int) error {
+	files, total := 0, int64(0)
+	maxBytes := int64(getServerConfig().Uploads.MaxExtractedMB) * 1024 * 1024
+	return walkArchive(f, size, format, func(entry archiveEntry) error {
+		if _, _, err := archiveEntryPath(entry.name, strip); err != nil {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, err)
+		}
+		files++
+		total += entry.size
+		if files > extractMaxFiles {
+			return withCode(http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Errorf("archive has more than %d entries", extractMaxFiles))
+		}
+		if total > maxBytes {
+			return withCode(http.StatusRequestEntityTooLarge, ErrPayloadTooLarge,
+				fmt.Errorf("archive unpacks to more than %dMB", getServerConfig().Uploads.MaxExtractedMB))
+		}
+		return nil
+	})
+}
+
+// withinDir reports whether path is dir or below it
+func withinDir(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// extractArchive writes the archive's directories and regular files under dest, which has its symlinks resolved
+func extractArchive(f *os.File, size int64, format, dest string, strip int, overwrite bool) (*ExtractResponse, error) {
+	resp := &ExtractResponse{Path: dest, Format: format, Files: []ExtractedFile{}, Skipped: []string{}}
+	maxBytes := int64(getServerConfig().Uploads.MaxExtractedMB) * 1024 * 1024
+	err := walkArchive(f, size, format, func(entry archiveEntry) error {
+		rel, ok, err := archiveEntryPath(entry.name, strip)
+		if err != nil || !ok {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if !entry.mode.IsDir() && !entry.mode.IsRegular() {
+			// Links could point outside the destination; devices and pipes have no place in a project
+			resp.Skipped = append(resp.Skipped, filepath.ToSlash(rel))
+			return nil
+		}
+		// A directory that already exists may be a symlink leading out of the destination,
+		// so the part that exists is resolved before anything is created under it
+		parent := filepath.Dir(target)
+		if entry.mode.IsDir() {
+			parent = target
+		}
+		if resolved, err := resolveExistingPath(parent); err != nil || !withinDir(dest, resolved) {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s leads outside the destination", rel))
+		}
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return err
+		}
+		if entry.mode.IsDir() {
+			resp.Dirs++
+			return nil
+		}
+		if info, err := os.Lstat(target); err == nil {
+			if !overwrite || !info.Mode().IsRegular() {
+				resp.Skipped = append(resp.Skipped, filepath.ToSlash(rel))
+				return nil
+			}
+		}
+
+		src, err := entry.open()
+		if err != nil {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s: %w", entry.name, err))
+		}
+		defer src.Close()
+		tmp, err := os.CreateTemp(parent, ".extract-*")
+		if err != nil {
+			return err
+		}
+		// The sizes archives declare aren't trusted; one byte past the remaining budget is enough to know
+		written, err := io.Copy(tmp, io.LimitReader(src, maxBytes-resp.Bytes+1))
+		if err == nil {
+			err = tmp.Chmod(entry.mode.Perm() | 0600)
+		}
+		if closeErr := tmp.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil && resp.Bytes+written > maxBytes {
+			err = withCode(http.StatusRequestEntityTooLarge, ErrPayloadTooLarge,
+				fmt.Errorf("archive unpacks to more than %dMB", getServerConfig().Uploads.MaxExtractedMB))
+		}
+		if err == nil {
+			err = os.Rename(tmp.Name(), target)
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			if errors.Is(err, zip.ErrChecksum) || errors.Is(err, zip.ErrFormat) || errors.Is(err, gzip.ErrChecksum) {
+				err = withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s: %w", entry.name, err))
+			}
+			return err
+		}
+		if !entry.time.IsZero() {
+			os.Chtimes(target, entry.time, entry.time)
+		}
+		resp.Files = append(resp.Files, ExtractedFile{Path: filepath.ToSlash(rel), Size: written})
+		resp.Bytes += written
+		return nil
+	})
+	return resp, err
+}
+
+// ExtractUpload handles POST /api/upload/extract
+// Unpacks a zip, tar, or tar.gz archive (multipart field "file", or the raw body) into a local directory,
+// which is created if missing. Every entry is checked before anything is written: an absolute path or ".."
+// refuses the whole archive. Links and special files are skipped, and so are existing files unless overwrite is set
+// Query parameters:
+//   - path: destination directory (required)
+//   - overwrite: replace existing files
+//   - strip: leading path components to remove from each entry, e.g. 1 for a repository's top directory
+func ExtractUpload(c *gin.Context) {
+	user := currentUser(c)
+	dest := c.Query("path")
+	if dest == "" || !filepath.IsAbs(dest) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "An absolute destination path is required")
+		return
+	}
+	if isRemotePath(dest) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Archives can't be extracted on remote hosts")
+		return
+	}
+	dest = filepath.Clean(dest)
+	if !userCanAccessPath(user, dest) {
+		denyPath(c, dest)
+		return
+	}
+	overwrite, _ := strconv.ParseBool(c.Query("overwrite"))
+	strip, err := strconv.Atoi(c.DefaultQuery("strip", "0"))
+	if err != nil || strip < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid strip parameter")
+		return
+	}
+	if info, err := os.Stat(dest); err == nil && !info.IsDir() {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Destination is a file, not a directory")
+		return
+	}
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				respondError(c, http.StatusBadRequest, ErrInvalidRequest, "No file provided")
+				return
+			}
+			if part.FormName() == "file" {
+				body = part
+				break
+			}
+			part.Close()
+		}
+	}
+
+	// zip needs random access, so the archive is spooled to disk first
+	tmp, err := os.CreateTemp("", "greyzone-extract-*")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to save archive")
+		return
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	limit := maxArchiveSize()
+	size, err := io.Copy(tmp, io.LimitReader(body, limit+1))
+	if err != nil || size > limit {
+		var maxBytes *http.MaxBytesError
+		switch {
+		case size > limit || errors.As(err, &maxBytes):
+			respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("Archive too large (max %dMB)", getServerConfig().Uploads.MaxArchiveMB))
+		case c.Request.Context().Err() != nil:
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Upload interrupted")
+		default:
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save archive", err.Error())
+		}
+		return
+	}
+	head := make([]byte, 512)
+	n, _ := tmp.ReadAt(head, 0)
+	format := archiveFormat(head[:n])
+	if format == "" {
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, "Not a zip, tar, or tar.gz archive")
+		return
+	}
+	if err := checkArchive(tmp, size, format, strip); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		if os.IsPermission(err) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to create directory: %v", err))
+		return
+	}
+	resolved, err := filepath.EvalSymlinks(dest)
+	if err != nil || !userCanAccessPath(user, resolved) {
+		denyPath(c, dest)
+		return
+	}
+	if withinDir(filepath.Clean(getServerDataDir()), resolved) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Archives can't be extracted into the server's data directory")
+		return
+	}
+	resp, err := extractArchive(tmp, size, format, resolved, strip, overwrite)
+	if err != nil {
+		// Files written before the failure stay; details says which
+		var coded *codedError
+		status, code := http.StatusInternalServerError, ErrInternal
+		if errors.As(err, &coded) {
+			status, code = coded.status, coded.code
+		} else if os.IsPermission(err) {
+			status, code = http.StatusForbidden, ErrPathForbidden
+		}
+		respondErrorDetails(c, status, code, err.Error(), resp)
+		return
+	}
+	resp.Path = dest
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// feedbackNoteLimit bounds a feedback note
+	feedbackNoteLimit = 4 << 10
+	// feedbackLabelLimit bounds the labels of one response and the length of each
+	feedbackLabelLimit    = 20
+	feedbackLabelMaxChars = 50
+)
+
+// MessageFeedback is a rating of one message of a session
+type MessageFeedback struct {
+	SessionID string   `json:"sessionId"`
+	UUID      string   `json:"uuid"`
+	Rating    string   `json:"rating,omitempty"` // up or down
+	Note      string   `json:"note,omitempty"`
+	Labels    []string `json:"labels"`
+	User      string   `json:"user,omitempty"` // who rated it when auth is enabled
+	UpdatedAt string   `json:"updatedAt"`
+
+	// Set by GET /api/feedback only: the text of the rated message
+	Text string `json:"text,omitempty"`
+}
+
+// FeedbackRequest is the body of POST /api/session/:id/messages/:uuid/feedback
+type FeedbackRequest struct {
+	Rating string   `json:"rating"` // up, down, or empty
+	Note   string   `json:"note"`
+	Labels []string `json:"labels"`
+}
+
+// FeedbackCounts are a session's rated messages, set on sessions by GET /api/sessions
+type FeedbackCounts struct {
+	Up      int `json:"up"`
+	Down    int `json:"down"`
+	Flagged int `json:"flagged"` // messages with a note or labels
+}
+
+// Feedback is kept until cleared or its session is deleted
+var (
+	feedbackMu     sync.Mutex
+	feedbackStore  map[string]map[string]MessageFeedback // session ID -> message uuid -> feedback
+	feedbackLoaded bool
+)
+
+func feedbackPath() string {
+	return serverDataPath("feedback.json")
+}
+
+// loadFeedback reads the feedback store once; caller must hold feedbackMu
+func loadFeedback() error {
+	if feedbackLoaded {
+		return nil
+	}
+	if err := loadJSONFile(feedbackPath(), &feedbackStore); err != nil {
+		return err
+	}
+	if feedbackStore == nil {
+		feedbackStore = make(map[string]map[string]MessageFeedback)
+	}
+	feedbackLoaded = true
+	return nil
+}
+
+// saveFeedback stores a message's feedback, or drops it when it has no rating, note, or labels
+func saveFeedback(fb MessageFeedback) error {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	if err := loadFeedback(); err != nil {
+		return err
+	}
+	if fb.Rating == "" && fb.Note == "" && len(fb.Labels) == 0 {
+		if _, ok := feedbackStore[fb.SessionID][fb.UUID]; !ok {
+			return nil
+		}
+		delete(feedbackStore[fb.SessionID], fb.UUID)
+		if len(feedbackStore[fb.SessionID]) == 0 {
+			delete(feedbackStore, fb.SessionID)
+		}
+	} else {
+		if feedbackStore[fb.SessionID] == nil {
+			feedbackStore[fb.SessionID] = make(map[string]MessageFeedback)
+		}
+		feedbackStore[fb.SessionID][fb.UUID] = fb
+	}
+	return writeJSONFileAtomicMode(feedbackPath(), feedbackStore, 0600)
+}
+
+// forgetFeedback drops a deleted session's feedback
+func forgetFeedback(sessionID string) {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	if err := loadFeedback(); err != nil || feedbackStore[sessionID] == nil {
+		return
+	}
+	delete(feedbackStore, sessionID)
+	if err := writeJSONFileAtomicMode(feedbackPath(), feedbackStore, 0600); err != nil {
+		log.Printf("[Feedback] Failed to save feedback: %v", err)
+	}
+}
+
+// sessionFeedback returns a session's feedback, oldest first
+func sessionFeedback(sessionID string) ([]MessageFeedback, error) {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	if err := loadFeedback(); err != nil {
+		return nil, err
+	}
+	list := []MessageFeedback{}
+	for _, fb := range feedbackStore[sessionID] {
+		list = append(list, fb)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt < list[j].UpdatedAt })
+	return list, nil
+}
+
+// attachFeedback sets Feedback on sessions with rated messages
+func attachFeedback(sessions []Session) {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	if err := loadFeedback(); err != nil {
+		log.Printf("[Feedback] Failed to load feedback: %v", err)
+		return
+	}
+	for i := range sessions {
+		if messages := feedbackStore[sessions[i].SessionID]; len(messages) > 0 {
+			sessions[i].Feedback = feedbackCounts(messages)
+		}
+	}
+}
+
+func feedbackCounts(messages map[string]MessageFeedback) *FeedbackCounts {
+	counts := &FeedbackCounts{}
+	for _, fb := range messages {
+		switch fb.Rating {
+		case "up":
+			counts.Up++
+		case "down":
+			counts.Down++
+		}
+		if fb.Note != "" || len(fb.Labels) > 0 {
+			counts.Flagged++
+		}
+	}
+	return counts
+}
+
+// matchesFeedbackFilter reports whether a session's feedback passes GET /api/sessions?feedback=
+func matchesFeedbackFilter(counts *FeedbackCounts, filter string) bool {
+	switch filter {
+	case "":
+		return true
+	case "up":
+		return counts != nil && counts.Up > 0
+	case "down":
+		return counts != nil && counts.Down > 0
+	case "flagged":
+		return counts != nil && counts.Flagged > 0
+	default: // any
+		return counts != nil
+	}
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// messageText returns the text of a message in a session transcript, "" when it has none
+func messageText(path string, uuid string) string {
+	msg, _, err := historyMessage(path, uuid)
+	if err != nil {
+		return ""
+	}
+	content, _ := json.Marshal(msg.Message["content"])
+	return transcriptText(content)
+}
+
+// SetMessageFeedback handles POST /api/session/:id/messages/:uuid/feedback
+// Replaces the message's rating, note, and labels; sending none of them clears its feedback
+func SetMessageFeedback(c *gin.Context) {
+	sessionID, uuid := c.Param("id"), c.Param("uuid")
+	user := currentUser(c)
+	path := ""
+	if userCanAccessSession(user, sessionID) {
+		path = findSessionFile(sessionID)
+	}
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req FeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if req.Rating != "" && req.Rating != "up" && req.Rating != "down" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "rating must be up, down, or empty")
+		return
+	}
+	req.Note = strings.TrimSpace(req.Note)
+	if len(req.Note) > feedbackNoteLimit {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("notes are limited to %d KB", feedbackNoteLimit>>10))
+		return
+	}
+	labels := []string{}
+	seen := make(map[string]bool)
+	for _, label := range req.Labels {
+		label = strings.TrimSpace(label)
+		if label == "" || seen[label] {
+			continue
+		}
+		if len([]rune(label)) > feedbackLabelMaxChars {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("labels are limited to %d characters", feedbackLabelMaxChars))
+			return
+		}
+		seen[label] = true
+		labels = append(labels, label)
+	}
+	if len(labels) > feedbackLabelLimit {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("at most %d labels", feedbackLabelLimit))
+		return
+	}
+
+	idx := historyIndexFor(path)
+	idx.mu.Lock()
+	err := idx.update(path)
+	_, found := idx.positions[uuid]
+	idx.mu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	if !found {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Message not found")
+		return
+	}
+
+	fb := MessageFeedback{
+		SessionID: sessionID,
+		UUID:      uuid,
+		Rating:    req.Rating,
+		Note:      req.Note,
+		Labels:    labels,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if user != nil {
+		fb.User = user.Username
+	}
+	if err := saveFeedback(fb); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save feedback", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, fb)
+}
+
+// GetSessionFeedback handles GET /api/session/:id/feedback
+// Returns the feedback on a session's messages, so a client can show it next to its history
+func GetSessionFeedback(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	list, err := sessionFeedback(sessionID)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load feedback", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessionId": sessionID, "feedback": list})
+}
+
+// ExportFeedback handles GET /api/feedback
+// Returns the feedback on sessions the user can see with the rated messages' text, newest first
+// Query parameters:
+//   - rating: up or down
+//   - label: only feedback with this label
+//   - format: json (default) or jsonl, downloaded as feedback.jsonl
+func ExportFeedback(c *gin.Context) {
+	user := currentUser(c)
+	rating, label := c.Query("rating"), c.Query("label")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "jsonl" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "format must be json or jsonl")
+		return
+	}
+
+	feedbackMu.Lock()
+	err := loadFeedback()
+	list := []MessageFeedback{}
+	for sessionID, messages := range feedbackStore {
+		if !userCanAccessSession(user, sessionID) {
+			continue
+		}
+		for _, fb := range messages {
+			if rating != "" && fb.Rating != rating {
+				continue
+			}
+			if label != "" && !hasLabel(fb.Labels, label) {
+				continue
+			}
+			list = append(list, fb)
+		}
+	}
+	feedbackMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load feedback", err.Error())
+		return
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].UpdatedAt > list[j].UpdatedAt })
+
+	paths := make(map[string]string)
+	for i := range list {
+		path, ok := paths[list[i].SessionID]
+		if !ok {
+			path = findSessionFile(list[i].SessionID)
+			paths[list[i].SessionID] = path
+		}
+		if path != "" {
+			list[i].Text = messageText(path, list[i].UUID)
+		}
+	}
+
+	if format == "jsonl" {
+		c.Header("Content-Disposition", `attachment; filename="feedback.jsonl"`)
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Status(http.StatusOK)
+		enc := json.NewEncoder(c.Writer)
+		for _, fb := range list {
+			enc.Encode(fb)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"feedback": list})
+}
@@ -0,0 +1,296 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultFileLockTTL is how long a lock lasts unless it is renewed
+	defaultFileLockTTL = 2 * time.Minute
+	// maxFileLockTTL bounds ttlSeconds, so a closed tab can't keep a file locked for long
+	maxFileLockTTL = time.Hour
+)
+
+// FileLock is an advisory lock on a local file, taken by an editor before it writes the file
+type FileLock struct {
+	ID         string `json:"id,omitempty"` // only shown to the lock's owner; pass as lockId to write, renew, or release
+	Path       string `json:"path"`
+	Owner      string `json:"owner,omitempty"`
+	Username   string `json:"username,omitempty"`
+	Holder     string `json:"holder,omitempty"` // the client's label, e.g. "tab 2" or "agent"
+	AcquiredAt string `json:"acquiredAt"`
+	ExpiresAt  string `json:"expiresAt"`
+
+	expires time.Time
+}
+
+// FileLockRequest is the body of POST /api/file/lock
+type FileLockRequest struct {
+	Path       string `json:"path"`
+	LockID     string `json:"lockId,omitempty"`     // renews a lock held with this ID
+	TTLSeconds int    `json:"ttlSeconds,omitempty"` // default 120, max 3600
+	Holder     string `json:"holder,omitempty"`
+}
+
+// FileUnlockRequest is the body of POST /api/file/unlock
+type FileUnlockRequest struct {
+	Path   string `json:"path"`
+	LockID string `json:"lockId,omitempty"`
+	// Force releases a lock without its ID: the lock's owner, e.g. for a closed tab, or an admin
+	Force bool `json:"force,omitempty"`
+}
+
+// FileLocksResponse is the response of GET /api/file/locks
+type FileLocksResponse struct {
+	Locks []FileLock `json:"locks"`
+}
+
+// Locks are kept in memory; a restart releases them
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = make(map[string]*FileLock) // resolved path -> lock
+)
+
+// fileLockPath resolves the local file a lock or write applies to, following symlinks as writes do
+func fileLockPath(user *User, path string) (string, error) {
+	if path == "" || !filepath.IsAbs(path) {
+		return "", withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("An absolute path is required"))
+	}
+	if isRemotePath(path) {
+		return "", withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("Files on remote hosts can't be locked"))
+	}
+	if !userCanAccessPath(user, path) {
+		return "", withCode(http.StatusForbidden, ErrPathForbidden, fmt.Errorf("Path is outside your projects: %s", path))
+	}
+	clean := filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(clean); err == nil && resolved != clean {
+		if !userCanAccessPath(user, resolved) {
+			return "", withCode(http.StatusForbidden, ErrPathForbidden, fmt.Errorf("Path is outside your projects: %s", path))
+		}
+		clean = resolved
+	}
+	return clean, nil
+}
+
+// activeFileLock returns the unexpired lock on path; caller must hold fileLocksMu
+func activeFileLock(path string) *FileLock {
+	lock := fileLocks[path]
+	if lock != nil && time.Now().After(lock.expires) {
+		delete(fileLocks, path)
+		return nil
+	}
+	return lock
+}
+
+// publicFileLock is a copy of lock as user sees it, without the ID unless user owns it
+func publicFileLock(lock *FileLock, user *User) *FileLock {
+	view := *lock
+	if view.Owner != ownerID(user) {
+		view.ID = ""
+	}
+	return &view
+}
+
+// fileLockConflict returns the lock that keeps lockID's holder from writing path, nil when there is none
+func fileLockConflict(path, lockID string) *FileLock {
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+	lock := activeFileLock(path)
+	if lock == nil || lock.ID == lockID {
+		return nil
+	}
+	view := *lock
+	view.ID = ""
+	return &view
+}
+
+// releaseFileLock drops the lock on a path that no longer exists
+func releaseFileLock(path string) {
+	fileLocksMu.Lock()
+	delete(fileLocks, path)
+	fileLocksMu.Unlock()
+}
+
+// fileLockFor returns the lock on a local path as user sees it, nil when it isn't locked
+func fileLockFor(user *User, path string) *FileLock {
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+	if len(fileLocks) == 0 {
+		return nil
+	}
+	path = filepath.Clean(path)
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	lock := activeFileLock(path)
+	if lock == nil {
+		return nil
+	}
+	return publicFileLock(lock, user)
+}
+
+// fileLockedError is the error of a write or lock refused because another holder has the file
+func fileLockedError(c *gin.Context, lock *FileLock) {
+	respondErrorDetails(c, http.StatusLocked, ErrFileLocked, "File is locked for editing by "+fileLockHolder(lock), lock)
+}
+
+// fileLockHolder describes who holds a lock, e.g. "alice (tab 2)"
+func fileLockHolder(lock *FileLock) string {
+	switch {
+	case lock.Username != "" && lock.Holder != "":
+		return lock.Username + " (" + lock.Holder + ")"
+	case lock.Username != "":
+		return lock.Username
+	case lock.Holder != "":
+		return lock.Holder
+	}
+	return "another editor"
+}
+
+// toolFileLock returns the lock on the file a gated tool call of a run in workDir would change, nil when there is none
+// claude holds no lock, so any lock on the file is someone else's
+func toolFileLock(workDir string, input map[string]interface{}) *FileLock {
+	for _, key := range []string{"file_path", "notebook_path"} {
+		path, ok := input[key].(string)
+		if !ok || path == "" {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workDir, path)
+		}
+		return fileLockFor(nil, path)
+	}
+	return nil
+}
+
+// LockFile handles POST /api/file/lock
+// Takes or renews an advisory lock on a local file; writes without its lockId are refused until it is released or expires
+func LockFile(c *gin.Context) {
+	var req FileLockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	ttl := defaultFileLockTTL
+	if req.TTLSeconds < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid ttlSeconds")
+		return
+	} else if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+	if ttl > maxFileLockTTL {
+		ttl = maxFileLockTTL
+	}
+	user := currentUser(c)
+	path, err := fileLockPath(user, req.Path)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
+		return
+	}
+
+	fileLocksMu.Lock()
+	lock := activeFileLock(path)
+	if lock != nil && lock.ID != req.LockID {
+		view := *lock
+		fileLocksMu.Unlock()
+		view.ID = ""
+		fileLockedError(c, &view)
+		return
+	}
+	now := time.Now().UTC()
+	if lock == nil {
+		if req.LockID != "" {
+			fileLocksMu.Unlock()
+			respondError(c, http.StatusConflict, ErrConflict, "Lock expired or was released")
+			return
+		}
+		lock = &FileLock{ID: generateID(), Path: path, Owner: ownerID(user), AcquiredAt: now.Format(time.RFC3339)}
+		if user != nil {
+			lock.Username = user.Username
+		}
+		fileLocks[path] = lock
+	}
+	if req.Holder != "" {
+		lock.Holder = req.Holder
+	}
+	lock.expires = now.Add(ttl)
+	lock.ExpiresAt = lock.expires.Format(time.RFC3339)
+	view := *lock
+	fileLocksMu.Unlock()
+	c.JSON(http.StatusOK, view)
+}
+
+// UnlockFile handles POST /api/file/unlock
+// Releases a lock; releasing a file that isn't locked succeeds
+func UnlockFile(c *gin.Context) {
+	var req FileUnlockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	user := currentUser(c)
+	path, err := fileLockPath(user, req.Path)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	fileLocksMu.Lock()
+	defer fileLocksMu.Unlock()
+	lock := activeFileLock(path)
+	if lock == nil {
+		c.JSON(http.StatusOK, gin.H{"released": false})
+		return
+	}
+	if lock.ID != req.LockID {
+		if !req.Force || !userCanAccessOwner(user, lock.Owner) {
+			respondErrorDetails(c, http.StatusLocked, ErrFileLocked, "File is locked by another holder", publicFileLock(lock, user))
+			return
+		}
+	}
+	delete(fileLocks, path)
+	c.JSON(http.StatusOK, gin.H{"released": true})
+}
+
+// ListFileLocks handles GET /api/file/locks
+// Lists the active locks on files the user can access
+// Query parameters:
+//   - path: only locks on this file or inside this directory
+func ListFileLocks(c *gin.Context) {
+	user := currentUser(c)
+	prefix := c.Query("path")
+	if prefix != "" {
+		if !userCanAccessPath(user, prefix) {
+			denyPath(c, prefix)
+			return
+		}
+		prefix = filepath.Clean(prefix)
+	}
+	fileLocksMu.Lock()
+	locks := []FileLock{}
+	for path := range fileLocks {
+		lock := activeFileLock(path)
+		if lock == nil || !userCanAccessPath(user, path) {
+			continue
+		}
+		if prefix != "" && path != prefix && !strings.HasPrefix(path, prefix+string(filepath.Separator)) {
+			continue
+		}
+		locks = append(locks, *publicFileLock(lock, user))
+	}
+	fileLocksMu.Unlock()
+	sort.Slice(locks, func(i, j int) bool { return locks[i].Path < locks[j].Path })
+	c.JSON(http.StatusOK, FileLocksResponse{Locks: locks})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"os"
@@ -16,11 +17,12 @@ const maxFileSize = 1 * 1024 * 1024 // 1MB
 
 // FileItem represents a file or directory entry
 type FileItem struct {
-	Name     string `json:"name"`
-	Path     string `json:"path"`
-	Type     string `json:"type"` // "directory" or "file"
-	Size     int64  `json:"size"`
-	Modified int64  `json:"modified"` // Unix timestamp
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Type     string    `json:"type"` // "directory" or "file"
+	Size     int64     `json:"size"`
+	Modified int64     `json:"modified"` // Unix timestamp
+	Lock     *FileLock `json:"lock,omitempty"`
 }
 
 // DirectoryItem represents a directory entry
@@ -56,11 +58,33 @@ type ReadFileRequest struct {
 
 // ReadFileResponse represents the response for reading a file
 type ReadFileResponse struct {
-	Content  string `json:"content"`
-	Language string `json:"language"`
-	Path     string `json:"path"`
-	Name     string `json:"name"`
-	Size     int64  `json:"size"`
+	Content  string    `json:"content"`
+	Language string    `json:"language"`
+	Path     string    `json:"path"`
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	MtimeMs  int64     `json:"mtimeMs,omitempty"` // pass back as expectedMtimeMs to POST /api/file/write
+	Lock     *FileLock `json:"lock,omitempty"`
+}
+
+// WriteFileRequest represents the request body for writing a file
+type WriteFileRequest struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+	// ExpectedMtimeMs is the mtimeMs the file was read at; the write is refused if it changed since (0 = not checked)
+	ExpectedMtimeMs int64 `json:"expectedMtimeMs,omitempty"`
+	CreateOnly      bool  `json:"createOnly,omitempty"` // refuse to replace an existing file
+	CreateDirs      bool  `json:"createDirs,omitempty"` // create missing parent directories
+	// LockID is the lock from POST /api/file/lock; the write is refused while someone else holds the file's lock
+	LockID string `json:"lockId,omitempty"`
+}
+
+// WriteFileResponse represents the response for writing a file
+type WriteFileResponse struct {
+	Path    string `json:"path"`
+	Size    int64  `json:"size"`
+	MtimeMs int64  `json:"mtimeMs"`
+	Created bool   `json:"created"`
 }
 
 var langMap = map[string]string{
@@ -93,38 +117,50 @@ var langMap = map[string]string{
 func ListDirectories(c *gin.Context) {
 	var req ListDirectoriesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Default to $HOME if path is empty
 	dirPath := req.Path
+	user := currentUser(c)
+	if dirPath == "" && user != nil && len(user.ProjectRoots) > 0 {
+		dirPath = user.ProjectRoots[0]
+	}
 	if dirPath == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get home directory"})
+			respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to get home directory")
 			return
 		}
 		dirPath = homeDir
 	}
+	if !userCanAccessPath(user, dirPath) {
+		denyPath(c, dirPath)
+		return
+	}
+	if h, remotePath, ok := parseRemotePath(dirPath); ok {
+		listRemoteDirectories(c, user, h, remotePath)
+		return
+	}
 
 	// Check if path exists
 	info, err := os.Stat(dirPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Path does not exist"})
+			respondError(c, http.StatusNotFound, ErrNotFound, "Path does not exist")
 			return
 		}
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
 		return
 	}
 
 	if !info.IsDir() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is not a directory"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is not a directory")
 		return
 	}
 
@@ -132,17 +168,18 @@ func ListDirectories(c *gin.Context) {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
 		return
 	}
 
 	var directories []DirectoryItem
 
 	// Add parent directory (..) if not at root
-	if dirPath != "/" && dirPath != filepath.VolumeName(dirPath)+string(filepath.Separator) {
+	if dirPath != "/" && dirPath != filepath.VolumeName(dirPath)+string(filepath.Separator) &&
+		userCanAccessPath(user, filepath.Dir(dirPath)) {
 		parentPath := filepath.Dir(dirPath)
 		directories = append(directories, DirectoryItem{
 			Name: "..",
@@ -176,38 +213,50 @@ func ListDirectories(c *gin.Context) {
 func ListFiles(c *gin.Context) {
 	var req ListFilesRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
 		return
 	}
 
 	// Default to $HOME if path is empty
 	dirPath := req.Path
+	user := currentUser(c)
+	if dirPath == "" && user != nil && len(user.ProjectRoots) > 0 {
+		dirPath = user.ProjectRoots[0]
+	}
 	if dirPath == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get home directory"})
+			respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to get home directory")
 			return
 		}
 		dirPath = homeDir
 	}
+	if !userCanAccessPath(user, dirPath) {
+		denyPath(c, dirPath)
+		return
+	}
+	if h, remotePath, ok := parseRemotePath(dirPath); ok {
+		listRemoteFiles(c, h, remotePath)
+		return
+	}
 
 	// Check if path exists
 	info, err := os.Stat(dirPath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Path does not exist"})
+			respondError(c, http.StatusNotFound, ErrNotFound, "Path does not exist")
 			return
 		}
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
 		return
 	}
 
 	if !info.IsDir() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is not a directory"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is not a directory")
 		return
 	}
 
@@ -215,10 +264,10 @@ func ListFiles(c *gin.Context) {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
 		return
 	}
 
@@ -244,6 +293,7 @@ func ListFiles(c *gin.Context) {
 			Path:     fullPath,
 			Size:     fileInfo.Size(),
 			Modified: fileInfo.ModTime().Unix(),
+			Lock:     fileLockFor(user, fullPath),
 		}
 
 		if entry.IsDir() {
@@ -275,12 +325,20 @@ func ListFiles(c *gin.Context) {
 func ReadFile(c *gin.Context) {
 	var req ReadFileRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
 		return
 	}
 
 	if req.Path == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is required"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is required")
+		return
+	}
+	if !userCanAccessPath(currentUser(c), req.Path) {
+		denyPath(c, req.Path)
+		return
+	}
+	if h, remotePath, ok := parseRemotePath(req.Path); ok {
+		readRemoteFile(c, h, remotePath, req.Path)
 		return
 	}
 
@@ -288,25 +346,25 @@ func ReadFile(c *gin.Context) {
 	info, err := os.Stat(req.Path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "File does not exist"})
+			respondError(c, http.StatusNotFound, ErrNotFound, "File does not exist")
 			return
 		}
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
 		return
 	}
 
 	if info.IsDir() {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Path is a directory, not a file"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
 		return
 	}
 
 	// Check file size
 	if info.Size() > maxFileSize {
-		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large (max 1MB)"})
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
 		return
 	}
 
@@ -314,27 +372,28 @@ func ReadFile(c *gin.Context) {
 	file, err := os.Open(req.Path)
 	if err != nil {
 		if os.IsPermission(err) {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Permission denied"})
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to read file")
 		return
 	}
 	defer file.Close()
 
 	contentBytes, err := io.ReadAll(file)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to read file")
 		return
 	}
 
 	// Check if content is valid UTF-8 (not binary)
 	if !utf8.Valid(contentBytes) {
-		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File is binary"})
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, "File is binary")
 		return
 	}
 
 	content := string(contentBytes)
+	trackRecentFile(currentUser(c), req.Path)
 
 	// Detect language from file extension
 	ext := strings.ToLower(filepath.Ext(req.Path))
@@ -349,5 +408,126 @@ func ReadFile(c *gin.Context) {
 		Path:     req.Path,
 		Name:     filepath.Base(req.Path),
 		Size:     info.Size(),
+		MtimeMs:  info.ModTime().UnixMilli(),
+		Lock:     fileLockFor(currentUser(c), req.Path),
+	})
+}
+
+// resolveExistingPath resolves the symlinks in path's nearest existing ancestor
+// and appends the components that don't exist yet
+func resolveExistingPath(path string) (string, error) {
+	dir, rest := filepath.Clean(path), ""
+	for {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		parent := filepath.Dir(dir)
+		if !os.IsNotExist(err) || parent == dir {
+			return "", err
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+		dir = parent
+	}
+}
+
+// WriteFile handles POST /api/file/write
+// Replaces a text file's content atomically, keeping its mode; symlinks are written through
+func WriteFile(c *gin.Context) {
+	var req WriteFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" || !filepath.IsAbs(req.Path) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "An absolute path is required")
+		return
+	}
+	user := currentUser(c)
+	if !userCanAccessPath(user, req.Path) {
+		denyPath(c, req.Path)
+		return
+	}
+	if isRemotePath(req.Path) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Files on remote hosts can't be written")
+		return
+	}
+	if len(req.Content) > maxFileSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
+		return
+	}
+
+	// A file that doesn't exist yet is resolved through its nearest existing parent,
+	// so a symlinked directory inside a root can't create files outside it
+	path := filepath.Clean(req.Path)
+	if resolved, err := resolveExistingPath(path); err == nil && resolved != path {
+		if !userCanAccessPath(user, resolved) {
+			denyPath(c, req.Path)
+			return
+		}
+		path = resolved
+	}
+	if lock := fileLockConflict(path, req.LockID); lock != nil {
+		fileLockedError(c, lock)
+		return
+	}
+	mode := os.FileMode(0644)
+	info, err := os.Stat(path)
+	switch {
+	case err == nil:
+		if info.IsDir() {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
+			return
+		}
+		if req.CreateOnly {
+			respondError(c, http.StatusConflict, ErrConflict, "File already exists")
+			return
+		}
+		if req.ExpectedMtimeMs != 0 && info.ModTime().UnixMilli() != req.ExpectedMtimeMs {
+			respondErrorDetails(c, http.StatusConflict, ErrConflict, "File changed since it was read",
+				gin.H{"mtimeMs": info.ModTime().UnixMilli()})
+			return
+		}
+		mode = info.Mode().Perm()
+	case os.IsNotExist(err):
+		if req.ExpectedMtimeMs != 0 {
+			respondError(c, http.StatusConflict, ErrConflict, "File was deleted since it was read")
+			return
+		}
+		if req.CreateDirs {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				respondError(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to create directories: %v", err))
+				return
+			}
+		} else if _, err := os.Stat(filepath.Dir(path)); err != nil {
+			respondError(c, http.StatusNotFound, ErrNotFound, "Directory does not exist")
+			return
+		}
+	case os.IsPermission(err):
+		respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+		return
+	default:
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+
+	if err := writeFileAtomicMode(path, []byte(req.Content), mode); err != nil {
+		if os.IsPermission(err) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to write file: %v", err))
+		return
+	}
+	written, err := os.Stat(path)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, WriteFileResponse{
+		Path:    req.Path,
+		Size:    written.Size(),
+		MtimeMs: written.ModTime().UnixMilli(),
+		Created: info == nil,
 	})
 }
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// branchCheckTimeout bounds the git commands behind a session's branch status
+const branchCheckTimeout = 5 * time.Second
+
+// BranchStatus compares the git branch a session ran on with its working directory now
+type BranchStatus struct {
+	Branch   string `json:"branch"`            // recorded in the transcript
+	Current  string `json:"current,omitempty"` // checked out in the working directory now; empty when detached
+	Detached bool   `json:"detached,omitempty"`
+	Exists   bool   `json:"exists"` // the branch is still in the repository
+	// Mismatch means resuming the session would run on another branch than it was written on
+	Mismatch bool `json:"mismatch"`
+}
+
+// branchGit runs git in dir and returns its trimmed output
+func branchGit(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), branchCheckTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "git", append([]string{"-C", dir}, args...)...).Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+// sessionBranchStatus checks a session's recorded branch against the repository in workDir
+// nil when there is nothing to compare: no branch recorded, a remote or missing directory, or no repository
+func sessionBranchStatus(workDir, branch string) *BranchStatus {
+	if branch == "" || branch == "HEAD" || workDir == "" || isRemotePath(workDir) {
+		return nil
+	}
+	if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+		return nil
+	}
+	if _, err := branchGit(workDir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return nil
+	}
+	status := &BranchStatus{Branch: branch}
+	if current, err := branchGit(workDir, "symbolic-ref", "--short", "-q", "HEAD"); err == nil && current != "" {
+		status.Current = current
+	} else {
+		status.Detached = true
+	}
+	_, err := branchGit(workDir, "show-ref", "--verify", "-q", "refs/heads/"+branch)
+	status.Exists = err == nil
+	status.Mismatch = status.Current != branch
+	return status
+}
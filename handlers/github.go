@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// githubIssueLimit bounds GET /api/integrations/github/issues
+	githubIssueLimit = 100
+	// githubCommentLimit bounds the comments added to an issue's prompt
+	githubCommentLimit = 30
+	// githubDefaultInstructions ends an issue's prompt when the request brings none
+	githubDefaultInstructions = "Work on this issue in the repository, then summarize what you changed and anything left open."
+)
+
+// githubRepoRegex matches owner/repo
+var githubRepoRegex = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// IntegrationsConfig connects the server to issue trackers and messengers
+type IntegrationsConfig struct {
+	GitHub   GitHubConfig   `yaml:"github" json:"github"`
+	Linear   LinearConfig   `yaml:"linear" json:"linear"`
+	Jira     JiraConfig     `yaml:"jira" json:"jira"`
+	Slack    SlackConfig    `yaml:"slack" json:"slack"`
+	Telegram TelegramConfig `yaml:"telegram" json:"telegram"`
+	Paste    PasteConfig    `yaml:"paste" json:"paste"`
+}
+
+// GitHubConfig lists the repositories whose issues can be run as prompts
+type GitHubConfig struct {
+	// Token defaults to GREYZONE_GITHUB_TOKEN, then GITHUB_TOKEN; public repositories work without one
+	Token string `yaml:"token" json:"-"`
+	// APIURL is https://api.github.com, or https://host/api/v3 for GitHub Enterprise
+	APIURL string       `yaml:"apiURL" json:"apiURL"`
+	Repos  []GitHubRepo `yaml:"repos" json:"repos"`
+}
+
+// GitHubRepo maps a repository to the working directory its issues run in
+type GitHubRepo struct {
+	Name    string `yaml:"name" json:"name"` // owner/repo
+	WorkDir string `yaml:"workDir" json:"workDir"`
+}
+
+// GitHubIssue is an open issue from GET /api/integrations/github/issues
+type GitHubIssue struct {
+	Number    int      `json:"number"`
+	Title     string   `json:"title"`
+	Body      string   `json:"body,omitempty"`
+	URL       string   `json:"url"`
+	Author    string   `json:"author"`
+	Labels    []string `json:"labels"`
+	Comments  int      `json:"comments"`
+	CreatedAt string   `json:"createdAt"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// GitHubIssuesResponse is the response of GET /api/integrations/github/issues
+type GitHubIssuesResponse struct {
+	Repo    string        `json:"repo"`
+	WorkDir string        `json:"workDir"`
+	Issues  []GitHubIssue `json:"issues"`
+}
+
+// GitHubIssueRunRequest is the body of POST /api/integrations/github/issues/:id/run
+type GitHubIssueRunRequest struct {
+	Instructions string   `json:"instructions,omitempty"` // what to do with the issue; a default asks claude to work on it
+	Comments     bool     `json:"comments"`               // add the issue's comments to the prompt
+	SessionID    string   `json:"sessionId,omitempty"`
+	PlanMode     bool     `json:"planMode"`
+	MCPServers   []string `json:"mcpServers,omitempty"`
+	Backend      string   `json:"backend,omitempty"`
+}
+
+// githubIssue is an issue as the GitHub REST API returns it
+type githubIssue struct {
+	Number  int    `json:"number"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+	User    struct {
+		Login string `json:"login"`
+	} `json:"user"`
+	Labels []struct {
+		Name string `json:"name"`
+	} `json:"labels"`
+	Comments    int             `json:"comments"`
+	CreatedAt   string          `json:"created_at"`
+	UpdatedAt   string          `json:"updated_at"`
+	PullRequest json.RawMessage `json:"pull_request"` // set when the issue is a pull request
+}
+
+func (i githubIssue) public() GitHubIssue {
+	issue := GitHubIssue{Number: i.Number, Title: i.Title, Body: i.Body, URL: i.HTMLURL, Author: i.User.Login,
+		Labels: []string{}, Comments: i.Comments, CreatedAt: i.CreatedAt, UpdatedAt: i.UpdatedAt}
+	for _, l := range i.Labels {
+		issue.Labels = append(issue.Labels, l.Name)
+	}
+	return issue
+}
+
+// validateGitHubConfig checks integrations.github
+func validateGitHubConfig(cfg GitHubConfig) error {
+	if cfg.APIURL != "" {
+		if u, err := url.Parse(cfg.APIURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("integrations.github.apiURL must be an http(s) URL")
+		}
+	}
+	for _, repo := range cfg.Repos {
+		if !githubRepoRegex.MatchString(repo.Name) {
+			return fmt.Errorf("integrations.github.repos: %q is not owner/repo", repo.Name)
+		}
+		if repo.WorkDir == "" {
+			return fmt.Errorf("integrations.github.repos: %s has no workDir", repo.Name)
+		}
+	}
+	return nil
+}
+
+// githubRequest calls the GitHub REST API with body (nil for none) as JSON and decodes the JSON response into out
+func githubRequest(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+	cfg := getServerConfig().Integrations.GitHub
+	base := strings.TrimSuffix(cfg.APIURL, "/")
+	if base == "" {
+		base = "https://api.github.com"
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, base+path, reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "claude-greyzone/"+Version)
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("GitHub is unreachable: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return withCode(http.StatusNotFound, ErrNotFound, fmt.Errorf("not found on GitHub: %s", path))
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		var errBody struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&errBody)
+		return withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("GitHub returned %s: %s", resp.Status, errBody.Message))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// githubRepoFor resolves the repo query parameter to a configured repository the user may work in,
+// writing an error response otherwise; it may be left out when only one is configured
+func githubRepoFor(c *gin.Context) (GitHubRepo, bool) {
+	repos := getServerConfig().Integrations.GitHub.Repos
+	if len(repos) == 0 {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "No GitHub repositories are configured")
+		return GitHubRepo{}, false
+	}
+	name := c.Query("repo")
+	if name == "" && len(repos) == 1 {
+		name = repos[0].Name
+	}
+	for _, repo := range repos {
+		if strings.EqualFold(repo.Name, name) {
+			repo.WorkDir = expandHome(repo.WorkDir)
+			if !userCanAccessPath(currentUser(c), repo.WorkDir) {
+				respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", repo.WorkDir))
+				return GitHubRepo{}, false
+			}
+			return repo, true
+		}
+	}
+	respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("GitHub repository %q is not configured", name))
+	return GitHubRepo{}, false
+}
+
+// ListGitHubIssues handles GET /api/integrations/github/issues
+// Lists open issues, newest first; pull requests are left out
+// Query parameters:
+//   - repo: owner/repo from integrations.github.repos (optional when only one is configured)
+//   - labels: comma-separated labels the issues must all have
+//   - limit: maximum number of issues (default and maximum: 100)
+func ListGitHubIssues(c *gin.Context) {
+	repo, ok := githubRepoFor(c)
+	if !ok {
+		return
+	}
+	limit := githubIssueLimit
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 && n < limit {
+			limit = n
+		}
+	}
+	query := url.Values{"state": {"open"}, "per_page": {strconv.Itoa(githubIssueLimit)}}
+	if labels := c.Query("labels"); labels != "" {
+		query.Set("labels", labels)
+	}
+	var issues []githubIssue
+	if err := githubRequest(c.Request.Context(), http.MethodGet, "/repos/"+repo.Name+"/issues?"+query.Encode(), nil, &issues); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	resp := GitHubIssuesResponse{Repo: repo.Name, WorkDir: repo.WorkDir, Issues: []GitHubIssue{}}
+	for _, issue := range issues {
+		if issue.PullRequest == nil && len(resp.Issues) < limit {
+			resp.Issues = append(resp.Issues, issue.public())
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RunGitHubIssue handles POST /api/integrations/github/issues/:id/run
+// Starts a chat in the repository's workDir seeded with the issue, streamed like POST /api/chat
+// Query parameters:
+//   - repo: owner/repo from integrations.github.repos (optional when only one is configured)
+func RunGitHubIssue(c *gin.Context) {
+	var req GitHubIssueRunRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	number, err := strconv.Atoi(c.Param("id"))
+	if err != nil || number <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid issue number")
+		return
+	}
+	repo, ok := githubRepoFor(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var issue githubIssue
+	if err := githubRequest(ctx, http.MethodGet, fmt.Sprintf("/repos/%s/issues/%d", repo.Name, number), nil, &issue); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "GitHub issue %s#%d: %s\n%s\n", repo.Name, issue.Number, issue.Title, issue.HTMLURL)
+	if body := strings.TrimSpace(issue.Body); body != "" {
+		fmt.Fprintf(&prompt, "\n%s\n", body)
+	}
+	if req.Comments && issue.Comments > 0 {
+		var comments []struct {
+			Body string `json:"body"`
+			User struct {
+				Login string `json:"login"`
+			} `json:"user"`
+		}
+		path := fmt.Sprintf("/repos/%s/issues/%d/comments?per_page=%d", repo.Name, number, githubCommentLimit)
+		if err := githubRequest(ctx, http.MethodGet, path, nil, &comments); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+		prompt.WriteString("\nComments:\n")
+		for _, comment := range comments {
+			fmt.Fprintf(&prompt, "\n@%s:\n%s\n", comment.User.Login, strings.TrimSpace(comment.Body))
+		}
+	}
+	instructions := strings.TrimSpace(req.Instructions)
+	if instructions == "" {
+		instructions = githubDefaultInstructions
+	}
+	fmt.Fprintf(&prompt, "\n%s", instructions)
+
+	workDir := repo.WorkDir
+	if req.SessionID != "" {
+		workDir = ""
+	}
+	executeChatStream(c, ChatRequest{
+		Prompt:     prompt.String(),
+		SessionID:  req.SessionID,
+		WorkDir:    workDir,
+		PlanMode:   req.PlanMode,
+		MCPServers: req.MCPServers,
+		Backend:    req.Backend,
+	}, false)
+}
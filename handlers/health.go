@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// healthMinFreeDiskMB is the free space readiness requires when limits.resources.minFreeDiskMB isn't set
+	healthMinFreeDiskMB = 100
+	// certExpiryWarning is how long before its certificate expires readiness starts warning
+	certExpiryWarning = 14 * 24 * time.Hour
+)
+
+// serverStartTime is when the server process started, for uptime
+var serverStartTime = time.Now()
+
+// HealthCheck is one check of GET /health/ready
+type HealthCheck struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"` // ok, warn, or error
+	Message    string                 `json:"message"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMs int64                  `json:"durationMs"`
+}
+
+// HealthReport is the response of GET /health/ready
+type HealthReport struct {
+	Status string        `json:"status"` // ready, or unready when a check is in error
+	Uptime string        `json:"uptime"`
+	Time   string        `json:"time"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// sessionScanStarted makes sure readiness starts at most one scan when none has run yet
+var sessionScanStarted sync.Once
+
+// checkClaudeCLI looks for the claude binary; with the api chat backend it is optional
+func checkClaudeCLI() HealthCheck {
+	check := HealthCheck{Name: "claude", Status: "ok"}
+	path, err := exec.LookPath("claude")
+	if err != nil {
+		check.Status, check.Message = "error", "claude CLI not found on PATH"
+		if getServerConfig().Claude.ChatBackend == "api" {
+			check.Status, check.Message = "warn", "claude CLI not found on PATH; chats use the API backend"
+		}
+		return check
+	}
+	check.Message = "found"
+	cliMu.Lock()
+	caps := cliCaps
+	cliMu.Unlock()
+	if caps != nil && caps.Path == path {
+		if caps.Error != "" {
+			check.Status, check.Message = "error", caps.Error
+		} else {
+			check.Details = map[string]interface{}{"version": caps.Version}
+		}
+	}
+	return check
+}
+
+// checkClaudeDir makes sure ~/.claude and its projects directory can be read and written
+func checkClaudeDir() HealthCheck {
+	check := HealthCheck{Name: "claudeDir", Status: "ok", Message: "readable and writable"}
+	claudeDir := getClaudeDir()
+	if info, err := os.Stat(claudeDir); err != nil || !info.IsDir() {
+		check.Status, check.Message = "error", "~/.claude not found; claude hasn't been run as this user yet"
+		return check
+	}
+	for _, dir := range []string{claudeDir, getProjectsDir()} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) && dir != claudeDir {
+			continue // created by claude's first session
+		}
+		if err := unix.Access(dir, unix.R_OK|unix.W_OK|unix.X_OK); err != nil {
+			check.Status, check.Message = "error", fmt.Sprintf("%s is not readable and writable: %v", dir, err)
+			return check
+		}
+	}
+	return check
+}
+
+// checkDiskSpace compares the free space where sessions and server data are written with the minimum
+func checkDiskSpace() HealthCheck {
+	check := HealthCheck{Name: "disk", Status: "ok"}
+	minFree := getServerConfig().Limits.Resources.MinFreeDiskMB
+	if minFree <= 0 {
+		minFree = healthMinFreeDiskMB
+	}
+	free := map[string]interface{}{}
+	lowest := int64(-1)
+	for name, dir := range map[string]string{"claudeDir": getClaudeDir(), "dataDir": getServerDataDir()} {
+		// A directory not created yet will be on its parent's filesystem
+		for dir != filepath.Dir(dir) {
+			if _, err := os.Stat(dir); err == nil {
+				break
+			}
+			dir = filepath.Dir(dir)
+		}
+		mb, ok := readFreeDiskMB(dir)
+		if !ok {
+			continue
+		}
+		free[name+"FreeMB"] = mb
+		if lowest < 0 || mb < lowest {
+			lowest = mb
+		}
+	}
+	free["minFreeMB"] = minFree
+	check.Details = free
+	switch {
+	case lowest < 0:
+		check.Status, check.Message = "warn", "Free disk space couldn't be read"
+	case lowest < minFree:
+		check.Status, check.Message = "error", fmt.Sprintf("%d MB free, below %d MB", lowest, minFree)
+	default:
+		check.Message = fmt.Sprintf("%d MB free", lowest)
+	}
+	return check
+}
+
+// checkCertificate reads the configured TLS certificate's validity period
+func checkCertificate() HealthCheck {
+	check := HealthCheck{Name: "certificate", Status: "ok"}
+	cfg := getServerConfig().TLS
+	switch {
+	case cfg.Disabled:
+		check.Message = "TLS disabled"
+		return check
+	case len(cfg.AutocertDomains) > 0:
+		check.Message = "Managed by Let's Encrypt"
+		return check
+	}
+	data, err := os.ReadFile(cfg.Cert)
+	if err != nil {
+		check.Status, check.Message = "error", fmt.Sprintf("Failed to read certificate: %v", err)
+		return check
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		check.Status, check.Message = "error", "Certificate file has no PEM certificate"
+		return check
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		check.Status, check.Message = "error", fmt.Sprintf("Failed to parse certificate: %v", err)
+		return check
+	}
+	now := time.Now()
+	left := cert.NotAfter.Sub(now)
+	check.Details = map[string]interface{}{
+		"notBefore": cert.NotBefore.UTC().Format(time.RFC3339),
+		"notAfter":  cert.NotAfter.UTC().Format(time.RFC3339),
+		"daysLeft":  int(left.Hours() / 24),
+	}
+	switch {
+	case now.Before(cert.NotBefore):
+		check.Status, check.Message = "error", "Certificate is not valid yet"
+	case left <= 0:
+		check.Status, check.Message = "error", "Certificate expired on "+cert.NotAfter.Format("2006-01-02")
+	case left < certExpiryWarning:
+		check.Status, check.Message = "warn", "Certificate expires on "+cert.NotAfter.Format("2006-01-02")
+	default:
+		check.Message = "Valid until " + cert.NotAfter.Format("2006-01-02")
+	}
+	return check
+}
+
+// checkSessionIndex reports the last full session scan, starting one in the background if none has run
+func checkSessionIndex() HealthCheck {
+	check := HealthCheck{Name: "sessionIndex", Status: "ok"}
+	sessionParseMu.Lock()
+	status := sessionScanStatus
+	sessionParseMu.Unlock()
+	if status.at.IsZero() {
+		sessionScanStarted.Do(func() { go scanSessions("") })
+		check.Status, check.Message = "warn", "Sessions haven't been scanned yet"
+		return check
+	}
+	check.Details = map[string]interface{}{
+		"lastScan":   status.at.UTC().Format(time.RFC3339),
+		"durationMs": status.duration.Milliseconds(),
+		"sessions":   status.sessions,
+	}
+	if os.IsNotExist(status.err) {
+		check.Message = "No sessions yet"
+		return check
+	}
+	if status.err != nil {
+		check.Status, check.Message = "error", fmt.Sprintf("Last scan failed: %v", status.err)
+		return check
+	}
+	check.Message = fmt.Sprintf("%d sessions", status.sessions)
+	return check
+}
+
+// HealthLive handles GET /health and GET /health/live
+// Answers as long as the server can serve requests, without checking anything it depends on
+func HealthLive(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "healthy",
+		"uptime": time.Since(serverStartTime).String(),
+		"time":   time.Now().Format(time.RFC3339),
+	})
+}
+
+// HealthReady handles GET /health/ready
+// Checks the claude CLI, ~/.claude, disk space, the TLS certificate, and the session index;
+// 503 when any check is in error, warnings don't count
+func HealthReady(c *gin.Context) {
+	report := HealthReport{Status: "ready", Uptime: time.Since(serverStartTime).String(), Time: time.Now().Format(time.RFC3339)}
+	for _, run := range []func() HealthCheck{checkClaudeCLI, checkClaudeDir, checkDiskSpace, checkCertificate, checkSessionIndex} {
+		start := time.Now()
+		check := run()
+		check.DurationMs = time.Since(start).Milliseconds()
+		if check.Status == "error" {
+			report.Status = "unready"
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
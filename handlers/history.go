@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// historyLine locates a timestamped transcript line
+type historyLine struct {
+	start     int64
+	end       int64
+	timestamp time.Time
+}
+
+// historyIndex maps positions in a transcript to byte offsets so polls can read only the new tail
+// It covers complete lines up to size and is extended as the file grows
+type historyIndex struct {
+	mu        sync.Mutex
+	size      int64
+	messages  int              // user/assistant messages in the indexed bytes
+	ends      map[string]int64 // uuid -> offset just past its line
+	lines     []historyLine    // timestamped lines in file order
+	lastUUID  string
+	spans     []historyLine  // user/assistant message lines in order (timestamp unused)
+	positions map[string]int // uuid -> index into spans
+}
+
+var (
+	historyMu      sync.Mutex
+	historyIndexes = make(map[string]*historyIndex) // keyed by .jsonl path
+)
+
+// isHistoryMessage reports whether a transcript line type is returned by GetSessionHistory
+func isHistoryMessage(msgType string) bool {
+	return msgType == "user" || msgType == "human" || msgType == "assistant"
+}
+
+// update indexes lines appended since the last call; a file that shrank is reindexed from the start
+// A trailing line without a newline is still being written and is left for the next call
+func (idx *historyIndex) update(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < idx.size || idx.ends == nil {
+		idx.size, idx.messages, idx.lastUUID = 0, 0, ""
+		idx.ends = make(map[string]int64)
+		idx.lines, idx.spans = nil, nil
+		idx.positions = make(map[string]int)
+	}
+	if info.Size() == idx.size {
+		return nil
+	}
+	if _, err := file.Seek(idx.size, io.SeekStart); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	offset := idx.size
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			break // io.EOF, possibly with a partial line
+		}
+		start := offset
+		offset += int64(len(line))
+
+		var entry struct {
+			Type      string `json:"type"`
+			UUID      string `json:"uuid"`
+			Timestamp string `json:"timestamp"`
+		}
+		if json.Unmarshal(line, &entry) != nil {
+			continue
+		}
+		if entry.UUID != "" {
+			idx.ends[entry.UUID] = offset
+			idx.lastUUID = entry.UUID
+		}
+		if ts, err := time.Parse(time.RFC3339Nano, entry.Timestamp); err == nil {
+			idx.lines = append(idx.lines, historyLine{start: start, end: offset, timestamp: ts})
+		}
+		if isHistoryMessage(entry.Type) {
+			if entry.UUID != "" {
+				idx.positions[entry.UUID] = len(idx.spans)
+			}
+			idx.spans = append(idx.spans, historyLine{start: start, end: offset})
+			idx.messages++
+		}
+	}
+	idx.size = offset
+	return nil
+}
+
+// historySince returns messages appended after sinceUUID, or timestamped after since when sinceUUID is empty
+// total counts every message in the transcript; lastUUID is the cursor for the next poll
+// found is false when sinceUUID isn't in the transcript (e.g. it was rewritten) and the client should reload
+func historySince(path string, sinceUUID string, since time.Time) (messages []Message, total int, lastUUID string, found bool, err error) {
+	idx := historyIndexFor(path)
+
+	idx.mu.Lock()
+	if err := idx.update(path); err != nil {
+		idx.mu.Unlock()
+		return nil, 0, "", false, err
+	}
+	var start int64
+	if sinceUUID != "" {
+		start, found = idx.ends[sinceUUID]
+	} else {
+		// Transcripts are appended in time order
+		i := sort.Search(len(idx.lines), func(i int) bool { return idx.lines[i].timestamp.After(since) })
+		start, found = idx.size, true
+		if i < len(idx.lines) {
+			start = idx.lines[i].start
+		}
+	}
+	end := idx.size
+	total, lastUUID = idx.messages, idx.lastUUID
+	idx.mu.Unlock()
+
+	if !found || start >= end {
+		return []Message{}, total, lastUUID, found, nil
+	}
+
+	messages, err = readHistoryRange(path, start, end)
+	if err != nil {
+		return nil, 0, "", false, err
+	}
+	return messages, total, lastUUID, true, nil
+}
+
+// historyIndexFor returns the index of a transcript, creating it on first use
+func historyIndexFor(path string) *historyIndex {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	idx, ok := historyIndexes[path]
+	if !ok {
+		idx = &historyIndex{}
+		historyIndexes[path] = idx
+	}
+	return idx
+}
+
+// readHistoryRange parses the user/assistant messages between two byte offsets of a transcript
+func readHistoryRange(path string, start, end int64) ([]Message, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := bufio.NewReaderSize(io.NewSectionReader(file, start, end-start), 64*1024)
+	messages := []Message{}
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg Message
+			if json.Unmarshal(line, &msg) == nil && isHistoryMessage(msg.Type) {
+				messages = append(messages, msg)
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return messages, nil
+}
+
+// historyMessage returns a user/assistant message of a transcript by uuid and its position among them
+func historyMessage(path string, uuid string) (Message, int, error) {
+	idx := historyIndexFor(path)
+	idx.mu.Lock()
+	if err := idx.update(path); err != nil {
+		idx.mu.Unlock()
+		return Message{}, 0, err
+	}
+	pos, ok := idx.positions[uuid]
+	var span historyLine
+	if ok {
+		span = idx.spans[pos]
+	}
+	idx.mu.Unlock()
+	if !ok {
+		return Message{}, 0, os.ErrNotExist
+	}
+	messages, err := readHistoryRange(path, span.start, span.end)
+	if err != nil {
+		return Message{}, 0, err
+	}
+	if len(messages) == 0 {
+		return Message{}, 0, os.ErrNotExist
+	}
+	return messages[0], pos, nil
+}
+
+// forgetHistoryIndexes drops indexes for transcripts not in keep
+func forgetHistoryIndexes(keep map[string]bool) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	for path := range historyIndexes {
+		if !keep[path] {
+			delete(historyIndexes, path)
+		}
+	}
+}
+
+const (
+	// messageContextDefault is how many messages GET /api/session/:id/messages/:uuid/context
+	// returns on each side of the target by default
+	messageContextDefault = 20
+	messageContextMax     = 200
+)
+
+// MessageContextResponse is the response of GET /api/session/:id/messages/:uuid/context
+type MessageContextResponse struct {
+	SessionID string    `json:"sessionId"`
+	Messages  []Message `json:"messages"`
+	Start     int       `json:"start"`  // position of the first returned message, as in search results' index
+	Target    int       `json:"target"` // position of the requested message
+	Total     int       `json:"total"`
+	HasBefore bool      `json:"hasBefore"` // messages before the window remain
+	HasAfter  bool      `json:"hasAfter"`
+}
+
+// GetMessageContext handles GET /api/session/:id/messages/:uuid/context
+// Returns a window of messages around one, so a deep link from search or a bookmark can open
+// the middle of a long conversation; page outward with the uuids at either end of the window
+// Query parameters:
+//   - before, after: messages on each side of the target (default 20, max 200)
+func GetMessageContext(c *gin.Context) {
+	sessionID, uuid := c.Param("id"), c.Param("uuid")
+	bounds := [2]int{messageContextDefault, messageContextDefault}
+	for i, name := range []string{"before", "after"} {
+		if value := c.Query(name); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 0 {
+				respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid "+name+" parameter")
+				return
+			}
+			if n > messageContextMax {
+				n = messageContextMax
+			}
+			bounds[i] = n
+		}
+	}
+
+	path := ""
+	if userCanAccessSession(currentUser(c), sessionID) {
+		path = findSessionFile(sessionID)
+	}
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+
+	idx := historyIndexFor(path)
+	idx.mu.Lock()
+	if err := idx.update(path); err != nil {
+		idx.mu.Unlock()
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	target, ok := idx.positions[uuid]
+	if !ok {
+		idx.mu.Unlock()
+		respondError(c, http.StatusNotFound, ErrNotFound, "Message not found")
+		return
+	}
+	first, last := target-bounds[0], target+bounds[1]
+	if first < 0 {
+		first = 0
+	}
+	if last >= len(idx.spans) {
+		last = len(idx.spans) - 1
+	}
+	start, end, total := idx.spans[first].start, idx.spans[last].end, len(idx.spans)
+	idx.mu.Unlock()
+
+	messages, err := readHistoryRange(path, start, end)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, MessageContextResponse{
+		SessionID: sessionID,
+		Messages:  messages,
+		Start:     first,
+		Target:    target,
+		Total:     total,
+		HasBefore: first > 0,
+		HasAfter:  last < total-1,
+	})
+}
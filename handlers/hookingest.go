@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// hookEventMaxSize bounds one ingested hook input; tool inputs can carry whole files
+	hookEventMaxSize = 1 << 20
+	// hookEventFileLimit is the size at which a session's event file is cut to its newer half
+	hookEventFileLimit = 4 << 20
+	// hookEventSecretHeader carries hookIngest.secret
+	hookEventSecretHeader = "X-Greyzone-Hook-Secret"
+)
+
+// permissionUseRegex finds the tool in a Notification hook's message, e.g. "Claude needs your permission to use Bash"
+var permissionUseRegex = regexp.MustCompile(`permission to use (\S+)`)
+
+// HookIngestConfig enables POST /api/hooks/ingest
+type HookIngestConfig struct {
+	// Secret must be sent by hook scripts in X-Greyzone-Hook-Secret; ingestion is off while it's empty
+	Secret string `yaml:"secret" json:"-"`
+}
+
+// HookEvent is a claude hook's input as received from a hook script
+type HookEvent struct {
+	SessionID  string          `json:"sessionId"`
+	Event      string          `json:"event"` // hook_event_name: Stop, Notification, PreToolUse, ...
+	Tool       string          `json:"tool,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	WorkDir    string          `json:"workDir,omitempty"`
+	ReceivedAt string          `json:"receivedAt"`
+	Input      json.RawMessage `json:"input"` // the hook input, unchanged
+}
+
+var hookEventsMu sync.Mutex
+
+// hookEventsPath is where a session's ingested hook events are appended
+func hookEventsPath(sessionID string) string {
+	return filepath.Join(serverDataPath("hook-events"), sessionID+".jsonl")
+}
+
+// appendHookEvent stores an event with its session, cutting the file once it grows past hookEventFileLimit
+func appendHookEvent(event HookEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	hookEventsMu.Lock()
+	defer hookEventsMu.Unlock()
+	path := hookEventsPath(event.SessionID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	info, statErr := f.Stat()
+	f.Close()
+	if err != nil || statErr != nil || info.Size() <= hookEventFileLimit {
+		return err
+	}
+	old, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	kept := old[len(old)/2:]
+	if i := bytes.IndexByte(kept, '\n'); i >= 0 {
+		kept = kept[i+1:]
+	}
+	return writeFileAtomic(path, kept)
+}
+
+// readHookEvents returns the newest limit events of a session, oldest first
+func readHookEvents(sessionID string, limit int) ([]HookEvent, error) {
+	hookEventsMu.Lock()
+	defer hookEventsMu.Unlock()
+	f, err := os.Open(hookEventsPath(sessionID))
+	if os.IsNotExist(err) {
+		return []HookEvent{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	events := []HookEvent{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), hookEventMaxSize*2)
+	for scanner.Scan() {
+		var event HookEvent
+		if json.Unmarshal(scanner.Bytes(), &event) == nil {
+			events = append(events, event)
+		}
+	}
+	if len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events, scanner.Err()
+}
+
+// forgetHookEvents drops a deleted session's events
+func forgetHookEvents(sessionID string) {
+	hookEventsMu.Lock()
+	defer hookEventsMu.Unlock()
+	os.Remove(hookEventsPath(sessionID))
+}
+
+// sessionOwnerOf returns the user that created a session, "" when unknown
+func sessionOwnerOf(sessionID string) string {
+	authManager.ownersMu.RLock()
+	defer authManager.ownersMu.RUnlock()
+	return authManager.sessionOwners[sessionID]
+}
+
+// IngestHookEvent handles POST /api/hooks/ingest
+// Takes the JSON a claude hook receives on stdin, so a hook command can be just
+// curl -d @- -H "X-Greyzone-Hook-Secret: ..." https://host/api/hooks/ingest
+// The event is stored with its session and sent live as a hookEvent on the session's channels.
+// Notification marks the session waiting for input and notifies its owner; the next
+// UserPromptSubmit or PostToolUse (an approved tool ran) clears that
+func IngestHookEvent(c *gin.Context) {
+	secret := getServerConfig().HookIngest.Secret
+	if secret == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Hook ingestion is not configured")
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader(hookEventSecretHeader)), []byte(secret)) != 1 {
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid hook secret")
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, hookEventMaxSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if len(body) > hookEventMaxSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("hook events are limited to %d MB", hookEventMaxSize>>20))
+		return
+	}
+	var input struct {
+		SessionID     string `json:"session_id"`
+		HookEventName string `json:"hook_event_name"`
+		ToolName      string `json:"tool_name"`
+		Message       string `json:"message"`
+		Cwd           string `json:"cwd"`
+	}
+	if err := json.Unmarshal(body, &input); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid hook input: %v", err))
+		return
+	}
+	if !sessionIDRegex.MatchString(input.SessionID) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "session_id is required")
+		return
+	}
+	if input.HookEventName == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "hook_event_name is required")
+		return
+	}
+
+	var compact bytes.Buffer
+	json.Compact(&compact, body)
+	event := HookEvent{
+		SessionID:  input.SessionID,
+		Event:      input.HookEventName,
+		Tool:       input.ToolName,
+		Message:    input.Message,
+		WorkDir:    input.Cwd,
+		ReceivedAt: time.Now().UTC().Format(time.RFC3339Nano),
+		Input:      compact.Bytes(),
+	}
+	if err := appendHookEvent(event); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to store hook event", err.Error())
+		return
+	}
+	broadcastSessionEvent(event.SessionID, "hookEvent", map[string]interface{}{"event": event})
+
+	switch event.Event {
+	case "Notification":
+		req := &InputRequest{Kind: "question", Detail: event.Message}
+		if strings.Contains(strings.ToLower(event.Message), "permission") {
+			req = &InputRequest{Kind: "permission"}
+			if m := permissionUseRegex.FindStringSubmatch(event.Message); m != nil {
+				req.Tool = m[1]
+			}
+		}
+		stateManager.setSessionWaiting(event.SessionID, req)
+		notifyInputRequest(sessionOwnerOf(event.SessionID), event.SessionID, req)
+	case "UserPromptSubmit", "PostToolUse":
+		stateManager.setSessionWaiting(event.SessionID, nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// GetHookEvents handles GET /api/hooks/events
+// Query parameters:
+//   - session_id: the session whose ingested hook events to return (required)
+//   - limit: maximum number of events, newest kept (default: 100)
+func GetHookEvents(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	if !sessionIDRegex.MatchString(sessionID) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "session_id is required")
+		return
+	}
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	limit := 100
+	if v := c.Query("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+	events, err := readHookEvents(sessionID, limit)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read hook events", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
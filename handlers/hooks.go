@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hookLogRegex matches hook execution records written by the CLI into session files
+// e.g. "PostToolUse:Edit [npm run lint] completed successfully"
+var hookLogRegex = regexp.MustCompile(`^(\w+)(?::(\S+))?\s+\[(.*?)\]\s*(.*)$`)
+
+// HooksConfig is the hooks section of a settings.json at a given scope
+type HooksConfig struct {
+	Scope string                 `json:"scope"`
+	Path  string                 `json:"path"`
+	Hooks map[string]interface{} `json:"hooks"`
+}
+
+// UpdateHooksRequest represents the request body for PUT /api/hooks
+type UpdateHooksRequest struct {
+	Scope   string                 `json:"scope"`
+	WorkDir string                 `json:"workDir"`
+	Hooks   map[string]interface{} `json:"hooks"`
+}
+
+// HookExecution is a hook run recorded in a session transcript
+type HookExecution struct {
+	SessionID string `json:"sessionId"`
+	Timestamp string `json:"timestamp"`
+	Event     string `json:"event"`
+	Matcher   string `json:"matcher,omitempty"`
+	Command   string `json:"command"`
+	Result    string `json:"result"`
+	Level     string `json:"level,omitempty"`
+}
+
+// GetHooks handles GET /api/hooks
+// Query parameters:
+//   - work_dir: project directory for project-scoped hooks (optional)
+func GetHooks(c *gin.Context) {
+	workDir := c.Query("work_dir")
+	if workDir != "" && !requireWorkDirAccess(c, workDir) {
+		return
+	}
+
+	scopes := []string{"user"}
+	if workDir != "" {
+		scopes = append(scopes, "project")
+	}
+
+	configs := make([]HooksConfig, 0, len(scopes))
+	for _, scope := range scopes {
+		path, _ := settingsPath(scope, workDir)
+		settings, _, err := loadSettingsFile(path)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to read %s", path), err.Error())
+			return
+		}
+		hooks, _ := settings["hooks"].(map[string]interface{})
+		if hooks == nil {
+			hooks = map[string]interface{}{}
+		}
+		configs = append(configs, HooksConfig{Scope: scope, Path: path, Hooks: hooks})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"hooks": configs,
+	})
+}
+
+// UpdateHooks handles PUT /api/hooks
+// Replaces only the hooks section of settings.json, keeping all other settings
+func UpdateHooks(c *gin.Context) {
+	var req UpdateHooksRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Hooks == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+
+	path, err := settingsPath(req.Scope, req.WorkDir)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	if errs := validateHooks(req.Hooks); len(errs) > 0 {
+		sort.Strings(errs)
+		respondErrorDetails(c, http.StatusUnprocessableEntity, ErrValidationFailed, "Hooks validation failed", errs)
+		return
+	}
+
+	settings, _, err := loadSettingsFile(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to read %s", path), err.Error())
+		return
+	}
+
+	if len(req.Hooks) == 0 {
+		delete(settings, "hooks")
+	} else {
+		settings["hooks"] = req.Hooks
+	}
+
+	if err := writeJSONFileAtomic(path, settings); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to write settings", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, HooksConfig{Scope: req.Scope, Path: path, Hooks: req.Hooks})
+}
+
+// parseHookExecutions scans a session file for hook execution records
+func parseHookExecutions(path string, sessionID string) []HookExecution {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var executions []HookExecution
+	scanner := bufio.NewScanner(file)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 10*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !jsonTypeIs(line, "system") {
+			continue
+		}
+
+		var entry struct {
+			Timestamp string `json:"timestamp"`
+			Content   string `json:"content"`
+			Level     string `json:"level"`
+		}
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+
+		match := hookLogRegex.FindStringSubmatch(entry.Content)
+		if match == nil || !validHookEvents[match[1]] {
+			continue
+		}
+		executions = append(executions, HookExecution{
+			SessionID: sessionID,
+			Timestamp: entry.Timestamp,
+			Event:     match[1],
+			Matcher:   match[2],
+			Command:   match[3],
+			Result:    match[4],
+			Level:     entry.Level,
+		})
+	}
+
+	return executions
+}
+
+// jsonTypeIs cheaply checks a JSONL line's "type" before a full unmarshal
+func jsonTypeIs(line []byte, msgType string) bool {
+	return strings.Contains(string(line), `"type":"`+msgType+`"`)
+}
+
+// GetHookLog handles GET /api/hooks/log
+// Only sessions the user can access are scanned
+// Query parameters:
+//   - session_id: only scan this session (optional)
+//   - limit: maximum number of executions to return (default: 100)
+func GetHookLog(c *gin.Context) {
+	sessionID := c.Query("session_id")
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+
+	user := currentUser(c)
+	var executions []HookExecution
+	if sessionID != "" {
+		if !userCanAccessSession(user, sessionID) {
+			respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+			return
+		}
+		path := findSessionFile(sessionID)
+		if path == "" {
+			respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+			return
+		}
+		executions = parseHookExecutions(path, sessionID)
+	} else {
+		// Scan the user's most recently modified sessions
+		keep := func(id string) bool { return userCanAccessSession(user, id) }
+		for _, path := range recentSessionFiles(20, keep) {
+			id := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+			executions = append(executions, parseHookExecutions(path, id)...)
+		}
+	}
+
+	sort.Slice(executions, func(i, j int) bool {
+		return executions[i].Timestamp > executions[j].Timestamp
+	})
+	if len(executions) > limit {
+		executions = executions[:limit]
+	}
+	if executions == nil {
+		executions = []HookExecution{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"executions": executions,
+	})
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultImagePrompt is sent to claude when a message has images but no text
+const defaultImagePrompt = "Analyze this image"
+
+// localePattern is the form of locale names: a language with an optional region, e.g. ko or pt-br
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})?$`)
+
+// builtinCatalogs translate server-generated strings, keyed by their English text
+// English needs no catalog; a string missing from a catalog is sent in English
+var builtinCatalogs = map[string]map[string]string{
+	"ko": {
+		defaultImagePrompt: "이 이미지를 분석해줘",
+
+		// Errors
+		"A budget for this project already exists":                  "이 프로젝트의 예산이 이미 있습니다",
+		"Admin role required":                                       "관리자 권한이 필요합니다",
+		"Another plugin operation is in progress":                   "다른 플러그인 작업이 진행 중입니다",
+		"Authentication required":                                   "로그인이 필요합니다",
+		"Batch already finished":                                    "배치가 이미 끝났습니다",
+		"Batch not found":                                           "배치를 찾을 수 없습니다",
+		"Budget not found":                                          "예산을 찾을 수 없습니다",
+		"Chat run not found or expired":                             "채팅 실행을 찾을 수 없거나 만료되었습니다",
+		"Chat stream not found or expired":                          "채팅 스트림을 찾을 수 없거나 만료되었습니다",
+		"Command expanded to an empty prompt":                       "명령이 빈 프롬프트로 확장되었습니다",
+		"Command not found":                                         "명령을 찾을 수 없습니다",
+		"Conversation not found":                                    "대화를 찾을 수 없습니다",
+		"Failed to back up session":                                 "세션을 백업하지 못했습니다",
+		"Failed to copy session":                                    "세션을 복사하지 못했습니다",
+		"Failed to repair session":                                  "세션을 복구하지 못했습니다",
+		"Failed to write recovered session":                         "복구한 세션을 쓰지 못했습니다",
+		"Failed to build OpenAPI spec":                              "OpenAPI 명세를 만들지 못했습니다",
+		"Failed to create backup directory":                         "백업 디렉터리를 만들지 못했습니다",
+		"Failed to create thumbnail":                                "썸네일을 만들지 못했습니다",
+		"Failed to create upload directory":                         "업로드 디렉터리를 만들지 못했습니다",
+		"Failed to delete file":                                     "파일을 삭제하지 못했습니다",
+		"Failed to delete session file":                             "세션 파일을 삭제하지 못했습니다",
+		"Failed to detect file type":                                "파일 형식을 알아내지 못했습니다",
+		"Failed to fork session":                                    "세션을 분기하지 못했습니다",
+		"Failed to generate filename":                               "파일 이름을 만들지 못했습니다",
+		"Failed to get home directory":                              "홈 디렉터리를 찾지 못했습니다",
+		"Failed to load budgets":                                    "예산을 불러오지 못했습니다",
+		"Failed to load project settings":                           "프로젝트 설정을 불러오지 못했습니다",
+		"Failed to load push settings":                              "푸시 설정을 불러오지 못했습니다",
+		"Failed to load schedules":                                  "예약 작업을 불러오지 못했습니다",
+		"Failed to load share links":                                "공유 링크를 불러오지 못했습니다",
+		"Failed to load templates":                                  "템플릿을 불러오지 못했습니다",
+		"Failed to load todos":                                      "할 일 목록을 불러오지 못했습니다",
+		"Failed to load usage":                                      "사용량을 불러오지 못했습니다",
+		"Failed to load webhooks":                                   "웹훅을 불러오지 못했습니다",
+		"Failed to open audit log":                                  "감사 로그를 열지 못했습니다",
+		"Failed to open session file":                               "세션 파일을 열지 못했습니다",
+		"Failed to parse installed_plugins.json":                    "installed_plugins.json을 해석하지 못했습니다",
+		"Failed to process file":                                    "파일을 처리하지 못했습니다",
+		"Failed to read file":                                       "파일을 읽지 못했습니다",
+		"Failed to read projects directory":                         "프로젝트 디렉터리를 읽지 못했습니다",
+		"Failed to read session file":                               "세션 파일을 읽지 못했습니다",
+		"Failed to render page":                                     "페이지를 렌더링하지 못했습니다",
+		"Failed to save budgets":                                    "예산을 저장하지 못했습니다",
+		"Failed to save file":                                       "파일을 저장하지 못했습니다",
+		"Failed to save pinned sessions":                            "고정된 세션을 저장하지 못했습니다",
+		"Failed to save preferences":                                "환경설정을 저장하지 못했습니다",
+		"Failed to save project settings":                           "프로젝트 설정을 저장하지 못했습니다",
+		"Failed to save schedules":                                  "예약 작업을 저장하지 못했습니다",
+		"Failed to save share link":                                 "공유 링크를 저장하지 못했습니다",
+		"Failed to save share links":                                "공유 링크를 저장하지 못했습니다",
+		"Failed to save subscription":                               "구독을 저장하지 못했습니다",
+		"Failed to save subscriptions":                              "구독을 저장하지 못했습니다",
+		"Failed to save templates":                                  "템플릿을 저장하지 못했습니다",
+		"Failed to save user":                                       "사용자를 저장하지 못했습니다",
+		"Failed to save users":                                      "사용자를 저장하지 못했습니다",
+		"Failed to save webhooks":                                   "웹훅을 저장하지 못했습니다",
+		"Failed to send the code":                                   "코드를 보내지 못했습니다",
+		"Failed to start claude setup-token":                        "claude setup-token을 시작하지 못했습니다",
+		"Failed to truncate session":                                "세션을 자르지 못했습니다",
+		"Failed to write forked session":                            "분기한 세션을 쓰지 못했습니다",
+		"Failed to write session":                                   "세션을 쓰지 못했습니다",
+		"Failed to write settings":                                  "설정을 쓰지 못했습니다",
+		"File does not exist":                                       "파일이 없습니다",
+		"File is binary":                                            "바이너리 파일입니다",
+		"File is too large (max 1MB)":                               "파일이 너무 큽니다 (최대 1MB)",
+		"File not found":                                            "파일을 찾을 수 없습니다",
+		"File too large or invalid request":                         "파일이 너무 크거나 잘못된 요청입니다",
+		"Filename is required":                                      "파일 이름이 필요합니다",
+		"Hooks validation failed":                                   "훅 검증에 실패했습니다",
+		"Invalid chat request":                                      "잘못된 채팅 요청입니다",
+		"Invalid code":                                              "잘못된 코드입니다",
+		"Invalid cursor":                                            "잘못된 cursor 값입니다",
+		"Invalid limit parameter":                                   "잘못된 limit 값입니다",
+		"Invalid lines parameter":                                   "잘못된 lines 값입니다",
+		"Invalid offset parameter":                                  "잘못된 offset 값입니다",
+		"Invalid or expired login state":                            "로그인 상태가 잘못되었거나 만료되었습니다",
+		"Invalid password":                                          "잘못된 비밀번호입니다",
+		"Invalid process ID":                                        "잘못된 프로세스 ID입니다",
+		"Invalid project id":                                        "잘못된 프로젝트 ID입니다",
+		"Invalid request body":                                      "잘못된 요청 본문입니다",
+		"Invalid sessions parameter":                                "잘못된 sessions 값입니다",
+		"Invalid since parameter":                                   "잘못된 since 값입니다",
+		"Invalid since parameter (expected RFC 3339)":               "잘못된 since 값입니다 (RFC 3339 형식이어야 합니다)",
+		"Invalid since_timestamp parameter":                         "잘못된 since_timestamp 값입니다",
+		"Invalid source":                                            "잘못된 source 값입니다",
+		"Invalid until parameter (expected RFC 3339)":               "잘못된 until 값입니다 (RFC 3339 형식이어야 합니다)",
+		"Invalid username or password":                              "사용자 이름 또는 비밀번호가 올바르지 않습니다",
+		"Invalid wait":                                              "잘못된 wait 값입니다",
+		"Message not found":                                         "메시지를 찾을 수 없습니다",
+		"Missing authorization code":                                "인가 코드가 없습니다",
+		"No file provided":                                          "파일이 없습니다",
+		"No records could be recovered":                             "복구할 수 있는 레코드가 없습니다",
+		"No login in progress":                                      "진행 중인 로그인이 없습니다",
+		"No login is waiting for a code":                            "코드를 기다리는 로그인이 없습니다",
+		"No subscription accepted the notification":                 "알림을 받은 구독이 없습니다",
+		"OIDC login is not enabled":                                 "OIDC 로그인이 활성화되어 있지 않습니다",
+		"Only admins can change roles, usernames, or project roots": "역할, 사용자 이름, 프로젝트 루트는 관리자만 바꿀 수 있습니다",
+		"Only the owner can modify this template":                   "이 템플릿은 소유자만 수정할 수 있습니다",
+		"Password login is not enabled":                             "비밀번호 로그인이 활성화되어 있지 않습니다",
+		"Path does not exist":                                       "경로가 없습니다",
+		"Path is a directory, not a file":                           "경로가 파일이 아니라 디렉터리입니다",
+		"Path is not a directory":                                   "경로가 디렉터리가 아닙니다",
+		"Path is required":                                          "경로가 필요합니다",
+		"Permission denied":                                         "권한이 없습니다",
+		"Process not found":                                         "프로세스를 찾을 수 없습니다",
+		"Project not found":                                         "프로젝트를 찾을 수 없습니다",
+		"Remote host unavailable":                                   "원격 호스트에 연결할 수 없습니다",
+		"Rewinding there would leave the session empty":             "그 지점으로 되돌리면 세션이 비게 됩니다",
+		"Schedule not found":                                        "예약 작업을 찾을 수 없습니다",
+		"Session has no text to summarize":                          "세션에 요약할 텍스트가 없습니다",
+		"Session not found":                                         "세션을 찾을 수 없습니다",
+		"Sessions can't be imported into remote directories":        "원격 디렉터리로는 세션을 가져올 수 없습니다",
+		"Set olderThanDays and/or largerThanMB":                     "olderThanDays나 largerThanMB를 지정하세요",
+		"Settings validation failed":                                "설정 검증에 실패했습니다",
+		"Share link not found":                                      "공유 링크를 찾을 수 없습니다",
+		"Streaming not supported":                                   "스트리밍을 지원하지 않습니다",
+		"Subscription not found":                                    "구독을 찾을 수 없습니다",
+		"Summarizer returned an empty summary":                      "요약기가 빈 요약을 반환했습니다",
+		"Template not found":                                        "템플릿을 찾을 수 없습니다",
+		"The shared session is no longer available":                 "공유된 세션을 더 이상 볼 수 없습니다",
+		"This link has expired or was revoked":                      "링크가 만료되었거나 취소되었습니다",
+		"This session is already processing a request":              "이 세션은 이미 요청을 처리하고 있습니다",
+		"Token exchange failed":                                     "토큰 교환에 실패했습니다",
+		"Token exchange returned no access token":                   "토큰 교환 결과에 액세스 토큰이 없습니다",
+		"User not found":                                            "사용자를 찾을 수 없습니다",
+		"Userinfo request failed":                                   "사용자 정보 요청에 실패했습니다",
+		"Userinfo response is missing the subject":                  "사용자 정보 응답에 subject가 없습니다",
+		"Webhook not found":                                         "웹훅을 찾을 수 없습니다",
+		"You cannot delete your own account":                        "자신의 계정은 삭제할 수 없습니다",
+		"a and b are required":                                      "a와 b가 필요합니다",
+		"claude CLI not found on PATH":                              "PATH에서 claude CLI를 찾을 수 없습니다",
+		"cron and prompt are required":                              "cron과 prompt가 필요합니다",
+		"endpoint and keys are required":                            "endpoint와 keys가 필요합니다",
+		"endpoint is required":                                      "endpoint가 필요합니다",
+		"endpoint must be an https URL":                             "endpoint는 https URL이어야 합니다",
+		"mode must be truncate or fork":                             "mode는 truncate나 fork여야 합니다",
+		"mode must be copy or replace":                              "mode는 copy나 replace여야 합니다",
+		"month must be YYYY-MM":                                     "month는 YYYY-MM 형식이어야 합니다",
+		"olderThanDays and largerThanMB must not be negative":       "olderThanDays와 largerThanMB는 음수일 수 없습니다",
+		"path does not belong to this project id":                   "경로가 이 프로젝트에 속하지 않습니다",
+		"process not found":                                         "프로세스를 찾을 수 없습니다",
+		"q is required":                                             "q가 필요합니다",
+		"role must be user or admin":                                "role은 user나 admin이어야 합니다",
+		"sessionId is required":                                     "sessionId가 필요합니다",
+		"settings is required":                                      "settings가 필요합니다",
+		"source is required":                                        "source가 필요합니다",
+		"status must be pending, in_progress, or completed":         "status는 pending, in_progress, completed 중 하나여야 합니다",
+		"url is required":                                           "url이 필요합니다",
+		"username is required":                                      "username이 필요합니다",
+
+		// Diagnostics
+		"Claude CLI is not logged in":    "Claude CLI에 로그인되어 있지 않습니다",
+		"Account has no credit left":     "계정의 크레딧이 바닥났습니다",
+		"Rate limited":                   "요청 한도에 걸렸습니다",
+		"Model is overloaded":            "모델이 과부하 상태입니다",
+		"Model not available":            "모델을 사용할 수 없습니다",
+		"Claude CLI rejected a flag":     "Claude CLI가 플래그를 거부했습니다",
+		"Conversation is too long":       "대화가 너무 깁니다",
+		"Network error reaching the API": "API에 연결하는 중 네트워크 오류가 났습니다",
+		"Sign in with POST /api/auth/claude-login, run `claude` on the server and use /login, or set ANTHROPIC_API_KEY for the server process.": "POST /api/auth/claude-login으로 로그인하거나, 서버에서 `claude`를 실행해 /login을 쓰거나, 서버 프로세스에 ANTHROPIC_API_KEY를 설정하세요.",
+		"Add credit or raise the spend limit of the account the CLI uses.":                                                                      "CLI가 쓰는 계정에 크레딧을 추가하거나 지출 한도를 올리세요.",
+		"Wait for the limit to reset, lower concurrent runs, or switch to a smaller model.":                                                     "한도가 초기화될 때까지 기다리거나, 동시 실행을 줄이거나, 더 작은 모델로 바꾸세요.",
+		"The API is temporarily overloaded; retry in a minute or use another model.":                                                            "API가 일시적으로 과부하 상태입니다. 잠시 후 다시 시도하거나 다른 모델을 쓰세요.",
+		"Check the model name in the request, project settings, or claude.defaultModel.":                                                        "요청, 프로젝트 설정, claude.defaultModel의 모델 이름을 확인하세요.",
+		"The installed claude CLI may be too old or too new for this server; run `claude update` or check claude.extraArgs.":                    "설치된 claude CLI가 이 서버에 비해 너무 오래되었거나 새로울 수 있습니다. `claude update`를 실행하거나 claude.extraArgs를 확인하세요.",
+		"Run /compact in the session or start a new one.":                                                                                       "세션에서 /compact를 실행하거나 새 세션을 시작하세요.",
+		"Check the server's connectivity and proxy settings (HTTPS_PROXY).":                                                                     "서버의 네트워크 연결과 프록시 설정(HTTPS_PROXY)을 확인하세요.",
+
+		// Claude CLI credentials
+		"claude could not reach the API": "claude가 API에 연결하지 못했습니다",
+		"claude is authenticated":        "claude가 인증되어 있습니다",
+		"claude's login has expired":     "claude 로그인이 만료되었습니다",
+		"claude is not logged in":        "claude에 로그인되어 있지 않습니다",
+		"Install it with `npm install -g @anthropic-ai/claude-code` for the user the server runs as.":                    "서버를 실행하는 사용자로 `npm install -g @anthropic-ai/claude-code`를 실행해 설치하세요.",
+		"Sign in again with POST /api/auth/claude-login, or run `claude` on the server and use /login.":                  "POST /api/auth/claude-login으로 다시 로그인하거나, 서버에서 `claude`를 실행해 /login을 쓰세요.",
+		"Sign in with POST /api/auth/claude-login, run `claude` on the server and use /login, or set ANTHROPIC_API_KEY.": "POST /api/auth/claude-login으로 로그인하거나, 서버에서 `claude`를 실행해 /login을 쓰거나, ANTHROPIC_API_KEY를 설정하세요.",
+
+		// Push notifications
+		"Claude finished":                                    "Claude가 작업을 마쳤습니다",
+		"Claude stopped with an error":                       "Claude가 오류로 멈췄습니다",
+		"Claude is waiting for permission":                   "Claude가 권한 승인을 기다리고 있습니다",
+		"A tool needs your approval":                         "도구 사용을 승인해야 합니다",
+		"Permission needed for":                              "권한 승인 필요:",
+		"Claude has a question":                              "Claude가 질문이 있습니다",
+		"Greyzone notifications work":                        "Greyzone 알림이 동작합니다",
+		"You'll be notified when long-running chats finish.": "오래 걸리는 채팅이 끝나면 알려드립니다.",
+	},
+}
+
+var (
+	localesMu sync.Mutex
+	// customCatalogs caches the catalogs read from <dataDir>/locales, nil until first use
+	customCatalogs map[string]map[string]string
+)
+
+func localesDir() string {
+	return serverDataPath("locales")
+}
+
+// loadCustomCatalogs reads <dataDir>/locales/<locale>.json, each an object of English text to translation
+func loadCustomCatalogs() map[string]map[string]string {
+	localesMu.Lock()
+	defer localesMu.Unlock()
+	if customCatalogs != nil {
+		return customCatalogs
+	}
+	customCatalogs = make(map[string]map[string]string)
+	entries, err := os.ReadDir(localesDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("[I18n] Failed to read locales directory: %v", err)
+		}
+		return customCatalogs
+	}
+	for _, entry := range entries {
+		locale := strings.ToLower(strings.TrimSuffix(entry.Name(), ".json"))
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") || !localePattern.MatchString(locale) {
+			continue
+		}
+		var catalog map[string]string
+		if err := loadJSONFile(filepath.Join(localesDir(), entry.Name()), &catalog); err != nil {
+			log.Printf("[I18n] Skipping %s: %v", entry.Name(), err)
+			continue
+		}
+		customCatalogs[locale] = catalog
+	}
+	return customCatalogs
+}
+
+// availableLocales lists en and every locale with a built-in or custom catalog
+func availableLocales() []string {
+	set := map[string]bool{"en": true}
+	for locale := range builtinCatalogs {
+		set[locale] = true
+	}
+	for locale := range loadCustomCatalogs() {
+		set[locale] = true
+	}
+	return sortedKeys(set)
+}
+
+// supportedLocale returns the available locale for tag (exact, then its language), or ""
+func supportedLocale(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return ""
+	}
+	available := availableLocales()
+	for _, candidate := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+		for _, locale := range available {
+			if locale == candidate {
+				return locale
+			}
+		}
+	}
+	return ""
+}
+
+// serverLocale is the configured locale, for strings sent outside a request (e.g. push notifications)
+func serverLocale() string {
+	if locale := supportedLocale(getServerConfig().Locale); locale != "" {
+		return locale
+	}
+	return "en"
+}
+
+// acceptedLocale picks the first available locale from an Accept-Language header, by quality
+func acceptedLocale(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		w := weighted{tag: strings.TrimSpace(fields[0]), q: 1}
+		for _, param := range fields[1:] {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					w.q = q
+				}
+			}
+		}
+		if w.tag != "" && w.tag != "*" && w.q > 0 {
+			tags = append(tags, w)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, w := range tags {
+		if locale := supportedLocale(w.tag); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// requestLocale resolves the locale of a request: the lang query parameter (for WebSocket and
+// EventSource clients, which can't set headers), then Accept-Language, then the server's locale
+func requestLocale(c *gin.Context) string {
+	if c == nil {
+		return serverLocale()
+	}
+	if locale := supportedLocale(c.Query("lang")); locale != "" {
+		return locale
+	}
+	if locale := acceptedLocale(c.GetHeader("Accept-Language")); locale != "" {
+		return locale
+	}
+	return serverLocale()
+}
+
+// translate returns message in locale, or message itself when there's no translation
+// A message with details after a colon ("Session not found: abc") translates its prefix
+func translate(locale, message string) string {
+	if locale == "en" || message == "" {
+		return message
+	}
+	lookup := func(key string) (string, bool) {
+		if text, ok := loadCustomCatalogs()[locale][key]; ok {
+			return text, true
+		}
+		text, ok := builtinCatalogs[locale][key]
+		return text, ok
+	}
+	if text, ok := lookup(message); ok {
+		return text
+	}
+	if prefix, rest, ok := strings.Cut(message, ": "); ok {
+		if text, ok := lookup(prefix); ok {
+			return text + ": " + rest
+		}
+	}
+	return message
+}
+
+// localize translates message into the locale of the request
+func localize(c *gin.Context, message string) string {
+	return translate(requestLocale(c), message)
+}
+
+// LocaleInfo is the response of GET /api/i18n
+type LocaleInfo struct {
+	Locale    string   `json:"locale"`    // the locale this request resolved to
+	Default   string   `json:"default"`   // the server's configured locale
+	Available []string `json:"available"` // locales with a catalog, plus en
+}
+
+// GetLocaleInfo handles GET /api/i18n
+// Reports which locale server-generated strings use for this request and which are available
+// Send Accept-Language or ?lang= to choose one
+func GetLocaleInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, LocaleInfo{Locale: requestLocale(c), Default: serverLocale(), Available: availableLocales()})
+}
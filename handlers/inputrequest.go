@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// InputRequest is what a session waiting for input is blocked on
+type InputRequest struct {
+	Kind      string `json:"kind"`                // permission or question
+	Tool      string `json:"tool,omitempty"`      // the tool needing approval
+	Detail    string `json:"detail,omitempty"`    // the question asked
+	ToolUseID string `json:"toolUseId,omitempty"` // the AskUserQuestion call its answer arrives for
+}
+
+// inputEvent is what input detection needs from a stream line
+type inputEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content []struct {
+			Type      string          `json:"type"`
+			ID        string          `json:"id"`
+			Name      string          `json:"name"`
+			Input     json.RawMessage `json:"input"`
+			ToolUseID string          `json:"tool_use_id"`
+		} `json:"content"`
+	} `json:"message"`
+}
+
+// detectInputRequest returns what a stream line shows claude waiting for the user on, or nil:
+// a tool it wasn't allowed to use, or an AskUserQuestion call
+func detectInputRequest(line string) *InputRequest {
+	if strings.Contains(line, "requested permissions") {
+		req := &InputRequest{Kind: "permission"}
+		if m := permissionRequestRegex.FindStringSubmatch(line); m != nil {
+			req.Tool = m[1]
+		}
+		return req
+	}
+	if !strings.Contains(line, `"AskUserQuestion"`) {
+		return nil
+	}
+	var event inputEvent
+	if json.Unmarshal([]byte(line), &event) != nil || event.Type != "assistant" {
+		return nil
+	}
+	for _, block := range event.Message.Content {
+		if block.Type != "tool_use" || block.Name != "AskUserQuestion" {
+			continue
+		}
+		var input struct {
+			Questions []struct {
+				Question string `json:"question"`
+			} `json:"questions"`
+			Question string `json:"question"`
+		}
+		json.Unmarshal(block.Input, &input)
+		req := &InputRequest{Kind: "question", Detail: input.Question, ToolUseID: block.ID}
+		if req.Detail == "" && len(input.Questions) > 0 {
+			req.Detail = input.Questions[0].Question
+		}
+		return req
+	}
+	return nil
+}
+
+// answeredToolUse returns the tool_use IDs a user line carries results for
+func answeredToolUse(line string) []string {
+	if !strings.Contains(line, "tool_result") {
+		return nil
+	}
+	var event inputEvent
+	if json.Unmarshal([]byte(line), &event) != nil || event.Type != "user" {
+		return nil
+	}
+	var ids []string
+	for _, block := range event.Message.Content {
+		if block.Type == "tool_result" {
+			ids = append(ids, block.ToolUseID)
+		}
+	}
+	return ids
+}
+
+// trackInputRequest marks the session waiting for input when a stream line shows claude blocked on
+// the user, and notifies the owner once per run; the question's answer clears it again
+// A permission request stays until the session's next prompt, since claude ends the run without it
+func trackInputRequest(owner string, sessionID string, line string, notified *bool) {
+	if req := detectInputRequest(line); req != nil {
+		stateManager.setSessionWaiting(sessionID, req)
+		if !*notified {
+			*notified = notifyInputRequest(owner, sessionID, req)
+		}
+		return
+	}
+	if ids := answeredToolUse(line); len(ids) > 0 {
+		stateManager.answerInputRequest(sessionID, ids)
+	}
+}
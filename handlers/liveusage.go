@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// usageEventInterval is the least time between two usage events of a run
+const usageEventInterval = 2 * time.Second
+
+// ModelPrice is what a model costs in USD per million tokens; cache reads cost a tenth of
+// input and cache writes a quarter more, as the API bills them
+type ModelPrice struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// RunUsage is the payload of a usage event: the tokens a chat run has used so far and their estimated cost
+type RunUsage struct {
+	InputTokens              int     `json:"inputTokens"`
+	OutputTokens             int     `json:"outputTokens"`
+	CacheReadInputTokens     int     `json:"cacheReadInputTokens"`
+	CacheCreationInputTokens int     `json:"cacheCreationInputTokens"`
+	CostUSD                  float64 `json:"costUsd"` // estimated from claude.pricing until final
+	Model                    string  `json:"model,omitempty"`
+	// Final is set on the event sent with the run's result, whose totals are the CLI's own when it reports them
+	Final bool `json:"final"`
+}
+
+// usageLine is the subset of an assistant stream event that carries its usage
+type usageLine struct {
+	Type    string `json:"type"`
+	Message struct {
+		ID    string        `json:"id"`
+		Model string        `json:"model"`
+		Usage *messageUsage `json:"usage"`
+	} `json:"message"`
+}
+
+// usageTracker adds up the usage of a run's assistant messages, subagents' included
+// An assistant message is streamed as one event per content block, each repeating its usage so far,
+// so the latest usage of each message ID is kept
+type usageTracker struct {
+	messages map[string]messageUsage
+	models   map[string]string
+	model    string
+	lastSent time.Time
+	pending  bool
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{messages: make(map[string]messageUsage), models: make(map[string]string)}
+}
+
+// modelPrice returns the claude.pricing entry whose key the model name contains, preferring the longest key
+func modelPrice(model string) (ModelPrice, bool) {
+	pricing := getServerConfig().Claude.Pricing
+	keys := make([]string, 0, len(pricing))
+	for key := range pricing {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	model = strings.ToLower(model)
+	for _, key := range keys {
+		if strings.Contains(model, strings.ToLower(key)) {
+			return pricing[key], true
+		}
+	}
+	return ModelPrice{}, false
+}
+
+// usageCost estimates what usage costs on a model, 0 when it has no price
+func usageCost(u messageUsage, model string) float64 {
+	price, ok := modelPrice(model)
+	if !ok {
+		return 0
+	}
+	return (float64(u.InputTokens)*price.Input +
+		float64(u.OutputTokens)*price.Output +
+		float64(u.CacheReadInputTokens)*price.Input*0.1 +
+		float64(u.CacheCreationInputTokens)*price.Input*1.25) / 1e6
+}
+
+// total adds up the messages seen so far
+func (t *usageTracker) total() RunUsage {
+	usage := RunUsage{Model: t.model}
+	for id, u := range t.messages {
+		usage.InputTokens += u.InputTokens
+		usage.OutputTokens += u.OutputTokens
+		usage.CacheReadInputTokens += u.CacheReadInputTokens
+		usage.CacheCreationInputTokens += u.CacheCreationInputTokens
+		usage.CostUSD += usageCost(u, t.models[id])
+	}
+	return usage
+}
+
+// track reads a claude output line and returns a usage event when one is due:
+// at most every usageEventInterval while the run streams, and always for its result
+func (t *usageTracker) track(line string) (RunUsage, bool) {
+	if result := parseResultEvent(line); result != nil {
+		usage := t.total()
+		if result.Usage.InputTokens > 0 || result.Usage.OutputTokens > 0 {
+			usage.InputTokens = int(result.Usage.InputTokens)
+			usage.OutputTokens = int(result.Usage.OutputTokens)
+			usage.CacheReadInputTokens = int(result.Usage.CacheReadInputTokens)
+			usage.CacheCreationInputTokens = int(result.Usage.CacheCreationInputTokens)
+		}
+		if result.TotalCostUSD > 0 {
+			usage.CostUSD = result.TotalCostUSD
+		}
+		usage.Final = true
+		t.pending = false
+		return usage, true
+	}
+	var event usageLine
+	if strings.Contains(line, `"usage"`) && json.Unmarshal([]byte(line), &event) == nil &&
+		event.Type == "assistant" && event.Message.Usage != nil {
+		id := event.Message.ID
+		if id == "" {
+			id = fmt.Sprintf("#%d", len(t.messages))
+		}
+		if prev, ok := t.messages[id]; !ok || prev != *event.Message.Usage {
+			t.messages[id] = *event.Message.Usage
+			t.models[id] = event.Message.Model
+			if event.Message.Model != "" {
+				t.model = event.Message.Model
+			}
+			t.pending = true
+		}
+	}
+	// An update held back by the interval goes out with a later line
+	if !t.pending || time.Since(t.lastSent) < usageEventInterval {
+		return RunUsage{}, false
+	}
+	t.lastSent = time.Now()
+	t.pending = false
+	return t.total(), true
+}
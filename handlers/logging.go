@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// requestIDHeader carries the request ID to and from clients/proxies
+	requestIDHeader = "X-Request-ID"
+	// logBufferSize is how many recent entries GET /api/server/logs can return
+	logBufferSize = 2000
+)
+
+// logLevel is adjustable at runtime; stdlib log.Printf calls are logged at Info
+var logLevel = new(slog.LevelVar)
+
+// RotatingFile is an io.Writer that rotates the log file when it exceeds maxSize
+// Rotated files are named server.log.1 (newest) … server.log.N (oldest)
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+// NewRotatingFile opens (or creates) path for appending
+func NewRotatingFile(path string, maxSizeMB int, maxBackups int) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path:       path,
+		maxSize:    int64(maxSizeMB) * 1024 * 1024,
+		maxBackups: maxBackups,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	return nil
+}
+
+func (r *RotatingFile) rotate() error {
+	r.file.Close()
+	for i := r.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if r.maxBackups > 0 {
+		os.Rename(r.path, r.path+".1")
+	} else {
+		os.Remove(r.path)
+	}
+	return r.open()
+}
+
+// Write implements io.Writer
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize && r.size > 0 {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// logRing keeps the most recent JSON log lines in memory
+type logRing struct {
+	mu      sync.Mutex
+	entries []json.RawMessage
+	next    int
+	full    bool
+}
+
+var recentLogs = &logRing{entries: make([]json.RawMessage, logBufferSize)}
+
+// Write implements io.Writer; each slog record is written as a single line
+func (l *logRing) Write(p []byte) (int, error) {
+	line := bytes.TrimSpace(p)
+	if len(line) == 0 || !json.Valid(line) {
+		return len(p), nil
+	}
+	entry := make(json.RawMessage, len(line))
+	copy(entry, line)
+
+	l.mu.Lock()
+	l.entries[l.next] = entry
+	l.next = (l.next + 1) % len(l.entries)
+	if l.next == 0 {
+		l.full = true
+	}
+	l.mu.Unlock()
+	return len(p), nil
+}
+
+// snapshot returns buffered entries, oldest first
+func (l *logRing) snapshot() []json.RawMessage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.full {
+		return append([]json.RawMessage(nil), l.entries[:l.next]...)
+	}
+	out := make([]json.RawMessage, 0, len(l.entries))
+	out = append(out, l.entries[l.next:]...)
+	return append(out, l.entries[:l.next]...)
+}
+
+// parseLogLevel maps "debug", "info", "warn", "error" to slog levels
+func parseLogLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if level == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("invalid log level: %s", level)
+	}
+	return l, nil
+}
+
+// SetupLogging installs a structured logger writing to stdout, a rotating file, and
+// the in-memory buffer behind GET /api/server/logs
+// Existing log.Printf calls are routed through it at Info level
+func SetupLogging(logDir string, cfg LoggingConfig) error {
+	level, err := parseLogLevel(cfg.Level)
+	if err != nil {
+		return err
+	}
+	logLevel.Set(level)
+
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	logPath := filepath.Join(logDir, "server.log")
+	file, err := NewRotatingFile(logPath, cfg.MaxSizeMB, cfg.MaxBackups)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	opts := &slog.HandlerOptions{Level: logLevel}
+	var console slog.Handler
+	if cfg.Format == "text" {
+		console = slog.NewTextHandler(os.Stdout, opts)
+	} else {
+		console = slog.NewJSONHandler(os.Stdout, opts)
+	}
+	persistent := slog.NewJSONHandler(io.MultiWriter(file, recentLogs), opts)
+	slog.SetDefault(slog.New(fanoutHandler{console, persistent}))
+	log.SetFlags(0)
+
+	slog.Info("Logging initialized", "file", logPath, "level", level.String())
+	return nil
+}
+
+// fanoutHandler sends each record to several handlers
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			h.Handle(ctx, r.Clone())
+		}
+	}
+	return nil
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, h := range f {
+		out[i] = h.WithGroup(name)
+	}
+	return out
+}
+
+// RequestIDMiddleware assigns each request an ID (or keeps a valid incoming one)
+// and returns it in the X-Request-ID response header
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIDHeader)
+		if id == "" || len(id) > 64 || strings.ContainsAny(id, " \t\r\n\"") {
+			id = generateID()
+		}
+		c.Set("requestId", id)
+		c.Header(requestIDHeader, id)
+		c.Next()
+	}
+}
+
+// requestID returns the ID assigned by RequestIDMiddleware
+func requestID(c *gin.Context) string {
+	return c.GetString("requestId")
+}
+
+// requestLogger returns a logger tagged with the request ID and user
+func requestLogger(c *gin.Context) *slog.Logger {
+	logger := slog.Default().With("requestId", requestID(c))
+	if u := currentUser(c); u != nil {
+		logger = logger.With("user", u.Username)
+	}
+	return logger
+}
+
+// GetServerLogs handles GET /api/server/logs
+// Query parameters:
+//   - limit: maximum entries to return, newest last (default: 200)
+//   - level: minimum level (debug, info, warn, error)
+//   - requestId: only entries for this request
+//   - q: substring filter on the raw entry
+func GetServerLogs(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "200"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+	minLevel, err := parseLogLevel(c.Query("level"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	reqID := c.Query("requestId")
+	query := c.Query("q")
+
+	entries := recentLogs.snapshot()
+	filtered := make([]json.RawMessage, 0, len(entries))
+	for _, entry := range entries {
+		if query != "" && !bytes.Contains(entry, []byte(query)) {
+			continue
+		}
+		var fields struct {
+			Level     string `json:"level"`
+			RequestID string `json:"requestId"`
+		}
+		json.Unmarshal(entry, &fields)
+		if reqID != "" && fields.RequestID != reqID {
+			continue
+		}
+		if level, err := parseLogLevel(fields.Level); err == nil && level < minLevel {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	if len(filtered) > limit {
+		filtered = filtered[len(filtered)-limit:]
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"entries": filtered,
+		"level":   logLevel.Level().String(),
+	})
+}
@@ -0,0 +1,844 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// lspIdleTimeout is how long a language server is kept once its last client disconnects
+	lspIdleTimeout = 5 * time.Minute
+	// lspStartTimeout bounds starting and initializing a language server
+	lspStartTimeout = 60 * time.Second
+	// lspRequestTimeout bounds one hover, definition, or references request
+	lspRequestTimeout = 15 * time.Second
+	// lspMaxMessage bounds a message from a language server
+	lspMaxMessage = 64 << 20
+)
+
+// LanguageServer is a language server the file viewer can use through GET /api/lsp
+type LanguageServer struct {
+	Name string `yaml:"name" json:"name"`
+	// Command speaks LSP on stdin and stdout, e.g. [gopls] or [typescript-language-server, --stdio]
+	Command []string `yaml:"command" json:"command"`
+	// Languages are the file languages it serves, as POST /api/file/read reports them (go, typescript, ...)
+	Languages             []string               `yaml:"languages" json:"languages"`
+	InitializationOptions map[string]interface{} `yaml:"initializationOptions" json:"initializationOptions,omitempty"`
+}
+
+// LanguageServerInfo is a configured language server as GET /api/lsp/servers shows it
+type LanguageServerInfo struct {
+	Name      string   `json:"name"`
+	Languages []string `json:"languages"`
+	Available bool     `json:"available"` // its command is on the server's PATH
+}
+
+// LanguageServerProcess is a running language server
+type LanguageServerProcess struct {
+	Name      string `json:"name"`
+	WorkDir   string `json:"workDir"`
+	Clients   int    `json:"clients"`
+	Documents int    `json:"documents"` // open in the file viewer
+	StartedAt string `json:"startedAt"`
+}
+
+// LSPMessage is a message on the GET /api/lsp WebSocket, in both directions
+type LSPMessage struct {
+	ID   int    `json:"id,omitempty"` // echoed on the result or error of a request
+	Type string `json:"type"`         // requests: open, change, close, hover, definition, references; replies: result, error, diagnostics, serverExited
+	Path string `json:"path,omitempty"`
+	// Content is the document's text for open (default: the file on disk) and change
+	Content *string `json:"content,omitempty"`
+	// Line and Character are 0-based, Character in UTF-16 code units as in LSP
+	Line      int `json:"line,omitempty"`
+	Character int `json:"character,omitempty"`
+
+	Server      string          `json:"server,omitempty"`
+	Result      interface{}     `json:"result,omitempty"`
+	Diagnostics json.RawMessage `json:"diagnostics,omitempty"`
+	Code        ErrorCode       `json:"code,omitempty"`
+	Message     string          `json:"message,omitempty"`
+}
+
+// LSPLocation is a definition or reference
+type LSPLocation struct {
+	Path  string          `json:"path"`
+	Range json.RawMessage `json:"range"`
+}
+
+// validateLanguageServers checks the languageServers section
+func validateLanguageServers(servers []LanguageServer) error {
+	seen := make(map[string]bool)
+	for i, s := range servers {
+		if s.Name == "" || seen[s.Name] {
+			return fmt.Errorf("languageServers[%d]: name is required and must be unique", i)
+		}
+		seen[s.Name] = true
+		if len(s.Command) == 0 || s.Command[0] == "" {
+			return fmt.Errorf("languageServers[%d]: command is required", i)
+		}
+		if len(s.Languages) == 0 {
+			return fmt.Errorf("languageServers[%d]: languages is required", i)
+		}
+	}
+	return nil
+}
+
+// languageServerFor returns the configured server of a file's language
+func languageServerFor(language string) (LanguageServer, bool) {
+	for _, s := range getServerConfig().LanguageServers {
+		for _, l := range s.Languages {
+			if strings.EqualFold(l, language) {
+				return s, true
+			}
+		}
+	}
+	return LanguageServer{}, false
+}
+
+// lspLanguageIDs are the LSP language identifiers that differ from the viewer's language names
+var lspLanguageIDs = map[string]string{
+	"tsx":  "typescriptreact",
+	"jsx":  "javascriptreact",
+	"bash": "shellscript",
+}
+
+// fileURI is the file:// URI of a local path
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
+
+// uriPath is the path of a file:// URI; other URIs are returned as they are
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return u.Path
+}
+
+// lspRPCMessage is a JSON-RPC message to or from a language server
+type lspRPCMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// lspConn speaks JSON-RPC with Content-Length framing to a language server
+type lspConn struct {
+	w   io.Writer
+	wmu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan lspRPCMessage
+	closed  bool
+
+	onNotify func(method string, params json.RawMessage)
+}
+
+func (c *lspConn) write(msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(data)
+	return err
+}
+
+// call sends a request and waits for its result
+func (c *lspConn) call(ctx context.Context, method string, params interface{}) (json.RawMessage, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("the language server exited")
+	}
+	c.nextID++
+	id := c.nextID
+	reply := make(chan lspRPCMessage, 1)
+	c.pending[id] = reply
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	if err := c.write(map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}); err != nil {
+		return nil, err
+	}
+	select {
+	case msg, ok := <-reply:
+		if !ok {
+			return nil, fmt.Errorf("the language server exited")
+		}
+		if msg.Error != nil {
+			return nil, fmt.Errorf("%s: %s", method, msg.Error.Message)
+		}
+		return msg.Result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// notify sends a notification
+func (c *lspConn) notify(method string, params interface{}) error {
+	return c.write(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// readLoop reads the server's messages until it closes stdout
+func (c *lspConn) readLoop(r io.Reader) {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	defer func() {
+		c.mu.Lock()
+		c.closed = true
+		for id, reply := range c.pending {
+			close(reply)
+			delete(c.pending, id)
+		}
+		c.mu.Unlock()
+	}()
+	for {
+		length := -1
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		if length < 0 || length > lspMaxMessage {
+			return
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(reader, body); err != nil {
+			return
+		}
+		var msg lspRPCMessage
+		if json.Unmarshal(body, &msg) != nil {
+			continue
+		}
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			c.answer(msg)
+		case msg.ID != nil:
+			id, _ := strconv.Atoi(string(*msg.ID))
+			c.mu.Lock()
+			reply := c.pending[id]
+			c.mu.Unlock()
+			if reply != nil {
+				reply <- msg
+			}
+		case c.onNotify != nil:
+			c.onNotify(msg.Method, msg.Params)
+		}
+	}
+}
+
+// answer replies to the server's own requests, which the bridge has no use for, so the server doesn't wait on them
+func (c *lspConn) answer(msg lspRPCMessage) {
+	var result interface{}
+	if msg.Method == "workspace/configuration" {
+		var params struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		json.Unmarshal(msg.Params, &params)
+		result = make([]interface{}, len(params.Items))
+	}
+	c.write(map[string]interface{}{"jsonrpc": "2.0", "id": msg.ID, "result": result})
+}
+
+// lspInstance is a language server running for one working directory, shared by its clients
+type lspInstance struct {
+	key       string
+	server    LanguageServer
+	workDir   string
+	startedAt time.Time
+	cmd       *exec.Cmd
+	conn      *lspConn
+	ready     chan struct{} // closed once initialized or failed
+	err       error
+	exited    chan struct{}
+
+	mu          sync.Mutex
+	clients     map[*lspClient]bool
+	docs        map[string]*lspDoc         // URI -> document
+	diagnostics map[string]json.RawMessage // URI -> latest diagnostics
+	idle        *time.Timer
+}
+
+// lspDoc is a document open in the language server for one or more clients
+type lspDoc struct {
+	version int
+	openers map[*lspClient]bool
+}
+
+// lspClient is a GET /api/lsp WebSocket
+type lspClient struct {
+	conn    *websocket.Conn
+	wmu     sync.Mutex
+	user    *User
+	workDir string
+
+	mu        sync.Mutex
+	instances map[*lspInstance]bool
+}
+
+var (
+	lspMu        sync.Mutex
+	lspInstances = make(map[string]*lspInstance) // server name and working directory -> instance
+)
+
+func (c *lspClient) send(msg LSPMessage) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	c.conn.WriteJSON(msg)
+}
+
+func (c *lspClient) sendError(id int, err error) {
+	c.send(LSPMessage{ID: id, Type: "error", Code: errorCodeFor(err, ErrInvalidRequest), Message: err.Error()})
+}
+
+// acquireLanguageServer returns the running instance of server for workDir with client added, starting it if needed
+func acquireLanguageServer(server LanguageServer, workDir string, client *lspClient) (*lspInstance, error) {
+	key := server.Name + "\x00" + workDir
+	lspMu.Lock()
+	inst := lspInstances[key]
+	if inst == nil {
+		inst = &lspInstance{
+			key:         key,
+			server:      server,
+			workDir:     workDir,
+			startedAt:   time.Now(),
+			ready:       make(chan struct{}),
+			exited:      make(chan struct{}),
+			clients:     make(map[*lspClient]bool),
+			docs:        make(map[string]*lspDoc),
+			diagnostics: make(map[string]json.RawMessage),
+		}
+		lspInstances[key] = inst
+		go inst.start()
+	}
+	inst.mu.Lock()
+	inst.clients[client] = true
+	if inst.idle != nil {
+		inst.idle.Stop()
+		inst.idle = nil
+	}
+	inst.mu.Unlock()
+	lspMu.Unlock()
+
+	client.mu.Lock()
+	client.instances[inst] = true
+	client.mu.Unlock()
+
+	select {
+	case <-inst.ready:
+	case <-time.After(lspStartTimeout):
+		return nil, withCode(http.StatusGatewayTimeout, ErrUnavailable, fmt.Errorf("language server %s did not start in time", server.Name))
+	}
+	if inst.err != nil {
+		return nil, inst.err
+	}
+	return inst, nil
+}
+
+// start runs and initializes the language server; ready is closed either way
+func (inst *lspInstance) start() {
+	defer close(inst.ready)
+	fail := func(err error) {
+		inst.err = withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("language server %s: %w", inst.server.Name, err))
+		log.Printf("[LSP] %v", inst.err)
+		inst.forget()
+	}
+
+	cmd := exec.Command(inst.server.Command[0], inst.server.Command[1:]...)
+	cmd.Dir = inst.workDir
+	cmd.Env = os.Environ()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		fail(err)
+		return
+	}
+	startInProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		fail(err)
+		return
+	}
+	limitProcessResources(cmd)
+	inst.cmd = cmd
+	inst.conn = &lspConn{w: stdin, pending: make(map[int]chan lspRPCMessage), onNotify: inst.handleNotification}
+	go inst.conn.readLoop(stdout)
+	go func() {
+		cmd.Wait()
+		close(inst.exited)
+		inst.forget()
+		inst.mu.Lock()
+		clients := make([]*lspClient, 0, len(inst.clients))
+		for client := range inst.clients {
+			clients = append(clients, client)
+		}
+		inst.mu.Unlock()
+		for _, client := range clients {
+			client.send(LSPMessage{Type: "serverExited", Server: inst.server.Name})
+		}
+		log.Printf("[LSP] %s for %s exited", inst.server.Name, inst.workDir)
+	}()
+
+	root := fileURI(inst.workDir)
+	ctx, cancel := context.WithTimeout(context.Background(), lspStartTimeout)
+	defer cancel()
+	_, err = inst.conn.call(ctx, "initialize", map[string]interface{}{
+		"processId":             os.Getpid(),
+		"rootUri":               root,
+		"rootPath":              inst.workDir,
+		"workspaceFolders":      []interface{}{map[string]string{"uri": root, "name": filepath.Base(inst.workDir)}},
+		"initializationOptions": inst.server.InitializationOptions,
+		"capabilities": map[string]interface{}{
+			"textDocument": map[string]interface{}{
+				"synchronization":    map[string]interface{}{"dynamicRegistration": false},
+				"hover":              map[string]interface{}{"contentFormat": []string{"markdown", "plaintext"}},
+				"definition":         map[string]interface{}{"linkSupport": true},
+				"references":         map[string]interface{}{},
+				"publishDiagnostics": map[string]interface{}{"relatedInformation": true},
+			},
+			"workspace": map[string]interface{}{"workspaceFolders": true, "configuration": true},
+		},
+	})
+	if err != nil {
+		inst.kill()
+		fail(err)
+		return
+	}
+	inst.conn.notify("initialized", map[string]interface{}{})
+	log.Printf("[LSP] Started %s for %s", inst.server.Name, inst.workDir)
+}
+
+// forget removes the instance from the registry, so the next client starts a new one
+func (inst *lspInstance) forget() {
+	lspMu.Lock()
+	if lspInstances[inst.key] == inst {
+		delete(lspInstances, inst.key)
+	}
+	lspMu.Unlock()
+}
+
+func (inst *lspInstance) kill() {
+	if inst.cmd != nil && inst.cmd.Process != nil {
+		inst.cmd.Process.Kill()
+	}
+}
+
+// shutdown asks the server to exit, killing it if it doesn't
+func (inst *lspInstance) shutdown() {
+	inst.forget()
+	if inst.conn == nil {
+		inst.kill()
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	inst.conn.call(ctx, "shutdown", nil)
+	cancel()
+	inst.conn.notify("exit", nil)
+	select {
+	case <-inst.exited:
+	case <-time.After(5 * time.Second):
+		inst.kill()
+	}
+}
+
+// handleNotification forwards diagnostics to the clients with the document open
+func (inst *lspInstance) handleNotification(method string, params json.RawMessage) {
+	if method != "textDocument/publishDiagnostics" {
+		return
+	}
+	var p struct {
+		URI         string          `json:"uri"`
+		Diagnostics json.RawMessage `json:"diagnostics"`
+	}
+	if json.Unmarshal(params, &p) != nil {
+		return
+	}
+	inst.mu.Lock()
+	inst.diagnostics[p.URI] = p.Diagnostics
+	var clients []*lspClient
+	if doc := inst.docs[p.URI]; doc != nil {
+		for client := range doc.openers {
+			clients = append(clients, client)
+		}
+	}
+	inst.mu.Unlock()
+	for _, client := range clients {
+		client.send(LSPMessage{Type: "diagnostics", Path: uriPath(p.URI), Server: inst.server.Name, Diagnostics: p.Diagnostics})
+	}
+}
+
+// open sends a document's text to the server, opening it for client
+func (inst *lspInstance) open(client *lspClient, path, language, text string) error {
+	uri := fileURI(path)
+	inst.mu.Lock()
+	doc := inst.docs[uri]
+	var err error
+	if doc == nil {
+		doc = &lspDoc{version: 1, openers: make(map[*lspClient]bool)}
+		inst.docs[uri] = doc
+		languageID := language
+		if id, ok := lspLanguageIDs[language]; ok {
+			languageID = id
+		}
+		err = inst.conn.notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "languageId": languageID, "version": doc.version, "text": text},
+		})
+	} else {
+		doc.version++
+		err = inst.conn.notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": doc.version},
+			"contentChanges": []interface{}{map[string]string{"text": text}},
+		})
+	}
+	doc.openers[client] = true
+	diagnostics := inst.diagnostics[uri]
+	inst.mu.Unlock()
+	if diagnostics != nil {
+		client.send(LSPMessage{Type: "diagnostics", Path: path, Server: inst.server.Name, Diagnostics: diagnostics})
+	}
+	return err
+}
+
+// close closes a document for client, and in the server once no client has it open
+func (inst *lspInstance) close(client *lspClient, uri string) {
+	inst.mu.Lock()
+	defer inst.mu.Unlock()
+	doc := inst.docs[uri]
+	if doc == nil {
+		return
+	}
+	delete(doc.openers, client)
+	if len(doc.openers) == 0 {
+		delete(inst.docs, uri)
+		delete(inst.diagnostics, uri)
+		inst.conn.notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]string{"uri": uri}})
+	}
+}
+
+// release drops a disconnected client; the server is shut down after lspIdleTimeout without clients
+func (inst *lspInstance) release(client *lspClient) {
+	inst.mu.Lock()
+	var uris []string
+	for uri, doc := range inst.docs {
+		if doc.openers[client] {
+			uris = append(uris, uri)
+		}
+	}
+	inst.mu.Unlock()
+	for _, uri := range uris {
+		inst.close(client, uri)
+	}
+	inst.mu.Lock()
+	delete(inst.clients, client)
+	if len(inst.clients) == 0 && inst.err == nil {
+		inst.idle = time.AfterFunc(lspIdleTimeout, func() {
+			lspMu.Lock()
+			inst.mu.Lock()
+			unused := len(inst.clients) == 0
+			inst.mu.Unlock()
+			if unused && lspInstances[inst.key] == inst {
+				delete(lspInstances, inst.key)
+			}
+			lspMu.Unlock()
+			if unused {
+				log.Printf("[LSP] Stopping idle %s for %s", inst.server.Name, inst.workDir)
+				inst.shutdown()
+			}
+		})
+	}
+	inst.mu.Unlock()
+}
+
+// lspLocations converts a definition or references result (Location, Location[], or LocationLink[]) to paths
+func lspLocations(raw json.RawMessage) []LSPLocation {
+	var list []struct {
+		URI                  string          `json:"uri"`
+		Range                json.RawMessage `json:"range"`
+		TargetURI            string          `json:"targetUri"`
+		TargetSelectionRange json.RawMessage `json:"targetSelectionRange"`
+	}
+	if json.Unmarshal(raw, &list) != nil {
+		var one struct {
+			URI   string          `json:"uri"`
+			Range json.RawMessage `json:"range"`
+		}
+		if json.Unmarshal(raw, &one) != nil || one.URI == "" {
+			return []LSPLocation{}
+		}
+		return []LSPLocation{{Path: uriPath(one.URI), Range: one.Range}}
+	}
+	locations := []LSPLocation{}
+	for _, l := range list {
+		if l.TargetURI != "" {
+			locations = append(locations, LSPLocation{Path: uriPath(l.TargetURI), Range: l.TargetSelectionRange})
+		} else if l.URI != "" {
+			locations = append(locations, LSPLocation{Path: uriPath(l.URI), Range: l.Range})
+		}
+	}
+	return locations
+}
+
+// lspDocumentText returns the text of a local file for didOpen
+func lspDocumentText(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", withCode(http.StatusNotFound, ErrNotFound, fmt.Errorf("File does not exist: %s", path))
+		}
+		return "", err
+	}
+	if info.IsDir() || info.Size() > maxFileSize {
+		return "", fmt.Errorf("not a text file under 1MB: %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) {
+		return "", withCode(http.StatusUnsupportedMediaType, ErrUnsupportedMedia, fmt.Errorf("File is binary: %s", path))
+	}
+	return string(data), nil
+}
+
+// handle answers one message from the client
+func (c *lspClient) handle(msg LSPMessage) error {
+	path := filepath.Clean(msg.Path)
+	if msg.Path == "" || !filepath.IsAbs(path) {
+		return fmt.Errorf("path must be absolute")
+	}
+	if !userCanAccessPath(c.user, path) {
+		return withCode(http.StatusForbidden, ErrPathForbidden, fmt.Errorf("Access to %s is not allowed", path))
+	}
+	language := langMap[strings.ToLower(filepath.Ext(path))]
+	server, ok := languageServerFor(language)
+	if !ok {
+		return withCode(http.StatusNotFound, ErrNotFound, fmt.Errorf("no language server is configured for %s", path))
+	}
+
+	if msg.Type == "close" {
+		lspMu.Lock()
+		inst := lspInstances[server.Name+"\x00"+c.workDir]
+		lspMu.Unlock()
+		if inst != nil {
+			inst.close(c, fileURI(path))
+		}
+		return nil
+	}
+
+	inst, err := acquireLanguageServer(server, c.workDir, c)
+	if err != nil {
+		return err
+	}
+	switch msg.Type {
+	case "open", "change":
+		var text string
+		if msg.Content != nil {
+			text = *msg.Content
+		} else if msg.Type == "change" {
+			return fmt.Errorf("change needs content")
+		} else if text, err = lspDocumentText(path); err != nil {
+			return err
+		}
+		if err := inst.open(c, path, language, text); err != nil {
+			return err
+		}
+		if msg.ID != 0 {
+			c.send(LSPMessage{ID: msg.ID, Type: "result", Server: server.Name, Result: map[string]string{"path": path}})
+		}
+		return nil
+	case "hover", "definition", "references":
+		// A document the client never opened is opened from disk, as the server needs its text
+		inst.mu.Lock()
+		doc := inst.docs[fileURI(path)]
+		opened := doc != nil && doc.openers[c]
+		inst.mu.Unlock()
+		if !opened {
+			text, err := lspDocumentText(path)
+			if err != nil {
+				return err
+			}
+			if err := inst.open(c, path, language, text); err != nil {
+				return err
+			}
+		}
+		params := map[string]interface{}{
+			"textDocument": map[string]string{"uri": fileURI(path)},
+			"position":     map[string]int{"line": msg.Line, "character": msg.Character},
+		}
+		if msg.Type == "references" {
+			params["context"] = map[string]bool{"includeDeclaration": true}
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), lspRequestTimeout)
+		defer cancel()
+		raw, err := inst.conn.call(ctx, "textDocument/"+msg.Type, params)
+		if err != nil {
+			return withCode(http.StatusBadGateway, ErrUpstream, err)
+		}
+		var result interface{} = raw
+		if msg.Type != "hover" {
+			result = lspLocations(raw)
+		} else if string(raw) == "null" {
+			result = nil
+		}
+		c.send(LSPMessage{ID: msg.ID, Type: "result", Server: server.Name, Result: result})
+		return nil
+	}
+	return fmt.Errorf("unknown message type: %s", msg.Type)
+}
+
+// LSPHandler handles GET /api/lsp, a WebSocket bridging the file viewer to the configured language servers
+// Each server runs once per working directory and is shared by the clients there. Clients send
+// open/change/close to sync documents and hover/definition/references requests, and get results
+// and diagnostics for the documents they have open
+// Query parameters:
+//   - workDir: the project the servers are rooted at (default: the session's working directory)
+//   - sessionId: the session whose working directory to use
+func LSPHandler(c *gin.Context) {
+	user := currentUser(c)
+	workDir := c.Query("workDir")
+	if sessionID := c.Query("sessionId"); workDir == "" && sessionID != "" && userCanAccessSession(user, sessionID) {
+		workDir = GetSessionWorkDir(sessionID)
+	}
+	if workDir == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "workDir is required")
+		return
+	}
+	if isRemotePath(workDir) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Language servers need a local working directory")
+		return
+	}
+	workDir = filepath.Clean(workDir)
+	if !userCanAccessPath(user, workDir) {
+		denyPath(c, workDir)
+		return
+	}
+	if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("Working directory does not exist: %s", workDir))
+		return
+	}
+	if len(getServerConfig().LanguageServers) == 0 {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "No languageServers are configured")
+		return
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("[LSP] Failed to upgrade to WebSocket: %v", err)
+		return
+	}
+	defer ws.Close()
+	client := &lspClient{conn: ws, user: user, workDir: workDir, instances: make(map[*lspInstance]bool)}
+	defer func() {
+		client.mu.Lock()
+		instances := client.instances
+		client.mu.Unlock()
+		for inst := range instances {
+			inst.release(client)
+		}
+	}()
+
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg LSPMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			client.sendError(0, err)
+			continue
+		}
+		handle := func() {
+			if err := client.handle(msg); err != nil {
+				client.sendError(msg.ID, err)
+			}
+		}
+		// Document changes apply in order; requests may wait on a slow answer, so each runs on its own
+		switch msg.Type {
+		case "hover", "definition", "references":
+			go handle()
+		default:
+			handle()
+		}
+	}
+}
+
+// ListLanguageServers handles GET /api/lsp/servers
+// Returns the configured language servers and the ones running in directories the user can access
+func ListLanguageServers(c *gin.Context) {
+	user := currentUser(c)
+	servers := []LanguageServerInfo{}
+	for _, s := range getServerConfig().LanguageServers {
+		_, err := exec.LookPath(s.Command[0])
+		servers = append(servers, LanguageServerInfo{Name: s.Name, Languages: s.Languages, Available: err == nil})
+	}
+	running := []LanguageServerProcess{}
+	lspMu.Lock()
+	for _, inst := range lspInstances {
+		if !userCanAccessPath(user, inst.workDir) {
+			continue
+		}
+		inst.mu.Lock()
+		running = append(running, LanguageServerProcess{
+			Name:      inst.server.Name,
+			WorkDir:   inst.workDir,
+			Clients:   len(inst.clients),
+			Documents: len(inst.docs),
+			StartedAt: inst.startedAt.UTC().Format(time.RFC3339),
+		})
+		inst.mu.Unlock()
+	}
+	lspMu.Unlock()
+	sort.Slice(running, func(i, j int) bool {
+		if running[i].WorkDir != running[j].WorkDir {
+			return running[i].WorkDir < running[j].WorkDir
+		}
+		return running[i].Name < running[j].Name
+	})
+	c.JSON(http.StatusOK, gin.H{"servers": servers, "running": running})
+}
@@ -1,10 +1,20 @@
 package handlers
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +26,7 @@ type MCPServerConfig struct {
 	Command string            `json:"command,omitempty"`
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 	Source  string            `json:"source"` // "user" or "project"
 }
 
@@ -36,6 +47,7 @@ type MCPServerConfigRaw struct {
 	Command string            `json:"command,omitempty"`
 	Args    []string          `json:"args,omitempty"`
 	Env     map[string]string `json:"env,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
 }
 
 // MCPServer represents a named MCP server with its configuration
@@ -67,6 +79,7 @@ func loadMCPConfig(path string, source string) ([]MCPServer, error) {
 				Command: rawConfig.Command,
 				Args:    rawConfig.Args,
 				Env:     rawConfig.Env,
+				Headers: rawConfig.Headers,
 				Source:  source,
 			},
 		}
@@ -99,6 +112,7 @@ func loadClaudeConfig(path string, source string) ([]MCPServer, error) {
 				Command: rawConfig.Command,
 				Args:    rawConfig.Args,
 				Env:     rawConfig.Env,
+				Headers: rawConfig.Headers,
 				Source:  source,
 			},
 		}
@@ -114,6 +128,10 @@ func GetMCPServers(c *gin.Context) {
 	if workDir == "" {
 		workDir = "."
 	}
+	if !requireWorkDirAccess(c, workDir) {
+		return
+	}
+	watchProject(currentUser(c), workDir)
 
 	var allServers []MCPServer
 	homeDir, _ := os.UserHomeDir()
@@ -140,3 +158,572 @@ func GetMCPServers(c *gin.Context) {
 		"servers": allServers,
 	})
 }
+
+// MCPServerRequest represents the request body for adding or editing an MCP server
+type MCPServerRequest struct {
+	Scope   string             `json:"scope"` // "user" or "project"
+	WorkDir string             `json:"workDir"`
+	Config  MCPServerConfigRaw `json:"config"`
+}
+
+// MCPTestResult reports the outcome of an MCP connectivity test
+type MCPTestResult struct {
+	Name       string   `json:"name"`
+	Responding bool     `json:"responding"`
+	ServerName string   `json:"serverName,omitempty"`
+	Version    string   `json:"version,omitempty"`
+	Tools      []string `json:"tools"`
+	Error      string   `json:"error,omitempty"`
+	DurationMs int64    `json:"durationMs"`
+}
+
+const mcpTestTimeout = 15 * time.Second
+
+// mcpConfigPath returns the file holding MCP servers for a scope
+// User servers live in ~/.claude.json, project servers in {workDir}/.mcp.json
+func mcpConfigPath(scope string, workDir string) (string, error) {
+	switch scope {
+	case "user":
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(homeDir, ".claude.json"), nil
+	case "project":
+		if workDir == "" {
+			return "", fmt.Errorf("workDir is required for project scope")
+		}
+		return filepath.Join(workDir, ".mcp.json"), nil
+	default:
+		return "", fmt.Errorf("invalid scope: %s", scope)
+	}
+}
+
+// modifyMCPServers loads the mcpServers map from path, applies fn, and writes it back
+// Entries stay raw JSON so fields MCPServerConfigRaw doesn't model survive, as do all other top-level keys;
+// the file keeps its permissions, 0600 when it is created since server env often holds tokens
+func modifyMCPServers(path string, fn func(servers map[string]json.RawMessage) error) error {
+	root := make(map[string]json.RawMessage)
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	servers := make(map[string]json.RawMessage)
+	if raw, ok := root["mcpServers"]; ok {
+		if err := json.Unmarshal(raw, &servers); err != nil {
+			return fmt.Errorf("failed to parse mcpServers: %w", err)
+		}
+	}
+
+	if err := fn(servers); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(servers)
+	if err != nil {
+		return err
+	}
+	root["mcpServers"] = raw
+	return writeJSONFileAtomicMode(path, root, mode)
+}
+
+// validateMCPServerConfig checks that a server config has the fields its transport needs
+func validateMCPServerConfig(cfg *MCPServerConfigRaw) error {
+	if cfg.Type == "" {
+		if cfg.URL != "" {
+			cfg.Type = "http"
+		} else {
+			cfg.Type = "stdio"
+		}
+	}
+	switch cfg.Type {
+	case "stdio":
+		if cfg.Command == "" {
+			return fmt.Errorf("command is required for stdio servers")
+		}
+	case "http", "sse":
+		if cfg.URL == "" {
+			return fmt.Errorf("url is required for %s servers", cfg.Type)
+		}
+	default:
+		return fmt.Errorf("invalid server type: %s", cfg.Type)
+	}
+	return nil
+}
+
+// findMCPServer looks up a server by name in user, legacy user, and project configs
+func findMCPServer(name string, workDir string) (*MCPServer, bool) {
+	homeDir, _ := os.UserHomeDir()
+
+	var candidates []MCPServer
+	if servers, err := loadClaudeConfig(filepath.Join(homeDir, ".claude.json"), "user"); err == nil {
+		candidates = append(candidates, servers...)
+	}
+	if servers, err := loadMCPConfig(filepath.Join(homeDir, ".claude", "mcp.json"), "user"); err == nil {
+		candidates = append(candidates, servers...)
+	}
+	if workDir != "" {
+		if servers, err := loadMCPConfig(filepath.Join(workDir, ".mcp.json"), "project"); err == nil {
+			candidates = append(candidates, servers...)
+		}
+	}
+
+	// Project servers take precedence over user servers
+	var found *MCPServer
+	for i := range candidates {
+		if candidates[i].Name == name {
+			found = &candidates[i]
+		}
+	}
+	return found, found != nil
+}
+
+// AddMCPServer handles POST /api/mcp/:name
+func AddMCPServer(c *gin.Context) {
+	saveMCPServer(c, false)
+}
+
+// UpdateMCPServer handles PUT /api/mcp/:name
+func UpdateMCPServer(c *gin.Context) {
+	saveMCPServer(c, true)
+}
+
+// saveMCPServer adds (mustExist=false) or edits (mustExist=true) a server entry
+func saveMCPServer(c *gin.Context, mustExist bool) {
+	name := c.Param("name")
+	var req MCPServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+
+	if err := validateMCPServerConfig(&req.Config); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	path, err := mcpConfigPath(req.Scope, req.WorkDir)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	status, code := http.StatusOK, ErrInternal
+	err = modifyMCPServers(path, func(servers map[string]json.RawMessage) error {
+		_, exists := servers[name]
+		if mustExist && !exists {
+			status, code = http.StatusNotFound, ErrNotFound
+			return fmt.Errorf("MCP server %s not found", name)
+		}
+		if !mustExist && exists {
+			status, code = http.StatusConflict, ErrConflict
+			return fmt.Errorf("MCP server %s already exists", name)
+		}
+		raw, err := json.Marshal(req.Config)
+		if err != nil {
+			return err
+		}
+		servers[name] = raw
+		return nil
+	})
+	if err != nil {
+		if status == http.StatusOK {
+			status = http.StatusInternalServerError
+		}
+		respondError(c, status, code, err.Error())
+		return
+	}
+
+	source := req.Scope
+	c.JSON(http.StatusOK, MCPServer{
+		Name: name,
+		Config: MCPServerConfig{
+			Type:    req.Config.Type,
+			URL:     req.Config.URL,
+			Command: req.Config.Command,
+			Args:    req.Config.Args,
+			Env:     req.Config.Env,
+			Headers: req.Config.Headers,
+			Source:  source,
+		},
+	})
+}
+
+// DeleteMCPServer handles DELETE /api/mcp/:name
+// Query parameters:
+//   - scope: "user" or "project"
+//   - work_dir: project directory (required for project scope)
+func DeleteMCPServer(c *gin.Context) {
+	name := c.Param("name")
+	path, err := mcpConfigPath(c.Query("scope"), c.Query("work_dir"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	notFound := false
+	err = modifyMCPServers(path, func(servers map[string]json.RawMessage) error {
+		if _, ok := servers[name]; !ok {
+			notFound = true
+			return fmt.Errorf("MCP server %s not found", name)
+		}
+		delete(servers, name)
+		return nil
+	})
+	if err != nil {
+		if notFound {
+			respondError(c, http.StatusNotFound, ErrNotFound, err.Error())
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "name": name})
+}
+
+// TestMCPServer handles POST /api/mcp/:name/test
+// Starts (stdio) or contacts (http) the server, performs the MCP initialize
+// handshake, and lists the tools it exposes
+func TestMCPServer(c *gin.Context) {
+	name := c.Param("name")
+	workDir := c.Query("work_dir")
+	if workDir != "" && !requireWorkDirAccess(c, workDir) {
+		return
+	}
+
+	server, ok := findMCPServer(name, workDir)
+	if !ok {
+		respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("MCP server %s not found", name))
+		return
+	}
+
+	cfg := MCPServerConfigRaw{
+		Type:    server.Config.Type,
+		URL:     server.Config.URL,
+		Command: server.Config.Command,
+		Args:    server.Config.Args,
+		Env:     server.Config.Env,
+		Headers: server.Config.Headers,
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), mcpTestTimeout)
+	defer cancel()
+
+	var result MCPTestResult
+	var err error
+	if cfg.Type == "http" || (cfg.Type == "" && cfg.URL != "") {
+		result, err = testHTTPMCPServer(ctx, cfg)
+	} else if cfg.Type == "sse" {
+		err = fmt.Errorf("connectivity testing is not supported for sse servers")
+	} else {
+		result, err = testStdioMCPServer(ctx, cfg, workDir)
+	}
+
+	result.Name = name
+	result.DurationMs = time.Since(start).Milliseconds()
+	if result.Tools == nil {
+		result.Tools = []string{}
+	}
+	if err != nil {
+		result.Error = err.Error()
+		log.Printf("[MCP] Test of %s failed: %v", name, err)
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// mcpRequest builds a JSON-RPC 2.0 request (or notification when id is 0)
+func mcpRequest(id int, method string, params interface{}) []byte {
+	msg := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"method":  method,
+	}
+	if id != 0 {
+		msg["id"] = id
+	}
+	if params != nil {
+		msg["params"] = params
+	}
+	data, _ := json.Marshal(msg)
+	return data
+}
+
+// mcpInitializeParams are the client parameters sent with initialize
+var mcpInitializeParams = map[string]interface{}{
+	"protocolVersion": "2024-11-05",
+	"capabilities":    map[string]interface{}{},
+	"clientInfo": map[string]interface{}{
+		"name":    "claude-web-ui",
+		"version": "1.0.0",
+	},
+}
+
+// mcpResponse is the subset of a JSON-RPC response used by the tester
+type mcpResponse struct {
+	ID     *int            `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// applyMCPResponses fills a test result from initialize and tools/list results
+func applyMCPResponses(result *MCPTestResult, initResult, toolsResult json.RawMessage) {
+	var init struct {
+		ServerInfo struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+		} `json:"serverInfo"`
+	}
+	if json.Unmarshal(initResult, &init) == nil {
+		result.ServerName = init.ServerInfo.Name
+		result.Version = init.ServerInfo.Version
+	}
+
+	var tools struct {
+		Tools []struct {
+			Name string `json:"name"`
+		} `json:"tools"`
+	}
+	if json.Unmarshal(toolsResult, &tools) == nil {
+		for _, tool := range tools.Tools {
+			result.Tools = append(result.Tools, tool.Name)
+		}
+		sort.Strings(result.Tools)
+	}
+}
+
+// testStdioMCPServer spawns a stdio MCP server and runs initialize + tools/list
+func testStdioMCPServer(ctx context.Context, cfg MCPServerConfigRaw, workDir string) (MCPTestResult, error) {
+	var result MCPTestResult
+
+	cmd := exec.CommandContext(ctx, cfg.Command, cfg.Args...)
+	if workDir != "" {
+		cmd.Dir = workDir
+	}
+	cmd.Env = os.Environ()
+	for key, val := range cfg.Env {
+		cmd.Env = append(cmd.Env, key+"="+val)
+	}
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return result, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return result, err
+	}
+	if err := cmd.Start(); err != nil {
+		return result, fmt.Errorf("failed to start server: %w", err)
+	}
+	defer func() {
+		stdin.Close()
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	responses := make(chan mcpResponse, 10)
+	go func() {
+		defer close(responses)
+		scanner := bufio.NewScanner(stdout)
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 4*1024*1024)
+		for scanner.Scan() {
+			var resp mcpResponse
+			if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil || resp.ID == nil {
+				continue // Skip logs and notifications
+			}
+			responses <- resp
+		}
+	}()
+
+	call := func(id int, method string, params interface{}) (json.RawMessage, error) {
+		if _, err := stdin.Write(append(mcpRequest(id, method, params), '\n')); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", method, err)
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("timed out waiting for %s response", method)
+			case resp, ok := <-responses:
+				if !ok {
+					return nil, fmt.Errorf("server exited before responding to %s", method)
+				}
+				if *resp.ID != id {
+					continue
+				}
+				if resp.Error != nil {
+					return nil, fmt.Errorf("%s failed: %s", method, resp.Error.Message)
+				}
+				return resp.Result, nil
+			}
+		}
+	}
+
+	initResult, err := call(1, "initialize", mcpInitializeParams)
+	if err != nil {
+		return result, err
+	}
+	result.Responding = true
+	stdin.Write(append(mcpRequest(0, "notifications/initialized", nil), '\n'))
+
+	toolsResult, err := call(2, "tools/list", map[string]interface{}{})
+	applyMCPResponses(&result, initResult, toolsResult)
+	return result, err
+}
+
+// testHTTPMCPServer runs initialize + tools/list against a streamable HTTP MCP server
+func testHTTPMCPServer(ctx context.Context, cfg MCPServerConfigRaw) (MCPTestResult, error) {
+	var result MCPTestResult
+	var sessionHeader string
+
+	call := func(id int, method string, params interface{}) (json.RawMessage, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, bytes.NewReader(mcpRequest(id, method, params)))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		for key, val := range cfg.Headers {
+			req.Header.Set(key, val)
+		}
+		if sessionHeader != "" {
+			req.Header.Set("Mcp-Session-Id", sessionHeader)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s request failed: %w", method, err)
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 400 {
+			return nil, fmt.Errorf("%s returned HTTP %d", method, resp.StatusCode)
+		}
+		if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+			sessionHeader = sid
+		}
+		if id == 0 {
+			return nil, nil
+		}
+
+		// Responses are either plain JSON or an SSE stream of JSON messages
+		var payloads [][]byte
+		if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+			scanner := bufio.NewScanner(resp.Body)
+			scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+			for scanner.Scan() {
+				if line := scanner.Text(); strings.HasPrefix(line, "data:") {
+					payloads = append(payloads, []byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))))
+				}
+			}
+		} else {
+			body, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+			if err != nil {
+				return nil, err
+			}
+			payloads = append(payloads, body)
+		}
+
+		for _, payload := range payloads {
+			var rpcResp mcpResponse
+			if err := json.Unmarshal(payload, &rpcResp); err != nil || rpcResp.ID == nil || *rpcResp.ID != id {
+				continue
+			}
+			if rpcResp.Error != nil {
+				return nil, fmt.Errorf("%s failed: %s", method, rpcResp.Error.Message)
+			}
+			return rpcResp.Result, nil
+		}
+		return nil, fmt.Errorf("no response to %s", method)
+	}
+
+	initResult, err := call(1, "initialize", mcpInitializeParams)
+	if err != nil {
+		return result, err
+	}
+	result.Responding = true
+	call(0, "notifications/initialized", nil)
+
+	toolsResult, err := call(2, "tools/list", map[string]interface{}{})
+	applyMCPResponses(&result, initResult, toolsResult)
+	return result, err
+}
+
+// prepareMCPConfig writes a temporary --mcp-config file containing only the
+// selected servers and returns the CLI args to load it exclusively.
+// The returned cleanup func removes the temp file once the run finishes.
+func prepareMCPConfig(names []string, workDir string) ([]string, func(), error) {
+	noop := func() {}
+	if len(names) == 0 {
+		return nil, noop, nil
+	}
+
+	selected := make(map[string]MCPServerConfigRaw, len(names))
+	for _, name := range names {
+		server, ok := findMCPServer(name, workDir)
+		if !ok {
+			return nil, noop, fmt.Errorf("MCP server not found: %s", name)
+		}
+		selected[name] = MCPServerConfigRaw{
+			Type:    server.Config.Type,
+			URL:     server.Config.URL,
+			Command: server.Config.Command,
+			Args:    server.Config.Args,
+			Env:     server.Config.Env,
+			Headers: server.Config.Headers,
+		}
+	}
+
+	data, err := json.Marshal(MCPConfigFile{MCPServers: selected})
+	if err != nil {
+		return nil, noop, err
+	}
+	tmpFile, err := os.CreateTemp("", "claude-mcp-*.json")
+	if err != nil {
+		return nil, noop, fmt.Errorf("failed to create MCP config file: %w", err)
+	}
+	defer tmpFile.Close()
+	if _, err := tmpFile.Write(data); err != nil {
+		os.Remove(tmpFile.Name())
+		return nil, noop, fmt.Errorf("failed to write MCP config file: %w", err)
+	}
+
+	path := tmpFile.Name()
+	args := []string{"--mcp-config", path, "--strict-mcp-config"}
+	return args, func() { os.Remove(path) }, nil
+}
+
+// extractInitMCPServers returns the mcp_servers list from a system/init stream event
+func extractInitMCPServers(line string) ([]interface{}, bool) {
+	if !strings.Contains(line, `"init"`) {
+		return nil, false
+	}
+	var event struct {
+		Type       string        `json:"type"`
+		Subtype    string        `json:"subtype"`
+		MCPServers []interface{} `json:"mcp_servers"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return nil, false
+	}
+	if event.Type != "system" || event.Subtype != "init" {
+		return nil, false
+	}
+	if event.MCPServers == nil {
+		event.MCPServers = []interface{}{}
+	}
+	return event.MCPServers, true
+}
@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics are rendered in the Prometheus text format directly; the metric set
+// is small and fixed, so the client library isn't needed
+
+// Histogram upper bounds in seconds
+var (
+	requestBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	chatBuckets    = []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func (h *histogram) observe(v float64) {
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// metricFamily is a named metric with labelled series
+type metricFamily struct {
+	help       string
+	kind       string // "counter" or "histogram"
+	buckets    []float64
+	counters   map[string]float64
+	histograms map[string]*histogram
+}
+
+// MetricsRegistry holds all server metrics
+type MetricsRegistry struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+	order    []string
+
+	terminalConnections int64
+}
+
+var metrics = newMetricsRegistry()
+
+func newMetricsRegistry() *MetricsRegistry {
+	m := &MetricsRegistry{families: make(map[string]*metricFamily)}
+	m.register("http_requests_total", "counter", "Total HTTP requests by method, route, and status code.", nil)
+	m.register("http_request_duration_seconds", "histogram", "HTTP request latency by method and route.", requestBuckets)
+	m.register("claude_stream_bytes_total", "counter", "Bytes of claude output streamed to clients.", nil)
+	m.register("claude_chat_duration_seconds", "histogram", "Duration of claude chat runs.", chatBuckets)
+	m.register("claude_chats_total", "counter", "Completed claude chat runs by transport and outcome.", nil)
+	m.register("claude_tokens_total", "counter", "Tokens reported by claude result events.", nil)
+	m.register("claude_cost_usd_total", "counter", "Cost in USD reported by claude result events.", nil)
+	m.register("claude_diagnostics_total", "counter", "Recognized claude CLI failures by kind.", nil)
+	m.register("claude_stream_turns_total", "counter", "Turns of the stream chat backend by how their process was found: reused, warm, or cold.", nil)
+	return m
+}
+
+func (m *MetricsRegistry) register(name, kind, help string, buckets []float64) {
+	m.families[name] = &metricFamily{
+		help:       help,
+		kind:       kind,
+		buckets:    buckets,
+		counters:   make(map[string]float64),
+		histograms: make(map[string]*histogram),
+	}
+	m.order = append(m.order, name)
+}
+
+// formatLabels renders label pairs ("k", "v", ...) as {k="v",...}
+func formatLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, fmt.Sprintf("%s=%s", pairs[i], strconv.Quote(pairs[i+1])))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func (m *MetricsRegistry) add(name string, v float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.families[name].counters[formatLabels(labels...)] += v
+}
+
+func (m *MetricsRegistry) observe(name string, v float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f := m.families[name]
+	key := formatLabels(labels...)
+	h, ok := f.histograms[key]
+	if !ok {
+		h = &histogram{buckets: f.buckets, counts: make([]uint64, len(f.buckets))}
+		f.histograms[key] = h
+	}
+	h.observe(v)
+}
+
+// withLabel inserts an extra label into a rendered label set
+func withLabel(labels string, key string, value string) string {
+	pair := fmt.Sprintf("%s=%s", key, strconv.Quote(value))
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + pair + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// write renders all metrics in Prometheus text format
+func (m *MetricsRegistry) write(b *strings.Builder) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, name := range m.order {
+		f := m.families[name]
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, f.help, name, f.kind)
+		if f.kind == "counter" {
+			keys := make([]string, 0, len(f.counters))
+			for k := range f.counters {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				fmt.Fprintf(b, "%s%s %s\n", name, k, formatFloat(f.counters[k]))
+			}
+			continue
+		}
+		keys := make([]string, 0, len(f.histograms))
+		for k := range f.histograms {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			h := f.histograms[k]
+			for i, upper := range h.buckets {
+				fmt.Fprintf(b, "%s_bucket%s %d\n", name, withLabel(k, "le", formatFloat(upper)), h.counts[i])
+			}
+			fmt.Fprintf(b, "%s_bucket%s %d\n", name, withLabel(k, "le", "+Inf"), h.count)
+			fmt.Fprintf(b, "%s_sum%s %s\n", name, k, formatFloat(h.sum))
+			fmt.Fprintf(b, "%s_count%s %d\n", name, k, h.count)
+		}
+	}
+}
+
+// writeGauge renders a single gauge sample
+func writeGauge(b *strings.Builder, name string, help string, samples map[string]float64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	keys := make([]string, 0, len(samples))
+	for k := range samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s%s %s\n", name, k, formatFloat(samples[k]))
+	}
+}
+
+// MetricsMiddleware records request counts and latencies
+// Routes are labelled by their pattern (e.g. /api/session/:id/info) to bound cardinality
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		method := c.Request.Method
+		metrics.add("http_requests_total", 1, "method", method, "route", route, "status", strconv.Itoa(c.Writer.Status()))
+		metrics.observe("http_request_duration_seconds", time.Since(start).Seconds(), "method", method, "route", route)
+	}
+}
+
+// recordStreamBytes counts claude output forwarded to a client
+func recordStreamBytes(transport string, n int) {
+	metrics.add("claude_stream_bytes_total", float64(n), "transport", transport)
+}
+
+// recordChatFinished records the duration and outcome of a chat run
+func recordChatFinished(transport string, start time.Time, outcome string) {
+	metrics.observe("claude_chat_duration_seconds", time.Since(start).Seconds(), "transport", transport)
+	metrics.add("claude_chats_total", 1, "transport", transport, "outcome", outcome)
+}
+
+// recordDiagnosticMetric counts a recognized claude CLI failure
+func recordDiagnosticMetric(kind string) {
+	metrics.add("claude_diagnostics_total", 1, "kind", kind)
+}
+
+// chatOutcome classifies a claude process exit for metrics
+func chatOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		switch exitErr.ExitCode() {
+		case 1, -1, 130, 137:
+			return "interrupted"
+		}
+	}
+	return "error"
+}
+
+// resultEvent is the final "result" event of a claude stream-json run
+type resultEvent struct {
+	Type         string  `json:"type"`
+	Subtype      string  `json:"subtype"`
+	IsError      bool    `json:"is_error"`
+	Result       string  `json:"result"`
+	SessionID    string  `json:"session_id"`
+	NumTurns     int     `json:"num_turns"`
+	DurationMS   int64   `json:"duration_ms"`
+	TotalCostUSD float64 `json:"total_cost_usd"`
+	Usage        struct {
+		InputTokens              float64 `json:"input_tokens"`
+		OutputTokens             float64 `json:"output_tokens"`
+		CacheReadInputTokens     float64 `json:"cache_read_input_tokens"`
+		CacheCreationInputTokens float64 `json:"cache_creation_input_tokens"`
+	} `json:"usage"`
+}
+
+// parseResultEvent returns the result event in line, or nil for other lines
+func parseResultEvent(line string) *resultEvent {
+	if !strings.Contains(line, `"result"`) {
+		return nil
+	}
+	var event resultEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Type != "result" {
+		return nil
+	}
+	return &event
+}
+
+// recordResultUsage adds token and cost counters from a claude result event
+func recordResultUsage(event *resultEvent) {
+	metrics.add("claude_tokens_total", event.Usage.InputTokens, "type", "input")
+	metrics.add("claude_tokens_total", event.Usage.OutputTokens, "type", "output")
+	metrics.add("claude_tokens_total", event.Usage.CacheReadInputTokens, "type", "cache_read")
+	metrics.add("claude_tokens_total", event.Usage.CacheCreationInputTokens, "type", "cache_creation")
+	metrics.add("claude_cost_usd_total", event.TotalCostUSD)
+}
+
+// GetMetrics handles GET /metrics
+func GetMetrics(c *gin.Context) {
+	var b strings.Builder
+	metrics.write(&b)
+
+	processes := map[string]float64{
+		formatLabels("mode", "chat"):     0,
+		formatLabels("mode", "terminal"): 0,
+	}
+	resources := sampleProcessResources()
+	cpu, cpuSeconds, rss := map[string]float64{}, map[string]float64{}, map[string]float64{}
+	processLock.RLock()
+	for id, info := range activeProcesses {
+		processes[formatLabels("mode", info.Mode)]++
+		if r, ok := resources[id]; ok {
+			labels := formatLabels("process_id", strconv.Itoa(id), "mode", info.Mode)
+			cpu[labels], cpuSeconds[labels], rss[labels] = r.CPUPercent, r.CPUSeconds, float64(r.RSSBytes)
+		}
+	}
+	processLock.RUnlock()
+	writeGauge(&b, "claude_active_processes", "Running claude processes by mode.", processes)
+	writeGauge(&b, "claude_process_cpu_percent", "CPU use of a running claude process and its children since the previous sample; 100 is one core.", cpu)
+	writeGauge(&b, "claude_process_cpu_seconds", "CPU time of a running claude process and its children.", cpuSeconds)
+	writeGauge(&b, "claude_process_resident_memory_bytes", "Resident memory of a running claude process and its children.", rss)
+
+	pool := map[string]float64{}
+	for state, n := range streamPoolCounts() {
+		pool[formatLabels("state", state)] = float64(n)
+	}
+	writeGauge(&b, "claude_stream_processes", "Processes kept by the stream chat backend by state.", pool)
+
+	sessionHub.mu.RLock()
+	chatConns := len(sessionHub.connections)
+	sessionHub.mu.RUnlock()
+	writeGauge(&b, "websocket_connections", "Open WebSocket connections by endpoint.", map[string]float64{
+		formatLabels("endpoint", "chat"):     float64(chatConns),
+		formatLabels("endpoint", "terminal"): float64(atomic.LoadInt64(&metrics.terminalConnections)),
+	})
+
+	stateManager.clientMu.RLock()
+	sseClients := len(stateManager.clients)
+	stateManager.clientMu.RUnlock()
+	writeGauge(&b, "sse_state_clients", "Connected state subscription (SSE) clients.", map[string]float64{"": float64(sseClients)})
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
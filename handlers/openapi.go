@@ -0,0 +1,623 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIOperation documents one route for the OpenAPI spec and the generated Go client
+type APIOperation struct {
+	Method      string
+	Path        string // gin route pattern, e.g. /api/session/:id/info
+	OperationID string // also the generated client method name
+	Tag         string
+	Summary     string
+	Query       []string
+	Request     interface{} // JSON request body (zero value), nil if none
+	Response    interface{} // JSON 200 response (zero value), nil if not JSON
+	Stream      string      // "sse" or "websocket" for streaming endpoints
+	Admin       bool
+}
+
+// envelope describes a {"key": value} response as a struct type so it can be
+// documented and decoded like the named response types
+func envelope(fields ...interface{}) interface{} {
+	var sf []reflect.StructField
+	for i := 0; i+1 < len(fields); i += 2 {
+		key := fields[i].(string)
+		name := strings.ToUpper(key[:1]) + key[1:]
+		if strings.HasSuffix(name, "Id") {
+			name = strings.TrimSuffix(name, "Id") + "ID"
+		}
+		sf = append(sf, reflect.StructField{
+			Name: name,
+			Type: reflect.TypeOf(fields[i+1]),
+			Tag:  reflect.StructTag(`json:"` + key + `"`),
+		})
+	}
+	return reflect.New(reflect.StructOf(sf)).Elem().Interface()
+}
+
+type successResponse struct {
+	Success bool `json:"success"`
+}
+
+var workDirQuery = []string{"work_dir"}
+
+// APIOperations lists every API route; main validates it against the router at startup
+var APIOperations = []APIOperation{
+	// Auth and users
+	{Method: "GET", Path: "/api/auth/status", OperationID: "AuthStatus", Tag: "auth", Summary: "Authentication mode and current user",
+		Response: envelope("mode", "", "authenticated", false, "user", &UserInfo{})},
+	{Method: "POST", Path: "/api/auth/login", OperationID: "Login", Tag: "auth", Summary: "Log in with username and password",
+		Request: LoginRequest{}, Response: envelope("token", "", "user", UserInfo{})},
+	{Method: "POST", Path: "/api/auth/logout", OperationID: "Logout", Tag: "auth", Summary: "End the current session", Response: successResponse{}},
+	{Method: "GET", Path: "/api/auth/me", OperationID: "GetCurrentUser", Tag: "auth", Summary: "Current user",
+		Response: envelope("user", &UserInfo{}, "mode", "", "apiKeyScope", "")},
+	{Method: "GET", Path: "/api/auth/oidc/login", OperationID: "OIDCLogin", Tag: "auth", Summary: "Redirect to the OIDC provider"},
+	{Method: "GET", Path: "/api/auth/oidc/callback", OperationID: "OIDCCallback", Tag: "auth", Summary: "OIDC redirect target", Query: []string{"code", "state"}},
+	{Method: "GET", Path: "/api/auth/claude-status", OperationID: "GetClaudeAuthStatus", Tag: "auth", Summary: "Whether the claude CLI has credentials, and the assisted login in progress",
+		Query: []string{"probe"}, Response: ClaudeAuthStatus{}},
+	{Method: "POST", Path: "/api/auth/claude-login", OperationID: "StartClaudeLogin", Tag: "auth", Summary: "Start claude setup-token and return the sign-in URL", Admin: true,
+		Response: ClaudeLogin{}},
+	{Method: "POST", Path: "/api/auth/claude-login/code", OperationID: "SubmitClaudeLoginCode", Tag: "auth", Summary: "Send the code from the sign-in page to the waiting login", Admin: true,
+		Request: ClaudeLoginCodeRequest{}, Response: ClaudeLogin{}},
+	{Method: "DELETE", Path: "/api/auth/claude-login", OperationID: "CancelClaudeLogin", Tag: "auth", Summary: "Cancel the assisted login", Admin: true,
+		Response: successResponse{}},
+	{Method: "GET", Path: "/api/users", OperationID: "ListUsers", Tag: "users", Summary: "List users", Admin: true,
+		Response: envelope("users", []UserInfo{})},
+	{Method: "POST", Path: "/api/users", OperationID: "CreateUser", Tag: "users", Summary: "Create a user", Admin: true,
+		Request: UserRequest{}, Response: UserInfo{}},
+	{Method: "PUT", Path: "/api/users/:id", OperationID: "UpdateUser", Tag: "users", Summary: "Update a user (users may change their own password)",
+		Request: UserRequest{}, Response: UserInfo{}},
+	{Method: "DELETE", Path: "/api/users/:id", OperationID: "DeleteUser", Tag: "users", Summary: "Delete a user", Admin: true, Response: successResponse{}},
+	{Method: "GET", Path: "/api/keys", OperationID: "ListAPIKeys", Tag: "auth", Summary: "API keys of the current user (every user's for admins)",
+		Response: envelope("keys", []APIKey{})},
+	{Method: "POST", Path: "/api/keys", OperationID: "CreateAPIKey", Tag: "auth", Summary: "Create a scoped API key; the key is only returned here",
+		Request: APIKeyRequest{}, Response: APIKeyResponse{}},
+	{Method: "DELETE", Path: "/api/keys/:id", OperationID: "RevokeAPIKey", Tag: "auth", Summary: "Revoke an API key", Response: successResponse{}},
+
+	// Sessions
+	{Method: "GET", Path: "/api/sessions", OperationID: "ListSessions", Tag: "sessions", Summary: "List sessions",
+		Query: []string{"work_dir", "feedback", "since", "until", "min_messages", "branch", "has_errors", "include_sidechains", "q", "limit"}, Response: SessionsResponse{}},
+	{Method: "POST", Path: "/api/sessions/dirty-check", OperationID: "CheckSessionsDirty", Tag: "sessions", Summary: "Report sessions changed since the given mtimes",
+		Request: SessionDirtyCheckRequest{}, Response: SessionDirtyCheckResponse{}},
+	{Method: "POST", Path: "/api/sessions/import", OperationID: "ImportSessions", Tag: "sessions", Summary: "Import a claude .jsonl transcript or a ChatGPT/Claude.ai export (multipart field \"file\" or the body) as sessions",
+		Query: []string{"workDir", "format", "conversation"}, Response: ImportSessionsResponse{}},
+	{Method: "GET", Path: "/api/session/:id/info", OperationID: "GetSession", Tag: "sessions", Summary: "Session metadata", Response: Session{}},
+	{Method: "GET", Path: "/api/session/:id/history", OperationID: "GetSessionHistory", Tag: "sessions", Summary: "Session messages",
+		Query: []string{"project", "limit", "offset", "since_uuid", "since_timestamp"}, Response: HistoryResponse{}},
+	{Method: "GET", Path: "/api/session/:id/messages/:uuid/context", OperationID: "GetMessageContext", Tag: "sessions", Summary: "A window of messages around one, for deep links into long sessions",
+		Query: []string{"before", "after"}, Response: MessageContextResponse{}},
+	{Method: "POST", Path: "/api/session/:id/messages/:uuid/feedback", OperationID: "SetMessageFeedback", Tag: "sessions", Summary: "Rate, note, or label a message; empty clears it",
+		Request: FeedbackRequest{}, Response: MessageFeedback{}},
+	{Method: "GET", Path: "/api/session/:id/artifacts", OperationID: "GetArtifacts", Tag: "sessions", Summary: "Code blocks from the session's assistant messages, with the files they were annotated with",
+		Query: []string{"language", "withPath"}, Response: ArtifactsResponse{}},
+	{Method: "POST", Path: "/api/session/:id/export/gist", OperationID: "ExportGist", Tag: "sessions", Summary: "Publish selected messages and artifacts to a GitHub gist or the configured pastebin",
+		Request: GistExportRequest{}, Response: SessionExport{}},
+	{Method: "GET", Path: "/api/session/:id/snapshots", OperationID: "ListSnapshots", Tag: "sessions", Summary: "Snapshots of the working directory taken before the session's runs, newest first",
+		Response: envelope("sessionId", "", "snapshots", []SessionSnapshot{})},
+	{Method: "POST", Path: "/api/session/:id/rollback", OperationID: "RollbackSession", Tag: "sessions", Summary: "Restore the working directory to a snapshot (default the latest), removing files created since unless keepNewFiles",
+		Request: RollbackRequest{}, Response: RollbackResponse{}},
+	{Method: "GET", Path: "/api/session/:id/dirs", OperationID: "GetSessionDirs", Tag: "sessions", Summary: "The session's working directory and additional directories, as file explorer roots",
+		Response: SessionDirsResponse{}},
+	{Method: "PUT", Path: "/api/session/:id/dirs", OperationID: "UpdateSessionDirs", Tag: "sessions", Summary: "Replace the additional directories (--add-dir) the session's next runs get",
+		Request: SessionDirsRequest{}, Response: SessionDirsResponse{}},
+	{Method: "GET", Path: "/api/session/:id/feedback", OperationID: "GetSessionFeedback", Tag: "sessions", Summary: "Feedback on a session's messages",
+		Response: envelope("sessionId", "", "feedback", []MessageFeedback{})},
+	{Method: "GET", Path: "/api/feedback", OperationID: "ExportFeedback", Tag: "sessions", Summary: "Feedback on visible sessions with the rated messages' text (format=jsonl downloads it)",
+		Query: []string{"rating", "label", "format"}, Response: envelope("feedback", []MessageFeedback{})},
+	{Method: "GET", Path: "/api/session/:id/search", OperationID: "SearchSession", Tag: "sessions", Summary: "Find messages in a session",
+		Query: []string{"q", "limit"}, Response: SessionSearchResponse{}},
+	{Method: "GET", Path: "/api/session/:id/mtime", OperationID: "GetSessionMtime", Tag: "sessions", Summary: "Session file modification time",
+		Response: envelope("sessionId", "", "mtime", int64(0))},
+	{Method: "DELETE", Path: "/api/session/:id", OperationID: "DeleteSession", Tag: "sessions", Summary: "Delete a session", Query: []string{"project"},
+		Response: envelope("success", false, "sessionId", "")},
+	{Method: "POST", Path: "/api/session/:id/compact", OperationID: "CompactSession", Tag: "sessions", Summary: "Run /compact on a session and stream the turn",
+		Request: CompactRequest{}, Stream: "sse"},
+	{Method: "POST", Path: "/api/session/:id/summarize", OperationID: "SummarizeSession", Tag: "sessions", Summary: "Generate or return the cached session summary",
+		Request: SummarizeRequest{}, Response: SummarizeResponse{}},
+	{Method: "POST", Path: "/api/session/:id/pin", OperationID: "PinSession", Tag: "sessions", Summary: "Pin a session so storage cleanup keeps it",
+		Response: envelope("sessionId", "", "pinned", false)},
+	{Method: "DELETE", Path: "/api/session/:id/pin", OperationID: "UnpinSession", Tag: "sessions", Summary: "Unpin a session",
+		Response: envelope("sessionId", "", "pinned", false)},
+	{Method: "GET", Path: "/api/session/:id/draft", OperationID: "GetDraft", Tag: "sessions", Summary: "The session's unsent prompt", Response: SessionDraft{}},
+	{Method: "PUT", Path: "/api/session/:id/draft", OperationID: "PutDraft", Tag: "sessions", Summary: "Save or clear the session's unsent prompt",
+		Request: SessionDraft{}, Response: SessionDraft{}},
+	{Method: "POST", Path: "/api/session/:id/share", OperationID: "CreateShare", Tag: "sessions", Summary: "Create an expiring read-only link to a session with secrets masked",
+		Request: ShareRequest{}, Response: ShareResponse{}},
+	{Method: "POST", Path: "/api/session/:id/redaction-report", OperationID: "TestRedaction", Tag: "sessions", Summary: "Report what the redaction rules would mask when sharing a session",
+		Request: RedactionTestRequest{}, Response: RedactionReport{}},
+	{Method: "GET", Path: "/api/shares", OperationID: "ListShares", Tag: "sessions", Summary: "Active share links", Query: []string{"sessionId"},
+		Response: envelope("shares", []ShareLink{})},
+	{Method: "DELETE", Path: "/api/shares/:id", OperationID: "RevokeShare", Tag: "sessions", Summary: "Revoke a share link", Response: successResponse{}},
+	{Method: "GET", Path: "/api/shared/:token", OperationID: "GetSharedTranscript", Tag: "sessions", Summary: "A shared transcript (public; the token is the credential)",
+		Response: SharedTranscript{}},
+	{Method: "POST", Path: "/api/session/:id/rewind", OperationID: "RewindSession", Tag: "sessions", Summary: "Truncate or fork a session at a message",
+		Request: RewindRequest{}, Response: RewindResponse{}},
+	{Method: "GET", Path: "/api/session/:id/validate", OperationID: "ValidateSession", Tag: "sessions", Summary: "Find truncated or corrupt lines in a session file",
+		Response: SessionValidation{}},
+	{Method: "POST", Path: "/api/session/:id/repair", OperationID: "RepairSession", Tag: "sessions", Summary: "Recover the parseable records of a corrupt session into a copy or in place",
+		Request: RepairRequest{}, Response: RepairResponse{}},
+
+	// Chat
+	{Method: "POST", Path: "/api/chat", OperationID: "Chat", Tag: "chat", Summary: "Run claude and stream its output", Request: ChatRequest{}, Stream: "sse"},
+	{Method: "DELETE", Path: "/api/chat", OperationID: "InterruptChat", Tag: "chat", Summary: "Interrupt the process running a session", Query: []string{"sessionId"}, Response: successResponse{}},
+	{Method: "GET", Path: "/api/approvals", OperationID: "ListApprovals", Tag: "chat", Summary: "Tool calls of approval-mode runs waiting for a decision",
+		Response: envelope("approvals", []PendingApproval{})},
+	{Method: "POST", Path: "/api/approvals/:id", OperationID: "DecideApproval", Tag: "chat", Summary: "Approve or deny a held tool call",
+		Request: ApprovalDecision{}, Response: envelope("approvalId", "", "approved", false)},
+	{Method: "GET", Path: "/api/chat/stream/:processId", OperationID: "ResumeChatStream", Tag: "chat", Summary: "Resume a chat stream after Last-Event-ID",
+		Query: []string{"lastEventId"}, Stream: "sse"},
+	{Method: "GET", Path: "/api/chat/poll", OperationID: "PollChat", Tag: "chat", Summary: "Long-poll the events of a chat run, for clients whose network cuts streams",
+		Query: []string{"runId", "cursor", "wait"}, Response: ChatPollResponse{}},
+	{Method: "POST", Path: "/api/chat/batch", OperationID: "StartBatch", Tag: "chat", Summary: "Run one prompt across working directories (or prompts in one), a few at a time",
+		Request: BatchRequest{}, Response: BatchStatus{}},
+	{Method: "GET", Path: "/api/chat/batch", OperationID: "ListBatches", Tag: "chat", Summary: "Recent batches without their runs",
+		Response: envelope("batches", []BatchStatus{})},
+	{Method: "GET", Path: "/api/chat/batch/:id", OperationID: "GetBatch", Tag: "chat", Summary: "A batch with the progress and results of its runs",
+		Response: BatchStatus{}},
+	{Method: "DELETE", Path: "/api/chat/batch/:id", OperationID: "CancelBatch", Tag: "chat", Summary: "Cancel a batch's queued and running runs",
+		Response: successResponse{}},
+	{Method: "GET", Path: "/api/runs/compare", OperationID: "CompareRuns", Tag: "sessions", Summary: "Two sessions aligned prompt by prompt: answers, durations, tokens, and files touched",
+		Query: []string{"a", "b"}, Response: RunComparison{}},
+	{Method: "POST", Path: "/api/chat/interactive", OperationID: "ChatInteractive", Tag: "chat", Summary: "Run claude, optionally continuing the last session", Request: ChatRequest{}, Stream: "sse"},
+	{Method: "GET", Path: "/api/chat/ws", OperationID: "ChatWebSocket", Tag: "chat", Summary: "Chat over WebSocket (messages: chat, subscribe, unsubscribe, interrupt, input)", Stream: "websocket"},
+	{Method: "POST", Path: "/api/ws-ticket", OperationID: "CreateWSTicket", Tag: "auth", Summary: "Single-use ticket authenticating one WebSocket connection",
+		Response: WSTicketResponse{}},
+	{Method: "GET", Path: "/api/processes", OperationID: "ListProcesses", Tag: "chat", Summary: "Running claude processes",
+		Response: envelope("processes", []ActiveProcessInfo{})},
+	{Method: "GET", Path: "/api/processes/:id", OperationID: "GetProcess", Tag: "chat", Summary: "A running process with its recent output and current activity",
+		Query: []string{"lines"}, Response: ProcessDetail{}},
+	{Method: "GET", Path: "/api/diagnostics", OperationID: "GetDiagnostics", Tag: "chat", Summary: "Recognized claude CLI failures with suggested remediation",
+		Query: []string{"since", "kind", "sessionId"}, Response: DiagnosticsResponse{}},
+	{Method: "GET", Path: "/api/todos", OperationID: "GetTodos", Tag: "chat", Summary: "Agent todo lists extracted from TodoWrite calls",
+		Query: []string{"sessionId", "project", "status"}, Response: envelope("sessions", []SessionTodos{})},
+	{Method: "GET", Path: "/api/usage", OperationID: "GetUsage", Tag: "chat", Summary: "Monthly cost by working directory, with budgets",
+		Query: []string{"month", "project"}, Response: UsageResponse{}},
+	{Method: "GET", Path: "/api/analytics/activity", OperationID: "GetActivity", Tag: "sessions", Summary: "Messages, runs, and cost per day, a weekday/hour heatmap, and tool, model, and project breakdowns",
+		Query: []string{"from", "to", "tz", "project", "limit"}, Response: ActivityResponse{}},
+	{Method: "GET", Path: "/api/digest", OperationID: "GetDigest", Tag: "sessions", Summary: "The activity digest the user would be mailed",
+		Query: []string{"period"}, Response: Digest{}},
+	{Method: "POST", Path: "/api/digest/send", OperationID: "SendDigest", Tag: "server", Summary: "Mail the configured digests now", Admin: true,
+		Response: DigestSendResponse{}},
+	{Method: "GET", Path: "/api/storage", OperationID: "GetStorage", Tag: "server", Summary: "Disk used by sessions per project, uploads, logs, and rewind backups", Admin: true,
+		Response: StorageReport{}},
+	{Method: "POST", Path: "/api/storage/cleanup", OperationID: "CleanupStorage", Tag: "server", Summary: "Preview or delete files matching a cleanup policy", Admin: true,
+		Request: StorageCleanupRequest{}, Response: StorageCleanupResponse{}},
+	{Method: "GET", Path: "/api/backup", OperationID: "GetBackupStatus", Tag: "server", Summary: "Backup parts, upload schedule, and the last upload", Admin: true,
+		Response: BackupStatus{}},
+	{Method: "POST", Path: "/api/backup", OperationID: "CreateBackup", Tag: "server", Summary: "Download a tar.gz of sessions, settings, commands, and server data, or upload it to backup.target", Admin: true,
+		Request: BackupRequest{}},
+	{Method: "POST", Path: "/api/restore", OperationID: "RestoreBackup", Tag: "server", Summary: "Restore a backup archive (request body or multipart field \"file\")", Admin: true,
+		Query: []string{"overwrite", "dryRun"}, Response: RestoreResponse{}},
+	{Method: "GET", Path: "/api/budgets", OperationID: "ListBudgets", Tag: "chat", Summary: "Project budgets with this month's spend",
+		Response: envelope("budgets", []BudgetInfo{})},
+	{Method: "POST", Path: "/api/budgets", OperationID: "CreateBudget", Tag: "chat", Summary: "Add a monthly project budget", Admin: true,
+		Request: BudgetRequest{}, Response: BudgetInfo{}},
+	{Method: "PUT", Path: "/api/budgets/:id", OperationID: "UpdateBudget", Tag: "chat", Summary: "Update a project budget", Admin: true,
+		Request: BudgetRequest{}, Response: BudgetInfo{}},
+	{Method: "DELETE", Path: "/api/budgets/:id", OperationID: "DeleteBudget", Tag: "chat", Summary: "Delete a project budget", Admin: true, Response: successResponse{}},
+	{Method: "GET", Path: "/api/terminal", OperationID: "TerminalHandler", Tag: "chat", Summary: "PTY terminal over WebSocket", Query: []string{"mode", "sessionId", "workDir", "protocol", "terminalId"}, Stream: "websocket"},
+	{Method: "GET", Path: "/api/terminals", OperationID: "ListTerminals", Tag: "chat", Summary: "The user's terminals opened with a terminalId, attached or not",
+		Response: envelope("terminals", []TerminalInfo{})},
+	{Method: "GET", Path: "/api/terminals/:id/scrollback", OperationID: "GetTerminalScrollback", Tag: "chat", Summary: "Buffered output of a terminal, to restore before reattaching",
+		Query: []string{"lines"}, Response: TerminalScrollback{}},
+	{Method: "GET", Path: "/api/lsp", OperationID: "LSPHandler", Tag: "files", Summary: "Hover, definition, references, and diagnostics from the configured language servers over WebSocket",
+		Query: []string{"workDir", "sessionId"}, Stream: "websocket"},
+	{Method: "GET", Path: "/api/lsp/servers", OperationID: "ListLanguageServers", Tag: "files", Summary: "Configured language servers, and those running in the user's directories",
+		Response: envelope("servers", []LanguageServerInfo{}, "running", []LanguageServerProcess{})},
+
+	// Files and uploads
+	{Method: "POST", Path: "/api/directories", OperationID: "ListDirectories", Tag: "files", Summary: "List subdirectories",
+		Request: ListDirectoriesRequest{}, Response: ListDirectoriesResponse{}},
+	{Method: "POST", Path: "/api/files", OperationID: "ListFiles", Tag: "files", Summary: "List files and directories",
+		Request: ListFilesRequest{}, Response: ListFilesResponse{}},
+	{Method: "GET", Path: "/api/remote-hosts", OperationID: "ListRemoteHosts", Tag: "files", Summary: "SSH hosts usable as host:path working directories",
+		Response: envelope("hosts", []RemoteHostInfo{})},
+	{Method: "GET", Path: "/api/backends", OperationID: "ListBackends", Tag: "chat", Summary: "Execution backends a chat can run in",
+		Response: envelope("backends", []ExecBackendInfo{})},
+	{Method: "GET", Path: "/api/chat-backends", OperationID: "ListChatBackends", Tag: "chat", Summary: "Chat backends (claude CLI, Anthropic API, agent CLIs) a chat can select",
+		Response: envelope("backends", []ChatBackendInfo{})},
+	{Method: "GET", Path: "/api/chat/pool", OperationID: "GetStreamPool", Tag: "chat", Summary: "Processes kept by the stream chat backend, and how turns found theirs",
+		Response: StreamPoolStatus{}, Admin: true},
+	{Method: "GET", Path: "/api/projects/:id/recent-files", OperationID: "GetRecentFiles", Tag: "files", Summary: "Files the user recently opened in the project, most recent first",
+		Query: []string{"limit"}, Response: RecentFilesResponse{}},
+	{Method: "GET", Path: "/api/projects/:id/context", OperationID: "GetProjectContext", Tag: "files", Summary: "Files a project's recent sessions touched, the user opened, or git shows as changed",
+		Query: []string{"sessions", "limit"}, Response: ProjectContextResponse{}},
+	{Method: "GET", Path: "/api/projects/:id/settings", OperationID: "GetProjectSettings", Tag: "config", Summary: "A project's chat defaults",
+		Query: []string{"path"}, Response: ProjectSettingsResponse{}},
+	{Method: "PUT", Path: "/api/projects/:id/settings", OperationID: "UpdateProjectSettings", Tag: "config", Summary: "Replace a project's chat defaults",
+		Query: []string{"path"}, Request: ProjectSettings{}, Response: ProjectSettingsResponse{}},
+	{Method: "POST", Path: "/api/file/read", OperationID: "ReadFile", Tag: "files", Summary: "Read a text file", Request: ReadFileRequest{}, Response: ReadFileResponse{}},
+	{Method: "POST", Path: "/api/file/outline", OperationID: "FileOutline", Tag: "files", Summary: "Functions, types, and headings of a text file, for an outline or minimap",
+		Request: OutlineRequest{}, Response: OutlineResponse{}},
+	{Method: "POST", Path: "/api/file/write", OperationID: "WriteFile", Tag: "files", Summary: "Write a text file, refusing if it changed since it was read",
+		Request: WriteFileRequest{}, Response: WriteFileResponse{}},
+	{Method: "POST", Path: "/api/file/delete", OperationID: "DeleteFile", Tag: "files", Summary: "Move a file or directory to the trash",
+		Request: DeleteFileRequest{}, Response: TrashItem{}},
+	{Method: "GET", Path: "/api/trash", OperationID: "ListTrash", Tag: "files", Summary: "Deleted files kept in the trash",
+		Query: []string{"project"}, Response: TrashResponse{}},
+	{Method: "POST", Path: "/api/trash/:id/restore", OperationID: "RestoreTrash", Tag: "files", Summary: "Move a deleted file back to its original path",
+		Request: RestoreTrashRequest{}, Response: TrashItem{}},
+	{Method: "DELETE", Path: "/api/trash/:id", OperationID: "PurgeTrashItem", Tag: "files", Summary: "Delete a trashed file for good",
+		Response: envelope("purged", 0, "size", int64(0))},
+	{Method: "DELETE", Path: "/api/trash", OperationID: "PurgeTrash", Tag: "files", Summary: "Empty the trash, optionally only for a project",
+		Query: []string{"project"}, Response: envelope("purged", 0, "size", int64(0))},
+	{Method: "POST", Path: "/api/file/lock", OperationID: "LockFile", Tag: "files", Summary: "Take or renew an advisory lock on a file before editing it",
+		Request: FileLockRequest{}, Response: FileLock{}},
+	{Method: "POST", Path: "/api/file/unlock", OperationID: "UnlockFile", Tag: "files", Summary: "Release a file lock",
+		Request: FileUnlockRequest{}, Response: envelope("released", false)},
+	{Method: "GET", Path: "/api/file/locks", OperationID: "ListFileLocks", Tag: "files", Summary: "Active file locks, optionally under a directory",
+		Query: []string{"path"}, Response: FileLocksResponse{}},
+	{Method: "POST", Path: "/api/upload", OperationID: "UploadFile", Tag: "files", Summary: "Upload an image (multipart field \"file\")", Response: UploadResponse{}},
+	{Method: "POST", Path: "/api/upload/extract", OperationID: "ExtractUpload", Tag: "files", Summary: "Unpack a zip, tar, or tar.gz archive (multipart field \"file\" or raw body) into a directory",
+		Query: []string{"path", "overwrite", "strip"}, Response: ExtractResponse{}},
+	{Method: "GET", Path: "/api/upload/:filename", OperationID: "GetUploadedFile", Tag: "files", Summary: "Download an uploaded file, or a thumbnail with w",
+		Query: []string{"w"}},
+	{Method: "DELETE", Path: "/api/upload/:filename", OperationID: "DeleteUploadedFile", Tag: "files", Summary: "Delete an uploaded file", Response: successResponse{}},
+
+	// Notifications
+	{Method: "GET", Path: "/api/push", OperationID: "GetPushConfig", Tag: "notifications", Summary: "VAPID public key and notification preferences",
+		Response: envelope("publicKey", "", "preferences", PushPreferences{}, "subscriptions", 0)},
+	{Method: "POST", Path: "/api/push/subscriptions", OperationID: "SubscribePush", Tag: "notifications", Summary: "Register a browser push subscription",
+		Request: PushSubscription{}, Response: successResponse{}},
+	{Method: "DELETE", Path: "/api/push/subscriptions", OperationID: "UnsubscribePush", Tag: "notifications", Summary: "Remove a push subscription",
+		Query: []string{"endpoint"}, Response: successResponse{}},
+	{Method: "PUT", Path: "/api/push/preferences", OperationID: "UpdatePushPreferences", Tag: "notifications", Summary: "Choose which events send notifications",
+		Request: PushPreferences{}, Response: PushPreferences{}},
+	{Method: "POST", Path: "/api/push/test", OperationID: "TestPush", Tag: "notifications", Summary: "Send a test notification to all of the user's devices",
+		Response: envelope("success", false, "sent", 0)},
+
+	{Method: "GET", Path: "/api/webhooks", OperationID: "ListWebhooks", Tag: "notifications", Summary: "Outbound webhooks", Admin: true,
+		Response: envelope("webhooks", []WebhookInfo{})},
+	{Method: "POST", Path: "/api/webhooks", OperationID: "CreateWebhook", Tag: "notifications", Summary: "Add a webhook", Admin: true,
+		Request: WebhookRequest{}, Response: WebhookInfo{}},
+	{Method: "PUT", Path: "/api/webhooks/:id", OperationID: "UpdateWebhook", Tag: "notifications", Summary: "Update a webhook", Admin: true,
+		Request: WebhookRequest{}, Response: WebhookInfo{}},
+	{Method: "DELETE", Path: "/api/webhooks/:id", OperationID: "DeleteWebhook", Tag: "notifications", Summary: "Delete a webhook", Admin: true, Response: successResponse{}},
+	{Method: "POST", Path: "/api/webhooks/:id/test", OperationID: "TestWebhook", Tag: "notifications", Summary: "Send a test delivery", Admin: true, Response: WebhookDelivery{}},
+
+	{Method: "GET", Path: "/api/schedules", OperationID: "ListSchedules", Tag: "schedules", Summary: "Scheduled prompts visible to the user",
+		Response: envelope("schedules", []ScheduleInfo{})},
+	{Method: "POST", Path: "/api/schedules", OperationID: "CreateSchedule", Tag: "schedules", Summary: "Schedule a recurring prompt",
+		Request: ScheduleRequest{}, Response: ScheduleInfo{}},
+	{Method: "GET", Path: "/api/schedules/:id", OperationID: "GetSchedule", Tag: "schedules", Summary: "Schedule details", Response: ScheduleInfo{}},
+	{Method: "PUT", Path: "/api/schedules/:id", OperationID: "UpdateSchedule", Tag: "schedules", Summary: "Update or enable/disable a schedule",
+		Request: ScheduleRequest{}, Response: ScheduleInfo{}},
+	{Method: "DELETE", Path: "/api/schedules/:id", OperationID: "DeleteSchedule", Tag: "schedules", Summary: "Delete a schedule", Response: successResponse{}},
+	{Method: "POST", Path: "/api/schedules/:id/run", OperationID: "RunSchedule", Tag: "schedules", Summary: "Run a schedule now", Response: ScheduleRun{}},
+	{Method: "GET", Path: "/api/schedules/:id/runs", OperationID: "GetScheduleRuns", Tag: "schedules", Summary: "Run history, newest first",
+		Query: []string{"limit"}, Response: envelope("runs", []ScheduleRun{})},
+
+	{Method: "GET", Path: "/api/templates", OperationID: "ListTemplates", Tag: "templates", Summary: "Own and shared prompt templates",
+		Response: envelope("templates", []PromptTemplate{})},
+	{Method: "POST", Path: "/api/templates", OperationID: "CreateTemplate", Tag: "templates", Summary: "Add a prompt template",
+		Request: TemplateRequest{}, Response: PromptTemplate{}},
+	{Method: "GET", Path: "/api/templates/:id", OperationID: "GetTemplate", Tag: "templates", Summary: "Prompt template", Response: PromptTemplate{}},
+	{Method: "PUT", Path: "/api/templates/:id", OperationID: "UpdateTemplate", Tag: "templates", Summary: "Update a prompt template",
+		Request: TemplateRequest{}, Response: PromptTemplate{}},
+	{Method: "DELETE", Path: "/api/templates/:id", OperationID: "DeleteTemplate", Tag: "templates", Summary: "Delete a prompt template", Response: successResponse{}},
+	{Method: "POST", Path: "/api/templates/:id/render", OperationID: "RenderTemplate", Tag: "templates", Summary: "Substitute variables into a template",
+		Request: TemplateRenderRequest{}, Response: TemplateRenderResponse{}},
+	{Method: "POST", Path: "/api/templates/:id/run", OperationID: "RunTemplate", Tag: "templates", Summary: "Render a template and stream a chat with it",
+		Request: TemplateRunRequest{}, Stream: "sse"},
+	{Method: "GET", Path: "/api/integrations/github/issues", OperationID: "ListGitHubIssues", Tag: "templates", Summary: "Open issues of a configured GitHub repository",
+		Query: []string{"repo", "labels", "limit"}, Response: GitHubIssuesResponse{}},
+	{Method: "POST", Path: "/api/integrations/github/issues/:id/run", OperationID: "RunGitHubIssue", Tag: "templates", Summary: "Stream a chat seeded with a GitHub issue in its repository's workDir",
+		Query: []string{"repo"}, Request: GitHubIssueRunRequest{}, Stream: "sse"},
+	{Method: "POST", Path: "/api/integrations/linear/webhook", OperationID: "LinearWebhook", Tag: "schedules", Summary: "Start the schedule runs a signed Linear issue event matches",
+		Request: json.RawMessage{}, Response: TicketWebhookResponse{}},
+	{Method: "POST", Path: "/api/integrations/jira/webhook", OperationID: "JiraWebhook", Tag: "schedules", Summary: "Start the schedule runs a Jira issue event matches",
+		Query: []string{"secret"}, Request: json.RawMessage{}, Response: TicketWebhookResponse{}},
+	{Method: "POST", Path: "/api/integrations/slack/events", OperationID: "SlackEvents", Tag: "chat", Summary: "Slack Events API request URL; messages to the app are prompts",
+		Request: json.RawMessage{}, Response: successResponse{}},
+	{Method: "POST", Path: "/api/integrations/telegram/webhook", OperationID: "TelegramWebhook", Tag: "chat", Summary: "Telegram bot webhook; messages to the bot are prompts",
+		Request: json.RawMessage{}, Response: successResponse{}},
+
+	// Claude configuration
+	{Method: "GET", Path: "/api/commands", OperationID: "ListCommands", Tag: "config", Summary: "Slash commands", Query: workDirQuery,
+		Response: envelope("commands", []Command{}, "digests", CommandDigests{})},
+	{Method: "GET", Path: "/api/commands/:name", OperationID: "GetCommand", Tag: "config", Summary: "Slash command detail", Query: workDirQuery, Response: CommandDetail{}},
+	{Method: "POST", Path: "/api/commands/:name/run", OperationID: "RunCommand", Tag: "config", Summary: "Run a slash command", Request: RunCommandRequest{}, Stream: "sse"},
+	{Method: "GET", Path: "/api/agents", OperationID: "ListAgents", Tag: "config", Summary: "Agent definitions", Query: workDirQuery, Response: envelope("agents", []Asset{})},
+	{Method: "GET", Path: "/api/agents/:name", OperationID: "GetAgent", Tag: "config", Summary: "Agent definition", Query: workDirQuery, Response: AssetDetail{}},
+	{Method: "GET", Path: "/api/skills", OperationID: "ListSkills", Tag: "config", Summary: "Skill definitions", Query: workDirQuery, Response: envelope("skills", []Asset{})},
+	{Method: "GET", Path: "/api/skills/:name", OperationID: "GetSkill", Tag: "config", Summary: "Skill definition", Query: workDirQuery, Response: AssetDetail{}},
+	{Method: "GET", Path: "/api/config", OperationID: "GetConfig", Tag: "config", Summary: "CLAUDE.md and related config files", Query: workDirQuery,
+		Response: envelope("configs", []Config{})},
+	{Method: "GET", Path: "/api/plugins", OperationID: "ListPlugins", Tag: "config", Summary: "Installed plugins", Response: envelope("plugins", []Plugin{})},
+	{Method: "POST", Path: "/api/plugins/install", OperationID: "InstallPlugin", Tag: "config", Summary: "Install a plugin (streams progress)", Admin: true,
+		Request: InstallPluginRequest{}, Stream: "sse"},
+	{Method: "DELETE", Path: "/api/plugins/:name", OperationID: "UninstallPlugin", Tag: "config", Summary: "Uninstall a plugin", Admin: true,
+		Response: envelope("success", false, "name", "")},
+	{Method: "POST", Path: "/api/plugins/:name/update", OperationID: "UpdatePlugin", Tag: "config", Summary: "Update a plugin (streams progress)", Admin: true, Stream: "sse"},
+	{Method: "GET", Path: "/api/mcp", OperationID: "GetMCPServers", Tag: "config", Summary: "MCP servers", Query: workDirQuery, Response: envelope("servers", []MCPServer{})},
+	{Method: "POST", Path: "/api/mcp/:name", OperationID: "AddMCPServer", Tag: "config", Summary: "Add an MCP server", Admin: true,
+		Request: MCPServerRequest{}, Response: MCPServer{}},
+	{Method: "PUT", Path: "/api/mcp/:name", OperationID: "UpdateMCPServer", Tag: "config", Summary: "Update an MCP server", Admin: true,
+		Request: MCPServerRequest{}, Response: MCPServer{}},
+	{Method: "DELETE", Path: "/api/mcp/:name", OperationID: "DeleteMCPServer", Tag: "config", Summary: "Delete an MCP server", Admin: true, Query: []string{"scope"},
+		Response: envelope("success", false, "name", "")},
+	{Method: "POST", Path: "/api/mcp/:name/test", OperationID: "TestMCPServer", Tag: "config", Summary: "Connect to an MCP server and list its tools", Admin: true,
+		Query: workDirQuery, Response: MCPTestResult{}},
+	{Method: "GET", Path: "/api/settings", OperationID: "GetSettings", Tag: "config", Summary: "Claude settings files", Query: workDirQuery,
+		Response: envelope("settings", []SettingsFile{})},
+	{Method: "PUT", Path: "/api/settings", OperationID: "UpdateSettings", Tag: "config", Summary: "Write a settings file", Admin: true,
+		Request: UpdateSettingsRequest{}, Response: SettingsFile{}},
+	{Method: "GET", Path: "/api/hooks", OperationID: "GetHooks", Tag: "config", Summary: "Hook configuration", Query: workDirQuery, Response: envelope("hooks", []HooksConfig{})},
+	{Method: "PUT", Path: "/api/hooks", OperationID: "UpdateHooks", Tag: "config", Summary: "Write hook configuration", Admin: true,
+		Request: UpdateHooksRequest{}, Response: HooksConfig{}},
+	{Method: "GET", Path: "/api/hooks/log", OperationID: "GetHookLog", Tag: "config", Summary: "Recent hook executions", Query: []string{"session_id", "limit"},
+		Response: envelope("executions", []HookExecution{})},
+	{Method: "POST", Path: "/api/hooks/ingest", OperationID: "IngestHookEvent", Tag: "config", Summary: "Record a claude hook's input, sent by a hook script with X-Greyzone-Hook-Secret",
+		Request: json.RawMessage{}, Response: successResponse{}},
+	{Method: "GET", Path: "/api/hooks/events", OperationID: "GetHookEvents", Tag: "config", Summary: "Hook events ingested for a session", Query: []string{"session_id", "limit"},
+		Response: envelope("events", []HookEvent{})},
+
+	// Server
+	{Method: "GET", Path: "/api/server/config", OperationID: "GetServerConfig", Tag: "server", Summary: "Effective server configuration", Admin: true,
+		Response: envelope("path", "", "config", ServerConfig{})},
+	{Method: "GET", Path: "/api/server/logs", OperationID: "GetServerLogs", Tag: "server", Summary: "Recent log entries", Admin: true,
+		Query: []string{"limit", "level", "requestId", "q"}, Response: envelope("entries", []json.RawMessage{}, "level", "")},
+	{Method: "GET", Path: "/api/server/doctor", OperationID: "GetDoctor", Tag: "server", Summary: "Check the claude CLI and required tools", Admin: true,
+		Query: []string{"refresh"}, Response: DoctorReport{}},
+	{Method: "GET", Path: "/api/audit", OperationID: "GetAuditLog", Tag: "server", Summary: "Audit log", Admin: true,
+		Query: []string{"user", "action", "sessionId", "since", "until", "limit"}, Response: envelope("entries", []AuditEntry{})},
+	{Method: "GET", Path: "/api/version", OperationID: "GetVersion", Tag: "server", Summary: "Server, claude CLI, and runtime versions", Query: []string{"checkUpdates"}, Response: VersionInfo{}},
+	{Method: "GET", Path: "/api/i18n", OperationID: "GetLocaleInfo", Tag: "server", Summary: "The locale of server-generated strings for this request, and those available",
+		Query: []string{"lang"}, Response: LocaleInfo{}},
+	{Method: "GET", Path: "/api/openapi.json", OperationID: "GetOpenAPISpec", Tag: "server", Summary: "This document"},
+	{Method: "GET", Path: "/api/state", OperationID: "GetState", Tag: "server", Summary: "Session processing state", Response: AppState{}},
+	{Method: "GET", Path: "/api/state/subscribe", OperationID: "SubscribeState", Tag: "server", Summary: "Session processing state updates", Stream: "sse"},
+}
+
+// schemaBuilder converts Go types to OpenAPI schemas, collecting named structs as components
+type schemaBuilder struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+func (b *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := b.schema(t.Elem())
+		if _, isRef := s["$ref"]; isRef {
+			return map[string]interface{}{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": b.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := b.components[name]; !ok {
+			b.components[name] = nil // placeholder breaks recursion
+			b.components[name] = b.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (b *schemaBuilder) structSchema(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			if embedded, ok := b.structSchema(f.Type)["properties"].(map[string]interface{}); ok {
+				for k, v := range embedded {
+					props[k] = v
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = b.schema(f.Type)
+	}
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+// openAPIPath converts /api/session/:id to /api/session/{id} and returns the parameter names
+func openAPIPath(route string) (string, []string) {
+	var params []string
+	parts := strings.Split(route, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+// BuildOpenAPISpec renders APIOperations as an OpenAPI 3 document
+func BuildOpenAPISpec(basePath string) map[string]interface{} {
+	b := &schemaBuilder{components: make(map[string]interface{})}
+	paths := make(map[string]map[string]interface{})
+
+	for _, op := range APIOperations {
+		p, pathParams := openAPIPath(op.Path)
+		var params []interface{}
+		for _, name := range pathParams {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		for _, name := range op.Query {
+			params = append(params, map[string]interface{}{
+				"name": name, "in": "query", "schema": map[string]interface{}{"type": "string"},
+			})
+		}
+
+		operation := map[string]interface{}{
+			"operationId": op.OperationID,
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if op.Admin {
+			operation["description"] = "Requires the admin role when authentication is enabled."
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Request))},
+				},
+			}
+		}
+
+		ok := map[string]interface{}{"description": "Success"}
+		switch {
+		case op.Stream == "sse":
+			ok["content"] = map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+		case op.Stream == "websocket":
+			ok = map[string]interface{}{"description": "Switching protocols to WebSocket"}
+		case op.Response != nil:
+			ok["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": b.schema(reflect.TypeOf(op.Response))},
+			}
+		}
+		status := "200"
+		if op.Stream == "websocket" {
+			status = "101"
+		}
+		operation["responses"] = map[string]interface{}{
+			status:    ok,
+			"default": map[string]interface{}{"$ref": "#/components/responses/Error"},
+		}
+
+		if paths[p] == nil {
+			paths[p] = make(map[string]interface{})
+		}
+		paths[p][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Claude Greyzone API",
+			"version": Version,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": basePath + "/"}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": b.components,
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type":     "object",
+								"required": []string{"code", "message"},
+								"properties": map[string]interface{}{
+									"code":      map[string]interface{}{"type": "string", "enum": errorCodes},
+									"message":   map[string]interface{}{"type": "string"},
+									"details":   map[string]interface{}{},
+									"requestId": map[string]interface{}{"type": "string"},
+									"error":     map[string]interface{}{"type": "string", "deprecated": true},
+								},
+							},
+						},
+					},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"cookie": map[string]interface{}{"type": "apiKey", "in": "cookie", "name": "greyzone_session"},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"cookie": []string{}},
+		},
+	}
+}
+
+// UndocumentedRoutes compares the router against APIOperations and returns
+// API routes missing from the spec and documented routes that aren't registered
+func UndocumentedRoutes(routes gin.RoutesInfo) (missing []string, stale []string) {
+	documented := make(map[string]bool)
+	for _, op := range APIOperations {
+		documented[op.Method+" "+op.Path] = true
+	}
+	registered := make(map[string]bool)
+	for _, r := range routes {
+		key := r.Method + " " + r.Path
+		registered[key] = true
+		if strings.HasPrefix(r.Path, "/api/") && !documented[key] {
+			missing = append(missing, key)
+		}
+	}
+	for key := range documented {
+		if !registered[key] {
+			stale = append(stale, key)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(stale)
+	return missing, stale
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIJSON []byte
+	openAPIErr  error
+)
+
+// GetOpenAPISpec handles GET /api/openapi.json
+func GetOpenAPISpec(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIJSON, openAPIErr = json.MarshalIndent(BuildOpenAPISpec(getServerConfig().BasePath), "", "  ")
+	})
+	if openAPIErr != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to build OpenAPI spec", openAPIErr.Error())
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIJSON)
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// outlineSymbolLimit bounds the symbols of one outline
+const outlineSymbolLimit = 5000
+
+// OutlineRequest is the body of POST /api/file/outline
+type OutlineRequest struct {
+	Path string `json:"path"`
+	// Content outlines an unsaved buffer instead of the file on disk; Path then only picks the language
+	Content  *string `json:"content,omitempty"`
+	Language string  `json:"language,omitempty"` // as POST /api/file/read reports it; default from the extension
+}
+
+// OutlineSymbol is a function, type, heading, or other named section of a file
+type OutlineSymbol struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`              // function, method, class, struct, interface, type, enum, trait, impl, module, const, var, heading, ...
+	Line    int    `json:"line"`              // 1-based
+	EndLine int    `json:"endLine,omitempty"` // when the parser knows it
+	Depth   int    `json:"depth"`             // nesting: methods in a class, subheadings
+	Detail  string `json:"detail,omitempty"`  // e.g. a method's receiver
+}
+
+// OutlineResponse is the response of POST /api/file/outline
+type OutlineResponse struct {
+	Path     string          `json:"path"`
+	Language string          `json:"language"`
+	Parser   string          `json:"parser"` // go (go/parser), regex, or none for languages without an outline
+	Symbols  []OutlineSymbol `json:"symbols"`
+	// Error is set when the file did not parse; Symbols then has what was found before the error
+	Error string `json:"error,omitempty"`
+}
+
+// outlineRule finds one kind of symbol; the name is the regular expression's "name" group
+type outlineRule struct {
+	re   *regexp.Regexp
+	kind string
+}
+
+// outlineRuleList pairs each regular expression with the kind of symbol it finds
+func outlineRuleList(pairs ...string) []outlineRule {
+	var list []outlineRule
+	for i := 0; i+1 < len(pairs); i += 2 {
+		list = append(list, outlineRule{regexp.MustCompile(pairs[i]), pairs[i+1]})
+	}
+	return list
+}
+
+var jsOutlineRules = outlineRuleList(
+	`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[\w$]+)`, "function",
+	`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(?P<name>[\w$]+)`, "class",
+	`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[\w$]+)\s*(?::[^=]+)?=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[\w$]+\s*=>)`, "function",
+	`^\s*(?:export\s+)?interface\s+(?P<name>[\w$]+)`, "interface",
+	`^\s*(?:export\s+)?type\s+(?P<name>[\w$]+)\s*(?:<[^=]*>)?\s*=`, "type",
+	`^\s*(?:export\s+)?(?:const\s+)?enum\s+(?P<name>[\w$]+)`, "enum",
+	`^\s+(?:(?:public|private|protected|static|async|readonly|override|get|set)\s+)*(?P<name>[\w$]+)\s*(?:<[^>]*>)?\([^)]*\)\s*(?::[^{]+)?\{`, "method",
+)
+
+var cLikeOutlineRules = outlineRuleList(
+	`^\s*(?:typedef\s+)?struct\s+(?P<name>\w+)\s*\{`, "struct",
+	`^\s*(?:typedef\s+)?enum\s+(?:class\s+)?(?P<name>\w+)`, "enum",
+	`^\s*(?:template\s*<[^>]*>\s*)?class\s+(?P<name>\w+)[^;]*$`, "class",
+	`^\s*namespace\s+(?P<name>[\w:]+)`, "module",
+	`^(?:[\w:*&<>,]+\s+)+\**(?P<name>[\w:~]+)\s*\([^;]*$`, "function",
+)
+
+// outlineRules are the regex fallbacks by language
+var outlineRules = map[string][]outlineRule{
+	"python": outlineRuleList(
+		`^\s*(?:async\s+)?def\s+(?P<name>\w+)`, "function",
+		`^\s*class\s+(?P<name>\w+)`, "class",
+	),
+	"javascript": jsOutlineRules,
+	"typescript": jsOutlineRules,
+	"jsx":        jsOutlineRules,
+	"tsx":        jsOutlineRules,
+	"rust": outlineRuleList(
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const\s+)?(?:async\s+)?(?:unsafe\s+)?(?:extern\s+"[^"]*"\s+)?fn\s+(?P<name>\w+)`, "function",
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?struct\s+(?P<name>\w+)`, "struct",
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(?P<name>\w+)`, "enum",
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(?P<name>\w+)`, "trait",
+		`^\s*(?:unsafe\s+)?impl(?:<[^>]*>)?\s+(?P<name>[^{]+?)\s*(?:where\b[^{]*)?\{?\s*$`, "impl",
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(?P<name>\w+)`, "module",
+		`^\s*(?:pub(?:\([^)]*\))?\s+)?type\s+(?P<name>\w+)`, "type",
+		`^\s*macro_rules!\s*(?P<name>\w+)`, "macro",
+	),
+	"java": outlineRuleList(
+		`^\s*(?:(?:public|private|protected|static|final|abstract|sealed)\s+)*(?:class|record)\s+(?P<name>\w+)`, "class",
+		`^\s*(?:(?:public|private|protected|static)\s+)*(?:@)?interface\s+(?P<name>\w+)`, "interface",
+		`^\s*(?:(?:public|private|protected|static)\s+)*enum\s+(?P<name>\w+)`, "enum",
+		`^\s+(?:(?:public|private|protected|static|final|abstract|synchronized|native|default)\s+)*(?:<[^>]*>\s*)?[\w<>\[\],.?\s]+\s+(?P<name>\w+)\s*\([^;]*$`, "method",
+	),
+	"c":   cLikeOutlineRules,
+	"cpp": cLikeOutlineRules,
+	"ruby": outlineRuleList(
+		`^\s*def\s+(?P<name>(?:self\.)?[\w?!=]+)`, "method",
+		`^\s*class\s+(?P<name>[\w:]+)`, "class",
+		`^\s*module\s+(?P<name>[\w:]+)`, "module",
+	),
+	"php": outlineRuleList(
+		`^\s*(?:(?:public|private|protected|static|abstract|final)\s+)*function\s+(?P<name>\w+)`, "function",
+		`^\s*(?:(?:abstract|final)\s+)?class\s+(?P<name>\w+)`, "class",
+		`^\s*interface\s+(?P<name>\w+)`, "interface",
+		`^\s*trait\s+(?P<name>\w+)`, "trait",
+	),
+	"bash": outlineRuleList(
+		`^\s*(?:function\s+)?(?P<name>[\w.:-]+)\s*\(\)\s*\{?`, "function",
+		`^\s*function\s+(?P<name>[\w.:-]+)`, "function",
+	),
+	"sql": outlineRuleList(
+		`(?i)^\s*create\s+(?:or\s+replace\s+)?(?:temp(?:orary)?\s+)?(?P<kind>table|view|index|function|procedure|trigger|type)\s+(?:if\s+not\s+exists\s+)?(?P<name>[\w."]+)`, "",
+	),
+}
+
+// outlineKeywords are words the method rules match that are control flow, not names
+var outlineKeywords = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true,
+	"function": true, "else": true, "do": true, "try": true, "with": true, "new": true, "sizeof": true,
+}
+
+// markdownHeading matches an ATX heading
+var markdownHeading = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+
+// goOutline lists a Go file's declarations with go/parser
+func goOutline(name string, src []byte) ([]OutlineSymbol, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, name, src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil, err
+	}
+	symbols := []OutlineSymbol{}
+	add := func(name, kind string, node ast.Node, depth int, detail string) {
+		symbols = append(symbols, OutlineSymbol{
+			Name:    name,
+			Kind:    kind,
+			Line:    fset.Position(node.Pos()).Line,
+			EndLine: fset.Position(node.End()).Line,
+			Depth:   depth,
+			Detail:  detail,
+		})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name.Name, "method", d, 0, types.ExprString(d.Recv.List[0].Type))
+			} else {
+				add(d.Name.Name, "function", d, 0, "")
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						add(s.Name.Name, "struct", s, 0, "")
+					case *ast.InterfaceType:
+						add(s.Name.Name, "interface", s, 0, "")
+						for _, m := range t.Methods.List {
+							for _, n := range m.Names {
+								add(n.Name, "method", m, 1, "")
+							}
+						}
+					default:
+						add(s.Name.Name, "type", s, 0, "")
+					}
+				case *ast.ValueSpec:
+					for _, n := range s.Names {
+						if n.Name != "_" {
+							add(n.Name, d.Tok.String(), s, 0, "")
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols, err
+}
+
+// markdownOutline lists a Markdown file's headings, skipping fenced code
+func markdownOutline(lines []string) []OutlineSymbol {
+	symbols := []OutlineSymbol{}
+	fence := ""
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+			}
+			continue
+		}
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			fence = trimmed[:3]
+			continue
+		}
+		if m := markdownHeading.FindStringSubmatch(line); m != nil {
+			symbols = append(symbols, OutlineSymbol{Name: m[2], Kind: "heading", Line: i + 1, Depth: len(m[1]) - 1})
+		}
+	}
+	return symbols
+}
+
+// regexOutline lists the symbols the language's rules match, nested by indentation
+func regexOutline(lines []string, list []outlineRule) []OutlineSymbol {
+	symbols := []OutlineSymbol{}
+	var indents []int
+	for i, line := range lines {
+		for _, rule := range list {
+			m := rule.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			name := strings.TrimSpace(m[rule.re.SubexpIndex("name")])
+			if name == "" || outlineKeywords[name] {
+				continue
+			}
+			kind := rule.kind
+			if k := rule.re.SubexpIndex("kind"); k >= 0 {
+				kind = strings.ToLower(m[k])
+			}
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			for len(indents) > 0 && indents[len(indents)-1] >= indent {
+				indents = indents[:len(indents)-1]
+			}
+			symbols = append(symbols, OutlineSymbol{Name: name, Kind: kind, Line: i + 1, Depth: len(indents)})
+			indents = append(indents, indent)
+			break
+		}
+		if len(symbols) >= outlineSymbolLimit {
+			break
+		}
+	}
+	return symbols
+}
+
+// fileOutline lists the symbols of a file in language; name is its base name
+func fileOutline(name, language string, src []byte) OutlineResponse {
+	resp := OutlineResponse{Path: name, Language: language, Parser: "regex", Symbols: []OutlineSymbol{}}
+	lines := strings.Split(string(src), "\n")
+	switch {
+	case language == "go":
+		resp.Parser = "go"
+		symbols, err := goOutline(name, src)
+		if symbols != nil {
+			resp.Symbols = symbols
+		}
+		if err != nil {
+			resp.Error = err.Error()
+		}
+	case language == "markdown":
+		resp.Symbols = markdownOutline(lines)
+	case outlineRules[language] != nil:
+		resp.Symbols = regexOutline(lines, outlineRules[language])
+	default:
+		resp.Parser = "none"
+	}
+	if len(resp.Symbols) > outlineSymbolLimit {
+		resp.Symbols = resp.Symbols[:outlineSymbolLimit]
+	}
+	return resp
+}
+
+// FileOutline handles POST /api/file/outline
+// Returns the functions, types, and headings of a text file for the viewer's outline and minimap:
+// Go files are parsed with go/parser, other languages matched line by line
+func FileOutline(c *gin.Context) {
+	var req OutlineRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is required")
+		return
+	}
+	if !userCanAccessPath(currentUser(c), req.Path) {
+		denyPath(c, req.Path)
+		return
+	}
+	language := req.Language
+	if language == "" {
+		language = langMap[strings.ToLower(path.Ext(req.Path))]
+	}
+
+	var src []byte
+	if req.Content != nil {
+		src = []byte(*req.Content)
+	} else if h, remotePath, ok := parseRemotePath(req.Path); ok {
+		var attrs sftpAttrs
+		err := withSFTP(h, func(client *sftpClient) error {
+			var err error
+			attrs, err = client.Stat(remotePath)
+			if err != nil || attrs.isDir() || attrs.Size > maxFileSize {
+				return err
+			}
+			src, err = client.ReadFile(remotePath, maxFileSize+1)
+			return err
+		})
+		if err != nil {
+			remoteFileError(c, err, "File does not exist")
+			return
+		}
+		if attrs.isDir() {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
+			return
+		}
+		if attrs.Size > maxFileSize {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
+			return
+		}
+	} else {
+		info, err := os.Stat(req.Path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				respondError(c, http.StatusNotFound, ErrNotFound, "File does not exist")
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+			return
+		}
+		if info.IsDir() {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
+			return
+		}
+		if info.Size() > maxFileSize {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
+			return
+		}
+		if src, err = os.ReadFile(req.Path); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to read file")
+			return
+		}
+	}
+	if len(src) > maxFileSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
+		return
+	}
+	if !utf8.Valid(src) {
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, "File is binary")
+		return
+	}
+	if language == "" {
+		language = "plaintext"
+	}
+	resp := fileOutline(filepath.Base(req.Path), language, src)
+	resp.Path = req.Path
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Pinned sessions are kept by POST /api/storage/cleanup
+var (
+	pinsMu     sync.Mutex
+	pinStore   map[string]time.Time // session ID -> when it was pinned
+	pinsLoaded bool
+)
+
+func pinsPath() string {
+	return serverDataPath("pinned-sessions.json")
+}
+
+// loadPins reads the pin store once; caller must hold pinsMu
+func loadPins() error {
+	if pinsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(pinsPath(), &pinStore); err != nil {
+		return err
+	}
+	if pinStore == nil {
+		pinStore = make(map[string]time.Time)
+	}
+	pinsLoaded = true
+	return nil
+}
+
+// setSessionPinned pins or unpins a session and saves the store
+func setSessionPinned(sessionID string, pinned bool) error {
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+	if err := loadPins(); err != nil {
+		return err
+	}
+	if _, ok := pinStore[sessionID]; ok == pinned {
+		return nil
+	}
+	if pinned {
+		pinStore[sessionID] = time.Now()
+	} else {
+		delete(pinStore, sessionID)
+	}
+	return writeJSONFileAtomic(pinsPath(), pinStore)
+}
+
+// unpinSession drops a deleted session's pin
+func unpinSession(sessionID string) {
+	if err := setSessionPinned(sessionID, false); err != nil {
+		log.Printf("[Pins] Failed to save pinned sessions: %v", err)
+	}
+}
+
+// isSessionPinned reports whether a session is pinned
+func isSessionPinned(sessionID string) bool {
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+	if err := loadPins(); err != nil {
+		return false
+	}
+	_, ok := pinStore[sessionID]
+	return ok
+}
+
+// attachPins sets Pinned on pinned sessions
+func attachPins(sessions []Session) {
+	pinsMu.Lock()
+	defer pinsMu.Unlock()
+	if err := loadPins(); err != nil {
+		log.Printf("[Pins] Failed to load pinned sessions: %v", err)
+		return
+	}
+	for i := range sessions {
+		_, sessions[i].Pinned = pinStore[sessions[i].SessionID]
+	}
+}
+
+// PinSession handles POST /api/session/:id/pin
+func PinSession(c *gin.Context) {
+	updateSessionPin(c, true)
+}
+
+// UnpinSession handles DELETE /api/session/:id/pin
+func UnpinSession(c *gin.Context) {
+	updateSessionPin(c, false)
+}
+
+func updateSessionPin(c *gin.Context, pinned bool) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	if err := setSessionPinned(sessionID, pinned); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save pinned sessions", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessionId": sessionID, "pinned": pinned})
+}
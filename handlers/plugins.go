@@ -0,0 +1,407 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// pluginNameRegex restricts plugin names derived from user input
+var pluginNameRegex = regexp.MustCompile(`^[A-Za-z0-9._@-]+$`)
+
+// pluginOpLock serializes plugin operations that rewrite installed_plugins.json
+var pluginOpLock sync.Mutex
+
+// InstallPluginRequest represents the request body for POST /api/plugins/install
+type InstallPluginRequest struct {
+	Source string `json:"source"` // git URL or marketplace plugin name
+	Name   string `json:"name"`   // optional override for git installs
+}
+
+// getPluginsDir returns the plugins directory path (~/.claude/plugins)
+func getPluginsDir() string {
+	return filepath.Join(getClaudeDir(), "plugins")
+}
+
+// installedPluginsPath returns the path of installed_plugins.json
+func installedPluginsPath() string {
+	return filepath.Join(getPluginsDir(), "installed_plugins.json")
+}
+
+// isGitSource reports whether a plugin source looks like a git repository URL
+func isGitSource(source string) bool {
+	return strings.HasPrefix(source, "https://") ||
+		strings.HasPrefix(source, "http://") ||
+		strings.HasPrefix(source, "git@") ||
+		strings.HasPrefix(source, "ssh://") ||
+		strings.HasSuffix(source, ".git")
+}
+
+// pluginNameFromGitURL derives a plugin name from the last path segment of a git URL
+func pluginNameFromGitURL(url string) string {
+	url = strings.TrimSuffix(strings.TrimSuffix(url, "/"), ".git")
+	if i := strings.LastIndexAny(url, "/:"); i >= 0 {
+		url = url[i+1:]
+	}
+	return url
+}
+
+// modifyInstalledPlugins loads installed_plugins.json, applies fn to the plugins map,
+// and writes it back, preserving fields this server doesn't know about
+func modifyInstalledPlugins(fn func(plugins map[string][]map[string]interface{}) error) error {
+	path := installedPluginsPath()
+	root := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		if err := json.Unmarshal(data, &root); err != nil {
+			return fmt.Errorf("failed to parse installed_plugins.json: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	plugins := map[string][]map[string]interface{}{}
+	if raw, ok := root["plugins"]; ok {
+		if err := json.Unmarshal(raw, &plugins); err != nil {
+			return fmt.Errorf("failed to parse plugins: %w", err)
+		}
+	}
+	if _, ok := root["version"]; !ok {
+		root["version"] = json.RawMessage("2")
+	}
+
+	if err := fn(plugins); err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(plugins)
+	if err != nil {
+		return err
+	}
+	root["plugins"] = raw
+	return writeJSONFileAtomic(path, root)
+}
+
+// findInstalledPlugin returns the most recent installation entry for a plugin
+func findInstalledPlugin(name string) (*InstalledPluginEntry, error) {
+	data, err := os.ReadFile(installedPluginsPath())
+	if err != nil {
+		return nil, err
+	}
+	var pluginsData InstalledPluginsFile
+	if err := json.Unmarshal(data, &pluginsData); err != nil {
+		return nil, err
+	}
+	entries := pluginsData.Plugins[name]
+	if len(entries) == 0 {
+		return nil, os.ErrNotExist
+	}
+	return &entries[0], nil
+}
+
+// readPluginVersion reads the version from a plugin's manifest, if present
+func readPluginVersion(installPath string) string {
+	for _, manifest := range []string{
+		filepath.Join(installPath, ".claude-plugin", "plugin.json"),
+		filepath.Join(installPath, "plugin.json"),
+	} {
+		data, err := os.ReadFile(manifest)
+		if err != nil {
+			continue
+		}
+		var meta struct {
+			Version string `json:"version"`
+		}
+		if json.Unmarshal(data, &meta) == nil && meta.Version != "" {
+			return meta.Version
+		}
+	}
+	return "unknown"
+}
+
+// gitHeadSha returns the current commit of a git checkout
+func gitHeadSha(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// startPluginStream sets SSE headers and returns a flusher for progress events
+func startPluginStream(c *gin.Context) (http.Flusher, bool) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		sendSSEError(c, ErrInternal, "Streaming not supported")
+		return nil, false
+	}
+	return flusher, true
+}
+
+// runStreamingCommand runs cmd and forwards each stdout/stderr line as a progress event
+func runStreamingCommand(c *gin.Context, flusher http.Flusher, cmd *exec.Cmd) error {
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	log.Printf("[Plugins] Executing: %s", strings.Join(cmd.Args, " "))
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		return err
+	}
+
+	go func() {
+		pw.CloseWithError(cmd.Wait())
+	}()
+
+	scanner := bufio.NewScanner(pr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			sendSSEMessage(c, SSEMessage{Type: "progress", Message: line})
+			flusher.Flush()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// InstallPlugin handles POST /api/plugins/install
+// Git URLs are cloned into ~/.claude/plugins/cache/{name} and registered in
+// installed_plugins.json; anything else is treated as a marketplace plugin name
+// and delegated to `claude plugin install`. Progress is streamed via SSE.
+func InstallPlugin(c *gin.Context) {
+	var req InstallPluginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Source == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "source is required")
+		return
+	}
+	if strings.HasPrefix(req.Source, "-") {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid source")
+		return
+	}
+
+	name := req.Name
+	if isGitSource(req.Source) && name == "" {
+		name = pluginNameFromGitURL(req.Source)
+	}
+	if isGitSource(req.Source) && !pluginNameRegex.MatchString(name) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("Invalid plugin name: %s", name))
+		return
+	}
+
+	if !pluginOpLock.TryLock() {
+		respondError(c, http.StatusConflict, ErrConflict, "Another plugin operation is in progress")
+		return
+	}
+	defer pluginOpLock.Unlock()
+
+	flusher, ok := startPluginStream(c)
+	if !ok {
+		return
+	}
+
+	// Marketplace install: the CLI manages the plugin directory and registry
+	if !isGitSource(req.Source) {
+		sendSSEMessage(c, SSEMessage{Type: "progress", Message: fmt.Sprintf("Installing %s from marketplace", req.Source)})
+		flusher.Flush()
+		if err := runStreamingCommand(c, flusher, exec.Command("claude", "plugin", "install", req.Source)); err != nil {
+			sendSSEError(c, ErrCLIFailed, fmt.Sprintf("Plugin install failed: %v", err))
+			return
+		}
+		sendSSEMessage(c, SSEMessage{Type: "done", Data: map[string]interface{}{"name": req.Source}})
+		flusher.Flush()
+		return
+	}
+
+	installPath := filepath.Join(getPluginsDir(), "cache", name)
+	if _, err := os.Stat(installPath); err == nil {
+		sendSSEError(c, ErrConflict, fmt.Sprintf("Plugin directory already exists: %s", installPath))
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(installPath), 0755); err != nil {
+		sendSSEError(c, ErrInternal, fmt.Sprintf("Failed to create plugins directory: %v", err))
+		return
+	}
+
+	sendSSEMessage(c, SSEMessage{Type: "progress", Message: fmt.Sprintf("Cloning %s", req.Source)})
+	flusher.Flush()
+	cmd := exec.Command("git", "clone", "--progress", "--depth", "1", "--", req.Source, installPath)
+	if err := runStreamingCommand(c, flusher, cmd); err != nil {
+		os.RemoveAll(installPath)
+		sendSSEError(c, ErrUpstream, fmt.Sprintf("git clone failed: %v", err))
+		return
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	entry := map[string]interface{}{
+		"scope":        "user",
+		"installPath":  installPath,
+		"version":      readPluginVersion(installPath),
+		"installedAt":  now,
+		"lastUpdated":  now,
+		"gitCommitSha": gitHeadSha(installPath),
+	}
+	err := modifyInstalledPlugins(func(plugins map[string][]map[string]interface{}) error {
+		plugins[name] = append([]map[string]interface{}{entry}, plugins[name]...)
+		return nil
+	})
+	if err != nil {
+		os.RemoveAll(installPath)
+		sendSSEError(c, ErrInternal, fmt.Sprintf("Failed to register plugin: %v", err))
+		return
+	}
+
+	log.Printf("[Plugins] Installed %s from %s", name, req.Source)
+	sendSSEMessage(c, SSEMessage{Type: "done", Data: map[string]interface{}{"name": name, "entry": entry}})
+	flusher.Flush()
+}
+
+// UninstallPlugin handles DELETE /api/plugins/:name
+// Removes the registry entry and the plugin directory if it lives under ~/.claude/plugins
+func UninstallPlugin(c *gin.Context) {
+	name := c.Param("name")
+
+	pluginOpLock.Lock()
+	defer pluginOpLock.Unlock()
+
+	var removed []map[string]interface{}
+	err := modifyInstalledPlugins(func(plugins map[string][]map[string]interface{}) error {
+		entries, ok := plugins[name]
+		if !ok {
+			return os.ErrNotExist
+		}
+		removed = entries
+		delete(plugins, name)
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("Plugin %s not found", name))
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+
+	// Only delete directories we own; never follow an installPath outside the plugins dir
+	pluginsDir := getPluginsDir() + string(filepath.Separator)
+	for _, entry := range removed {
+		installPath, _ := entry["installPath"].(string)
+		cleanPath := filepath.Clean(installPath)
+		if installPath != "" && strings.HasPrefix(cleanPath, pluginsDir) {
+			if err := os.RemoveAll(cleanPath); err != nil {
+				log.Printf("[Plugins] Failed to remove %s: %v", cleanPath, err)
+			}
+		}
+	}
+
+	log.Printf("[Plugins] Uninstalled %s", name)
+	c.JSON(http.StatusOK, gin.H{"success": true, "name": name})
+}
+
+// UpdatePlugin handles POST /api/plugins/:name/update
+// Pulls the latest commit for git-installed plugins, otherwise delegates to
+// `claude plugin update`. Progress is streamed via SSE.
+func UpdatePlugin(c *gin.Context) {
+	name := c.Param("name")
+
+	entry, err := findInstalledPlugin(name)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("Plugin %s not found", name))
+		return
+	}
+
+	if !pluginOpLock.TryLock() {
+		respondError(c, http.StatusConflict, ErrConflict, "Another plugin operation is in progress")
+		return
+	}
+	defer pluginOpLock.Unlock()
+
+	flusher, ok := startPluginStream(c)
+	if !ok {
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(entry.InstallPath, ".git")); err != nil {
+		sendSSEMessage(c, SSEMessage{Type: "progress", Message: fmt.Sprintf("Updating %s via claude CLI", name)})
+		flusher.Flush()
+		if err := runStreamingCommand(c, flusher, exec.Command("claude", "plugin", "update", name)); err != nil {
+			sendSSEError(c, ErrCLIFailed, fmt.Sprintf("Plugin update failed: %v", err))
+			return
+		}
+		sendSSEMessage(c, SSEMessage{Type: "done", Data: map[string]interface{}{"name": name}})
+		flusher.Flush()
+		return
+	}
+
+	previousSha := entry.GitCommitSha
+	cmd := exec.Command("git", "-C", entry.InstallPath, "pull", "--ff-only", "--progress")
+	if err := runStreamingCommand(c, flusher, cmd); err != nil {
+		sendSSEError(c, ErrUpstream, fmt.Sprintf("git pull failed: %v", err))
+		return
+	}
+
+	sha := gitHeadSha(entry.InstallPath)
+	version := readPluginVersion(entry.InstallPath)
+	err = modifyInstalledPlugins(func(plugins map[string][]map[string]interface{}) error {
+		entries := plugins[name]
+		if len(entries) == 0 {
+			return os.ErrNotExist
+		}
+		entries[0]["gitCommitSha"] = sha
+		entries[0]["version"] = version
+		entries[0]["lastUpdated"] = time.Now().UTC().Format(time.RFC3339)
+		return nil
+	})
+	if err != nil {
+		sendSSEError(c, ErrInternal, fmt.Sprintf("Failed to update plugin registry: %v", err))
+		return
+	}
+
+	log.Printf("[Plugins] Updated %s (%s -> %s)", name, previousSha, sha)
+	sendSSEMessage(c, SSEMessage{Type: "done", Data: map[string]interface{}{
+		"name":        name,
+		"version":     version,
+		"previousSha": previousSha,
+		"sha":         sha,
+		"updated":     previousSha != sha,
+	}})
+	flusher.Flush()
+}
+
+// forEachInstalledPlugin calls fn with the most recent entry of every installed plugin
+func forEachInstalledPlugin(fn func(pluginName string, entry InstalledPluginEntry)) {
+	data, err := os.ReadFile(installedPluginsPath())
+	if err != nil {
+		return
+	}
+	var pluginsData InstalledPluginsFile
+	if err := json.Unmarshal(data, &pluginsData); err != nil {
+		return
+	}
+	for pluginName, entries := range pluginsData.Plugins {
+		if len(entries) == 0 {
+			continue
+		}
+		fn(pluginName, entries[0])
+	}
+}
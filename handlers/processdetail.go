@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// processOutputKeep is how many streamed lines a running process keeps for its detail view
+	processOutputKeep = 200
+	// processLineLimit truncates each kept line; stream-json lines can carry whole files
+	processLineLimit = 4096
+	// activityDetailLimit truncates the argument shown next to a running tool
+	activityDetailLimit = 200
+)
+
+// activityDetailKeys are the tool input fields that best describe what a call does, in order of preference
+var activityDetailKeys = []string{"file_path", "notebook_path", "command", "pattern", "url", "query", "path", "description", "prompt"}
+
+// ProcessActivity is what a claude run is doing right now
+type ProcessActivity struct {
+	State     string `json:"state"` // starting, thinking, responding, tool, done; running for terminals
+	Tool      string `json:"tool,omitempty"`
+	ToolUseID string `json:"toolUseId,omitempty"`
+	Detail    string `json:"detail,omitempty"` // the file, command, or pattern the tool was given
+	Since     int64  `json:"since"`            // unix seconds
+}
+
+// ProcessDetail is the response of GET /api/processes/:id
+type ProcessDetail struct {
+	ProcessID    int               `json:"processId"`
+	SessionID    string            `json:"sessionId"`
+	WorkDir      string            `json:"workDir"`
+	StartTime    int64             `json:"startTime"`
+	Mode         string            `json:"mode"`
+	Backend      string            `json:"backend,omitempty"`
+	ChatBackend  string            `json:"chatBackend,omitempty"`
+	Owner        string            `json:"owner,omitempty"`
+	Activity     ProcessActivity   `json:"activity"`
+	Output       []string          `json:"output"` // the last streamed lines, oldest first
+	LastOutputAt int64             `json:"lastOutputAt,omitempty"`
+	Resumable    bool              `json:"resumable"` // GET /api/chat/stream/:processId can follow it
+	Resources    *ProcessResources `json:"resources,omitempty"`
+}
+
+// processOutput is the tail of a process's output and its parsed activity
+type processOutput struct {
+	mu         sync.Mutex
+	lines      []string
+	sessionID  string
+	activity   ProcessActivity
+	lastOutput time.Time
+}
+
+func newProcessOutput() *processOutput {
+	return &processOutput{activity: ProcessActivity{State: "starting", Since: time.Now().Unix()}}
+}
+
+// clipText shortens s to at most limit bytes without splitting a character
+func clipText(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return s[:limit] + "…"
+}
+
+// recordProcessOutput keeps a streamed line of a running process and updates its activity
+func recordProcessOutput(processID int, line string) {
+	processLock.RLock()
+	info := activeProcesses[processID]
+	processLock.RUnlock()
+	if info == nil || info.output == nil {
+		return
+	}
+	out := info.output
+
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.lines = append(out.lines, clipText(line, processLineLimit))
+	if len(out.lines) > processOutputKeep {
+		out.lines = out.lines[len(out.lines)-processOutputKeep:]
+	}
+	out.lastOutput = time.Now()
+	if sessionID := extractInitSessionID(line); sessionID != "" {
+		out.sessionID = sessionID
+	}
+	if activity, ok := parseActivity(line, out.activity); ok {
+		if activity.State != out.activity.State || activity.ToolUseID != out.activity.ToolUseID {
+			activity.Since = time.Now().Unix()
+			out.activity = activity
+		}
+	}
+}
+
+// parseActivity derives the current activity from a stream-json line
+// ok is false when the line doesn't change what the run is doing
+func parseActivity(line string, current ProcessActivity) (ProcessActivity, bool) {
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content []struct {
+				Type      string                 `json:"type"`
+				ID        string                 `json:"id"`
+				Name      string                 `json:"name"`
+				Input     map[string]interface{} `json:"input"`
+				ToolUseID string                 `json:"tool_use_id"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return current, false
+	}
+
+	switch event.Type {
+	case "result":
+		return ProcessActivity{State: "done"}, true
+	case "assistant":
+		// The last block wins: a tool call usually follows the text announcing it
+		activity, found := current, false
+		for _, block := range event.Message.Content {
+			switch block.Type {
+			case "tool_use":
+				activity, found = ProcessActivity{State: "tool", Tool: block.Name, ToolUseID: block.ID, Detail: toolActivityDetail(block.Input)}, true
+			case "text":
+				activity, found = ProcessActivity{State: "responding"}, true
+			case "thinking":
+				activity, found = ProcessActivity{State: "thinking"}, true
+			}
+		}
+		return activity, found
+	case "user":
+		// A tool result hands control back to the model
+		for _, block := range event.Message.Content {
+			if block.Type == "tool_result" && block.ToolUseID == current.ToolUseID {
+				return ProcessActivity{State: "thinking"}, true
+			}
+		}
+	}
+	return current, false
+}
+
+// toolActivityDetail picks the most telling argument of a tool call
+func toolActivityDetail(input map[string]interface{}) string {
+	for _, key := range activityDetailKeys {
+		if value, ok := input[key].(string); ok && value != "" {
+			return clipText(value, activityDetailLimit)
+		}
+	}
+	return ""
+}
+
+// GetProcess handles GET /api/processes/:id
+// Returns a running process with its last streamed lines and what it is doing now
+// Query parameters:
+//   - lines: how many output lines to include (default 20, max 200, 0 for none)
+func GetProcess(c *gin.Context) {
+	processID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid process ID")
+		return
+	}
+	lines, err := strconv.Atoi(c.DefaultQuery("lines", "20"))
+	if err != nil || lines < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid lines parameter")
+		return
+	}
+	if lines > processOutputKeep {
+		lines = processOutputKeep
+	}
+
+	processLock.RLock()
+	info := activeProcesses[processID]
+	var detail ProcessDetail
+	if info != nil {
+		detail = ProcessDetail{
+			ProcessID:   processID,
+			SessionID:   info.SessionID,
+			WorkDir:     info.WorkDir,
+			StartTime:   info.StartTime,
+			Mode:        info.Mode,
+			Backend:     info.Backend,
+			ChatBackend: info.ChatBackend,
+			Owner:       info.Owner,
+		}
+	}
+	processLock.RUnlock()
+	if info == nil || !userCanAccessOwner(currentUser(c), info.Owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Process not found")
+		return
+	}
+
+	detail.Output = []string{}
+	if out := info.output; out != nil {
+		out.mu.Lock()
+		detail.Activity = out.activity
+		if detail.SessionID == "" {
+			detail.SessionID = out.sessionID
+		}
+		tail := out.lines
+		if len(tail) > lines {
+			tail = tail[len(tail)-lines:]
+		}
+		detail.Output = append(detail.Output, tail...)
+		if !out.lastOutput.IsZero() {
+			detail.LastOutputAt = out.lastOutput.Unix()
+		}
+		out.mu.Unlock()
+	} else {
+		detail.Activity = ProcessActivity{State: "running", Since: detail.StartTime}
+	}
+
+	chatStreamsMu.Lock()
+	detail.Resumable = chatStreams[processID] != nil
+	chatStreamsMu.Unlock()
+	if r, ok := sampleProcessResources()[processID]; ok {
+		detail.Resources = &r
+	}
+	c.JSON(http.StatusOK, detail)
+}
@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"os/exec"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// processLimitTick is how often running processes are checked against limits.processes
+const processLimitTick = 10 * time.Second
+
+// ProcessLimitsConfig stops abandoned and runaway terminals and chat runs (0 = no limit)
+type ProcessLimitsConfig struct {
+	// TerminalIdleMinutes closes a terminal with no input or output for this long
+	TerminalIdleMinutes int `yaml:"terminalIdleMinutes" json:"terminalIdleMinutes"`
+	// TerminalMaxMinutes closes a terminal this long after it opened
+	TerminalMaxMinutes int `yaml:"terminalMaxMinutes" json:"terminalMaxMinutes"`
+	// ChatIdleMinutes stops a chat run that printed nothing for this long
+	ChatIdleMinutes int `yaml:"chatIdleMinutes" json:"chatIdleMinutes"`
+	// ChatMaxMinutes stops a chat run this long after it started
+	ChatMaxMinutes int `yaml:"chatMaxMinutes" json:"chatMaxMinutes"`
+	// WarnSeconds is how long before a process is stopped that it is warned
+	WarnSeconds int `yaml:"warnSeconds" json:"warnSeconds"`
+	// MaxMemoryMB caps the address space (RLIMIT_AS) of each shell and claude process and their children
+	MaxMemoryMB int `yaml:"maxMemoryMB" json:"maxMemoryMB"`
+	// MaxProcesses caps RLIMIT_NPROC for shells and claude processes; it counts every process of the server's user
+	MaxProcesses int `yaml:"maxProcesses" json:"maxProcesses"`
+	// MaxTerminalsPerUser caps a user's running terminals, detached ones with a terminalId included
+	MaxTerminalsPerUser int `yaml:"maxTerminalsPerUser" json:"maxTerminalsPerUser"`
+}
+
+// ProcessLimitEvent is the payload of a processLimit event, sent when a chat run is about to be or was stopped
+type ProcessLimitEvent struct {
+	ProcessID int    `json:"processId"`
+	Reason    string `json:"reason"` // idle or lifetime
+	StopsAt   int64  `json:"stopsAt,omitempty"`
+	Stopped   bool   `json:"stopped"`
+}
+
+// limitDeadline returns when a process that started at started and was last active at active gets stopped, and why;
+// zero when neither limit is set
+func limitDeadline(idleMinutes, maxMinutes int, started, active time.Time) (time.Time, string) {
+	var deadline time.Time
+	reason := ""
+	if idleMinutes > 0 {
+		deadline, reason = active.Add(time.Duration(idleMinutes)*time.Minute), "idle"
+	}
+	if maxMinutes > 0 {
+		if end := started.Add(time.Duration(maxMinutes) * time.Minute); deadline.IsZero() || end.Before(deadline) {
+			deadline, reason = end, "lifetime"
+		}
+	}
+	return deadline, reason
+}
+
+// limitReason describes why a process is stopped
+func limitReason(reason string, idleMinutes, maxMinutes int) string {
+	minutes := func(n int) string {
+		if n == 1 {
+			return "1 minute"
+		}
+		return fmt.Sprintf("%d minutes", n)
+	}
+	if reason == "idle" {
+		return "idle for " + minutes(idleMinutes)
+	}
+	return "running for " + minutes(maxMinutes)
+}
+
+// limitProcessResources applies limits.processes' rlimits to a started process; processes it starts inherit them
+func limitProcessResources(cmd *exec.Cmd) {
+	limits := getServerConfig().Limits.Processes
+	if cmd.Process == nil {
+		return
+	}
+	set := func(resource int, value uint64, name string) {
+		rlim := unix.Rlimit{Cur: value, Max: value}
+		if err := unix.Prlimit(cmd.Process.Pid, resource, &rlim, nil); err != nil {
+			log.Printf("[Limits] Failed to set the %s of process %d: %v", name, cmd.Process.Pid, err)
+		}
+	}
+	if limits.MaxMemoryMB > 0 {
+		set(unix.RLIMIT_AS, uint64(limits.MaxMemoryMB)<<20, "memory limit")
+	}
+	if limits.MaxProcesses > 0 {
+		set(unix.RLIMIT_NPROC, uint64(limits.MaxProcesses), "process limit")
+	}
+}
+
+// StartProcessLimits stops chat runs past limits.processes.chatIdleMinutes or chatMaxMinutes,
+// warning their session warnSeconds before with a processLimit event
+func StartProcessLimits() {
+	go func() {
+		warned := make(map[int]time.Time)
+		stopped := make(map[int]bool)
+		for now := range time.Tick(processLimitTick) {
+			enforceChatLimits(now, warned, stopped)
+		}
+	}()
+}
+
+// enforceChatLimits checks every running chat once; warned and stopped carry state between calls
+func enforceChatLimits(now time.Time, warned map[int]time.Time, stopped map[int]bool) {
+	limits := getServerConfig().Limits.Processes
+	processLock.RLock()
+	running := make(map[int]*ProcessInfo, len(activeProcesses))
+	for id, info := range activeProcesses {
+		if info.output != nil {
+			running[id] = info
+		}
+	}
+	processLock.RUnlock()
+	for id := range warned {
+		if running[id] == nil {
+			delete(warned, id)
+		}
+	}
+	for id := range stopped {
+		if running[id] == nil {
+			delete(stopped, id)
+		}
+	}
+
+	for id, info := range running {
+		started := time.Unix(info.StartTime, 0)
+		info.output.mu.Lock()
+		active, sessionID := info.output.lastOutput, info.output.sessionID
+		info.output.mu.Unlock()
+		if active.IsZero() {
+			active = started
+		}
+		if info.SessionID != "" {
+			sessionID = info.SessionID
+		}
+		deadline, reason := limitDeadline(limits.ChatIdleMinutes, limits.ChatMaxMinutes, started, active)
+		if deadline.IsZero() || stopped[id] {
+			continue
+		}
+		event := ProcessLimitEvent{ProcessID: id, Reason: reason}
+		if !now.Before(deadline) {
+			log.Printf("[Limits] Stopping %s process %d (session %s): %s", info.Mode, id, sessionID,
+				limitReason(reason, limits.ChatIdleMinutes, limits.ChatMaxMinutes))
+			stopped[id] = true
+			event.Stopped = true
+			broadcastSessionEvent(sessionID, "processLimit", map[string]interface{}{"limit": event})
+			if err := info.Interrupt(); err != nil {
+				log.Printf("[Limits] Failed to stop process %d: %v", id, err)
+			}
+			continue
+		}
+		warn := time.Duration(limits.WarnSeconds) * time.Second
+		if warn > 0 && now.Add(warn).After(deadline) && !warned[id].Equal(deadline) {
+			warned[id] = deadline
+			event.StopsAt = deadline.Unix()
+			broadcastSessionEvent(sessionID, "processLimit", map[string]interface{}{"limit": event})
+		}
+	}
+}
+
+// watchTerminalLimits closes a terminal past limits.processes.terminalIdleMinutes or terminalMaxMinutes,
+// sending a warning frame warnSeconds before; activity holds the last input or output in Unix nanoseconds
+func watchTerminalLimits(conn terminalWriter, cmd *exec.Cmd, openedAt time.Time, activity *atomic.Int64) (stop func()) {
+	limits := getServerConfig().Limits.Processes
+	if limits.TerminalIdleMinutes == 0 && limits.TerminalMaxMinutes == 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(processLimitTick)
+		defer ticker.Stop()
+		var warned time.Time
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				deadline, reason := limitDeadline(limits.TerminalIdleMinutes, limits.TerminalMaxMinutes, openedAt, time.Unix(0, activity.Load()))
+				why := limitReason(reason, limits.TerminalIdleMinutes, limits.TerminalMaxMinutes)
+				if !now.Before(deadline) {
+					log.Printf("[Terminal] Closing terminal (pid %d): %s", cmd.Process.Pid, why)
+					conn.write(termFrameError, []byte("Terminal closed: "+why))
+					killProcessGroup(cmd)
+					return
+				}
+				warn := time.Duration(limits.WarnSeconds) * time.Second
+				if warn > 0 && now.Add(warn).After(deadline) && !warned.Equal(deadline) {
+					warned = deadline
+					conn.write(termFrameWarning, []byte(fmt.Sprintf("This terminal closes in %d seconds: %s",
+						int(deadline.Sub(now).Round(time.Second).Seconds()), why)))
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
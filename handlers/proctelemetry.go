@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"bytes"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockTicks is USER_HZ, the unit of the CPU times in /proc/<pid>/stat; 100 on every mainstream Linux
+	clockTicks = 100
+	// resourceSampleInterval is the least time between two samples of a process; earlier requests reuse the last one
+	resourceSampleInterval = time.Second
+)
+
+// ProcessResources is the CPU and memory use of a claude process and everything it spawned
+type ProcessResources struct {
+	CPUPercent float64 `json:"cpuPercent"` // since the previous sample; 100 is one full core
+	CPUSeconds float64 `json:"cpuSeconds"` // user plus system time over the whole run
+	RSSBytes   int64   `json:"rssBytes"`
+	Processes  int     `json:"processes"` // the process and its live descendants
+	SampledAt  int64   `json:"sampledAt"` // unix seconds
+}
+
+// resourceSample is the last reading of a process, kept to turn CPU time into a rate
+type resourceSample struct {
+	at        time.Time
+	ticks     uint64
+	resources ProcessResources
+}
+
+var (
+	resourceSamples = make(map[int]*resourceSample) // by process ID
+	resourceMu      sync.Mutex
+)
+
+// procStat is what telemetry needs from /proc/<pid>/stat
+type procStat struct {
+	ppid  int
+	ticks uint64 // utime + stime + cutime + cstime
+	rss   int64  // pages
+}
+
+// readProcStat parses /proc/<pid>/stat
+// The command name can hold spaces and parentheses, so fields are counted from the last ')'
+func readProcStat(pid string) (procStat, bool) {
+	data, err := os.ReadFile("/proc/" + pid + "/stat")
+	if err != nil {
+		return procStat{}, false
+	}
+	end := bytes.LastIndexByte(data, ')')
+	if end < 0 {
+		return procStat{}, false
+	}
+	// fields[0] is field 3 (state): ppid is field 4, utime..cstime 14-17, rss 24
+	fields := strings.Fields(string(data[end+1:]))
+	if len(fields) < 22 {
+		return procStat{}, false
+	}
+	var stat procStat
+	stat.ppid, _ = strconv.Atoi(fields[1])
+	for _, f := range fields[11:15] {
+		ticks, _ := strconv.ParseUint(f, 10, 64)
+		stat.ticks += ticks
+	}
+	stat.rss, _ = strconv.ParseInt(fields[21], 10, 64)
+	return stat, true
+}
+
+// readProcessTree reads every process on the host; nil where there is no /proc
+func readProcessTree() map[int]procStat {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+	tree := make(map[int]procStat, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() {
+			continue
+		}
+		if stat, ok := readProcStat(entry.Name()); ok {
+			tree[pid] = stat
+		}
+	}
+	return tree
+}
+
+// treeUsage sums the CPU ticks and resident pages of pid and its descendants
+// script runs claude in a session of its own, so the tree follows parents rather than the process group
+func treeUsage(tree map[int]procStat, children map[int][]int, pid int) (ticks uint64, rss int64, count int, ok bool) {
+	if _, ok := tree[pid]; !ok {
+		return 0, 0, 0, false
+	}
+	queue := []int{pid}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		stat := tree[p]
+		ticks += stat.ticks
+		rss += stat.rss
+		count++
+		queue = append(queue, children[p]...)
+	}
+	return ticks, rss, count, true
+}
+
+// sampleProcessResources returns the latest CPU and memory use of the running processes by process ID
+// Remote, container, and API runs have no local process and are left out
+func sampleProcessResources() map[int]ProcessResources {
+	type tracked struct {
+		pid   int
+		start int64
+	}
+	processLock.RLock()
+	pids := make(map[int]tracked)
+	for id, info := range activeProcesses {
+		if info.pid > 0 {
+			pids[id] = tracked{info.pid, info.StartTime}
+		}
+	}
+	processLock.RUnlock()
+
+	resourceMu.Lock()
+	defer resourceMu.Unlock()
+	for id := range resourceSamples {
+		if _, ok := pids[id]; !ok {
+			delete(resourceSamples, id)
+		}
+	}
+
+	now := time.Now()
+	var tree map[int]procStat
+	var children map[int][]int
+	for id, p := range pids {
+		prev := resourceSamples[id]
+		if prev != nil && now.Sub(prev.at) < resourceSampleInterval {
+			continue
+		}
+		if tree == nil {
+			if tree = readProcessTree(); tree == nil {
+				break
+			}
+			children = make(map[int][]int)
+			for pid, stat := range tree {
+				children[stat.ppid] = append(children[stat.ppid], pid)
+			}
+		}
+		ticks, rss, count, ok := treeUsage(tree, children, p.pid)
+		if !ok {
+			continue // exited; unregistered shortly
+		}
+
+		sample := &resourceSample{at: now, ticks: ticks}
+		sample.resources = ProcessResources{
+			CPUSeconds: float64(ticks) / clockTicks,
+			RSSBytes:   rss * int64(os.Getpagesize()),
+			Processes:  count,
+			SampledAt:  now.Unix(),
+		}
+		// The first sample averages over the run; later ones cover the time since the previous
+		elapsed, delta := now.Sub(time.Unix(p.start, 0)).Seconds(), sample.resources.CPUSeconds
+		if prev != nil {
+			elapsed = now.Sub(prev.at).Seconds()
+			delta = 0
+			if ticks > prev.ticks { // exited children that nobody reaped take their time with them
+				delta = float64(ticks-prev.ticks) / clockTicks
+			}
+		}
+		if elapsed > 0 {
+			sample.resources.CPUPercent = float64(int(delta/elapsed*1000+0.5)) / 10
+		}
+		resourceSamples[id] = sample
+	}
+
+	result := make(map[int]ProcessResources, len(resourceSamples))
+	for id, sample := range resourceSamples {
+		result[id] = sample.resources
+	}
+	return result
+}
+
+// chatProcessPID is the local pid of a chat turn, or 0 when it runs elsewhere
+func chatProcessPID(proc ChatProcess) int {
+	if p, ok := proc.(interface{ Pid() int }); ok {
+		return p.Pid()
+	}
+	return 0
+}
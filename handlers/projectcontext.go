@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// contextGitTimeout bounds the git status run behind GET /api/projects/:id/context
+const contextGitTimeout = 5 * time.Second
+
+// contextFileTools are the tool calls whose path arguments count as touching a file
+var contextFileTools = map[string]string{
+	"Read":         "read",
+	"Edit":         "edited",
+	"MultiEdit":    "edited",
+	"Write":        "edited",
+	"NotebookEdit": "edited",
+}
+
+// ContextSuggestion is a file worth mentioning in the next prompt
+type ContextSuggestion struct {
+	Path      string   `json:"path"`
+	RelPath   string   `json:"relPath"`             // relative to the project, or the absolute path outside it
+	Reasons   []string `json:"reasons"`             // read, edited, opened, and/or the git change: modified, added, deleted, renamed, untracked
+	GitStatus string   `json:"gitStatus,omitempty"` // porcelain XY code
+	Touches   int      `json:"touches"`             // tool calls on the file in the scanned sessions
+	LastUsed  string   `json:"lastUsed,omitempty"`  // timestamp of the latest such tool call, or opening in the editor
+	Modified  int64    `json:"modified"`            // file mtime, unix seconds
+}
+
+// ProjectContextResponse is the response of GET /api/projects/:id/context
+type ProjectContextResponse struct {
+	ProjectID       string              `json:"projectId"`
+	ProjectPath     string              `json:"projectPath"`
+	GitBranch       string              `json:"gitBranch,omitempty"`
+	SessionsScanned int                 `json:"sessionsScanned"`
+	Files           []ContextSuggestion `json:"files"`
+}
+
+// contextToolPaths collects the files touched by tool calls in a transcript
+// Returns the session's working directory as recorded in the transcript too
+func contextToolPaths(path string, touch func(file, reason, timestamp string)) (cwd string) {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg Message
+			if json.Unmarshal(line, &msg) == nil {
+				if cwd == "" {
+					cwd = msg.CWD
+				}
+				if blocks, ok := msg.Message["content"].([]interface{}); ok && msg.Type == "assistant" {
+					for _, item := range blocks {
+						block, ok := item.(map[string]interface{})
+						if !ok || block["type"] != "tool_use" {
+							continue
+						}
+						name, _ := block["name"].(string)
+						reason, ok := contextFileTools[name]
+						if !ok {
+							continue
+						}
+						input, _ := block["input"].(map[string]interface{})
+						for _, key := range []string{"file_path", "notebook_path"} {
+							if p, ok := input[key].(string); ok && p != "" {
+								touch(p, reason, msg.Timestamp)
+							}
+						}
+					}
+				}
+			}
+		}
+		if err != nil {
+			return cwd
+		}
+	}
+}
+
+// contextGitChanges runs git status in dir and returns changed paths (absolute) with their XY codes
+func contextGitChanges(ctx context.Context, dir string) (branch string, changes map[string]string) {
+	out, err := exec.CommandContext(ctx, "git", "-C", dir, "status", "--porcelain=v1", "--branch").Output()
+	if err != nil {
+		return "", nil
+	}
+	changes = make(map[string]string)
+	for _, line := range strings.Split(string(out), "\n") {
+		if strings.HasPrefix(line, "## ") {
+			branch, _, _ = strings.Cut(strings.TrimPrefix(line, "## "), "...")
+			continue
+		}
+		if len(line) < 4 {
+			continue
+		}
+		code, name := line[:2], line[3:]
+		if _, to, ok := strings.Cut(name, " -> "); ok {
+			name = to
+		}
+		if unquoted, err := strconv.Unquote(name); err == nil {
+			name = unquoted
+		}
+		changes[filepath.Join(dir, name)] = code
+	}
+	return branch, changes
+}
+
+// gitChangeReason names a porcelain XY code
+func gitChangeReason(code string) string {
+	switch {
+	case code == "??":
+		return "untracked"
+	case strings.Contains(code, "D"):
+		return "deleted"
+	case strings.Contains(code, "R"):
+		return "renamed"
+	case strings.Contains(code, "A"):
+		return "added"
+	}
+	return "modified"
+}
+
+// GetProjectContext handles GET /api/projects/:id/context
+// Suggests files to mention in the next prompt: those the project's recent sessions read or edited,
+// those the user recently opened, and those with uncommitted git changes, most recent first.
+// :id is the project's directory name under ~/.claude/projects
+// Query parameters:
+//   - sessions: how many recent sessions to inspect (default 5, max 50)
+//   - limit: maximum files to return (default 30, max 200)
+func GetProjectContext(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" || projectID != filepath.Base(projectID) || strings.HasPrefix(projectID, ".") {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid project id")
+		return
+	}
+	sessionLimit, err := strconv.Atoi(c.DefaultQuery("sessions", "5"))
+	if err != nil || sessionLimit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid sessions parameter")
+		return
+	}
+	if sessionLimit > 50 {
+		sessionLimit = 50
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "30"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+	if limit > 200 {
+		limit = 200
+	}
+
+	projectDir := filepath.Join(getProjectsDir(), projectID)
+	entries, err := os.ReadDir(projectDir)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Project not found")
+		return
+	}
+
+	// Most recently written sessions the user can see
+	user := currentUser(c)
+	type transcript struct {
+		path    string
+		modTime time.Time
+	}
+	var transcripts []transcript
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".jsonl") || !userCanAccessSession(user, strings.TrimSuffix(name, ".jsonl")) {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			transcripts = append(transcripts, transcript{path: filepath.Join(projectDir, name), modTime: info.ModTime()})
+		}
+	}
+	sort.Slice(transcripts, func(i, j int) bool { return transcripts[i].modTime.After(transcripts[j].modTime) })
+	if len(transcripts) > sessionLimit {
+		transcripts = transcripts[:sessionLimit]
+	}
+
+	suggestions := make(map[string]*ContextSuggestion)
+	suggestion := func(p string) *ContextSuggestion {
+		p = filepath.Clean(p)
+		s, ok := suggestions[p]
+		if !ok {
+			s = &ContextSuggestion{Path: p}
+			suggestions[p] = s
+		}
+		return s
+	}
+	addReason := func(s *ContextSuggestion, reason string) {
+		for _, r := range s.Reasons {
+			if r == reason {
+				return
+			}
+		}
+		s.Reasons = append(s.Reasons, reason)
+	}
+
+	projectPath := ""
+	for _, t := range transcripts {
+		cwd := contextToolPaths(t.path, func(file, reason, timestamp string) {
+			s := suggestion(file)
+			s.Touches++
+			addReason(s, reason)
+			if timestamp > s.LastUsed {
+				s.LastUsed = timestamp
+			}
+		})
+		if projectPath == "" {
+			projectPath = cwd
+		}
+	}
+	openedIn, opened := recentFiles(user, projectID, limit)
+	for _, f := range opened {
+		s := suggestion(f.Path)
+		addReason(s, "opened")
+		if f.OpenedAt > s.LastUsed {
+			s.LastUsed = f.OpenedAt
+		}
+	}
+	if projectPath == "" {
+		projectPath = openedIn
+	}
+	if projectPath == "" {
+		projectPath = projectPathFromDir(projectID)
+	}
+	if !userCanAccessPath(user, projectPath) {
+		denyPath(c, projectPath)
+		return
+	}
+
+	resp := ProjectContextResponse{
+		ProjectID:       projectID,
+		ProjectPath:     projectPath,
+		SessionsScanned: len(transcripts),
+		Files:           []ContextSuggestion{},
+	}
+	if _, _, remote := parseRemotePath(projectPath); !remote {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), contextGitTimeout)
+		branch, changes := contextGitChanges(ctx, projectPath)
+		cancel()
+		resp.GitBranch = branch
+		for p, code := range changes {
+			s := suggestion(p)
+			s.GitStatus = code
+			addReason(s, gitChangeReason(code))
+		}
+	}
+
+	for p, s := range suggestions {
+		if !userCanAccessPath(user, p) {
+			continue
+		}
+		info, err := os.Stat(p)
+		if err != nil && s.GitStatus == "" {
+			continue // gone, and not a deletion worth mentioning
+		}
+		if err == nil {
+			if info.IsDir() {
+				continue
+			}
+			s.Modified = info.ModTime().Unix()
+		}
+		s.RelPath = p
+		if rel, err := filepath.Rel(projectPath, p); err == nil && !strings.HasPrefix(rel, "..") {
+			s.RelPath = rel
+		}
+		resp.Files = append(resp.Files, *s)
+	}
+
+	// Most recent first, by the later of the last tool call and the file's mtime
+	recency := func(s ContextSuggestion) int64 {
+		latest := s.Modified
+		if t, err := time.Parse(time.RFC3339, s.LastUsed); err == nil && t.Unix() > latest {
+			latest = t.Unix()
+		}
+		return latest
+	}
+	sort.Slice(resp.Files, func(i, j int) bool {
+		ri, rj := recency(resp.Files[i]), recency(resp.Files[j])
+		if ri != rj {
+			return ri > rj
+		}
+		return resp.Files[i].Path < resp.Files[j].Path
+	})
+	if len(resp.Files) > limit {
+		resp.Files = resp.Files[:limit]
+	}
+	c.JSON(http.StatusOK, resp)
+}
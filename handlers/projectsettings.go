@@ -0,0 +1,255 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// envNamePattern matches the environment variable names project settings may set
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ProjectSettings are the chat defaults of a project
+// Chats in the project, or a directory under it, use them for whatever the request leaves empty
+type ProjectSettings struct {
+	Model          string            `json:"model,omitempty"`
+	PermissionMode string            `json:"permissionMode,omitempty"`
+	AllowedTools   []string          `json:"allowedTools,omitempty"`
+	Env            map[string]string `json:"env,omitempty"` // set for local runs only
+	UpdatedAt      *time.Time        `json:"updatedAt,omitempty"`
+	UpdatedBy      string            `json:"updatedBy,omitempty"`
+}
+
+// ProjectSettingsResponse is the response of GET and PUT /api/projects/:id/settings
+type ProjectSettingsResponse struct {
+	ProjectID   string          `json:"projectId"`
+	ProjectPath string          `json:"projectPath"`
+	Settings    ProjectSettings `json:"settings"`
+	// Server defaults used where neither the request nor the project sets a value
+	DefaultModel          string `json:"defaultModel"`
+	DefaultPermissionMode string `json:"defaultPermissionMode"`
+}
+
+var (
+	projectSettingsMu     sync.Mutex
+	projectSettingsStore  map[string]ProjectSettings // project path -> settings
+	projectSettingsLoaded bool
+)
+
+func projectSettingsPath() string {
+	return serverDataPath("project-settings.json")
+}
+
+// loadProjectSettings reads the store once; caller must hold projectSettingsMu
+func loadProjectSettings() error {
+	if projectSettingsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(projectSettingsPath(), &projectSettingsStore); err != nil {
+		return err
+	}
+	if projectSettingsStore == nil {
+		projectSettingsStore = make(map[string]ProjectSettings)
+	}
+	projectSettingsLoaded = true
+	return nil
+}
+
+// settingsForWorkDir returns the settings of the innermost project containing workDir
+func settingsForWorkDir(workDir string) (ProjectSettings, bool) {
+	projectSettingsMu.Lock()
+	defer projectSettingsMu.Unlock()
+	if err := loadProjectSettings(); err != nil {
+		log.Printf("[ProjectSettings] Failed to load project settings: %v", err)
+		return ProjectSettings{}, false
+	}
+	best := ""
+	for projectPath := range projectSettingsStore {
+		if (workDir == projectPath || strings.HasPrefix(workDir, strings.TrimSuffix(projectPath, "/")+"/")) && len(projectPath) > len(best) {
+			best = projectPath
+		}
+	}
+	if best == "" {
+		return ProjectSettings{}, false
+	}
+	return projectSettingsStore[best], true
+}
+
+// inheritProjectSettings fills the model, permission mode, and allowed tools a chat turn
+// leaves empty from its project's settings, and adds the project's environment
+func inheritProjectSettings(turn *ChatTurn) error {
+	if turn.PermissionMode != "" && !validPermissionModes[turn.PermissionMode] {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("invalid permissionMode: %s", turn.PermissionMode))
+	}
+	settings, ok := settingsForWorkDir(turn.WorkDir)
+	if !ok {
+		return nil
+	}
+	if turn.Model == "" {
+		turn.Model = settings.Model
+	}
+	if turn.PermissionMode == "" {
+		turn.PermissionMode = settings.PermissionMode
+	}
+	if len(turn.AllowedTools) == 0 {
+		turn.AllowedTools = settings.AllowedTools
+	}
+	names := make([]string, 0, len(settings.Env))
+	for name := range settings.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		turn.Env = append(turn.Env, name+"="+settings.Env[name])
+	}
+	return nil
+}
+
+// validateProjectSettings checks settings before they are stored
+func validateProjectSettings(s ProjectSettings) error {
+	if s.PermissionMode != "" && !validPermissionModes[s.PermissionMode] {
+		return fmt.Errorf("invalid permissionMode: %s", s.PermissionMode)
+	}
+	if strings.HasPrefix(s.Model, "-") || strings.ContainsAny(s.Model, " \t\n") {
+		return fmt.Errorf("invalid model: %s", s.Model)
+	}
+	for _, tool := range s.AllowedTools {
+		if strings.TrimSpace(tool) == "" || strings.Contains(tool, ",") {
+			return fmt.Errorf("invalid allowed tool: %q", tool)
+		}
+	}
+	for name := range s.Env {
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("invalid environment variable name: %q", name)
+		}
+	}
+	return nil
+}
+
+// projectSettingsTarget resolves :id (a directory name under ~/.claude/projects) to a project path
+// Directory names lose the difference between / and -, so a path with dashes is found
+// among the stored projects or passed as ?path=
+func projectSettingsTarget(c *gin.Context) (string, string, bool) {
+	projectID := c.Param("id")
+	if projectID == "" || projectID != filepath.Base(projectID) || strings.HasPrefix(projectID, ".") {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid project id")
+		return "", "", false
+	}
+
+	projectPath := ""
+	if p := c.Query("path"); p != "" {
+		p = filepath.Clean(p)
+		if !filepath.IsAbs(p) || hashProjectPath(p) != projectID {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "path does not belong to this project id")
+			return "", "", false
+		}
+		projectPath = p
+	}
+	if projectPath == "" {
+		projectSettingsMu.Lock()
+		if err := loadProjectSettings(); err == nil {
+			for p := range projectSettingsStore {
+				if hashProjectPath(p) == projectID {
+					projectPath = p
+					break
+				}
+			}
+		}
+		projectSettingsMu.Unlock()
+	}
+	if projectPath == "" {
+		projectPath = projectPathFromDir(projectID)
+	}
+
+	if !userCanAccessPath(currentUser(c), projectPath) {
+		denyPath(c, projectPath)
+		return "", "", false
+	}
+	return projectID, projectPath, true
+}
+
+func projectSettingsResponse(projectID, projectPath string, settings ProjectSettings) ProjectSettingsResponse {
+	cfg := getServerConfig().Claude
+	return ProjectSettingsResponse{
+		ProjectID:             projectID,
+		ProjectPath:           projectPath,
+		Settings:              settings,
+		DefaultModel:          cfg.DefaultModel,
+		DefaultPermissionMode: cfg.PermissionMode,
+	}
+}
+
+// GetProjectSettings handles GET /api/projects/:id/settings
+// Query parameters:
+//   - path: the project path, when its directory name is ambiguous
+func GetProjectSettings(c *gin.Context) {
+	projectID, projectPath, ok := projectSettingsTarget(c)
+	if !ok {
+		return
+	}
+	projectSettingsMu.Lock()
+	err := loadProjectSettings()
+	settings := projectSettingsStore[projectPath]
+	projectSettingsMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load project settings", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, projectSettingsResponse(projectID, projectPath, settings))
+}
+
+// UpdateProjectSettings handles PUT /api/projects/:id/settings
+// Replaces the project's settings; an empty body clears them
+// Query parameters:
+//   - path: the project path, when its directory name is ambiguous
+func UpdateProjectSettings(c *gin.Context) {
+	projectID, projectPath, ok := projectSettingsTarget(c)
+	if !ok {
+		return
+	}
+	var settings ProjectSettings
+	if err := c.ShouldBindJSON(&settings); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	settings.Model = strings.TrimSpace(settings.Model)
+	if err := validateProjectSettings(settings); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	empty := settings.Model == "" && settings.PermissionMode == "" && len(settings.AllowedTools) == 0 && len(settings.Env) == 0
+	if empty {
+		settings = ProjectSettings{}
+	} else {
+		now := time.Now()
+		settings.UpdatedAt = &now
+		settings.UpdatedBy = ownerID(currentUser(c))
+	}
+
+	projectSettingsMu.Lock()
+	err := loadProjectSettings()
+	if err == nil {
+		if empty {
+			delete(projectSettingsStore, projectPath)
+		} else {
+			projectSettingsStore[projectPath] = settings
+		}
+		// env may hold credentials
+		err = writeJSONFileAtomicMode(projectSettingsPath(), projectSettingsStore, 0600)
+	}
+	projectSettingsMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save project settings", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, projectSettingsResponse(projectID, projectPath, settings))
+}
@@ -0,0 +1,531 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Web Push (RFC 8030) with VAPID authentication (RFC 8292) and
+// aes128gcm payload encryption (RFC 8291)
+
+// PushSubscription is a browser PushSubscription as serialized by toJSON()
+type PushSubscription struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	UserAgent string    `json:"userAgent,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// PushPreferences controls which events notify a user
+type PushPreferences struct {
+	OnComplete   bool `json:"onComplete"`
+	OnError      bool `json:"onError"`
+	OnPermission bool `json:"onPermission"` // also questions claude asks with AskUserQuestion
+	// MinDurationSeconds skips completion notifications for quick chats
+	MinDurationSeconds int `json:"minDurationSeconds"`
+}
+
+// PushNotification is the payload delivered to the service worker
+type PushNotification struct {
+	Event     string `json:"event"` // complete, error, permission, question, budget, test
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	SessionID string `json:"sessionId,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// pushUserData is everything stored for one user (keyed by user ID, "" when auth is off)
+type pushUserData struct {
+	Preferences   PushPreferences    `json:"preferences"`
+	Subscriptions []PushSubscription `json:"subscriptions"`
+}
+
+type vapidKeyFile struct {
+	PrivateKey string `json:"privateKey"` // base64url scalar
+	PublicKey  string `json:"publicKey"`  // base64url uncompressed point
+}
+
+// PushManager stores subscriptions and the server's VAPID key pair
+type PushManager struct {
+	mu     sync.Mutex
+	loaded bool
+	users  map[string]*pushUserData
+	key    *ecdsa.PrivateKey
+	public string
+}
+
+var pushManager = &PushManager{}
+
+func defaultPushPreferences() PushPreferences {
+	return PushPreferences{OnComplete: true, OnError: true, OnPermission: true, MinDurationSeconds: 30}
+}
+
+func pushStorePath() string {
+	return serverDataPath("push.json")
+}
+
+// load reads subscriptions and the VAPID key, generating a key on first use
+// Caller must hold pm.mu
+func (pm *PushManager) load() error {
+	if pm.loaded {
+		return nil
+	}
+	users := make(map[string]*pushUserData)
+	if err := loadJSONFile(pushStorePath(), &users); err != nil {
+		return err
+	}
+
+	var keys vapidKeyFile
+	keyPath := serverDataPath("vapid.json")
+	if err := loadJSONFile(keyPath, &keys); err != nil {
+		return err
+	}
+	if keys.PrivateKey == "" {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return err
+		}
+		pub, err := key.PublicKey.ECDH()
+		if err != nil {
+			return err
+		}
+		keys.PrivateKey = base64.RawURLEncoding.EncodeToString(key.D.FillBytes(make([]byte, 32)))
+		keys.PublicKey = base64.RawURLEncoding.EncodeToString(pub.Bytes())
+		if err := writeJSONFileAtomicMode(keyPath, keys, 0600); err != nil {
+			return err
+		}
+		log.Printf("[Push] Generated VAPID key pair")
+	}
+	d, err := base64.RawURLEncoding.DecodeString(keys.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("invalid VAPID key in %s: %w", keyPath, err)
+	}
+	key := &ecdsa.PrivateKey{D: new(big.Int).SetBytes(d)}
+	key.PublicKey.Curve = elliptic.P256()
+	key.PublicKey.X, key.PublicKey.Y = key.PublicKey.Curve.ScalarBaseMult(d)
+
+	pm.users = users
+	pm.key = key
+	pm.public = keys.PublicKey
+	pm.loaded = true
+	return nil
+}
+
+func (pm *PushManager) save() error {
+	return writeJSONFileAtomicMode(pushStorePath(), pm.users, 0600)
+}
+
+// user returns the stored data for owner, creating defaults
+// Caller must hold pm.mu
+func (pm *PushManager) user(owner string) *pushUserData {
+	data, ok := pm.users[owner]
+	if !ok {
+		data = &pushUserData{Preferences: defaultPushPreferences()}
+		pm.users[owner] = data
+	}
+	return data
+}
+
+// vapidAuthorization builds the VAPID Authorization header for an endpoint
+func (pm *PushManager) vapidAuthorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	subject := getServerConfig().Push.Subject
+	if subject == "" {
+		subject = "mailto:greyzone@localhost"
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`))
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": subject,
+	})
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, s, err := ecdsa.Sign(rand.Reader, pm.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	jwt := signingInput + "." + base64.RawURLEncoding.EncodeToString(sig)
+	return "vapid t=" + jwt + ", k=" + pm.public, nil
+}
+
+// decodeBase64 accepts both padded and unpadded URL-safe base64
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// encryptPushPayload encrypts payload for a subscription (RFC 8291, single record)
+func encryptPushPayload(sub PushSubscription, payload []byte) ([]byte, error) {
+	uaPublicBytes, err := decodeBase64(sub.Keys.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64(sub.Keys.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %w", err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(uaPublicBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asPrivate.PublicKey().Bytes()
+	sharedSecret, err := asPrivate.ECDH(uaPublic)
+	if err != nil {
+		return nil, err
+	}
+
+	expand := func(prk []byte, info []byte, n int) []byte {
+		out := make([]byte, n)
+		io.ReadFull(hkdf.Expand(sha256.New, prk, info), out)
+		return out
+	}
+	keyInfo := append(append([]byte("WebPush: info\x00"), uaPublicBytes...), asPublic...)
+	ikm := expand(hkdf.Extract(sha256.New, sharedSecret, authSecret), keyInfo, 32)
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	prk := hkdf.Extract(sha256.New, ikm, salt)
+	cek := expand(prk, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := expand(prk, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (only) record
+	ciphertext := gcm.Seal(nil, nonce, append(payload, 0x02), nil)
+
+	var body bytes.Buffer
+	body.Write(salt)
+	binary.Write(&body, binary.BigEndian, uint32(4096))
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	body.Write(ciphertext)
+	return body.Bytes(), nil
+}
+
+// send delivers one notification; gone reports an expired subscription
+func (pm *PushManager) send(sub PushSubscription, payload []byte, urgency string) (gone bool, err error) {
+	body, err := encryptPushPayload(sub, payload)
+	if err != nil {
+		return false, err
+	}
+	auth, err := pm.vapidAuthorization(sub.Endpoint)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Urgency", urgency)
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return true, nil
+	case resp.StatusCode >= 300:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return false, fmt.Errorf("push service returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return false, nil
+}
+
+// notify sends n to every subscription of owner; expired subscriptions are removed
+func (pm *PushManager) notify(owner string, n PushNotification) int {
+	pm.mu.Lock()
+	if err := pm.load(); err != nil {
+		pm.mu.Unlock()
+		log.Printf("[Push] %v", err)
+		return 0
+	}
+	subs := append([]PushSubscription(nil), pm.user(owner).Subscriptions...)
+	pm.mu.Unlock()
+	if len(subs) == 0 {
+		return 0
+	}
+
+	payload, _ := json.Marshal(n)
+	urgency := "normal"
+	if n.Event == "permission" || n.Event == "question" || n.Event == "error" {
+		urgency = "high"
+	}
+
+	sent := 0
+	var expired []string
+	for _, sub := range subs {
+		gone, err := pm.send(sub, payload, urgency)
+		switch {
+		case gone:
+			expired = append(expired, sub.Endpoint)
+		case err != nil:
+			log.Printf("[Push] Failed to notify %s: %v", sub.Endpoint, err)
+		default:
+			sent++
+		}
+	}
+
+	if len(expired) > 0 {
+		pm.mu.Lock()
+		for _, endpoint := range expired {
+			pm.removeSubscription(owner, endpoint)
+		}
+		pm.save()
+		pm.mu.Unlock()
+	}
+	return sent
+}
+
+// removeSubscription drops endpoint for owner; caller must hold pm.mu
+func (pm *PushManager) removeSubscription(owner string, endpoint string) bool {
+	data := pm.user(owner)
+	for i, sub := range data.Subscriptions {
+		if sub.Endpoint == endpoint {
+			data.Subscriptions = append(data.Subscriptions[:i], data.Subscriptions[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// sessionURL links a notification back to the session in the UI
+func sessionURL(sessionID string) string {
+	base := getServerConfig().BasePath + "/"
+	if sessionID == "" {
+		return base
+	}
+	return base + "?session=" + url.QueryEscape(sessionID)
+}
+
+// notifyChatFinished sends completion/error notifications according to the owner's preferences
+func notifyChatFinished(owner string, sessionID string, workDir string, outcome string, duration time.Duration) {
+	if outcome == "interrupted" {
+		return
+	}
+	pushManager.mu.Lock()
+	if err := pushManager.load(); err != nil {
+		pushManager.mu.Unlock()
+		return
+	}
+	prefs := pushManager.user(owner).Preferences
+	pushManager.mu.Unlock()
+
+	project := filepath.Base(workDir)
+	locale := serverLocale()
+	n := PushNotification{SessionID: sessionID, URL: sessionURL(sessionID)}
+	switch outcome {
+	case "success":
+		if !prefs.OnComplete || duration < time.Duration(prefs.MinDurationSeconds)*time.Second {
+			return
+		}
+		n.Event = "complete"
+		n.Title = translate(locale, "Claude finished")
+		n.Body = fmt.Sprintf("%s · %s", project, duration.Round(time.Second))
+	default:
+		if !prefs.OnError {
+			return
+		}
+		n.Event = "error"
+		n.Title = translate(locale, "Claude stopped with an error")
+		n.Body = project
+	}
+	go pushManager.notify(owner, n)
+}
+
+var permissionRequestRegex = regexp.MustCompile(`requested permissions to (?:use|write to|read) ([A-Za-z0-9_./:-]+)`)
+
+// notifyInputRequest notifies that claude is blocked on a permission or a question
+// Returns true once a notification was triggered so callers only notify once per run
+func notifyInputRequest(owner string, sessionID string, req *InputRequest) bool {
+	pushManager.mu.Lock()
+	if err := pushManager.load(); err != nil {
+		pushManager.mu.Unlock()
+		return false
+	}
+	prefs := pushManager.user(owner).Preferences
+	pushManager.mu.Unlock()
+	if !prefs.OnPermission {
+		return true
+	}
+
+	locale := serverLocale()
+	n := PushNotification{SessionID: sessionID, URL: sessionURL(sessionID)}
+	if req.Kind == "question" {
+		n.Event = "question"
+		n.Title = translate(locale, "Claude has a question")
+		n.Body = req.Detail
+	} else {
+		n.Event = "permission"
+		n.Title = translate(locale, "Claude is waiting for permission")
+		n.Body = translate(locale, "A tool needs your approval")
+		if req.Tool != "" {
+			n.Body = translate(locale, "Permission needed for") + " " + req.Tool
+		}
+	}
+	go pushManager.notify(owner, n)
+	return true
+}
+
+// GetPushConfig handles GET /api/push
+// Returns the VAPID public key, the user's preferences, and subscription count
+func GetPushConfig(c *gin.Context) {
+	owner := ownerID(currentUser(c))
+	pushManager.mu.Lock()
+	defer pushManager.mu.Unlock()
+	if err := pushManager.load(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load push settings", err.Error())
+		return
+	}
+	data := pushManager.user(owner)
+	c.JSON(http.StatusOK, gin.H{
+		"publicKey":     pushManager.public,
+		"preferences":   data.Preferences,
+		"subscriptions": len(data.Subscriptions),
+	})
+}
+
+// SubscribePush handles POST /api/push/subscriptions
+func SubscribePush(c *gin.Context) {
+	var sub PushSubscription
+	if err := c.ShouldBindJSON(&sub); err != nil || sub.Endpoint == "" || sub.Keys.P256dh == "" || sub.Keys.Auth == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "endpoint and keys are required")
+		return
+	}
+	if u, err := url.Parse(sub.Endpoint); err != nil || u.Scheme != "https" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "endpoint must be an https URL")
+		return
+	}
+	sub.UserAgent = c.Request.UserAgent()
+	sub.CreatedAt = time.Now()
+
+	owner := ownerID(currentUser(c))
+	pushManager.mu.Lock()
+	defer pushManager.mu.Unlock()
+	if err := pushManager.load(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load push settings", err.Error())
+		return
+	}
+	pushManager.removeSubscription(owner, sub.Endpoint)
+	data := pushManager.user(owner)
+	data.Subscriptions = append(data.Subscriptions, sub)
+	if err := pushManager.save(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save subscription", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// UnsubscribePush handles DELETE /api/push/subscriptions
+// Query parameters:
+//   - endpoint: the subscription endpoint to remove
+func UnsubscribePush(c *gin.Context) {
+	endpoint := c.Query("endpoint")
+	if endpoint == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "endpoint is required")
+		return
+	}
+	owner := ownerID(currentUser(c))
+	pushManager.mu.Lock()
+	defer pushManager.mu.Unlock()
+	if err := pushManager.load(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load push settings", err.Error())
+		return
+	}
+	if !pushManager.removeSubscription(owner, endpoint) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Subscription not found")
+		return
+	}
+	if err := pushManager.save(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save subscriptions", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// UpdatePushPreferences handles PUT /api/push/preferences
+func UpdatePushPreferences(c *gin.Context) {
+	var prefs PushPreferences
+	if err := c.ShouldBindJSON(&prefs); err != nil || prefs.MinDurationSeconds < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	owner := ownerID(currentUser(c))
+	pushManager.mu.Lock()
+	defer pushManager.mu.Unlock()
+	if err := pushManager.load(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load push settings", err.Error())
+		return
+	}
+	pushManager.user(owner).Preferences = prefs
+	if err := pushManager.save(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save preferences", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// TestPush handles POST /api/push/test
+func TestPush(c *gin.Context) {
+	sent := pushManager.notify(ownerID(currentUser(c)), PushNotification{
+		Event: "test",
+		Title: localize(c, "Greyzone notifications work"),
+		Body:  localize(c, "You'll be notified when long-running chats finish."),
+		URL:   sessionURL(""),
+	})
+	if sent == 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "No subscription accepted the notification")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "sent": sent})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recentFilesPerProject bounds the files remembered per project, across users
+const recentFilesPerProject = 200
+
+// RecentFile is a file opened with POST /api/file/read
+type RecentFile struct {
+	Path     string `json:"path"`
+	RelPath  string `json:"relPath"` // relative to the project
+	OpenedAt string `json:"openedAt"`
+	Opens    int    `json:"opens"`
+	Owner    string `json:"owner,omitempty"`
+}
+
+// RecentFilesResponse is the response of GET /api/projects/:id/recent-files
+type RecentFilesResponse struct {
+	ProjectID   string       `json:"projectId"`
+	ProjectPath string       `json:"projectPath"`
+	Files       []RecentFile `json:"files"`
+}
+
+// recentProject is a project's recently opened files, most recent first
+type recentProject struct {
+	Path  string       `json:"path"`
+	Files []RecentFile `json:"files"`
+}
+
+var (
+	recentFilesMu     sync.Mutex
+	recentFilesStore  map[string]*recentProject // project ID -> files
+	recentFilesLoaded bool
+)
+
+func recentFilesPath() string {
+	return serverDataPath("recent-files.json")
+}
+
+// loadRecentFiles reads the store once; caller must hold recentFilesMu
+func loadRecentFiles() error {
+	if recentFilesLoaded {
+		return nil
+	}
+	if err := loadJSONFile(recentFilesPath(), &recentFilesStore); err != nil {
+		return err
+	}
+	if recentFilesStore == nil {
+		recentFilesStore = make(map[string]*recentProject)
+	}
+	recentFilesLoaded = true
+	return nil
+}
+
+// projectForFile returns the innermost directory above a local file that has sessions under ~/.claude/projects
+func projectForFile(path string) (projectID, projectPath string, ok bool) {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		id := hashProjectPath(dir)
+		if info, err := os.Stat(filepath.Join(getProjectsDir(), id)); err == nil && info.IsDir() {
+			return id, dir, true
+		}
+		if dir == filepath.Dir(dir) {
+			return "", "", false
+		}
+	}
+}
+
+// trackRecentFile records that user opened a local file, under the project it belongs to
+func trackRecentFile(user *User, path string) {
+	path = filepath.Clean(path)
+	projectID, projectPath, ok := projectForFile(path)
+	if !ok {
+		return
+	}
+	recentFilesMu.Lock()
+	defer recentFilesMu.Unlock()
+	if err := loadRecentFiles(); err != nil {
+		log.Printf("[RecentFiles] Failed to load recent files: %v", err)
+		return
+	}
+	project := recentFilesStore[projectID]
+	if project == nil {
+		project = &recentProject{}
+		recentFilesStore[projectID] = project
+	}
+	project.Path = projectPath
+	file := RecentFile{Path: path, Owner: ownerID(user)}
+	files := []RecentFile{file}
+	for _, f := range project.Files {
+		if f.Path == file.Path && f.Owner == file.Owner {
+			files[0].Opens = f.Opens
+			continue
+		}
+		files = append(files, f)
+	}
+	files[0].Opens++
+	files[0].OpenedAt = time.Now().UTC().Format(time.RFC3339)
+	if len(files) > recentFilesPerProject {
+		files = files[:recentFilesPerProject]
+	}
+	project.Files = files
+	if err := writeJSONFileAtomic(recentFilesPath(), recentFilesStore); err != nil {
+		log.Printf("[RecentFiles] Failed to save recent files: %v", err)
+	}
+}
+
+// recentFiles returns the files user recently opened in a project that still exist, most recent first
+func recentFiles(user *User, projectID string, limit int) (string, []RecentFile) {
+	recentFilesMu.Lock()
+	var projectPath string
+	var stored []RecentFile
+	if err := loadRecentFiles(); err != nil {
+		log.Printf("[RecentFiles] Failed to load recent files: %v", err)
+	} else if project := recentFilesStore[projectID]; project != nil {
+		projectPath = project.Path
+		stored = append(stored, project.Files...)
+	}
+	recentFilesMu.Unlock()
+
+	files := []RecentFile{}
+	for _, f := range stored {
+		if len(files) >= limit {
+			break
+		}
+		if f.Owner != ownerID(user) || !userCanAccessPath(user, f.Path) {
+			continue
+		}
+		if info, err := os.Stat(f.Path); err != nil || info.IsDir() {
+			continue
+		}
+		f.RelPath = f.Path
+		if rel, err := filepath.Rel(projectPath, f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			f.RelPath = rel
+		}
+		files = append(files, f)
+	}
+	return projectPath, files
+}
+
+// GetRecentFiles handles GET /api/projects/:id/recent-files
+// Returns the files the user opened in the project with POST /api/file/read, most recent first, for the quick switcher.
+// :id is the project's directory name under ~/.claude/projects
+// Query parameters:
+//   - limit: maximum files to return (default 20, max 200)
+func GetRecentFiles(c *gin.Context) {
+	projectID := c.Param("id")
+	if projectID == "" || projectID != filepath.Base(projectID) || strings.HasPrefix(projectID, ".") {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid project id")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+	if limit > recentFilesPerProject {
+		limit = recentFilesPerProject
+	}
+	user := currentUser(c)
+	projectPath, files := recentFiles(user, projectID, limit)
+	if projectPath == "" {
+		projectPath = projectPathFromDir(projectID)
+	}
+	if !userCanAccessPath(user, projectPath) {
+		denyPath(c, projectPath)
+		return
+	}
+	c.JSON(http.StatusOK, RecentFilesResponse{ProjectID: projectID, ProjectPath: projectPath, Files: files})
+}
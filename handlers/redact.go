@@ -0,0 +1,290 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redactionRule masks the matches of one secret pattern
+type redactionRule struct {
+	name        string
+	source      string // builtin, config, or request
+	re          *regexp.Regexp
+	replacement string // regexp replacement template; defaults to [REDACTED:name]
+}
+
+// builtinRedactions catch common credentials in transcript text
+var builtinRedactions = []redactionRule{
+	{name: "private-key", re: regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{name: "anthropic-key", re: regexp.MustCompile(`sk-ant-[A-Za-z0-9_\-]{20,}`)},
+	{name: "openai-key", re: regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_\-]{20,}`)},
+	{name: "aws-access-key", re: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "github-token", re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{name: "slack-token", re: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9\-]{10,}`)},
+	{name: "jwt", re: regexp.MustCompile(`\beyJ[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}\.[A-Za-z0-9_\-]{10,}`)},
+	{name: "url-credentials", re: regexp.MustCompile(`\b([a-z][a-z0-9+.\-]*://[^:/\s@]+:)[^@\s/]+@`), replacement: "${1}[REDACTED:url-credentials]@"},
+	{name: "bearer", re: regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/\-]{20,}=*`), replacement: "${1}[REDACTED:bearer]"},
+	{
+		name:        "assignment",
+		re:          regexp.MustCompile(`(?i)\b((?:password|passwd|secret|token|api[_\-]?key|access[_\-]?key)[A-Za-z_]*\s*[:=]\s*)("[^"\n]*"|'[^'\n]*'|[^\s"',;]+)`),
+		replacement: "${1}[REDACTED:assignment]",
+	},
+}
+
+var (
+	// redactionNamePattern is the form of rule names, which appear in [REDACTED:name] markers
+	redactionNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_\-]*$`)
+	// redactionMarker finds the markers left in redacted text
+	redactionMarker = regexp.MustCompile(`\[REDACTED:([a-z0-9_\-]+)\]`)
+	// entropyCandidate matches the runs of token characters checked for entropy; not /, so paths split up
+	entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+_\-=]+`)
+)
+
+// redactor masks secrets in text shared outside the server
+type redactor struct {
+	rules            []redactionRule
+	entropyMinLength int // 0 disables the entropy check
+	entropyMinBits   float64
+}
+
+// validateRedaction checks the redaction section of the server config
+func validateRedaction(cfg RedactionConfig) error {
+	builtin := make(map[string]bool)
+	for _, rule := range builtinRedactions {
+		builtin[rule.name] = true
+	}
+	for _, name := range cfg.DisableBuiltin {
+		if !builtin[name] {
+			return fmt.Errorf("redaction.disableBuiltin: unknown rule %q", name)
+		}
+	}
+	for i, rule := range cfg.Rules {
+		if !redactionNamePattern.MatchString(rule.Name) {
+			return fmt.Errorf("redaction.rules[%d]: name must be lowercase letters, digits, - and _", i)
+		}
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return fmt.Errorf("redaction.rules[%d]: %w", i, err)
+		}
+	}
+	if !cfg.DisableEntropy && (cfg.EntropyMinLength <= 0 || cfg.EntropyMinBits <= 0) {
+		return fmt.Errorf("redaction.entropyMinLength and entropyMinBits must be positive")
+	}
+	return nil
+}
+
+// newRedactor returns the configured rules followed by extra regular expressions
+func newRedactor(extra []string) (*redactor, error) {
+	cfg := getServerConfig().Redaction
+	r := &redactor{}
+	disabled := make(map[string]bool)
+	for _, name := range cfg.DisableBuiltin {
+		disabled[name] = true
+	}
+	for _, rule := range builtinRedactions {
+		if !disabled[rule.name] {
+			rule.source = "builtin"
+			r.rules = append(r.rules, rule)
+		}
+	}
+	for _, rule := range cfg.Rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %s: %w", rule.Name, err)
+		}
+		r.rules = append(r.rules, redactionRule{name: rule.Name, source: "config", re: re})
+	}
+	for i, pattern := range extra {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact[%d]: %w", i, err)
+		}
+		r.rules = append(r.rules, redactionRule{name: "custom", source: "request", re: re})
+	}
+	if !cfg.DisableEntropy {
+		r.entropyMinLength, r.entropyMinBits = cfg.EntropyMinLength, cfg.EntropyMinBits
+	}
+	return r, nil
+}
+
+// redact returns s with every rule's matches masked, then any high-entropy tokens
+func (r *redactor) redact(s string) string {
+	for _, rule := range r.rules {
+		replacement := rule.replacement
+		if replacement == "" {
+			replacement = "[REDACTED:" + rule.name + "]"
+		}
+		s = rule.re.ReplaceAllString(s, replacement)
+	}
+	if r.entropyMinLength > 0 {
+		s = entropyCandidate.ReplaceAllStringFunc(s, func(token string) string {
+			if looksRandom(token, r.entropyMinLength, r.entropyMinBits) {
+				return "[REDACTED:entropy]"
+			}
+			return token
+		})
+	}
+	return s
+}
+
+// looksRandom reports whether token is long, mixes letters and digits, and has
+// at least minBits bits of Shannon entropy per character
+func looksRandom(token string, minLength int, minBits float64) bool {
+	if len(token) < minLength || strings.HasPrefix(token, "REDACTED") {
+		return false
+	}
+	var letters, digits bool
+	counts := make(map[rune]int)
+	for _, ch := range token {
+		counts[ch]++
+		letters = letters || unicode.IsLetter(ch)
+		digits = digits || unicode.IsDigit(ch)
+	}
+	if !letters || !digits {
+		return false
+	}
+	bits := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(len(token))
+		bits -= p * math.Log2(p)
+	}
+	return bits >= minBits
+}
+
+// redactionFindingLimit caps the findings listed by a redaction report
+const redactionFindingLimit = 200
+
+// RedactionTestRequest is the body of POST /api/session/:id/redaction-report
+type RedactionTestRequest struct {
+	Redact             []string `json:"redact,omitempty"` // extra patterns, as for a share link
+	IncludeToolOutputs bool     `json:"includeToolOutputs"`
+}
+
+// RedactionRuleInfo is a rule in effect and how often it matched
+type RedactionRuleInfo struct {
+	Name    string `json:"name"`
+	Source  string `json:"source"` // builtin, config, request, or entropy
+	Pattern string `json:"pattern,omitempty"`
+	Matches int    `json:"matches"`
+}
+
+// RedactionFinding is one masked span
+type RedactionFinding struct {
+	Message int    `json:"message"` // index into the shared transcript's messages
+	Role    string `json:"role"`
+	Field   string `json:"field"` // text, tool, or output
+	Rule    string `json:"rule"`
+	Context string `json:"context"` // the surrounding redacted text
+}
+
+// RedactionReport is the response of POST /api/session/:id/redaction-report
+type RedactionReport struct {
+	SessionID string              `json:"sessionId"`
+	Total     int                 `json:"total"`
+	Rules     []RedactionRuleInfo `json:"rules"`
+	Findings  []RedactionFinding  `json:"findings"`
+	Truncated bool                `json:"truncated,omitempty"` // more than the listed findings
+}
+
+// markerContext returns the text around s[start:end], at most n bytes on each side
+func markerContext(s string, start, end, n int) string {
+	from, to := start-n, end+n
+	if from <= 0 {
+		from = 0
+	} else {
+		for from < start && !utf8.RuneStart(s[from]) {
+			from++
+		}
+	}
+	if to >= len(s) {
+		to = len(s)
+	} else {
+		for to > end && !utf8.RuneStart(s[to]) {
+			to--
+		}
+	}
+	context := strings.Join(strings.Fields(s[from:to]), " ")
+	if from > 0 {
+		context = "…" + context
+	}
+	if to < len(s) {
+		context += "…"
+	}
+	return context
+}
+
+// TestRedaction handles POST /api/session/:id/redaction-report
+// Runs the redaction rules over a session as a share link would and reports what gets masked,
+// so rules can be tested before sharing. Matched secrets are never returned, only their context
+func TestRedaction(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req RedactionTestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	r, err := newRedactor(req.Redact)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	transcript, err := sharedTranscript(&ShareLink{SessionID: sessionID, IncludeToolOutputs: req.IncludeToolOutputs, Redact: req.Redact})
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+
+	report := RedactionReport{SessionID: sessionID, Rules: []RedactionRuleInfo{}, Findings: []RedactionFinding{}}
+	ruleIndex := make(map[string]int)
+	for _, rule := range r.rules {
+		if _, ok := ruleIndex[rule.name]; !ok {
+			ruleIndex[rule.name] = len(report.Rules)
+			report.Rules = append(report.Rules, RedactionRuleInfo{Name: rule.name, Source: rule.source, Pattern: rule.re.String()})
+		}
+	}
+	if r.entropyMinLength > 0 {
+		ruleIndex["entropy"] = len(report.Rules)
+		report.Rules = append(report.Rules, RedactionRuleInfo{Name: "entropy", Source: "entropy"})
+	}
+
+	scan := func(index int, role, field, text string) {
+		for _, loc := range redactionMarker.FindAllStringSubmatchIndex(text, -1) {
+			rule := text[loc[2]:loc[3]]
+			if i, ok := ruleIndex[rule]; ok {
+				report.Rules[i].Matches++
+			}
+			report.Total++
+			if len(report.Findings) >= redactionFindingLimit {
+				report.Truncated = true
+				continue
+			}
+			report.Findings = append(report.Findings, RedactionFinding{
+				Message: index,
+				Role:    role,
+				Field:   field,
+				Rule:    rule,
+				Context: markerContext(text, loc[0], loc[1], 40),
+			})
+		}
+	}
+	for i, msg := range transcript.Messages {
+		scan(i, msg.Role, "text", msg.Text)
+		for _, tool := range msg.Tools {
+			scan(i, msg.Role, "tool", tool.Detail)
+			scan(i, msg.Role, "output", tool.Output)
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
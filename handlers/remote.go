@@ -0,0 +1,512 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// RemoteHost is an SSH target whose directories can be used as host:path working directories
+type RemoteHost struct {
+	Name         string   `yaml:"name" json:"name"`
+	Host         string   `yaml:"host" json:"host"`
+	User         string   `yaml:"user" json:"user,omitempty"`
+	Port         int      `yaml:"port" json:"port,omitempty"`
+	IdentityFile string   `yaml:"identityFile" json:"identityFile,omitempty"`
+	KnownHosts   string   `yaml:"knownHosts" json:"knownHosts,omitempty"` // default ~/.ssh/known_hosts
+	ClaudePath   string   `yaml:"claudePath" json:"claudePath,omitempty"` // default "claude" on the remote PATH
+	AllowedRoots []string `yaml:"allowedRoots" json:"allowedRoots,omitempty"`
+}
+
+// RemoteHostInfo is a configured host as shown to clients
+type RemoteHostInfo struct {
+	Name         string   `json:"name"`
+	Host         string   `json:"host"`
+	AllowedRoots []string `json:"allowedRoots,omitempty"`
+}
+
+const remoteDialTimeout = 10 * time.Second
+
+// validateRemoteHosts checks the remoteHosts section and normalizes roots
+func validateRemoteHosts(hosts []RemoteHost) error {
+	seen := make(map[string]bool)
+	for i := range hosts {
+		h := &hosts[i]
+		if h.Name == "" || strings.ContainsAny(h.Name, ":/\\ ") {
+			return fmt.Errorf("remoteHosts[%d]: name is required and must not contain ':', '/', or spaces", i)
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("remoteHosts[%d]: duplicate name %q", i, h.Name)
+		}
+		seen[h.Name] = true
+		if h.Host == "" {
+			return fmt.Errorf("remoteHosts[%d]: host is required", i)
+		}
+		if h.Port < 0 || h.Port > 65535 {
+			return fmt.Errorf("remoteHosts[%d]: port must be between 1 and 65535", i)
+		}
+		for j, root := range h.AllowedRoots {
+			if !path.IsAbs(root) {
+				return fmt.Errorf("remoteHosts[%d].allowedRoots[%d]: must be an absolute path", i, j)
+			}
+			h.AllowedRoots[j] = path.Clean(root)
+		}
+	}
+	return nil
+}
+
+// parseRemotePath splits a host:/path working directory for a configured remote host
+func parseRemotePath(p string) (RemoteHost, string, bool) {
+	name, rest, ok := strings.Cut(p, ":")
+	if !ok || name == "" || !strings.HasPrefix(rest, "/") {
+		return RemoteHost{}, "", false
+	}
+	for _, h := range getServerConfig().RemoteHosts {
+		if h.Name == name {
+			return h, path.Clean(rest), true
+		}
+	}
+	return RemoteHost{}, "", false
+}
+
+// isRemotePath reports whether p refers to a configured remote host
+func isRemotePath(p string) bool {
+	_, _, ok := parseRemotePath(p)
+	return ok
+}
+
+// remotePathWithin reports whether a clean remote path is root or below it
+func remotePathWithin(p string, root string) bool {
+	return p == root || root == "/" || strings.HasPrefix(p, root+"/")
+}
+
+// userCanAccessRemotePath applies the host's allowed roots and the user's host:path project roots
+func userCanAccessRemotePath(u *User, h RemoteHost, p string) bool {
+	if len(h.AllowedRoots) > 0 {
+		allowed := false
+		for _, root := range h.AllowedRoots {
+			if remotePathWithin(p, root) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if u == nil || u.IsAdmin() || len(u.ProjectRoots) == 0 {
+		return true
+	}
+	for _, root := range u.ProjectRoots {
+		rootHost, rootPath, ok := parseRemotePath(root)
+		if ok && rootHost.Name == h.Name && remotePathWithin(p, rootPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote single-quotes s for a POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+// shellJoin quotes and joins a command line for a POSIX shell
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func (h RemoteHost) target() string {
+	if h.User != "" {
+		return h.User + "@" + h.Host
+	}
+	return h.Host
+}
+
+// sshArgs returns ssh client arguments that run remoteCommand on the host
+func (h RemoteHost) sshArgs(remoteCommand string) []string {
+	args := []string{"-T", "-o", "BatchMode=yes", "-o", "ServerAliveInterval=30"}
+	if h.Port != 0 {
+		args = append(args, "-p", strconv.Itoa(h.Port))
+	}
+	if h.IdentityFile != "" {
+		args = append(args, "-i", expandHome(h.IdentityFile))
+	}
+	if h.KnownHosts != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+expandHome(h.KnownHosts))
+	}
+	return append(args, h.target(), "--", remoteCommand)
+}
+
+// remoteClaudeCommand runs claude in dir on the remote host over ssh
+func remoteClaudeCommand(h RemoteHost, dir string, args []string) *exec.Cmd {
+	claudePath := h.ClaudePath
+	if claudePath == "" {
+		claudePath = "claude"
+	}
+	// claudePath is left unquoted so admins can use ~ or $HOME in it
+	remote := "cd " + shellQuote(dir) + " && exec " + claudePath + " " + shellJoin(args)
+	return exec.Command("ssh", h.sshArgs(remote)...)
+}
+
+// checkWorkDir verifies that a local or remote working directory exists
+func checkWorkDir(workDir string) error {
+	if h, dir, ok := parseRemotePath(workDir); ok {
+		var attrs sftpAttrs
+		err := withSFTP(h, func(client *sftpClient) error {
+			var err error
+			attrs, err = client.Stat(dir)
+			return err
+		})
+		if errors.Is(err, os.ErrNotExist) || (err == nil && !attrs.isDir()) {
+			return fmt.Errorf("Working directory does not exist: %s", workDir)
+		}
+		if err != nil {
+			return withCode(http.StatusBadGateway, ErrRemoteUnavailable, fmt.Errorf("Failed to reach %s: %v", h.Name, err))
+		}
+		return nil
+	}
+	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+		return fmt.Errorf("Working directory does not exist: %s", workDir)
+	}
+	return nil
+}
+
+// expandHome resolves a leading ~/ against the server user's home directory
+func expandHome(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+	return p
+}
+
+// sshClientConfig builds auth and host key checking for h
+// Keys come from identityFile (or the default ~/.ssh keys) plus a running ssh-agent
+// The returned cleanup closes the agent connection once the handshake is done
+func sshClientConfig(h RemoteHost) (*ssh.ClientConfig, func(), error) {
+	username := h.User
+	if username == "" {
+		if u, err := user.Current(); err == nil {
+			username = u.Username
+		}
+	}
+
+	var signers []ssh.Signer
+	keyFiles := []string{h.IdentityFile}
+	if h.IdentityFile == "" {
+		keyFiles = []string{"~/.ssh/id_ed25519", "~/.ssh/id_ecdsa", "~/.ssh/id_rsa"}
+	}
+	for _, file := range keyFiles {
+		data, err := os.ReadFile(expandHome(file))
+		if err != nil {
+			if h.IdentityFile != "" {
+				return nil, nil, fmt.Errorf("failed to read identity file: %w", err)
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		if err != nil {
+			if h.IdentityFile != "" {
+				return nil, nil, fmt.Errorf("failed to parse identity file: %w", err)
+			}
+			continue
+		}
+		signers = append(signers, signer)
+	}
+	auth := []ssh.AuthMethod{}
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	cleanup := func() {}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+			cleanup = func() { conn.Close() }
+		}
+	}
+	if len(auth) == 0 {
+		return nil, nil, fmt.Errorf("no SSH keys available for %s", h.Name)
+	}
+
+	knownHostsFile := h.KnownHosts
+	if knownHostsFile == "" {
+		knownHostsFile = "~/.ssh/known_hosts"
+	}
+	hostKeyCallback, err := knownhosts.New(expandHome(knownHostsFile))
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to load known hosts: %w", err)
+	}
+
+	return &ssh.ClientConfig{
+		User:            username,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         remoteDialTimeout,
+	}, cleanup, nil
+}
+
+// dialSFTP opens an SSH connection to h and starts the sftp subsystem
+func dialSFTP(h RemoteHost) (*sftpClient, error) {
+	config, cleanup, err := sshClientConfig(h)
+	if err != nil {
+		return nil, err
+	}
+	port := h.Port
+	if port == 0 {
+		port = 22
+	}
+	conn, err := ssh.Dial("tcp", net.JoinHostPort(h.Host, strconv.Itoa(port)), config)
+	cleanup()
+	if err != nil {
+		return nil, err
+	}
+	session, err := conn.NewSession()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	w, err := session.StdinPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client, err := newSFTPClient(r, w, func() error {
+		session.Close()
+		return conn.Close()
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+var (
+	sftpMu      sync.Mutex
+	sftpClients = make(map[string]*sftpClient)
+)
+
+// withSFTP runs fn with a cached SFTP connection to h
+// A broken connection is dropped and the call retried once on a fresh one
+func withSFTP(h RemoteHost, fn func(*sftpClient) error) error {
+	// Key on the whole definition so config reloads don't reuse stale connections
+	key := fmt.Sprintf("%s|%s|%d|%s|%s", h.Name, h.target(), h.Port, h.IdentityFile, h.KnownHosts)
+	for attempt := 0; ; attempt++ {
+		sftpMu.Lock()
+		client := sftpClients[key]
+		if client == nil {
+			var err error
+			client, err = dialSFTP(h)
+			if err != nil {
+				sftpMu.Unlock()
+				return err
+			}
+			sftpClients[key] = client
+		}
+		sftpMu.Unlock()
+
+		err := fn(client)
+		var statusErr *sftpStatusError
+		if err == nil || errors.As(err, &statusErr) {
+			return err
+		}
+		log.Printf("[Remote] SFTP connection to %s failed: %v", h.Name, err)
+		sftpMu.Lock()
+		if sftpClients[key] == client {
+			delete(sftpClients, key)
+		}
+		sftpMu.Unlock()
+		client.Close()
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// remoteFileError writes the response for a failed remote file operation
+func remoteFileError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		respondError(c, http.StatusNotFound, ErrNotFound, notFound)
+	case errors.Is(err, os.ErrPermission):
+		respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+	default:
+		respondErrorDetails(c, http.StatusBadGateway, ErrRemoteUnavailable, "Remote host unavailable", err.Error())
+	}
+}
+
+// readRemoteDir stats and lists a remote directory
+func readRemoteDir(c *gin.Context, h RemoteHost, dir string) ([]sftpEntry, bool) {
+	var entries []sftpEntry
+	var notDir bool
+	err := withSFTP(h, func(client *sftpClient) error {
+		attrs, err := client.Stat(dir)
+		if err != nil {
+			return err
+		}
+		if !attrs.isDir() {
+			notDir = true
+			return nil
+		}
+		entries, err = client.ReadDir(dir)
+		return err
+	})
+	if err != nil {
+		remoteFileError(c, err, "Path does not exist")
+		return nil, false
+	}
+	if notDir {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is not a directory")
+		return nil, false
+	}
+	return entries, true
+}
+
+// listRemoteDirectories is ListDirectories for a host:path directory
+func listRemoteDirectories(c *gin.Context, user *User, h RemoteHost, dir string) {
+	entries, ok := readRemoteDir(c, h, dir)
+	if !ok {
+		return
+	}
+
+	var directories []DirectoryItem
+	if parent := path.Dir(dir); parent != dir && userCanAccessRemotePath(user, h, parent) {
+		directories = append(directories, DirectoryItem{Name: "..", Path: h.Name + ":" + parent})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, ".") || !entry.Attrs.isDir() {
+			continue
+		}
+		directories = append(directories, DirectoryItem{
+			Name: entry.Name,
+			Path: h.Name + ":" + path.Join(dir, entry.Name),
+		})
+	}
+
+	c.JSON(http.StatusOK, ListDirectoriesResponse{
+		Directories: directories,
+	})
+}
+
+// listRemoteFiles is ListFiles for a host:path directory
+func listRemoteFiles(c *gin.Context, h RemoteHost, dir string) {
+	entries, ok := readRemoteDir(c, h, dir)
+	if !ok {
+		return
+	}
+
+	var directories []FileItem
+	var files []FileItem
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name, ".") {
+			continue
+		}
+		item := FileItem{
+			Name:     entry.Name,
+			Path:     h.Name + ":" + path.Join(dir, entry.Name),
+			Size:     entry.Attrs.Size,
+			Modified: entry.Attrs.ModTime,
+		}
+		if entry.Attrs.isDir() {
+			item.Type = "directory"
+			directories = append(directories, item)
+		} else {
+			item.Type = "file"
+			files = append(files, item)
+		}
+	}
+	sort.Slice(directories, func(i, j int) bool { return directories[i].Name < directories[j].Name })
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	c.JSON(http.StatusOK, ListFilesResponse{
+		Items: append(directories, files...),
+	})
+}
+
+// readRemoteFile is ReadFile for a host:path file
+func readRemoteFile(c *gin.Context, h RemoteHost, file string, displayPath string) {
+	var attrs sftpAttrs
+	var content []byte
+	err := withSFTP(h, func(client *sftpClient) error {
+		var err error
+		attrs, err = client.Stat(file)
+		if err != nil || attrs.isDir() || attrs.Size > maxFileSize {
+			return err
+		}
+		content, err = client.ReadFile(file, maxFileSize+1)
+		return err
+	})
+	if err != nil {
+		remoteFileError(c, err, "File does not exist")
+		return
+	}
+	if attrs.isDir() {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Path is a directory, not a file")
+		return
+	}
+	if attrs.Size > maxFileSize || len(content) > maxFileSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, "File is too large (max 1MB)")
+		return
+	}
+	if !utf8.Valid(content) {
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, "File is binary")
+		return
+	}
+
+	language := langMap[strings.ToLower(path.Ext(file))]
+	if language == "" {
+		language = "plaintext"
+	}
+	c.JSON(http.StatusOK, ReadFileResponse{
+		Content:  string(content),
+		Language: language,
+		Path:     displayPath,
+		Name:     path.Base(file),
+		Size:     int64(len(content)),
+	})
+}
+
+// ListRemoteHosts handles GET /api/remote-hosts
+// Returns the configured SSH hosts usable as host:path working directories
+func ListRemoteHosts(c *gin.Context) {
+	hosts := make([]RemoteHostInfo, 0)
+	for _, h := range getServerConfig().RemoteHosts {
+		hosts = append(hosts, RemoteHostInfo{Name: h.Name, Host: h.Host, AllowedRoots: h.AllowedRoots})
+	}
+	c.JSON(http.StatusOK, gin.H{"hosts": hosts})
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// gpuQueryTimeout bounds nvidia-smi, which can hang while a driver is wedged
+const gpuQueryTimeout = 5 * time.Second
+
+// ResourceGuardConfig refuses to start chats and terminals while the host is overloaded (0 = not checked)
+type ResourceGuardConfig struct {
+	// MaxLoadPerCPU is the highest 1-minute load average per CPU core
+	MaxLoadPerCPU float64 `yaml:"maxLoadPerCPU" json:"maxLoadPerCPU"`
+	// MinFreeMemoryMB is the least available memory
+	MinFreeMemoryMB int64 `yaml:"minFreeMemoryMB" json:"minFreeMemoryMB"`
+	// MinFreeDiskMB is the least free space on the working directory's filesystem
+	MinFreeDiskMB int64 `yaml:"minFreeDiskMB" json:"minFreeDiskMB"`
+	// MinFreeGPUMemoryMB is the least free memory on the emptiest GPU, read with nvidia-smi
+	MinFreeGPUMemoryMB int64 `yaml:"minFreeGPUMemoryMB" json:"minFreeGPUMemoryMB"`
+}
+
+// enabled reports whether any threshold is set
+func (g ResourceGuardConfig) enabled() bool {
+	return g.MaxLoadPerCPU > 0 || g.MinFreeMemoryMB > 0 || g.MinFreeDiskMB > 0 || g.MinFreeGPUMemoryMB > 0
+}
+
+// ResourceReadings are the host's readings when a run was refused, the details of a RESOURCE_BUSY error
+// A reading is omitted when its threshold isn't set or it couldn't be taken
+type ResourceReadings struct {
+	Load1           *float64            `json:"load1,omitempty"`
+	CPUs            int                 `json:"cpus"`
+	FreeMemoryMB    *int64              `json:"freeMemoryMB,omitempty"`
+	FreeDiskMB      *int64              `json:"freeDiskMB,omitempty"`
+	FreeGPUMemoryMB *int64              `json:"freeGPUMemoryMB,omitempty"`
+	Exceeded        []string            `json:"exceeded"` // load, memory, disk, gpuMemory
+	Limits          ResourceGuardConfig `json:"limits"`
+}
+
+// readLoadAverage returns the 1-minute load average from /proc/loadavg
+func readLoadAverage() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	return load, err == nil
+}
+
+// readAvailableMemoryMB returns MemAvailable from /proc/meminfo
+func readAvailableMemoryMB() (int64, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kb, err := strconv.ParseInt(fields[1], 10, 64)
+			return kb >> 10, err == nil
+		}
+	}
+	return 0, false
+}
+
+// readFreeDiskMB returns the space unprivileged users may still write on dir's filesystem
+func readFreeDiskMB(dir string) (int64, bool) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, false
+	}
+	return int64(fs.Bavail) * int64(fs.Bsize) >> 20, true
+}
+
+// readFreeGPUMemoryMB returns the free memory of the GPU with the most of it
+func readFreeGPUMemoryMB() (int64, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), gpuQueryTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "nvidia-smi", "--query-gpu=memory.free", "--format=csv,noheader,nounits").Output()
+	if err != nil {
+		return 0, false
+	}
+	best, found := int64(0), false
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		if mb, err := strconv.ParseInt(strings.TrimSpace(string(line)), 10, 64); err == nil && (!found || mb > best) {
+			best, found = mb, true
+		}
+	}
+	return best, found
+}
+
+// checkResources returns a RESOURCE_BUSY error, with the readings as details, when the host is past
+// one of limits.resources; runs in remote directories use another host and aren't checked
+func checkResources(workDir string) error {
+	guard := getServerConfig().Limits.Resources
+	if !guard.enabled() {
+		return nil
+	}
+	if _, _, ok := parseRemotePath(workDir); ok {
+		return nil
+	}
+	readings := ResourceReadings{CPUs: runtime.NumCPU(), Exceeded: []string{}, Limits: guard}
+	var problems []string
+	if guard.MaxLoadPerCPU > 0 {
+		if load, ok := readLoadAverage(); ok {
+			readings.Load1 = &load
+			if limit := guard.MaxLoadPerCPU * float64(readings.CPUs); load > limit {
+				readings.Exceeded = append(readings.Exceeded, "load")
+				problems = append(problems, fmt.Sprintf("load average %.2f is above %.2f", load, limit))
+			}
+		}
+	}
+	if guard.MinFreeMemoryMB > 0 {
+		if free, ok := readAvailableMemoryMB(); ok {
+			readings.FreeMemoryMB = &free
+			if free < guard.MinFreeMemoryMB {
+				readings.Exceeded = append(readings.Exceeded, "memory")
+				problems = append(problems, fmt.Sprintf("%d MB of memory available, below %d MB", free, guard.MinFreeMemoryMB))
+			}
+		}
+	}
+	if guard.MinFreeDiskMB > 0 && workDir != "" {
+		if free, ok := readFreeDiskMB(workDir); ok {
+			readings.FreeDiskMB = &free
+			if free < guard.MinFreeDiskMB {
+				readings.Exceeded = append(readings.Exceeded, "disk")
+				problems = append(problems, fmt.Sprintf("%d MB of disk free in %s, below %d MB", free, workDir, guard.MinFreeDiskMB))
+			}
+		}
+	}
+	if guard.MinFreeGPUMemoryMB > 0 {
+		if free, ok := readFreeGPUMemoryMB(); ok {
+			readings.FreeGPUMemoryMB = &free
+			if free < guard.MinFreeGPUMemoryMB {
+				readings.Exceeded = append(readings.Exceeded, "gpuMemory")
+				problems = append(problems, fmt.Sprintf("%d MB of GPU memory free, below %d MB", free, guard.MinFreeGPUMemoryMB))
+			}
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return withDetails(http.StatusServiceUnavailable, ErrResourceBusy,
+		fmt.Errorf("server resources are low: %s", strings.Join(problems, "; ")), readings)
+}
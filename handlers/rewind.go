@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RewindRequest is the body of POST /api/session/:id/rewind
+type RewindRequest struct {
+	UUID   string `json:"uuid" binding:"required"` // message to rewind to
+	Mode   string `json:"mode"`                    // truncate (default) or fork
+	Before bool   `json:"before"`                  // drop the message itself too, e.g. to redo a prompt
+}
+
+// RewindResponse is the response of POST /api/session/:id/rewind
+type RewindResponse struct {
+	SessionID string `json:"sessionId"`        // the truncated session, or the new fork
+	Backup    string `json:"backup,omitempty"` // file name of the copy in rewind-backups made before truncation
+	Messages  int    `json:"messages"`         // messages left in the session
+}
+
+// rewindLine is the part of a transcript line rewindCut looks at
+type rewindLine struct {
+	Type    string `json:"type"`
+	UUID    string `json:"uuid"`
+	Message struct {
+		ID      string          `json:"id"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// toolBlocks returns the IDs of the line's tool_use blocks and of the tool_use blocks its tool_result blocks answer
+func (l *rewindLine) toolBlocks() (uses []string, results []string) {
+	var blocks []struct {
+		Type      string `json:"type"`
+		ID        string `json:"id"`
+		ToolUseID string `json:"tool_use_id"`
+	}
+	if json.Unmarshal(l.Message.Content, &blocks) != nil {
+		return nil, nil
+	}
+	for _, block := range blocks {
+		switch block.Type {
+		case "tool_use":
+			uses = append(uses, block.ID)
+		case "tool_result":
+			results = append(results, block.ToolUseID)
+		}
+	}
+	return uses, results
+}
+
+// errRewindMidTool is rewindCut's error for a cut that would leave a tool call without its result,
+// which claude refuses to resume
+var errRewindMidTool = withCode(http.StatusBadRequest, ErrInvalidRequest,
+	fmt.Errorf("rewinding there would leave a tool call without its result"))
+
+// rewindCut returns the byte offset at which to cut the transcript so it ends at uuid
+// An assistant reply is written as one line per content block, so the cut includes the rest of its blocks,
+// and the results of the reply's tool calls after it; found is false when uuid isn't in the transcript
+func rewindCut(data []byte, uuid string, before bool) (cut int, messages int, found bool, err error) {
+	offset := 0
+	replyID := ""
+	// pending is the latest reply's tool calls whose result hasn't been seen; a result only answers
+	// the reply before it, so calls left open by an older reply (e.g. an interrupted run) don't count
+	pending := make(map[string]bool)
+	lastReply := ""
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += offset + 1
+		}
+		var line rewindLine
+		json.Unmarshal(data[offset:end], &line)
+		uses, results := line.toolBlocks()
+
+		switch {
+		case found && replyID != "" && line.Type == "assistant" && line.Message.ID == replyID:
+			// Another block of the reply rewound to
+		case found && len(pending) > 0 && line.Type == "user" && len(results) > 0:
+			// The results of the reply's tool calls
+		case found && len(pending) > 0 && !isHistoryMessage(line.Type):
+			// Progress and other records between a call and its result
+			offset = end
+			continue
+		case found && len(pending) > 0:
+			return 0, 0, true, errRewindMidTool
+		case found:
+			return cut, messages, true, nil
+		case line.UUID == uuid:
+			found = true
+			if before {
+				// Cutting before a new reply drops nothing the calls before it could still be waiting on
+				if len(pending) > 0 && (line.Type != "assistant" || line.Message.ID == lastReply) {
+					return 0, 0, true, errRewindMidTool
+				}
+				return offset, messages, true, nil
+			}
+			if line.Type == "assistant" {
+				replyID = line.Message.ID
+			}
+		}
+
+		if isHistoryMessage(line.Type) {
+			messages++
+		}
+		if line.Type == "assistant" && line.Message.ID != lastReply {
+			lastReply = line.Message.ID
+			pending = make(map[string]bool)
+		}
+		for _, id := range results {
+			delete(pending, id)
+		}
+		for _, id := range uses {
+			pending[id] = true
+		}
+		if found {
+			cut = end
+		}
+		offset = end
+	}
+	if found && len(pending) > 0 {
+		return 0, 0, true, errRewindMidTool
+	}
+	return cut, messages, found, nil
+}
+
+// forkTranscript rewrites each line's sessionId so the copy is a session of its own
+func forkTranscript(data []byte, sessionID string) ([]byte, error) {
+	var out bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var fields map[string]json.RawMessage
+			if json.Unmarshal(trimmed, &fields) == nil {
+				if _, ok := fields["sessionId"]; ok {
+					fields["sessionId"], _ = json.Marshal(sessionID)
+				}
+				rewritten, merr := json.Marshal(fields)
+				if merr != nil {
+					return nil, merr
+				}
+				trimmed = rewritten
+			}
+			out.Write(trimmed)
+			out.WriteByte('\n')
+		}
+		if err == io.EOF {
+			return out.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data through a temp file in the same directory
+func writeFileAtomic(path string, data []byte) error {
+	return writeFileAtomicMode(path, data, 0644)
+}
+
+// writeFileAtomicMode is writeFileAtomic with explicit file permissions, set before any data is written
+func writeFileAtomicMode(path string, data []byte, perm os.FileMode) error {
+	// A temp file per writer, ending in .tmp so the config watcher skips it
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// backupSessionFile saves a transcript about to be rewritten under the data directory's rewind-backups
+// and returns the backup's file name; clients aren't told where the data directory is
+func backupSessionFile(sessionID string, data []byte) (string, error) {
+	backupDir := serverDataPath("rewind-backups")
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+	stamp := strings.ReplaceAll(time.Now().UTC().Format("20060102T150405.000Z"), ".", "")
+	backup := filepath.Join(backupDir, sessionID+"-"+stamp+".jsonl")
+	if err := os.WriteFile(backup, data, 0600); err != nil {
+		return "", err
+	}
+	return filepath.Base(backup), nil
+}
+
+// RewindSession handles POST /api/session/:id/rewind
+// Cuts the session back to a message so the conversation can continue from there.
+// truncate rewrites the session after saving the original under the data directory's rewind-backups;
+// fork leaves it alone and copies the part up to the message into a new session
+func RewindSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	user := currentUser(c)
+	if !userCanAccessSession(user, sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req RewindRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = "truncate"
+	}
+	if req.Mode != "truncate" && req.Mode != "fork" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "mode must be truncate or fork")
+		return
+	}
+
+	path := findSessionFile(sessionID)
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	if req.Mode == "truncate" && IsSessionLoading(sessionID) {
+		respondError(c, http.StatusConflict, ErrSessionBusy, "This session is already processing a request")
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	cut, messages, found, err := rewindCut(data, req.UUID, req.Before)
+	if !found {
+		respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("Message %s not found in session", req.UUID))
+		return
+	}
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if messages == 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Rewinding there would leave the session empty")
+		return
+	}
+
+	if req.Mode == "fork" {
+		forkID := newUUID()
+		forked, err := forkTranscript(data[:cut], forkID)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to fork session", err.Error())
+			return
+		}
+		if err := writeFileAtomic(filepath.Join(filepath.Dir(path), forkID+".jsonl"), forked); err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to write forked session", err.Error())
+			return
+		}
+		recordSessionOwner(forkID, ownerID(user))
+		c.JSON(http.StatusOK, RewindResponse{SessionID: forkID, Messages: messages})
+		return
+	}
+
+	backup, err := backupSessionFile(sessionID, data)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to back up session", err.Error())
+		return
+	}
+	if err := writeFileAtomic(path, data[:cut]); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to truncate session", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, RewindResponse{SessionID: sessionID, Backup: backup, Messages: messages})
+}
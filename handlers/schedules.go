@@ -0,0 +1,743 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxScheduleRuns is how many runs of each schedule are kept in the history
+	maxScheduleRuns = 50
+	// defaultScheduleTimeout bounds a headless run unless the schedule sets its own limit
+	defaultScheduleTimeout = 60 * time.Minute
+	scheduleSummaryLimit   = 2000
+	scheduleStderrLines    = 20
+)
+
+// Schedule is a prompt run headlessly on a cron schedule
+type Schedule struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Cron     string `json:"cron"`
+	Timezone string `json:"timezone,omitempty"` // IANA name; server local time when empty
+	Prompt   string `json:"prompt"`
+	WorkDir  string `json:"workDir"`
+	Model    string `json:"model,omitempty"`
+	Backend  string `json:"backend,omitempty"` // execution backend; runs locally when empty
+
+	// Tool policy
+	PermissionMode  string   `json:"permissionMode,omitempty"` // server default when empty
+	AllowedTools    []string `json:"allowedTools,omitempty"`
+	DisallowedTools []string `json:"disallowedTools,omitempty"`
+
+	TimeoutMinutes int       `json:"timeoutMinutes,omitempty"`
+	Enabled        bool      `json:"enabled"`
+	Owner          string    `json:"owner,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// ScheduleInfo is a schedule as returned by the API
+type ScheduleInfo struct {
+	Schedule
+	NextRun *time.Time   `json:"nextRun,omitempty"`
+	LastRun *ScheduleRun `json:"lastRun,omitempty"`
+	Running bool         `json:"running"`
+}
+
+// ScheduleRequest is the body of POST/PUT /api/schedules
+// On update, nil fields are left unchanged
+type ScheduleRequest struct {
+	Name            *string   `json:"name"`
+	Cron            *string   `json:"cron"`
+	Timezone        *string   `json:"timezone"`
+	Prompt          *string   `json:"prompt"`
+	WorkDir         *string   `json:"workDir"`
+	Model           *string   `json:"model"`
+	Backend         *string   `json:"backend"`
+	PermissionMode  *string   `json:"permissionMode"`
+	AllowedTools    *[]string `json:"allowedTools"`
+	DisallowedTools *[]string `json:"disallowedTools"`
+	TimeoutMinutes  *int      `json:"timeoutMinutes"`
+	Enabled         *bool     `json:"enabled"`
+}
+
+// ScheduleRun records one execution of a schedule
+type ScheduleRun struct {
+	ID         string     `json:"id"`
+	ScheduleID string     `json:"scheduleId"`
+	Trigger    string     `json:"trigger"` // "cron", "manual", "linear", or "jira"
+	Status     string     `json:"status"`  // running, success, error, interrupted, or skipped
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	SessionID  string     `json:"sessionId,omitempty"`
+	ProcessID  int        `json:"processId,omitempty"`
+	CostUSD    float64    `json:"costUsd,omitempty"`
+	NumTurns   int        `json:"numTurns,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	Ticket     *TicketRef `json:"ticket,omitempty"` // the tracker ticket that started the run
+}
+
+// scheduleStore is the on-disk format of schedules.json
+type scheduleStore struct {
+	Schedules []Schedule               `json:"schedules"`
+	Runs      map[string][]ScheduleRun `json:"runs"` // schedule ID -> runs, oldest first
+}
+
+var (
+	schedulesMu     sync.Mutex
+	schedules       scheduleStore
+	schedulesLoaded bool
+	schedulerOnce   sync.Once
+)
+
+func schedulesPath() string {
+	return serverDataPath("schedules.json")
+}
+
+// loadSchedules reads schedules once; caller must hold schedulesMu
+func loadSchedules() error {
+	if schedulesLoaded {
+		return nil
+	}
+	if err := loadJSONFile(schedulesPath(), &schedules); err != nil {
+		return err
+	}
+	if schedules.Runs == nil {
+		schedules.Runs = make(map[string][]ScheduleRun)
+	}
+	// Runs interrupted by a restart can never finish
+	for id, runs := range schedules.Runs {
+		for i := range runs {
+			if runs[i].Status == "running" {
+				runs[i].Status = "error"
+				runs[i].Error = "server restarted during run"
+			}
+		}
+		schedules.Runs[id] = runs
+	}
+	schedulesLoaded = true
+	return nil
+}
+
+func saveSchedules() error {
+	return writeJSONFileAtomicMode(schedulesPath(), schedules, 0600)
+}
+
+// findSchedule returns the index of id; caller must hold schedulesMu
+func findSchedule(id string) int {
+	for i, s := range schedules.Schedules {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// location returns the schedule's time zone
+func (s Schedule) location() *time.Location {
+	if s.Timezone == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.Local
+	}
+	return loc
+}
+
+// info builds the API view; caller must hold schedulesMu
+func (s Schedule) info() ScheduleInfo {
+	info := ScheduleInfo{Schedule: s}
+	runs := schedules.Runs[s.ID]
+	if len(runs) > 0 {
+		last := runs[len(runs)-1]
+		info.LastRun = &last
+	}
+	info.Running = scheduleRunning(s.ID)
+	if s.Enabled {
+		if cron, err := parseCron(s.Cron); err == nil {
+			if next := cron.next(time.Now().In(s.location())); !next.IsZero() {
+				info.NextRun = &next
+			}
+		}
+	}
+	return info
+}
+
+// validateSchedule checks a schedule after applying a request
+func validateSchedule(s Schedule, user *User) error {
+	if strings.TrimSpace(s.Prompt) == "" {
+		return fmt.Errorf("prompt is required")
+	}
+	if _, err := parseCron(s.Cron); err != nil {
+		return fmt.Errorf("invalid cron expression: %v", err)
+	}
+	if s.Timezone != "" {
+		if _, err := time.LoadLocation(s.Timezone); err != nil {
+			return fmt.Errorf("unknown timezone: %s", s.Timezone)
+		}
+	}
+	if s.WorkDir == "" {
+		return fmt.Errorf("workDir is required")
+	}
+	if !userCanAccessPath(user, s.WorkDir) {
+		return fmt.Errorf("working directory is outside your projects: %s", s.WorkDir)
+	}
+	if isRemotePath(s.WorkDir) {
+		if err := checkWorkDir(s.WorkDir); err != nil {
+			return err
+		}
+	} else if info, err := os.Stat(s.WorkDir); err != nil || !info.IsDir() {
+		return fmt.Errorf("working directory does not exist: %s", s.WorkDir)
+	}
+	if err := checkBackend(s.Backend, s.WorkDir); err != nil {
+		return err
+	}
+	if s.PermissionMode != "" && !validPermissionModes[s.PermissionMode] {
+		return fmt.Errorf("permissionMode must be one of default, acceptEdits, plan, bypassPermissions")
+	}
+	if s.TimeoutMinutes < 0 {
+		return fmt.Errorf("timeoutMinutes must not be negative")
+	}
+	return nil
+}
+
+func applyScheduleRequest(s *Schedule, req ScheduleRequest) {
+	if req.Name != nil {
+		s.Name = *req.Name
+	}
+	if req.Cron != nil {
+		s.Cron = strings.TrimSpace(*req.Cron)
+	}
+	if req.Timezone != nil {
+		s.Timezone = *req.Timezone
+	}
+	if req.Prompt != nil {
+		s.Prompt = *req.Prompt
+	}
+	if req.WorkDir != nil {
+		s.WorkDir = *req.WorkDir
+	}
+	if req.Model != nil {
+		s.Model = *req.Model
+	}
+	if req.Backend != nil {
+		s.Backend = *req.Backend
+	}
+	if req.PermissionMode != nil {
+		s.PermissionMode = *req.PermissionMode
+	}
+	if req.AllowedTools != nil {
+		s.AllowedTools = *req.AllowedTools
+	}
+	if req.DisallowedTools != nil {
+		s.DisallowedTools = *req.DisallowedTools
+	}
+	if req.TimeoutMinutes != nil {
+		s.TimeoutMinutes = *req.TimeoutMinutes
+	}
+	if req.Enabled != nil {
+		s.Enabled = *req.Enabled
+	}
+}
+
+// scheduleRunning reports whether a run of id is in progress; caller must hold schedulesMu
+func scheduleRunning(id string) bool {
+	for _, run := range schedules.Runs[id] {
+		if run.Status == "running" {
+			return true
+		}
+	}
+	return false
+}
+
+// appendScheduleRun adds a run to the history, dropping the oldest; caller must hold schedulesMu
+func appendScheduleRun(run ScheduleRun) {
+	runs := append(schedules.Runs[run.ScheduleID], run)
+	if len(runs) > maxScheduleRuns {
+		runs = runs[len(runs)-maxScheduleRuns:]
+	}
+	schedules.Runs[run.ScheduleID] = runs
+}
+
+// updateScheduleRun replaces a run in the history and persists it
+func updateScheduleRun(run ScheduleRun) {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	runs := schedules.Runs[run.ScheduleID]
+	for i := range runs {
+		if runs[i].ID == run.ID {
+			runs[i] = run
+		}
+	}
+	if err := saveSchedules(); err != nil {
+		log.Printf("[Scheduler] Failed to save run history: %v", err)
+	}
+}
+
+// startScheduleRun records a new run and launches it unless the previous run is still going
+// A run started by a ticket reports its outcome there, including when it's skipped
+func startScheduleRun(s Schedule, trigger string, ticket *TicketRef) ScheduleRun {
+	run := ScheduleRun{
+		ID:         generateID(),
+		ScheduleID: s.ID,
+		Trigger:    trigger,
+		Status:     "running",
+		StartedAt:  time.Now(),
+		Ticket:     ticket,
+	}
+
+	schedulesMu.Lock()
+	if scheduleRunning(s.ID) {
+		run.Status = "skipped"
+		run.Error = "previous run still in progress"
+	} else if err := checkChatLimits(s.Owner); err != nil {
+		run.Status = "skipped"
+		run.Error = err.Error()
+	} else if err := checkBudget(s.WorkDir); err != nil {
+		run.Status = "skipped"
+		run.Error = err.Error()
+	} else if err := checkResources(s.WorkDir); err != nil {
+		run.Status = "skipped"
+		run.Error = err.Error()
+	}
+	if run.Status == "skipped" {
+		finished := run.StartedAt
+		run.FinishedAt = &finished
+	}
+	appendScheduleRun(run)
+	if err := saveSchedules(); err != nil {
+		log.Printf("[Scheduler] Failed to save run history: %v", err)
+	}
+	schedulesMu.Unlock()
+
+	if run.Status == "skipped" {
+		log.Printf("[Scheduler] Skipped %q (%s): %s", s.Name, s.ID, run.Error)
+		go reportTicketRun(s, run)
+		return run
+	}
+	go executeScheduleRun(s, run)
+	return run
+}
+
+// executeScheduleRun runs claude headlessly and records the outcome
+// The run is a normal claude session owned by the schedule's owner
+func executeScheduleRun(s Schedule, run ScheduleRun) {
+	user := userByID(s.Owner)
+	mode := s.PermissionMode
+	if mode == "" {
+		mode = getServerConfig().Claude.PermissionMode
+	}
+	model := s.Model
+	if model == "" {
+		model = getServerConfig().Claude.DefaultModel
+	}
+	args := claudeArgs(mode, model)
+	if len(s.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(s.AllowedTools, ","))
+	}
+	if len(s.DisallowedTools) > 0 {
+		args = append(args, "--disallowedTools", strings.Join(s.DisallowedTools, ","))
+	}
+	args = append(args, s.Prompt)
+
+	timeout := defaultScheduleTimeout
+	if s.TimeoutMinutes > 0 {
+		timeout = time.Duration(s.TimeoutMinutes) * time.Minute
+	}
+
+	finish := func(status string, errMsg string) {
+		finished := time.Now()
+		run.FinishedAt = &finished
+		run.Status = status
+		run.Error = errMsg
+		updateScheduleRun(run)
+	}
+
+	cmd, stopBackend, err := claudeCommand(s.WorkDir, s.Backend, args)
+	if err != nil {
+		finish("error", err.Error())
+		return
+	}
+	defer stopBackend()
+	cmd.Env = os.Environ()
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		finish("error", fmt.Sprintf("Failed to create stdout pipe: %v", err))
+		return
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		finish("error", fmt.Sprintf("Failed to create stderr pipe: %v", err))
+		return
+	}
+	startInProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		finish("error", fmt.Sprintf("Failed to start claude command: %v", err))
+		return
+	}
+	limitProcessResources(cmd)
+
+	startTime := time.Now()
+	processID := getNextProcessID()
+	info := &ProcessInfo{
+		Interrupt: func() error { return killProcessGroup(cmd) },
+		WorkDir:   s.WorkDir,
+		StartTime: startTime.Unix(),
+		Mode:      "schedule",
+		Backend:   s.Backend,
+		Owner:     s.Owner,
+	}
+	if runsLocally(s.WorkDir, s.Backend) {
+		info.pid = cmd.Process.Pid
+	}
+	registerProcess(processID, info)
+	run.ProcessID = processID
+	updateScheduleRun(run)
+
+	log.Printf("[Scheduler] Running %q (%s) in %s, processId=%d", s.Name, s.ID, s.WorkDir, processID)
+	recordAudit(AuditEntry{
+		Time:    startTime,
+		User:    auditUser(user),
+		Action:  "chat.execute",
+		Target:  s.ID,
+		WorkDir: s.WorkDir,
+		Details: map[string]string{"transport": "schedule", "trigger": run.Trigger, "prompt": auditPrompt(s.Prompt)},
+	})
+	fireChatStarted(user, "schedule", "", s.WorkDir, s.Prompt)
+
+	timer := time.AfterFunc(timeout, func() {
+		log.Printf("[Scheduler] %q (%s) exceeded %s, killing processId=%d", s.Name, s.ID, timeout, processID)
+		killProcessGroup(cmd)
+	})
+	defer timer.Stop()
+
+	var lastResult *resultEvent
+	var sessionID string
+	diagnostics := newDiagnosticRun("schedule", processID, s.WorkDir, s.Owner)
+	stdoutDone := make(chan struct{})
+	go func() {
+		defer close(stdoutDone)
+		scanner := bufio.NewScanner(stdout)
+		buf := make([]byte, 0, 64*1024)
+		scanner.Buffer(buf, 1024*1024)
+		inputNotified := false
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+			recordStreamBytes("schedule", len(line))
+			if newSessionID := extractInitSessionID(line); newSessionID != "" && sessionID == "" {
+				sessionID = newSessionID
+				recordSessionOwner(sessionID, s.Owner)
+				processLock.Lock()
+				info.SessionID = sessionID
+				processLock.Unlock()
+				SetSessionLoading(sessionID, true)
+				SetSessionProcessID(sessionID, &processID)
+			}
+			if result := parseResultEvent(line); result != nil {
+				recordResultUsage(result)
+				recordProjectCost(s.Owner, s.WorkDir, result)
+				lastResult = result
+				diagnostics.checkResult(result)
+			}
+			trackInputRequest(s.Owner, sessionID, line, &inputNotified)
+			trackTodos(sessionID, s.WorkDir, line)
+			trackContextUsage(sessionID, line)
+			recordProcessOutput(processID, line)
+		}
+	}()
+
+	// Keep the tail of stderr for the run's error message
+	var stderrTail []string
+	stderrDone := make(chan struct{})
+	go func() {
+		defer close(stderrDone)
+		scanner := bufio.NewScanner(stderr)
+		for scanner.Scan() {
+			if line := scanner.Text(); line != "" {
+				diagnostics.check(line)
+				stderrTail = append(stderrTail, line)
+				if len(stderrTail) > scheduleStderrLines {
+					stderrTail = stderrTail[1:]
+				}
+			}
+		}
+	}()
+
+	// Wait closes the pipes, so let the readers drain them first
+	<-stdoutDone
+	<-stderrDone
+	err = cmd.Wait()
+	unregisterProcess(processID)
+	if sessionID != "" {
+		SetSessionLoading(sessionID, false)
+		SetSessionProcessID(sessionID, nil)
+	}
+
+	duration := time.Since(startTime)
+	outcome := chatOutcome(err)
+	recordChatFinished("schedule", startTime, outcome)
+	notifyChatFinished(s.Owner, sessionID, s.WorkDir, outcome, duration)
+	fireChatFinished(user, "schedule", sessionID, s.WorkDir, outcome, duration, lastResult)
+
+	run.SessionID = sessionID
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+		if len(stderrTail) > 0 {
+			errMsg += ": " + strings.Join(stderrTail, "\n")
+		}
+	}
+	if lastResult != nil {
+		run.CostUSD = lastResult.TotalCostUSD
+		run.NumTurns = lastResult.NumTurns
+		if lastResult.IsError {
+			outcome = "error"
+			if errMsg == "" {
+				errMsg = lastResult.Subtype
+			}
+		} else {
+			run.Summary = lastResult.Result
+			if len(run.Summary) > scheduleSummaryLimit {
+				run.Summary = run.Summary[:scheduleSummaryLimit] + "…"
+			}
+		}
+	}
+	finish(outcome, errMsg)
+	log.Printf("[Scheduler] %q (%s) finished: %s in %s", s.Name, s.ID, outcome, duration.Round(time.Second))
+	reportTicketRun(s, run)
+}
+
+// StartScheduler runs due schedules at the top of every minute
+// Runs missed while the server was down are not caught up
+func StartScheduler() {
+	schedulerOnce.Do(func() {
+		go func() {
+			for {
+				now := time.Now()
+				tick := now.Truncate(time.Minute).Add(time.Minute)
+				time.Sleep(tick.Sub(now))
+				runDueSchedules(tick)
+			}
+		}()
+	})
+}
+
+// runDueSchedules starts every enabled schedule whose cron expression matches tick
+func runDueSchedules(tick time.Time) {
+	schedulesMu.Lock()
+	if err := loadSchedules(); err != nil {
+		schedulesMu.Unlock()
+		log.Printf("[Scheduler] Failed to load schedules: %v", err)
+		return
+	}
+	var due []Schedule
+	for _, s := range schedules.Schedules {
+		if !s.Enabled {
+			continue
+		}
+		cron, err := parseCron(s.Cron)
+		if err != nil {
+			continue
+		}
+		if cron.matches(tick.In(s.location())) {
+			due = append(due, s)
+		}
+	}
+	schedulesMu.Unlock()
+
+	for _, s := range due {
+		startScheduleRun(s, "cron", nil)
+	}
+}
+
+// visibleSchedule looks up a schedule the user may access; caller must hold schedulesMu
+// It writes the error response and returns -1 when not found
+func visibleSchedule(c *gin.Context) int {
+	if err := loadSchedules(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load schedules", err.Error())
+		return -1
+	}
+	i := findSchedule(c.Param("id"))
+	if i < 0 || !userCanAccessOwner(currentUser(c), schedules.Schedules[i].Owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Schedule not found")
+		return -1
+	}
+	return i
+}
+
+// ListSchedules handles GET /api/schedules
+func ListSchedules(c *gin.Context) {
+	user := currentUser(c)
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	if err := loadSchedules(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load schedules", err.Error())
+		return
+	}
+	list := make([]ScheduleInfo, 0, len(schedules.Schedules))
+	for _, s := range schedules.Schedules {
+		if userCanAccessOwner(user, s.Owner) {
+			list = append(list, s.info())
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"schedules": list})
+}
+
+// CreateSchedule handles POST /api/schedules
+func CreateSchedule(c *gin.Context) {
+	user := currentUser(c)
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Cron == nil || req.Prompt == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "cron and prompt are required")
+		return
+	}
+	s := Schedule{ID: generateID(), Enabled: true, Owner: ownerID(user), CreatedAt: time.Now()}
+	applyScheduleRequest(&s, req)
+	if s.Name == "" {
+		s.Name = s.Cron
+	}
+	if err := validateSchedule(s, user); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	if err := loadSchedules(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load schedules", err.Error())
+		return
+	}
+	schedules.Schedules = append(schedules.Schedules, s)
+	if err := saveSchedules(); err != nil {
+		schedules.Schedules = schedules.Schedules[:len(schedules.Schedules)-1]
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save schedules", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, s.info())
+}
+
+// GetSchedule handles GET /api/schedules/:id
+func GetSchedule(c *gin.Context) {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	i := visibleSchedule(c)
+	if i < 0 {
+		return
+	}
+	c.JSON(http.StatusOK, schedules.Schedules[i].info())
+}
+
+// UpdateSchedule handles PUT /api/schedules/:id (including enable/disable)
+func UpdateSchedule(c *gin.Context) {
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	i := visibleSchedule(c)
+	if i < 0 {
+		return
+	}
+	s := schedules.Schedules[i]
+	applyScheduleRequest(&s, req)
+	// The owner's project roots apply, even when an admin edits the schedule
+	if err := validateSchedule(s, userByID(s.Owner)); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	previous := schedules.Schedules[i]
+	schedules.Schedules[i] = s
+	if err := saveSchedules(); err != nil {
+		schedules.Schedules[i] = previous
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save schedules", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, s.info())
+}
+
+// DeleteSchedule handles DELETE /api/schedules/:id
+// A run in progress is left to finish
+func DeleteSchedule(c *gin.Context) {
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	i := visibleSchedule(c)
+	if i < 0 {
+		return
+	}
+	previous := schedules.Schedules
+	id := schedules.Schedules[i].ID
+	runs := schedules.Runs[id]
+	schedules.Schedules = append(append([]Schedule(nil), previous[:i]...), previous[i+1:]...)
+	delete(schedules.Runs, id)
+	if err := saveSchedules(); err != nil {
+		schedules.Schedules = previous
+		schedules.Runs[id] = runs
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save schedules", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RunSchedule handles POST /api/schedules/:id/run, starting a run immediately
+func RunSchedule(c *gin.Context) {
+	schedulesMu.Lock()
+	i := visibleSchedule(c)
+	if i < 0 {
+		schedulesMu.Unlock()
+		return
+	}
+	s := schedules.Schedules[i]
+	schedulesMu.Unlock()
+
+	run := startScheduleRun(s, "manual", nil)
+	status := http.StatusAccepted
+	if run.Status == "skipped" {
+		status = http.StatusConflict
+	}
+	c.JSON(status, run)
+}
+
+// GetScheduleRuns handles GET /api/schedules/:id/runs
+// Query parameters:
+//   - limit: maximum runs to return, newest first (default: all kept runs)
+func GetScheduleRuns(c *gin.Context) {
+	limit := maxScheduleRuns
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+			return
+		}
+		limit = n
+	}
+
+	schedulesMu.Lock()
+	defer schedulesMu.Unlock()
+	i := visibleSchedule(c)
+	if i < 0 {
+		return
+	}
+	runs := schedules.Runs[schedules.Schedules[i].ID]
+	out := make([]ScheduleRun, 0, len(runs))
+	for j := len(runs) - 1; j >= 0 && len(out) < limit; j-- {
+		out = append(out, runs[j])
+	}
+	c.JSON(http.StatusOK, gin.H{"runs": out})
+}
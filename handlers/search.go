@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchSnippetContext is how many characters of context a snippet keeps before the first match
+const searchSnippetContext = 60
+
+// searchSnippetLength caps a snippet's length in characters
+const searchSnippetLength = 200
+
+// SnippetPart is a piece of a search snippet; Match marks the highlighted parts
+type SnippetPart struct {
+	Text  string `json:"text"`
+	Match bool   `json:"match,omitempty"`
+}
+
+// SessionSearchMatch is a message containing the query
+type SessionSearchMatch struct {
+	UUID      string        `json:"uuid"`
+	Index     int           `json:"index"` // position among the session's messages, as in GET /api/session/:id/history
+	Type      string        `json:"type"`
+	Timestamp string        `json:"timestamp"`
+	Count     int           `json:"count"` // occurrences in the message
+	Snippet   []SnippetPart `json:"snippet"`
+}
+
+// SessionSearchResponse is the response of GET /api/session/:id/search
+type SessionSearchResponse struct {
+	SessionID     string               `json:"sessionId"`
+	Query         string               `json:"query"`
+	Matches       []SessionSearchMatch `json:"matches"`
+	Total         int                  `json:"total"`         // matching messages, including those past limit
+	TotalMessages int                  `json:"totalMessages"` // messages in the session
+}
+
+// SearchSession handles GET /api/session/:id/search
+// Finds messages containing q (case-insensitive) without sending the whole history
+// Query parameters:
+//   - q: text to find (required)
+//   - limit: maximum matches to return (default 50, max 500)
+func SearchSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "q is required")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
+		return
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	path := findSessionFile(sessionID)
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to open session file", err.Error())
+		return
+	}
+	defer file.Close()
+
+	needle := foldRunes(query)
+	resp := SessionSearchResponse{SessionID: sessionID, Query: query, Matches: []SessionSearchMatch{}}
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg Message
+			if json.Unmarshal(line, &msg) == nil && isHistoryMessage(msg.Type) {
+				index := resp.TotalMessages
+				resp.TotalMessages++
+				text := []rune(messageSearchText(msg.Message["content"]))
+				if positions := findFolded(foldRunes(string(text)), needle); len(positions) > 0 {
+					resp.Total++
+					if len(resp.Matches) < limit {
+						resp.Matches = append(resp.Matches, SessionSearchMatch{
+							UUID:      msg.UUID,
+							Index:     index,
+							Type:      msg.Type,
+							Timestamp: msg.Timestamp,
+							Count:     len(positions),
+							Snippet:   searchSnippet(text, positions, len(needle)),
+						})
+					}
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// messageSearchText returns the searchable text of a message: text blocks and tool results
+func messageSearchText(content interface{}) string {
+	switch v := content.(type) {
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			block, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch block["type"] {
+			case "text":
+				if s, ok := block["text"].(string); ok {
+					parts = append(parts, s)
+				}
+			case "tool_result":
+				if s := messageSearchText(block["content"]); s != "" {
+					parts = append(parts, s)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// foldRunes lowercases s rune by rune, so indexes match the original's runes
+func foldRunes(s string) []rune {
+	runes := []rune(s)
+	for i, r := range runes {
+		runes[i] = unicode.ToLower(r)
+	}
+	return runes
+}
+
+// findFolded returns the non-overlapping rune positions of needle in haystack
+func findFolded(haystack []rune, needle []rune) []int {
+	var positions []int
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			positions = append(positions, i)
+			i += len(needle) - 1
+		}
+	}
+	return positions
+}
+
+// searchSnippet cuts a window around the first match and marks every match inside it
+func searchSnippet(text []rune, positions []int, length int) []SnippetPart {
+	start := positions[0] - searchSnippetContext
+	if start < 0 {
+		start = 0
+	}
+	end := start + searchSnippetLength
+	if end < positions[0]+length {
+		end = positions[0] + length
+	}
+	if end > len(text) {
+		end = len(text)
+	}
+
+	var parts []SnippetPart
+	add := func(from, to int, match bool) {
+		if from >= to {
+			return
+		}
+		s := strings.Join(strings.Fields(string(text[from:to])), " ")
+		if !match && from > start && unicode.IsSpace(text[from]) {
+			s = " " + s
+		}
+		if !match && to < end && unicode.IsSpace(text[to-1]) && s != " " {
+			s += " "
+		}
+		if s != "" {
+			parts = append(parts, SnippetPart{Text: s, Match: match})
+		}
+	}
+	if start > 0 {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	cursor := start
+	for _, pos := range positions {
+		if pos < start {
+			continue
+		}
+		if pos+length > end {
+			break
+		}
+		add(cursor, pos, false)
+		add(pos, pos+length, true)
+		cursor = pos + length
+	}
+	add(cursor, end, false)
+	if end < len(text) {
+		parts = append(parts, SnippetPart{Text: "…"})
+	}
+	return parts
+}
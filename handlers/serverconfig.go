@@ -0,0 +1,583 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig is the server configuration loaded from config.yaml
+// Precedence: built-in defaults < config file < environment < command-line flags
+type ServerConfig struct {
+	Port           int           `yaml:"port" json:"port"`
+	Host           string        `yaml:"host" json:"host"`
+	Listen         string        `yaml:"listen" json:"listen,omitempty"`
+	BasePath       string        `yaml:"basePath" json:"basePath"`
+	TrustedProxies []string      `yaml:"trustedProxies" json:"trustedProxies"`
+	AllowedOrigins []string      `yaml:"allowedOrigins" json:"allowedOrigins"`
+	LogDir         string        `yaml:"logDir" json:"logDir"`
+	Logging        LoggingConfig `yaml:"logging" json:"logging"`
+	DataDir        string        `yaml:"dataDir" json:"dataDir"`
+	StaticDir      string        `yaml:"staticDir" json:"staticDir,omitempty"`
+	// Locale is the language of server-generated strings when a request doesn't ask for one
+	Locale string `yaml:"locale" json:"locale"`
+
+	TLS     TLSConfig     `yaml:"tls" json:"tls"`
+	HTTP    HTTPConfig    `yaml:"http" json:"http"`
+	Auth    AuthConfig    `yaml:"auth" json:"auth"`
+	Claude  ClaudeConfig  `yaml:"claude" json:"claude"`
+	Limits  LimitsConfig  `yaml:"limits" json:"limits"`
+	Uploads UploadsConfig `yaml:"uploads" json:"uploads"`
+	Push    PushConfig    `yaml:"push" json:"push"`
+
+	// Redaction masks secrets in shared transcripts
+	Redaction RedactionConfig `yaml:"redaction" json:"redaction"`
+	// Backup uploads archives of ~/.claude and the data directory on a schedule
+	Backup BackupConfig `yaml:"backup" json:"backup"`
+	// HookIngest lets claude hook scripts post their events to the server
+	HookIngest HookIngestConfig `yaml:"hookIngest" json:"hookIngest"`
+	// Digest mails a summary of activity daily or weekly
+	Digest DigestConfig `yaml:"digest" json:"digest"`
+	// Integrations connect issue trackers and messengers
+	Integrations IntegrationsConfig `yaml:"integrations" json:"integrations"`
+
+	// AllowedRoots restricts every user (including admins) to these directories
+	AllowedRoots []string `yaml:"allowedRoots" json:"allowedRoots"`
+
+	// RemoteHosts are SSH targets usable as host:path working directories
+	RemoteHosts []RemoteHost `yaml:"remoteHosts" json:"remoteHosts,omitempty"`
+	// Backends are containers chats can run claude in instead of the server's environment
+	Backends []ExecBackend `yaml:"backends" json:"backends,omitempty"`
+	// AgentCLIs are other coding agent CLIs selectable as a chat backend
+	AgentCLIs []AgentCLI `yaml:"agentCLIs" json:"agentCLIs,omitempty"`
+	// LanguageServers back the file viewer's hover, go-to-definition, and diagnostics
+	LanguageServers []LanguageServer `yaml:"languageServers" json:"languageServers,omitempty"`
+}
+
+// TLSConfig is the tls section of config.yaml
+type TLSConfig struct {
+	Disabled        bool     `yaml:"disabled" json:"disabled"`
+	Cert            string   `yaml:"cert" json:"cert"`
+	Key             string   `yaml:"key" json:"key"`
+	AutocertDomains []string `yaml:"autocertDomains" json:"autocertDomains"`
+	AutocertCache   string   `yaml:"autocertCache" json:"autocertCache"`
+	AutocertEmail   string   `yaml:"autocertEmail" json:"autocertEmail,omitempty"`
+}
+
+// HTTPConfig tunes connection handling (timeouts in seconds, 0 = none)
+type HTTPConfig struct {
+	ReadHeaderTimeout int `yaml:"readHeaderTimeout" json:"readHeaderTimeout"`
+	ReadTimeout       int `yaml:"readTimeout" json:"readTimeout"`
+	// WriteTimeout bounds each response; SSE and WebSocket endpoints are exempt
+	WriteTimeout int `yaml:"writeTimeout" json:"writeTimeout"`
+	// IdleTimeout closes keep-alive connections with no request in flight
+	IdleTimeout int `yaml:"idleTimeout" json:"idleTimeout"`
+	// MaxConcurrentStreams limits HTTP/2 streams per connection
+	MaxConcurrentStreams int  `yaml:"maxConcurrentStreams" json:"maxConcurrentStreams"`
+	DisableHTTP2         bool `yaml:"disableHTTP2" json:"disableHTTP2"`
+	// MaxBodyMB caps request bodies (0 = unlimited); uploads are capped by uploads.maxSizeMB instead
+	MaxBodyMB int `yaml:"maxBodyMB" json:"maxBodyMB"`
+}
+
+// LoggingConfig controls log level, format, and file rotation
+type LoggingConfig struct {
+	Level      string `yaml:"level" json:"level"`   // debug, info, warn, error
+	Format     string `yaml:"format" json:"format"` // console format: json or text (files are always JSON)
+	MaxSizeMB  int    `yaml:"maxSizeMB" json:"maxSizeMB"`
+	MaxBackups int    `yaml:"maxBackups" json:"maxBackups"`
+}
+
+// ClaudeConfig holds defaults applied to every claude CLI run
+type ClaudeConfig struct {
+	DefaultModel   string `yaml:"defaultModel" json:"defaultModel"`
+	PermissionMode string `yaml:"permissionMode" json:"permissionMode"`
+	// ContextWindow sizes the context meter (models with a [1m] suffix use 1M)
+	ContextWindow int `yaml:"contextWindow" json:"contextWindow"`
+	// CompactAtPercent is the context usage at which compaction is suggested (0 = never)
+	CompactAtPercent int `yaml:"compactAtPercent" json:"compactAtPercent"`
+	// ChatBackend runs chats with the claude CLI ("cli"), a claude process kept per session ("stream"),
+	// or the Anthropic API ("api")
+	ChatBackend string `yaml:"chatBackend" json:"chatBackend"`
+	// SummaryModel writes session summaries (POST /api/session/:id/summarize)
+	SummaryModel string `yaml:"summaryModel" json:"summaryModel"`
+	// Pricing estimates the cost of running chats for usage events, keyed by a part of the model name
+	Pricing map[string]ModelPrice `yaml:"pricing" json:"pricing"`
+	// ApprovalTools are the tools held for approval in chats started with approval mode
+	ApprovalTools []string `yaml:"approvalTools" json:"approvalTools"`
+	// ApprovalTimeoutSeconds is how long a held tool call waits for a decision before it is denied
+	ApprovalTimeoutSeconds int `yaml:"approvalTimeoutSeconds" json:"approvalTimeoutSeconds"`
+	// API configures the api chat backend; the key is read from ANTHROPIC_API_KEY
+	API AnthropicAPIConfig `yaml:"api" json:"api"`
+	// StreamInput configures the stream chat backend
+	StreamInput StreamInputConfig `yaml:"streamInput" json:"streamInput"`
+}
+
+// AnthropicAPIConfig configures the api chat backend
+type AnthropicAPIConfig struct {
+	BaseURL   string `yaml:"baseURL" json:"baseURL"`
+	Model     string `yaml:"model" json:"model"`
+	MaxTokens int    `yaml:"maxTokens" json:"maxTokens"`
+	// SummaryModel is the API model name used for session summaries
+	SummaryModel string `yaml:"summaryModel" json:"summaryModel"`
+}
+
+// LimitsConfig holds concurrency limits (0 = unlimited)
+type LimitsConfig struct {
+	MaxConcurrentChats int `yaml:"maxConcurrentChats" json:"maxConcurrentChats"`
+	MaxChatsPerUser    int `yaml:"maxChatsPerUser" json:"maxChatsPerUser"`
+	// SessionListLimit is how many sessions GET /api/sessions returns without a limit parameter
+	SessionListLimit int `yaml:"sessionListLimit" json:"sessionListLimit"`
+	// Resources are checked before a chat or terminal starts
+	Resources ResourceGuardConfig `yaml:"resources" json:"resources"`
+	// Processes bound how long terminals and chat runs live and what they may use
+	Processes ProcessLimitsConfig `yaml:"processes" json:"processes"`
+}
+
+// UploadsConfig is the upload policy
+type UploadsConfig struct {
+	MaxSizeMB        int      `yaml:"maxSizeMB" json:"maxSizeMB"`
+	AllowedTypes     []string `yaml:"allowedTypes" json:"allowedTypes"`
+	RetentionMinutes int      `yaml:"retentionMinutes" json:"retentionMinutes"`
+	// MaxArchiveMB caps archives sent to POST /api/upload/extract, MaxExtractedMB what they unpack to
+	MaxArchiveMB   int `yaml:"maxArchiveMB" json:"maxArchiveMB"`
+	MaxExtractedMB int `yaml:"maxExtractedMB" json:"maxExtractedMB"`
+}
+
+// PushConfig configures Web Push notifications
+type PushConfig struct {
+	// Subject is the VAPID contact (mailto: or https: URL) sent to push services
+	Subject string `yaml:"subject" json:"subject"`
+}
+
+// RedactionConfig adds to and tunes the built-in secret rules
+type RedactionConfig struct {
+	Rules          []RedactionRuleConfig `yaml:"rules" json:"rules,omitempty"`
+	DisableBuiltin []string              `yaml:"disableBuiltin" json:"disableBuiltin,omitempty"` // built-in rule names to turn off
+	// Random-looking tokens of at least EntropyMinLength characters with EntropyMinBits
+	// bits of Shannon entropy per character are masked too
+	DisableEntropy   bool    `yaml:"disableEntropy" json:"disableEntropy"`
+	EntropyMinLength int     `yaml:"entropyMinLength" json:"entropyMinLength"`
+	EntropyMinBits   float64 `yaml:"entropyMinBits" json:"entropyMinBits"`
+}
+
+// RedactionRuleConfig is a named regular expression whose matches are masked
+type RedactionRuleConfig struct {
+	Name    string `yaml:"name" json:"name"`
+	Pattern string `yaml:"pattern" json:"pattern"`
+}
+
+// DefaultServerConfig returns the built-in defaults
+func DefaultServerConfig() ServerConfig {
+	return ServerConfig{
+		Port:   43210,
+		Host:   "127.0.0.1",
+		LogDir: "./logs",
+		Locale: "en",
+		Logging: LoggingConfig{
+			Level:      "info",
+			Format:     "json",
+			MaxSizeMB:  50,
+			MaxBackups: 5,
+		},
+		TLS: TLSConfig{
+			Cert:          "cert.pem",
+			Key:           "key.pem",
+			AutocertCache: "./certs",
+		},
+		Limits: LimitsConfig{
+			SessionListLimit: 50,
+			Processes:        ProcessLimitsConfig{WarnSeconds: 60, MaxTerminalsPerUser: 10},
+		},
+		HTTP: HTTPConfig{
+			ReadHeaderTimeout:    10,
+			IdleTimeout:          120,
+			MaxConcurrentStreams: 250,
+			MaxBodyMB:            10,
+		},
+		Auth: AuthConfig{Mode: "none"},
+		Claude: ClaudeConfig{
+			PermissionMode:   "bypassPermissions",
+			ContextWindow:    200000,
+			CompactAtPercent: 80,
+			ChatBackend:      "cli",
+			SummaryModel:     "haiku",
+			Pricing: map[string]ModelPrice{
+				"opus":   {Input: 15, Output: 75},
+				"sonnet": {Input: 3, Output: 15},
+				"haiku":  {Input: 1, Output: 5},
+			},
+			ApprovalTools:          []string{"Bash", "Write", "Edit", "MultiEdit", "NotebookEdit"},
+			ApprovalTimeoutSeconds: 600,
+			API: AnthropicAPIConfig{
+				BaseURL:      "https://api.anthropic.com",
+				Model:        "claude-sonnet-4-5",
+				MaxTokens:    8192,
+				SummaryModel: "claude-haiku-4-5",
+			},
+			StreamInput: StreamInputConfig{
+				IdleMinutes:  10,
+				MaxProcesses: 8,
+			},
+		},
+		Redaction: RedactionConfig{
+			EntropyMinLength: 32,
+			EntropyMinBits:   4.2,
+		},
+		Uploads: UploadsConfig{
+			MaxSizeMB:        10,
+			AllowedTypes:     []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+			RetentionMinutes: 60,
+			MaxArchiveMB:     100,
+			MaxExtractedMB:   1024,
+		},
+	}
+}
+
+// DefaultConfigPath returns ~/.config/claude-web-ui/config.yaml
+func DefaultConfigPath() string {
+	return serverDataPath("config.yaml")
+}
+
+// LoadServerConfig reads a YAML config file over cfg
+// A missing file is not an error unless required is set
+func LoadServerConfig(path string, cfg *ServerConfig, required bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !required {
+			return nil
+		}
+		return err
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// envList splits a comma-separated environment value
+func envList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ApplyEnv overrides cfg with GREYZONE_* environment variables
+func (cfg *ServerConfig) ApplyEnv() error {
+	str := map[string]*string{
+		"GREYZONE_HOST":                    &cfg.Host,
+		"GREYZONE_LISTEN":                  &cfg.Listen,
+		"GREYZONE_BASE_PATH":               &cfg.BasePath,
+		"GREYZONE_LOG_DIR":                 &cfg.LogDir,
+		"GREYZONE_LOG_LEVEL":               &cfg.Logging.Level,
+		"GREYZONE_LOG_FORMAT":              &cfg.Logging.Format,
+		"GREYZONE_DATA_DIR":                &cfg.DataDir,
+		"GREYZONE_STATIC_DIR":              &cfg.StaticDir,
+		"GREYZONE_TLS_CERT":                &cfg.TLS.Cert,
+		"GREYZONE_TLS_KEY":                 &cfg.TLS.Key,
+		"GREYZONE_AUTH":                    &cfg.Auth.Mode,
+		"GREYZONE_OIDC_ISSUER":             &cfg.Auth.OIDCIssuer,
+		"GREYZONE_OIDC_CLIENT_ID":          &cfg.Auth.OIDCClientID,
+		"GREYZONE_OIDC_CLIENT_SECRET":      &cfg.Auth.OIDCClientSecret,
+		"GREYZONE_OIDC_REDIRECT_URL":       &cfg.Auth.OIDCRedirectURL,
+		"GREYZONE_DEFAULT_MODEL":           &cfg.Claude.DefaultModel,
+		"GREYZONE_PERMISSION_MODE":         &cfg.Claude.PermissionMode,
+		"GREYZONE_CHAT_BACKEND":            &cfg.Claude.ChatBackend,
+		"GREYZONE_AUTOCERT_CACHE":          &cfg.TLS.AutocertCache,
+		"GREYZONE_AUTOCERT_EMAIL":          &cfg.TLS.AutocertEmail,
+		"GREYZONE_PUSH_SUBJECT":            &cfg.Push.Subject,
+		"GREYZONE_BACKUP_PASSWORD":         &cfg.Backup.Target.Password,
+		"GREYZONE_SMTP_PASSWORD":           &cfg.Digest.SMTP.Password,
+		"GREYZONE_HOOK_SECRET":             &cfg.HookIngest.Secret,
+		"GREYZONE_GITHUB_TOKEN":            &cfg.Integrations.GitHub.Token,
+		"GREYZONE_LINEAR_API_KEY":          &cfg.Integrations.Linear.APIKey,
+		"GREYZONE_LINEAR_WEBHOOK_SECRET":   &cfg.Integrations.Linear.WebhookSecret,
+		"GREYZONE_JIRA_API_TOKEN":          &cfg.Integrations.Jira.APIToken,
+		"GREYZONE_JIRA_WEBHOOK_SECRET":     &cfg.Integrations.Jira.WebhookSecret,
+		"GREYZONE_SLACK_BOT_TOKEN":         &cfg.Integrations.Slack.BotToken,
+		"GREYZONE_SLACK_SIGNING_SECRET":    &cfg.Integrations.Slack.SigningSecret,
+		"GREYZONE_TELEGRAM_BOT_TOKEN":      &cfg.Integrations.Telegram.BotToken,
+		"GREYZONE_TELEGRAM_WEBHOOK_SECRET": &cfg.Integrations.Telegram.WebhookSecret,
+		"GREYZONE_PASTE_TOKEN":             &cfg.Integrations.Paste.Token,
+	}
+	for key, dst := range str {
+		if v := os.Getenv(key); v != "" {
+			*dst = v
+		}
+	}
+
+	if cfg.Integrations.GitHub.Token == "" {
+		cfg.Integrations.GitHub.Token = os.Getenv("GITHUB_TOKEN")
+	}
+
+	lists := map[string]*[]string{
+		"GREYZONE_TRUSTED_PROXIES":   &cfg.TrustedProxies,
+		"GREYZONE_ALLOWED_ORIGINS":   &cfg.AllowedOrigins,
+		"GREYZONE_AUTOCERT_DOMAINS":  &cfg.TLS.AutocertDomains,
+		"GREYZONE_OIDC_ADMIN_EMAILS": &cfg.Auth.OIDCAdminEmails,
+		"GREYZONE_ALLOWED_ROOTS":     &cfg.AllowedRoots,
+	}
+	for key, dst := range lists {
+		if v := os.Getenv(key); v != "" {
+			*dst = envList(v)
+		}
+	}
+
+	ints := map[string]*int{
+		"GREYZONE_PORT":                   &cfg.Port,
+		"GREYZONE_MAX_CONCURRENT_CHATS":   &cfg.Limits.MaxConcurrentChats,
+		"GREYZONE_MAX_CHATS_PER_USER":     &cfg.Limits.MaxChatsPerUser,
+		"GREYZONE_TERMINAL_IDLE_MINUTES":  &cfg.Limits.Processes.TerminalIdleMinutes,
+		"GREYZONE_MAX_TERMINALS_PER_USER": &cfg.Limits.Processes.MaxTerminalsPerUser,
+		"GREYZONE_CHAT_MAX_MINUTES":       &cfg.Limits.Processes.ChatMaxMinutes,
+		"GREYZONE_UPLOAD_MAX_SIZE_MB":     &cfg.Uploads.MaxSizeMB,
+		"GREYZONE_WRITE_TIMEOUT":          &cfg.HTTP.WriteTimeout,
+		"GREYZONE_IDLE_TIMEOUT":           &cfg.HTTP.IdleTimeout,
+		"GREYZONE_MAX_BODY_MB":            &cfg.HTTP.MaxBodyMB,
+	}
+	for key, dst := range ints {
+		if v := os.Getenv(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid number %q", key, v)
+			}
+			*dst = n
+		}
+	}
+
+	if v := os.Getenv("GREYZONE_NO_TLS"); v != "" {
+		cfg.TLS.Disabled = v == "1" || strings.EqualFold(v, "true")
+	}
+	return nil
+}
+
+// Validate checks values that would otherwise fail later at runtime
+func (cfg *ServerConfig) Validate() error {
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	if cfg.Claude.PermissionMode != "" && !validPermissionModes[cfg.Claude.PermissionMode] {
+		return fmt.Errorf("claude.permissionMode must be one of default, acceptEdits, plan, bypassPermissions")
+	}
+	if cfg.Claude.ContextWindow <= 0 {
+		return fmt.Errorf("claude.contextWindow must be positive")
+	}
+	if cfg.Claude.CompactAtPercent < 0 || cfg.Claude.CompactAtPercent > 100 {
+		return fmt.Errorf("claude.compactAtPercent must be between 0 and 100")
+	}
+	if !chatBackendNames[cfg.Claude.ChatBackend] && findAgentCLI(cfg.AgentCLIs, cfg.Claude.ChatBackend) == nil {
+		return fmt.Errorf("claude.chatBackend must be cli, stream, api, or the name of one of agentCLIs")
+	}
+	if cfg.Claude.StreamInput.IdleMinutes < 0 || cfg.Claude.StreamInput.MaxProcesses < 0 || cfg.Claude.StreamInput.Warm < 0 {
+		return fmt.Errorf("claude.streamInput values must not be negative")
+	}
+	for model, price := range cfg.Claude.Pricing {
+		if price.Input < 0 || price.Output < 0 {
+			return fmt.Errorf("claude.pricing.%s must not be negative", model)
+		}
+	}
+	if cfg.Claude.ApprovalTimeoutSeconds <= 0 {
+		return fmt.Errorf("claude.approvalTimeoutSeconds must be positive")
+	}
+	if cfg.Claude.ChatBackend == "api" && (cfg.Claude.API.BaseURL == "" || cfg.Claude.API.Model == "" || cfg.Claude.API.MaxTokens <= 0) {
+		return fmt.Errorf("claude.api needs baseURL, model and a positive maxTokens")
+	}
+	if r := cfg.Limits.Resources; cfg.Limits.MaxConcurrentChats < 0 || cfg.Limits.MaxChatsPerUser < 0 ||
+		r.MaxLoadPerCPU < 0 || r.MinFreeMemoryMB < 0 || r.MinFreeDiskMB < 0 || r.MinFreeGPUMemoryMB < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if cfg.Limits.SessionListLimit <= 0 || cfg.Limits.SessionListLimit > maxSessionListLimit {
+		return fmt.Errorf("limits.sessionListLimit must be between 1 and %d", maxSessionListLimit)
+	}
+	if p := cfg.Limits.Processes; p.TerminalIdleMinutes < 0 || p.TerminalMaxMinutes < 0 || p.ChatIdleMinutes < 0 || p.ChatMaxMinutes < 0 ||
+		p.WarnSeconds < 0 || p.MaxMemoryMB < 0 || p.MaxProcesses < 0 || p.MaxTerminalsPerUser < 0 {
+		return fmt.Errorf("limits.processes values must not be negative")
+	}
+	if cfg.HTTP.ReadHeaderTimeout < 0 || cfg.HTTP.ReadTimeout < 0 || cfg.HTTP.WriteTimeout < 0 || cfg.HTTP.IdleTimeout < 0 {
+		return fmt.Errorf("http timeouts must not be negative")
+	}
+	if cfg.HTTP.MaxConcurrentStreams < 0 {
+		return fmt.Errorf("http.maxConcurrentStreams must not be negative")
+	}
+	if cfg.HTTP.MaxBodyMB < 0 {
+		return fmt.Errorf("http.maxBodyMB must not be negative")
+	}
+	if _, err := parseLogLevel(cfg.Logging.Level); err != nil {
+		return err
+	}
+	if cfg.Logging.Format != "json" && cfg.Logging.Format != "text" {
+		return fmt.Errorf("logging.format must be json or text")
+	}
+	if cfg.Uploads.MaxSizeMB <= 0 {
+		return fmt.Errorf("uploads.maxSizeMB must be positive")
+	}
+	if cfg.Uploads.RetentionMinutes <= 0 {
+		return fmt.Errorf("uploads.retentionMinutes must be positive")
+	}
+	if cfg.Uploads.MaxArchiveMB <= 0 || cfg.Uploads.MaxExtractedMB <= 0 {
+		return fmt.Errorf("uploads.maxArchiveMB and uploads.maxExtractedMB must be positive")
+	}
+	for i, origin := range cfg.AllowedOrigins {
+		normalized, err := normalizeOrigin(origin)
+		if err != nil {
+			return fmt.Errorf("allowedOrigins[%d]: %w", i, err)
+		}
+		cfg.AllowedOrigins[i] = normalized
+	}
+	for i, root := range cfg.AllowedRoots {
+		if !filepath.IsAbs(root) {
+			return fmt.Errorf("allowedRoots[%d]: must be an absolute path", i)
+		}
+		cfg.AllowedRoots[i] = filepath.Clean(root)
+	}
+	if !localePattern.MatchString(cfg.Locale) {
+		return fmt.Errorf("locale must be a language code such as en or ko")
+	}
+	if err := validateRedaction(cfg.Redaction); err != nil {
+		return err
+	}
+	if err := validateRemoteHosts(cfg.RemoteHosts); err != nil {
+		return err
+	}
+	if err := validateExecBackends(cfg.Backends); err != nil {
+		return err
+	}
+	if err := validateAgentCLIs(cfg.AgentCLIs); err != nil {
+		return err
+	}
+	if err := validateLanguageServers(cfg.LanguageServers); err != nil {
+		return err
+	}
+	if err := validateGitHubConfig(cfg.Integrations.GitHub); err != nil {
+		return err
+	}
+	if err := validateTicketConfig(cfg.Integrations); err != nil {
+		return err
+	}
+	if err := validateBackupConfig(cfg.Backup); err != nil {
+		return err
+	}
+	if err := validateDigestConfig(cfg.Digest); err != nil {
+		return err
+	}
+	return nil
+}
+
+var (
+	serverConfig     = DefaultServerConfig()
+	serverConfigPath string
+	serverConfigMu   sync.RWMutex
+)
+
+// SetServerConfig installs the effective configuration used by handlers
+func SetServerConfig(cfg ServerConfig, path string) {
+	serverConfigMu.Lock()
+	defer serverConfigMu.Unlock()
+	serverConfig = cfg
+	serverConfigPath = path
+}
+
+// getServerConfig returns the effective configuration
+func getServerConfig() ServerConfig {
+	serverConfigMu.RLock()
+	defer serverConfigMu.RUnlock()
+	return serverConfig
+}
+
+// withinAllowedRoots reports whether path is inside the server-wide allowed roots
+func withinAllowedRoots(path string) bool {
+	roots := getServerConfig().AllowedRoots
+	if len(roots) == 0 {
+		return true
+	}
+	cleanPath := filepath.Clean(path)
+	for _, root := range roots {
+		if cleanPath == root || strings.HasPrefix(cleanPath, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// claudeBaseArgs returns the non-interactive claude flags with configured defaults
+func claudeBaseArgs(planMode bool) []string {
+	cfg := getServerConfig().Claude
+	mode := cfg.PermissionMode
+	if planMode {
+		mode = "plan"
+	}
+	return claudeArgs(mode, cfg.DefaultModel)
+}
+
+// claudeArgs returns headless stream-json arguments for an explicit permission mode and model
+func claudeArgs(mode string, model string) []string {
+	args := []string{
+		"-p",
+		"--output-format", "stream-json",
+		"--verbose",
+	}
+	// Older CLIs may lack one of the permission flags (see GET /api/server/doctor)
+	switch {
+	case (mode == "" || mode == "bypassPermissions") && claudeSupports("--dangerously-skip-permissions"):
+		args = append(args, "--dangerously-skip-permissions")
+	case mode == "":
+		args = append(args, "--permission-mode", "bypassPermissions")
+	case claudeSupports("--permission-mode"):
+		args = append(args, "--permission-mode", mode)
+	default:
+		log.Printf("[Doctor] claude has no --permission-mode; running with its default instead of %s", mode)
+	}
+	if model != "" {
+		args = append(args, "--model", model)
+	}
+	return args
+}
+
+// checkChatLimits returns an error if starting another chat would exceed the configured limits
+func checkChatLimits(owner string) error {
+	limits := getServerConfig().Limits
+	if limits.MaxConcurrentChats == 0 && limits.MaxChatsPerUser == 0 {
+		return nil
+	}
+
+	processLock.RLock()
+	total, mine := 0, 0
+	for _, info := range activeProcesses {
+		total++
+		if owner != "" && info.Owner == owner {
+			mine++
+		}
+	}
+	processLock.RUnlock()
+
+	if limits.MaxConcurrentChats > 0 && total >= limits.MaxConcurrentChats {
+		return withCode(http.StatusTooManyRequests, ErrRateLimited, fmt.Errorf("server is busy: %d chats already running (limit %d)", total, limits.MaxConcurrentChats))
+	}
+	if owner != "" && limits.MaxChatsPerUser > 0 && mine >= limits.MaxChatsPerUser {
+		return withCode(http.StatusTooManyRequests, ErrRateLimited, fmt.Errorf("you already have %d chats running (limit %d)", mine, limits.MaxChatsPerUser))
+	}
+	return nil
+}
+
+// GetServerConfig handles GET /api/server/config
+// Returns the effective configuration; secrets are omitted
+func GetServerConfig(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"path":   serverConfigPath,
+		"config": getServerConfig(),
+	})
+}
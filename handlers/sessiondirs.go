@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAddDirs bounds the additional directories of one session
+const maxAddDirs = 20
+
+// SessionDirs are the directories a session works in besides its working directory, passed to claude as --add-dir
+type SessionDirs struct {
+	AddDirs   []string `json:"addDirs"`
+	UpdatedAt string   `json:"updatedAt,omitempty"`
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+}
+
+// SessionDirsRequest is the body of PUT /api/session/:id/dirs
+type SessionDirsRequest struct {
+	AddDirs []string `json:"addDirs"` // empty clears them
+}
+
+// SessionDirsResponse is the response of GET and PUT /api/session/:id/dirs
+type SessionDirsResponse struct {
+	SessionID string   `json:"sessionId"`
+	WorkDir   string   `json:"workDir"`
+	AddDirs   []string `json:"addDirs"`
+	// Roots are the working directory and the additional directories, for the file explorer
+	Roots []DirectoryItem `json:"roots"`
+}
+
+// Additional directories are kept until their session is deleted
+var (
+	sessionDirsMu     sync.Mutex
+	sessionDirsStore  map[string]SessionDirs // session ID -> directories
+	sessionDirsLoaded bool
+)
+
+func sessionDirsPath() string {
+	return serverDataPath("session-dirs.json")
+}
+
+// loadSessionDirs reads the store once; caller must hold sessionDirsMu
+func loadSessionDirs() error {
+	if sessionDirsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(sessionDirsPath(), &sessionDirsStore); err != nil {
+		return err
+	}
+	if sessionDirsStore == nil {
+		sessionDirsStore = make(map[string]SessionDirs)
+	}
+	sessionDirsLoaded = true
+	return nil
+}
+
+// sessionAddDirs returns a session's additional directories
+func sessionAddDirs(sessionID string) []string {
+	if sessionID == "" {
+		return nil
+	}
+	sessionDirsMu.Lock()
+	defer sessionDirsMu.Unlock()
+	if err := loadSessionDirs(); err != nil {
+		log.Printf("[SessionDirs] Failed to load session directories: %v", err)
+		return nil
+	}
+	return append([]string(nil), sessionDirsStore[sessionID].AddDirs...)
+}
+
+// setSessionAddDirs stores a session's additional directories; none clears them
+func setSessionAddDirs(sessionID string, dirs []string, user *User) error {
+	sessionDirsMu.Lock()
+	defer sessionDirsMu.Unlock()
+	if err := loadSessionDirs(); err != nil {
+		return err
+	}
+	if len(dirs) == 0 {
+		if _, ok := sessionDirsStore[sessionID]; !ok {
+			return nil
+		}
+		delete(sessionDirsStore, sessionID)
+	} else {
+		sessionDirsStore[sessionID] = SessionDirs{
+			AddDirs:   dirs,
+			UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+			UpdatedBy: ownerID(user),
+		}
+	}
+	return writeJSONFileAtomic(sessionDirsPath(), sessionDirsStore)
+}
+
+// forgetSessionDirs drops a deleted session's additional directories
+func forgetSessionDirs(sessionID string) {
+	if err := setSessionAddDirs(sessionID, nil, nil); err != nil {
+		log.Printf("[SessionDirs] Failed to save session directories: %v", err)
+	}
+}
+
+// checkAddDirs cleans a chat's additional directories and checks that user may use them with workDir
+// They must be local directories, as claude on a remote host or in a container could not reach them
+func checkAddDirs(user *User, workDir, backend string, dirs []string) ([]string, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	if len(dirs) > maxAddDirs {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("at most %d addDirs", maxAddDirs))
+	}
+	if !runsLocally(workDir, backend) {
+		return nil, localOnlyError("additional directories", workDir, backend)
+	}
+	var clean []string
+	seen := map[string]bool{filepath.Clean(workDir): true}
+	for _, dir := range dirs {
+		dir = filepath.Clean(expandHome(dir))
+		if !filepath.IsAbs(dir) {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("addDirs must be absolute paths: %s", dir))
+		}
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		if !userCanAccessPath(user, dir) {
+			return nil, withCode(http.StatusForbidden, ErrPathForbidden, fmt.Errorf("Directory is outside your projects: %s", dir))
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("Directory does not exist: %s", dir))
+		}
+		clean = append(clean, dir)
+	}
+	return clean, nil
+}
+
+// chatAddDirs returns the additional directories of a chat run: those requested, which the session then keeps,
+// or when the request has none (nil, not empty) the ones the session resumed already has
+func chatAddDirs(user *User, sessionID, workDir, backend string, requested []string) ([]string, error) {
+	if requested != nil {
+		return checkAddDirs(user, workDir, backend, requested)
+	}
+	stored := sessionAddDirs(sessionID)
+	if len(stored) == 0 {
+		return nil, nil
+	}
+	// A directory removed since, or no longer allowed, is left out rather than failing the run
+	var dirs []string
+	for _, dir := range stored {
+		if _, err := checkAddDirs(user, workDir, backend, []string{dir}); err != nil {
+			log.Printf("[SessionDirs] Leaving %s out of session %s: %v", dir, sessionID, err)
+			continue
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
+// keepChatAddDirs stores the additional directories a chat requested with its session; nothing when it requested none
+func keepChatAddDirs(sessionID string, requested, dirs []string, user *User) {
+	if requested == nil || sessionID == "" {
+		return
+	}
+	if err := setSessionAddDirs(sessionID, dirs, user); err != nil {
+		log.Printf("[SessionDirs] Failed to save session directories: %v", err)
+	}
+}
+
+// sessionDirsResponse lists a session's directories as explorer roots
+func sessionDirsResponse(sessionID string, dirs []string) SessionDirsResponse {
+	workDir := GetSessionWorkDir(sessionID)
+	resp := SessionDirsResponse{SessionID: sessionID, WorkDir: workDir, AddDirs: dirs, Roots: []DirectoryItem{}}
+	if resp.AddDirs == nil {
+		resp.AddDirs = []string{}
+	}
+	if workDir != "" {
+		resp.Roots = append(resp.Roots, DirectoryItem{Name: filepath.Base(workDir), Path: workDir})
+	}
+	for _, dir := range dirs {
+		resp.Roots = append(resp.Roots, DirectoryItem{Name: filepath.Base(dir), Path: dir})
+	}
+	return resp
+}
+
+// GetSessionDirs handles GET /api/session/:id/dirs
+// Returns the session's working directory and additional directories, the roots its file explorer shows
+func GetSessionDirs(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	c.JSON(http.StatusOK, sessionDirsResponse(sessionID, sessionAddDirs(sessionID)))
+}
+
+// UpdateSessionDirs handles PUT /api/session/:id/dirs
+// Replaces the session's additional directories, used by its next runs
+func UpdateSessionDirs(c *gin.Context) {
+	sessionID := c.Param("id")
+	user := currentUser(c)
+	if !userCanAccessSession(user, sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req SessionDirsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	dirs, err := checkAddDirs(user, GetSessionWorkDir(sessionID), "", req.AddDirs)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if err := setSessionAddDirs(sessionID, dirs, user); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save session directories", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, sessionDirsResponse(sessionID, dirs))
+}
@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// gistExportTimeout bounds publishing one export
+const gistExportTimeout = 30 * time.Second
+
+// PasteConfig is the pastebin POST /api/session/:id/export/gist publishes to with target "paste"
+type PasteConfig struct {
+	// URL receives the export as a text/plain POST and answers with its link, as plain text or a JSON url field
+	URL string `yaml:"url" json:"url"`
+	// Token is sent as a bearer token; defaults to GREYZONE_PASTE_TOKEN
+	Token string `yaml:"token" json:"-"`
+}
+
+// GistExportRequest is the body of POST /api/session/:id/export/gist
+type GistExportRequest struct {
+	MessageUUIDs []string `json:"messageUuids"` // user and assistant messages, published as conversation.md
+	ArtifactIDs  []string `json:"artifactIds"`  // from GET /api/session/:id/artifacts, published as their own files
+	Description  string   `json:"description"`
+	Public       bool     `json:"public"`
+	Target       string   `json:"target"` // gist (default) or paste
+	Redact       []string `json:"redact"` // extra regular expressions masked on top of the redaction rules
+}
+
+// SessionExport is a published export, listed on the session's info
+type SessionExport struct {
+	Target      string   `json:"target"`
+	URL         string   `json:"url"`
+	Description string   `json:"description,omitempty"`
+	Messages    []string `json:"messages,omitempty"`
+	Artifacts   []string `json:"artifacts,omitempty"`
+	User        string   `json:"user,omitempty"`
+	CreatedAt   string   `json:"createdAt"`
+}
+
+// gistFile is a file of an export
+type gistFile struct {
+	name    string
+	content string
+}
+
+// Exports are kept until their session is deleted
+var (
+	exportsMu     sync.Mutex
+	exportStore   map[string][]SessionExport // session ID -> exports, oldest first
+	exportsLoaded bool
+)
+
+func exportsPath() string {
+	return serverDataPath("session-exports.json")
+}
+
+// loadExports reads the export store once; caller must hold exportsMu
+func loadExports() error {
+	if exportsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(exportsPath(), &exportStore); err != nil {
+		return err
+	}
+	if exportStore == nil {
+		exportStore = make(map[string][]SessionExport)
+	}
+	exportsLoaded = true
+	return nil
+}
+
+// recordExport appends an export to its session's
+func recordExport(sessionID string, export SessionExport) error {
+	exportsMu.Lock()
+	defer exportsMu.Unlock()
+	if err := loadExports(); err != nil {
+		return err
+	}
+	exportStore[sessionID] = append(exportStore[sessionID], export)
+	return writeJSONFileAtomicMode(exportsPath(), exportStore, 0600)
+}
+
+// sessionExports returns a session's exports, oldest first
+func sessionExports(sessionID string) []SessionExport {
+	exportsMu.Lock()
+	defer exportsMu.Unlock()
+	if err := loadExports(); err != nil {
+		log.Printf("[Export] Failed to load exports: %v", err)
+		return nil
+	}
+	return append([]SessionExport(nil), exportStore[sessionID]...)
+}
+
+// forgetExports drops a deleted session's exports
+func forgetExports(sessionID string) {
+	exportsMu.Lock()
+	defer exportsMu.Unlock()
+	if err := loadExports(); err != nil || exportStore[sessionID] == nil {
+		return
+	}
+	delete(exportStore, sessionID)
+	if err := writeJSONFileAtomicMode(exportsPath(), exportStore, 0600); err != nil {
+		log.Printf("[Export] Failed to save exports: %v", err)
+	}
+}
+
+// languageExt returns the file extension of a code block language, ".txt" when unknown
+func languageExt(language string) string {
+	var exts []string
+	for ext, lang := range langMap {
+		if strings.EqualFold(lang, language) {
+			exts = append(exts, ext)
+		}
+	}
+	if len(exts) == 0 {
+		return ".txt"
+	}
+	sort.Strings(exts)
+	return exts[0]
+}
+
+// uniqueName returns name, or name with a number before its extension when taken
+func uniqueName(name string, taken map[string]bool) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for n := 2; taken[name]; n++ {
+		name = fmt.Sprintf("%s-%d%s", base, n, ext)
+	}
+	taken[name] = true
+	return name
+}
+
+// publishGist creates a gist of files and returns its URL
+func publishGist(ctx context.Context, description string, public bool, files []gistFile) (string, error) {
+	if getServerConfig().Integrations.GitHub.Token == "" {
+		return "", withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("integrations.github.token is not configured"))
+	}
+	body := map[string]interface{}{"description": description, "public": public}
+	contents := make(map[string]interface{}, len(files))
+	for _, f := range files {
+		contents[f.name] = map[string]string{"content": f.content}
+	}
+	body["files"] = contents
+	var gist struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := githubRequest(ctx, http.MethodPost, "/gists", body, &gist); err != nil {
+		return "", err
+	}
+	return gist.HTMLURL, nil
+}
+
+// publishPaste posts files, joined into one text, to integrations.paste.url and returns the paste's URL
+func publishPaste(ctx context.Context, files []gistFile) (string, error) {
+	cfg := getServerConfig().Integrations.Paste
+	if cfg.URL == "" {
+		return "", withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("integrations.paste.url is not configured"))
+	}
+	var text strings.Builder
+	for i, f := range files {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+		if len(files) > 1 {
+			fmt.Fprintf(&text, "==> %s <==\n", f.name)
+		}
+		text.WriteString(f.content)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.URL, strings.NewReader(text.String()))
+	if err != nil {
+		return "", withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("integrations.paste.url: %w", err))
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cfg.Token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", withCode(http.StatusBadGateway, ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("pastebin returned %s: %s", resp.Status, strings.TrimSpace(string(data))))
+	}
+	var link struct {
+		URL  string `json:"url"`
+		Link string `json:"link"`
+	}
+	if json.Unmarshal(data, &link) == nil {
+		if link.URL != "" {
+			return link.URL, nil
+		}
+		if link.Link != "" {
+			return link.Link, nil
+		}
+	}
+	if url := strings.TrimSpace(string(data)); strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+		return url, nil
+	}
+	if location := resp.Header.Get("Location"); location != "" {
+		return location, nil
+	}
+	return "", withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("pastebin did not return a URL"))
+}
+
+// ExportGist handles POST /api/session/:id/export/gist
+// Publishes the selected messages as conversation.md and the selected artifacts as their own files
+// to a GitHub gist, or to integrations.paste as one text, masking secrets as share links do.
+// The export is recorded on the session and returned with its URL
+func ExportGist(c *gin.Context) {
+	sessionID := c.Param("id")
+	user := currentUser(c)
+	path := ""
+	if userCanAccessSession(user, sessionID) {
+		path = findSessionFile(sessionID)
+	}
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req GistExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if req.Target == "" {
+		req.Target = "gist"
+	}
+	if req.Target != "gist" && req.Target != "paste" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "target must be gist or paste")
+		return
+	}
+	if len(req.MessageUUIDs) == 0 && len(req.ArtifactIDs) == 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Select at least one message or artifact")
+		return
+	}
+	r, err := newRedactor(req.Redact)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	var files []gistFile
+	taken := make(map[string]bool)
+	if len(req.MessageUUIDs) > 0 {
+		type selected struct {
+			pos int
+			msg Message
+		}
+		var messages []selected
+		seen := make(map[string]bool)
+		for _, uuid := range req.MessageUUIDs {
+			if seen[uuid] {
+				continue
+			}
+			seen[uuid] = true
+			msg, pos, err := historyMessage(path, uuid)
+			if err != nil {
+				respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("Message not found: %s", uuid))
+				return
+			}
+			messages = append(messages, selected{pos, msg})
+		}
+		sort.Slice(messages, func(i, j int) bool { return messages[i].pos < messages[j].pos })
+		var md strings.Builder
+		for _, m := range messages {
+			content, _ := json.Marshal(m.msg.Message["content"])
+			text := strings.TrimSpace(transcriptText(content))
+			if text == "" {
+				continue
+			}
+			role := "User"
+			if m.msg.Type == "assistant" {
+				role = "Assistant"
+			}
+			fmt.Fprintf(&md, "### %s\n\n%s\n\n", role, r.redact(text))
+		}
+		if md.Len() == 0 {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "The selected messages have no text")
+			return
+		}
+		files = append(files, gistFile{uniqueName("conversation.md", taken), md.String()})
+	}
+	if len(req.ArtifactIDs) > 0 {
+		cache, err := sessionArtifacts(path)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+			return
+		}
+		byID := make(map[string]Artifact, len(cache.artifacts))
+		for _, artifact := range cache.artifacts {
+			byID[artifact.ID] = artifact
+		}
+		for i, id := range req.ArtifactIDs {
+			artifact, ok := byID[id]
+			if !ok {
+				respondError(c, http.StatusNotFound, ErrNotFound, fmt.Sprintf("Artifact not found: %s", id))
+				return
+			}
+			name := filepath.Base(artifact.Path)
+			if artifact.Path == "" {
+				name = fmt.Sprintf("artifact-%d%s", i+1, languageExt(artifact.Language))
+			}
+			files = append(files, gistFile{uniqueName(name, taken), r.redact(artifact.Content)})
+		}
+	}
+
+	description := strings.TrimSpace(req.Description)
+	if description == "" {
+		description = "Claude session " + sessionID
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), gistExportTimeout)
+	defer cancel()
+	var url string
+	if req.Target == "paste" {
+		url, err = publishPaste(ctx, files)
+	} else {
+		url, err = publishGist(ctx, description, req.Public, files)
+	}
+	if err != nil {
+		log.Printf("[Export] Failed to publish session %s to %s: %v", sessionID, req.Target, err)
+		respondCheckError(c, err)
+		return
+	}
+
+	export := SessionExport{
+		Target:      req.Target,
+		URL:         url,
+		Description: description,
+		Messages:    req.MessageUUIDs,
+		Artifacts:   req.ArtifactIDs,
+		CreatedAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if user != nil {
+		export.User = user.Username
+	}
+	if err := recordExport(sessionID, export); err != nil {
+		log.Printf("[Export] Failed to record export of session %s: %v", sessionID, err)
+	}
+	c.JSON(http.StatusOK, export)
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxSessionListLimit bounds the limit parameter of GET /api/sessions
+const maxSessionListLimit = 1000
+
+// sessionFilter is the query of GET /api/sessions beyond work_dir and feedback
+type sessionFilter struct {
+	since, until      time.Time // zero = open
+	minMessages       int
+	branch            string
+	hasErrors         *bool
+	includeSidechains bool
+	query             string // lower case
+	limit             int
+}
+
+// parseFilterTime reads an RFC 3339 time or a date; a date in until means the end of that day
+func parseFilterTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return day, nil
+}
+
+// parseSessionFilter reads the filters and limit of GET /api/sessions
+func parseSessionFilter(c *gin.Context) (sessionFilter, error) {
+	filter := sessionFilter{
+		branch:            c.Query("branch"),
+		includeSidechains: true,
+		query:             strings.ToLower(strings.TrimSpace(c.Query("q"))),
+		limit:             getServerConfig().Limits.SessionListLimit,
+	}
+	invalid := func(name string) error {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("Invalid %s parameter", name))
+	}
+	var err error
+	if v := c.Query("since"); v != "" {
+		if filter.since, err = parseFilterTime(v, false); err != nil {
+			return filter, invalid("since")
+		}
+	}
+	if v := c.Query("until"); v != "" {
+		if filter.until, err = parseFilterTime(v, true); err != nil {
+			return filter, invalid("until")
+		}
+	}
+	if v := c.Query("min_messages"); v != "" {
+		if filter.minMessages, err = strconv.Atoi(v); err != nil || filter.minMessages < 0 {
+			return filter, invalid("min_messages")
+		}
+	}
+	if v := c.Query("has_errors"); v != "" {
+		hasErrors, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, invalid("has_errors")
+		}
+		filter.hasErrors = &hasErrors
+	}
+	if v := c.Query("include_sidechains"); v != "" {
+		if filter.includeSidechains, err = strconv.ParseBool(v); err != nil {
+			return filter, invalid("include_sidechains")
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		if filter.limit, err = strconv.Atoi(v); err != nil || filter.limit <= 0 {
+			return filter, invalid("limit")
+		}
+		if filter.limit > maxSessionListLimit {
+			filter.limit = maxSessionListLimit
+		}
+	}
+	return filter, nil
+}
+
+// messageErrorCount counts the failed tool calls and API errors in one transcript line
+func messageErrorCount(msg Message) int {
+	if msg.IsAPIErrorMessage {
+		return 1
+	}
+	blocks, _ := msg.Message["content"].([]interface{})
+	count := 0
+	for _, block := range blocks {
+		if b, ok := block.(map[string]interface{}); ok && b["type"] == "tool_result" && b["is_error"] == true {
+			count++
+		}
+	}
+	return count
+}
+
+// sessionErrors returns a session's error count; indexed sessions are parsed for it, through the parse cache
+func sessionErrors(session *Session) int {
+	if session.Errors > 0 || session.FullPath == "" {
+		return session.Errors
+	}
+	parsed := parseSessionCached(session.FullPath, filepath.Base(filepath.Dir(session.FullPath)))
+	if parsed == nil {
+		return 0
+	}
+	session.Errors = parsed.Errors
+	return parsed.Errors
+}
+
+// apply keeps the sessions matching every filter, reusing the slice
+func (f sessionFilter) apply(sessions []Session) []Session {
+	kept := sessions[:0]
+	for i := range sessions {
+		session := &sessions[i]
+		if !f.includeSidechains && session.IsSidechain {
+			continue
+		}
+		if f.branch != "" && session.GitBranch != f.branch {
+			continue
+		}
+		if session.MessageCount < f.minMessages {
+			continue
+		}
+		if f.query != "" && !strings.Contains(strings.ToLower(session.FirstPrompt), f.query) {
+			continue
+		}
+		if !f.since.IsZero() || !f.until.IsZero() {
+			modified, err := time.Parse(time.RFC3339Nano, session.Modified)
+			if err != nil || (!f.since.IsZero() && modified.Before(f.since)) || (!f.until.IsZero() && modified.After(f.until)) {
+				continue
+			}
+		}
+		if f.hasErrors != nil && (sessionErrors(session) > 0) != *f.hasErrors {
+			continue
+		}
+		kept = append(kept, *session)
+	}
+	return kept
+}
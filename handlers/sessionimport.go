@@ -0,0 +1,510 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionImportMaxSize bounds an import; a ChatGPT or Claude.ai export of years of chats runs to tens of MB
+const sessionImportMaxSize = 128 << 20
+
+// ImportedSession is one session created by POST /api/sessions/import
+type ImportedSession struct {
+	SessionID string `json:"sessionId"`
+	Title     string `json:"title,omitempty"`
+	Source    string `json:"source"` // jsonl, chatgpt, or claude.ai
+	Messages  int    `json:"messages"`
+	Created   string `json:"created,omitempty"`
+}
+
+// ImportSessionsResponse is the response of POST /api/sessions/import
+type ImportSessionsResponse struct {
+	WorkDir  string            `json:"workDir"`
+	Sessions []ImportedSession `json:"sessions"`
+	Skipped  int               `json:"skipped"` // conversations without a user or assistant message
+}
+
+// importMessage is one turn of a converted conversation
+type importMessage struct {
+	role string // user or assistant
+	text string
+	at   time.Time
+}
+
+// importConversation is a conversation from an export, before it is written as a transcript
+type importConversation struct {
+	id       string
+	title    string
+	created  time.Time
+	messages []importMessage
+}
+
+// chatGPTConversation is an entry of conversations.json in a ChatGPT export
+// Messages form a tree (edits and regenerations branch it); current_node is the leaf that was shown
+type chatGPTConversation struct {
+	ID             string  `json:"id"`
+	ConversationID string  `json:"conversation_id"`
+	Title          string  `json:"title"`
+	CreateTime     float64 `json:"create_time"`
+	CurrentNode    string  `json:"current_node"`
+	Mapping        map[string]struct {
+		Parent  string `json:"parent"`
+		Message *struct {
+			Author struct {
+				Role string `json:"role"`
+			} `json:"author"`
+			CreateTime float64 `json:"create_time"`
+			Content    struct {
+				ContentType string            `json:"content_type"`
+				Parts       []json.RawMessage `json:"parts"`
+				Text        string            `json:"text"`
+			} `json:"content"`
+			Recipient string `json:"recipient"`
+			Metadata  struct {
+				Hidden bool `json:"is_visually_hidden_from_conversation"`
+			} `json:"metadata"`
+		} `json:"message"`
+	} `json:"mapping"`
+}
+
+// claudeAIConversation is an entry of conversations.json in a Claude.ai export
+type claudeAIConversation struct {
+	UUID         string `json:"uuid"`
+	Name         string `json:"name"`
+	CreatedAt    string `json:"created_at"`
+	ChatMessages []struct {
+		Sender    string `json:"sender"` // human or assistant
+		Text      string `json:"text"`
+		CreatedAt string `json:"created_at"`
+		Content   []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Attachments []struct {
+			FileName         string `json:"file_name"`
+			ExtractedContent string `json:"extracted_content"`
+		} `json:"attachments"`
+	} `json:"chat_messages"`
+}
+
+// chatGPTTime converts ChatGPT's fractional unix seconds
+func chatGPTTime(seconds float64) time.Time {
+	if seconds <= 0 {
+		return time.Time{}
+	}
+	whole, frac := math.Modf(seconds)
+	return time.Unix(int64(whole), int64(frac*1e9))
+}
+
+// convert follows the shown branch from current_node back to the root
+// Only text the user saw is kept: system prompts, tool traffic, and hidden messages are dropped
+func (conv chatGPTConversation) convert() importConversation {
+	out := importConversation{id: conv.ID, title: conv.Title, created: chatGPTTime(conv.CreateTime)}
+	if out.id == "" {
+		out.id = conv.ConversationID
+	}
+	node := conv.CurrentNode
+	if _, ok := conv.Mapping[node]; !ok {
+		// No leaf recorded: take the latest message
+		var latest float64
+		for id, n := range conv.Mapping {
+			if n.Message != nil && n.Message.CreateTime >= latest {
+				node, latest = id, n.Message.CreateTime
+			}
+		}
+	}
+	var path []importMessage
+	for seen := make(map[string]bool); node != "" && !seen[node]; {
+		seen[node] = true
+		n, ok := conv.Mapping[node]
+		if !ok {
+			break
+		}
+		node = n.Parent
+		m := n.Message
+		if m == nil || m.Metadata.Hidden || (m.Author.Role != "user" && m.Author.Role != "assistant") {
+			continue
+		}
+		if m.Recipient != "" && m.Recipient != "all" {
+			continue
+		}
+		var text string
+		switch m.Content.ContentType {
+		case "text", "multimodal_text":
+			var parts []string
+			for _, raw := range m.Content.Parts {
+				var s string
+				if json.Unmarshal(raw, &s) == nil && strings.TrimSpace(s) != "" {
+					parts = append(parts, s)
+				}
+			}
+			text = strings.Join(parts, "\n\n")
+		case "code":
+			text = "```\n" + m.Content.Text + "\n```"
+		}
+		if strings.TrimSpace(text) != "" {
+			path = append(path, importMessage{role: m.Author.Role, text: text, at: chatGPTTime(m.CreateTime)})
+		}
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		out.messages = append(out.messages, path[i])
+	}
+	return out
+}
+
+func (conv claudeAIConversation) convert() importConversation {
+	created, _ := time.Parse(time.RFC3339Nano, conv.CreatedAt)
+	out := importConversation{id: conv.UUID, title: conv.Name, created: created}
+	for _, m := range conv.ChatMessages {
+		role := "assistant"
+		if m.Sender == "human" {
+			role = "user"
+		}
+		var parts []string
+		for _, block := range m.Content {
+			if block.Type == "text" && strings.TrimSpace(block.Text) != "" {
+				parts = append(parts, block.Text)
+			}
+		}
+		if len(parts) == 0 && strings.TrimSpace(m.Text) != "" {
+			parts = append(parts, m.Text)
+		}
+		for _, a := range m.Attachments {
+			if a.ExtractedContent != "" {
+				parts = append(parts, fmt.Sprintf("<attachment name=%q>\n%s\n</attachment>", a.FileName, a.ExtractedContent))
+			}
+		}
+		if len(parts) == 0 {
+			continue
+		}
+		at, _ := time.Parse(time.RFC3339Nano, m.CreatedAt)
+		out.messages = append(out.messages, importMessage{role: role, text: strings.Join(parts, "\n\n"), at: at})
+	}
+	return out
+}
+
+// parseExport reads the conversations of a ChatGPT or Claude.ai export: the zip, its
+// conversations.json, or a single conversation from it
+func parseExport(data []byte) (source string, convs []importConversation, err error) {
+	if bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		if data, err = exportConversationsJSON(data); err != nil {
+			return "", nil, err
+		}
+	}
+	var raw []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		raw = []json.RawMessage{trimmed}
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return "", nil, fmt.Errorf("not a ChatGPT or Claude.ai export: %w", err)
+	}
+	for _, entry := range raw {
+		var probe map[string]json.RawMessage
+		if json.Unmarshal(entry, &probe) != nil {
+			continue
+		}
+		switch {
+		case probe["mapping"] != nil:
+			var conv chatGPTConversation
+			if err := json.Unmarshal(entry, &conv); err != nil {
+				return "", nil, fmt.Errorf("invalid ChatGPT conversation: %w", err)
+			}
+			source = "chatgpt"
+			convs = append(convs, conv.convert())
+		case probe["chat_messages"] != nil:
+			var conv claudeAIConversation
+			if err := json.Unmarshal(entry, &conv); err != nil {
+				return "", nil, fmt.Errorf("invalid Claude.ai conversation: %w", err)
+			}
+			source = "claude.ai"
+			convs = append(convs, conv.convert())
+		}
+	}
+	if source == "" {
+		return "", nil, errors.New("not a ChatGPT or Claude.ai export: no conversations found")
+	}
+	return source, convs, nil
+}
+
+// exportConversationsJSON extracts conversations.json from an export zip
+func exportConversationsJSON(data []byte) ([]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid zip: %w", err)
+	}
+	for _, f := range archive.File {
+		if filepath.Base(f.Name) != "conversations.json" {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(io.LimitReader(r, sessionImportMaxSize))
+	}
+	return nil, errors.New("the zip has no conversations.json")
+}
+
+// writeImportedConversation writes a converted conversation as a new session in workDir
+// Consecutive messages from the same side are merged, since turns must alternate when the session is resumed
+func writeImportedConversation(conv importConversation, workDir string, source string) (*ImportedSession, error) {
+	var messages []importMessage
+	for _, m := range conv.messages {
+		if n := len(messages); n > 0 && messages[n-1].role == m.role {
+			messages[n-1].text += "\n\n" + m.text
+			continue
+		}
+		messages = append(messages, m)
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	sessionID := newUUID()
+	stream := &transcriptStream{
+		workDir:   workDir,
+		sessionID: sessionID,
+		path:      filepath.Join(getProjectsDir(), hashProjectPath(workDir), sessionID+".jsonl"),
+		extra:     map[string]interface{}{"importedFrom": source},
+	}
+	// Messages without a time of their own follow the previous one
+	last := conv.created
+	if last.IsZero() {
+		last = time.Now()
+	}
+	for _, m := range messages {
+		if !m.at.IsZero() {
+			last = m.at
+		}
+		var message interface{} = map[string]interface{}{"role": "user", "content": m.text}
+		if m.role == "assistant" {
+			message = agentMessage(m.text)
+		}
+		if err := stream.recordAt(m.role, message, last); err != nil {
+			os.Remove(stream.path)
+			return nil, err
+		}
+	}
+	os.Chtimes(stream.path, last, last)
+
+	imported := &ImportedSession{SessionID: sessionID, Title: conv.title, Source: source, Messages: len(messages)}
+	if !conv.created.IsZero() {
+		imported.Created = conv.created.UTC().Format(time.RFC3339)
+	}
+	return imported, nil
+}
+
+// writeImportedTranscript copies a claude transcript into workDir as a new session
+// Lines that aren't whole records are dropped, as repair would
+func writeImportedTranscript(data []byte, workDir string) (*ImportedSession, error) {
+	report, kept := validateTranscript("", data)
+	sessionID := newUUID()
+	var out bytes.Buffer
+	var created, last time.Time
+	messages := 0
+	for _, record := range kept {
+		var fields map[string]json.RawMessage
+		if json.Unmarshal(record, &fields) != nil {
+			continue
+		}
+		if _, ok := fields["sessionId"]; ok {
+			fields["sessionId"], _ = json.Marshal(sessionID)
+		}
+		if _, ok := fields["cwd"]; ok {
+			fields["cwd"], _ = json.Marshal(workDir)
+		}
+		var line struct {
+			Type      string `json:"type"`
+			Timestamp string `json:"timestamp"`
+		}
+		json.Unmarshal(record, &line)
+		if isHistoryMessage(line.Type) {
+			messages++
+		}
+		if at, err := time.Parse(time.RFC3339Nano, line.Timestamp); err == nil {
+			if created.IsZero() {
+				created = at
+			}
+			last = at
+		}
+		rewritten, err := json.Marshal(fields)
+		if err != nil {
+			return nil, err
+		}
+		out.Write(rewritten)
+		out.WriteByte('\n')
+	}
+	if messages == 0 {
+		return nil, withCode(http.StatusUnprocessableEntity, ErrValidationFailed,
+			fmt.Errorf("no messages found in the transcript (%d of %d lines invalid)", report.InvalidLines, report.Lines))
+	}
+
+	path := filepath.Join(getProjectsDir(), hashProjectPath(workDir), sessionID+".jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(path, out.Bytes()); err != nil {
+		return nil, err
+	}
+	imported := &ImportedSession{SessionID: sessionID, Source: "jsonl", Messages: messages}
+	if !last.IsZero() {
+		os.Chtimes(path, last, last)
+		imported.Created = created.UTC().Format(time.RFC3339)
+	}
+	return imported, nil
+}
+
+// readImportBody returns the uploaded file: the multipart field "file", or the raw request body
+func readImportBody(c *gin.Context) ([]byte, string, error) {
+	var body io.Reader = c.Request.Body
+	name := ""
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		reader, err := c.Request.MultipartReader()
+		if err != nil {
+			return nil, "", err
+		}
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				return nil, "", errors.New("No file provided")
+			}
+			if part.FormName() == "file" {
+				body, name = part, part.FileName()
+				break
+			}
+			part.Close()
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(body, sessionImportMaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > sessionImportMaxSize {
+		return nil, "", withCode(http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Errorf("imports are limited to %d MB", sessionImportMaxSize>>20))
+	}
+	return data, name, nil
+}
+
+// ImportSessions handles POST /api/sessions/import
+// Takes a claude .jsonl transcript, or a ChatGPT or Claude.ai export (the zip or its conversations.json),
+// as the multipart field "file" or the request body, and writes each conversation as a session of workDir
+// that can be resumed like any other. Exports keep only the text of the conversation as shown
+// Query parameters:
+//   - workDir: the project to import into (default: the first project root, then the home directory)
+//   - format: jsonl or export (default: detected)
+//   - conversation: import only the export conversation with this ID
+func ImportSessions(c *gin.Context) {
+	user := currentUser(c)
+	workDir := expandHome(c.Query("workDir"))
+	if workDir == "" && user != nil && len(user.ProjectRoots) > 0 {
+		workDir = user.ProjectRoots[0]
+	}
+	if workDir == "" {
+		workDir, _ = os.UserHomeDir()
+	}
+	if _, _, ok := parseRemotePath(workDir); ok {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Sessions can't be imported into remote directories")
+		return
+	}
+	workDir = filepath.Clean(workDir)
+	if !userCanAccessPath(user, workDir) {
+		respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", workDir))
+		return
+	}
+	if err := checkWorkDir(workDir); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	format := c.Query("format")
+	if format != "" && format != "jsonl" && format != "export" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "format must be jsonl or export")
+		return
+	}
+
+	data, name, err := readImportBody(c)
+	if err != nil {
+		respondCheckError(c, withCode(http.StatusBadRequest, ErrInvalidRequest, err))
+		return
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "No file provided")
+		return
+	}
+	if format == "" {
+		format = "export"
+		if strings.HasSuffix(strings.ToLower(name), ".jsonl") || isTranscript(data) {
+			format = "jsonl"
+		}
+	}
+
+	resp := ImportSessionsResponse{WorkDir: workDir, Sessions: []ImportedSession{}}
+	owner := ownerID(user)
+	if format == "jsonl" {
+		imported, err := writeImportedTranscript(data, workDir)
+		if err != nil {
+			respondCheckError(c, err)
+			return
+		}
+		recordSessionOwner(imported.SessionID, owner)
+		resp.Sessions = append(resp.Sessions, *imported)
+	} else {
+		source, convs, err := parseExport(data)
+		if err != nil {
+			respondCheckError(c, withCode(http.StatusBadRequest, ErrInvalidRequest, err))
+			return
+		}
+		if id := c.Query("conversation"); id != "" {
+			var selected []importConversation
+			for _, conv := range convs {
+				if conv.id == id {
+					selected = append(selected, conv)
+				}
+			}
+			if len(selected) == 0 {
+				respondError(c, http.StatusNotFound, ErrNotFound, "Conversation not found")
+				return
+			}
+			convs = selected
+		}
+		sort.SliceStable(convs, func(i, j int) bool { return convs[i].created.Before(convs[j].created) })
+		for _, conv := range convs {
+			imported, err := writeImportedConversation(conv, workDir, source)
+			if err != nil {
+				respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to write session", err.Error())
+				return
+			}
+			if imported == nil {
+				resp.Skipped++
+				continue
+			}
+			recordSessionOwner(imported.SessionID, owner)
+			if imported.Title != "" {
+				storeSessionSummary(imported.SessionID, SessionSummary{Summary: imported.Title, Model: source, MessageCount: imported.Messages, GeneratedAt: time.Now()})
+			}
+			resp.Sessions = append(resp.Sessions, *imported)
+		}
+	}
+	log.Printf("[Import] %s imported %d sessions into %s (%s, %d skipped)", auditUser(user), len(resp.Sessions), workDir, format, resp.Skipped)
+	c.JSON(http.StatusOK, resp)
+}
+
+// isTranscript reports whether data looks like a claude transcript: its first line is a record with a type
+func isTranscript(data []byte) bool {
+	first, _, _ := bytes.Cut(bytes.TrimSpace(data), []byte("\n"))
+	kind, _ := transcriptRecord(bytes.TrimSpace(first))
+	return kind == ""
+}
@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// validateIssueLimit bounds the issues a report lists; the counts cover them all
+	validateIssueLimit = 100
+	// validatePreviewLimit truncates the start of a bad line shown in a report
+	validatePreviewLimit = 120
+	// salvageAttempts bounds how many object starts inside a corrupt line are tried
+	salvageAttempts = 64
+)
+
+// SessionIssue is a problem with one line of a transcript
+type SessionIssue struct {
+	Line     int    `json:"line"`   // 1-based
+	Offset   int64  `json:"offset"` // byte offset of the line
+	Kind     string `json:"kind"`   // truncated, invalid_json, not_object, missing_type
+	Message  string `json:"message"`
+	Preview  string `json:"preview,omitempty"`
+	Salvaged bool   `json:"salvaged,omitempty"` // a whole record was found after the damage and repair keeps it
+}
+
+// SessionValidation is the response of GET /api/session/:id/validate
+type SessionValidation struct {
+	SessionID      string         `json:"sessionId"`
+	Valid          bool           `json:"valid"`
+	Size           int64          `json:"size"`
+	Lines          int            `json:"lines"`
+	ValidLines     int            `json:"validLines"`
+	InvalidLines   int            `json:"invalidLines"`
+	Salvageable    int            `json:"salvageable"`    // records repair recovers from invalid lines
+	Truncated      bool           `json:"truncated"`      // the last line was cut off mid-write
+	MissingNewline bool           `json:"missingNewline"` // the last line is complete but unterminated
+	Issues         []SessionIssue `json:"issues"`         // at most 100
+}
+
+// RepairRequest is the body of POST /api/session/:id/repair
+type RepairRequest struct {
+	Mode string `json:"mode"` // copy (default) writes a recovered session; replace rewrites this one after a backup
+}
+
+// RepairResponse is the response of POST /api/session/:id/repair
+type RepairResponse struct {
+	SessionID string `json:"sessionId"`        // the recovered copy, or the repaired session
+	Backup    string `json:"backup,omitempty"` // file name of the original's copy in rewind-backups, with replace
+	Kept      int    `json:"kept"`             // records written
+	Dropped   int    `json:"dropped"`          // invalid lines left out
+	Salvaged  int    `json:"salvaged"`         // records recovered from invalid lines
+}
+
+// transcriptRecord checks one line; ok lines are JSON objects with a type
+func transcriptRecord(line []byte) (kind string, message string) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(line, &fields); err != nil {
+		var value interface{}
+		if json.Unmarshal(line, &value) == nil {
+			return "not_object", "Line is JSON but not an object"
+		}
+		return "invalid_json", err.Error()
+	}
+	var recordType string
+	if json.Unmarshal(fields["type"], &recordType) != nil || recordType == "" {
+		return "missing_type", "Record has no type"
+	}
+	return "", ""
+}
+
+// salvageLine looks for a whole record inside a corrupt line, e.g. one appended after a write that was cut off
+func salvageLine(line []byte) []byte {
+	for start, tries := 1, 0; start < len(line) && tries < salvageAttempts; tries++ {
+		i := bytes.Index(line[start:], []byte(`{"`))
+		if i < 0 {
+			return nil
+		}
+		start += i
+		if kind, _ := transcriptRecord(line[start:]); kind == "" {
+			return line[start:]
+		}
+		start++
+	}
+	return nil
+}
+
+// previewLine is the start of a line, cut on a rune boundary
+func previewLine(line []byte) string {
+	if len(line) > validatePreviewLimit {
+		line = line[:validatePreviewLimit]
+		for len(line) > 0 && !utf8.Valid(line) {
+			line = line[:len(line)-1]
+		}
+		return string(line) + "…"
+	}
+	return string(line)
+}
+
+// validateTranscript checks every line of a transcript and returns the records repair would keep
+func validateTranscript(sessionID string, data []byte) (*SessionValidation, [][]byte) {
+	report := &SessionValidation{SessionID: sessionID, Size: int64(len(data)), Issues: []SessionIssue{}}
+	var kept [][]byte
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		last := end < 0
+		if last {
+			end = len(data)
+		} else {
+			end += offset
+		}
+		line := bytes.TrimSpace(data[offset:end])
+		lineOffset := offset
+		offset = end + 1
+		report.Lines++
+		if len(line) == 0 {
+			continue
+		}
+
+		kind, message := transcriptRecord(line)
+		if kind == "" {
+			report.ValidLines++
+			kept = append(kept, line)
+			if last {
+				report.MissingNewline = true
+			}
+			continue
+		}
+		report.InvalidLines++
+		if last && kind == "invalid_json" {
+			kind, message = "truncated", "The last line ends mid-record, as when claude is killed while writing"
+			report.Truncated = true
+		}
+		issue := SessionIssue{Line: report.Lines, Offset: int64(lineOffset), Kind: kind, Message: message, Preview: previewLine(line)}
+		if record := salvageLine(line); record != nil {
+			issue.Salvaged = true
+			report.Salvageable++
+			kept = append(kept, record)
+		}
+		if len(report.Issues) < validateIssueLimit {
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+	report.Valid = report.InvalidLines == 0
+	return report, kept
+}
+
+// sessionFileFor resolves a session the user may access to its transcript, writing a 404 otherwise
+func sessionFileFor(c *gin.Context, sessionID string) string {
+	path := ""
+	if userCanAccessSession(currentUser(c), sessionID) {
+		path = findSessionFile(sessionID)
+	}
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+	}
+	return path
+}
+
+// ValidateSession handles GET /api/session/:id/validate
+// Reports lines of the transcript that aren't whole JSON records, typically the last line of a
+// run that was killed mid-write, and how many records a repair would keep
+func ValidateSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	path := sessionFileFor(c, sessionID)
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	report, _ := validateTranscript(sessionID, data)
+	c.JSON(http.StatusOK, report)
+}
+
+// RepairSession handles POST /api/session/:id/repair
+// Keeps the records that parse, plus whole records found inside damaged lines, and drops the rest.
+// copy writes them to a new session and leaves the original alone; replace rewrites the session
+// after saving the original under the data directory's rewind-backups
+func RepairSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	var req RepairRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	if req.Mode == "" {
+		req.Mode = "copy"
+	}
+	if req.Mode != "copy" && req.Mode != "replace" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "mode must be copy or replace")
+		return
+	}
+	path := sessionFileFor(c, sessionID)
+	if path == "" {
+		return
+	}
+	if req.Mode == "replace" && IsSessionLoading(sessionID) {
+		respondError(c, http.StatusConflict, ErrSessionBusy, "This session is already processing a request")
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	report, kept := validateTranscript(sessionID, data)
+	if len(kept) == 0 {
+		respondError(c, http.StatusUnprocessableEntity, ErrValidationFailed, "No records could be recovered")
+		return
+	}
+	recovered := append(bytes.Join(kept, []byte("\n")), '\n')
+	resp := RepairResponse{SessionID: sessionID, Kept: len(kept), Dropped: report.InvalidLines - report.Salvageable, Salvaged: report.Salvageable}
+
+	if req.Mode == "copy" {
+		copyID := newUUID()
+		forked, err := forkTranscript(recovered, copyID)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to copy session", err.Error())
+			return
+		}
+		if err := writeFileAtomic(filepath.Join(filepath.Dir(path), copyID+".jsonl"), forked); err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to write recovered session", err.Error())
+			return
+		}
+		recordSessionOwner(copyID, ownerID(currentUser(c)))
+		resp.SessionID = copyID
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+
+	if resp.Backup, err = backupSessionFile(sessionID, data); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to back up session", err.Error())
+		return
+	}
+	if err := writeFileAtomic(path, recovered); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to repair session", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
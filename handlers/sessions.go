@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,12 +21,25 @@ type Session struct {
 	FullPath     string `json:"fullPath"`
 	FileMtime    int64  `json:"fileMtime"`
 	FirstPrompt  string `json:"firstPrompt"`
+	Summary      string `json:"summary,omitempty"` // from POST /api/session/:id/summarize
 	MessageCount int    `json:"messageCount"`
 	Created      string `json:"created"`
 	Modified     string `json:"modified"`
 	GitBranch    string `json:"gitBranch"`
 	ProjectPath  string `json:"projectPath"`
 	IsSidechain  bool   `json:"isSidechain"`
+	Agent        string `json:"agent,omitempty"`  // agent CLI that ran the session; empty for claude
+	Pinned       bool   `json:"pinned,omitempty"` // kept by POST /api/storage/cleanup
+	// Rated messages, from POST /api/session/:id/messages/:uuid/feedback
+	Feedback *FeedbackCounts `json:"feedback,omitempty"`
+	// Errors counts failed tool calls and API errors; only known for transcripts the server parsed
+	Errors int `json:"errors,omitempty"`
+
+	// Set by GET /api/session/:id/info only
+	ContextUsage *ContextUsage   `json:"contextUsage,omitempty"`
+	Exports      []SessionExport `json:"exports,omitempty"` // from POST /api/session/:id/export/gist
+	AddDirs      []string        `json:"addDirs,omitempty"` // from PUT /api/session/:id/dirs or a chat's addDirs
+	BranchStatus *BranchStatus   `json:"branchStatus,omitempty"`
 }
 
 // SessionsIndex represents the sessions-index.json structure
@@ -45,24 +59,31 @@ type Message struct {
 	GitBranch   string                 `json:"gitBranch,omitempty"`
 	ParentUUID  *string                `json:"parentUuid,omitempty"`
 	IsSidechain bool                   `json:"isSidechain,omitempty"`
+	Agent       string                 `json:"agent,omitempty"`
+	// IsAPIErrorMessage marks an assistant message claude wrote for a failed API request
+	IsAPIErrorMessage bool `json:"isApiErrorMessage,omitempty"`
 }
 
 // SessionsResponse is the response for ListSessions
 type SessionsResponse struct {
 	Sessions []Session `json:"sessions"`
-	Total    int       `json:"total"`
+	Total    int       `json:"total"`   // sessions returned
+	Matched  int       `json:"matched"` // sessions matching the filters, before limit
 }
 
 // HistoryResponse is the response for GetSessionHistory
 type HistoryResponse struct {
-	Messages []Message `json:"messages"`
-	Total    int       `json:"total"`
-	SessionID string   `json:"sessionId"`
+	Messages  []Message `json:"messages"`
+	Total     int       `json:"total"`
+	SessionID string    `json:"sessionId"`
+	LastUUID  string    `json:"lastUuid,omitempty"` // cursor for since_uuid on incremental fetches
+	Reset     bool      `json:"reset,omitempty"`    // since_uuid wasn't found; messages is the full (limited) history
 }
 
 // SessionDirtyCheckRequest represents the request for checking multiple sessions' dirty status
 type SessionDirtyCheckRequest struct {
-	Sessions []SessionCheckInfo `json:"sessions"`
+	Sessions      []SessionCheckInfo `json:"sessions"`
+	IncludeCounts bool               `json:"includeCounts,omitempty"` // also report message counts of dirty sessions
 }
 
 // SessionCheckInfo contains info for checking a single session's dirty status
@@ -78,8 +99,9 @@ type SessionDirtyCheckResponse struct {
 
 // DirtySessionInfo contains info about a dirty session
 type DirtySessionInfo struct {
-	SessionID string `json:"sessionId"`
-	NewMtime  int64  `json:"newMtime"`
+	SessionID    string `json:"sessionId"`
+	NewMtime     int64  `json:"newMtime"`
+	MessageCount *int   `json:"messageCount,omitempty"` // with includeCounts
 }
 
 // getClaudeDir returns the Claude directory path (~/.claude)
@@ -139,7 +161,11 @@ func parseUnindexedSession(filePath string, dirName string) *Session {
 	var firstPrompt string
 	var created string
 	var cwd string
+	var agent string
+	var gitBranch string
+	isSidechain := false
 	messageCount := 0
+	errorCount := 0
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -155,7 +181,12 @@ func parseUnindexedSession(filePath string, dirName string) *Session {
 		// Get created timestamp from first message
 		if created == "" && msg.Timestamp != "" {
 			created = msg.Timestamp
+			isSidechain = msg.IsSidechain
+		}
+		if msg.GitBranch != "" {
+			gitBranch = msg.GitBranch
 		}
+		errorCount += messageErrorCount(msg)
 
 		// Get working directory from summary message
 		if msg.Type == "summary" && msg.CWD != "" {
@@ -165,6 +196,9 @@ func parseUnindexedSession(filePath string, dirName string) *Session {
 		// Count user/assistant messages
 		if msg.Type == "user" || msg.Type == "human" || msg.Type == "assistant" {
 			messageCount++
+			if msg.Agent != "" {
+				agent = msg.Agent
+			}
 
 			// Get first prompt from first user message
 			if firstPrompt == "" && (msg.Type == "user" || msg.Type == "human") {
@@ -212,14 +246,32 @@ func parseUnindexedSession(filePath string, dirName string) *Session {
 		Created:      created,
 		Modified:     fileInfo.ModTime().Format("2006-01-02T15:04:05.000Z"),
 		ProjectPath:  projectPath,
+		GitBranch:    gitBranch,
+		IsSidechain:  isSidechain,
+		Agent:        agent,
+		Errors:       errorCount,
 	}
 }
 
 // ListSessions handles GET /api/sessions
 // Query parameters:
 //   - work_dir: filter sessions by project path
+//   - feedback: only sessions with rated messages: any, up, down, or flagged (a note or labels)
+//   - since, until: only sessions last modified in this range (RFC 3339 or YYYY-MM-DD, both inclusive)
+//   - min_messages: only sessions with at least this many messages
+//   - branch: only sessions on this git branch
+//   - has_errors: true for sessions with failed tool calls or API errors, false for those without
+//   - include_sidechains: false leaves out sidechain sessions
+//   - q: only sessions whose first prompt contains this text (case-insensitive)
+//   - limit: maximum sessions to return (default limits.sessionListLimit, max 1000)
 func ListSessions(c *gin.Context) {
 	workDir := c.Query("work_dir")
+	feedback := c.Query("feedback")
+	filter, err := parseSessionFilter(c)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
 	projectsDir := getProjectsDir()
 
 	// Check if projects directory exists
@@ -231,94 +283,52 @@ func ListSessions(c *gin.Context) {
 		return
 	}
 
-	// Read all project directories
-	entries, err := os.ReadDir(projectsDir)
+	// Scan project directories concurrently; unchanged files come from the parse cache
+	allSessions, err := scanSessions(workDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to read projects directory",
-			"details": err.Error(),
-		})
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory", err.Error())
 		return
 	}
 
-	var allSessions []Session
-	indexedSessionIDs := make(map[string]bool)
-
-	// Iterate through each project directory
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		projectDir := filepath.Join(projectsDir, entry.Name())
-		indexPath := filepath.Join(projectDir, "sessions-index.json")
-
-		// Derive correct projectPath from directory name
-		// e.g., -home-seo -> /home/seo
-		correctProjectPath := strings.ReplaceAll(entry.Name(), "-", "/")
-		if !strings.HasPrefix(correctProjectPath, "/") {
-			correctProjectPath = "/" + correctProjectPath
-		}
-
-		// Try to read sessions-index.json if it exists
-		if data, err := os.ReadFile(indexPath); err == nil {
-			var index SessionsIndex
-			if err := json.Unmarshal(data, &index); err == nil {
-				// Filter sessions by work_dir if specified
-				for _, session := range index.Entries {
-					// Override projectPath with correct value derived from directory
-					session.ProjectPath = correctProjectPath
-					if workDir == "" || session.ProjectPath == workDir {
-						allSessions = append(allSessions, session)
-						indexedSessionIDs[session.SessionID] = true
-					}
-				}
+	// Only show sessions owned by the current user
+	if user := currentUser(c); user != nil && !user.IsAdmin() {
+		visible := allSessions[:0]
+		for _, session := range allSessions {
+			if userCanAccessSession(user, session.SessionID) {
+				visible = append(visible, session)
 			}
 		}
+		allSessions = visible
+	}
 
-		// Scan for .jsonl files not in the index
-		files, err := os.ReadDir(projectDir)
-		if err != nil {
-			continue
-		}
-
-		for _, file := range files {
-			if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
-				continue
-			}
-
-			sessionID := strings.TrimSuffix(file.Name(), ".jsonl")
-
-			// Skip if already in index
-			if indexedSessionIDs[sessionID] {
-				continue
-			}
-
-			// Parse the unindexed session
-			filePath := filepath.Join(projectDir, file.Name())
-			session := parseUnindexedSession(filePath, entry.Name())
-			if session != nil {
-				// Filter by work_dir if specified
-				if workDir == "" || session.ProjectPath == workDir {
-					allSessions = append(allSessions, *session)
-				}
+	attachFeedback(allSessions)
+	if feedback != "" {
+		matching := allSessions[:0]
+		for _, session := range allSessions {
+			if matchesFeedbackFilter(session.Feedback, feedback) {
+				matching = append(matching, session)
 			}
 		}
+		allSessions = matching
 	}
+	allSessions = filter.apply(allSessions)
 
 	// Sort sessions by modified date (descending)
 	sort.Slice(allSessions, func(i, j int) bool {
 		return allSessions[i].Modified > allSessions[j].Modified
 	})
 
-	// Limit to 50 sessions
-	if len(allSessions) > 50 {
-		allSessions = allSessions[:50]
+	matched := len(allSessions)
+	if len(allSessions) > filter.limit {
+		allSessions = allSessions[:filter.limit]
 	}
+	attachSummaries(allSessions)
+	attachPins(allSessions)
 
 	c.JSON(http.StatusOK, SessionsResponse{
 		Sessions: allSessions,
 		Total:    len(allSessions),
+		Matched:  matched,
 	})
 }
 
@@ -326,11 +336,15 @@ func ListSessions(c *gin.Context) {
 // Returns session metadata (firstPrompt, projectPath, etc.) for a single session
 func GetSession(c *gin.Context) {
 	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
 	projectsDir := getProjectsDir()
 
 	entries, err := os.ReadDir(projectsDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read projects directory"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory")
 		return
 	}
 
@@ -357,6 +371,14 @@ func GetSession(c *gin.Context) {
 					if session.SessionID == sessionID {
 						// Override projectPath with correct value derived from directory
 						session.ProjectPath = correctProjectPath
+						session.ContextUsage = sessionContextUsage(sessionID, findSessionFile(sessionID))
+						if summary := getSessionSummary(sessionID); summary != nil {
+							session.Summary = summary.Summary
+						}
+						session.Pinned = isSessionPinned(sessionID)
+						session.Exports = sessionExports(sessionID)
+						session.AddDirs = sessionAddDirs(sessionID)
+						session.BranchStatus = sessionBranchStatus(session.ProjectPath, session.GitBranch)
 						c.JSON(http.StatusOK, session)
 						return
 					}
@@ -367,15 +389,23 @@ func GetSession(c *gin.Context) {
 		// Check .jsonl file directly
 		sessionFile := filepath.Join(projectDir, sessionID+".jsonl")
 		if _, err := os.Stat(sessionFile); err == nil {
-			session := parseUnindexedSession(sessionFile, entry.Name())
+			session := parseSessionCached(sessionFile, entry.Name())
 			if session != nil {
+				session.ContextUsage = sessionContextUsage(sessionID, sessionFile)
+				if summary := getSessionSummary(sessionID); summary != nil {
+					session.Summary = summary.Summary
+				}
+				session.Pinned = isSessionPinned(sessionID)
+				session.Exports = sessionExports(sessionID)
+				session.AddDirs = sessionAddDirs(sessionID)
+				session.BranchStatus = sessionBranchStatus(session.ProjectPath, session.GitBranch)
 				c.JSON(http.StatusOK, session)
 				return
 			}
 		}
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
 }
 
 // DeleteSession handles DELETE /api/sessions/:session_id
@@ -385,6 +415,10 @@ func GetSession(c *gin.Context) {
 //   - project: project path (optional, used to find the correct project directory)
 func DeleteSession(c *gin.Context) {
 	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
 	projectPath := c.Query("project")
 	projectsDir := getProjectsDir()
 
@@ -400,10 +434,7 @@ func DeleteSession(c *gin.Context) {
 		// Search for the session file in all project directories
 		entries, err := os.ReadDir(projectsDir)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to read projects directory",
-				"details": err.Error(),
-			})
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory", err.Error())
 			return
 		}
 
@@ -423,29 +454,33 @@ func DeleteSession(c *gin.Context) {
 
 	// Check if session file was found
 	if sessionFilePath == "" {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Session %s not found", sessionID),
-		})
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, fmt.Sprintf("Session %s not found", sessionID))
 		return
 	}
 
 	// Check if file exists
 	if _, err := os.Stat(sessionFilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Session file not found: %s", sessionID),
-		})
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, fmt.Sprintf("Session file not found: %s", sessionID))
 		return
 	}
 
-	// Delete the session file
-	if err := os.Remove(sessionFilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to delete session file",
-			"details": err.Error(),
-		})
+	if err := removeSessionFile(projectDir, sessionID); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to delete session file", err.Error())
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"sessionId": sessionID,
+	})
+}
+
+// removeSessionFile deletes a session's transcript, its sessions-index.json entry, and the server's metadata about it
+func removeSessionFile(projectDir string, sessionID string) error {
+	if err := os.Remove(filepath.Join(projectDir, sessionID+".jsonl")); err != nil {
+		return err
+	}
+
 	// Update sessions-index.json if it exists
 	indexPath := filepath.Join(projectDir, "sessions-index.json")
 	if data, err := os.ReadFile(indexPath); err == nil {
@@ -466,11 +501,19 @@ func DeleteSession(c *gin.Context) {
 			}
 		}
 	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success":   true,
-		"sessionId": sessionID,
-	})
+	forgetTodos(sessionID)
+	forgetContextUsage(sessionID)
+	forgetSessionSummary(sessionID)
+	unpinSession(sessionID)
+	forgetDraft(sessionID)
+	forgetFeedback(sessionID)
+	forgetExports(sessionID)
+	forgetSnapshots(sessionID)
+	forgetSessionDirs(sessionID)
+	forgetShares(sessionID)
+	closeStreamSession(sessionID)
+	forgetHookEvents(sessionID)
+	return nil
 }
 
 // GetSessionHistory handles GET /api/sessions/:session_id/history
@@ -480,28 +523,39 @@ func DeleteSession(c *gin.Context) {
 //   - project: project path (optional, used to find the correct project directory)
 //   - limit: maximum number of messages to return (default: 100)
 //   - offset: number of messages to skip (default: 0)
+//   - since_uuid: only return messages appended after this message (limit is not applied)
+//   - since_timestamp: only return messages after this RFC 3339 time (limit is not applied)
 func GetSessionHistory(c *gin.Context) {
 	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
 	projectPath := c.Query("project")
 	limitStr := c.DefaultQuery("limit", "100")
 	offsetStr := c.DefaultQuery("offset", "0")
 
 	limit, err := strconv.Atoi(limitStr)
 	if err != nil || limit < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid limit parameter",
-		})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid limit parameter")
 		return
 	}
 
 	offset, err := strconv.Atoi(offsetStr)
 	if err != nil || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid offset parameter",
-		})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid offset parameter")
 		return
 	}
 
+	sinceUUID := c.Query("since_uuid")
+	var sinceTime time.Time
+	if ts := c.Query("since_timestamp"); ts != "" && sinceUUID == "" {
+		if sinceTime, err = time.Parse(time.RFC3339Nano, ts); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid since_timestamp parameter")
+			return
+		}
+	}
+
 	projectsDir := getProjectsDir()
 	var sessionFilePath string
 
@@ -514,10 +568,7 @@ func GetSessionHistory(c *gin.Context) {
 		// Search for the session file in all project directories
 		entries, err := os.ReadDir(projectsDir)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to read projects directory",
-				"details": err.Error(),
-			})
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory", err.Error())
 			return
 		}
 
@@ -536,27 +587,40 @@ func GetSessionHistory(c *gin.Context) {
 
 	// Check if session file was found
 	if sessionFilePath == "" {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Session %s not found", sessionID),
-		})
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, fmt.Sprintf("Session %s not found", sessionID))
 		return
 	}
 
 	// Check if file exists
 	if _, err := os.Stat(sessionFilePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": fmt.Sprintf("Session file not found: %s", sessionID),
-		})
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, fmt.Sprintf("Session file not found: %s", sessionID))
 		return
 	}
 
+	// Incremental fetch: read only what was appended after the client's cursor
+	reset := false
+	if sinceUUID != "" || !sinceTime.IsZero() {
+		messages, total, lastUUID, found, err := historySince(sessionFilePath, sinceUUID, sinceTime)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+			return
+		}
+		if found {
+			c.JSON(http.StatusOK, HistoryResponse{
+				Messages:  messages,
+				Total:     total,
+				SessionID: sessionID,
+				LastUUID:  lastUUID,
+			})
+			return
+		}
+		reset = true
+	}
+
 	// Read and parse the .jsonl file
 	file, err := os.Open(sessionFilePath)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to open session file",
-			"details": err.Error(),
-		})
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to open session file", err.Error())
 		return
 	}
 	defer file.Close()
@@ -588,15 +652,18 @@ func GetSessionHistory(c *gin.Context) {
 	}
 
 	if err := scanner.Err(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Failed to read session file",
-			"details": err.Error(),
-		})
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
 		return
 	}
 
 	total := len(messages)
 
+	// The cursor is the newest message even when limit=0 returns none of them
+	lastUUID := ""
+	if total > 0 {
+		lastUUID = messages[total-1].UUID
+	}
+
 	// Return the LAST N messages (most recent) instead of first N
 	// This ensures users see their latest conversation
 	if total > limit {
@@ -607,6 +674,8 @@ func GetSessionHistory(c *gin.Context) {
 		Messages:  messages,
 		Total:     total,
 		SessionID: sessionID,
+		LastUUID:  lastUUID,
+		Reset:     reset,
 	})
 }
 
@@ -615,41 +684,44 @@ func GetSessionHistory(c *gin.Context) {
 func CheckSessionsDirty(c *gin.Context) {
 	var req SessionDirtyCheckRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
 		return
 	}
 
-	projectsDir := getProjectsDir()
-	var dirtySessions []DirtySessionInfo
-
+	user := currentUser(c)
+	lastMtimes := make(map[string]int64, len(req.Sessions))
+	var sessionIDs []string
 	for _, check := range req.Sessions {
-		// Search for the session file
-		entries, err := os.ReadDir(projectsDir)
-		if err != nil {
+		if check.SessionID == "" || strings.ContainsAny(check.SessionID, `/\`) || !userCanAccessSession(user, check.SessionID) {
 			continue
 		}
+		lastMtimes[check.SessionID] = check.LastMtime
+		sessionIDs = append(sessionIDs, check.SessionID)
+	}
 
-		for _, entry := range entries {
-			if !entry.IsDir() {
-				continue
-			}
-
-			sessionFile := filepath.Join(projectsDir, entry.Name(), check.SessionID+".jsonl")
-			fileInfo, err := os.Stat(sessionFile)
-			if err != nil {
-				continue
-			}
+	// Resolve every path from the catalog in one pass, then stat them concurrently
+	paths := resolveSessionPaths(sessionIDs)
+	infos := statSessionFiles(paths)
 
-			// Compare mtime
-			newMtime := fileInfo.ModTime().Unix()
-			if newMtime > check.LastMtime {
-				dirtySessions = append(dirtySessions, DirtySessionInfo{
-					SessionID: check.SessionID,
-					NewMtime:  newMtime,
-				})
+	dirtySessions := make([]DirtySessionInfo, 0)
+	for _, id := range sessionIDs {
+		info, ok := infos[id]
+		if !ok {
+			continue
+		}
+		newMtime := info.ModTime().Unix()
+		if newMtime <= lastMtimes[id] {
+			continue
+		}
+		dirty := DirtySessionInfo{SessionID: id, NewMtime: newMtime}
+		if req.IncludeCounts {
+			count := 0
+			if session := parseSessionCached(paths[id], filepath.Base(filepath.Dir(paths[id]))); session != nil {
+				count = session.MessageCount
 			}
-			break // Found the file, no need to check other directories
+			dirty.MessageCount = &count
 		}
+		dirtySessions = append(dirtySessions, dirty)
 	}
 
 	c.JSON(http.StatusOK, SessionDirtyCheckResponse{
@@ -661,11 +733,15 @@ func CheckSessionsDirty(c *gin.Context) {
 // Returns the modification time of a session file
 func GetSessionMtime(c *gin.Context) {
 	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
 	projectsDir := getProjectsDir()
 
 	entries, err := os.ReadDir(projectsDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read projects directory"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to read projects directory")
 		return
 	}
 
@@ -687,5 +763,81 @@ func GetSessionMtime(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+}
+
+// findSessionFile returns the .jsonl path for a session, or "" if not found
+func findSessionFile(sessionID string) string {
+	if sessionID == "" || strings.ContainsAny(sessionID, `/\`) {
+		return ""
+	}
+
+	path := resolveSessionPaths([]string{sessionID})[sessionID]
+	if path == "" {
+		return ""
+	}
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	// Moved or deleted since the catalog was built
+	refreshSessionCatalog()
+	sessionParseMu.Lock()
+	defer sessionParseMu.Unlock()
+	return sessionCatalog[sessionID]
+}
+
+// recentSessionFiles returns up to limit session files whose IDs keep accepts, most recently modified first
+func recentSessionFiles(limit int, keep func(sessionID string) bool) []string {
+	projectsDir := getProjectsDir()
+	entries, err := os.ReadDir(projectsDir)
+	if err != nil {
+		return nil
+	}
+
+	type sessionFile struct {
+		path  string
+		mtime int64
+	}
+	var files []sessionFile
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		projectDir := filepath.Join(projectsDir, entry.Name())
+		projectFiles, err := os.ReadDir(projectDir)
+		if err != nil {
+			continue
+		}
+		for _, file := range projectFiles {
+			if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
+				continue
+			}
+			if !keep(strings.TrimSuffix(file.Name(), ".jsonl")) {
+				continue
+			}
+			info, err := file.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, sessionFile{
+				path:  filepath.Join(projectDir, file.Name()),
+				mtime: info.ModTime().UnixNano(),
+			})
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime > files[j].mtime
+	})
+	if len(files) > limit {
+		files = files[:limit]
+	}
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.path
+	}
+	return paths
 }
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSessionScanWorkers bounds how many project directories are scanned at once
+const maxSessionScanWorkers = 8
+
+// sessionCWDMaxLines bounds how far into a transcript its first user message is looked for
+const sessionCWDMaxLines = 200
+
+// projectDirNameRegex matches the characters claude replaces with "-" in a project directory name
+var projectDirNameRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+// parsedSession is a cached parseUnindexedSession result, valid while the file is unchanged
+type parsedSession struct {
+	modTime time.Time
+	size    int64
+	session *Session // nil for files without messages
+}
+
+var (
+	sessionParseMu    sync.Mutex
+	sessionParseCache = make(map[string]parsedSession) // keyed by .jsonl path
+	sessionCatalog    = make(map[string]string)        // session ID -> .jsonl path, from the last scan
+	sessionCWDCache   = make(map[string]string)        // .jsonl path -> cwd of its first user message
+
+	// sessionScanStatus is the outcome of the last full scan, for GET /health/ready
+	sessionScanStatus struct {
+		at       time.Time // zero until a full scan finishes
+		duration time.Duration
+		sessions int
+		err      error
+	}
+)
+
+// projectPathFromDir converts a project directory name back to its path
+// e.g., -home-seo -> /home/seo
+func projectPathFromDir(dirName string) string {
+	projectPath := strings.ReplaceAll(dirName, "-", "/")
+	if !strings.HasPrefix(projectPath, "/") {
+		projectPath = "/" + projectPath
+	}
+	return projectPath
+}
+
+// sessionFileCWD returns the working directory recorded in a transcript's first user message, cached per file
+// It is only trusted when it encodes to the transcript's project directory name; "" otherwise
+func sessionFileCWD(path string) string {
+	sessionParseMu.Lock()
+	cwd, ok := sessionCWDCache[path]
+	sessionParseMu.Unlock()
+	if ok {
+		return cwd
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+	reader := bufio.NewReader(file)
+	for i := 0; i < sessionCWDMaxLines && cwd == ""; i++ {
+		line, err := reader.ReadBytes('\n')
+		var msg struct {
+			Type string `json:"type"`
+			CWD  string `json:"cwd"`
+		}
+		if json.Unmarshal(line, &msg) == nil && (msg.Type == "user" || msg.Type == "human") {
+			cwd = msg.CWD
+		}
+		if err != nil {
+			break
+		}
+	}
+	dirName := filepath.Base(filepath.Dir(path))
+	if cwd == "" || !filepath.IsAbs(cwd) ||
+		(projectDirNameRegex.ReplaceAllString(cwd, "-") != dirName && hashProjectPath(cwd) != dirName) {
+		// Not cached: a transcript just started may not have its first message yet
+		return ""
+	}
+	cwd = filepath.Clean(cwd)
+	sessionParseMu.Lock()
+	sessionCWDCache[path] = cwd
+	sessionParseMu.Unlock()
+	return cwd
+}
+
+// parseSessionCached is parseUnindexedSession memoized on the file's mtime and size
+func parseSessionCached(filePath string, dirName string) *Session {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil
+	}
+
+	sessionParseMu.Lock()
+	cached, ok := sessionParseCache[filePath]
+	sessionParseMu.Unlock()
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		if cached.session == nil {
+			return nil
+		}
+		session := *cached.session
+		return &session
+	}
+
+	session := parseUnindexedSession(filePath, dirName)
+	sessionParseMu.Lock()
+	sessionParseCache[filePath] = parsedSession{modTime: info.ModTime(), size: info.Size(), session: session}
+	sessionParseMu.Unlock()
+	if session == nil {
+		return nil
+	}
+	copied := *session
+	return &copied
+}
+
+// scanProjectDir returns a project directory's indexed sessions plus its unindexed .jsonl files
+// projectPath is always derived from the directory name, which is the source of truth
+// seen, if set, receives every session file in the directory
+func scanProjectDir(projectsDir string, dirName string, seen func(string)) []Session {
+	projectDir := filepath.Join(projectsDir, dirName)
+	projectPath := projectPathFromDir(dirName)
+
+	var sessions []Session
+	indexed := make(map[string]bool)
+	if data, err := os.ReadFile(filepath.Join(projectDir, "sessions-index.json")); err == nil {
+		var index SessionsIndex
+		if err := json.Unmarshal(data, &index); err == nil {
+			for _, session := range index.Entries {
+				session.ProjectPath = projectPath
+				sessions = append(sessions, session)
+				indexed[session.SessionID] = true
+			}
+		}
+	}
+
+	files, err := os.ReadDir(projectDir)
+	if err != nil {
+		return sessions
+	}
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
+			continue
+		}
+		filePath := filepath.Join(projectDir, file.Name())
+		if seen != nil {
+			seen(filePath)
+		}
+		if indexed[strings.TrimSuffix(file.Name(), ".jsonl")] {
+			continue
+		}
+		if session := parseSessionCached(filePath, dirName); session != nil {
+			sessions = append(sessions, *session)
+		}
+	}
+	return sessions
+}
+
+// scanSessions scans project directories with a bounded worker pool
+// If workDir is set, only that project's directory is read
+// Results keep directory order; a full scan also rebuilds the session catalog
+// and evicts cache entries for deleted files
+func scanSessions(workDir string) ([]Session, error) {
+	start := time.Now()
+	projectsDir := getProjectsDir()
+	entries, err := os.ReadDir(projectsDir)
+	if err != nil {
+		if workDir == "" {
+			sessionParseMu.Lock()
+			sessionScanStatus.at, sessionScanStatus.duration, sessionScanStatus.err = time.Now(), time.Since(start), err
+			sessionParseMu.Unlock()
+		}
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if workDir != "" && projectPathFromDir(entry.Name()) != workDir {
+			continue
+		}
+		dirs = append(dirs, entry.Name())
+	}
+
+	workers := runtime.NumCPU()
+	if workers > maxSessionScanWorkers {
+		workers = maxSessionScanWorkers
+	}
+	if workers > len(dirs) {
+		workers = len(dirs)
+	}
+
+	results := make([][]Session, len(dirs))
+	var seenMu sync.Mutex
+	seenFiles := make(map[string]bool)
+	seen := func(path string) {
+		seenMu.Lock()
+		seenFiles[path] = true
+		seenMu.Unlock()
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = scanProjectDir(projectsDir, dirs[i], seen)
+			}
+		}()
+	}
+	for i := range dirs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	sessionParseMu.Lock()
+	if workDir == "" {
+		for path := range sessionParseCache {
+			if !seenFiles[path] {
+				delete(sessionParseCache, path)
+			}
+		}
+		for path := range sessionCWDCache {
+			if !seenFiles[path] {
+				delete(sessionCWDCache, path)
+			}
+		}
+		sessionCatalog = make(map[string]string, len(seenFiles))
+	}
+	for path := range seenFiles {
+		sessionCatalog[strings.TrimSuffix(filepath.Base(path), ".jsonl")] = path
+	}
+	if workDir == "" {
+		sessionScanStatus.at, sessionScanStatus.duration, sessionScanStatus.err = time.Now(), time.Since(start), nil
+		sessionScanStatus.sessions = len(seenFiles)
+	}
+	sessionParseMu.Unlock()
+	if workDir == "" {
+		forgetHistoryIndexes(seenFiles)
+	}
+
+	var sessions []Session
+	for _, dirSessions := range results {
+		sessions = append(sessions, dirSessions...)
+	}
+	return sessions, nil
+}
+
+// refreshSessionCatalog rebuilds the session ID -> file map from a directory listing, without parsing
+func refreshSessionCatalog() {
+	projectsDir := getProjectsDir()
+	entries, err := os.ReadDir(projectsDir)
+	if err != nil {
+		return
+	}
+	catalog := make(map[string]string)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(projectsDir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if !file.IsDir() && strings.HasSuffix(file.Name(), ".jsonl") {
+				catalog[strings.TrimSuffix(file.Name(), ".jsonl")] = filepath.Join(projectsDir, entry.Name(), file.Name())
+			}
+		}
+	}
+	sessionParseMu.Lock()
+	sessionCatalog = catalog
+	sessionParseMu.Unlock()
+}
+
+// resolveSessionPaths maps session IDs to their .jsonl files
+// The catalog is refreshed at most once, when some ID isn't in it; unknown IDs are omitted
+func resolveSessionPaths(sessionIDs []string) map[string]string {
+	paths := make(map[string]string, len(sessionIDs))
+	lookup := func() bool {
+		sessionParseMu.Lock()
+		defer sessionParseMu.Unlock()
+		complete := true
+		for _, id := range sessionIDs {
+			if path, ok := sessionCatalog[id]; ok {
+				paths[id] = path
+			} else {
+				complete = false
+			}
+		}
+		return complete
+	}
+	if !lookup() {
+		refreshSessionCatalog()
+		lookup()
+	}
+	return paths
+}
+
+// statSessionFiles stats files concurrently with the scan worker bound
+// Missing files are dropped from the catalog and omitted from the result
+func statSessionFiles(paths map[string]string) map[string]os.FileInfo {
+	type result struct {
+		id   string
+		info os.FileInfo
+	}
+	ids := make(chan string)
+	results := make(chan result)
+	workers := maxSessionScanWorkers
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				info, err := os.Stat(paths[id])
+				if err != nil {
+					info = nil
+				}
+				results <- result{id, info}
+			}
+		}()
+	}
+	go func() {
+		for id := range paths {
+			ids <- id
+		}
+		close(ids)
+		wg.Wait()
+		close(results)
+	}()
+
+	infos := make(map[string]os.FileInfo, len(paths))
+	var missing []string
+	for r := range results {
+		if r.info == nil {
+			missing = append(missing, r.id)
+			continue
+		}
+		infos[r.id] = r.info
+	}
+	if len(missing) > 0 {
+		sessionParseMu.Lock()
+		for _, id := range missing {
+			delete(sessionCatalog, id)
+		}
+		sessionParseMu.Unlock()
+	}
+	return infos
+}
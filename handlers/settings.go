@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SettingsFile represents a Claude Code settings.json at a given scope
+type SettingsFile struct {
+	Scope    string                 `json:"scope"` // "user" or "project"
+	Path     string                 `json:"path"`
+	Exists   bool                   `json:"exists"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// UpdateSettingsRequest represents the request body for PUT /api/settings
+type UpdateSettingsRequest struct {
+	Scope    string                 `json:"scope"`
+	WorkDir  string                 `json:"workDir"`
+	Settings map[string]interface{} `json:"settings"`
+}
+
+// validPermissionModes lists the accepted permissions.defaultMode values
+var validPermissionModes = map[string]bool{
+	"default":           true,
+	"acceptEdits":       true,
+	"plan":              true,
+	"bypassPermissions": true,
+}
+
+// validHookEvents lists the hook events supported by Claude Code
+var validHookEvents = map[string]bool{
+	"PreToolUse":       true,
+	"PostToolUse":      true,
+	"Notification":     true,
+	"UserPromptSubmit": true,
+	"Stop":             true,
+	"SubagentStop":     true,
+	"PreCompact":       true,
+	"SessionStart":     true,
+	"SessionEnd":       true,
+}
+
+// settingsPath returns the settings.json path for a scope
+func settingsPath(scope string, workDir string) (string, error) {
+	switch scope {
+	case "user":
+		return filepath.Join(getClaudeDir(), "settings.json"), nil
+	case "project":
+		if workDir == "" {
+			return "", fmt.Errorf("workDir is required for project scope")
+		}
+		return filepath.Join(workDir, ".claude", "settings.json"), nil
+	default:
+		return "", fmt.Errorf("invalid scope: %s", scope)
+	}
+}
+
+// loadSettingsFile reads a settings.json file, returning an empty object if missing
+func loadSettingsFile(path string) (map[string]interface{}, bool, error) {
+	settings := make(map[string]interface{})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return settings, false, nil
+		}
+		return nil, false, err
+	}
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, true, err
+	}
+	return settings, true, nil
+}
+
+// writeJSONFileAtomic writes v as indented JSON via a temp file and rename
+func writeJSONFileAtomic(path string, v interface{}) error {
+	return writeJSONFileAtomicMode(path, v, 0644)
+}
+
+// writeJSONFileAtomicMode is writeJSONFileAtomic with explicit file permissions
+func writeJSONFileAtomicMode(path string, v interface{}, perm os.FileMode) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomicMode(path, append(data, '\n'), perm)
+}
+
+// isStringArray reports whether v is a JSON array of strings
+func isStringArray(v interface{}) bool {
+	arr, ok := v.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, item := range arr {
+		if _, ok := item.(string); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// validateSettings checks settings against the known settings.json schema
+// Unknown keys are allowed so newer CLI settings are not rejected
+func validateSettings(settings map[string]interface{}) []string {
+	var errs []string
+
+	if v, ok := settings["model"]; ok {
+		if _, ok := v.(string); !ok {
+			errs = append(errs, "model: must be a string")
+		}
+	}
+
+	if v, ok := settings["env"]; ok {
+		env, ok := v.(map[string]interface{})
+		if !ok {
+			errs = append(errs, "env: must be an object")
+		} else {
+			for key, val := range env {
+				if _, ok := val.(string); !ok {
+					errs = append(errs, fmt.Sprintf("env.%s: must be a string", key))
+				}
+			}
+		}
+	}
+
+	if v, ok := settings["permissions"]; ok {
+		perms, ok := v.(map[string]interface{})
+		if !ok {
+			errs = append(errs, "permissions: must be an object")
+		} else {
+			for _, key := range []string{"allow", "deny", "ask", "additionalDirectories"} {
+				if val, ok := perms[key]; ok && !isStringArray(val) {
+					errs = append(errs, fmt.Sprintf("permissions.%s: must be an array of strings", key))
+				}
+			}
+			if val, ok := perms["defaultMode"]; ok {
+				mode, ok := val.(string)
+				if !ok || !validPermissionModes[mode] {
+					errs = append(errs, "permissions.defaultMode: must be one of default, acceptEdits, plan, bypassPermissions")
+				}
+			}
+		}
+	}
+
+	if v, ok := settings["hooks"]; ok {
+		errs = append(errs, validateHooks(v)...)
+	}
+
+	for _, key := range []string{"cleanupPeriodDays"} {
+		if v, ok := settings[key]; ok {
+			if _, ok := v.(float64); !ok {
+				errs = append(errs, fmt.Sprintf("%s: must be a number", key))
+			}
+		}
+	}
+
+	for _, key := range []string{"includeCoAuthoredBy"} {
+		if v, ok := settings[key]; ok {
+			if _, ok := v.(bool); !ok {
+				errs = append(errs, fmt.Sprintf("%s: must be a boolean", key))
+			}
+		}
+	}
+
+	sort.Strings(errs)
+	return errs
+}
+
+// validateHooks checks the hooks section: event -> [{matcher, hooks: [{type, command, timeout}]}]
+func validateHooks(v interface{}) []string {
+	var errs []string
+
+	hooks, ok := v.(map[string]interface{})
+	if !ok {
+		return []string{"hooks: must be an object"}
+	}
+
+	for event, matchersVal := range hooks {
+		prefix := "hooks." + event
+		if !validHookEvents[event] {
+			errs = append(errs, fmt.Sprintf("%s: unknown hook event", prefix))
+			continue
+		}
+		matchers, ok := matchersVal.([]interface{})
+		if !ok {
+			errs = append(errs, fmt.Sprintf("%s: must be an array", prefix))
+			continue
+		}
+		for i, matcherVal := range matchers {
+			matcherPrefix := fmt.Sprintf("%s[%d]", prefix, i)
+			matcher, ok := matcherVal.(map[string]interface{})
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: must be an object", matcherPrefix))
+				continue
+			}
+			if m, ok := matcher["matcher"]; ok {
+				if _, ok := m.(string); !ok {
+					errs = append(errs, fmt.Sprintf("%s.matcher: must be a string", matcherPrefix))
+				}
+			}
+			commands, ok := matcher["hooks"].([]interface{})
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s.hooks: must be an array", matcherPrefix))
+				continue
+			}
+			for j, cmdVal := range commands {
+				cmdPrefix := fmt.Sprintf("%s.hooks[%d]", matcherPrefix, j)
+				hookCmd, ok := cmdVal.(map[string]interface{})
+				if !ok {
+					errs = append(errs, fmt.Sprintf("%s: must be an object", cmdPrefix))
+					continue
+				}
+				if hookCmd["type"] != "command" {
+					errs = append(errs, fmt.Sprintf("%s.type: must be \"command\"", cmdPrefix))
+				}
+				if command, ok := hookCmd["command"].(string); !ok || command == "" {
+					errs = append(errs, fmt.Sprintf("%s.command: must be a non-empty string", cmdPrefix))
+				}
+				if timeout, ok := hookCmd["timeout"]; ok {
+					if n, ok := timeout.(float64); !ok || n <= 0 {
+						errs = append(errs, fmt.Sprintf("%s.timeout: must be a positive number", cmdPrefix))
+					}
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+// GetSettings handles GET /api/settings
+// Query parameters:
+//   - work_dir: project directory for project-scoped settings (optional)
+func GetSettings(c *gin.Context) {
+	workDir := c.Query("work_dir")
+	if workDir != "" && !requireWorkDirAccess(c, workDir) {
+		return
+	}
+
+	scopes := []string{"user"}
+	if workDir != "" {
+		scopes = append(scopes, "project")
+		watchProject(currentUser(c), workDir)
+	}
+
+	files := make([]SettingsFile, 0, len(scopes))
+	for _, scope := range scopes {
+		path, err := settingsPath(scope, workDir)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+		settings, exists, err := loadSettingsFile(path)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, fmt.Sprintf("Failed to read %s", path), err.Error())
+			return
+		}
+		files = append(files, SettingsFile{
+			Scope:    scope,
+			Path:     path,
+			Exists:   exists,
+			Settings: settings,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings": files,
+	})
+}
+
+// UpdateSettings handles PUT /api/settings
+// Replaces the settings.json at the given scope after schema validation
+func UpdateSettings(c *gin.Context) {
+	var req UpdateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Settings == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "settings is required")
+		return
+	}
+
+	path, err := settingsPath(req.Scope, req.WorkDir)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	if errs := validateSettings(req.Settings); len(errs) > 0 {
+		respondErrorDetails(c, http.StatusUnprocessableEntity, ErrValidationFailed, "Settings validation failed", errs)
+		return
+	}
+
+	if err := writeJSONFileAtomic(path, req.Settings); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to write settings", err.Error())
+		return
+	}
+
+	c.JSON(http.StatusOK, SettingsFile{
+		Scope:    req.Scope,
+		Path:     path,
+		Exists:   true,
+		Settings: req.Settings,
+	})
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// A minimal SFTP (protocol version 3) client covering what remote browsing needs:
+// stat, directory listing, and reading files. Requests are issued one at a time.
+
+const (
+	sshFxpInit    = 1
+	sshFxpVersion = 2
+	sshFxpOpen    = 3
+	sshFxpClose   = 4
+	sshFxpRead    = 5
+	sshFxpOpendir = 11
+	sshFxpReaddir = 12
+	sshFxpStat    = 17
+	sshFxpStatus  = 101
+	sshFxpHandle  = 102
+	sshFxpData    = 103
+	sshFxpName    = 104
+	sshFxpAttrs   = 105
+
+	sshFxOK               = 0
+	sshFxEOF              = 1
+	sshFxNoSuchFile       = 2
+	sshFxPermissionDenied = 3
+
+	sshFxfRead = 0x1
+
+	sftpAttrSize        = 0x1
+	sftpAttrUIDGID      = 0x2
+	sftpAttrPermissions = 0x4
+	sftpAttrACModTime   = 0x8
+	sftpAttrExtended    = 0x80000000
+
+	sftpReadChunk     = 32 * 1024
+	sftpMaxPacketSize = 4 * 1024 * 1024
+)
+
+// sftpAttrs is the subset of file attributes the UI shows
+type sftpAttrs struct {
+	Size    int64
+	Mode    uint32
+	ModTime int64
+}
+
+func (a sftpAttrs) isDir() bool {
+	return a.Mode&0170000 == 0040000
+}
+
+// sftpEntry is one directory entry
+type sftpEntry struct {
+	Name  string
+	Attrs sftpAttrs
+}
+
+// sftpStatusError is an SSH_FXP_STATUS failure reported by the server
+type sftpStatusError struct {
+	Code uint32
+	Msg  string
+}
+
+func (e *sftpStatusError) Error() string {
+	return fmt.Sprintf("sftp: %s (code %d)", e.Msg, e.Code)
+}
+
+// Is maps status codes to os errors so os.IsNotExist-style checks work via errors.Is
+func (e *sftpStatusError) Is(target error) bool {
+	switch e.Code {
+	case sshFxNoSuchFile:
+		return target == os.ErrNotExist
+	case sshFxPermissionDenied:
+		return target == os.ErrPermission
+	}
+	return false
+}
+
+type sftpClient struct {
+	mu     sync.Mutex
+	r      io.Reader
+	w      io.Writer
+	closer func() error
+	nextID uint32
+}
+
+// newSFTPClient performs the version handshake over an established sftp subsystem channel
+func newSFTPClient(r io.Reader, w io.Writer, closer func() error) (*sftpClient, error) {
+	c := &sftpClient{r: r, w: w, closer: closer}
+	if err := c.writePacket(sshFxpInit, appendUint32(nil, 3)); err != nil {
+		return nil, err
+	}
+	typ, _, err := c.readPacket()
+	if err != nil {
+		return nil, err
+	}
+	if typ != sshFxpVersion {
+		return nil, fmt.Errorf("sftp: unexpected packet %d during handshake", typ)
+	}
+	return c, nil
+}
+
+// Close ends the session
+func (c *sftpClient) Close() error {
+	if c.closer == nil {
+		return nil
+	}
+	return c.closer()
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return binary.BigEndian.AppendUint64(b, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+func (c *sftpClient) writePacket(typ byte, payload []byte) error {
+	packet := make([]byte, 0, 5+len(payload))
+	packet = appendUint32(packet, uint32(len(payload)+1))
+	packet = append(packet, typ)
+	packet = append(packet, payload...)
+	_, err := c.w.Write(packet)
+	return err
+}
+
+func (c *sftpClient) readPacket() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > sftpMaxPacketSize {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return body[0], body[1:], nil
+}
+
+// request sends a request and returns the matching response; caller must hold c.mu
+func (c *sftpClient) request(typ byte, payload []byte) (byte, *sftpReader, error) {
+	c.nextID++
+	id := c.nextID
+	if err := c.writePacket(typ, append(appendUint32(nil, id), payload...)); err != nil {
+		return 0, nil, err
+	}
+	respType, body, err := c.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	r := &sftpReader{b: body}
+	if got := r.uint32(); r.err != nil || got != id {
+		return 0, nil, fmt.Errorf("sftp: response id mismatch")
+	}
+	return respType, r, nil
+}
+
+// sftpReader decodes SFTP wire types, remembering the first error
+type sftpReader struct {
+	b   []byte
+	err error
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if r.err != nil || len(r.b) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.b)
+	r.b = r.b[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if r.err != nil || len(r.b) < 8 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.b)
+	r.b = r.b[8:]
+	return v
+}
+
+func (r *sftpReader) string() string {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.b)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.b[:n])
+	r.b = r.b[n:]
+	return s
+}
+
+func (r *sftpReader) attrs() sftpAttrs {
+	var a sftpAttrs
+	flags := r.uint32()
+	if flags&sftpAttrSize != 0 {
+		a.Size = int64(r.uint64())
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		r.uint32()
+		r.uint32()
+	}
+	if flags&sftpAttrPermissions != 0 {
+		a.Mode = r.uint32()
+	}
+	if flags&sftpAttrACModTime != 0 {
+		r.uint32()
+		a.ModTime = int64(r.uint32())
+	}
+	if flags&sftpAttrExtended != 0 {
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			r.string()
+			r.string()
+		}
+	}
+	return a
+}
+
+// status decodes an SSH_FXP_STATUS body; OK yields nil
+func (r *sftpReader) status() error {
+	code := r.uint32()
+	msg := r.string()
+	if r.err != nil {
+		return r.err
+	}
+	if code == sshFxOK {
+		return nil
+	}
+	return &sftpStatusError{Code: code, Msg: msg}
+}
+
+// unexpected turns a non-matching response into an error
+func unexpected(typ byte, r *sftpReader) error {
+	if typ == sshFxpStatus {
+		if err := r.status(); err != nil {
+			return err
+		}
+	}
+	return fmt.Errorf("sftp: unexpected response type %d", typ)
+}
+
+// handle opens a file or directory handle; caller must hold c.mu
+func (c *sftpClient) handle(typ byte, payload []byte) (string, error) {
+	respType, r, err := c.request(typ, payload)
+	if err != nil {
+		return "", err
+	}
+	if respType != sshFxpHandle {
+		return "", unexpected(respType, r)
+	}
+	h := r.string()
+	return h, r.err
+}
+
+// closeHandle releases a handle; caller must hold c.mu
+func (c *sftpClient) closeHandle(h string) error {
+	respType, r, err := c.request(sshFxpClose, appendString(nil, h))
+	if err != nil {
+		return err
+	}
+	if respType != sshFxpStatus {
+		return unexpected(respType, r)
+	}
+	return r.status()
+}
+
+// Stat returns the attributes of path, following symlinks
+func (c *sftpClient) Stat(path string) (sftpAttrs, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	respType, r, err := c.request(sshFxpStat, appendString(nil, path))
+	if err != nil {
+		return sftpAttrs{}, err
+	}
+	if respType != sshFxpAttrs {
+		return sftpAttrs{}, unexpected(respType, r)
+	}
+	a := r.attrs()
+	return a, r.err
+}
+
+// ReadDir lists a directory, excluding . and ..
+func (c *sftpClient) ReadDir(path string) ([]sftpEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	h, err := c.handle(sshFxpOpendir, appendString(nil, path))
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(h)
+
+	var entries []sftpEntry
+	for {
+		respType, r, err := c.request(sshFxpReaddir, appendString(nil, h))
+		if err != nil {
+			return nil, err
+		}
+		if respType == sshFxpStatus {
+			var statusErr *sftpStatusError
+			if err := r.status(); errors.As(err, &statusErr) && statusErr.Code == sshFxEOF {
+				return entries, nil
+			} else if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("sftp: unexpected OK status while reading directory")
+		}
+		if respType != sshFxpName {
+			return nil, unexpected(respType, r)
+		}
+		for n := r.uint32(); n > 0 && r.err == nil; n-- {
+			name := r.string()
+			r.string() // longname
+			attrs := r.attrs()
+			if name != "." && name != ".." {
+				entries = append(entries, sftpEntry{Name: name, Attrs: attrs})
+			}
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+	}
+}
+
+// ReadFile reads up to limit bytes of a file
+func (c *sftpClient) ReadFile(path string, limit int64) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	payload := appendString(nil, path)
+	payload = appendUint32(payload, sshFxfRead)
+	payload = appendUint32(payload, 0) // no attributes
+	h, err := c.handle(sshFxpOpen, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeHandle(h)
+
+	var data []byte
+	for int64(len(data)) < limit {
+		chunk := int64(sftpReadChunk)
+		if remaining := limit - int64(len(data)); remaining < chunk {
+			chunk = remaining
+		}
+		req := appendString(nil, h)
+		req = appendUint64(req, uint64(len(data)))
+		req = appendUint32(req, uint32(chunk))
+		respType, r, err := c.request(sshFxpRead, req)
+		if err != nil {
+			return nil, err
+		}
+		if respType == sshFxpStatus {
+			var statusErr *sftpStatusError
+			if err := r.status(); errors.As(err, &statusErr) && statusErr.Code == sshFxEOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("sftp: unexpected OK status while reading file")
+		}
+		if respType != sshFxpData {
+			return nil, unexpected(respType, r)
+		}
+		chunkData := r.string()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if chunkData == "" {
+			break
+		}
+		data = append(data, chunkData...)
+	}
+	return data, nil
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// shareDefaultHours and shareMaxHours bound how long a share link works
+	shareDefaultHours = 24
+	shareMaxHours     = 30 * 24
+
+	// shareOutputLimit truncates each tool output included in a share
+	shareOutputLimit = 4000
+)
+
+// ShareLink is a read-only public link to a session transcript
+type ShareLink struct {
+	ID                 string    `json:"id"`
+	SessionID          string    `json:"sessionId"`
+	Owner              string    `json:"owner,omitempty"`
+	CreatedAt          time.Time `json:"createdAt"`
+	ExpiresAt          time.Time `json:"expiresAt"`
+	IncludeToolOutputs bool      `json:"includeToolOutputs"`
+	Redact             []string  `json:"redact,omitempty"` // extra patterns masked besides the built-in secret rules
+}
+
+// ShareRequest is the body of POST /api/session/:id/share
+type ShareRequest struct {
+	ExpiresInHours     int      `json:"expiresInHours"`     // default 24, at most 720
+	IncludeToolOutputs bool     `json:"includeToolOutputs"` // tool results are left out unless set
+	Redact             []string `json:"redact,omitempty"`   // regular expressions to mask
+}
+
+// ShareResponse is the response of POST /api/session/:id/share
+type ShareResponse struct {
+	ShareLink
+	Token string `json:"token"`
+	Path  string `json:"path"` // /share/<token> under the server's base path
+	URL   string `json:"url"`  // Path on the host this request came to
+}
+
+// SharedToolCall is a tool call in a shared transcript
+type SharedToolCall struct {
+	Name   string `json:"name"`
+	Detail string `json:"detail,omitempty"` // the file, command, or pattern it was given
+	Output string `json:"output,omitempty"` // with includeToolOutputs
+}
+
+// SharedMessage is a message of a shared transcript
+type SharedMessage struct {
+	Role      string           `json:"role"` // user or assistant
+	Timestamp string           `json:"timestamp,omitempty"`
+	Text      string           `json:"text,omitempty"`
+	Tools     []SharedToolCall `json:"tools,omitempty"`
+}
+
+// SharedTranscript is the response of GET /api/shared/:token
+type SharedTranscript struct {
+	Title     string          `json:"title"`
+	ExpiresAt time.Time       `json:"expiresAt"`
+	Messages  []SharedMessage `json:"messages"`
+}
+
+// shareStoreFile is shares.json: the signing key and the links
+type shareStoreFile struct {
+	Key    string                `json:"key"`
+	Shares map[string]*ShareLink `json:"shares"`
+}
+
+var (
+	sharesMu     sync.Mutex
+	shareStore   shareStoreFile
+	sharesLoaded bool
+)
+
+func sharesPath() string {
+	return serverDataPath("shares.json")
+}
+
+// loadShares reads the store once, generating the signing key on first use; caller must hold sharesMu
+func loadShares() error {
+	if sharesLoaded {
+		return nil
+	}
+	if err := loadJSONFile(sharesPath(), &shareStore); err != nil {
+		return err
+	}
+	if shareStore.Shares == nil {
+		shareStore.Shares = make(map[string]*ShareLink)
+	}
+	if shareStore.Key == "" {
+		shareStore.Key = randomToken(32)
+		if err := saveShares(); err != nil {
+			return err
+		}
+	}
+	sharesLoaded = true
+	return nil
+}
+
+// saveShares drops expired links and writes the store; caller must hold sharesMu
+func saveShares() error {
+	now := time.Now()
+	for id, share := range shareStore.Shares {
+		if now.After(share.ExpiresAt) {
+			delete(shareStore.Shares, id)
+		}
+	}
+	return writeJSONFileAtomicMode(sharesPath(), shareStore, 0600)
+}
+
+// shareToken is the link ID followed by an HMAC over the ID and expiry
+func shareToken(key string, share *ShareLink) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(share.ID + "." + strconv.FormatInt(share.ExpiresAt.Unix(), 10)))
+	return share.ID + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// lookupShare returns the unexpired link a token was issued for
+func lookupShare(token string) *ShareLink {
+	id, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil
+	}
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+	if err := loadShares(); err != nil {
+		log.Printf("[Shares] Failed to load share links: %v", err)
+		return nil
+	}
+	share := shareStore.Shares[id]
+	if share == nil || time.Now().After(share.ExpiresAt) {
+		return nil
+	}
+	if !hmac.Equal([]byte(token), []byte(shareToken(shareStore.Key, share))) {
+		return nil
+	}
+	copied := *share
+	return &copied
+}
+
+// forgetShares revokes the links to a deleted session
+func forgetShares(sessionID string) {
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+	if err := loadShares(); err != nil {
+		return
+	}
+	changed := false
+	for id, share := range shareStore.Shares {
+		if share.SessionID == sessionID {
+			delete(shareStore.Shares, id)
+			changed = true
+		}
+	}
+	if changed {
+		if err := saveShares(); err != nil {
+			log.Printf("[Shares] Failed to save share links: %v", err)
+		}
+	}
+}
+
+// requestOrigin is the scheme and host the client used to reach the server
+func requestOrigin(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+// CreateShare handles POST /api/session/:id/share
+// Creates an expiring link that shows the transcript, with secrets masked, to anyone who has it
+func CreateShare(c *gin.Context) {
+	sessionID := c.Param("id")
+	user := currentUser(c)
+	if !userCanAccessSession(user, sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req ShareRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	if req.ExpiresInHours == 0 {
+		req.ExpiresInHours = shareDefaultHours
+	}
+	if req.ExpiresInHours < 0 || req.ExpiresInHours > shareMaxHours {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("expiresInHours must be between 1 and %d", shareMaxHours))
+		return
+	}
+	if _, err := newRedactor(req.Redact); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	share := &ShareLink{
+		ID:                 randomToken(16),
+		SessionID:          sessionID,
+		Owner:              ownerID(user),
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(time.Duration(req.ExpiresInHours) * time.Hour),
+		IncludeToolOutputs: req.IncludeToolOutputs,
+		Redact:             req.Redact,
+	}
+
+	sharesMu.Lock()
+	err := loadShares()
+	var token string
+	if err == nil {
+		shareStore.Shares[share.ID] = share
+		token = shareToken(shareStore.Key, share)
+		err = saveShares()
+	}
+	sharesMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save share link", err.Error())
+		return
+	}
+
+	path := getServerConfig().BasePath + "/share/" + token
+	c.JSON(http.StatusOK, ShareResponse{
+		ShareLink: *share,
+		Token:     token,
+		Path:      path,
+		URL:       requestOrigin(c) + path,
+	})
+}
+
+// ListShares handles GET /api/shares
+// Query parameters:
+//   - sessionId: only the links to this session
+func ListShares(c *gin.Context) {
+	user := currentUser(c)
+	sessionID := c.Query("sessionId")
+
+	sharesMu.Lock()
+	err := loadShares()
+	shares := []ShareLink{}
+	now := time.Now()
+	for _, share := range shareStore.Shares {
+		if now.After(share.ExpiresAt) || !userCanAccessOwner(user, share.Owner) || (sessionID != "" && share.SessionID != sessionID) {
+			continue
+		}
+		shares = append(shares, *share)
+	}
+	sharesMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load share links", err.Error())
+		return
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+// RevokeShare handles DELETE /api/shares/:id
+func RevokeShare(c *gin.Context) {
+	id := c.Param("id")
+	sharesMu.Lock()
+	defer sharesMu.Unlock()
+	if err := loadShares(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load share links", err.Error())
+		return
+	}
+	share := shareStore.Shares[id]
+	if share == nil || !userCanAccessOwner(currentUser(c), share.Owner) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Share link not found")
+		return
+	}
+	delete(shareStore.Shares, id)
+	if err := saveShares(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save share links", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// sharedTranscript reads a session as the sanitized view a share link shows
+func sharedTranscript(share *ShareLink) (*SharedTranscript, error) {
+	path := findSessionFile(share.SessionID)
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	r, err := newRedactor(share.Redact)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	transcript := &SharedTranscript{ExpiresAt: share.ExpiresAt, Messages: []SharedMessage{}}
+	toolCalls := make(map[string][2]int) // tool_use ID -> message and tool index
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		var msg Message
+		if len(line) > 0 && json.Unmarshal(line, &msg) == nil && isHistoryMessage(msg.Type) && !msg.IsSidechain {
+			shared := SharedMessage{Role: "user", Timestamp: msg.Timestamp}
+			if msg.Type == "assistant" {
+				shared.Role = "assistant"
+			}
+			var texts []string
+			switch content := msg.Message["content"].(type) {
+			case string:
+				texts = append(texts, content)
+			case []interface{}:
+				for _, item := range content {
+					block, _ := item.(map[string]interface{})
+					switch block["type"] {
+					case "text":
+						if text, ok := block["text"].(string); ok {
+							texts = append(texts, text)
+						}
+					case "tool_use":
+						name, _ := block["name"].(string)
+						input, _ := block["input"].(map[string]interface{})
+						if id, ok := block["id"].(string); ok {
+							toolCalls[id] = [2]int{len(transcript.Messages), len(shared.Tools)}
+						}
+						shared.Tools = append(shared.Tools, SharedToolCall{Name: name, Detail: r.redact(toolActivityDetail(input))})
+					case "tool_result":
+						// Outputs go with their call; they are the likeliest place for secrets
+						id, _ := block["tool_use_id"].(string)
+						at, ok := toolCalls[id]
+						if !share.IncludeToolOutputs || !ok {
+							continue
+						}
+						output := clipText(strings.TrimSpace(messageSearchText(block["content"])), shareOutputLimit)
+						transcript.Messages[at[0]].Tools[at[1]].Output = r.redact(output)
+					}
+				}
+			}
+			shared.Text = r.redact(strings.TrimSpace(strings.Join(texts, "\n\n")))
+			if shared.Text != "" || len(shared.Tools) > 0 {
+				if transcript.Title == "" && shared.Role == "user" {
+					transcript.Title = clipText(strings.Join(strings.Fields(shared.Text), " "), 100)
+				}
+				transcript.Messages = append(transcript.Messages, shared)
+			}
+		}
+		if readErr != nil {
+			break
+		}
+	}
+	if summary := getSessionSummary(share.SessionID); summary != nil {
+		transcript.Title = r.redact(summary.Summary)
+	}
+	if transcript.Title == "" {
+		transcript.Title = "Shared session"
+	}
+	return transcript, nil
+}
+
+// GetSharedTranscript handles GET /api/shared/:token
+// Public: the token is the credential
+func GetSharedTranscript(c *gin.Context) {
+	share := lookupShare(c.Param("token"))
+	if share == nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, "This link has expired or was revoked")
+		return
+	}
+	transcript, err := sharedTranscript(share)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, "The shared session is no longer available")
+		return
+	}
+	setShareHeaders(c)
+	c.JSON(http.StatusOK, transcript)
+}
+
+// setShareHeaders keeps share pages out of caches, search indexes, and Referer headers
+func setShareHeaders(c *gin.Context) {
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+}
+
+// sharePage renders a shared transcript without the client bundle
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex, nofollow">
+<title>{{with .Transcript}}{{.Title}}{{else}}Shared session{{end}}</title>
+<style>
+body { font: 15px/1.5 system-ui, sans-serif; max-width: 860px; margin: 2rem auto; padding: 0 1rem; color: #1f2328; background: #fff; }
+h1 { font-size: 1.3rem; }
+.meta { color: #656d76; font-size: 0.85rem; }
+.msg { border: 1px solid #d0d7de; border-radius: 8px; padding: 0.75rem 1rem; margin: 1rem 0; }
+.user { background: #f6f8fa; }
+.role { font-weight: 600; font-size: 0.85rem; color: #656d76; }
+pre { white-space: pre-wrap; word-wrap: break-word; margin: 0.5rem 0; font: inherit; }
+.tool { font: 13px ui-monospace, monospace; color: #57606a; margin: 0.25rem 0; }
+.tool pre { font: 12px ui-monospace, monospace; background: #f6f8fa; padding: 0.5rem; border-radius: 6px; max-height: 20rem; overflow: auto; }
+</style>
+</head>
+<body>
+{{if .Error}}<h1>{{.Error}}</h1>{{else}}{{with .Transcript}}
+<h1>{{.Title}}</h1>
+<p class="meta">Read-only copy. Secrets are masked. This link expires {{.ExpiresAt.Format "2006-01-02 15:04 MST"}}.</p>
+{{range .Messages}}<div class="msg {{.Role}}">
+<div class="role">{{.Role}}{{if .Timestamp}} · {{.Timestamp}}{{end}}</div>
+{{if .Text}}<pre>{{.Text}}</pre>{{end}}
+{{range .Tools}}<div class="tool">⚙ {{.Name}}{{if .Detail}}: {{.Detail}}{{end}}{{if .Output}}<pre>{{.Output}}</pre>{{end}}</div>{{end}}
+</div>
+{{end}}{{end}}{{end}}
+</body>
+</html>
+`))
+
+// SharedTranscriptPage handles GET /share/:token
+// Renders the shared transcript as a standalone page for viewers without an account
+func SharedTranscriptPage(c *gin.Context) {
+	setShareHeaders(c)
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	data := struct {
+		Error      string
+		Transcript *SharedTranscript
+	}{}
+	status := http.StatusOK
+	if share := lookupShare(c.Param("token")); share == nil {
+		status, data.Error = http.StatusNotFound, "This link has expired or was revoked"
+	} else if transcript, err := sharedTranscript(share); err != nil {
+		status, data.Error = http.StatusNotFound, "The shared session is no longer available"
+	} else {
+		data.Transcript = transcript
+	}
+	var page bytes.Buffer
+	if err := sharePage.Execute(&page, data); err != nil {
+		log.Printf("[Shares] Failed to render share page: %v", err)
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to render page")
+		return
+	}
+	c.Data(status, "text/html; charset=utf-8", page.Bytes())
+}
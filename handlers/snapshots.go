@@ -0,0 +1,497 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// snapshotsPerSession is how many snapshots a session keeps; older ones are dropped
+	snapshotsPerSession = 10
+	// snapshotCopyMaxFiles and snapshotCopyMaxBytes bound snapshots of working directories outside git
+	snapshotCopyMaxFiles = 20000
+	snapshotCopyMaxBytes = 256 << 20
+	// snapshotRefPrefix keeps snapshot commits from being garbage collected
+	snapshotRefPrefix = "refs/greyzone/snapshots/"
+)
+
+// SessionSnapshot is the state of a working directory before a chat run
+type SessionSnapshot struct {
+	ID        string `json:"id"`
+	WorkDir   string `json:"workDir"`
+	Kind      string `json:"kind"`             // git or copy
+	Commit    string `json:"commit,omitempty"` // git: a commit of the working tree, untracked files included
+	Files     int    `json:"files,omitempty"`  // copy: files copied
+	CreatedAt string `json:"createdAt"`
+	// RolledBackAt is when the working directory was last restored to this snapshot
+	RolledBackAt string `json:"rolledBackAt,omitempty"`
+}
+
+// RollbackRequest is the body of POST /api/session/:id/rollback
+type RollbackRequest struct {
+	SnapshotID   string `json:"snapshotId"`   // default: the latest
+	KeepNewFiles bool   `json:"keepNewFiles"` // keep files created since the snapshot
+}
+
+// RollbackResponse is the response of POST /api/session/:id/rollback
+type RollbackResponse struct {
+	Snapshot SessionSnapshot `json:"snapshot"`
+	Restored []string        `json:"restored"` // changed or deleted since, relative to the working directory
+	Removed  []string        `json:"removed"`  // created since
+}
+
+// snapshotFile is a file of a copy snapshot
+type snapshotFile struct {
+	Path  string      `json:"path"` // relative to the working directory
+	Size  int64       `json:"size"`
+	Mtime int64       `json:"mtime"` // Unix nanoseconds
+	Mode  fs.FileMode `json:"mode"`
+}
+
+// Snapshots are kept per session until dropped for newer ones or the session is deleted
+var (
+	snapshotsMu     sync.Mutex
+	snapshotStore   map[string][]SessionSnapshot // session ID -> snapshots, oldest first
+	snapshotsLoaded bool
+)
+
+func snapshotsPath() string {
+	return serverDataPath("snapshots.json")
+}
+
+// snapshotDir holds a copy snapshot's manifest and files
+func snapshotDir(id string) string {
+	return filepath.Join(serverDataPath("snapshots"), id)
+}
+
+// loadSnapshots reads the snapshot store once; caller must hold snapshotsMu
+func loadSnapshots() error {
+	if snapshotsLoaded {
+		return nil
+	}
+	if err := loadJSONFile(snapshotsPath(), &snapshotStore); err != nil {
+		return err
+	}
+	if snapshotStore == nil {
+		snapshotStore = make(map[string][]SessionSnapshot)
+	}
+	snapshotsLoaded = true
+	return nil
+}
+
+// snapshotGit runs git in dir with extra environment and returns its output, or its stderr as the error
+func snapshotGit(dir string, env []string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), env...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// gitWorkTree writes a tree of dir as it is on disk, untracked files included, without touching the index
+func gitWorkTree(dir string) (string, error) {
+	out, err := snapshotGit(dir, nil, "rev-parse", "--git-path", "index")
+	if err != nil {
+		return "", err
+	}
+	index := strings.TrimSpace(string(out))
+	if !filepath.IsAbs(index) {
+		index = filepath.Join(dir, index)
+	}
+	tmp, err := os.CreateTemp("", "greyzone-index-*")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+	// Starting from the real index saves hashing unchanged files
+	if data, err := os.ReadFile(index); err == nil {
+		os.WriteFile(tmp.Name(), data, 0600)
+	}
+	env := []string{"GIT_INDEX_FILE=" + tmp.Name()}
+	if _, err := snapshotGit(dir, env, "add", "-A", "--", "."); err != nil {
+		return "", err
+	}
+	out, err = snapshotGit(dir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// takeSnapshot records workDir before a run: as a git commit in a repository, otherwise as a copy of its files
+func takeSnapshot(workDir string) (*SessionSnapshot, error) {
+	snap := &SessionSnapshot{ID: generateID(), WorkDir: workDir, CreatedAt: time.Now().UTC().Format(time.RFC3339)}
+	if _, err := snapshotGit(workDir, nil, "rev-parse", "--is-inside-work-tree"); err == nil {
+		tree, err := gitWorkTree(workDir)
+		if err != nil {
+			return nil, err
+		}
+		args := []string{"commit-tree", tree, "-m", "greyzone snapshot " + snap.ID}
+		if head, err := snapshotGit(workDir, nil, "rev-parse", "--verify", "-q", "HEAD"); err == nil {
+			args = append(args, "-p", strings.TrimSpace(string(head)))
+		}
+		out, err := snapshotGit(workDir, []string{
+			"GIT_AUTHOR_NAME=greyzone", "GIT_AUTHOR_EMAIL=greyzone@localhost",
+			"GIT_COMMITTER_NAME=greyzone", "GIT_COMMITTER_EMAIL=greyzone@localhost",
+		}, args...)
+		if err != nil {
+			return nil, err
+		}
+		snap.Kind, snap.Commit = "git", strings.TrimSpace(string(out))
+		if _, err := snapshotGit(workDir, nil, "update-ref", snapshotRefPrefix+snap.ID, snap.Commit); err != nil {
+			return nil, err
+		}
+		return snap, nil
+	}
+
+	snap.Kind = "copy"
+	files, err := scanSnapshotFiles(workDir)
+	if err != nil {
+		return nil, err
+	}
+	dir := snapshotDir(snap.ID)
+	for _, f := range files {
+		if err := copySnapshotFile(filepath.Join(workDir, f.Path), filepath.Join(dir, "files", f.Path), f.Mode); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	if err := writeJSONFileAtomicMode(filepath.Join(dir, "manifest.json"), files, 0600); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	snap.Files = len(files)
+	return snap, nil
+}
+
+// scanSnapshotFiles lists the regular files under workDir, failing past the copy limits
+func scanSnapshotFiles(workDir string) ([]snapshotFile, error) {
+	var files []snapshotFile
+	var total int64
+	err := filepath.WalkDir(workDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(workDir, path)
+		files = append(files, snapshotFile{Path: rel, Size: info.Size(), Mtime: info.ModTime().UnixNano(), Mode: info.Mode().Perm()})
+		total += info.Size()
+		if len(files) > snapshotCopyMaxFiles || total > snapshotCopyMaxBytes {
+			return withCode(http.StatusRequestEntityTooLarge, ErrPayloadTooLarge,
+				fmt.Errorf("the working directory is too large to snapshot outside git (over %d files or %d MB)", snapshotCopyMaxFiles, snapshotCopyMaxBytes>>20))
+		}
+		return nil
+	})
+	return files, err
+}
+
+// copySnapshotFile copies a file, creating its directory, and keeps its mode
+func copySnapshotFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode|0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Chmod(dst, mode)
+}
+
+// dropSnapshot deletes what a snapshot keeps: its ref or its copied files
+func dropSnapshot(snap SessionSnapshot) {
+	switch snap.Kind {
+	case "git":
+		if _, err := snapshotGit(snap.WorkDir, nil, "update-ref", "-d", snapshotRefPrefix+snap.ID); err != nil {
+			log.Printf("[Snapshots] Failed to delete snapshot %s: %v", snap.ID, err)
+		}
+	case "copy":
+		os.RemoveAll(snapshotDir(snap.ID))
+	}
+}
+
+// recordSnapshot keeps a run's snapshot with its session, dropping the session's oldest past snapshotsPerSession
+func recordSnapshot(sessionID string, snap SessionSnapshot) {
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	if err := loadSnapshots(); err != nil {
+		log.Printf("[Snapshots] Failed to load snapshots: %v", err)
+		dropSnapshot(snap)
+		return
+	}
+	list := append(snapshotStore[sessionID], snap)
+	for len(list) > snapshotsPerSession {
+		dropSnapshot(list[0])
+		list = list[1:]
+	}
+	snapshotStore[sessionID] = list
+	if err := writeJSONFileAtomicMode(snapshotsPath(), snapshotStore, 0600); err != nil {
+		log.Printf("[Snapshots] Failed to save snapshots: %v", err)
+	}
+}
+
+// forgetSnapshots drops a deleted session's snapshots
+func forgetSnapshots(sessionID string) {
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	if err := loadSnapshots(); err != nil || snapshotStore[sessionID] == nil {
+		return
+	}
+	for _, snap := range snapshotStore[sessionID] {
+		dropSnapshot(snap)
+	}
+	delete(snapshotStore, sessionID)
+	if err := writeJSONFileAtomicMode(snapshotsPath(), snapshotStore, 0600); err != nil {
+		log.Printf("[Snapshots] Failed to save snapshots: %v", err)
+	}
+}
+
+// runSnapshot ties a snapshot taken before a run to the run's session once it is known
+type runSnapshot struct {
+	snap     *SessionSnapshot
+	attached bool
+}
+
+// newRunSnapshot snapshots workDir for a run when requested; nil otherwise
+func newRunSnapshot(requested bool, workDir, backend string) (*runSnapshot, error) {
+	if !requested {
+		return nil, nil
+	}
+	if !runsLocally(workDir, backend) {
+		return nil, localOnlyError("snapshots", workDir, backend)
+	}
+	snap, err := takeSnapshot(workDir)
+	if err != nil {
+		return nil, err
+	}
+	return &runSnapshot{snap: snap}, nil
+}
+
+// attach records the snapshot with sessionID; later calls do nothing
+func (r *runSnapshot) attach(sessionID string) {
+	if r == nil || r.attached || sessionID == "" {
+		return
+	}
+	r.attached = true
+	recordSnapshot(sessionID, *r.snap)
+}
+
+// finish drops the snapshot of a run that never got a session
+func (r *runSnapshot) finish() {
+	if r != nil && !r.attached {
+		dropSnapshot(*r.snap)
+	}
+}
+
+// removeCreatedFile removes a file created since a snapshot, and the directories it leaves empty
+func removeCreatedFile(workDir, rel string) error {
+	if err := os.Remove(filepath.Join(workDir, rel)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for dir := filepath.Dir(rel); dir != "."; dir = filepath.Dir(dir) {
+		if os.Remove(filepath.Join(workDir, dir)) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// rollbackGit restores workDir to a git snapshot
+func rollbackGit(snap SessionSnapshot, keepNew bool) (restored, removed []string, err error) {
+	tree, err := gitWorkTree(snap.WorkDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	out, err := snapshotGit(snap.WorkDir, nil, "diff", "--relative", "--no-renames", "--name-status", "-z", snap.Commit, tree, "--", ".")
+	if err != nil {
+		return nil, nil, err
+	}
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, path := fields[i], fields[i+1]
+		if status == "A" {
+			if !keepNew {
+				if err := removeCreatedFile(snap.WorkDir, path); err != nil {
+					return restored, removed, err
+				}
+				removed = append(removed, path)
+			}
+			continue
+		}
+		restored = append(restored, path)
+	}
+	if len(restored) > 0 {
+		args := append([]string{"restore", "--source=" + snap.Commit, "--worktree", "--"}, restored...)
+		if _, err := snapshotGit(snap.WorkDir, nil, args...); err != nil {
+			return nil, removed, err
+		}
+	}
+	return restored, removed, nil
+}
+
+// rollbackCopy restores workDir to a copy snapshot
+func rollbackCopy(snap SessionSnapshot, keepNew bool) (restored, removed []string, err error) {
+	var files []snapshotFile
+	if err := loadJSONFile(filepath.Join(snapshotDir(snap.ID), "manifest.json"), &files); err != nil {
+		return nil, nil, err
+	}
+	saved := make(map[string]bool, len(files))
+	for _, f := range files {
+		saved[f.Path] = true
+		path := filepath.Join(snap.WorkDir, f.Path)
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() && info.Size() == f.Size && info.ModTime().UnixNano() == f.Mtime {
+			continue
+		}
+		if err := copySnapshotFile(filepath.Join(snapshotDir(snap.ID), "files", f.Path), path, f.Mode); err != nil {
+			return restored, removed, err
+		}
+		os.Chtimes(path, time.Now(), time.Unix(0, f.Mtime))
+		restored = append(restored, f.Path)
+	}
+	if keepNew {
+		return restored, removed, nil
+	}
+	current, err := scanSnapshotFiles(snap.WorkDir)
+	if err != nil {
+		return restored, removed, err
+	}
+	for _, f := range current {
+		if !saved[f.Path] {
+			if err := removeCreatedFile(snap.WorkDir, f.Path); err != nil {
+				return restored, removed, err
+			}
+			removed = append(removed, f.Path)
+		}
+	}
+	return restored, removed, nil
+}
+
+// ListSnapshots handles GET /api/session/:id/snapshots
+// Returns the snapshots taken before the session's runs, newest first
+func ListSnapshots(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	snapshotsMu.Lock()
+	err := loadSnapshots()
+	list := []SessionSnapshot{}
+	for i := len(snapshotStore[sessionID]) - 1; i >= 0; i-- {
+		list = append(list, snapshotStore[sessionID][i])
+	}
+	snapshotsMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load snapshots", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sessionId": sessionID, "snapshots": list})
+}
+
+// RollbackSession handles POST /api/session/:id/rollback
+// Restores the working directory to a snapshot taken before one of the session's runs: files changed or deleted
+// since are put back and files created since are removed. Files git ignores are left alone in repositories,
+// and commits made since stay; only the working tree is restored
+func RollbackSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) || findSessionFile(sessionID) == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req RollbackRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	if IsSessionLoading(sessionID) {
+		respondError(c, http.StatusConflict, ErrSessionBusy, "Stop the session's run before rolling back")
+		return
+	}
+
+	snapshotsMu.Lock()
+	defer snapshotsMu.Unlock()
+	if err := loadSnapshots(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load snapshots", err.Error())
+		return
+	}
+	list := snapshotStore[sessionID]
+	index := len(list) - 1
+	if req.SnapshotID != "" {
+		for index >= 0 && list[index].ID != req.SnapshotID {
+			index--
+		}
+	}
+	if index < 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Snapshot not found")
+		return
+	}
+	snap := list[index]
+	if !userCanAccessPath(currentUser(c), snap.WorkDir) {
+		respondError(c, http.StatusForbidden, ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", snap.WorkDir))
+		return
+	}
+
+	rollback := rollbackGit
+	if snap.Kind == "copy" {
+		rollback = rollbackCopy
+	}
+	restored, removed, err := rollback(snap, req.KeepNewFiles)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to roll back", err.Error())
+		return
+	}
+	sort.Strings(restored)
+	sort.Strings(removed)
+	if restored == nil {
+		restored = []string{}
+	}
+	if removed == nil {
+		removed = []string{}
+	}
+	list[index].RolledBackAt = time.Now().UTC().Format(time.RFC3339)
+	if err := writeJSONFileAtomicMode(snapshotsPath(), snapshotStore, 0600); err != nil {
+		log.Printf("[Snapshots] Failed to save snapshots: %v", err)
+	}
+	log.Printf("[Snapshots] Rolled back %s to snapshot %s: %d restored, %d removed", snap.WorkDir, snap.ID, len(restored), len(removed))
+	c.JSON(http.StatusOK, RollbackResponse{Snapshot: list[index], Restored: restored, Removed: removed})
+}
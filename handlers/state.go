@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"bytes"
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +22,9 @@ type SessionState struct {
 	SessionID string `json:"sessionId"`
 	IsLoading bool   `json:"isLoading"`
 	ProcessID *int   `json:"processId,omitempty"`
+	// WaitingForInput is set while claude is blocked on the user rather than working; it can outlast the run
+	WaitingForInput bool          `json:"waitingForInput"`
+	InputRequest    *InputRequest `json:"inputRequest,omitempty"`
 }
 
 // AppState represents the server state (session processing status only)
@@ -28,11 +33,23 @@ type AppState struct {
 	Version  int64                    `json:"version"`
 }
 
+// stateReplayEvents bounds the named events kept for clients reconnecting with Last-Event-ID
+const stateReplayEvents = 256
+
+// stateEvent is a single SSE payload; Name is empty for plain state snapshots
+type stateEvent struct {
+	ID        int64
+	Name      string
+	Data      []byte
+	SessionID string // set for events only clients that can access the session receive
+}
+
 // SSE client for state updates
 type StateClient struct {
 	ID      string
-	Channel chan []byte
+	Channel chan stateEvent
 	Done    chan struct{}
+	User    *User // only sessions visible to this user are sent (nil = all)
 }
 
 // StateManager handles session state with proper concurrency
@@ -41,6 +58,11 @@ type StateManager struct {
 	mu       sync.RWMutex
 	clients  map[string]*StateClient
 	clientMu sync.RWMutex
+
+	// Event IDs and recent named events, for SSE resume; eventMu also orders delivery
+	eventMu  sync.Mutex
+	eventSeq int64
+	recent   []stateEvent
 }
 
 var stateManager *StateManager
@@ -63,6 +85,23 @@ func generateID() string {
 	return hex.EncodeToString(b)
 }
 
+// filterState returns the part of a state visible to user
+func filterState(state AppState, user *User) AppState {
+	if user == nil || user.IsAdmin() {
+		return state
+	}
+	filtered := AppState{
+		Sessions: make(map[string]*SessionState),
+		Version:  state.Version,
+	}
+	for sessionId, session := range state.Sessions {
+		if userCanAccessSession(user, sessionId) {
+			filtered.Sessions[sessionId] = session
+		}
+	}
+	return filtered
+}
+
 // Broadcast state to all connected clients
 func (sm *StateManager) broadcast() {
 	sm.mu.Lock()
@@ -70,24 +109,114 @@ func (sm *StateManager) broadcast() {
 	data, _ := json.Marshal(sm.state)
 	sm.mu.Unlock()
 
+	sm.eventMu.Lock()
+	defer sm.eventMu.Unlock()
+	sm.eventSeq++
+	id := sm.eventSeq
+
 	sm.clientMu.RLock()
 	defer sm.clientMu.RUnlock()
 
 	for _, client := range sm.clients {
+		clientData := data
+		if client.User != nil && !client.User.IsAdmin() {
+			sm.mu.RLock()
+			clientData, _ = json.Marshal(filterState(sm.state, client.User))
+			sm.mu.RUnlock()
+		}
 		select {
-		case client.Channel <- data:
+		case client.Channel <- stateEvent{ID: id, Data: clientData}:
 		default:
 			log.Printf("Warning: client %s buffer full, state update dropped", client.ID)
 		}
 	}
 }
 
+// publishEvent numbers a named event, keeps it for replay, and queues it for the SSE clients that may see it
+func (sm *StateManager) publishEvent(event stateEvent) {
+	sm.eventMu.Lock()
+	defer sm.eventMu.Unlock()
+	sm.eventSeq++
+	event.ID = sm.eventSeq
+	sm.recent = append(sm.recent, event)
+	if len(sm.recent) > stateReplayEvents {
+		sm.recent = sm.recent[len(sm.recent)-stateReplayEvents:]
+	}
+
+	sm.clientMu.RLock()
+	defer sm.clientMu.RUnlock()
+	for _, client := range sm.clients {
+		if event.SessionID != "" && !userCanAccessSession(client.User, event.SessionID) {
+			continue
+		}
+		select {
+		case client.Channel <- event:
+		default:
+			log.Printf("Warning: client %s buffer full, %s event dropped", client.ID, event.Name)
+		}
+	}
+}
+
+// eventsSince returns the named events after lastID that user may see, and the latest event ID
+// complete is false when some of those events are no longer kept
+func (sm *StateManager) eventsSince(lastID int64, user *User) (events []stateEvent, latest int64, complete bool) {
+	sm.eventMu.Lock()
+	defer sm.eventMu.Unlock()
+	complete = len(sm.recent) == 0 || sm.recent[0].ID <= lastID+1 || lastID == 0
+	for _, event := range sm.recent {
+		if event.ID <= lastID || (event.SessionID != "" && !userCanAccessSession(user, event.SessionID)) {
+			continue
+		}
+		events = append(events, event)
+	}
+	return events, sm.eventSeq, complete
+}
+
+// broadcastEvent sends a named event to all SSE clients and chat WebSocket connections
+// SSE clients receive it as `event: {name}` so plain onmessage state handlers ignore it
+func (sm *StateManager) broadcastEvent(name string, payload map[string]interface{}) {
+	msg := make(map[string]interface{}, len(payload)+1)
+	for key, val := range payload {
+		msg[key] = val
+	}
+	msg["type"] = name
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", name, err)
+		return
+	}
+
+	sm.publishEvent(stateEvent{Name: name, Data: data})
+	sessionHub.BroadcastAll(msg)
+}
+
+// broadcastSessionEvent is broadcastEvent limited to clients that can access sessionId
+func (sm *StateManager) broadcastSessionEvent(sessionId string, name string, payload map[string]interface{}) {
+	msg := make(map[string]interface{}, len(payload)+2)
+	for key, val := range payload {
+		msg[key] = val
+	}
+	msg["type"] = name
+	msg["sessionId"] = sessionId
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", name, err)
+		return
+	}
+
+	sm.publishEvent(stateEvent{Name: name, Data: data, SessionID: sessionId})
+	sessionHub.BroadcastVisible(sessionId, msg)
+}
+
 // AddClient adds a new SSE client
-func (sm *StateManager) addClient() *StateClient {
+func (sm *StateManager) addClient(user *User) *StateClient {
 	client := &StateClient{
 		ID:      generateID(),
-		Channel: make(chan []byte, 10),
+		Channel: make(chan stateEvent, 10),
 		Done:    make(chan struct{}),
+		User:    user,
 	}
 
 	sm.clientMu.Lock()
@@ -128,7 +257,7 @@ func (sm *StateManager) getState() AppState {
 				session.IsLoading = false
 				session.ProcessID = nil
 				// Mark for cleanup if no longer needed
-				if !session.IsLoading {
+				if !session.WaitingForInput {
 					sessionsToClean = append(sessionsToClean, sessionId)
 				}
 			}
@@ -157,9 +286,11 @@ func (sm *StateManager) getState() AppState {
 
 	for sessionId, session := range sm.state.Sessions {
 		sessionCopy := &SessionState{
-			SessionID: session.SessionID,
-			IsLoading: session.IsLoading,
-			ProcessID: session.ProcessID,
+			SessionID:       session.SessionID,
+			IsLoading:       session.IsLoading,
+			ProcessID:       session.ProcessID,
+			WaitingForInput: session.WaitingForInput,
+			InputRequest:    session.InputRequest,
 		}
 		stateCopy.Sessions[sessionId] = sessionCopy
 	}
@@ -192,9 +323,14 @@ func (sm *StateManager) setSessionLoading(sessionId string, loading bool) {
 		}
 	}
 	sm.state.Sessions[sessionId].IsLoading = loading
+	// A new prompt is the answer to whatever the session was waiting for
+	if loading {
+		sm.state.Sessions[sessionId].WaitingForInput = false
+		sm.state.Sessions[sessionId].InputRequest = nil
+	}
 
 	// Clean up if session is no longer loading and has no process
-	if !loading && sm.state.Sessions[sessionId].ProcessID == nil {
+	if !loading && sm.state.Sessions[sessionId].ProcessID == nil && !sm.state.Sessions[sessionId].WaitingForInput {
 		delete(sm.state.Sessions, sessionId)
 	}
 
@@ -218,17 +354,64 @@ func (sm *StateManager) setSessionProcessID(sessionId string, processID *int) {
 	sm.state.Sessions[sessionId].ProcessID = processID
 
 	// Clean up if session is no longer loading and has no process
-	if !sm.state.Sessions[sessionId].IsLoading && processID == nil {
+	if !sm.state.Sessions[sessionId].IsLoading && processID == nil && !sm.state.Sessions[sessionId].WaitingForInput {
+		delete(sm.state.Sessions, sessionId)
+	}
+
+	go sm.broadcast()
+}
+
+// setSessionWaiting marks a session waiting for input, or clears it with nil
+func (sm *StateManager) setSessionWaiting(sessionId string, req *InputRequest) {
+	if sessionId == "" {
+		return
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	session, ok := sm.state.Sessions[sessionId]
+	if !ok {
+		if req == nil {
+			return
+		}
+		session = &SessionState{SessionID: sessionId}
+		sm.state.Sessions[sessionId] = session
+	}
+	session.WaitingForInput = req != nil
+	session.InputRequest = req
+
+	if req == nil && !session.IsLoading && session.ProcessID == nil {
 		delete(sm.state.Sessions, sessionId)
 	}
 
 	go sm.broadcast()
 }
 
+// answerInputRequest clears a session's wait when the tool results of a line answer its question
+func (sm *StateManager) answerInputRequest(sessionId string, toolUseIDs []string) {
+	sm.mu.RLock()
+	session, ok := sm.state.Sessions[sessionId]
+	pending := ""
+	if ok && session.InputRequest != nil {
+		pending = session.InputRequest.ToolUseID
+	}
+	sm.mu.RUnlock()
+	if pending == "" {
+		return
+	}
+	for _, id := range toolUseIDs {
+		if id == pending {
+			sm.setSessionWaiting(sessionId, nil)
+			return
+		}
+	}
+}
+
 // === HTTP Handlers ===
 
 func GetState(c *gin.Context) {
-	c.JSON(http.StatusOK, stateManager.getState())
+	c.JSON(http.StatusOK, filterState(stateManager.getState(), currentUser(c)))
 }
 
 func SubscribeState(c *gin.Context) {
@@ -239,22 +422,34 @@ func SubscribeState(c *gin.Context) {
 
 	flusher, ok := c.Writer.(http.Flusher)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Streaming not supported")
 		return
 	}
 
-	client := stateManager.addClient()
+	client := stateManager.addClient(currentUser(c))
 	defer stateManager.removeClient(client.ID)
 
+	// On reconnect, replay the named events missed since Last-Event-ID; the state
+	// snapshot that follows supersedes any missed state updates
+	events, latest, complete := stateManager.eventsSince(lastEventID(c), client.User)
+	if !complete {
+		events = append([]stateEvent{{Name: "resync", Data: []byte(`{"type":"resync"}`)}}, events...)
+	}
+	for _, event := range events {
+		if err := writeStateEvent(c.Writer, event); err != nil {
+			return
+		}
+	}
+
 	// Send initial state
 	stateManager.mu.RLock()
-	data, _ := json.Marshal(stateManager.state)
+	data, _ := json.Marshal(filterState(stateManager.state, client.User))
 	stateManager.mu.RUnlock()
-
-	c.Writer.Write([]byte("data: "))
-	c.Writer.Write(data)
-	c.Writer.Write([]byte("\n\n"))
+	if err := writeStateEvent(c.Writer, stateEvent{ID: latest, Data: data}); err != nil {
+		return
+	}
 	flusher.Flush()
+	lastSent := latest
 
 	// Heartbeat ticker
 	ticker := time.NewTicker(30 * time.Second)
@@ -270,14 +465,13 @@ func SubscribeState(c *gin.Context) {
 				return
 			}
 			flusher.Flush()
-		case data := <-client.Channel:
-			if _, err := c.Writer.Write([]byte("data: ")); err != nil {
-				return
-			}
-			if _, err := c.Writer.Write(data); err != nil {
-				return
+		case event := <-client.Channel:
+			// Already sent by the replay or the initial snapshot
+			if event.ID <= lastSent {
+				continue
 			}
-			if _, err := c.Writer.Write([]byte("\n\n")); err != nil {
+			lastSent = event.ID
+			if err := writeStateEvent(c.Writer, event); err != nil {
 				return
 			}
 			flusher.Flush()
@@ -285,6 +479,22 @@ func SubscribeState(c *gin.Context) {
 	}
 }
 
+// writeStateEvent writes one SSE event with its ID; unnamed events are state snapshots
+func writeStateEvent(w io.Writer, event stateEvent) error {
+	var buf bytes.Buffer
+	if event.ID > 0 {
+		fmt.Fprintf(&buf, "id: %d\n", event.ID)
+	}
+	if event.Name != "" {
+		buf.WriteString("event: " + event.Name + "\n")
+	}
+	buf.WriteString("data: ")
+	buf.Write(event.Data)
+	buf.WriteString("\n\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
 // === Internal functions for chat handler ===
 
 func SetSessionLoading(sessionId string, loading bool) {
@@ -295,6 +505,14 @@ func SetSessionProcessID(sessionId string, processID *int) {
 	stateManager.setSessionProcessID(sessionId, processID)
 }
 
+func broadcastStateEvent(name string, payload map[string]interface{}) {
+	stateManager.broadcastEvent(name, payload)
+}
+
+func broadcastSessionEvent(sessionId string, name string, payload map[string]interface{}) {
+	stateManager.broadcastSessionEvent(sessionId, name, payload)
+}
+
 func IsSessionLoading(sessionId string) bool {
 	// Get snapshot of active processes first (lock order: processLock before sm.mu)
 	processLock.RLock()
@@ -347,12 +565,11 @@ func GetSessionWorkDir(sessionID string) string {
 
 		sessionFile := filepath.Join(projectsDir, entry.Name(), sessionID+".jsonl")
 		if _, err := os.Stat(sessionFile); err == nil {
-			// Found the session file - derive workDir from directory name
-			// e.g., -home-seo -> /home/seo
-			dirName := entry.Name()
-			workDir := strings.ReplaceAll(dirName, "-", "/")
-			if !strings.HasPrefix(workDir, "/") {
-				workDir = "/" + workDir
+			// Found the session file - prefer the cwd claude recorded in it, since the
+			// directory name can't tell "-" and "." apart from "/" (e.g., -home-seo -> /home/seo)
+			workDir := sessionFileCWD(sessionFile)
+			if workDir == "" {
+				workDir = projectPathFromDir(entry.Name())
 			}
 			log.Printf("[GetSessionWorkDir] sessionID=%s -> workDir=%s", sessionID, workDir)
 			return workDir
@@ -365,62 +582,9 @@ func GetSessionWorkDir(sessionID string) string {
 // getAllSessions scans all Claude CLI sessions from ~/.claude/projects
 // Includes both indexed sessions and unindexed .jsonl files
 func getAllSessions() []Session {
-	projectsDir := getProjectsDir()
-	if projectsDir == "" {
-		return []Session{}
-	}
-
-	entries, err := os.ReadDir(projectsDir)
+	sessions, err := scanSessions("")
 	if err != nil {
 		return []Session{}
 	}
-
-	var allSessions []Session
-	indexedSessionIDs := make(map[string]bool)
-
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		projectDir := filepath.Join(projectsDir, entry.Name())
-		indexPath := filepath.Join(projectDir, "sessions-index.json")
-
-		// Try to read sessions from index
-		if data, err := os.ReadFile(indexPath); err == nil {
-			var index SessionsIndex
-			if err := json.Unmarshal(data, &index); err == nil {
-				for _, session := range index.Entries {
-					allSessions = append(allSessions, session)
-					indexedSessionIDs[session.SessionID] = true
-				}
-			}
-		}
-
-		// Scan for unindexed .jsonl files
-		files, err := os.ReadDir(projectDir)
-		if err != nil {
-			continue
-		}
-
-		for _, file := range files {
-			if file.IsDir() || !strings.HasSuffix(file.Name(), ".jsonl") {
-				continue
-			}
-
-			sessionID := strings.TrimSuffix(file.Name(), ".jsonl")
-			if indexedSessionIDs[sessionID] {
-				continue
-			}
-
-			// Parse unindexed session
-			filePath := filepath.Join(projectDir, file.Name())
-			session := parseUnindexedSession(filePath, entry.Name())
-			if session != nil {
-				allSessions = append(allSessions, *session)
-			}
-		}
-	}
-
-	return allSessions
+	return sessions
 }
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// storageKinds are the areas GET /api/storage reports and POST /api/storage/cleanup can prune
+var storageKinds = []string{"sessions", "uploads", "logs", "archives"}
+
+// StorageProject is the disk used by one project's sessions
+type StorageProject struct {
+	ProjectID   string `json:"projectId"` // directory name under ~/.claude/projects
+	ProjectPath string `json:"projectPath"`
+	Sessions    int    `json:"sessions"`
+	Bytes       int64  `json:"bytes"`
+}
+
+// StorageReport is the response of GET /api/storage
+type StorageReport struct {
+	Sessions int64            `json:"sessions"` // session transcripts, all projects
+	Projects []StorageProject `json:"projects"` // largest first
+	Uploads  int64            `json:"uploads"`
+	Logs     int64            `json:"logs"`
+	Archives int64            `json:"archives"` // transcript backups kept by rewind
+	Total    int64            `json:"total"`
+}
+
+// StorageCleanupRequest is the body of POST /api/storage/cleanup
+// Files must match every criterion given; pinned and running sessions are never removed
+type StorageCleanupRequest struct {
+	OlderThanDays int      `json:"olderThanDays"` // not modified for this many days
+	LargerThanMB  float64  `json:"largerThanMB"`
+	Kinds         []string `json:"kinds"` // sessions, uploads, logs, archives (default all)
+	Apply         bool     `json:"apply"` // delete the candidates; otherwise only preview them
+}
+
+// StorageCandidate is a file matched by a cleanup policy
+type StorageCandidate struct {
+	Kind        string `json:"kind"`
+	Path        string `json:"path"`
+	SessionID   string `json:"sessionId,omitempty"`
+	ProjectPath string `json:"projectPath,omitempty"`
+	Bytes       int64  `json:"bytes"`
+	Modified    int64  `json:"modified"` // unix seconds
+}
+
+// StorageCleanupResponse is the response of POST /api/storage/cleanup
+type StorageCleanupResponse struct {
+	Applied    bool               `json:"applied"`
+	Candidates []StorageCandidate `json:"candidates"`
+	Bytes      int64              `json:"bytes"`            // total size of the candidates
+	Deleted    int                `json:"deleted"`          // with apply
+	Failed     []string           `json:"failed,omitempty"` // candidates that could not be deleted, with the reason
+}
+
+// storageFile is a file found while walking a storage area
+type storageFile struct {
+	path string
+	info fs.FileInfo
+}
+
+// storageArea returns the directory holding a kind of data
+func storageArea(kind string) string {
+	switch kind {
+	case "sessions":
+		return getProjectsDir()
+	case "uploads":
+		return filepath.Join(os.TempDir(), uploadTempDir)
+	case "logs":
+		return getServerConfig().LogDir
+	case "archives":
+		return serverDataPath("rewind-backups")
+	}
+	return ""
+}
+
+// storageFiles lists the regular files under dir; a missing dir has none
+func storageFiles(dir string) []storageFile {
+	var files []storageFile
+	filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			files = append(files, storageFile{path: path, info: info})
+		}
+		return nil
+	})
+	return files
+}
+
+// GetStorage handles GET /api/storage
+// Reports bytes used by sessions per project, uploads, logs, and rewind backups
+func GetStorage(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	report := StorageReport{Projects: []StorageProject{}}
+
+	projects := make(map[string]*StorageProject)
+	projectsDir := getProjectsDir()
+	for _, f := range storageFiles(projectsDir) {
+		report.Sessions += f.info.Size()
+		rel, err := filepath.Rel(projectsDir, f.path)
+		if err != nil {
+			continue
+		}
+		projectID, _, nested := strings.Cut(rel, string(filepath.Separator))
+		if !nested {
+			continue
+		}
+		p, ok := projects[projectID]
+		if !ok {
+			p = &StorageProject{ProjectID: projectID, ProjectPath: projectPathFromDir(projectID)}
+			projects[projectID] = p
+		}
+		p.Bytes += f.info.Size()
+		if filepath.Dir(f.path) == filepath.Join(projectsDir, projectID) && strings.HasSuffix(f.path, ".jsonl") {
+			p.Sessions++
+		}
+	}
+	for _, p := range projects {
+		report.Projects = append(report.Projects, *p)
+	}
+	sort.Slice(report.Projects, func(i, j int) bool { return report.Projects[i].Bytes > report.Projects[j].Bytes })
+
+	for _, f := range storageFiles(storageArea("uploads")) {
+		report.Uploads += f.info.Size()
+	}
+	for _, f := range storageFiles(storageArea("logs")) {
+		report.Logs += f.info.Size()
+	}
+	for _, f := range storageFiles(storageArea("archives")) {
+		report.Archives += f.info.Size()
+	}
+	report.Total = report.Sessions + report.Uploads + report.Logs + report.Archives
+	c.JSON(http.StatusOK, report)
+}
+
+// cleanupCandidates returns the files of a kind matching the policy
+func cleanupCandidates(kind string, cutoff time.Time, minBytes int64) []StorageCandidate {
+	area := storageArea(kind)
+	var candidates []StorageCandidate
+	for _, f := range storageFiles(area) {
+		if !f.info.ModTime().Before(cutoff) || f.info.Size() < minBytes {
+			continue
+		}
+		candidate := StorageCandidate{Kind: kind, Path: f.path, Bytes: f.info.Size(), Modified: f.info.ModTime().Unix()}
+		switch kind {
+		case "sessions":
+			// Only transcripts directly in a project directory; the rest belongs to claude
+			if !strings.HasSuffix(f.path, ".jsonl") || filepath.Dir(filepath.Dir(f.path)) != area {
+				continue
+			}
+			candidate.SessionID = strings.TrimSuffix(filepath.Base(f.path), ".jsonl")
+			candidate.ProjectPath = projectPathFromDir(filepath.Base(filepath.Dir(f.path)))
+			if isSessionPinned(candidate.SessionID) || IsSessionLoading(candidate.SessionID) {
+				continue
+			}
+		case "logs":
+			// The live log is rotated, not deleted
+			if filepath.Base(f.path) == "server.log" {
+				continue
+			}
+		case "uploads":
+			if strings.HasSuffix(f.path, uploadMetaSuffix) {
+				continue // removed with its upload
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// deleteCandidate removes one cleanup candidate along with anything kept beside it
+func deleteCandidate(candidate StorageCandidate) error {
+	switch candidate.Kind {
+	case "sessions":
+		return removeSessionFile(filepath.Dir(candidate.Path), candidate.SessionID)
+	case "uploads":
+		if err := os.Remove(candidate.Path); err != nil {
+			return err
+		}
+		os.Remove(candidate.Path + uploadMetaSuffix)
+		return nil
+	}
+	return os.Remove(candidate.Path)
+}
+
+// CleanupStorage handles POST /api/storage/cleanup
+// Lists the files matching a policy, and deletes them when apply is set
+func CleanupStorage(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req StorageCleanupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	if req.OlderThanDays < 0 || req.LargerThanMB < 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "olderThanDays and largerThanMB must not be negative")
+		return
+	}
+	if req.OlderThanDays == 0 && req.LargerThanMB == 0 {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Set olderThanDays and/or largerThanMB")
+		return
+	}
+	kinds := req.Kinds
+	if len(kinds) == 0 {
+		kinds = storageKinds
+	}
+	for _, kind := range kinds {
+		if storageArea(kind) == "" {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("Unknown kind %q (expected %s)", kind, strings.Join(storageKinds, ", ")))
+			return
+		}
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -req.OlderThanDays)
+	minBytes := int64(req.LargerThanMB * 1024 * 1024)
+	resp := StorageCleanupResponse{Applied: req.Apply, Candidates: []StorageCandidate{}}
+	for _, kind := range kinds {
+		resp.Candidates = append(resp.Candidates, cleanupCandidates(kind, cutoff, minBytes)...)
+	}
+	for _, candidate := range resp.Candidates {
+		resp.Bytes += candidate.Bytes
+	}
+
+	if req.Apply {
+		for _, candidate := range resp.Candidates {
+			if err := deleteCandidate(candidate); err != nil {
+				resp.Failed = append(resp.Failed, fmt.Sprintf("%s: %v", candidate.Path, err))
+				continue
+			}
+			resp.Deleted++
+		}
+		log.Printf("[Storage] Cleanup deleted %d files (%d bytes matched, %d failed)", resp.Deleted, resp.Bytes, len(resp.Failed))
+	}
+	c.JSON(http.StatusOK, resp)
+}
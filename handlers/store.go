@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// serverDataDir holds server-owned metadata (users, schedules, templates, ...)
+// Defaults to ~/.config/claude-web-ui
+var serverDataDir string
+
+// SetServerDataDir overrides the directory used for server metadata
+func SetServerDataDir(dir string) {
+	serverDataDir = dir
+}
+
+// getServerDataDir returns the server metadata directory
+func getServerDataDir() string {
+	if serverDataDir != "" {
+		return serverDataDir
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		configDir = filepath.Join(os.TempDir(), "claude-web-ui")
+		return configDir
+	}
+	return filepath.Join(configDir, "claude-web-ui")
+}
+
+// serverDataPath returns the path of a file inside the server metadata directory
+func serverDataPath(name string) string {
+	return filepath.Join(getServerDataDir(), name)
+}
+
+// loadJSONFile reads JSON from path into v
+// A missing file is not an error and leaves v untouched
+func loadJSONFile(path string, v interface{}) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamingRoutes are the method and route pattern of the SSE and WebSocket endpoints
+var streamingRoutes = func() map[string]bool {
+	routes := make(map[string]bool)
+	for _, op := range APIOperations {
+		if op.Stream != "" {
+			routes[op.Method+" "+op.Path] = true
+		}
+	}
+	return routes
+}()
+
+// StreamDeadlineMiddleware lifts the server's write timeout for SSE and WebSocket
+// endpoints, which hold their response open for as long as the client follows
+func StreamDeadlineMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if streamingRoutes[c.Request.Method+" "+c.FullPath()] {
+			// Not every ResponseWriter supports deadlines; there is nothing to lift then
+			http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,674 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamInterruptGrace is how long an interrupted turn may take to end before its process is killed
+	streamInterruptGrace = 5 * time.Second
+	// streamReapInterval is how often idle processes are looked for
+	streamReapInterval = 30 * time.Second
+	// streamPendingLimit bounds the output a warm process may write before its first prompt
+	streamPendingLimit = 64
+)
+
+// StreamInputConfig configures the stream chat backend
+type StreamInputConfig struct {
+	// IdleMinutes closes a session's process after this long without a turn
+	IdleMinutes int `yaml:"idleMinutes" json:"idleMinutes"`
+	// MaxProcesses bounds the processes kept alive between turns; the least recently used idle one is closed first
+	MaxProcesses int `yaml:"maxProcesses" json:"maxProcesses"`
+	// Warm keeps this many processes started ahead for new sessions with settings used recently
+	Warm int `yaml:"warm" json:"warm"`
+	// WarmWorkDirs are warmed at startup with each project's chat defaults
+	WarmWorkDirs []string `yaml:"warmWorkDirs" json:"warmWorkDirs"`
+}
+
+// streamChatBackend keeps one claude process per session, started with --input-format stream-json,
+// and writes each prompt to its stdin instead of starting claude with --resume for every message
+// A process is reused while the turn's settings match the ones it was started with
+type streamChatBackend struct{}
+
+func (streamChatBackend) Name() string { return "stream" }
+
+// streamSession is a long-lived claude process
+// Its fields are guarded by streamMu
+type streamSession struct {
+	key        string // the settings the process was started with
+	sessionID  string // known once claude reports it
+	workDir    string
+	cmd        *exec.Cmd
+	stdin      io.WriteCloser
+	cleanup    func()
+	remote     bool
+	turn       *streamTurn // the turn in progress, or nil when idle
+	started    time.Time
+	lastUsed   time.Time
+	turns      int
+	transcript int64    // size of the session file after the last turn; another writer changes it
+	warm       bool     // started ahead, waiting in streamWarm for a new session
+	pending    [][]byte // output written before the first prompt
+	closed     bool     // no longer takes turns
+	exited     bool     // the process has been waited for
+	requests   int      // control request counter
+}
+
+// streamTurn is one prompt sent to a streamSession
+type streamTurn struct {
+	session   *streamSession
+	stdout    *io.PipeReader
+	stdoutW   *io.PipeWriter
+	stderr    *io.PipeReader
+	stderrW   *io.PipeWriter
+	done      chan struct{}
+	err       error
+	interrupt *time.Timer
+	pending   [][]byte // the process's output from before the turn, sent first
+}
+
+// streamTemplate is how warm processes for new sessions with one key are started
+type streamTemplate struct {
+	turn  ChatTurn
+	key   string
+	mode  string
+	model string
+}
+
+var (
+	streamMu       sync.Mutex
+	streamSessions = make(map[string]*streamSession)   // by session ID
+	streamLive     = make(map[*streamSession]bool)     // every running process, including ones whose session isn't known yet
+	streamWarm     = make(map[string][]*streamSession) // processes started ahead for new sessions, by key
+	streamStarts   = make(map[string]int)              // turns by how their process was found: reused, warm, or cold
+	streamReapOnce sync.Once
+)
+
+// streamSessionKey identifies the settings a process was started with
+func streamSessionKey(turn ChatTurn, mode string, model string) string {
+	key, _ := json.Marshal([]interface{}{turn.WorkDir, turn.Backend, mode, model, turn.AllowedTools, turn.MCPServers, turn.Env, turn.AddDirs})
+	return string(key)
+}
+
+// transcriptSize is the size of a session's file, or -1 when there is none
+func transcriptSize(sessionID string) int64 {
+	if path := findSessionFile(sessionID); path != "" {
+		if info, err := os.Stat(path); err == nil {
+			return info.Size()
+		}
+	}
+	return -1
+}
+
+func (streamChatBackend) Start(turn ChatTurn) (ChatProcess, error) {
+	if !claudeSupports("--input-format") {
+		return nil, withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("claude has no --input-format; update it or use the cli chat backend"))
+	}
+	if reason := localOnlyReason(turn.WorkDir, turn.Backend); reason != "" && len(turn.MCPServers) > 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s", reason))
+	}
+	var content []apiBlock
+	for _, path := range turn.ImagePaths {
+		block, err := apiImageBlock(path)
+		if err != nil {
+			return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, err)
+		}
+		content = append(content, block)
+	}
+	if turn.Prompt != "" {
+		content = append(content, apiBlock{Type: "text", Text: turn.Prompt})
+	}
+	if len(content) == 0 {
+		return nil, withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("prompt is required"))
+	}
+
+	cfg := getServerConfig().Claude
+	mode, model := turn.PermissionMode, turn.Model
+	if mode == "" {
+		mode = cfg.PermissionMode
+	}
+	if turn.PlanMode {
+		mode = "plan"
+	}
+	if model == "" {
+		model = cfg.DefaultModel
+	}
+	key := streamSessionKey(turn, mode, model)
+
+	streamReapOnce.Do(func() { go reapStreamSessions() })
+	t, start, err := acquireStreamSession(turn, key, mode, model)
+	if err != nil {
+		return nil, err
+	}
+	s := t.session
+	metrics.add("claude_stream_turns_total", 1, "start", start)
+	if turn.Logger != nil {
+		turn.Logger.Info("Sending prompt to claude over stdin", "sessionId", turn.SessionID, "workDir", turn.WorkDir, "start", start)
+	}
+
+	message, _ := json.Marshal(map[string]interface{}{
+		"type":               "user",
+		"message":            apiMessage{Role: "user", Content: content},
+		"parent_tool_use_id": nil,
+		"session_id":         turn.SessionID,
+	})
+	if _, err := s.stdin.Write(append(message, '\n')); err != nil {
+		s.kill()
+		t.finish(err)
+		return nil, withCode(http.StatusInternalServerError, ErrCLIFailed, fmt.Errorf("failed to send prompt to claude: %w", err))
+	}
+	return t, nil
+}
+
+// acquireStreamSession starts a turn on the session's live process, or on a new one when there is none,
+// it was started with other settings, or the transcript was changed by something else since
+// A new session takes a warm process when one with its settings is ready
+func acquireStreamSession(turn ChatTurn, key string, mode string, model string) (*streamTurn, string, error) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if s := streamSessions[turn.SessionID]; turn.SessionID != "" && s != nil && !s.closed {
+		if s.turn != nil {
+			return nil, "", withCode(http.StatusConflict, ErrSessionBusy, fmt.Errorf("This session is already processing a request"))
+		}
+		if s.key == key && s.transcript == transcriptSize(turn.SessionID) {
+			streamStarts["reused"]++
+			return newStreamTurn(s), "reused", nil
+		}
+		s.closeLocked()
+	}
+
+	fresh := turn.SessionID == "" && !turn.Continue
+	template := streamTemplate{turn: turn, key: key, mode: mode, model: model}
+	template.turn.Prompt, template.turn.ImagePaths, template.turn.Logger = "", nil, nil
+	if fresh {
+		if s := takeWarmLocked(key); s != nil {
+			streamStarts["warm"]++
+			go refillStreamPool(template)
+			return newStreamTurn(s), "warm", nil
+		}
+	}
+
+	// Make room by closing the least recently used idle process
+	if limit := getServerConfig().Claude.StreamInput.MaxProcesses; limit > 0 && len(streamLive) >= limit {
+		var oldest *streamSession
+		for s := range streamLive {
+			if s.turn == nil && (oldest == nil || s.lastUsed.Before(oldest.lastUsed)) {
+				oldest = s
+			}
+		}
+		if oldest != nil {
+			oldest.closeLocked()
+		}
+	}
+
+	s, err := spawnStreamSessionLocked(turn, key, mode, model)
+	if err != nil {
+		return nil, "", err
+	}
+	streamStarts["cold"]++
+	if fresh {
+		go refillStreamPool(template)
+	}
+	return newStreamTurn(s), "cold", nil
+}
+
+// spawnStreamSessionLocked starts a claude process waiting for prompts on stdin; caller must hold streamMu
+func spawnStreamSessionLocked(turn ChatTurn, key string, mode string, model string) (*streamSession, error) {
+	args := claudeArgs(mode, model)
+	args = append(args, "--input-format", "stream-json")
+	if len(turn.AllowedTools) > 0 {
+		args = append(args, "--allowedTools", strings.Join(turn.AllowedTools, ","))
+	}
+	args = append(args, addDirArgs(turn.AddDirs)...)
+	if turn.SessionID != "" {
+		args = append(args, "--resume", turn.SessionID)
+	} else if turn.Continue {
+		args = append(args, "--continue")
+	}
+	mcpArgs, cleanupMCP, err := prepareMCPConfig(turn.MCPServers, turn.WorkDir)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, mcpArgs...)
+	cmd, stopBackend, err := claudeCommand(turn.WorkDir, turn.Backend, args)
+	if err != nil {
+		cleanupMCP()
+		return nil, err
+	}
+	remote := !runsLocally(turn.WorkDir, turn.Backend)
+	cmd.Env = os.Environ()
+	if !remote {
+		cmd.Env = append(cmd.Env, turn.Env...)
+	}
+	if turn.Logger != nil {
+		turn.Logger.Info("Executing claude", "args", strings.Join(args, " "), "workDir", turn.WorkDir, "sessionId", turn.SessionID, "backend", turn.Backend)
+	}
+
+	now := time.Now()
+	s := &streamSession{key: key, sessionID: turn.SessionID, workDir: turn.WorkDir, cmd: cmd, remote: remote, started: now, lastUsed: now}
+	s.cleanup = func() {
+		stopBackend()
+		cleanupMCP()
+	}
+	fail := func(what string, err error) (*streamSession, error) {
+		s.cleanup()
+		return nil, withCode(http.StatusInternalServerError, errorCodeFor(err, ErrInternal), fmt.Errorf("%s: %w", what, err))
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fail("failed to create stdout pipe", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fail("failed to create stderr pipe", err)
+	}
+	if s.stdin, err = cmd.StdinPipe(); err != nil {
+		return fail("failed to create stdin pipe", err)
+	}
+	startInProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return fail("failed to start claude command", err)
+	}
+	limitProcessResources(cmd)
+	streamLive[s] = true
+	if s.sessionID != "" {
+		streamSessions[s.sessionID] = s
+	}
+	go s.run(stdout, stderr)
+	return s, nil
+}
+
+// takeWarmLocked hands out a warm process started with key, or nil; caller must hold streamMu
+func takeWarmLocked(key string) *streamSession {
+	for len(streamWarm[key]) > 0 {
+		s := streamWarm[key][0]
+		streamWarm[key] = streamWarm[key][1:]
+		if !s.closed {
+			s.warm = false
+			return s
+		}
+	}
+	delete(streamWarm, key)
+	return nil
+}
+
+// dropWarmLocked removes a process from the warm pool; caller must hold streamMu
+func dropWarmLocked(s *streamSession) {
+	if !s.warm {
+		return
+	}
+	s.warm = false
+	pool := streamWarm[s.key]
+	for i, w := range pool {
+		if w == s {
+			streamWarm[s.key] = append(pool[:i:i], pool[i+1:]...)
+			break
+		}
+	}
+	if len(streamWarm[s.key]) == 0 {
+		delete(streamWarm, s.key)
+	}
+}
+
+// refillStreamPool starts warm processes for new sessions with the template's settings
+// until claude.streamInput.warm are ready, without going over maxProcesses
+func refillStreamPool(template streamTemplate) {
+	cfg := getServerConfig().Claude.StreamInput
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for len(streamWarm[template.key]) < cfg.Warm && (cfg.MaxProcesses == 0 || len(streamLive) < cfg.MaxProcesses) {
+		s, err := spawnStreamSessionLocked(template.turn, template.key, template.mode, template.model)
+		if err != nil {
+			log.Printf("[Stream] Failed to start a warm claude process in %s: %v", template.turn.WorkDir, err)
+			return
+		}
+		s.warm = true
+		streamWarm[template.key] = append(streamWarm[template.key], s)
+	}
+}
+
+// StartStreamPool warms claude.streamInput.warmWorkDirs at startup, with each project's chat defaults,
+// when the stream chat backend is the default
+func StartStreamPool() {
+	cfg := getServerConfig().Claude
+	if cfg.ChatBackend != "stream" || cfg.StreamInput.Warm <= 0 || len(cfg.StreamInput.WarmWorkDirs) == 0 {
+		return
+	}
+	streamReapOnce.Do(func() { go reapStreamSessions() })
+	for _, dir := range cfg.StreamInput.WarmWorkDirs {
+		turn := ChatTurn{WorkDir: expandHome(dir)}
+		if err := inheritProjectSettings(&turn); err != nil {
+			log.Printf("[Stream] Not warming %s: %v", dir, err)
+			continue
+		}
+		mode, model := turn.PermissionMode, turn.Model
+		if mode == "" {
+			mode = cfg.PermissionMode
+		}
+		if model == "" {
+			model = cfg.DefaultModel
+		}
+		go refillStreamPool(streamTemplate{turn: turn, key: streamSessionKey(turn, mode, model), mode: mode, model: model})
+	}
+}
+
+// newStreamTurn makes a turn the one in progress on s; caller must hold streamMu
+// Output a warm process wrote before its first prompt goes out first
+func newStreamTurn(s *streamSession) *streamTurn {
+	t := &streamTurn{session: s, done: make(chan struct{}), pending: s.pending}
+	t.stdout, t.stdoutW = io.Pipe()
+	t.stderr, t.stderrW = io.Pipe()
+	s.pending = nil
+	s.turn = t
+	s.turns++
+	return t
+}
+
+// current is the turn in progress, or nil
+func (s *streamSession) current() *streamTurn {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	return s.turn
+}
+
+// run reads the process's output until it exits, handing lines to the turn in progress
+// A result event ends the turn; the process then waits for the next prompt
+func (s *streamSession) run(stdout io.Reader, stderr io.Reader) {
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		scanner := bufio.NewScanner(stderr)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			if t := s.current(); t != nil {
+				t.stderrW.Write(append(scanner.Bytes(), '\n'))
+			}
+		}
+	}()
+
+	reader := bufio.NewReader(stdout)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			s.handleLine(line)
+		}
+		if err != nil {
+			break
+		}
+	}
+	readers.Wait()
+	err := s.cmd.Wait()
+	s.cleanup()
+
+	streamMu.Lock()
+	s.closed, s.exited = true, true
+	dropWarmLocked(s)
+	delete(streamLive, s)
+	if streamSessions[s.sessionID] == s {
+		delete(streamSessions, s.sessionID)
+	}
+	t := s.turn
+	streamMu.Unlock()
+	if t != nil {
+		if err == nil {
+			err = errors.New("claude exited before finishing the turn")
+		}
+		t.finish(err)
+	}
+}
+
+// handleLine forwards one stdout line, noting the session ID and the end of the turn
+func (s *streamSession) handleLine(line []byte) {
+	var event struct {
+		Type      string `json:"type"`
+		Subtype   string `json:"subtype"`
+		SessionID string `json:"session_id"`
+	}
+	json.Unmarshal(line, &event)
+	if event.Type == "control_response" {
+		return // answers to our interrupts
+	}
+	if event.SessionID != "" {
+		streamMu.Lock()
+		if event.SessionID != s.sessionID {
+			if streamSessions[s.sessionID] == s {
+				delete(streamSessions, s.sessionID)
+			}
+			s.sessionID = event.SessionID
+		}
+		if !s.closed {
+			streamSessions[s.sessionID] = s
+		}
+		streamMu.Unlock()
+	}
+	streamMu.Lock()
+	t := s.turn
+	if t == nil {
+		// A warm process may report before its first prompt; later idle output belongs to no turn
+		if s.turns == 0 && len(s.pending) < streamPendingLimit {
+			s.pending = append(s.pending, append([]byte(nil), line...))
+		}
+		streamMu.Unlock()
+		return
+	}
+	pending := t.pending
+	t.pending = nil
+	streamMu.Unlock()
+	for _, held := range pending {
+		t.stdoutW.Write(held)
+	}
+	t.stdoutW.Write(line)
+	if event.Type == "result" {
+		streamMu.Lock()
+		sessionID := s.sessionID
+		streamMu.Unlock()
+		size := transcriptSize(sessionID)
+		streamMu.Lock()
+		s.transcript = size
+		streamMu.Unlock()
+		t.finish(nil)
+	}
+}
+
+// finish ends the turn and frees its process for the next one
+func (t *streamTurn) finish(err error) {
+	s := t.session
+	streamMu.Lock()
+	if s.turn != t {
+		streamMu.Unlock()
+		return
+	}
+	s.turn = nil
+	s.lastUsed = time.Now()
+	streamMu.Unlock()
+	if t.interrupt != nil {
+		t.interrupt.Stop()
+	}
+	t.stdoutW.Close()
+	t.stderrW.Close()
+	t.err = err
+	close(t.done)
+}
+
+// closeLocked ends an idle process by closing its stdin; caller must hold streamMu
+func (s *streamSession) closeLocked() {
+	dropWarmLocked(s)
+	delete(streamLive, s)
+	if streamSessions[s.sessionID] == s {
+		delete(streamSessions, s.sessionID)
+	}
+	s.closed = true
+	s.stdin.Close()
+	go func() {
+		// claude exits on EOF; one that doesn't within the grace period is killed
+		time.Sleep(streamInterruptGrace)
+		streamMu.Lock()
+		running := !s.exited
+		streamMu.Unlock()
+		if running {
+			killProcessGroup(s.cmd)
+		}
+	}()
+}
+
+// kill stops the process; run then ends the turn in progress
+func (s *streamSession) kill() {
+	killProcessGroup(s.cmd)
+}
+
+func (t *streamTurn) Stdout() io.Reader { return t.stdout }
+func (t *streamTurn) Stderr() io.Reader { return t.stderr }
+func (t *streamTurn) Stdin() io.Writer  { return nil }
+
+func (t *streamTurn) Wait() error {
+	<-t.done
+	return t.err
+}
+
+// Interrupt asks claude to stop the turn, keeping the process; one that doesn't stop in time is killed
+func (t *streamTurn) Interrupt() error {
+	s := t.session
+	streamMu.Lock()
+	if s.turn != t || t.interrupt != nil {
+		streamMu.Unlock()
+		return nil
+	}
+	s.requests++
+	request, _ := json.Marshal(map[string]interface{}{
+		"type":       "control_request",
+		"request_id": fmt.Sprintf("interrupt-%d", s.requests),
+		"request":    map[string]string{"subtype": "interrupt"},
+	})
+	t.interrupt = time.AfterFunc(streamInterruptGrace, s.kill)
+	streamMu.Unlock()
+	if _, err := s.stdin.Write(append(request, '\n')); err != nil {
+		s.kill()
+	}
+	return nil
+}
+
+// Pid is the shared process, for resource telemetry; 0 when claude runs on another host or in a container
+func (t *streamTurn) Pid() int {
+	if t.session.remote || t.session.cmd.Process == nil {
+		return 0
+	}
+	return t.session.cmd.Process.Pid
+}
+
+// reapStreamSessions closes processes that have been idle longer than claude.streamInput.idleMinutes
+func reapStreamSessions() {
+	for range time.Tick(streamReapInterval) {
+		idle := time.Duration(getServerConfig().Claude.StreamInput.IdleMinutes) * time.Minute
+		if idle <= 0 {
+			continue
+		}
+		streamMu.Lock()
+		for s := range streamLive {
+			if s.turn == nil && !s.warm && time.Since(s.lastUsed) > idle {
+				log.Printf("[Stream] Closing idle claude process of session %s", s.sessionID)
+				s.closeLocked()
+			}
+		}
+		streamMu.Unlock()
+	}
+}
+
+// closeStreamSession ends the idle process of a session, e.g. when the session is deleted
+func closeStreamSession(sessionID string) {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	if s := streamSessions[sessionID]; s != nil && s.turn == nil {
+		s.closeLocked()
+	}
+}
+
+// StreamProcess is one process of the stream chat backend in GET /api/chat/pool
+type StreamProcess struct {
+	SessionID   string `json:"sessionId,omitempty"` // empty for warm processes
+	WorkDir     string `json:"workDir"`
+	State       string `json:"state"` // busy, idle, or warm
+	PID         int    `json:"pid,omitempty"`
+	IdleSeconds int    `json:"idleSeconds"`
+	Turns       int    `json:"turns"`
+	StartedAt   string `json:"startedAt"`
+}
+
+// StreamPoolStatus is the response of GET /api/chat/pool
+type StreamPoolStatus struct {
+	Processes    []StreamProcess `json:"processes"`
+	Warm         int             `json:"warm"`
+	MaxProcesses int             `json:"maxProcesses"`
+	Starts       map[string]int  `json:"starts"` // turns since startup by how their process was found: reused, warm, or cold
+}
+
+// state is busy, idle, or warm; caller must hold streamMu
+func (s *streamSession) state() string {
+	switch {
+	case s.turn != nil:
+		return "busy"
+	case s.warm:
+		return "warm"
+	}
+	return "idle"
+}
+
+// streamPoolCounts counts the stream chat backend's processes by state, for /metrics
+func streamPoolCounts() map[string]int {
+	counts := map[string]int{"busy": 0, "idle": 0, "warm": 0}
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for s := range streamLive {
+		counts[s.state()]++
+	}
+	return counts
+}
+
+// GetStreamPool handles GET /api/chat/pool
+// Lists the processes the stream chat backend keeps, and how often turns reused one, took a warm one, or started claude
+func GetStreamPool(c *gin.Context) {
+	cfg := getServerConfig().Claude.StreamInput
+	status := StreamPoolStatus{Processes: []StreamProcess{}, Warm: cfg.Warm, MaxProcesses: cfg.MaxProcesses,
+		Starts: map[string]int{"reused": 0, "warm": 0, "cold": 0}}
+	streamMu.Lock()
+	for kind, n := range streamStarts {
+		status.Starts[kind] = n
+	}
+	for s := range streamLive {
+		p := StreamProcess{SessionID: s.sessionID, WorkDir: s.workDir, State: s.state(), Turns: s.turns,
+			StartedAt: s.started.UTC().Format(time.RFC3339)}
+		if p.State != "busy" {
+			p.IdleSeconds = int(time.Since(s.lastUsed).Seconds())
+		}
+		if !s.remote && s.cmd.Process != nil {
+			p.PID = s.cmd.Process.Pid
+		}
+		status.Processes = append(status.Processes, p)
+	}
+	streamMu.Unlock()
+	sort.Slice(status.Processes, func(i, j int) bool { return status.Processes[i].StartedAt < status.Processes[j].StartedAt })
+	c.JSON(http.StatusOK, status)
+}
+
+// stopStreamSessions kills every process kept for the stream chat backend, on server shutdown
+func stopStreamSessions() {
+	streamMu.Lock()
+	defer streamMu.Unlock()
+	for s := range streamLive {
+		s.kill()
+	}
+}
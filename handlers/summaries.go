@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// summaryTimeout bounds one summarizer run
+const summaryTimeout = 90 * time.Second
+
+// summaryInputLimit caps the transcript text sent to the summarizer, keeping the latest messages
+const summaryInputLimit = 24000
+
+// summaryMessageLimit caps each message's share of the summarizer input
+const summaryMessageLimit = 600
+
+const summaryPrompt = `Summarize the following conversation between a user and a coding assistant in one sentence of at most 160 characters.
+Say what was worked on, not how the conversation went. Reply with the summary only, no preamble or quotes.
+
+`
+
+// SessionSummary is a generated blurb for a session
+type SessionSummary struct {
+	Summary      string    `json:"summary"`
+	Model        string    `json:"model"`
+	MessageCount int       `json:"messageCount"` // messages summarized; the summary is stale once the session has more
+	GeneratedAt  time.Time `json:"generatedAt"`
+}
+
+// SummarizeRequest is the body of POST /api/session/:id/summarize
+type SummarizeRequest struct {
+	Force bool `json:"force"` // regenerate even if the cached summary is current
+}
+
+// SummarizeResponse is the response of POST /api/session/:id/summarize
+type SummarizeResponse struct {
+	SessionSummary
+	SessionID string `json:"sessionId"`
+	Cached    bool   `json:"cached"`
+}
+
+var (
+	summariesMu     sync.Mutex
+	summaryStore    map[string]*SessionSummary
+	summariesLoaded bool
+)
+
+func summariesPath() string {
+	return serverDataPath("summaries.json")
+}
+
+// loadSummaries reads the summary store once; caller must hold summariesMu
+func loadSummaries() error {
+	if summariesLoaded {
+		return nil
+	}
+	if err := loadJSONFile(summariesPath(), &summaryStore); err != nil {
+		return err
+	}
+	if summaryStore == nil {
+		summaryStore = make(map[string]*SessionSummary)
+	}
+	summariesLoaded = true
+	return nil
+}
+
+// getSessionSummary returns the cached summary of a session, or nil
+func getSessionSummary(sessionID string) *SessionSummary {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	if err := loadSummaries(); err != nil {
+		return nil
+	}
+	if s, ok := summaryStore[sessionID]; ok {
+		copied := *s
+		return &copied
+	}
+	return nil
+}
+
+// attachSummaries fills in Summary for sessions that have one
+func attachSummaries(sessions []Session) {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	if err := loadSummaries(); err != nil {
+		log.Printf("[Summaries] Failed to load summaries: %v", err)
+		return
+	}
+	for i := range sessions {
+		if s, ok := summaryStore[sessions[i].SessionID]; ok {
+			sessions[i].Summary = s.Summary
+		}
+	}
+}
+
+// storeSessionSummary saves the summary of a session
+func storeSessionSummary(sessionID string, summary SessionSummary) {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	if err := loadSummaries(); err != nil {
+		return
+	}
+	summaryStore[sessionID] = &summary
+	if err := writeJSONFileAtomic(summariesPath(), summaryStore); err != nil {
+		log.Printf("[Summaries] Failed to save summaries: %v", err)
+	}
+}
+
+// forgetSessionSummary drops a deleted session's summary
+func forgetSessionSummary(sessionID string) {
+	summariesMu.Lock()
+	defer summariesMu.Unlock()
+	if err := loadSummaries(); err != nil {
+		return
+	}
+	if _, ok := summaryStore[sessionID]; !ok {
+		return
+	}
+	delete(summaryStore, sessionID)
+	if err := writeJSONFileAtomic(summariesPath(), summaryStore); err != nil {
+		log.Printf("[Summaries] Failed to save summaries: %v", err)
+	}
+}
+
+// summaryInput condenses a transcript to its user and assistant text
+func summaryInput(path string) (string, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+
+	var parts []string
+	count := 0
+	reader := bufio.NewReaderSize(file, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var msg Message
+			if json.Unmarshal(line, &msg) == nil && isHistoryMessage(msg.Type) {
+				count++
+				text := strings.TrimSpace(messageSearchText(msg.Message["content"]))
+				if text != "" {
+					if runes := []rune(text); len(runes) > summaryMessageLimit {
+						text = string(runes[:summaryMessageLimit]) + "…"
+					}
+					role := "User"
+					if msg.Type == "assistant" {
+						role = "Assistant"
+					}
+					parts = append(parts, role+": "+text)
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// Keep the first prompt, then as many of the latest messages as fit
+	if len(parts) == 0 {
+		return "", count, nil
+	}
+	size := len(parts[0])
+	first := len(parts)
+	for first > 1 && size+len(parts[first-1]) <= summaryInputLimit {
+		first--
+		size += len(parts[first])
+	}
+	kept := []string{parts[0]}
+	if first > 1 {
+		kept = append(kept, "[...]")
+	}
+	kept = append(kept, parts[first:]...)
+	return strings.Join(kept, "\n\n"), count, nil
+}
+
+// runSummarizer sends prompt to the summary model, over the Anthropic API when that is the chat backend
+// and through claude -p otherwise, and returns the reply and the model used
+func runSummarizer(ctx context.Context, prompt string) (string, string, error) {
+	cfg := getServerConfig().Claude
+	if cfg.ChatBackend == "api" {
+		return runAPISummarizer(ctx, cfg.API, prompt)
+	}
+
+	if _, err := exec.LookPath("claude"); err != nil {
+		return "", "", withCode(http.StatusInternalServerError, ErrCLINotFound, fmt.Errorf("claude CLI not found on PATH"))
+	}
+	// Run in a directory of its own so the throwaway session can be removed afterwards
+	dir := serverDataPath("summarizer")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", "", err
+	}
+	cmd := exec.CommandContext(ctx, "claude", "-p", "--model", cfg.SummaryModel, "--output-format", "json")
+	cmd.Dir = dir
+	cmd.Env = os.Environ()
+	cmd.Stdin = strings.NewReader(prompt)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", withCode(http.StatusBadGateway, ErrCLIFailed, fmt.Errorf("summarizer failed: %v: %s", err, strings.TrimSpace(stderr.String())))
+	}
+	result := parseResultEvent(strings.TrimSpace(string(out)))
+	if result == nil {
+		return "", "", withCode(http.StatusBadGateway, ErrCLIFailed, fmt.Errorf("summarizer returned no result"))
+	}
+	recordResultUsage(result)
+	if result.SessionID != "" {
+		os.Remove(filepath.Join(getProjectsDir(), hashProjectPath(dir), result.SessionID+".jsonl"))
+	}
+	if result.IsError {
+		return "", "", withCode(http.StatusBadGateway, ErrCLIFailed, fmt.Errorf("summarizer failed: %s", result.Result))
+	}
+	return result.Result, cfg.SummaryModel, nil
+}
+
+// runAPISummarizer is runSummarizer for the api chat backend
+func runAPISummarizer(ctx context.Context, cfg AnthropicAPIConfig, prompt string) (string, string, error) {
+	key := os.Getenv("ANTHROPIC_API_KEY")
+	if key == "" {
+		return "", "", withCode(http.StatusServiceUnavailable, ErrUnavailable, fmt.Errorf("the api chat backend needs ANTHROPIC_API_KEY"))
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"model":      cfg.SummaryModel,
+		"max_tokens": 256,
+		"messages":   []apiMessage{{Role: "user", Content: []apiBlock{{Type: "text", Text: prompt}}}},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(cfg.BaseURL, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", key)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API request failed: %w", err))
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", "", withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("Anthropic API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(data))))
+	}
+	var message struct {
+		Content []apiBlock `json:"content"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return "", "", withCode(http.StatusBadGateway, ErrUpstream, fmt.Errorf("invalid Anthropic API response: %w", err))
+	}
+	var text strings.Builder
+	for _, block := range message.Content {
+		if block.Type == "text" {
+			text.WriteString(block.Text)
+		}
+	}
+	return text.String(), cfg.SummaryModel, nil
+}
+
+// SummarizeSession handles POST /api/session/:id/summarize
+// Generates a one-line summary with a cheap model and caches it until the session grows
+func SummarizeSession(c *gin.Context) {
+	sessionID := c.Param("id")
+	if !userCanAccessSession(currentUser(c), sessionID) {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+	var req SummarizeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	path := findSessionFile(sessionID)
+	if path == "" {
+		respondError(c, http.StatusNotFound, ErrSessionNotFound, "Session not found")
+		return
+	}
+
+	input, count, err := summaryInput(path)
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to read session file", err.Error())
+		return
+	}
+	if cached := getSessionSummary(sessionID); cached != nil && !req.Force && cached.MessageCount == count {
+		c.JSON(http.StatusOK, SummarizeResponse{SessionSummary: *cached, SessionID: sessionID, Cached: true})
+		return
+	}
+	if input == "" {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Session has no text to summarize")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), summaryTimeout)
+	defer cancel()
+	text, model, err := runSummarizer(ctx, summaryPrompt+input)
+	if err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	text = strings.Trim(strings.Join(strings.Fields(text), " "), `"`)
+	if text == "" {
+		respondError(c, http.StatusBadGateway, ErrUpstream, "Summarizer returned an empty summary")
+		return
+	}
+
+	summary := SessionSummary{Summary: text, Model: model, MessageCount: count, GeneratedAt: time.Now()}
+	storeSessionSummary(sessionID, summary)
+
+	c.JSON(http.StatusOK, SummarizeResponse{SessionSummary: summary, SessionID: sessionID})
+}
@@ -0,0 +1,387 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// templateVarRegex matches {{name}} placeholders (whitespace inside the braces is allowed)
+var templateVarRegex = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*\}\}`)
+
+var templateVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// PromptTemplate is a reusable prompt with {{variables}}
+type PromptTemplate struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Body        string             `json:"body"`
+	Variables   []TemplateVariable `json:"variables"`
+	WorkDir     string             `json:"workDir,omitempty"` // default working directory for runs
+	Shared      bool               `json:"shared"`            // visible to every user
+	Owner       string             `json:"owner,omitempty"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+}
+
+// TemplateVariable describes a placeholder in a template body
+type TemplateVariable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     string `json:"default,omitempty"`
+	Required    bool   `json:"required"`
+}
+
+// TemplateRequest is the body of POST/PUT /api/templates
+// On update, nil fields are left unchanged
+type TemplateRequest struct {
+	Name        *string             `json:"name"`
+	Description *string             `json:"description"`
+	Body        *string             `json:"body"`
+	Variables   *[]TemplateVariable `json:"variables"`
+	WorkDir     *string             `json:"workDir"`
+	Shared      *bool               `json:"shared"`
+}
+
+// TemplateRenderRequest is the body of POST /api/templates/:id/render
+type TemplateRenderRequest struct {
+	Values map[string]string `json:"values"`
+}
+
+// TemplateRenderResponse is the rendered prompt
+type TemplateRenderResponse struct {
+	Prompt string `json:"prompt"`
+}
+
+// TemplateRunRequest is the body of POST /api/templates/:id/run
+// The remaining fields are passed to the chat as in POST /api/chat
+type TemplateRunRequest struct {
+	Values     map[string]string `json:"values"`
+	SessionID  string            `json:"sessionId"`
+	WorkDir    string            `json:"workDir"`
+	PlanMode   bool              `json:"planMode"`
+	MCPServers []string          `json:"mcpServers,omitempty"`
+	Backend    string            `json:"backend,omitempty"`
+}
+
+var (
+	templatesMu     sync.Mutex
+	templates       []PromptTemplate
+	templatesLoaded bool
+)
+
+func templatesPath() string {
+	return serverDataPath("templates.json")
+}
+
+// loadTemplates reads templates once; caller must hold templatesMu
+func loadTemplates() error {
+	if templatesLoaded {
+		return nil
+	}
+	if err := loadJSONFile(templatesPath(), &templates); err != nil {
+		return err
+	}
+	templatesLoaded = true
+	return nil
+}
+
+func saveTemplates() error {
+	return writeJSONFileAtomic(templatesPath(), templates)
+}
+
+// syncTemplateVariables adds placeholders used in the body but not declared
+// Undeclared placeholders are required
+func syncTemplateVariables(t *PromptTemplate) {
+	declared := make(map[string]bool, len(t.Variables))
+	for _, v := range t.Variables {
+		declared[v.Name] = true
+	}
+	for _, m := range templateVarRegex.FindAllStringSubmatch(t.Body, -1) {
+		if !declared[m[1]] {
+			declared[m[1]] = true
+			t.Variables = append(t.Variables, TemplateVariable{Name: m[1], Required: true})
+		}
+	}
+	if t.Variables == nil {
+		t.Variables = []TemplateVariable{}
+	}
+}
+
+func validateTemplate(t PromptTemplate, user *User) error {
+	if strings.TrimSpace(t.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if strings.TrimSpace(t.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	seen := make(map[string]bool)
+	for _, v := range t.Variables {
+		if !templateVarName.MatchString(v.Name) {
+			return fmt.Errorf("invalid variable name: %q", v.Name)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variable: %s", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	if t.WorkDir != "" && !userCanAccessPath(user, t.WorkDir) {
+		return fmt.Errorf("working directory is outside your projects: %s", t.WorkDir)
+	}
+	return nil
+}
+
+func applyTemplateRequest(t *PromptTemplate, req TemplateRequest) {
+	if req.Name != nil {
+		t.Name = *req.Name
+	}
+	if req.Description != nil {
+		t.Description = *req.Description
+	}
+	if req.Body != nil {
+		t.Body = *req.Body
+	}
+	if req.Variables != nil {
+		t.Variables = *req.Variables
+	}
+	if req.WorkDir != nil {
+		t.WorkDir = *req.WorkDir
+	}
+	if req.Shared != nil {
+		t.Shared = *req.Shared
+	}
+}
+
+// render substitutes values (falling back to defaults) into the body
+// It fails if a required variable has no value
+func (t PromptTemplate) render(values map[string]string) (string, error) {
+	resolved := make(map[string]string, len(t.Variables))
+	var missing []string
+	for _, v := range t.Variables {
+		value, ok := values[v.Name]
+		if !ok || value == "" {
+			value = v.Default
+		}
+		if value == "" && v.Required {
+			missing = append(missing, v.Name)
+		}
+		resolved[v.Name] = value
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("missing values for: %s", strings.Join(missing, ", "))
+	}
+	return templateVarRegex.ReplaceAllStringFunc(t.Body, func(m string) string {
+		return resolved[templateVarRegex.FindStringSubmatch(m)[1]]
+	}), nil
+}
+
+// findTemplate returns the index of id; caller must hold templatesMu
+func findTemplate(id string) int {
+	for i, t := range templates {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// visibleTemplate looks up a template the user may read (own or shared); caller must hold templatesMu
+// With write set, shared templates of other users are refused
+// It writes the error response and returns -1 on failure
+func visibleTemplate(c *gin.Context, write bool) int {
+	if err := loadTemplates(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load templates", err.Error())
+		return -1
+	}
+	user := currentUser(c)
+	i := findTemplate(c.Param("id"))
+	if i < 0 || (!templates[i].Shared && !userCanAccessOwner(user, templates[i].Owner)) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Template not found")
+		return -1
+	}
+	if write && !userCanAccessOwner(user, templates[i].Owner) {
+		respondError(c, http.StatusForbidden, ErrForbidden, "Only the owner can modify this template")
+		return -1
+	}
+	return i
+}
+
+// ListTemplates handles GET /api/templates
+func ListTemplates(c *gin.Context) {
+	user := currentUser(c)
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if err := loadTemplates(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load templates", err.Error())
+		return
+	}
+	list := make([]PromptTemplate, 0, len(templates))
+	for _, t := range templates {
+		if t.Shared || userCanAccessOwner(user, t.Owner) {
+			list = append(list, t)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": list})
+}
+
+// CreateTemplate handles POST /api/templates
+func CreateTemplate(c *gin.Context) {
+	user := currentUser(c)
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	now := time.Now()
+	t := PromptTemplate{ID: generateID(), Owner: ownerID(user), CreatedAt: now, UpdatedAt: now}
+	applyTemplateRequest(&t, req)
+	syncTemplateVariables(&t)
+	if err := validateTemplate(t, user); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	if err := loadTemplates(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load templates", err.Error())
+		return
+	}
+	templates = append(templates, t)
+	if err := saveTemplates(); err != nil {
+		templates = templates[:len(templates)-1]
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save templates", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// GetTemplate handles GET /api/templates/:id
+func GetTemplate(c *gin.Context) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	i := visibleTemplate(c, false)
+	if i < 0 {
+		return
+	}
+	c.JSON(http.StatusOK, templates[i])
+}
+
+// UpdateTemplate handles PUT /api/templates/:id
+func UpdateTemplate(c *gin.Context) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	i := visibleTemplate(c, true)
+	if i < 0 {
+		return
+	}
+	t := templates[i]
+	t.Variables = append([]TemplateVariable(nil), t.Variables...)
+	applyTemplateRequest(&t, req)
+	syncTemplateVariables(&t)
+	if err := validateTemplate(t, currentUser(c)); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	t.UpdatedAt = time.Now()
+	previous := templates[i]
+	templates[i] = t
+	if err := saveTemplates(); err != nil {
+		templates[i] = previous
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save templates", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, t)
+}
+
+// DeleteTemplate handles DELETE /api/templates/:id
+func DeleteTemplate(c *gin.Context) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	i := visibleTemplate(c, true)
+	if i < 0 {
+		return
+	}
+	previous := templates
+	templates = append(append([]PromptTemplate(nil), previous[:i]...), previous[i+1:]...)
+	if err := saveTemplates(); err != nil {
+		templates = previous
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save templates", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// lookupTemplate returns a copy of the template the user may read, writing the error response on failure
+func lookupTemplate(c *gin.Context) (PromptTemplate, bool) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+	i := visibleTemplate(c, false)
+	if i < 0 {
+		return PromptTemplate{}, false
+	}
+	return templates[i], true
+}
+
+// RenderTemplate handles POST /api/templates/:id/render
+func RenderTemplate(c *gin.Context) {
+	var req TemplateRenderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	t, ok := lookupTemplate(c)
+	if !ok {
+		return
+	}
+	prompt, err := t.render(req.Values)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, TemplateRenderResponse{Prompt: prompt})
+}
+
+// RunTemplate handles POST /api/templates/:id/run
+// It renders the template and streams a chat exactly like POST /api/chat
+func RunTemplate(c *gin.Context) {
+	var req TemplateRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	t, ok := lookupTemplate(c)
+	if !ok {
+		return
+	}
+	prompt, err := t.render(req.Values)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	workDir := req.WorkDir
+	if workDir == "" && req.SessionID == "" {
+		workDir = t.WorkDir
+	}
+	executeChatStream(c, ChatRequest{
+		Prompt:     prompt,
+		SessionID:  req.SessionID,
+		WorkDir:    workDir,
+		PlanMode:   req.PlanMode,
+		MCPServers: req.MCPServers,
+		Backend:    req.Backend,
+	}, false)
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
-	"io"
+	"fmt"
 	"log"
-	"net/http"
 	"os"
 	"os/exec"
-	"strings"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"github.com/creack/pty"
@@ -17,138 +20,344 @@ import (
 	"github.com/gorilla/websocket"
 )
 
-// checkTerminalOrigin validates WebSocket origin for terminal connections
-func checkTerminalOrigin(r *http.Request) bool {
-	origin := r.Header.Get("Origin")
-	// Allow requests with no Origin (same-origin or non-browser clients)
-	if origin == "" {
-		return true
-	}
-
-	// Allow localhost variants
-	allowedPrefixes := []string{
-		"http://localhost",
-		"https://localhost",
-		"http://127.0.0.1",
-		"https://127.0.0.1",
-		"http://[::1]",
-		"https://[::1]",
-	}
-	for _, prefix := range allowedPrefixes {
-		if strings.HasPrefix(origin, prefix) {
-			return true
-		}
-	}
-
-	// Allow Tailscale IPs (100.x.x.x range)
-	if strings.Contains(origin, "://100.") {
-		return true
-	}
-
-	log.Printf("[Terminal WS] Rejected connection from origin: %s", origin)
-	return false
-}
-
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin:     checkTerminalOrigin,
+	CheckOrigin:     checkOrigin,
+	Subprotocols:    []string{wsProtocol},
 }
 
-// ResizeMessage represents a terminal resize message
+// Terminal frame opcodes: with ?protocol=framed every message is binary, its first byte one of these
+const (
+	termFrameData    byte = 0x00 // input or output bytes
+	termFrameResize  byte = 0x01 // cols and rows, big-endian uint16 each
+	termFramePing    byte = 0x02 // answered with a pong carrying the same payload
+	termFramePaste   byte = 0x03 // pasted text, bracketed when the program enabled bracketed paste
+	termFramePong    byte = 0x04
+	termFrameError   byte = 0x05 // UTF-8 message; the connection closes after it
+	termFrameExit    byte = 0x06 // the process exited; its exit code as decimal text
+	termFrameWarning byte = 0x07 // UTF-8 notice, e.g. that the terminal is about to be closed for idling
+)
+
+var (
+	bracketedPasteOn  = []byte("\x1b[?2004h")
+	bracketedPasteOff = []byte("\x1b[?2004l")
+	pasteStart        = []byte("\x1b[200~")
+	pasteEnd          = []byte("\x1b[201~")
+)
+
+// ResizeMessage represents a terminal resize message (unframed protocol)
 type ResizeMessage struct {
 	Type string `json:"type"`
 	Cols uint16 `json:"cols"`
 	Rows uint16 `json:"rows"`
 }
 
+// sessionIDRegex matches Claude session IDs (UUIDs)
+var sessionIDRegex = regexp.MustCompile(`^[0-9a-fA-F-]{8,64}$`)
+
+// buildTerminalCommand creates the command for a terminal session
+// Query parameters:
+//   - mode: "shell" (default) runs bash, "claude" runs the interactive claude TUI
+//   - sessionId: session to resume (claude mode only)
+//   - workDir: working directory (defaults to the session's workDir or $HOME)
+func buildTerminalCommand(c *gin.Context) (*exec.Cmd, string, error) {
+	mode := c.DefaultQuery("mode", "shell")
+	sessionID := c.Query("sessionId")
+	workDir := c.Query("workDir")
+	user := currentUser(c)
+
+	if sessionID != "" && !userCanAccessSession(user, sessionID) {
+		return nil, "", fmt.Errorf("session not found: %s", sessionID)
+	}
+	// A raw shell escapes project restrictions, so restricted users only get claude
+	if mode == "shell" && user != nil && !user.IsAdmin() && len(user.ProjectRoots) > 0 {
+		return nil, "", fmt.Errorf("shell terminals are not available for your account")
+	}
+
+	if workDir == "" && sessionID != "" {
+		workDir = GetSessionWorkDir(sessionID)
+	}
+	if workDir == "" && user != nil && len(user.ProjectRoots) > 0 {
+		workDir = user.ProjectRoots[0]
+	}
+	if workDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		workDir = homeDir
+	}
+	if info, err := os.Stat(workDir); err != nil || !info.IsDir() {
+		return nil, "", fmt.Errorf("working directory does not exist: %s", workDir)
+	}
+	if !userCanAccessPath(user, workDir) {
+		return nil, "", fmt.Errorf("working directory is outside your projects: %s", workDir)
+	}
+
+	var cmd *exec.Cmd
+	switch mode {
+	case "shell":
+		cmd = exec.Command("bash")
+	case "claude":
+		args := []string{}
+		if sessionID != "" {
+			if !sessionIDRegex.MatchString(sessionID) {
+				return nil, "", fmt.Errorf("invalid session ID: %s", sessionID)
+			}
+			args = append(args, "--resume", sessionID)
+		}
+		cmd = exec.Command("claude", args...)
+	default:
+		return nil, "", fmt.Errorf("unknown terminal mode: %s", mode)
+	}
+
+	cmd.Dir = workDir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	return cmd, mode, nil
+}
+
+// terminalConn writes to a terminal WebSocket from the output and input goroutines
+type terminalConn struct {
+	conn   *websocket.Conn
+	framed bool
+	mu     sync.Mutex
+}
+
+// write sends a frame; unframed, data goes out as a binary message and errors and warnings as text
+func (t *terminalConn) write(op byte, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.framed {
+		return t.conn.WriteMessage(websocket.BinaryMessage, append([]byte{op}, payload...))
+	}
+	switch op {
+	case termFrameData:
+		return t.conn.WriteMessage(websocket.BinaryMessage, payload)
+	case termFrameError, termFrameWarning:
+		return t.conn.WriteMessage(websocket.TextMessage, payload)
+	}
+	return nil
+}
+
 // TerminalHandler handles WebSocket terminal connections
+// Query parameters:
+//   - protocol: "framed" prefixes every message in both directions with an opcode byte
+//     (data, resize, ping, paste), so input is never mistaken for a control message;
+//     without it, text messages that parse as a resize message are resizes
+//   - terminalId: an ID picked by the client; the terminal then keeps running for a while after the
+//     connection closes, and connecting again with the same ID reattaches to it instead of starting another
 func TerminalHandler(c *gin.Context) {
+	// A new terminal past the user's limit is refused before the upgrade, so the client gets the error code
+	terminalID := c.Query("terminalId")
+	if t := findTerminal(currentUser(c), terminalID); t == nil || !t.info().Running {
+		if err := checkTerminalLimit(ownerID(currentUser(c))); err != nil {
+			respondCheckError(c, err)
+			return
+		}
+	}
+
 	// Upgrade HTTP connection to WebSocket
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade to WebSocket: %v", err)
 		return
 	}
-	defer conn.Close()
+	defer ws.Close()
+	atomic.AddInt64(&metrics.terminalConnections, 1)
+	defer atomic.AddInt64(&metrics.terminalConnections, -1)
+	conn := &terminalConn{conn: ws, framed: c.Query("protocol") == "framed"}
 
-	// Create bash shell command
-	cmd := exec.Command("bash")
-	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	if terminalID != "" && !terminalIDRegex.MatchString(terminalID) {
+		conn.write(termFrameError, []byte("Invalid terminalId"))
+		return
+	}
+	if terminalID != "" {
+		if t := findTerminal(currentUser(c), terminalID); t != nil && t.attach(conn) {
+			log.Printf("[Terminal] Reattached terminal %s (pid %d)", terminalID, t.cmd.Process.Pid)
+			t.readInput(conn)
+			t.detach(conn)
+			return
+		}
+	}
 
-	// Start the command with a PTY
-	ptmx, err := pty.Start(cmd)
+	t, err := startTerminal(c, conn, terminalID)
 	if err != nil {
-		log.Printf("Failed to start PTY: %v", err)
-		conn.WriteMessage(websocket.TextMessage, []byte("Failed to start terminal"))
+		log.Printf("[Terminal] %v", err)
+		conn.write(termFrameError, []byte(err.Error()))
 		return
 	}
+	t.readInput(conn)
+	t.detach(conn)
+}
+
+// startTerminal starts the requested command in a PTY attached to conn, registered under terminalID if set
+func startTerminal(c *gin.Context, conn *terminalConn, terminalID string) (*managedTerminal, error) {
+	cmd, mode, err := buildTerminalCommand(c)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkResources(cmd.Dir); err != nil {
+		return nil, err
+	}
+	user := currentUser(c)
+	owner := ownerID(user)
+	if err := reserveTerminal(owner); err != nil {
+		return nil, err
+	}
+	started := false
 	defer func() {
-		ptmx.Close()
-		cmd.Process.Kill()
-		cmd.Wait()
+		if !started {
+			releaseTerminal(owner)
+		}
 	}()
 
-	// Use a WaitGroup to ensure proper cleanup
-	var wg sync.WaitGroup
-	wg.Add(2)
-
-	// Copy PTY output to WebSocket
-	go func() {
-		defer wg.Done()
-		buf := make([]byte, 8192)
-		for {
-			n, err := ptmx.Read(buf)
-			if err != nil {
-				if err != io.EOF {
-					log.Printf("PTY read error: %v", err)
-				}
-				return
-			}
-			if n > 0 {
-				if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
-					log.Printf("WebSocket write error: %v", err)
-					return
-				}
+	// A claude session can't be driven from the chat and the TUI at the same time
+	sessionID := c.Query("sessionId")
+	if mode == "claude" && sessionID != "" && IsSessionLoading(sessionID) {
+		return nil, fmt.Errorf("This session is already processing a request")
+	}
+
+	// Start the command with a PTY
+	ptmx, err := pty.Start(cmd)
+	if err != nil {
+		log.Printf("Failed to start PTY: %v", err)
+		return nil, fmt.Errorf("Failed to start terminal")
+	}
+	limitProcessResources(cmd)
+	started = true
+
+	openedAt := time.Now()
+	t := &managedTerminal{
+		id:        terminalID,
+		owner:     owner,
+		mode:      mode,
+		sessionID: sessionID,
+		startedAt: openedAt,
+		cmd:       cmd,
+		ptmx:      ptmx,
+		conn:      conn,
+		done:      make(chan struct{}),
+	}
+	t.activity.Store(openedAt.UnixNano())
+	cleanup := []func(){func() { releaseTerminal(owner) }}
+	cleanup = append(cleanup, watchTerminalLimits(t, cmd, openedAt, &t.activity))
+	entry := newAuditEntry(c, "terminal.open")
+	entry.SessionID = sessionID
+	entry.WorkDir = cmd.Dir
+	entry.Details = map[string]string{"mode": mode}
+	recordAudit(entry)
+	cleanup = append(cleanup, func() {
+		entry.Action = "terminal.close"
+		entry.Time = time.Now()
+		entry.Details = map[string]string{"mode": mode, "duration": time.Since(openedAt).Round(time.Second).String()}
+		recordAudit(entry)
+	})
+
+	// Register claude TUI processes with the chat subsystem so they show up
+	// in the processes list, block concurrent chats, and can be interrupted
+	if mode == "claude" {
+		processID := getNextProcessID()
+		registerProcess(processID, &ProcessInfo{
+			Interrupt: func() error { return killProcessGroup(cmd) },
+			SessionID: sessionID,
+			WorkDir:   cmd.Dir,
+			StartTime: time.Now().Unix(),
+			Mode:      "terminal",
+			Owner:     t.owner,
+			pid:       cmd.Process.Pid,
+		})
+		if sessionID != "" {
+			SetSessionLoading(sessionID, true)
+			SetSessionProcessID(sessionID, &processID)
+		}
+		log.Printf("[Terminal] Started claude TUI (process %d, session %s, workDir %s)", processID, sessionID, cmd.Dir)
+		cleanup = append(cleanup, func() {
+			unregisterProcess(processID)
+			if sessionID != "" {
+				SetSessionLoading(sessionID, false)
+				SetSessionProcessID(sessionID, nil)
 			}
+		})
+	}
+	t.onExit = func() {
+		for _, fn := range cleanup {
+			fn()
 		}
-	}()
+	}
 
-	// Copy WebSocket input to PTY
-	go func() {
-		defer wg.Done()
-		for {
-			msgType, msg, err := conn.ReadMessage()
-			if err != nil {
-				if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
-					log.Printf("WebSocket read error: %v", err)
-				}
-				return
+	if terminalID != "" {
+		// An exited terminal with this ID only kept its scrollback; the new one replaces it
+		terminalsMu.Lock()
+		terminals[terminalKey(t.owner, terminalID)] = t
+		terminalsMu.Unlock()
+	}
+	go t.pumpOutput()
+	return t, nil
+}
+
+// readInput copies WebSocket input from conn to the PTY until the connection closes
+func (t *managedTerminal) readInput(conn *terminalConn) {
+	for {
+		msgType, msg, err := conn.conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket read error: %v", err)
 			}
+			return
+		}
 
-			// Handle resize messages (JSON)
-			if msgType == websocket.TextMessage {
-				var resizeMsg ResizeMessage
-				if err := json.Unmarshal(msg, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
-					if resizeMsg.Cols > 0 && resizeMsg.Rows > 0 {
-						if err := resizePty(ptmx, resizeMsg.Cols, resizeMsg.Rows); err != nil {
+		if conn.framed {
+			if len(msg) == 0 {
+				continue
+			}
+			op, payload := msg[0], msg[1:]
+			switch op {
+			case termFrameData:
+				msg = payload
+			case termFramePaste:
+				// A paste can't end the bracket early and run the rest as typed input
+				msg = bytes.ReplaceAll(payload, pasteEnd, nil)
+				if t.bracketedPaste.Load() {
+					msg = append(append(append([]byte{}, pasteStart...), msg...), pasteEnd...)
+				}
+			case termFrameResize:
+				if len(payload) == 4 {
+					cols, rows := binary.BigEndian.Uint16(payload), binary.BigEndian.Uint16(payload[2:])
+					if cols > 0 && rows > 0 {
+						if err := resizePty(t.ptmx, cols, rows); err != nil {
 							log.Printf("Failed to resize PTY: %v", err)
 						}
 					}
-					continue
 				}
+				continue
+			case termFramePing:
+				conn.write(termFramePong, payload)
+				continue
+			default:
+				continue
 			}
-
-			// Write regular terminal input to PTY
-			if _, err := ptmx.Write(msg); err != nil {
-				log.Printf("PTY write error: %v", err)
-				return
+		} else if msgType == websocket.TextMessage {
+			// Handle resize messages (JSON)
+			var resizeMsg ResizeMessage
+			if err := json.Unmarshal(msg, &resizeMsg); err == nil && resizeMsg.Type == "resize" {
+				if resizeMsg.Cols > 0 && resizeMsg.Rows > 0 {
+					if err := resizePty(t.ptmx, resizeMsg.Cols, resizeMsg.Rows); err != nil {
+						log.Printf("Failed to resize PTY: %v", err)
+					}
+				}
+				continue
 			}
 		}
-	}()
 
-	// Wait for both goroutines to finish
-	wg.Wait()
+		// Write regular terminal input to PTY
+		t.activity.Store(time.Now().UnixNano())
+		if _, err := t.ptmx.Write(msg); err != nil {
+			log.Printf("PTY write error: %v", err)
+			return
+		}
+	}
 }
 
 // resizePty resizes the PTY to the specified dimensions
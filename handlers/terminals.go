@@ -0,0 +1,390 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// terminalScrollbackBytes bounds the output kept per terminal for GET /api/terminals/:id/scrollback
+	terminalScrollbackBytes = 256 * 1024
+	// terminalDetachTimeout is how long a terminal with an ID keeps running without a connection
+	terminalDetachTimeout = 10 * time.Minute
+)
+
+// terminalIDRegex matches the terminalId a client picks to reattach to its terminal later
+var terminalIDRegex = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// terminalWriter sends a terminal frame to whatever is connected
+type terminalWriter interface {
+	write(op byte, payload []byte) error
+}
+
+// scrollbackBuffer is a ring buffer of a terminal's most recent output
+type scrollbackBuffer struct {
+	data    []byte
+	start   int // index of the oldest byte once the buffer is full
+	dropped bool
+}
+
+// Write appends output, overwriting the oldest bytes past terminalScrollbackBytes
+func (b *scrollbackBuffer) Write(p []byte) {
+	if len(p) >= terminalScrollbackBytes {
+		b.dropped = b.dropped || len(b.data) > 0 || len(p) > terminalScrollbackBytes
+		b.data = append(b.data[:0], p[len(p)-terminalScrollbackBytes:]...)
+		b.start = 0
+		return
+	}
+	if room := terminalScrollbackBytes - len(b.data); room > 0 {
+		n := len(p)
+		if n > room {
+			n = room
+		}
+		b.data = append(b.data, p[:n]...)
+		p = p[n:]
+	}
+	for len(p) > 0 {
+		n := copy(b.data[b.start:], p)
+		b.start = (b.start + n) % len(b.data)
+		p = p[n:]
+		b.dropped = true
+	}
+}
+
+// Bytes returns the buffered output, oldest first
+func (b *scrollbackBuffer) Bytes() []byte {
+	out := make([]byte, 0, len(b.data))
+	return append(append(out, b.data[b.start:]...), b.data[:b.start]...)
+}
+
+// managedTerminal is a PTY and its process, which outlive their WebSocket when the client gave a terminalId
+type managedTerminal struct {
+	id        string // "" for a terminal that ends with its connection
+	owner     string
+	mode      string
+	sessionID string
+	startedAt time.Time
+	cmd       *exec.Cmd
+	ptmx      *os.File
+
+	// activity is the last input or output in Unix nanoseconds, for limits.processes.terminalIdleMinutes
+	activity atomic.Int64
+	// bracketedPaste is whether the program asked for bracketed paste, as last seen in its output
+	bracketedPaste atomic.Bool
+	done           chan struct{} // closed once the process has exited and been cleaned up
+	onExit         func()        // releases what the terminal held, before done is closed
+
+	mu          sync.Mutex
+	conn        *terminalConn // nil while detached
+	detachedAt  time.Time
+	detachTimer *time.Timer
+	exited      bool
+	scrollback  scrollbackBuffer
+}
+
+// TerminalInfo is a running terminal in GET /api/terminals
+type TerminalInfo struct {
+	ID         string `json:"id"`
+	Mode       string `json:"mode"`
+	WorkDir    string `json:"workDir"`
+	SessionID  string `json:"sessionId,omitempty"`
+	StartedAt  string `json:"startedAt"`
+	Attached   bool   `json:"attached"`
+	Running    bool   `json:"running"`
+	DetachedAt string `json:"detachedAt,omitempty"`
+}
+
+// TerminalScrollback is the response of GET /api/terminals/:id/scrollback
+type TerminalScrollback struct {
+	ID        string `json:"id"`
+	Output    string `json:"output"` // raw output, escape sequences included, to write to the terminal before live output
+	Lines     int    `json:"lines"`
+	Truncated bool   `json:"truncated"` // older output was dropped, by ?lines= or the buffer's size
+	Running   bool   `json:"running"`
+	Attached  bool   `json:"attached"`
+}
+
+// Terminals with an ID, keyed by owner and ID so clients of different users can't collide
+var (
+	terminalsMu sync.Mutex
+	terminals   = make(map[string]*managedTerminal)
+	// terminalCounts is each owner's running terminals, with or without an ID
+	terminalCounts = make(map[string]int)
+)
+
+// terminalKey is the registry key of a user's terminal
+func terminalKey(owner, id string) string {
+	return owner + "\x00" + id
+}
+
+// checkTerminalLimitLocked returns an error if owner can't start another terminal; caller must hold terminalsMu
+func checkTerminalLimitLocked(owner string) error {
+	limit := getServerConfig().Limits.Processes.MaxTerminalsPerUser
+	if n := terminalCounts[owner]; limit > 0 && n >= limit {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("you already have %d terminals running (limit %d)", n, limit))
+	}
+	return nil
+}
+
+// checkTerminalLimit reports whether owner may start another terminal, before the WebSocket is upgraded
+func checkTerminalLimit(owner string) error {
+	terminalsMu.Lock()
+	defer terminalsMu.Unlock()
+	return checkTerminalLimitLocked(owner)
+}
+
+// reserveTerminal counts a terminal about to start against limits.processes.maxTerminalsPerUser
+func reserveTerminal(owner string) error {
+	terminalsMu.Lock()
+	defer terminalsMu.Unlock()
+	if err := checkTerminalLimitLocked(owner); err != nil {
+		return err
+	}
+	terminalCounts[owner]++
+	return nil
+}
+
+// releaseTerminal gives back the slot of a terminal whose process has exited, or that failed to start
+func releaseTerminal(owner string) {
+	terminalsMu.Lock()
+	defer terminalsMu.Unlock()
+	if terminalCounts[owner]--; terminalCounts[owner] <= 0 {
+		delete(terminalCounts, owner)
+	}
+}
+
+// findTerminal returns the user's terminal with id, nil when there is none
+func findTerminal(user *User, id string) *managedTerminal {
+	terminalsMu.Lock()
+	defer terminalsMu.Unlock()
+	return terminals[terminalKey(ownerID(user), id)]
+}
+
+// write sends a frame to the attached connection; output while detached only goes to the scrollback
+func (t *managedTerminal) write(op byte, payload []byte) error {
+	t.mu.Lock()
+	conn := t.conn
+	t.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return conn.write(op, payload)
+}
+
+// info describes the terminal for GET /api/terminals
+func (t *managedTerminal) info() TerminalInfo {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	info := TerminalInfo{
+		ID:        t.id,
+		Mode:      t.mode,
+		WorkDir:   t.cmd.Dir,
+		SessionID: t.sessionID,
+		StartedAt: t.startedAt.UTC().Format(time.RFC3339),
+		Attached:  t.conn != nil,
+		Running:   !t.exited,
+	}
+	if t.conn == nil && !t.detachedAt.IsZero() {
+		info.DetachedAt = t.detachedAt.UTC().Format(time.RFC3339)
+	}
+	return info
+}
+
+// attach makes conn the terminal's connection, closing the one it replaces
+// false when the process has exited, so a new terminal should take the ID
+func (t *managedTerminal) attach(conn *terminalConn) bool {
+	t.mu.Lock()
+	if t.exited {
+		t.mu.Unlock()
+		return false
+	}
+	old := t.conn
+	t.conn = conn
+	if t.detachTimer != nil {
+		t.detachTimer.Stop()
+		t.detachTimer = nil
+	}
+	t.mu.Unlock()
+	if old != nil {
+		old.write(termFrameError, []byte("Terminal was attached from another connection"))
+		old.close()
+	}
+	return true
+}
+
+// detach is called when conn closes; a terminal without an ID is killed, one with an ID keeps running
+// until terminalDetachTimeout passes without a new connection
+func (t *managedTerminal) detach(conn *terminalConn) {
+	if t.id == "" {
+		t.kill()
+		<-t.done
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != conn || t.exited {
+		return // already taken over by another connection, or nothing left to keep
+	}
+	t.conn = nil
+	t.detachedAt = time.Now()
+	t.detachTimer = time.AfterFunc(terminalDetachTimeout, func() {
+		t.mu.Lock()
+		attached := t.conn != nil
+		t.mu.Unlock()
+		if attached {
+			return
+		}
+		t.unregister()
+		t.kill()
+	})
+}
+
+// unregister removes the terminal from the registry unless its ID went to a newer terminal
+func (t *managedTerminal) unregister() {
+	terminalsMu.Lock()
+	defer terminalsMu.Unlock()
+	key := terminalKey(t.owner, t.id)
+	if terminals[key] == t {
+		delete(terminals, key)
+	}
+}
+
+// kill ends the process; pumpOutput then cleans up
+func (t *managedTerminal) kill() {
+	t.ptmx.Close()
+	t.cmd.Process.Kill()
+}
+
+// pumpOutput copies PTY output to the scrollback and the attached connection until the process exits
+func (t *managedTerminal) pumpOutput() {
+	buf := make([]byte, 8192)
+	for {
+		n, err := t.ptmx.Read(buf)
+		if n > 0 {
+			t.activity.Store(time.Now().UnixNano())
+			if on, off := bytes.LastIndex(buf[:n], bracketedPasteOn), bytes.LastIndex(buf[:n], bracketedPasteOff); on != off {
+				t.bracketedPaste.Store(on > off)
+			}
+			t.mu.Lock()
+			t.scrollback.Write(buf[:n])
+			conn := t.conn
+			t.mu.Unlock()
+			if conn != nil {
+				if err := conn.write(termFrameData, buf[:n]); err != nil {
+					log.Printf("WebSocket write error: %v", err)
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// The PTY closes when the process exits; framed clients learn its exit code
+	t.ptmx.Close()
+	t.cmd.Wait()
+	t.mu.Lock()
+	t.exited = true
+	conn := t.conn
+	t.mu.Unlock()
+	if conn != nil {
+		if conn.framed && t.cmd.ProcessState != nil {
+			conn.write(termFrameExit, []byte(strconv.Itoa(t.cmd.ProcessState.ExitCode())))
+		}
+		conn.close()
+		// Nobody is left to ask for the scrollback; a detached terminal keeps it until its timeout
+		t.unregister()
+	}
+	if t.onExit != nil {
+		t.onExit()
+	}
+	close(t.done)
+}
+
+// close ends the connection with a close frame, which also ends its readInput
+func (t *terminalConn) close() {
+	t.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	t.conn.Close()
+}
+
+// ListTerminals handles GET /api/terminals
+// Lists the user's terminals opened with a terminalId, which can be reattached with GET /api/terminal?terminalId=
+func ListTerminals(c *gin.Context) {
+	owner := ownerID(currentUser(c))
+	terminalsMu.Lock()
+	var owned []*managedTerminal
+	for _, t := range terminals {
+		if t.owner == owner {
+			owned = append(owned, t)
+		}
+	}
+	terminalsMu.Unlock()
+	list := make([]TerminalInfo, 0, len(owned))
+	for _, t := range owned {
+		list = append(list, t.info())
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].StartedAt < list[j].StartedAt })
+	c.JSON(http.StatusOK, gin.H{"terminals": list})
+}
+
+// GetTerminalScrollback handles GET /api/terminals/:id/scrollback
+// Returns a terminal's buffered output, so a reattaching client can restore it before live output resumes
+// Query parameters:
+//   - lines: only the last this many lines (default: everything buffered, up to 256KB)
+func GetTerminalScrollback(c *gin.Context) {
+	lines := 0
+	if v := c.Query("lines"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid lines parameter")
+			return
+		}
+		lines = n
+	}
+	t := findTerminal(currentUser(c), c.Param("id"))
+	if t == nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Terminal not found")
+		return
+	}
+
+	t.mu.Lock()
+	output := t.scrollback.Bytes()
+	resp := TerminalScrollback{ID: t.id, Truncated: t.scrollback.dropped, Running: !t.exited, Attached: t.conn != nil}
+	t.mu.Unlock()
+	// Once bytes were dropped the first line is partial, and may start inside an escape sequence or character
+	if resp.Truncated {
+		if i := bytes.IndexByte(output, '\n'); i >= 0 {
+			output = output[i+1:]
+		}
+	}
+	if lines > 0 {
+		end := len(bytes.TrimRight(output, "\r\n"))
+		for i, count := end-1, 0; i >= 0; i-- {
+			if output[i] == '\n' {
+				if count++; count == lines {
+					output, resp.Truncated = output[i+1:], true
+					break
+				}
+			}
+		}
+	}
+	resp.Output = string(output)
+	resp.Lines = bytes.Count(output, []byte("\n"))
+	if len(output) > 0 && output[len(output)-1] != '\n' {
+		resp.Lines++
+	}
+	c.JSON(http.StatusOK, resp)
+}
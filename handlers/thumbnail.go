@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // registers the GIF decoder for image.Decode
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	thumbnailMinWidth = 16
+	thumbnailMaxWidth = 1024
+
+	// Directory under the upload directory holding generated thumbnails
+	thumbnailDir = "thumbs"
+)
+
+// thumbnailMu serializes thumbnail generation so concurrent requests don't encode the same file twice
+var thumbnailMu sync.Mutex
+
+// thumbnailPath is where the width-w thumbnail of an upload is kept
+func thumbnailPath(filePath string, width int, ext string) string {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	return filepath.Join(filepath.Dir(filePath), thumbnailDir, fmt.Sprintf("%s-w%d%s", name, width, ext))
+}
+
+// uploadThumbnail returns a copy of an image upload scaled down to width, creating it on first use
+// Returns an empty path when the original should be served as is: an image no wider than width,
+// or a type the standard library can't decode (webp)
+func uploadThumbnail(filePath string, contentType string, width int) (string, string, error) {
+	ext, thumbType := ".jpg", "image/jpeg"
+	switch contentType {
+	case "image/jpeg":
+	case "image/png", "image/gif":
+		// Keep transparency
+		ext, thumbType = ".png", "image/png"
+	default:
+		return "", "", nil
+	}
+
+	thumbPath := thumbnailPath(filePath, width, ext)
+	thumbnailMu.Lock()
+	defer thumbnailMu.Unlock()
+	if _, err := os.Stat(thumbPath); err == nil {
+		return thumbPath, thumbType, nil
+	}
+
+	src, err := os.Open(filePath)
+	if err != nil {
+		return "", "", err
+	}
+	defer src.Close()
+	img, _, err := image.Decode(src)
+	if err != nil {
+		return "", "", fmt.Errorf("decode %s: %w", filepath.Base(filePath), err)
+	}
+	if img.Bounds().Dx() <= width {
+		return "", "", nil
+	}
+	scaled := scaleImage(img, width)
+
+	if err := os.MkdirAll(filepath.Dir(thumbPath), 0755); err != nil {
+		return "", "", err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(thumbPath), ".thumb-*")
+	if err != nil {
+		return "", "", err
+	}
+	if thumbType == "image/png" {
+		err = png.Encode(tmp, scaled)
+	} else {
+		err = jpeg.Encode(tmp, scaled, &jpeg.Options{Quality: 85})
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), thumbPath)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return "", "", err
+	}
+	return thumbPath, thumbType, nil
+}
+
+// scaleImage shrinks img to width, averaging the (premultiplied) source pixels behind each output pixel
+func scaleImage(img image.Image, width int) *image.RGBA {
+	b := img.Bounds()
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.RGBA64Model.Convert(img.At(sx, sy)).(color.RGBA64)
+					r += uint64(c.R)
+					g += uint64(c.G)
+					bl += uint64(c.B)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+			out.SetRGBA(x, y, color.RGBA{R: uint8(r / n >> 8), G: uint8(g / n >> 8), B: uint8(bl / n >> 8), A: uint8(a / n >> 8)})
+		}
+	}
+	return out
+}
+
+// removeThumbnails deletes the thumbnails generated for an upload
+func removeThumbnails(filePath string) {
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	matches, _ := filepath.Glob(filepath.Join(filepath.Dir(filePath), thumbnailDir, name+"-w*"))
+	for _, m := range matches {
+		os.Remove(m)
+	}
+}
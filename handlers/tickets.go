@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ticketWebhookMaxSize bounds an inbound tracker webhook
+	ticketWebhookMaxSize = 1 << 20
+	// linearDefaultAPIURL is Linear's GraphQL endpoint
+	linearDefaultAPIURL = "https://api.linear.app/graphql"
+)
+
+// LinearConfig enables POST /api/integrations/linear/webhook
+type LinearConfig struct {
+	// APIKey posts run results as comments; defaults to GREYZONE_LINEAR_API_KEY
+	APIKey string `yaml:"apiKey" json:"-"`
+	// WebhookSecret is the webhook's signing secret, checked against Linear-Signature; defaults to GREYZONE_LINEAR_WEBHOOK_SECRET
+	WebhookSecret string       `yaml:"webhookSecret" json:"-"`
+	APIURL        string       `yaml:"apiURL" json:"apiURL"` // https://api.linear.app/graphql
+	Rules         []TicketRule `yaml:"rules" json:"rules"`
+}
+
+// JiraConfig enables POST /api/integrations/jira/webhook
+type JiraConfig struct {
+	BaseURL string `yaml:"baseURL" json:"baseURL"` // https://your-site.atlassian.net
+	// Email and APIToken post run results as comments; the token defaults to GREYZONE_JIRA_API_TOKEN
+	Email    string `yaml:"email" json:"email"`
+	APIToken string `yaml:"apiToken" json:"-"`
+	// WebhookSecret is checked against X-Hub-Signature, or the secret query parameter for webhooks
+	// that can't sign; defaults to GREYZONE_JIRA_WEBHOOK_SECRET
+	WebhookSecret string       `yaml:"webhookSecret" json:"-"`
+	Rules         []TicketRule `yaml:"rules" json:"rules"`
+}
+
+// TicketRule starts a run of a schedule when a ticket gets a label or moves to a status
+type TicketRule struct {
+	Project string `yaml:"project" json:"project,omitempty"` // Linear team key or Jira project key; any when empty
+	Label   string `yaml:"label" json:"label,omitempty"`     // run when this label is added
+	Status  string `yaml:"status" json:"status,omitempty"`   // run when the ticket moves to this status
+	// Schedule is the ID of the schedule whose workDir, owner, and tool policy the run uses,
+	// whether or not it's enabled
+	Schedule string `yaml:"schedule" json:"schedule"`
+	// Prompt replaces the schedule's prompt, with {{id}}, {{title}}, {{description}}, {{url}}, {{status}},
+	// {{labels}}, and {{project}} filled in from the ticket; when empty the ticket follows the schedule's prompt
+	Prompt string `yaml:"prompt" json:"prompt,omitempty"`
+}
+
+// TicketRef is the ticket that triggered a schedule run
+type TicketRef struct {
+	Tracker string `json:"tracker"` // linear or jira
+	ID      string `json:"id"`      // the tracker's ID for API calls
+	Key     string `json:"key"`     // e.g. ENG-123
+	URL     string `json:"url,omitempty"`
+}
+
+// TicketWebhookResponse is the response of the tracker webhooks: the runs the event started
+type TicketWebhookResponse struct {
+	Runs []ScheduleRun `json:"runs"`
+}
+
+// ticketEvent is a tracker webhook reduced to what rules match on
+type ticketEvent struct {
+	TicketRef
+	Title       string
+	Description string
+	Project     string
+	Status      string
+	Labels      []string
+	AddedLabels []string
+	NewStatus   string // set when the event moved the ticket to Status
+}
+
+// validateTicketRules checks integrations.<tracker>.rules
+func validateTicketRules(tracker string, rules []TicketRule) error {
+	for i, rule := range rules {
+		if rule.Schedule == "" {
+			return fmt.Errorf("integrations.%s.rules[%d]: schedule is required", tracker, i)
+		}
+		if rule.Label == "" && rule.Status == "" {
+			return fmt.Errorf("integrations.%s.rules[%d]: set label or status", tracker, i)
+		}
+	}
+	return nil
+}
+
+// validateTicketConfig checks integrations.linear and integrations.jira
+func validateTicketConfig(cfg IntegrationsConfig) error {
+	if cfg.Linear.APIURL != "" {
+		if u, err := url.Parse(cfg.Linear.APIURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("integrations.linear.apiURL must be an http(s) URL")
+		}
+	}
+	if err := validateTicketRules("linear", cfg.Linear.Rules); err != nil {
+		return err
+	}
+	if len(cfg.Jira.Rules) > 0 {
+		if u, err := url.Parse(cfg.Jira.BaseURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("integrations.jira.baseURL must be an http(s) URL")
+		}
+	}
+	return validateTicketRules("jira", cfg.Jira.Rules)
+}
+
+// matches reports whether the event fires the rule
+func (r TicketRule) matches(ev ticketEvent) bool {
+	if r.Project != "" && !strings.EqualFold(r.Project, ev.Project) {
+		return false
+	}
+	if r.Status != "" && ev.NewStatus != "" && strings.EqualFold(r.Status, ev.NewStatus) {
+		return true
+	}
+	if r.Label != "" {
+		for _, label := range ev.AddedLabels {
+			if strings.EqualFold(r.Label, label) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// prompt renders the rule's prompt for the event on top of the schedule's
+func (r TicketRule) prompt(ev ticketEvent, schedulePrompt string) string {
+	if r.Prompt == "" {
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "%s\n\n%s %s: %s\n", schedulePrompt, ev.Tracker, ev.Key, ev.Title)
+		if ev.URL != "" {
+			fmt.Fprintf(&prompt, "%s\n", ev.URL)
+		}
+		if desc := strings.TrimSpace(ev.Description); desc != "" {
+			fmt.Fprintf(&prompt, "\n%s\n", desc)
+		}
+		return prompt.String()
+	}
+	values := map[string]string{
+		"id":          ev.Key,
+		"title":       ev.Title,
+		"description": ev.Description,
+		"url":         ev.URL,
+		"status":      ev.Status,
+		"labels":      strings.Join(ev.Labels, ", "),
+		"project":     ev.Project,
+	}
+	return templateVarRegex.ReplaceAllStringFunc(r.Prompt, func(m string) string {
+		if v, ok := values[templateVarRegex.FindStringSubmatch(m)[1]]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// startTicketRuns starts a schedule run for every rule the event fires
+func startTicketRuns(rules []TicketRule, ev ticketEvent) []ScheduleRun {
+	runs := []ScheduleRun{}
+	for _, rule := range rules {
+		if !rule.matches(ev) {
+			continue
+		}
+		schedulesMu.Lock()
+		err := loadSchedules()
+		i := -1
+		if err == nil {
+			i = findSchedule(rule.Schedule)
+		}
+		var s Schedule
+		if i >= 0 {
+			s = schedules.Schedules[i]
+		}
+		schedulesMu.Unlock()
+		if err != nil || i < 0 {
+			log.Printf("[Tickets] %s %s matched a rule for schedule %s, which can't be found: %v", ev.Tracker, ev.Key, rule.Schedule, err)
+			continue
+		}
+		s.Prompt = rule.prompt(ev, s.Prompt)
+		ticket := ev.TicketRef
+		log.Printf("[Tickets] %s %s starts %q (%s)", ev.Tracker, ev.Key, s.Name, s.ID)
+		runs = append(runs, startScheduleRun(s, ev.Tracker, &ticket))
+	}
+	return runs
+}
+
+// readTicketWebhook reads a webhook body, writing an error response when it's too large
+func readTicketWebhook(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, ticketWebhookMaxSize+1))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return nil, false
+	}
+	if len(body) > ticketWebhookMaxSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("webhooks are limited to %d MB", ticketWebhookMaxSize>>20))
+		return nil, false
+	}
+	return body, true
+}
+
+// validSignature reports whether signature is the hex HMAC-SHA256 of body, with an optional sha256= prefix
+func validSignature(secret string, body []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(signature, "sha256=")), []byte(expected)) == 1
+}
+
+// LinearWebhook handles POST /api/integrations/linear/webhook
+// Issue events signed with integrations.linear.webhookSecret start the schedule runs of the
+// rules they match: a label added on create or update, or a state change
+func LinearWebhook(c *gin.Context) {
+	cfg := getServerConfig().Integrations.Linear
+	if cfg.WebhookSecret == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Linear webhooks are not configured")
+		return
+	}
+	body, ok := readTicketWebhook(c)
+	if !ok {
+		return
+	}
+	if !validSignature(cfg.WebhookSecret, body, c.GetHeader("Linear-Signature")) {
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid webhook signature")
+		return
+	}
+	var payload struct {
+		Action string `json:"action"`
+		Type   string `json:"type"`
+		URL    string `json:"url"`
+		Data   struct {
+			ID          string `json:"id"`
+			Identifier  string `json:"identifier"`
+			Title       string `json:"title"`
+			Description string `json:"description"`
+			URL         string `json:"url"`
+			Team        struct {
+				Key string `json:"key"`
+			} `json:"team"`
+			State struct {
+				Name string `json:"name"`
+			} `json:"state"`
+			Labels []struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"labels"`
+		} `json:"data"`
+		UpdatedFrom struct {
+			StateID  *string   `json:"stateId"`
+			LabelIDs *[]string `json:"labelIds"`
+		} `json:"updatedFrom"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid webhook: %v", err))
+		return
+	}
+	if payload.Type != "Issue" || (payload.Action != "create" && payload.Action != "update") {
+		c.JSON(http.StatusOK, TicketWebhookResponse{Runs: []ScheduleRun{}})
+		return
+	}
+
+	data := payload.Data
+	ev := ticketEvent{
+		TicketRef:   TicketRef{Tracker: "linear", ID: data.ID, Key: data.Identifier, URL: data.URL},
+		Title:       data.Title,
+		Description: data.Description,
+		Project:     data.Team.Key,
+		Status:      data.State.Name,
+	}
+	if ev.URL == "" {
+		ev.URL = payload.URL
+	}
+	previous := map[string]bool{}
+	if payload.UpdatedFrom.LabelIDs != nil {
+		for _, id := range *payload.UpdatedFrom.LabelIDs {
+			previous[id] = true
+		}
+	}
+	for _, label := range data.Labels {
+		ev.Labels = append(ev.Labels, label.Name)
+		if payload.Action == "create" || (payload.UpdatedFrom.LabelIDs != nil && !previous[label.ID]) {
+			ev.AddedLabels = append(ev.AddedLabels, label.Name)
+		}
+	}
+	if payload.Action == "update" && payload.UpdatedFrom.StateID != nil {
+		ev.NewStatus = ev.Status
+	}
+	c.JSON(http.StatusOK, TicketWebhookResponse{Runs: startTicketRuns(cfg.Rules, ev)})
+}
+
+// JiraWebhook handles POST /api/integrations/jira/webhook
+// Issue created and updated events start the schedule runs of the rules they match: a label
+// added, or a status change
+// Query parameters:
+//   - secret: integrations.jira.webhookSecret, for webhooks that don't send X-Hub-Signature
+func JiraWebhook(c *gin.Context) {
+	cfg := getServerConfig().Integrations.Jira
+	if cfg.WebhookSecret == "" {
+		respondError(c, http.StatusServiceUnavailable, ErrUnavailable, "Jira webhooks are not configured")
+		return
+	}
+	body, ok := readTicketWebhook(c)
+	if !ok {
+		return
+	}
+	if signature := c.GetHeader("X-Hub-Signature"); signature != "" {
+		ok = validSignature(cfg.WebhookSecret, body, signature)
+	} else {
+		ok = subtle.ConstantTimeCompare([]byte(c.Query("secret")), []byte(cfg.WebhookSecret)) == 1
+	}
+	if !ok {
+		respondError(c, http.StatusUnauthorized, ErrUnauthorized, "Invalid webhook signature")
+		return
+	}
+	var payload struct {
+		WebhookEvent string `json:"webhookEvent"`
+		Issue        struct {
+			ID     string `json:"id"`
+			Key    string `json:"key"`
+			Fields struct {
+				Summary     string          `json:"summary"`
+				Description json.RawMessage `json:"description"`
+				Labels      []string        `json:"labels"`
+				Project     struct {
+					Key string `json:"key"`
+				} `json:"project"`
+				Status struct {
+					Name string `json:"name"`
+				} `json:"status"`
+			} `json:"fields"`
+		} `json:"issue"`
+		Changelog struct {
+			Items []struct {
+				Field      string `json:"field"`
+				FromString string `json:"fromString"`
+				ToString   string `json:"toString"`
+			} `json:"items"`
+		} `json:"changelog"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("invalid webhook: %v", err))
+		return
+	}
+	created := payload.WebhookEvent == "jira:issue_created"
+	if !created && payload.WebhookEvent != "jira:issue_updated" {
+		c.JSON(http.StatusOK, TicketWebhookResponse{Runs: []ScheduleRun{}})
+		return
+	}
+
+	issue := payload.Issue
+	ev := ticketEvent{
+		TicketRef: TicketRef{Tracker: "jira", ID: issue.Key, Key: issue.Key},
+		Title:     issue.Fields.Summary,
+		Project:   issue.Fields.Project.Key,
+		Status:    issue.Fields.Status.Name,
+		Labels:    issue.Fields.Labels,
+	}
+	if cfg.BaseURL != "" {
+		ev.URL = strings.TrimSuffix(cfg.BaseURL, "/") + "/browse/" + issue.Key
+	}
+	// Webhooks send the description as wiki text; anything else is left out
+	json.Unmarshal(issue.Fields.Description, &ev.Description)
+	if created {
+		ev.AddedLabels = issue.Fields.Labels
+	}
+	for _, item := range payload.Changelog.Items {
+		switch item.Field {
+		case "status":
+			ev.NewStatus = item.ToString
+		case "labels":
+			previous := map[string]bool{}
+			for _, label := range strings.Fields(item.FromString) {
+				previous[label] = true
+			}
+			for _, label := range strings.Fields(item.ToString) {
+				if !previous[label] {
+					ev.AddedLabels = append(ev.AddedLabels, label)
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, TicketWebhookResponse{Runs: startTicketRuns(cfg.Rules, ev)})
+}
+
+// ticketComment is the comment a finished or skipped run leaves on its ticket
+func ticketComment(s Schedule, run ScheduleRun) string {
+	switch run.Status {
+	case "success":
+		text := fmt.Sprintf("Claude finished %q", s.Name)
+		if run.SessionID != "" && run.CostUSD > 0 {
+			text += fmt.Sprintf(" (session %s, $%.2f)", run.SessionID, run.CostUSD)
+		} else if run.SessionID != "" {
+			text += fmt.Sprintf(" (session %s)", run.SessionID)
+		}
+		if run.Summary != "" {
+			text += ":\n\n" + run.Summary
+		}
+		return text
+	case "skipped":
+		return fmt.Sprintf("Claude skipped %q: %s", s.Name, run.Error)
+	default:
+		return fmt.Sprintf("Claude's run of %q ended with %s: %s", s.Name, run.Status, run.Error)
+	}
+}
+
+// reportTicketRun writes a run's outcome back to its ticket as a comment
+// Trackers without API credentials are left alone
+func reportTicketRun(s Schedule, run ScheduleRun) {
+	if run.Ticket == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var err error
+	switch run.Ticket.Tracker {
+	case "linear":
+		err = commentOnLinear(ctx, run.Ticket.ID, ticketComment(s, run))
+	case "jira":
+		err = commentOnJira(ctx, run.Ticket.ID, ticketComment(s, run))
+	}
+	if err != nil {
+		log.Printf("[Tickets] Failed to comment on %s %s: %v", run.Ticket.Tracker, run.Ticket.Key, err)
+	}
+}
+
+// ticketPost POSTs a JSON body and fails on any status but 2xx
+func ticketPost(ctx context.Context, endpoint string, header http.Header, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claude-greyzone/"+Version)
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// commentOnLinear adds a comment to a Linear issue through the GraphQL API
+func commentOnLinear(ctx context.Context, issueID string, text string) error {
+	cfg := getServerConfig().Integrations.Linear
+	if cfg.APIKey == "" {
+		return nil
+	}
+	endpoint := cfg.APIURL
+	if endpoint == "" {
+		endpoint = linearDefaultAPIURL
+	}
+	body := map[string]interface{}{
+		"query": "mutation($input: CommentCreateInput!) { commentCreate(input: $input) { success } }",
+		"variables": map[string]interface{}{
+			"input": map[string]string{"issueId": issueID, "body": text},
+		},
+	}
+	var out struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := ticketPost(ctx, endpoint, http.Header{"Authorization": {cfg.APIKey}}, body, &out); err != nil {
+		return err
+	}
+	if len(out.Errors) > 0 {
+		return fmt.Errorf("%s", out.Errors[0].Message)
+	}
+	return nil
+}
+
+// commentOnJira adds a comment to a Jira issue through the REST API
+func commentOnJira(ctx context.Context, key string, text string) error {
+	cfg := getServerConfig().Integrations.Jira
+	if cfg.APIToken == "" || cfg.BaseURL == "" {
+		return nil
+	}
+	auth := "Basic " + base64.StdEncoding.EncodeToString([]byte(cfg.Email+":"+cfg.APIToken))
+	endpoint := strings.TrimSuffix(cfg.BaseURL, "/") + "/rest/api/2/issue/" + url.PathEscape(key) + "/comment"
+	return ticketPost(ctx, endpoint, http.Header{"Authorization": {auth}}, map[string]string{"body": text}, nil)
+}
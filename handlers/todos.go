@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Todo is one item of a TodoWrite list
+type Todo struct {
+	Content    string `json:"content"`
+	Status     string `json:"status"` // pending, in_progress, or completed
+	ActiveForm string `json:"activeForm,omitempty"`
+}
+
+// SessionTodos is the latest todo list written in a session
+type SessionTodos struct {
+	SessionID  string    `json:"sessionId"`
+	WorkDir    string    `json:"workDir,omitempty"`
+	Todos      []Todo    `json:"todos"`
+	Total      int       `json:"total"`
+	Completed  int       `json:"completed"`
+	InProgress int       `json:"inProgress"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+var (
+	todosMu     sync.Mutex
+	todoStore   map[string]*SessionTodos
+	todosLoaded bool
+)
+
+func todosPath() string {
+	return serverDataPath("todos.json")
+}
+
+// loadTodos reads the task store once; caller must hold todosMu
+func loadTodos() error {
+	if todosLoaded {
+		return nil
+	}
+	if err := loadJSONFile(todosPath(), &todoStore); err != nil {
+		return err
+	}
+	if todoStore == nil {
+		todoStore = make(map[string]*SessionTodos)
+	}
+	todosLoaded = true
+	return nil
+}
+
+// countTodos fills in the progress counters
+func (s *SessionTodos) countTodos() {
+	s.Total, s.Completed, s.InProgress = len(s.Todos), 0, 0
+	for _, t := range s.Todos {
+		switch t.Status {
+		case "completed":
+			s.Completed++
+		case "in_progress":
+			s.InProgress++
+		}
+	}
+}
+
+// extractTodoWrite returns the todo list from a TodoWrite tool call in an assistant stream event
+// Each call replaces the whole list, so only the last one in the event matters
+func extractTodoWrite(line string) (string, []Todo, bool) {
+	if !strings.Contains(line, `"TodoWrite"`) {
+		return "", nil, false
+	}
+	var event struct {
+		Type      string `json:"type"`
+		SessionID string `json:"session_id"`
+		Message   struct {
+			Content []struct {
+				Type  string `json:"type"`
+				Name  string `json:"name"`
+				Input struct {
+					Todos []Todo `json:"todos"`
+				} `json:"input"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal([]byte(line), &event); err != nil || event.Type != "assistant" {
+		return "", nil, false
+	}
+	var todos []Todo
+	found := false
+	for _, block := range event.Message.Content {
+		if block.Type == "tool_use" && block.Name == "TodoWrite" {
+			todos, found = block.Input.Todos, true
+		}
+	}
+	if todos == nil {
+		todos = []Todo{}
+	}
+	return event.SessionID, todos, found
+}
+
+// trackTodos records a TodoWrite call from a claude output line and pushes it to clients
+// sessionID is used when the event doesn't carry one
+func trackTodos(sessionID string, workDir string, line string) {
+	eventSessionID, todos, ok := extractTodoWrite(line)
+	if !ok {
+		return
+	}
+	if eventSessionID != "" {
+		sessionID = eventSessionID
+	}
+	if sessionID == "" {
+		return
+	}
+
+	todosMu.Lock()
+	if err := loadTodos(); err != nil {
+		todosMu.Unlock()
+		log.Printf("[Todos] Failed to load task store: %v", err)
+		return
+	}
+	if prev, ok := todoStore[sessionID]; ok && reflect.DeepEqual(prev.Todos, todos) {
+		todosMu.Unlock()
+		return
+	}
+	entry := &SessionTodos{SessionID: sessionID, WorkDir: workDir, Todos: todos, UpdatedAt: time.Now()}
+	entry.countTodos()
+	todoStore[sessionID] = entry
+	if err := writeJSONFileAtomic(todosPath(), todoStore); err != nil {
+		log.Printf("[Todos] Failed to save task store: %v", err)
+	}
+	update := *entry
+	todosMu.Unlock()
+
+	broadcastSessionEvent(sessionID, "todos", map[string]interface{}{"todos": update})
+}
+
+// forgetTodos drops a deleted session's todo list
+func forgetTodos(sessionID string) {
+	todosMu.Lock()
+	defer todosMu.Unlock()
+	if err := loadTodos(); err != nil {
+		return
+	}
+	if _, ok := todoStore[sessionID]; !ok {
+		return
+	}
+	delete(todoStore, sessionID)
+	if err := writeJSONFileAtomic(todosPath(), todoStore); err != nil {
+		log.Printf("[Todos] Failed to save task store: %v", err)
+	}
+}
+
+// GetTodos handles GET /api/todos
+// Query parameters:
+//   - sessionId: only this session
+//   - project: only sessions run in this working directory
+//   - status: only items with this status (pending, in_progress, completed); counters still cover the full list
+func GetTodos(c *gin.Context) {
+	user := currentUser(c)
+	sessionID := c.Query("sessionId")
+	project := c.Query("project")
+	if project != "" {
+		project = filepath.Clean(project)
+	}
+	status := c.Query("status")
+	switch status {
+	case "", "pending", "in_progress", "completed":
+	default:
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "status must be pending, in_progress, or completed")
+		return
+	}
+
+	todosMu.Lock()
+	if err := loadTodos(); err != nil {
+		todosMu.Unlock()
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load todos", err.Error())
+		return
+	}
+	result := make([]SessionTodos, 0)
+	for id, entry := range todoStore {
+		if sessionID != "" && id != sessionID {
+			continue
+		}
+		if project != "" && filepath.Clean(entry.WorkDir) != project {
+			continue
+		}
+		if !userCanAccessSession(user, id) {
+			continue
+		}
+		item := *entry
+		if status != "" {
+			item.Todos = make([]Todo, 0, len(entry.Todos))
+			for _, t := range entry.Todos {
+				if t.Status == status {
+					item.Todos = append(item.Todos, t)
+				}
+			}
+			if len(item.Todos) == 0 {
+				continue
+			}
+		}
+		result = append(result, item)
+	}
+	todosMu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool { return result[i].UpdatedAt.After(result[j].UpdatedAt) })
+	c.JSON(http.StatusOK, gin.H{"sessions": result})
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// transcriptStream produces what the claude CLI would for chat backends that aren't the claude CLI:
+// stream-json lines for the client, and user/assistant lines in ~/.claude/projects for sessions and history
+type transcriptStream struct {
+	out        io.Writer
+	workDir    string
+	sessionID  string
+	path       string                 // session .jsonl file
+	parentUUID string                 // uuid of the last recorded line
+	extra      map[string]interface{} // added to every recorded line
+}
+
+// emit writes one stream-json line, as claude --output-format stream-json would
+func (t *transcriptStream) emit(event map[string]interface{}) error {
+	event["session_id"] = t.sessionID
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = t.out.Write(append(data, '\n'))
+	return err
+}
+
+// record appends a user or assistant line to the session transcript, chained to the previous one
+func (t *transcriptStream) record(kind string, message interface{}) error {
+	return t.recordAt(kind, message, time.Now())
+}
+
+// recordAt is record with the line's timestamp given, for messages that happened earlier
+func (t *transcriptStream) recordAt(kind string, message interface{}, at time.Time) error {
+	line := map[string]interface{}{
+		"parentUuid":  nil,
+		"isSidechain": false,
+		"userType":    "external",
+		"cwd":         t.workDir,
+		"sessionId":   t.sessionID,
+		"type":        kind,
+		"message":     message,
+		"uuid":        newUUID(),
+		"timestamp":   at.UTC().Format(time.RFC3339Nano),
+	}
+	if t.parentUUID != "" {
+		line["parentUuid"] = t.parentUUID
+	}
+	for k, v := range t.extra {
+		line[k] = v
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	t.parentUUID = line["uuid"].(string)
+	return nil
+}
+
+// resolveTranscript picks the session a turn continues, like --resume and --continue do,
+// or allocates a new session ID and transcript path in the working directory's project
+func resolveTranscript(turn ChatTurn) (sessionID string, path string, err error) {
+	if turn.SessionID != "" {
+		if path = findSessionFile(turn.SessionID); path == "" {
+			return "", "", withCode(http.StatusNotFound, ErrSessionNotFound, fmt.Errorf("session not found: %s", turn.SessionID))
+		}
+		return turn.SessionID, path, nil
+	}
+	if turn.Continue {
+		if path = latestTranscript(turn.WorkDir); path != "" {
+			return strings.TrimSuffix(filepath.Base(path), ".jsonl"), path, nil
+		}
+	}
+	sessionID = newUUID()
+	return sessionID, filepath.Join(getProjectsDir(), hashProjectPath(turn.WorkDir), sessionID+".jsonl"), nil
+}
+
+// latestTranscript returns the most recently modified session file of workDir, or ""
+func latestTranscript(workDir string) string {
+	dir := filepath.Join(getProjectsDir(), hashProjectPath(workDir))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	latest := ""
+	var latestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if info.ModTime().After(latestTime) {
+			latest = filepath.Join(dir, entry.Name())
+			latestTime = info.ModTime()
+		}
+	}
+	return latest
+}
@@ -0,0 +1,460 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trashRetention is how long deleted files are kept before they are purged for good
+const trashRetention = 30 * 24 * time.Hour
+
+// TrashItem is a file or directory deleted with POST /api/file/delete, kept until restored or purged
+type TrashItem struct {
+	ID           string `json:"id"`
+	ProjectID    string `json:"projectId"` // directory name under ~/.claude/projects, or the parent directory's
+	ProjectPath  string `json:"projectPath"`
+	OriginalPath string `json:"originalPath"`
+	RelPath      string `json:"relPath"` // relative to the project
+	IsDir        bool   `json:"isDir"`
+	Size         int64  `json:"size"` // bytes of the files it holds
+	Files        int    `json:"files"`
+	DeletedAt    string `json:"deletedAt"`
+	DeletedBy    string `json:"deletedBy,omitempty"`
+	PurgeAt      string `json:"purgeAt"`
+}
+
+// DeleteFileRequest is the body of POST /api/file/delete
+type DeleteFileRequest struct {
+	Path   string `json:"path"`
+	LockID string `json:"lockId,omitempty"` // needed while the file is locked, see POST /api/file/lock
+}
+
+// RestoreTrashRequest is the body of POST /api/trash/:id/restore
+type RestoreTrashRequest struct {
+	// Overwrite moves whatever is at the original path now to the trash instead of refusing
+	Overwrite bool `json:"overwrite"`
+}
+
+// TrashResponse is the response of GET /api/trash
+type TrashResponse struct {
+	Items []TrashItem `json:"items"` // newest first
+	Size  int64       `json:"size"`
+}
+
+// Deleted files are moved under the data directory's trash/<id>/, indexed in trash.json
+var (
+	trashMu     sync.Mutex
+	trashStore  map[string]TrashItem // item ID -> item
+	trashLoaded bool
+)
+
+func trashIndexPath() string {
+	return serverDataPath("trash.json")
+}
+
+// trashItemPath is where an item's file or directory is kept
+func trashItemPath(item TrashItem) string {
+	return filepath.Join(serverDataPath("trash"), item.ID, filepath.Base(item.OriginalPath))
+}
+
+// loadTrash reads the trash index once and purges expired items; caller must hold trashMu
+func loadTrash() error {
+	if !trashLoaded {
+		if err := loadJSONFile(trashIndexPath(), &trashStore); err != nil {
+			return err
+		}
+		if trashStore == nil {
+			trashStore = make(map[string]TrashItem)
+		}
+		trashLoaded = true
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	expired := false
+	for id, item := range trashStore {
+		if item.PurgeAt < now {
+			purgeTrashItem(item)
+			delete(trashStore, id)
+			expired = true
+		}
+	}
+	if expired {
+		return saveTrash()
+	}
+	return nil
+}
+
+// saveTrash writes the trash index; caller must hold trashMu
+func saveTrash() error {
+	return writeJSONFileAtomicMode(trashIndexPath(), trashStore, 0600)
+}
+
+// purgeTrashItem deletes what an item keeps
+func purgeTrashItem(item TrashItem) {
+	if err := os.RemoveAll(filepath.Join(serverDataPath("trash"), item.ID)); err != nil {
+		log.Printf("[Trash] Failed to purge %s: %v", item.OriginalPath, err)
+	}
+}
+
+// moveTree renames src to dst, copying and then removing src when they are on different filesystems
+func moveTree(src, dst string, dirMode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(dst), dirMode); err != nil {
+		return err
+	}
+	err := os.Rename(src, dst)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyTree(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
+
+// copyTree copies a file or directory with its modes; symlinks are copied as links
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		case entry.Type()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case entry.Type().IsRegular():
+			return copySnapshotFile(path, target, info.Mode().Perm())
+		}
+		return nil // sockets, devices, and pipes aren't kept
+	})
+}
+
+// treeSize counts the regular files under path and their bytes
+func treeSize(path string) (files int, size int64) {
+	filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err == nil && entry.Type().IsRegular() {
+			if info, err := entry.Info(); err == nil {
+				files++
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return files, size
+}
+
+// checkDeletablePath refuses paths whose deletion would take a root or the server's own data with it
+func checkDeletablePath(user *User, path string) error {
+	if path == filepath.Dir(path) {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s can't be deleted", path))
+	}
+	protected := append([]string{}, getServerConfig().AllowedRoots...)
+	if user != nil {
+		protected = append(protected, user.ProjectRoots...)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		protected = append(protected, home)
+	}
+	for _, root := range protected {
+		if path == filepath.Clean(root) {
+			return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s can't be deleted", path))
+		}
+	}
+	data := filepath.Clean(getServerDataDir())
+	if path == data || strings.HasPrefix(path, data+string(filepath.Separator)) ||
+		strings.HasPrefix(data, path+string(filepath.Separator)) {
+		return withCode(http.StatusBadRequest, ErrInvalidRequest, fmt.Errorf("%s holds the server's data", path))
+	}
+	return nil
+}
+
+// resolveTrashPath resolves the symlinks in path's parent directories, keeping path itself unresolved
+// so a symlink is moved rather than its target; false when the real location is outside the user's roots
+func resolveTrashPath(user *User, path string) (string, bool) {
+	parent, err := resolveExistingPath(filepath.Dir(path))
+	if err != nil {
+		return "", false
+	}
+	resolved := filepath.Join(parent, filepath.Base(path))
+	return resolved, userCanAccessPath(user, resolved)
+}
+
+// trashPath moves a local file or directory to the trash; caller must hold trashMu
+func trashPath(user *User, path string) (TrashItem, error) {
+	now := time.Now().UTC()
+	item := TrashItem{
+		ID:           generateID(),
+		OriginalPath: path,
+		DeletedAt:    now.Format(time.RFC3339),
+		DeletedBy:    ownerID(user),
+		PurgeAt:      now.Add(trashRetention).Format(time.RFC3339),
+	}
+	projectID, projectPath, ok := projectForFile(path)
+	if !ok {
+		projectPath = filepath.Dir(path)
+		projectID = hashProjectPath(projectPath)
+	}
+	item.ProjectID, item.ProjectPath = projectID, projectPath
+	item.RelPath = path
+	if rel, err := filepath.Rel(projectPath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		item.RelPath = rel
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return item, err
+	}
+	item.IsDir = info.IsDir()
+	item.Files, item.Size = treeSize(path)
+
+	if err := moveTree(path, trashItemPath(item), 0700); err != nil {
+		os.RemoveAll(filepath.Join(serverDataPath("trash"), item.ID))
+		return item, err
+	}
+	trashStore[item.ID] = item
+	if err := saveTrash(); err != nil {
+		log.Printf("[Trash] Failed to save trash index: %v", err)
+	}
+	return item, nil
+}
+
+// trashItemFor returns an item the user deleted (any item for admins)
+// caller must hold trashMu
+func trashItemFor(user *User, id string) (TrashItem, bool) {
+	item, ok := trashStore[id]
+	if !ok || !userCanAccessOwner(user, item.DeletedBy) || !userCanAccessPath(user, item.OriginalPath) {
+		return TrashItem{}, false
+	}
+	return item, true
+}
+
+// DeleteFile handles POST /api/file/delete
+// Moves a local file or directory to the trash, where it is kept for 30 days unless restored or purged
+func DeleteFile(c *gin.Context) {
+	var req DeleteFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+	if req.Path == "" || !filepath.IsAbs(req.Path) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "An absolute path is required")
+		return
+	}
+	user := currentUser(c)
+	if !userCanAccessPath(user, req.Path) {
+		denyPath(c, req.Path)
+		return
+	}
+	if isRemotePath(req.Path) {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Files on remote hosts can't be deleted")
+		return
+	}
+	// A symlink is deleted itself, not what it points to, but a symlinked parent directory
+	// must not reach outside the user's roots
+	path, ok := resolveTrashPath(user, filepath.Clean(req.Path))
+	if !ok {
+		denyPath(c, req.Path)
+		return
+	}
+	if err := checkDeletablePath(user, filepath.Clean(req.Path)); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if err := checkDeletablePath(user, path); err != nil {
+		respondCheckError(c, err)
+		return
+	}
+	if _, err := os.Lstat(path); err != nil {
+		if os.IsNotExist(err) {
+			respondError(c, http.StatusNotFound, ErrNotFound, "File does not exist")
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrInternal, err.Error())
+		return
+	}
+	if lock := fileLockConflict(path, req.LockID); lock != nil {
+		fileLockedError(c, lock)
+		return
+	}
+
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	if err := loadTrash(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load trash", err.Error())
+		return
+	}
+	item, err := trashPath(user, path)
+	if err != nil {
+		if os.IsPermission(err) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+			return
+		}
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to move the file to the trash", err.Error())
+		return
+	}
+	releaseFileLock(path)
+	c.JSON(http.StatusOK, item)
+}
+
+// ListTrash handles GET /api/trash
+// Returns the files the user deleted that are still in the trash, newest first
+// Query parameters:
+//   - project: only files deleted from this directory
+func ListTrash(c *gin.Context) {
+	user := currentUser(c)
+	project := c.Query("project")
+	if project != "" {
+		if !userCanAccessPath(user, project) {
+			denyPath(c, project)
+			return
+		}
+		project = filepath.Clean(project)
+	}
+	trashMu.Lock()
+	err := loadTrash()
+	resp := TrashResponse{Items: []TrashItem{}}
+	for id := range trashStore {
+		item, ok := trashItemFor(user, id)
+		if !ok {
+			continue
+		}
+		if project != "" && !strings.HasPrefix(item.OriginalPath, project+string(filepath.Separator)) {
+			continue
+		}
+		resp.Items = append(resp.Items, item)
+		resp.Size += item.Size
+	}
+	trashMu.Unlock()
+	if err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load trash", err.Error())
+		return
+	}
+	sort.Slice(resp.Items, func(i, j int) bool { return resp.Items[i].DeletedAt > resp.Items[j].DeletedAt })
+	c.JSON(http.StatusOK, resp)
+}
+
+// RestoreTrash handles POST /api/trash/:id/restore
+// Moves a deleted file back to where it was, recreating missing parent directories
+func RestoreTrash(c *gin.Context) {
+	var req RestoreTrashRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+			return
+		}
+	}
+	user := currentUser(c)
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	if err := loadTrash(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load trash", err.Error())
+		return
+	}
+	item, ok := trashItemFor(user, c.Param("id"))
+	if !ok {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Trash item not found")
+		return
+	}
+	// The original parent may have been replaced by a symlink since
+	target, ok := resolveTrashPath(user, item.OriginalPath)
+	if !ok {
+		denyPath(c, item.OriginalPath)
+		return
+	}
+	if _, err := os.Lstat(target); err == nil {
+		if !req.Overwrite {
+			respondErrorDetails(c, http.StatusConflict, ErrConflict, "A file exists at the original path", item.OriginalPath)
+			return
+		}
+		if lock := fileLockConflict(target, ""); lock != nil {
+			fileLockedError(c, lock)
+			return
+		}
+		if _, err := trashPath(user, target); err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to move the current file to the trash", err.Error())
+			return
+		}
+	}
+	if err := moveTree(trashItemPath(item), target, 0755); err != nil {
+		if os.IsPermission(err) {
+			respondError(c, http.StatusForbidden, ErrPathForbidden, "Permission denied")
+			return
+		}
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to restore the file", err.Error())
+		return
+	}
+	purgeTrashItem(item)
+	delete(trashStore, item.ID)
+	if err := saveTrash(); err != nil {
+		log.Printf("[Trash] Failed to save trash index: %v", err)
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// PurgeTrash handles DELETE /api/trash/:id and DELETE /api/trash
+// Deletes one item for good, or every item the user deleted (optionally only from ?project=)
+func PurgeTrash(c *gin.Context) {
+	user := currentUser(c)
+	id := c.Param("id")
+	project := c.Query("project")
+	if project != "" {
+		project = filepath.Clean(project)
+	}
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	if err := loadTrash(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load trash", err.Error())
+		return
+	}
+	var purge []TrashItem
+	if id != "" {
+		item, ok := trashItemFor(user, id)
+		if !ok {
+			respondError(c, http.StatusNotFound, ErrNotFound, "Trash item not found")
+			return
+		}
+		purge = append(purge, item)
+	} else {
+		for id := range trashStore {
+			item, ok := trashItemFor(user, id)
+			if ok && (project == "" || strings.HasPrefix(item.OriginalPath, project+string(filepath.Separator))) {
+				purge = append(purge, item)
+			}
+		}
+	}
+	var size int64
+	for _, item := range purge {
+		purgeTrashItem(item)
+		delete(trashStore, item.ID)
+		size += item.Size
+	}
+	if err := saveTrash(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save trash index", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"purged": len(purge), "size": size})
+}
@@ -1,14 +1,20 @@
 package handlers
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -16,14 +22,22 @@ import (
 )
 
 const (
-	// Maximum upload size: 10MB
-	maxUploadSize = 10 * 1024 * 1024
 	// Temp directory for uploads
 	uploadTempDir = "uploads"
-	// Cleanup threshold: 1 hour
-	cleanupThreshold = 1 * time.Hour
+
+	// uploadMultipartOverhead is room in the body limit for the multipart framing around the file
+	uploadMultipartOverhead = 64 * 1024
+
+	// Suffix of the metadata file saved next to each upload
+	uploadMetaSuffix = ".meta"
 )
 
+// uploadMeta is what UploadFile records about an upload for serving it later
+type uploadMeta struct {
+	Type string `json:"type"` // MIME type detected from the content
+	Name string `json:"name"` // original filename
+}
+
 // UploadResponse represents the response for a successful file upload
 type UploadResponse struct {
 	FilePath string `json:"filePath"`
@@ -32,113 +46,160 @@ type UploadResponse struct {
 	FileSize int64  `json:"fileSize"`
 }
 
-// Supported image MIME types
-var supportedImageTypes = map[string]bool{
-	"image/jpeg": true,
-	"image/jpg":  true,
-	"image/png":  true,
-	"image/gif":  true,
-	"image/webp": true,
+// Extensions for the default image types (mime.TypeByExtension misses some on minimal systems)
+var imageExtTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
 }
 
-// Supported image extensions
-var supportedImageExts = map[string]bool{
-	".jpg":  true,
-	".jpeg": true,
-	".png":  true,
-	".gif":  true,
-	".webp": true,
+// maxUploadSize returns the configured upload size limit in bytes
+func maxUploadSize() int64 {
+	return int64(getServerConfig().Uploads.MaxSizeMB) * 1024 * 1024
 }
 
-// UploadFile handles image file uploads via multipart form data
-func UploadFile(c *gin.Context) {
-	// Parse multipart form with max memory
-	if err := c.Request.ParseMultipartForm(maxUploadSize); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File too large or invalid request"})
-		return
+// uploadTypeAllowed reports whether a MIME type is allowed by the upload policy
+func uploadTypeAllowed(mimeType string) bool {
+	mimeType = strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0])
+	if mimeType == "image/jpg" {
+		mimeType = "image/jpeg"
 	}
-
-	// Get the file from the form
-	file, header, err := c.Request.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No file provided"})
-		return
+	for _, allowed := range getServerConfig().Uploads.AllowedTypes {
+		if strings.EqualFold(allowed, mimeType) {
+			return true
+		}
 	}
-	defer file.Close()
+	return false
+}
 
-	// Validate file size
-	if header.Size > maxUploadSize {
-		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-			"error": fmt.Sprintf("File too large (max %dMB)", maxUploadSize/(1024*1024)),
-		})
-		return
+// uploadExtAllowed reports whether a file extension maps to an allowed type
+func uploadExtAllowed(ext string) bool {
+	if mimeType, ok := imageExtTypes[ext]; ok {
+		return uploadTypeAllowed(mimeType)
 	}
+	return uploadTypeAllowed(mime.TypeByExtension(ext))
+}
 
-	// Validate file type by extension
-	ext := strings.ToLower(filepath.Ext(header.Filename))
-	if !supportedImageExts[ext] {
-		c.JSON(http.StatusUnsupportedMediaType, gin.H{
-			"error": fmt.Sprintf("Unsupported file type. Supported: JPEG, PNG, GIF, WebP"),
-		})
-		return
+// userUploadDir returns the upload directory for a user
+// Each user gets a subdirectory so uploads are not shared between accounts
+func userUploadDir(u *User) string {
+	tempDir := filepath.Join(os.TempDir(), uploadTempDir)
+	if u == nil {
+		return tempDir
 	}
+	return filepath.Join(tempDir, u.ID)
+}
 
-	// Detect MIME type from file content
-	mimeType, err := detectMimeType(file)
+// userCanAttachFile reports whether a user may attach a file to a prompt
+func userCanAttachFile(u *User, path string) bool {
+	uploadDir := userUploadDir(u)
+	cleanPath := filepath.Clean(path)
+	return userCanAccessPath(u, path) || strings.HasPrefix(cleanPath, uploadDir+string(filepath.Separator))
+}
+
+// UploadFile handles image file uploads via multipart form data
+// The file part is streamed to disk with a hard cap at uploads.maxSizeMB instead of being buffered
+func UploadFile(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to detect file type"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Expected a multipart/form-data body")
 		return
 	}
+	limit := maxUploadSize()
+	tooLarge := func() {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrPayloadTooLarge, fmt.Sprintf("File too large (max %dMB)", getServerConfig().Uploads.MaxSizeMB))
+	}
+
+	// Skip to the file part; other fields are drained as they go by
+	var part *multipart.Part
+	for {
+		if part, err = reader.NextPart(); err != nil {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "No file provided")
+			return
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			break
+		}
+		part.Close()
+	}
+	defer part.Close()
+	filename := part.FileName()
 
-	// Validate MIME type
-	if !supportedImageTypes[mimeType] {
-		c.JSON(http.StatusUnsupportedMediaType, gin.H{
-			"error": fmt.Sprintf("Unsupported image type: %s", mimeType),
-		})
+	// Validate file type by extension
+	ext := strings.ToLower(filepath.Ext(filename))
+	if !uploadExtAllowed(ext) {
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, fmt.Sprintf("Unsupported file type. Supported: %s", strings.Join(getServerConfig().Uploads.AllowedTypes, ", ")))
 		return
 	}
 
-	// Reset file pointer after reading
-	if _, err := file.Seek(0, 0); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process file"})
+	// Detect MIME type from the first bytes before anything is written
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Failed to read upload")
+		return
+	}
+	head = head[:n]
+	mimeType := http.DetectContentType(head)
+	if !uploadTypeAllowed(mimeType) {
+		respondError(c, http.StatusUnsupportedMediaType, ErrUnsupportedMedia, fmt.Sprintf("Unsupported image type: %s", mimeType))
 		return
 	}
 
 	// Create temp directory if it doesn't exist
-	tempDir := filepath.Join(os.TempDir(), uploadTempDir)
+	tempDir := userUploadDir(currentUser(c))
 	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to create upload directory")
 		return
 	}
 
-	// Generate unique filename using hash and timestamp
-	uniqueFilename, err := generateUniqueFilename(file, ext)
+	// Write to a hidden file while hashing; it is renamed once the content hash is known
+	tmp, err := os.CreateTemp(tempDir, ".upload-*")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate filename"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to save file")
 		return
 	}
-
-	// Reset file pointer again after hashing
-	if _, err := file.Seek(0, 0); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process file"})
+	hasher := sha256.New()
+	// One byte past the limit is enough to know the file is too large
+	written, err := io.Copy(io.MultiWriter(tmp, hasher), io.LimitReader(io.MultiReader(bytes.NewReader(head), part), limit+1))
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || written > limit {
+		os.Remove(tmp.Name())
+		var maxBytes *http.MaxBytesError
+		switch {
+		case written > limit || errors.As(err, &maxBytes):
+			tooLarge()
+		case c.Request.Context().Err() != nil:
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Upload interrupted")
+		default:
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save file", err.Error())
+		}
 		return
 	}
 
-	// Create destination file
+	uniqueFilename := generateUniqueFilename(hasher.Sum(nil), ext)
 	destPath := filepath.Join(tempDir, uniqueFilename)
-	destFile, err := os.Create(destPath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	if err := os.Rename(tmp.Name(), destPath); err != nil {
+		os.Remove(tmp.Name())
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to save file")
 		return
 	}
-	defer destFile.Close()
 
-	// Copy file contents
-	written, err := io.Copy(destFile, file)
-	if err != nil {
-		os.Remove(destPath)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
+	entry := newAuditEntry(c, "upload.create")
+	entry.Target = uniqueFilename
+	entry.Details = map[string]string{"type": mimeType, "size": strconv.FormatInt(written, 10)}
+	recordAudit(entry)
+
+	meta, _ := json.Marshal(uploadMeta{Type: mimeType, Name: filename})
+	if err := os.WriteFile(destPath+uploadMetaSuffix, meta, 0644); err != nil {
+		log.Printf("[Upload] Failed to write metadata for %s: %v", uniqueFilename, err)
 	}
 
 	// Run cleanup of old files asynchronously
@@ -153,34 +214,11 @@ func UploadFile(c *gin.Context) {
 	})
 }
 
-// detectMimeType detects the MIME type from file content
-func detectMimeType(file multipart.File) (string, error) {
-	// Read first 512 bytes for MIME detection
-	buffer := make([]byte, 512)
-	n, err := file.Read(buffer)
-	if err != nil && err != io.EOF {
-		return "", err
-	}
-
-	// Detect content type
-	mimeType := http.DetectContentType(buffer[:n])
-	return mimeType, nil
-}
-
-// generateUniqueFilename creates a unique filename using hash and timestamp
-func generateUniqueFilename(file multipart.File, ext string) (string, error) {
-	// Create hash of file contents
-	hasher := sha256.New()
-	if _, err := io.Copy(hasher, file); err != nil {
-		return "", err
-	}
-	hash := hex.EncodeToString(hasher.Sum(nil))
-
+// generateUniqueFilename names an upload after its content hash and the time
+func generateUniqueFilename(sum []byte, ext string) string {
 	// Use first 16 characters of hash + timestamp
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%s_%d%s", hash[:16], timestamp, ext)
-
-	return filename, nil
+	hash := hex.EncodeToString(sum)
+	return fmt.Sprintf("%s_%d%s", hash[:16], time.Now().Unix(), ext)
 }
 
 // CleanupOldUploads removes temporary files older than the cleanup threshold
@@ -192,83 +230,137 @@ func CleanupOldUploads() {
 		return
 	}
 
-	// Read directory contents
-	entries, err := os.ReadDir(tempDir)
-	if err != nil {
-		return
-	}
-
 	// Current time for comparison
 	now := time.Now()
+	// Uploads attached to unsent drafts are kept, however old
+	drafts := draftAttachmentPaths()
 
-	// Iterate through files and remove old ones
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
+	// Walk files (including per-user subdirectories) and remove old ones
+	filepath.WalkDir(tempDir, func(filePath string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return nil
 		}
 
-		filePath := filepath.Join(tempDir, entry.Name())
 		fileInfo, err := entry.Info()
 		if err != nil {
-			continue
+			return nil
 		}
 
 		// Check if file is older than threshold
 		age := now.Sub(fileInfo.ModTime())
-		if age > cleanupThreshold {
+		if age > time.Duration(getServerConfig().Uploads.RetentionMinutes)*time.Minute && !drafts[strings.TrimSuffix(filePath, uploadMetaSuffix)] {
 			// Remove old file
 			os.Remove(filePath)
 		}
+		return nil
+	})
+}
+
+// uploadContentType returns the stored MIME type of an upload, falling back to its extension
+func uploadContentType(filePath string) string {
+	var meta uploadMeta
+	if data, err := os.ReadFile(filePath + uploadMetaSuffix); err == nil && json.Unmarshal(data, &meta) == nil && meta.Type != "" {
+		return meta.Type
+	}
+	ext := strings.ToLower(filepath.Ext(filePath))
+	if mimeType, ok := imageExtTypes[ext]; ok {
+		return mimeType
+	}
+	if mimeType := mime.TypeByExtension(ext); mimeType != "" {
+		return mimeType
 	}
+	return "application/octet-stream"
 }
 
 // GetUploadedFile serves an uploaded file
+// Upload names are content hashes, so responses are cacheable forever; Range requests are supported
+// Query parameters:
+//   - w: serve a JPEG/PNG/GIF image scaled down to this width (16-1024), generated once and kept with the upload
 func GetUploadedFile(c *gin.Context) {
 	filename := c.Param("filename")
 	if filename == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Filename is required"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Filename is required")
 		return
 	}
 
 	// Sanitize filename to prevent directory traversal
 	cleanFilename := filepath.Base(filename)
-	tempDir := filepath.Join(os.TempDir(), uploadTempDir)
+	if strings.HasSuffix(cleanFilename, uploadMetaSuffix) {
+		respondError(c, http.StatusNotFound, ErrNotFound, "File not found")
+		return
+	}
+	tempDir := userUploadDir(currentUser(c))
 	filePath := filepath.Join(tempDir, cleanFilename)
 
 	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		respondError(c, http.StatusNotFound, ErrNotFound, "File not found")
+		return
+	}
+	contentType := uploadContentType(filePath)
+	etag := strings.TrimSuffix(cleanFilename, filepath.Ext(cleanFilename))
+
+	if w := c.Query("w"); w != "" {
+		width, err := strconv.Atoi(w)
+		if err != nil || width < thumbnailMinWidth || width > thumbnailMaxWidth {
+			respondError(c, http.StatusBadRequest, ErrInvalidRequest, fmt.Sprintf("w must be between %d and %d", thumbnailMinWidth, thumbnailMaxWidth))
+			return
+		}
+		thumbPath, thumbType, err := uploadThumbnail(filePath, contentType, width)
+		if err != nil {
+			respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to create thumbnail", err.Error())
+			return
+		}
+		if thumbPath != "" {
+			if thumbInfo, err := os.Stat(thumbPath); err == nil {
+				filePath, info, contentType = thumbPath, thumbInfo, thumbType
+				etag += "-w" + w
+			}
+		}
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		respondError(c, http.StatusNotFound, ErrNotFound, "File not found")
 		return
 	}
+	defer file.Close()
 
-	// Serve the file
-	c.File(filePath)
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.Header("ETag", `"`+etag+`"`)
+	c.Header("X-Content-Type-Options", "nosniff")
+	// ServeContent answers Range and If-None-Match
+	http.ServeContent(c.Writer, c.Request, cleanFilename, info.ModTime(), file)
 }
 
 // DeleteUploadedFile deletes an uploaded file
 func DeleteUploadedFile(c *gin.Context) {
 	filename := c.Param("filename")
 	if filename == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Filename is required"})
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Filename is required")
 		return
 	}
 
 	// Sanitize filename
 	cleanFilename := filepath.Base(filename)
-	tempDir := filepath.Join(os.TempDir(), uploadTempDir)
+	tempDir := userUploadDir(currentUser(c))
 	filePath := filepath.Join(tempDir, cleanFilename)
 
 	// Check if file exists
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		respondError(c, http.StatusNotFound, ErrNotFound, "File not found")
 		return
 	}
 
 	// Delete the file
 	if err := os.Remove(filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file"})
+		respondError(c, http.StatusInternalServerError, ErrInternal, "Failed to delete file")
 		return
 	}
+	os.Remove(filePath + uploadMetaSuffix)
+	removeThumbnails(filePath)
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Build information, set at link time:
+//
+//	go build -ldflags "-X claude-web-ui/handlers.Version=v1.2.0 -X claude-web-ui/handlers.Commit=$(git rev-parse HEAD)"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+const (
+	releasesURL     = "https://api.github.com/repos/greyfolk99/claude-greyzone/releases/latest"
+	claudeNpmURL    = "https://registry.npmjs.org/@anthropic-ai/claude-code/latest"
+	updateCacheTTL  = time.Hour
+	toolVersionTTL  = 5 * time.Minute
+	versionCmdLimit = 5 * time.Second
+)
+
+// VersionInfo is the response of GET /api/version
+type VersionInfo struct {
+	Server  ServerVersion `json:"server"`
+	Claude  ToolVersion   `json:"claude"`
+	Node    ToolVersion   `json:"node"`
+	OS      string        `json:"os"`
+	Arch    string        `json:"arch"`
+	Updates *UpdateInfo   `json:"updates,omitempty"`
+}
+
+// ServerVersion describes the running server build
+type ServerVersion struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildDate string `json:"buildDate,omitempty"`
+	GoVersion string `json:"goVersion"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// ToolVersion is the output of `<tool> --version`
+type ToolVersion struct {
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// UpdateInfo compares installed versions against the latest releases
+type UpdateInfo struct {
+	ServerLatest          string `json:"serverLatest,omitempty"`
+	ServerUpdateAvailable bool   `json:"serverUpdateAvailable"`
+	ServerReleaseURL      string `json:"serverReleaseUrl,omitempty"`
+	ClaudeLatest          string `json:"claudeLatest,omitempty"`
+	ClaudeUpdateAvailable bool   `json:"claudeUpdateAvailable"`
+	CheckedAt             string `json:"checkedAt"`
+	Error                 string `json:"error,omitempty"`
+}
+
+var (
+	versionMu     sync.Mutex
+	toolVersions  = make(map[string]ToolVersion)
+	toolCheckedAt = make(map[string]time.Time)
+	updateCache   *UpdateInfo
+	updateChecked time.Time
+)
+
+// serverVersion fills commit information from the Go build info when not set via ldflags
+func serverVersion() ServerVersion {
+	v := ServerVersion{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if v.Commit == "" {
+					v.Commit = s.Value
+				}
+			case "vcs.time":
+				if v.BuildDate == "" {
+					v.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				v.Modified = s.Value == "true"
+			}
+		}
+	}
+	return v
+}
+
+// toolVersion runs `<name> --version`, caching the result briefly
+func toolVersion(name string) ToolVersion {
+	versionMu.Lock()
+	if v, ok := toolVersions[name]; ok && time.Since(toolCheckedAt[name]) < toolVersionTTL {
+		versionMu.Unlock()
+		return v
+	}
+	versionMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), versionCmdLimit)
+	defer cancel()
+	var v ToolVersion
+	out, err := exec.CommandContext(ctx, name, "--version").Output()
+	if err != nil {
+		v.Error = err.Error()
+	} else {
+		v.Version = strings.TrimSpace(string(out))
+	}
+
+	versionMu.Lock()
+	toolVersions[name] = v
+	toolCheckedAt[name] = time.Now()
+	versionMu.Unlock()
+	return v
+}
+
+// fetchJSON GETs url and decodes the JSON response into v
+func fetchJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "claude-greyzone/"+Version)
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// versionNumber extracts "1.2.3" from strings like "v1.2.3" or "1.2.3 (Claude Code)"
+func versionNumber(s string) string {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, " ("); i >= 0 {
+		s = s[:i]
+	}
+	return s
+}
+
+// checkUpdates queries the latest server release and claude CLI version (cached for an hour)
+func checkUpdates(ctx context.Context, claude ToolVersion) *UpdateInfo {
+	versionMu.Lock()
+	if updateCache != nil && time.Since(updateChecked) < updateCacheTTL {
+		info := *updateCache
+		versionMu.Unlock()
+		return &info
+	}
+	versionMu.Unlock()
+
+	info := &UpdateInfo{CheckedAt: time.Now().Format(time.RFC3339)}
+	var errs []string
+
+	var release struct {
+		TagName string `json:"tag_name"`
+		HTMLURL string `json:"html_url"`
+	}
+	if err := fetchJSON(ctx, releasesURL, &release); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		info.ServerLatest = release.TagName
+		info.ServerReleaseURL = release.HTMLURL
+		info.ServerUpdateAvailable = Version != "dev" && versionNumber(release.TagName) != versionNumber(Version)
+	}
+
+	var pkg struct {
+		Version string `json:"version"`
+	}
+	if err := fetchJSON(ctx, claudeNpmURL, &pkg); err != nil {
+		errs = append(errs, err.Error())
+	} else {
+		info.ClaudeLatest = pkg.Version
+		info.ClaudeUpdateAvailable = claude.Version != "" && versionNumber(claude.Version) != pkg.Version
+	}
+	info.Error = strings.Join(errs, "; ")
+
+	// Failed checks are not cached so the next request retries
+	if len(errs) == 0 {
+		versionMu.Lock()
+		cached := *info
+		updateCache = &cached
+		updateChecked = time.Now()
+		versionMu.Unlock()
+	}
+	return info
+}
+
+// GetVersion handles GET /api/version
+// Query parameters:
+//   - checkUpdates: "true" to compare against the latest releases (requires network access)
+func GetVersion(c *gin.Context) {
+	info := VersionInfo{
+		Server: serverVersion(),
+		Claude: toolVersion("claude"),
+		Node:   toolVersion("node"),
+		OS:     runtime.GOOS,
+		Arch:   runtime.GOARCH,
+	}
+	if c.Query("checkUpdates") == "true" {
+		info.Updates = checkUpdates(c.Request.Context(), info.Claude)
+	}
+	c.JSON(http.StatusOK, info)
+}
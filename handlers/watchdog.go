@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// stopPatternLimit bounds the stopPatterns of one chat request
+	stopPatternLimit = 50
+	// watchdogMatchLimit clips the matched text sent with a watchdogTriggered event
+	watchdogMatchLimit = 200
+)
+
+// WatchdogEvent is the payload of a watchdogTriggered event, sent when a stop pattern matched and the run was interrupted
+type WatchdogEvent struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`         // text or tool
+	Tool    string `json:"tool,omitempty"` // the tool called, for source tool
+	Match   string `json:"match"`          // the text or tool call the pattern matched, clipped
+}
+
+// watchdog interrupts a run whose assistant text or tool calls match one of its stop patterns
+type watchdog struct {
+	patterns  []*regexp.Regexp
+	triggered bool
+}
+
+// newWatchdog compiles a chat request's stopPatterns; nil when there are none
+func newWatchdog(patterns []string) (*watchdog, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	if len(patterns) > stopPatternLimit {
+		return nil, fmt.Errorf("at most %d stopPatterns", stopPatternLimit)
+	}
+	w := &watchdog{}
+	for i, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("stopPatterns[%d]: %w", i, err)
+		}
+		w.patterns = append(w.patterns, re)
+	}
+	return w, nil
+}
+
+// toolCallSubjects returns what stop patterns see of a tool call: "Tool: argument" for each of its
+// string arguments, the most telling first, e.g. "Bash: rm -rf build" or "Edit: /etc/hosts"
+func toolCallSubjects(name string, input map[string]interface{}) []string {
+	var subjects []string
+	seen := make(map[string]bool)
+	for _, key := range activityDetailKeys {
+		if value, ok := input[key].(string); ok && value != "" {
+			subjects = append(subjects, name+": "+value)
+			seen[key] = true
+		}
+	}
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if value, ok := input[key].(string); ok && value != "" && !seen[key] {
+			subjects = append(subjects, name+": "+value)
+		}
+	}
+	if len(subjects) == 0 {
+		subjects = append(subjects, name+":")
+	}
+	return subjects
+}
+
+// check reads a claude output line and reports the first stop pattern its assistant text or tool calls match
+// A watchdog triggers once; the caller interrupts the run
+func (w *watchdog) check(line string) (WatchdogEvent, bool) {
+	if w == nil || w.triggered || !strings.Contains(line, `"assistant"`) {
+		return WatchdogEvent{}, false
+	}
+	var event struct {
+		Type    string `json:"type"`
+		Message struct {
+			Content []struct {
+				Type  string                 `json:"type"`
+				Text  string                 `json:"text"`
+				Name  string                 `json:"name"`
+				Input map[string]interface{} `json:"input"`
+			} `json:"content"`
+		} `json:"message"`
+	}
+	if json.Unmarshal([]byte(line), &event) != nil || event.Type != "assistant" {
+		return WatchdogEvent{}, false
+	}
+	for _, block := range event.Message.Content {
+		var subjects []string
+		switch block.Type {
+		case "text":
+			subjects = []string{block.Text}
+		case "tool_use":
+			subjects = toolCallSubjects(block.Name, block.Input)
+		default:
+			continue
+		}
+		for _, re := range w.patterns {
+			for _, subject := range subjects {
+				if !re.MatchString(subject) {
+					continue
+				}
+				w.triggered = true
+				trigger := WatchdogEvent{Pattern: re.String(), Source: "text", Match: clipText(subject, watchdogMatchLimit)}
+				if block.Type == "tool_use" {
+					trigger.Source, trigger.Tool = "tool", block.Name
+				} else if loc := re.FindStringIndex(subject); loc != nil {
+					// Long text is clipped around the match
+					trigger.Match = markerContext(subject, loc[0], loc[1], watchdogMatchLimit/2)
+				}
+				return trigger, true
+			}
+		}
+	}
+	return WatchdogEvent{}, false
+}
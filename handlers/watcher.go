@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// configDebounce coalesces bursts of file events (editors often write several times)
+	configDebounce = 300 * time.Millisecond
+	// maxWatchedProjects bounds the project workDirs watched at once; the least recently requested is dropped
+	maxWatchedProjects = 32
+)
+
+// ConfigChange describes a change to a watched configuration file
+type ConfigChange struct {
+	Kind    string `json:"kind"`  // "claudeMd", "settings", "mcp", "commands", "agents", "skills", "plugins"
+	Scope   string `json:"scope"` // "user" or "project"
+	WorkDir string `json:"workDir,omitempty"`
+	Path    string `json:"path"`
+}
+
+// ConfigWatcher watches Claude configuration files and directories
+type ConfigWatcher struct {
+	watcher  *fsnotify.Watcher
+	mu       sync.Mutex
+	watched  map[string]bool        // directories currently watched
+	projects map[string]time.Time   // project workDirs registered for watching, with when they were last requested
+	pending  map[string]*time.Timer // debounce timers keyed by kind|scope|workDir
+	handlers []func(ConfigChange)
+}
+
+var configWatcher *ConfigWatcher
+
+// StartConfigWatcher starts watching user-level Claude configuration
+// Project directories are added lazily via watchProject as clients request them
+func StartConfigWatcher() error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	configWatcher = &ConfigWatcher{
+		watcher:  w,
+		watched:  make(map[string]bool),
+		projects: make(map[string]time.Time),
+		pending:  make(map[string]*time.Timer),
+	}
+
+	// Push every change to connected clients so they can invalidate caches
+	configWatcher.onChange(func(change ConfigChange) {
+		broadcastStateEvent("configChanged", map[string]interface{}{
+			"kind":    change.Kind,
+			"scope":   change.Scope,
+			"workDir": change.WorkDir,
+			"path":    change.Path,
+		})
+	})
+	configWatcher.onChange(invalidateResponseCache)
+	configWatcher.onChange(notifyCommandsUpdated)
+
+	for _, dir := range userConfigDirs() {
+		configWatcher.addDir(dir)
+	}
+
+	go configWatcher.run()
+	log.Printf("[ConfigWatcher] Watching %d directories", len(configWatcher.watched))
+	return nil
+}
+
+// userConfigDirs lists the directories holding user-level configuration
+func userConfigDirs() []string {
+	claudeDir := getClaudeDir()
+	homeDir, _ := os.UserHomeDir()
+	return []string{
+		homeDir, // ~/.claude.json (user MCP servers)
+		claudeDir,
+		filepath.Join(claudeDir, "commands"),
+		filepath.Join(claudeDir, "agents"),
+		filepath.Join(claudeDir, "skills"),
+		getPluginsDir(),
+	}
+}
+
+// projectConfigDirs lists the directories holding a project's configuration
+func projectConfigDirs(workDir string) []string {
+	projectClaudeDir := filepath.Join(workDir, ".claude")
+	return []string{
+		workDir,
+		projectClaudeDir,
+		filepath.Join(projectClaudeDir, "commands"),
+		filepath.Join(projectClaudeDir, "agents"),
+		filepath.Join(projectClaudeDir, "skills"),
+	}
+}
+
+// onChange registers a callback invoked (debounced) for every config change
+func (cw *ConfigWatcher) onChange(fn func(ConfigChange)) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	cw.handlers = append(cw.handlers, fn)
+}
+
+// addDir starts watching a directory if it exists and isn't watched yet
+func (cw *ConfigWatcher) addDir(dir string) {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if dir == "" || cw.watched[dir] {
+		return
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return
+	}
+	if err := cw.watcher.Add(dir); err != nil {
+		log.Printf("[ConfigWatcher] Failed to watch %s: %v", dir, err)
+		return
+	}
+	cw.watched[dir] = true
+}
+
+// watchProject registers a project workDir (CLAUDE.md, .mcp.json, .claude/*) the user may access
+// Past maxWatchedProjects, the project requested least recently stops being watched
+func watchProject(user *User, workDir string) {
+	if configWatcher == nil || workDir == "" || workDir == "." || isRemotePath(workDir) {
+		return
+	}
+	workDir = filepath.Clean(workDir)
+	absDir, err := filepath.Abs(workDir)
+	if err != nil || !userCanAccessPath(user, absDir) {
+		return
+	}
+
+	configWatcher.mu.Lock()
+	_, known := configWatcher.projects[workDir]
+	configWatcher.projects[workDir] = time.Now()
+	if !known && len(configWatcher.projects) > maxWatchedProjects {
+		configWatcher.evictProjectLocked()
+	}
+	configWatcher.mu.Unlock()
+	if known {
+		return
+	}
+
+	for _, dir := range projectConfigDirs(workDir) {
+		configWatcher.addDir(dir)
+	}
+}
+
+// evictProjectLocked stops watching the least recently requested project
+// Directories another project or the user config still needs stay watched; cw.mu must be held
+func (cw *ConfigWatcher) evictProjectLocked() {
+	var oldest string
+	var oldestAt time.Time
+	for workDir, at := range cw.projects {
+		if oldest == "" || at.Before(oldestAt) {
+			oldest, oldestAt = workDir, at
+		}
+	}
+	delete(cw.projects, oldest)
+
+	keep := make(map[string]bool)
+	for _, dir := range userConfigDirs() {
+		keep[dir] = true
+	}
+	for workDir := range cw.projects {
+		for _, dir := range projectConfigDirs(workDir) {
+			keep[dir] = true
+		}
+	}
+	for _, dir := range projectConfigDirs(oldest) {
+		if keep[dir] || !cw.watched[dir] {
+			continue
+		}
+		cw.watcher.Remove(dir)
+		delete(cw.watched, dir)
+	}
+}
+
+// classify maps a changed path to a ConfigChange, or returns false if it's not config
+func (cw *ConfigWatcher) classify(path string) (ConfigChange, bool) {
+	claudeDir := getClaudeDir()
+	homeDir, _ := os.UserHomeDir()
+	name := filepath.Base(path)
+	dir := filepath.Dir(path)
+
+	// User scope
+	switch {
+	case path == filepath.Join(homeDir, ".claude.json"):
+		return ConfigChange{Kind: "mcp", Scope: "user", Path: path}, true
+	case dir == claudeDir && name == "CLAUDE.md":
+		return ConfigChange{Kind: "claudeMd", Scope: "user", Path: path}, true
+	case dir == claudeDir && (name == "settings.json" || name == "settings.local.json"):
+		return ConfigChange{Kind: "settings", Scope: "user", Path: path}, true
+	case dir == claudeDir && name == "mcp.json":
+		return ConfigChange{Kind: "mcp", Scope: "user", Path: path}, true
+	case dir == getPluginsDir():
+		if name == "installed_plugins.json" {
+			return ConfigChange{Kind: "plugins", Scope: "user", Path: path}, true
+		}
+		return ConfigChange{}, false
+	}
+	for _, kind := range []string{"commands", "agents", "skills"} {
+		if dir == filepath.Join(claudeDir, kind) || path == filepath.Join(claudeDir, kind) {
+			return ConfigChange{Kind: kind, Scope: "user", Path: path}, true
+		}
+	}
+
+	// Project scope
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	for workDir := range cw.projects {
+		projectClaudeDir := filepath.Join(workDir, ".claude")
+		switch {
+		case dir == workDir && name == "CLAUDE.md", dir == projectClaudeDir && name == "CLAUDE.md":
+			return ConfigChange{Kind: "claudeMd", Scope: "project", WorkDir: workDir, Path: path}, true
+		case dir == workDir && name == ".mcp.json":
+			return ConfigChange{Kind: "mcp", Scope: "project", WorkDir: workDir, Path: path}, true
+		case dir == projectClaudeDir && (name == "settings.json" || name == "settings.local.json"):
+			return ConfigChange{Kind: "settings", Scope: "project", WorkDir: workDir, Path: path}, true
+		}
+		for _, kind := range []string{"commands", "agents", "skills"} {
+			if dir == filepath.Join(projectClaudeDir, kind) || path == filepath.Join(projectClaudeDir, kind) {
+				return ConfigChange{Kind: kind, Scope: "project", WorkDir: workDir, Path: path}, true
+			}
+		}
+	}
+
+	return ConfigChange{}, false
+}
+
+// run processes fsnotify events until the watcher is closed
+func (cw *ConfigWatcher) run() {
+	for {
+		select {
+		case event, ok := <-cw.watcher.Events:
+			if !ok {
+				return
+			}
+			// Skip temp files from atomic writes (e.g. settings.json.tmp)
+			if strings.HasSuffix(event.Name, ".tmp") || strings.HasSuffix(event.Name, "~") {
+				continue
+			}
+
+			// Newly created config directories (e.g. .claude/commands) need their own watch
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					if cw.isConfigDir(event.Name) {
+						cw.addDir(event.Name)
+					}
+				}
+			}
+
+			change, ok := cw.classify(event.Name)
+			if !ok {
+				continue
+			}
+			cw.schedule(change)
+
+		case err, ok := <-cw.watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("[ConfigWatcher] Error: %v", err)
+		}
+	}
+}
+
+// isConfigDir reports whether a newly created directory should be watched
+func (cw *ConfigWatcher) isConfigDir(path string) bool {
+	name := filepath.Base(path)
+	switch name {
+	case ".claude", "commands", "agents", "skills", "plugins":
+		return true
+	}
+	return false
+}
+
+// schedule debounces a change and notifies handlers once events settle
+func (cw *ConfigWatcher) schedule(change ConfigChange) {
+	key := change.Kind + "|" + change.Scope + "|" + change.WorkDir
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	if timer, ok := cw.pending[key]; ok {
+		timer.Stop()
+	}
+	cw.pending[key] = time.AfterFunc(configDebounce, func() {
+		cw.mu.Lock()
+		delete(cw.pending, key)
+		handlers := append([]func(ConfigChange){}, cw.handlers...)
+		cw.mu.Unlock()
+
+		log.Printf("[ConfigWatcher] %s changed (%s) %s", change.Kind, change.Scope, change.Path)
+		for _, fn := range handlers {
+			fn(change)
+		}
+	})
+}
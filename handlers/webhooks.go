@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Webhook event names
+const (
+	WebhookChatStarted     = "chat.started"
+	WebhookChatCompleted   = "chat.completed"
+	WebhookChatFailed      = "chat.failed"
+	WebhookChatInterrupted = "chat.interrupted"
+	WebhookBudgetExceeded  = "budget.exceeded"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookChatStarted:     true,
+	WebhookChatCompleted:   true,
+	WebhookChatFailed:      true,
+	WebhookChatInterrupted: true,
+	WebhookBudgetExceeded:  true,
+}
+
+const (
+	webhookAttempts     = 3
+	webhookSummaryLimit = 2000
+)
+
+// Webhook is an outbound HTTP callback for run lifecycle events
+type Webhook struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	URL     string   `json:"url"`
+	Secret  string   `json:"secret,omitempty"`
+	Events  []string `json:"events"` // empty means all events
+	Format  string   `json:"format"` // json (default), slack, or discord
+	Enabled bool     `json:"enabled"`
+
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// WebhookInfo is a webhook as returned by the API (secret withheld)
+type WebhookInfo struct {
+	Webhook
+	HasSecret    bool             `json:"hasSecret"`
+	LastDelivery *WebhookDelivery `json:"lastDelivery,omitempty"`
+}
+
+// WebhookRequest is the body of POST/PUT /api/webhooks
+// On update, nil fields are left unchanged
+type WebhookRequest struct {
+	Name    *string   `json:"name"`
+	URL     *string   `json:"url"`
+	Secret  *string   `json:"secret"`
+	Events  *[]string `json:"events"`
+	Format  *string   `json:"format"`
+	Enabled *bool     `json:"enabled"`
+}
+
+// WebhookDelivery records the outcome of the latest delivery
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	StatusCode int       `json:"statusCode,omitempty"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// WebhookPayload is the JSON body of "json" format webhooks
+type WebhookPayload struct {
+	Event      string  `json:"event"`
+	DeliveryID string  `json:"deliveryId"`
+	Timestamp  string  `json:"timestamp"`
+	SessionID  string  `json:"sessionId,omitempty"`
+	User       string  `json:"user"`
+	WorkDir    string  `json:"workDir,omitempty"`
+	Transport  string  `json:"transport,omitempty"`
+	DurationMS int64   `json:"durationMs,omitempty"`
+	CostUSD    float64 `json:"costUsd,omitempty"`
+	BudgetUSD  float64 `json:"budgetUsd,omitempty"`
+	NumTurns   int     `json:"numTurns,omitempty"`
+	Summary    string  `json:"summary,omitempty"`
+	Error      string  `json:"error,omitempty"`
+	Prompt     string  `json:"prompt,omitempty"`
+}
+
+var (
+	webhooksMu     sync.Mutex
+	webhooks       []Webhook
+	webhooksLoaded bool
+	lastDeliveries = make(map[string]*WebhookDelivery)
+)
+
+func webhooksPath() string {
+	return serverDataPath("webhooks.json")
+}
+
+// loadWebhooks reads the webhook list once; caller must hold webhooksMu
+func loadWebhooks() error {
+	if webhooksLoaded {
+		return nil
+	}
+	if err := loadJSONFile(webhooksPath(), &webhooks); err != nil {
+		return err
+	}
+	webhooksLoaded = true
+	return nil
+}
+
+func saveWebhooks() error {
+	return writeJSONFileAtomicMode(webhooksPath(), webhooks, 0600)
+}
+
+func (w Webhook) info() WebhookInfo {
+	info := WebhookInfo{Webhook: w, HasSecret: w.Secret != ""}
+	info.Secret = ""
+	if d, ok := lastDeliveries[w.ID]; ok {
+		last := *d
+		info.LastDelivery = &last
+	}
+	return info
+}
+
+func (w Webhook) wants(event string) bool {
+	if !w.Enabled {
+		return false
+	}
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookBody renders the payload in the webhook's format
+func webhookBody(w Webhook, p WebhookPayload) ([]byte, error) {
+	if w.Format != "slack" && w.Format != "discord" {
+		return json.Marshal(p)
+	}
+
+	text := fmt.Sprintf("*%s*", p.Event)
+	if p.WorkDir != "" {
+		text += fmt.Sprintf(" in `%s`", filepath.Base(p.WorkDir))
+	}
+	text += " by " + p.User
+	if p.DurationMS > 0 {
+		text += fmt.Sprintf(" · %s", (time.Duration(p.DurationMS) * time.Millisecond).Round(time.Second))
+	}
+	if p.CostUSD > 0 {
+		text += fmt.Sprintf(" · $%.4f", p.CostUSD)
+	}
+	if p.BudgetUSD > 0 {
+		text += fmt.Sprintf(" of $%.2f budget", p.BudgetUSD)
+	}
+	if p.Error != "" {
+		text += "\n" + p.Error
+	}
+	if p.Summary != "" {
+		text += "\n" + p.Summary
+	}
+	if w.Format == "discord" {
+		return json.Marshal(map[string]string{"content": text})
+	}
+	return json.Marshal(map[string]string{"text": text})
+}
+
+// signWebhook returns the X-Greyzone-Signature value for body
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook POSTs the payload, retrying with backoff on network errors and 5xx
+func deliverWebhook(w Webhook, p WebhookPayload) *WebhookDelivery {
+	d := &WebhookDelivery{ID: p.DeliveryID, Event: p.Event, Time: time.Now()}
+	body, err := webhookBody(w, p)
+	if err != nil {
+		d.Error = err.Error()
+		return d
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		d.Attempts = attempt
+		req, err := http.NewRequest(http.MethodPost, w.URL, bytes.NewReader(body))
+		if err != nil {
+			d.Error = err.Error()
+			return d
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "claude-greyzone/"+Version)
+		req.Header.Set("X-Greyzone-Event", p.Event)
+		req.Header.Set("X-Greyzone-Delivery", p.DeliveryID)
+		if w.Secret != "" {
+			req.Header.Set("X-Greyzone-Signature", signWebhook(w.Secret, body))
+		}
+
+		resp, err := client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+			d.StatusCode = resp.StatusCode
+			if resp.StatusCode < 300 {
+				d.Error = ""
+				return d
+			}
+			d.Error = resp.Status
+			if resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+				return d
+			}
+		} else {
+			d.Error = err.Error()
+		}
+		if attempt < webhookAttempts {
+			time.Sleep(time.Duration(attempt*attempt) * time.Second)
+		}
+	}
+	return d
+}
+
+// fireWebhooks delivers p to every enabled webhook subscribed to its event
+func fireWebhooks(p WebhookPayload) {
+	webhooksMu.Lock()
+	if err := loadWebhooks(); err != nil {
+		webhooksMu.Unlock()
+		log.Printf("[Webhooks] Failed to load webhooks: %v", err)
+		return
+	}
+	var targets []Webhook
+	for _, w := range webhooks {
+		if w.wants(p.Event) {
+			targets = append(targets, w)
+		}
+	}
+	webhooksMu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+
+	p.DeliveryID = generateID()
+	p.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	for _, w := range targets {
+		go func(w Webhook) {
+			d := deliverWebhook(w, p)
+			if d.Error != "" {
+				log.Printf("[Webhooks] %s delivery to %s failed after %d attempt(s): %s", p.Event, w.URL, d.Attempts, d.Error)
+			}
+			webhooksMu.Lock()
+			lastDeliveries[w.ID] = d
+			webhooksMu.Unlock()
+		}(w)
+	}
+}
+
+// fireChatStarted sends chat.started
+func fireChatStarted(user *User, transport string, sessionID string, workDir string, prompt string) {
+	fireWebhooks(WebhookPayload{
+		Event:     WebhookChatStarted,
+		SessionID: sessionID,
+		User:      auditUser(user),
+		WorkDir:   workDir,
+		Transport: transport,
+		Prompt:    auditPrompt(prompt),
+	})
+}
+
+// fireChatFinished sends chat.completed, chat.failed, or chat.interrupted with the run's result
+func fireChatFinished(user *User, transport string, sessionID string, workDir string, outcome string, duration time.Duration, result *resultEvent) {
+	// Every transport reports here, so the analytics run counts are kept here too
+	recordRunStats(ownerID(user), workDir, outcome, result)
+	p := WebhookPayload{
+		SessionID:  sessionID,
+		User:       auditUser(user),
+		WorkDir:    workDir,
+		Transport:  transport,
+		DurationMS: duration.Milliseconds(),
+	}
+	switch outcome {
+	case "success":
+		p.Event = WebhookChatCompleted
+	case "interrupted":
+		p.Event = WebhookChatInterrupted
+	default:
+		p.Event = WebhookChatFailed
+		p.Error = "claude exited with an error"
+	}
+	if result != nil {
+		p.CostUSD = result.TotalCostUSD
+		p.NumTurns = result.NumTurns
+		if result.SessionID != "" {
+			p.SessionID = result.SessionID
+		}
+		if result.IsError {
+			p.Event = WebhookChatFailed
+			p.Error = result.Subtype
+		} else {
+			p.Summary = result.Result
+		}
+		if len(p.Summary) > webhookSummaryLimit {
+			p.Summary = p.Summary[:webhookSummaryLimit] + "…"
+		}
+	}
+	fireWebhooks(p)
+}
+
+// validateWebhook checks a webhook after applying a request
+func validateWebhook(w Webhook) error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	for _, e := range w.Events {
+		if !webhookEvents[e] {
+			return fmt.Errorf("unknown event: %s", e)
+		}
+	}
+	switch w.Format {
+	case "json", "slack", "discord":
+	default:
+		return fmt.Errorf("format must be json, slack, or discord")
+	}
+	return nil
+}
+
+func applyWebhookRequest(w *Webhook, req WebhookRequest) {
+	if req.Name != nil {
+		w.Name = *req.Name
+	}
+	if req.URL != nil {
+		w.URL = *req.URL
+	}
+	if req.Secret != nil {
+		w.Secret = *req.Secret
+	}
+	if req.Events != nil {
+		w.Events = *req.Events
+	}
+	if req.Format != nil {
+		w.Format = *req.Format
+	}
+	if req.Enabled != nil {
+		w.Enabled = *req.Enabled
+	}
+}
+
+// findWebhook returns the index of id; caller must hold webhooksMu
+func findWebhook(id string) int {
+	for i, w := range webhooks {
+		if w.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ListWebhooks handles GET /api/webhooks
+func ListWebhooks(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := loadWebhooks(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load webhooks", err.Error())
+		return
+	}
+	list := make([]WebhookInfo, 0, len(webhooks))
+	for _, w := range webhooks {
+		list = append(list, w.info())
+	}
+	c.JSON(http.StatusOK, gin.H{"webhooks": list})
+}
+
+// CreateWebhook handles POST /api/webhooks
+func CreateWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "url is required")
+		return
+	}
+	w := Webhook{ID: generateID(), Format: "json", Enabled: true, CreatedAt: time.Now()}
+	applyWebhookRequest(&w, req)
+	if err := validateWebhook(w); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := loadWebhooks(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load webhooks", err.Error())
+		return
+	}
+	webhooks = append(webhooks, w)
+	if err := saveWebhooks(); err != nil {
+		webhooks = webhooks[:len(webhooks)-1]
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save webhooks", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, w.info())
+}
+
+// UpdateWebhook handles PUT /api/webhooks/:id
+func UpdateWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, "Invalid request body")
+		return
+	}
+
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := loadWebhooks(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load webhooks", err.Error())
+		return
+	}
+	i := findWebhook(c.Param("id"))
+	if i < 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Webhook not found")
+		return
+	}
+	updated := webhooks[i]
+	applyWebhookRequest(&updated, req)
+	if err := validateWebhook(updated); err != nil {
+		respondError(c, http.StatusBadRequest, ErrInvalidRequest, err.Error())
+		return
+	}
+	previous := webhooks[i]
+	webhooks[i] = updated
+	if err := saveWebhooks(); err != nil {
+		webhooks[i] = previous
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save webhooks", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, updated.info())
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/:id
+func DeleteWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := loadWebhooks(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load webhooks", err.Error())
+		return
+	}
+	id := c.Param("id")
+	i := findWebhook(id)
+	if i < 0 {
+		respondError(c, http.StatusNotFound, ErrNotFound, "Webhook not found")
+		return
+	}
+	webhooks = append(webhooks[:i:i], webhooks[i+1:]...)
+	delete(lastDeliveries, id)
+	if err := saveWebhooks(); err != nil {
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to save webhooks", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// TestWebhook handles POST /api/webhooks/:id/test
+// Sends a synthetic chat.completed event and waits for the result
+func TestWebhook(c *gin.Context) {
+	if !requireAdmin(c) {
+		return
+	}
+	webhooksMu.Lock()
+	if err := loadWebhooks(); err != nil {
+		webhooksMu.Unlock()
+		respondErrorDetails(c, http.StatusInternalServerError, ErrInternal, "Failed to load webhooks", err.Error())
+		return
+	}
+	i := findWebhook(c.Param("id"))
+	if i < 0 {
+		webhooksMu.Unlock()
+		respondError(c, http.StatusNotFound, ErrNotFound, "Webhook not found")
+		return
+	}
+	w := webhooks[i]
+	webhooksMu.Unlock()
+
+	d := deliverWebhook(w, WebhookPayload{
+		Event:      WebhookChatCompleted,
+		DeliveryID: generateID(),
+		Timestamp:  time.Now().UTC().Format(time.RFC3339),
+		User:       auditUser(currentUser(c)),
+		Summary:    "This is a test delivery from Claude Greyzone.",
+	})
+	webhooksMu.Lock()
+	lastDeliveries[w.ID] = d
+	webhooksMu.Unlock()
+	c.JSON(http.StatusOK, d)
+}
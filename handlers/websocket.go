@@ -3,10 +3,11 @@ package handlers
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
 	"os/exec"
 	"regexp"
@@ -19,46 +20,15 @@ import (
 )
 
 var chatUpgrader = websocket.Upgrader{
-	CheckOrigin:     checkWebSocketOrigin,
+	CheckOrigin:     checkOrigin,
+	Subprotocols:    []string{wsProtocol},
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
 }
 
-// checkWebSocketOrigin validates WebSocket connection origins
-func checkWebSocketOrigin(r *http.Request) bool {
-	origin := r.Header.Get("Origin")
-
-	// Allow requests with no origin (non-browser clients)
-	if origin == "" {
-		return true
-	}
-
-	// Allow localhost variants
-	allowedPrefixes := []string{
-		"http://localhost",
-		"https://localhost",
-		"http://127.0.0.1",
-		"https://127.0.0.1",
-		"http://[::1]",
-		"https://[::1]",
-	}
-	for _, prefix := range allowedPrefixes {
-		if strings.HasPrefix(origin, prefix) {
-			return true
-		}
-	}
-
-	// Allow Tailscale IPs (100.x.x.x range)
-	if strings.Contains(origin, "://100.") {
-		return true
-	}
-
-	log.Printf("[WS] Rejected connection from origin: %s", origin)
-	return false
-}
-
 // Session WebSocket Hub - manages connections per session for broadcasting
 type SessionHub struct {
+	connections        map[*WSConnection]bool // all open chat connections
 	sessions           map[string]map[*WSConnection]bool
 	pendingPrompts     map[string]string   // sessionID -> pending user prompt
 	accumulatedContent map[string][]string // sessionID -> accumulated data chunks
@@ -66,11 +36,67 @@ type SessionHub struct {
 }
 
 var sessionHub = &SessionHub{
+	connections:        make(map[*WSConnection]bool),
 	sessions:           make(map[string]map[*WSConnection]bool),
 	pendingPrompts:     make(map[string]string),
 	accumulatedContent: make(map[string][]string),
 }
 
+func (h *SessionHub) Register(ws *WSConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connections[ws] = true
+}
+
+func (h *SessionHub) Unregister(ws *WSConnection) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.connections, ws)
+}
+
+// BroadcastAll sends a message to every open chat connection regardless of subscriptions
+func (h *SessionHub) BroadcastAll(msg interface{}) {
+	h.mu.RLock()
+	conns := make([]*WSConnection, 0, len(h.connections))
+	for ws := range h.connections {
+		conns = append(conns, ws)
+	}
+	h.mu.RUnlock()
+	for _, ws := range conns {
+		ws.SendJSON(msg)
+	}
+}
+
+// BroadcastVisible sends a message to every open chat connection whose user can access sessionID
+func (h *SessionHub) BroadcastVisible(sessionID string, msg interface{}) {
+	h.mu.RLock()
+	conns := make([]*WSConnection, 0, len(h.connections))
+	for ws := range h.connections {
+		if userCanAccessSession(ws.user, sessionID) {
+			conns = append(conns, ws)
+		}
+	}
+	h.mu.RUnlock()
+	for _, ws := range conns {
+		ws.SendJSON(msg)
+	}
+}
+
+// BroadcastOwner sends a message to every open chat connection whose user can access owner's data
+func (h *SessionHub) BroadcastOwner(owner string, msg interface{}) {
+	h.mu.RLock()
+	conns := make([]*WSConnection, 0, len(h.connections))
+	for ws := range h.connections {
+		if userCanAccessOwner(ws.user, owner) {
+			conns = append(conns, ws)
+		}
+	}
+	h.mu.RUnlock()
+	for _, ws := range conns {
+		ws.SendJSON(msg)
+	}
+}
+
 func (h *SessionHub) Subscribe(sessionID string, ws *WSConnection) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
@@ -159,10 +185,28 @@ type WSMessage struct {
 
 // Chat request payload
 type WSChatRequest struct {
-	Prompt    string `json:"prompt"`
-	SessionID string `json:"sessionId,omitempty"`
-	WorkDir   string `json:"workDir,omitempty"`
-	Continue  bool   `json:"continue,omitempty"`
+	Prompt      string   `json:"prompt"`
+	SessionID   string   `json:"sessionId,omitempty"`
+	WorkDir     string   `json:"workDir,omitempty"`
+	Continue    bool     `json:"continue,omitempty"`
+	PlanMode    bool     `json:"planMode,omitempty"`
+	MCPServers  []string `json:"mcpServers,omitempty"`
+	Backend     string   `json:"backend,omitempty"`
+	ChatBackend string   `json:"chatBackend,omitempty"`
+	// Empty fields fall back to the project settings, then the server defaults
+	Model          string   `json:"model,omitempty"`
+	PermissionMode string   `json:"permissionMode,omitempty"`
+	AllowedTools   []string `json:"allowedTools,omitempty"`
+	// StopPatterns interrupt the run when one matches its assistant text or a tool call ("Bash: rm -rf build")
+	StopPatterns []string `json:"stopPatterns,omitempty"`
+	// Approval holds calls of ApprovalTools until an approve message decides them
+	Approval      bool     `json:"approval,omitempty"`
+	ApprovalTools []string `json:"approvalTools,omitempty"`
+	// Snapshot records the working directory first, for POST /api/session/:id/rollback
+	Snapshot bool `json:"snapshot,omitempty"`
+	// AddDirs are directories claude may work in besides the working directory; the session keeps them for
+	// later runs, and an empty list clears them
+	AddDirs []string `json:"addDirs,omitempty"`
 }
 
 // User input payload (for yes/no responses)
@@ -172,27 +216,66 @@ type WSUserInput struct {
 
 // WebSocket connection wrapper
 type WSConnection struct {
-	conn     *websocket.Conn
-	send     chan []byte
-	done     chan struct{}
-	mu       sync.Mutex
-	stdinPipe io.WriteCloser
+	conn      *websocket.Conn
+	send      chan []byte
+	done      chan struct{}
+	mu        sync.Mutex
+	stdinPipe io.Writer
+	user      *User        // authenticated user (nil when auth is disabled)
+	logger    *slog.Logger // tagged with the upgrade request's ID
+	audit     AuditEntry   // who/where fields of the upgrade request for audit entries
+	locale    string       // language of server-generated messages
 }
 
-func newWSConnection(conn *websocket.Conn) *WSConnection {
+func newWSConnection(conn *websocket.Conn, c *gin.Context) *WSConnection {
 	return &WSConnection{
-		conn: conn,
-		send: make(chan []byte, 256),
-		done: make(chan struct{}),
+		conn:   conn,
+		send:   make(chan []byte, 256),
+		done:   make(chan struct{}),
+		user:   currentUser(c),
+		logger: requestLogger(c),
+		audit:  newAuditEntry(c, ""),
+		locale: requestLocale(c),
 	}
 }
 
+// auditEntry starts an audit entry attributed to this connection's user
+func (c *WSConnection) auditEntry(action string) AuditEntry {
+	entry := c.audit
+	entry.Time = time.Now()
+	entry.Action = action
+	return entry
+}
+
 func (c *WSConnection) SendJSON(v interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	return c.conn.WriteJSON(v)
 }
 
+// sendError sends an error event with its machine-readable code
+func (c *WSConnection) sendError(code ErrorCode, message string) error {
+	return c.SendJSON(map[string]interface{}{
+		"type":    "error",
+		"code":    code,
+		"message": translate(c.locale, message),
+	})
+}
+
+// sendCheckError sends err as an error event with the code and details attached by withDetails
+func (c *WSConnection) sendCheckError(err error) error {
+	event := map[string]interface{}{
+		"type":    "error",
+		"code":    errorCodeFor(err, ErrInvalidRequest),
+		"message": translate(c.locale, err.Error()),
+	}
+	var coded *codedError
+	if errors.As(err, &coded) && coded.details != nil {
+		event["details"] = coded.details
+	}
+	return c.SendJSON(event)
+}
+
 func (c *WSConnection) Close() {
 	close(c.done)
 	c.conn.Close()
@@ -206,9 +289,12 @@ func ChatWebSocket(c *gin.Context) {
 		return
 	}
 
-	ws := newWSConnection(conn)
+	ws := newWSConnection(conn, c)
 	defer ws.Close()
 
+	sessionHub.Register(ws)
+	defer sessionHub.Unregister(ws)
+
 	// Track subscribed sessions for cleanup
 	subscribedSessions := make(map[string]bool)
 	defer func() {
@@ -217,14 +303,14 @@ func ChatWebSocket(c *gin.Context) {
 		}
 	}()
 
-	log.Printf("[WS] New connection established")
+	ws.logger.Info("WebSocket connection established")
 
 	// Read messages from client
 	for {
 		var msg WSMessage
 		if err := conn.ReadJSON(&msg); err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("[WS] Read error: %v", err)
+				ws.logger.Warn("WebSocket read error", "error", err)
 			}
 			break
 		}
@@ -238,16 +324,16 @@ func ChatWebSocket(c *gin.Context) {
 			if err := json.Unmarshal(msg.Payload, &req); err != nil || req.SessionID == "" {
 				continue
 			}
+			if !userCanAccessSession(ws.user, req.SessionID) {
+				continue
+			}
 			sessionHub.Subscribe(req.SessionID, ws)
 			subscribedSessions[req.SessionID] = true
 
 		case "chat":
 			var req WSChatRequest
 			if err := json.Unmarshal(msg.Payload, &req); err != nil {
-				ws.SendJSON(map[string]interface{}{
-					"type":    "error",
-					"message": "Invalid chat request",
-				})
+				ws.sendError(ErrInvalidRequest, "Invalid chat request")
 				continue
 			}
 			go handleWSChat(ws, req)
@@ -263,6 +349,21 @@ func ChatWebSocket(c *gin.Context) {
 				ws.stdinPipe.Write([]byte(input.Input + "\n"))
 			}
 
+		case "approve":
+			// Decide a tool call held by approval mode
+			var decision ApprovalDecision
+			if err := json.Unmarshal(msg.Payload, &decision); err != nil || decision.ApprovalID == "" {
+				ws.sendError(ErrInvalidRequest, "Invalid approve message")
+				continue
+			}
+			if err := decideApproval(ws.user, decision.ApprovalID, decision); err != nil {
+				ws.sendCheckError(err)
+				continue
+			}
+			entry := ws.auditEntry("chat.approve")
+			entry.Details = map[string]string{"approvalId": decision.ApprovalID, "approved": fmt.Sprint(decision.Approve)}
+			recordAudit(entry)
+
 		case "interrupt":
 			// Handle interrupt - find and kill process
 			var req struct {
@@ -271,14 +372,14 @@ func ChatWebSocket(c *gin.Context) {
 			if err := json.Unmarshal(msg.Payload, &req); err != nil {
 				continue
 			}
-			log.Printf("[WS] Interrupt requested for session %s", req.SessionID)
+			ws.logger.Info("Interrupt requested", "sessionId", req.SessionID)
 			// Find the process first (with RLock), then kill it outside the lock
-			var cmdToKill *exec.Cmd
+			var interrupt func() error
 			var pidToUnregister int
 			processLock.RLock()
 			for pid, info := range activeProcesses {
-				if info.SessionID == req.SessionID {
-					cmdToKill = info.Cmd
+				if info.SessionID == req.SessionID && userCanAccessOwner(ws.user, info.Owner) {
+					interrupt = info.Interrupt
 					pidToUnregister = pid
 					break
 				}
@@ -286,15 +387,18 @@ func ChatWebSocket(c *gin.Context) {
 			processLock.RUnlock()
 
 			// Now kill and cleanup outside the lock
-			if cmdToKill != nil && cmdToKill.Process != nil {
-				log.Printf("[WS] Killing process %d for session %s", pidToUnregister, req.SessionID)
-				cmdToKill.Process.Kill()
+			if interrupt != nil {
+				ws.logger.Info("Interrupt: killing process", "processId", pidToUnregister, "sessionId", req.SessionID)
+				interrupt()
 				unregisterProcess(pidToUnregister)
 				SetSessionLoading(req.SessionID, false)
 				SetSessionProcessID(req.SessionID, nil)
-				log.Printf("[WS] Interrupt complete for session %s", req.SessionID)
+				ws.logger.Info("Interrupt complete", "sessionId", req.SessionID)
+				entry := ws.auditEntry("chat.interrupt")
+				entry.SessionID = req.SessionID
+				recordAudit(entry)
 			} else {
-				log.Printf("[WS] No process found for session %s", req.SessionID)
+				ws.logger.Info("Interrupt: process not found", "sessionId", req.SessionID)
 			}
 		}
 	}
@@ -302,12 +406,14 @@ func ChatWebSocket(c *gin.Context) {
 
 // handleWSChat executes claude CLI and streams output via WebSocket
 func handleWSChat(ws *WSConnection, req WSChatRequest) {
+	if req.SessionID != "" && !userCanAccessSession(ws.user, req.SessionID) {
+		ws.sendError(ErrSessionNotFound, "Session not found")
+		return
+	}
+
 	// Check if session is already loading
 	if req.SessionID != "" && IsSessionLoading(req.SessionID) {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": "This session is already processing a request",
-		})
+		ws.sendError(ErrSessionBusy, "This session is already processing a request")
 		return
 	}
 
@@ -316,24 +422,53 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 	if workDir == "" && req.SessionID != "" {
 		workDir = GetSessionWorkDir(req.SessionID)
 	}
+	if workDir == "" && ws.user != nil && len(ws.user.ProjectRoots) > 0 {
+		workDir = ws.user.ProjectRoots[0]
+	}
 	if workDir == "" {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
-			ws.SendJSON(map[string]interface{}{
-				"type":    "error",
-				"message": fmt.Sprintf("Failed to get home directory: %v", err),
-			})
+			ws.sendError(ErrInternal, fmt.Sprintf("Failed to get home directory: %v", err))
 			return
 		}
 		workDir = homeDir
 	}
 
+	if !userCanAccessPath(ws.user, workDir) {
+		ws.sendError(ErrPathForbidden, fmt.Sprintf("Working directory is outside your projects: %s", workDir))
+		return
+	}
+
 	// Validate working directory
-	if _, err := os.Stat(workDir); os.IsNotExist(err) {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": fmt.Sprintf("Working directory does not exist: %s", workDir),
-		})
+	if err := checkWorkDir(workDir); err != nil {
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
+		return
+	}
+	if err := checkBackend(req.Backend, workDir); err != nil {
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
+		return
+	}
+	addDirs, err := chatAddDirs(ws.user, req.SessionID, workDir, req.Backend, req.AddDirs)
+	if err != nil {
+		ws.sendCheckError(err)
+		return
+	}
+	watch, err := newWatchdog(req.StopPatterns)
+	if err != nil {
+		ws.sendError(ErrInvalidRequest, err.Error())
+		return
+	}
+
+	if err := checkChatLimits(ownerID(ws.user)); err != nil {
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
+		return
+	}
+	if err := checkBudget(workDir); err != nil {
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
+		return
+	}
+	if err := checkResources(workDir); err != nil {
+		ws.sendCheckError(err)
 		return
 	}
 
@@ -346,6 +481,9 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 	for _, match := range matches {
 		if len(match) > 1 {
 			path := strings.TrimSpace(match[1])
+			if !userCanAttachFile(ws.user, path) {
+				continue
+			}
 			if _, err := os.Stat(path); err == nil {
 				imagePaths = append(imagePaths, path)
 			}
@@ -357,97 +495,82 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 	cleanPrompt = strings.TrimSpace(cleanPrompt)
 
 	if cleanPrompt == "" && len(imagePaths) > 0 {
-		cleanPrompt = "이 이미지를 분석해줘"
+		cleanPrompt = translate(ws.locale, defaultImagePrompt)
 	}
 
-	// Build claude command arguments
-	args := []string{
-		"-p",
-		"--output-format", "stream-json",
-		"--verbose",
-		"--dangerously-skip-permissions",
-	}
-
-	if req.SessionID != "" {
-		args = append(args, "--resume", req.SessionID)
-	}
-
-	if req.Continue || (cleanPrompt == "" && len(imagePaths) == 0) {
-		args = append(args, "--continue")
-	}
-
-	for _, imgPath := range imagePaths {
-		args = append(args, "--files", imgPath)
-	}
-
-	if cleanPrompt != "" {
-		args = append(args, cleanPrompt)
-	}
-
-	// Create command using script to force PTY for proper output streaming
-	// script -q -c "command" /dev/null forces PTY mode without saving typescript
-	// Shell-escape each argument to handle spaces and special characters
-	quotedArgs := make([]string, len(args))
-	for i, arg := range args {
-		// Use single quotes to avoid shell interpretation
-		// Replace any single quotes in the arg with '\'' (close quote, escaped quote, open quote)
-		escapedArg := strings.ReplaceAll(arg, "'", "'\"'\"'")
-		quotedArgs[i] = "'" + escapedArg + "'"
-	}
-	claudeCmd := "claude " + strings.Join(quotedArgs, " ")
-	cmd := exec.Command("script", "-q", "-c", claudeCmd, "/dev/null")
-	cmd.Dir = workDir
-	cmd.Env = os.Environ()
-
-	log.Printf("[WS] Executing via script: claude %s (workDir: %s)", strings.Join(args, " "), workDir)
-
-	// Get pipes
-	stdout, err := cmd.StdoutPipe()
+	chatBackend, err := chatBackendFor(req.ChatBackend)
 	if err != nil {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": fmt.Sprintf("Failed to create stdout pipe: %v", err),
-		})
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
 		return
 	}
 
-	stderr, err := cmd.StderrPipe()
+	var gate *approvalGate
+	if req.Approval {
+		if gate, err = newApprovalGate(ownerID(ws.user), workDir, req.Backend, chatBackend.Name(), req.ApprovalTools); err != nil {
+			ws.sendCheckError(err)
+			return
+		}
+		defer gate.close()
+	}
+	snapshot, err := newRunSnapshot(req.Snapshot, workDir, req.Backend)
 	if err != nil {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": fmt.Sprintf("Failed to create stderr pipe: %v", err),
-		})
+		ws.sendCheckError(err)
 		return
 	}
-
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": fmt.Sprintf("Failed to create stdin pipe: %v", err),
-		})
+	defer snapshot.finish()
+	snapshot.attach(req.SessionID)
+
+	// CLI chats run under script to force PTY mode for proper output streaming
+	turn := ChatTurn{
+		WorkDir:        workDir,
+		SessionID:      req.SessionID,
+		Prompt:         cleanPrompt,
+		ImagePaths:     imagePaths,
+		Continue:       req.Continue,
+		PlanMode:       req.PlanMode,
+		MCPServers:     req.MCPServers,
+		Backend:        req.Backend,
+		PTY:            true,
+		Logger:         ws.logger.With("transport", "ws", "chatBackend", chatBackend.Name()),
+		Model:          req.Model,
+		PermissionMode: req.PermissionMode,
+		AllowedTools:   req.AllowedTools,
+		Approval:       gate,
+		AddDirs:        addDirs,
+	}
+	if err := inheritProjectSettings(&turn); err != nil {
+		ws.sendError(errorCodeFor(err, ErrInvalidRequest), err.Error())
 		return
 	}
-	ws.stdinPipe = stdin
-
-	// Start command
-	if err := cmd.Start(); err != nil {
-		ws.SendJSON(map[string]interface{}{
-			"type":    "error",
-			"message": fmt.Sprintf("Failed to start claude command: %v", err),
-		})
+	proc, err := chatBackend.Start(turn)
+	if err != nil {
+		ws.sendError(errorCodeFor(err, ErrInternal), err.Error())
 		return
 	}
+	ws.stdinPipe = proc.Stdin()
 
 	// Register process
+	startTime := time.Now()
 	processID := getNextProcessID()
 	registerProcess(processID, &ProcessInfo{
-		Cmd:       cmd,
-		SessionID: req.SessionID,
-		WorkDir:   workDir,
-		StartTime: time.Now().Unix(),
+		Interrupt:   proc.Interrupt,
+		SessionID:   req.SessionID,
+		WorkDir:     workDir,
+		StartTime:   time.Now().Unix(),
+		Mode:        "chat",
+		Backend:     req.Backend,
+		ChatBackend: chatBackend.Name(),
+		Owner:       ownerID(ws.user),
+		pid:         chatProcessPID(proc),
 	})
 
+	entry := ws.auditEntry("chat.execute")
+	entry.SessionID = req.SessionID
+	entry.WorkDir = workDir
+	entry.Details = map[string]string{"transport": "ws", "prompt": auditPrompt(req.Prompt)}
+	recordAudit(entry)
+	fireChatStarted(ws.user, "ws", req.SessionID, workDir, req.Prompt)
+
 	activeSessionID := req.SessionID
 	if activeSessionID != "" {
 		SetSessionLoading(activeSessionID, true)
@@ -455,13 +578,33 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 		// Subscribe sender to this session for broadcasts
 		sessionHub.Subscribe(activeSessionID, ws)
 	}
+	if gate != nil {
+		// Approval cards go to everyone watching the session
+		gate.setNotify(func(event string, data map[string]interface{}) {
+			msg := map[string]interface{}{"type": event}
+			for k, v := range data {
+				msg[k] = v
+			}
+			if activeSessionID != "" {
+				sessionHub.Broadcast(activeSessionID, msg)
+			} else {
+				ws.SendJSON(msg)
+			}
+		})
+	}
+	if snapshot != nil {
+		ws.SendJSON(map[string]interface{}{
+			"type":     "snapshot",
+			"snapshot": snapshot.snap,
+		})
+	}
 
 	// Cleanup on exit
 	defer func() {
-		log.Printf("[WS] Cleanup: session %s, process %d", activeSessionID, processID)
+		ws.logger.Debug("Cleanup", "sessionId", activeSessionID, "processId", processID)
 		// Update state FIRST (before unregisterProcess to avoid race)
 		if activeSessionID != "" {
-			log.Printf("[WS] Cleanup: setting session %s loading=false", activeSessionID)
+			ws.logger.Debug("Cleanup: setting loading=false", "sessionId", activeSessionID)
 			SetSessionLoading(activeSessionID, false)
 			SetSessionProcessID(activeSessionID, nil)
 			sessionHub.ClearPendingPrompt(activeSessionID)
@@ -470,7 +613,7 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 		// Then unregister process
 		unregisterProcess(processID)
 		ws.stdinPipe = nil
-		log.Printf("[WS] Cleanup done for session %s", activeSessionID)
+		ws.logger.Debug("Cleanup done", "sessionId", activeSessionID)
 	}()
 
 	// Set pending prompt and broadcast to all subscribers (including sender)
@@ -493,21 +636,25 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 	var wg sync.WaitGroup
 
 	// Read stdout
+	inputNotified := false
+	var lastResult *resultEvent
+	runUsage := newUsageTracker()
+	diagnostics := newDiagnosticRun("ws", processID, workDir, ownerID(ws.user))
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Printf("[WS] Starting stdout reader")
-		scanner := bufio.NewScanner(stdout)
+		ws.logger.Debug("Starting stdout reader")
+		scanner := bufio.NewScanner(proc.Stdout())
 		buf := make([]byte, 0, 64*1024)
 		scanner.Buffer(buf, 1024*1024)
-		log.Printf("[WS] Entering scanner loop")
+		ws.logger.Debug("Entering scanner loop")
 
 		for scanner.Scan() {
 			line := scanner.Text()
 			if len(line) > 100 {
-				log.Printf("[WS] stdout line: %s...", line[:100])
+				ws.logger.Debug("stdout line", "line", line[:100]+"...")
 			} else {
-				log.Printf("[WS] stdout line: %s", line)
+				ws.logger.Debug("stdout line", "line", line)
 			}
 			if line == "" {
 				continue
@@ -540,6 +687,20 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 				}
 			}
 
+			recordStreamBytes("ws", len(line))
+			if result := parseResultEvent(line); result != nil {
+				recordResultUsage(result)
+				recordProjectCost(ownerID(ws.user), workDir, result)
+				lastResult = result
+				if d, ok := diagnostics.checkResult(result); ok {
+					ws.SendJSON(wsDiagnosticMessage(d, ws.locale))
+				}
+			}
+			trackInputRequest(ownerID(ws.user), activeSessionID, line, &inputNotified)
+			trackTodos(activeSessionID, workDir, line)
+			trackContextUsage(activeSessionID, line)
+			recordProcessOutput(processID, line)
+
 			// Forward the line - broadcast to all subscribers if session exists
 			msg := map[string]interface{}{
 				"type": "data",
@@ -551,35 +712,81 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 			} else {
 				ws.SendJSON(msg)
 			}
+			if usage, ok := runUsage.track(line); ok {
+				usageMsg := map[string]interface{}{"type": "usage", "usage": usage}
+				if activeSessionID != "" {
+					sessionHub.Broadcast(activeSessionID, usageMsg)
+				} else {
+					ws.SendJSON(usageMsg)
+				}
+			}
+			if trigger, ok := watch.check(line); ok {
+				ws.logger.Info("Stop pattern matched, interrupting", "processId", processID, "pattern", trigger.Pattern, "match", trigger.Match)
+				watchdogMsg := map[string]interface{}{"type": "watchdogTriggered", "watchdog": trigger}
+				if activeSessionID != "" {
+					sessionHub.Broadcast(activeSessionID, watchdogMsg)
+				} else {
+					ws.SendJSON(watchdogMsg)
+				}
+				if err := proc.Interrupt(); err != nil {
+					ws.logger.Error("Failed to interrupt process", "processId", processID, "error", err)
+				}
+			}
+
+			// Remember who created new sessions
+			if newSessionID := extractInitSessionID(line); newSessionID != "" {
+				recordSessionOwner(newSessionID, ownerID(ws.user))
+				snapshot.attach(newSessionID)
+				keepChatAddDirs(newSessionID, req.AddDirs, addDirs, ws.user)
+			}
+
+			// Surface which MCP servers the CLI actually loaded
+			if servers, ok := extractInitMCPServers(line); ok {
+				ws.SendJSON(map[string]interface{}{
+					"type":    "mcpServers",
+					"servers": servers,
+				})
+			}
 		}
 		if err := scanner.Err(); err != nil {
-			log.Printf("[WS] Scanner error: %v", err)
+			ws.logger.Warn("Scanner error", "error", err)
 		}
-		log.Printf("[WS] Stdout reader finished")
+		ws.logger.Debug("Stdout reader finished")
 	}()
 
 	// Read stderr
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		scanner := bufio.NewScanner(stderr)
+		scanner := bufio.NewScanner(proc.Stderr())
 		buf := make([]byte, 0, 64*1024)
 		scanner.Buffer(buf, 1024*1024)
 
 		for scanner.Scan() {
 			line := scanner.Text()
-			if line != "" {
-				ws.SendJSON(map[string]interface{}{
-					"type":    "stderr",
-					"message": line,
-				})
+			if line == "" {
+				continue
 			}
+			if d, recognized, first := diagnostics.check(line); recognized {
+				if first {
+					ws.SendJSON(wsDiagnosticMessage(d, ws.locale))
+				}
+				continue
+			}
+			ws.SendJSON(map[string]interface{}{
+				"type":    "stderr",
+				"message": line,
+			})
 		}
 	}()
 
 	// Wait for command to finish
-	err = cmd.Wait()
+	err = proc.Wait()
 	wg.Wait()
+	recordChatFinished("ws", startTime, chatOutcome(err))
+	notifyChatFinished(ownerID(ws.user), activeSessionID, workDir, chatOutcome(err), time.Since(startTime))
+	fireChatFinished(ws.user, "ws", activeSessionID, workDir, chatOutcome(err), time.Since(startTime), lastResult)
+	ws.logger.Info("Claude process finished", "processId", processID, "sessionId", activeSessionID, "outcome", chatOutcome(err), "duration", time.Since(startTime).String())
 
 	// Helper to send or broadcast
 	sendOrBroadcast := func(msg map[string]interface{}) {
@@ -601,12 +808,14 @@ func handleWSChat(ws *WSConnection, req WSChatRequest) {
 			} else {
 				sendOrBroadcast(map[string]interface{}{
 					"type":    "error",
+					"code":    ErrCLIFailed,
 					"message": fmt.Sprintf("Command exited with error: %v (exit code: %d)", err, exitCode),
 				})
 			}
 		} else {
 			sendOrBroadcast(map[string]interface{}{
 				"type":    "error",
+				"code":    errorCodeFor(err, ErrCLIFailed),
 				"message": fmt.Sprintf("Command execution failed: %v", err),
 			})
 		}
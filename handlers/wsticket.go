@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsTicketTTL is how long a WebSocket ticket can be redeemed after it is minted
+	wsTicketTTL = 30 * time.Second
+
+	// wsProtocol is the subprotocol the WebSocket endpoints select; clients list it
+	// next to a credential subprotocol so the handshake has one to echo back
+	wsProtocol = "greyzone"
+
+	// Credential subprotocols: greyzone.token.<login token> or greyzone.ticket.<ticket>
+	wsTokenProtocol  = wsProtocol + ".token."
+	wsTicketProtocol = wsProtocol + ".ticket."
+)
+
+// WSTicketResponse is the response of POST /api/ws-ticket
+type WSTicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Protocols []string  `json:"protocols"` // pass to new WebSocket(url, protocols)
+}
+
+var (
+	wsTicketKeyOnce sync.Once
+	wsTicketKey     []byte
+
+	wsTicketsMu   sync.Mutex
+	wsTicketsUsed = make(map[string]time.Time) // redeemed ticket -> expiry, so each works once
+)
+
+// wsTicketSigningKey is generated per process; tickets don't survive a restart
+func wsTicketSigningKey() []byte {
+	wsTicketKeyOnce.Do(func() {
+		wsTicketKey = make([]byte, 32)
+		rand.Read(wsTicketKey)
+	})
+	return wsTicketKey
+}
+
+func signWSTicket(payload string) string {
+	mac := hmac.New(sha256.New, wsTicketSigningKey())
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// mintWSTicket returns a signed single-use ticket for userID
+// The payload is userID.expiry.nonce, base64url encoded, followed by its HMAC
+func mintWSTicket(userID string) (string, time.Time) {
+	expires := time.Now().Add(wsTicketTTL)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(userID + "." + strconv.FormatInt(expires.Unix(), 10) + "." + randomToken(12)))
+	return payload + "." + signWSTicket(payload), expires
+}
+
+// redeemWSTicket checks a ticket and returns its user ID
+func redeemWSTicket(ticket string) (string, bool) {
+	payload, sig, ok := strings.Cut(ticket, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signWSTicket(payload))) {
+		return "", false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", false
+	}
+	parts := strings.Split(string(raw), ".")
+	if len(parts) != 3 {
+		return "", false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	expires := time.Unix(expiry, 0)
+	now := time.Now()
+	if now.After(expires) {
+		return "", false
+	}
+
+	wsTicketsMu.Lock()
+	defer wsTicketsMu.Unlock()
+	for t, exp := range wsTicketsUsed {
+		if now.After(exp) {
+			delete(wsTicketsUsed, t)
+		}
+	}
+	if _, used := wsTicketsUsed[ticket]; used {
+		return "", false
+	}
+	wsTicketsUsed[ticket] = expires
+	return parts[0], true
+}
+
+// wsRequestUser authenticates a WebSocket upgrade by a credential subprotocol or ?ticket=
+// Browsers can't set headers on WebSocket requests, so this keeps tokens out of URLs
+// and lets clients connect without relying on the cookie (the Origin check still applies)
+func wsRequestUser(c *gin.Context) *User {
+	var token, ticket string
+	for _, protocol := range websocket.Subprotocols(c.Request) {
+		if strings.HasPrefix(protocol, wsTokenProtocol) {
+			token = strings.TrimPrefix(protocol, wsTokenProtocol)
+		} else if strings.HasPrefix(protocol, wsTicketProtocol) {
+			ticket = strings.TrimPrefix(protocol, wsTicketProtocol)
+		}
+	}
+	if ticket == "" {
+		ticket = c.Query("ticket")
+	}
+
+	if token != "" {
+		return authManager.lookupSession(token)
+	}
+	if ticket != "" {
+		if userID, ok := redeemWSTicket(ticket); ok {
+			return userByID(userID)
+		}
+	}
+	return nil
+}
+
+// CreateWSTicket handles POST /api/ws-ticket
+// Mints a ticket that authenticates one WebSocket connection within wsTicketTTL,
+// for clients that would otherwise put their login token in the URL
+// API keys can't mint tickets: a ticket carries the owner's full role, not the key's scope
+func CreateWSTicket(c *gin.Context) {
+	if requestAPIKeyScope(c) != "" {
+		respondError(c, http.StatusForbidden, ErrForbidden, "WebSocket tickets can't be created with an API key")
+		return
+	}
+	ticket, expires := mintWSTicket(ownerID(currentUser(c)))
+	c.JSON(http.StatusOK, WSTicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: expires,
+		Protocols: []string{wsProtocol, wsTicketProtocol + ticket},
+	})
+}
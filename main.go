@@ -2,15 +2,15 @@ package main
 
 import (
 	"context"
-	"flag"
-	"fmt"
-	"io"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
-	"strings"
+	"path"
+	"runtime"
+	"strconv"
 	"syscall"
 	"time"
 
@@ -20,15 +20,56 @@ import (
 )
 
 func main() {
-	// Parse command line arguments
-	port := flag.Int("port", 43210, "Server port")
-	logDir := flag.String("log-dir", "./logs", "Log directory")
-	flag.Parse()
+	// Load config file, environment, and command line arguments
+	cfg, configPath, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
 	// Setup logging to file
-	if err := setupLogging(*logDir); err != nil {
+	if err := handlers.SetupLogging(cfg.LogDir, cfg.Logging); err != nil {
 		log.Fatalf("Failed to setup logging: %v", err)
 	}
+	log.Printf("claude-greyzone %s (%s)", handlers.Version, runtime.Version())
+
+	if cfg.DataDir != "" {
+		handlers.SetServerDataDir(cfg.DataDir)
+	}
+	handlers.SetServerConfig(cfg, configPath)
+
+	// Load user accounts and enable authentication
+	basePath := cfg.BasePath
+	authConfig := cfg.Auth
+	authConfig.LoginRedirect = basePath + "/"
+	if err := handlers.ConfigureAuth(authConfig); err != nil {
+		log.Fatalf("Failed to configure authentication: %v", err)
+	}
+
+	// Watch Claude config files so clients are notified of changes
+	if err := handlers.StartConfigWatcher(); err != nil {
+		log.Printf("Config watcher disabled: %v", err)
+	}
+
+	// Run scheduled prompts
+	handlers.StartScheduler()
+
+	// Upload backups on backup.schedule
+	handlers.StartBackupScheduler()
+
+	// Mail activity digests on digest.schedule
+	handlers.StartDigestScheduler()
+
+	// Stop chat runs past limits.processes
+	handlers.StartProcessLimits()
+
+	// Start warm processes for the stream chat backend
+	handlers.StartStreamPool()
+
+	// Use the claude token stored by an assisted login
+	handlers.LoadClaudeToken()
+
+	// Find the claude CLI and the flags it supports
+	go handlers.ProbeClaudeCLI()
 
 	// Set Gin mode
 	gin.SetMode(gin.ReleaseMode)
@@ -36,65 +77,274 @@ func main() {
 	// Create Gin router
 	router := gin.New()
 
+	// Only honor X-Forwarded-For from configured proxies
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid --trusted-proxies: %v", err)
+	}
+
 	// Add middleware
 	router.Use(recoveryMiddleware())
+	router.Use(handlers.RequestIDMiddleware())
 	router.Use(loggingMiddleware())
+	router.Use(handlers.MetricsMiddleware())
+	router.Use(handlers.StreamDeadlineMiddleware())
+	router.Use(handlers.BodyLimitMiddleware())
 	router.Use(corsMiddleware())
+	router.Use(handlers.AuthMiddleware())
 
-	// Health check endpoint
-	router.GET("/health", healthCheck())
+	// Health check endpoints: live answers while the server runs, ready checks what it depends on
+	router.GET("/health", handlers.HealthLive)
+	router.GET("/health/live", handlers.HealthLive)
+	router.GET("/health/ready", handlers.HealthReady)
 
-	// Serve static files from client/dist
-	router.Static("/assets", "./client/dist/assets")
-	router.StaticFile("/favicon.ico", "./client/dist/favicon.ico")
+	// Public read-only transcripts (POST /api/session/:id/share)
+	router.GET("/share/:token", handlers.SharedTranscriptPage)
+
+	// Prometheus metrics
+	router.GET("/metrics", handlers.GetMetrics)
+
+	// Serve the client bundle (embedded, or --static-dir during development)
+	static, err := clientFS(cfg.StaticDir)
+	if err != nil {
+		log.Fatalf("Failed to load client bundle: %v", err)
+	}
+	router.GET("/assets/*filepath", func(c *gin.Context) {
+		if !serveStatic(c, static, path.Join("assets", c.Param("filepath"))) {
+			c.Status(http.StatusNotFound)
+		}
+	})
 
 	// API routes
 	api := router.Group("/api")
+	admin := handlers.AdminOnly()
 	{
+		// Authentication and user management
+		api.GET("/auth/status", handlers.AuthStatus)
+		api.POST("/auth/login", handlers.Login)
+		api.POST("/auth/logout", handlers.Logout)
+		api.GET("/auth/me", handlers.GetCurrentUser)
+		api.GET("/auth/oidc/login", handlers.OIDCLogin)
+		api.GET("/auth/oidc/callback", handlers.OIDCCallback)
+		api.GET("/auth/claude-status", handlers.GetClaudeAuthStatus)
+		api.POST("/auth/claude-login", admin, handlers.Audited("claude.login"), handlers.StartClaudeLogin)
+		api.POST("/auth/claude-login/code", admin, handlers.Audited("claude.login.code"), handlers.SubmitClaudeLoginCode)
+		api.DELETE("/auth/claude-login", admin, handlers.Audited("claude.login.cancel"), handlers.CancelClaudeLogin)
+		api.GET("/users", handlers.ListUsers)
+		api.POST("/users", handlers.Audited("user.create"), handlers.CreateUser)
+		api.PUT("/users/:id", handlers.Audited("user.update"), handlers.UpdateUser)
+		api.DELETE("/users/:id", handlers.Audited("user.delete"), handlers.DeleteUser)
+		api.GET("/keys", handlers.ListAPIKeys)
+		api.POST("/keys", handlers.Audited("apikey.create"), handlers.CreateAPIKey)
+		api.DELETE("/keys/:id", handlers.Audited("apikey.revoke"), handlers.RevokeAPIKey)
+
 		api.GET("/sessions", handlers.ListSessions)
 		api.POST("/sessions/dirty-check", handlers.CheckSessionsDirty)
+		api.POST("/sessions/import", handlers.Audited("session.import"), handlers.ImportSessions)
 		api.GET("/session/:id/info", handlers.GetSession)
 		api.GET("/session/:id/history", handlers.GetSessionHistory)
+		api.GET("/session/:id/messages/:uuid/context", handlers.GetMessageContext)
+		api.POST("/session/:id/messages/:uuid/feedback", handlers.Audited("session.feedback"), handlers.SetMessageFeedback)
+		api.GET("/session/:id/feedback", handlers.GetSessionFeedback)
+		api.GET("/session/:id/artifacts", handlers.GetArtifacts)
+		api.POST("/session/:id/export/gist", handlers.Audited("session.export"), handlers.ExportGist)
+		api.GET("/session/:id/snapshots", handlers.ListSnapshots)
+		api.POST("/session/:id/rollback", handlers.Audited("session.rollback"), handlers.RollbackSession)
+		api.GET("/session/:id/dirs", handlers.GetSessionDirs)
+		api.PUT("/session/:id/dirs", handlers.Audited("session.dirs"), handlers.UpdateSessionDirs)
+		api.GET("/feedback", handlers.ExportFeedback)
+		api.GET("/session/:id/search", handlers.SearchSession)
 		api.GET("/session/:id/mtime", handlers.GetSessionMtime)
-		api.DELETE("/session/:id", handlers.DeleteSession)
+		api.DELETE("/session/:id", handlers.Audited("session.delete"), handlers.DeleteSession)
+		api.POST("/session/:id/compact", handlers.CompactSession)
+		api.POST("/session/:id/summarize", handlers.SummarizeSession)
+		api.POST("/session/:id/pin", handlers.Audited("session.pin"), handlers.PinSession)
+		api.DELETE("/session/:id/pin", handlers.Audited("session.unpin"), handlers.UnpinSession)
+		api.GET("/session/:id/draft", handlers.GetDraft)
+		api.PUT("/session/:id/draft", handlers.PutDraft)
+		api.POST("/session/:id/share", handlers.Audited("session.share"), handlers.CreateShare)
+		api.POST("/session/:id/redaction-report", handlers.TestRedaction)
+		api.GET("/shares", handlers.ListShares)
+		api.DELETE("/shares/:id", handlers.Audited("share.revoke"), handlers.RevokeShare)
+		api.GET("/shared/:token", handlers.GetSharedTranscript)
+		api.POST("/session/:id/rewind", handlers.Audited("session.rewind"), handlers.RewindSession)
+		api.GET("/session/:id/validate", handlers.ValidateSession)
+		api.POST("/session/:id/repair", handlers.Audited("session.repair"), handlers.RepairSession)
 		api.POST("/chat", handlers.Chat)
 		api.DELETE("/chat", handlers.InterruptChat)
+		api.GET("/approvals", handlers.ListApprovals)
+		api.POST("/approvals/:id", handlers.Audited("chat.approve"), handlers.DecideApproval)
 		api.POST("/chat/interactive", handlers.ChatInteractive)
+		api.GET("/chat/stream/:processId", handlers.ResumeChatStream)
+		api.GET("/chat/poll", handlers.PollChat)
+		api.POST("/chat/batch", handlers.Audited("chat.batch"), handlers.StartBatch)
+		api.GET("/chat/batch", handlers.ListBatches)
+		api.GET("/chat/batch/:id", handlers.GetBatch)
+		api.GET("/runs/compare", handlers.CompareRuns)
+		api.DELETE("/chat/batch/:id", handlers.Audited("chat.batch.cancel"), handlers.CancelBatch)
 		api.GET("/chat/ws", handlers.ChatWebSocket)
+		api.POST("/ws-ticket", handlers.CreateWSTicket)
 		api.POST("/directories", handlers.ListDirectories)
 		api.POST("/files", handlers.ListFiles)
+		api.GET("/remote-hosts", handlers.ListRemoteHosts)
+		api.GET("/backends", handlers.ListBackends)
+		api.GET("/chat-backends", handlers.ListChatBackends)
+		api.GET("/chat/pool", admin, handlers.GetStreamPool)
 		api.POST("/file/read", handlers.ReadFile)
-		api.GET("/commands", handlers.ListCommands)
-		api.GET("/config", handlers.GetConfig)
-		api.GET("/plugins", handlers.ListPlugins)
-		api.GET("/mcp", handlers.GetMCPServers)
+		api.POST("/file/outline", handlers.FileOutline)
+		api.POST("/file/write", handlers.Audited("file.write"), handlers.WriteFile)
+		api.POST("/file/delete", handlers.Audited("file.delete"), handlers.DeleteFile)
+		api.POST("/file/lock", handlers.LockFile)
+		api.POST("/file/unlock", handlers.UnlockFile)
+		api.GET("/file/locks", handlers.ListFileLocks)
+		api.GET("/trash", handlers.ListTrash)
+		api.POST("/trash/:id/restore", handlers.Audited("trash.restore"), handlers.RestoreTrash)
+		api.DELETE("/trash/:id", handlers.Audited("trash.purge"), handlers.PurgeTrash)
+		api.DELETE("/trash", handlers.Audited("trash.purge"), handlers.PurgeTrash)
+		api.GET("/projects/:id/context", handlers.GetProjectContext)
+		api.GET("/projects/:id/recent-files", handlers.GetRecentFiles)
+		api.GET("/projects/:id/settings", handlers.GetProjectSettings)
+		api.PUT("/projects/:id/settings", handlers.Audited("project.settings"), handlers.UpdateProjectSettings)
+		api.GET("/commands", handlers.Cached("commands"), handlers.ListCommands)
+		api.GET("/commands/:name", handlers.GetCommand)
+		api.POST("/commands/:name/run", handlers.RunCommand)
+		api.GET("/agents", handlers.ListAgents)
+		api.GET("/agents/:name", handlers.GetAgent)
+		api.GET("/skills", handlers.ListSkills)
+		api.GET("/skills/:name", handlers.GetSkill)
+		api.GET("/config", handlers.Cached("config"), handlers.GetConfig)
+		api.GET("/plugins", handlers.Cached("plugins"), handlers.ListPlugins)
+		api.POST("/plugins/install", admin, handlers.Audited("plugin.install"), handlers.InstallPlugin)
+		api.DELETE("/plugins/:name", admin, handlers.Audited("plugin.uninstall"), handlers.UninstallPlugin)
+		api.POST("/plugins/:name/update", admin, handlers.Audited("plugin.update"), handlers.UpdatePlugin)
+		api.GET("/mcp", handlers.Cached("mcp"), handlers.GetMCPServers)
+		api.POST("/mcp/:name", admin, handlers.Audited("mcp.create"), handlers.AddMCPServer)
+		api.PUT("/mcp/:name", admin, handlers.Audited("mcp.update"), handlers.UpdateMCPServer)
+		api.DELETE("/mcp/:name", admin, handlers.Audited("mcp.delete"), handlers.DeleteMCPServer)
+		api.POST("/mcp/:name/test", admin, handlers.TestMCPServer)
+		api.GET("/settings", handlers.GetSettings)
+		api.PUT("/settings", admin, handlers.Audited("settings.update"), handlers.UpdateSettings)
+		api.GET("/hooks", handlers.GetHooks)
+		api.PUT("/hooks", admin, handlers.Audited("hooks.update"), handlers.UpdateHooks)
+		api.GET("/hooks/log", handlers.GetHookLog)
+		api.POST("/hooks/ingest", handlers.IngestHookEvent)
+		api.GET("/hooks/events", handlers.GetHookEvents)
 		api.POST("/upload", handlers.UploadFile)
+		api.POST("/upload/extract", handlers.Audited("upload.extract"), handlers.ExtractUpload)
 		api.GET("/upload/:filename", handlers.GetUploadedFile)
-		api.DELETE("/upload/:filename", handlers.DeleteUploadedFile)
+		api.DELETE("/upload/:filename", handlers.Audited("upload.delete"), handlers.DeleteUploadedFile)
 		api.GET("/terminal", handlers.TerminalHandler)
+		api.GET("/terminals", handlers.ListTerminals)
+		api.GET("/terminals/:id/scrollback", handlers.GetTerminalScrollback)
+		api.GET("/lsp", handlers.LSPHandler)
+		api.GET("/lsp/servers", handlers.ListLanguageServers)
+
+		// Web Push notifications
+		api.GET("/push", handlers.GetPushConfig)
+		api.POST("/push/subscriptions", handlers.SubscribePush)
+		api.DELETE("/push/subscriptions", handlers.UnsubscribePush)
+		api.PUT("/push/preferences", handlers.UpdatePushPreferences)
+		api.POST("/push/test", handlers.TestPush)
+
+		// Outbound webhooks
+		api.GET("/webhooks", handlers.ListWebhooks)
+		api.POST("/webhooks", handlers.Audited("webhook.create"), handlers.CreateWebhook)
+		api.PUT("/webhooks/:id", handlers.Audited("webhook.update"), handlers.UpdateWebhook)
+		api.DELETE("/webhooks/:id", handlers.Audited("webhook.delete"), handlers.DeleteWebhook)
+		api.POST("/webhooks/:id/test", handlers.TestWebhook)
+
+		// Scheduled prompts
+		api.GET("/schedules", handlers.ListSchedules)
+		api.POST("/schedules", handlers.Audited("schedule.create"), handlers.CreateSchedule)
+		api.GET("/schedules/:id", handlers.GetSchedule)
+		api.PUT("/schedules/:id", handlers.Audited("schedule.update"), handlers.UpdateSchedule)
+		api.DELETE("/schedules/:id", handlers.Audited("schedule.delete"), handlers.DeleteSchedule)
+		api.POST("/schedules/:id/run", handlers.Audited("schedule.run"), handlers.RunSchedule)
+		api.GET("/schedules/:id/runs", handlers.GetScheduleRuns)
+
+		// Prompt templates
+		api.GET("/templates", handlers.ListTemplates)
+		api.POST("/templates", handlers.Audited("template.create"), handlers.CreateTemplate)
+		api.GET("/templates/:id", handlers.GetTemplate)
+		api.PUT("/templates/:id", handlers.Audited("template.update"), handlers.UpdateTemplate)
+		api.DELETE("/templates/:id", handlers.Audited("template.delete"), handlers.DeleteTemplate)
+		api.POST("/templates/:id/render", handlers.RenderTemplate)
+		api.POST("/templates/:id/run", handlers.RunTemplate)
+
+		// Issue trackers
+		api.GET("/integrations/github/issues", handlers.ListGitHubIssues)
+		api.POST("/integrations/github/issues/:id/run", handlers.RunGitHubIssue)
+		api.POST("/integrations/linear/webhook", handlers.LinearWebhook)
+		api.POST("/integrations/jira/webhook", handlers.JiraWebhook)
+		api.POST("/integrations/slack/events", handlers.SlackEvents)
+		api.POST("/integrations/telegram/webhook", handlers.TelegramWebhook)
 
 		// Active processes
-		api.GET("/processes", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{
-				"processes": handlers.GetActiveProcesses(),
-			})
-		})
+		api.GET("/processes", handlers.ListProcesses)
+		api.GET("/processes/:id", handlers.GetProcess)
+		api.GET("/diagnostics", handlers.GetDiagnostics)
+		api.GET("/todos", handlers.GetTodos)
+
+		// Disk usage
+		api.GET("/storage", handlers.GetStorage)
+		api.POST("/storage/cleanup", handlers.Audited("storage.cleanup"), handlers.CleanupStorage)
+
+		// Backup and restore
+		api.GET("/backup", admin, handlers.GetBackupStatus)
+		api.POST("/backup", admin, handlers.Audited("backup.create"), handlers.CreateBackup)
+		api.POST("/restore", admin, handlers.Audited("backup.restore"), handlers.RestoreBackup)
+
+		// Spend tracking and budgets
+		api.GET("/usage", handlers.GetUsage)
+		api.GET("/analytics/activity", handlers.GetActivity)
+		api.GET("/digest", handlers.GetDigest)
+		api.POST("/digest/send", admin, handlers.Audited("digest.send"), handlers.SendDigest)
+		api.GET("/budgets", handlers.ListBudgets)
+		api.POST("/budgets", handlers.Audited("budget.create"), handlers.CreateBudget)
+		api.PUT("/budgets/:id", handlers.Audited("budget.update"), handlers.UpdateBudget)
+		api.DELETE("/budgets/:id", handlers.Audited("budget.delete"), handlers.DeleteBudget)
+
+		// Effective server configuration
+		api.GET("/server/config", handlers.GetServerConfig)
+		api.GET("/server/logs", handlers.GetServerLogs)
+		api.GET("/server/doctor", handlers.GetDoctor)
+		api.GET("/audit", handlers.GetAuditLog)
+		api.GET("/version", handlers.GetVersion)
+		api.GET("/i18n", handlers.GetLocaleInfo)
+		api.GET("/openapi.json", handlers.GetOpenAPISpec)
 
 		// State management (session processing status only - tabs managed client-side)
 		api.GET("/state", handlers.GetState)
 		api.GET("/state/subscribe", handlers.SubscribeState)
 	}
 
+	// Keep /api/openapi.json in sync with the registered routes
+	if missing, stale := handlers.UndocumentedRoutes(router.Routes()); len(missing)+len(stale) > 0 {
+		log.Printf("WARNING: OpenAPI spec out of date: undocumented %v, not registered %v", missing, stale)
+	}
+
 	// Serve index.html for root and any unmatched routes (SPA fallback)
-	router.NoRoute(func(c *gin.Context) {
-		c.File("./client/dist/index.html")
-	})
+	router.NoRoute(serveClient(static, basePath))
 
-	// Create HTTPS server (localhost only for security)
-	addr := fmt.Sprintf("127.0.0.1:%d", *port)
+	// Create server (localhost only by default for security)
+	addr := cfg.Listen
+	if addr == "" {
+		addr = net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	}
 	server := &http.Server{
-		Addr:    addr,
-		Handler: router,
+		Addr:              addr,
+		Handler:           stripBasePath(basePath, router),
+		ReadHeaderTimeout: time.Duration(cfg.HTTP.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.HTTP.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.HTTP.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.HTTP.IdleTimeout) * time.Second,
+	}
+	if basePath != "" {
+		log.Printf("Serving under base path %s", basePath)
+	}
+	if h, _, err := net.SplitHostPort(addr); err == nil && cfg.Auth.Mode == "none" {
+		if ip := net.ParseIP(h); ip == nil || !ip.IsLoopback() {
+			log.Printf("WARNING: listening on %s without authentication (use --auth local or oidc)", addr)
+		}
 	}
 
 	// Signal handling for graceful shutdown
@@ -103,9 +353,8 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Starting HTTPS server on https://%s", addr)
-		if err := server.ListenAndServeTLS("cert.pem", "key.pem"); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Failed to start HTTPS server: %v", err)
+		if err := listenAndServe(server, cfg.TLS, cfg.HTTP); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
@@ -120,6 +369,7 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Printf("Server shutdown error: %v", err)
 	}
+	handlers.StopProcesses()
 
 	log.Printf("Server stopped")
 }
@@ -130,10 +380,7 @@ func recoveryMiddleware() gin.HandlerFunc {
 		defer func() {
 			if err := recover(); err != nil {
 				log.Printf("PANIC recovered: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Internal server error",
-				})
-				c.Abort()
+				c.AbortWithStatusJSON(http.StatusInternalServerError, handlers.NewAPIError(c, handlers.ErrInternal, "Internal server error", nil))
 			}
 		}()
 		c.Next()
@@ -149,15 +396,22 @@ func loggingMiddleware() gin.HandlerFunc {
 
 		c.Next()
 
-		// Skip logging for health check
-		if path == "/health" {
+		// Skip logging for health checks
+		if path == "/health" || path == "/health/live" || path == "/health/ready" {
 			return
 		}
 
 		duration := time.Since(start)
 		statusCode := c.Writer.Status()
 
-		log.Printf("[%s] %s %d - %v", method, path, statusCode, duration)
+		slog.Info("request",
+			"method", method,
+			"path", path,
+			"status", statusCode,
+			"duration", duration.String(),
+			"clientIp", c.ClientIP(),
+			"requestId", c.GetString("requestId"),
+		)
 	}
 }
 
@@ -166,18 +420,21 @@ func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
 
-		// Allow requests with no origin (same-origin, curl, etc.)
-		// Or validate that origin is from localhost/127.0.0.1/Tailscale IPs
-		if origin != "" && !isAllowedOrigin(origin) {
-			c.AbortWithStatus(http.StatusForbidden)
+		// Allow requests with no origin (curl, etc.), same-origin requests, and
+		// origins from the allowedOrigins config
+		c.Writer.Header().Add("Vary", "Origin")
+		if !handlers.OriginAllowed(c.Request, origin) {
+			log.Printf("[CORS] Rejected request from origin: %s", origin)
+			c.AbortWithStatusJSON(http.StatusForbidden, handlers.NewAPIError(c, handlers.ErrForbidden, "Origin not allowed", nil))
 			return
 		}
 
 		if origin != "" {
 			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		}
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Request-ID")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -188,65 +445,3 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
-
-// isAllowedOrigin checks if the origin is from trusted sources
-func isAllowedOrigin(origin string) bool {
-	// Allow localhost variants
-	allowedPrefixes := []string{
-		"http://localhost",
-		"https://localhost",
-		"http://127.0.0.1",
-		"https://127.0.0.1",
-		"http://[::1]",
-		"https://[::1]",
-	}
-	for _, prefix := range allowedPrefixes {
-		if strings.HasPrefix(origin, prefix) {
-			return true
-		}
-	}
-
-	// Allow Tailscale IPs (100.x.x.x range)
-	if strings.Contains(origin, "://100.") {
-		return true
-	}
-
-	return false
-}
-
-// healthCheck returns server health status
-func healthCheck() gin.HandlerFunc {
-	startTime := time.Now()
-	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"uptime":  time.Since(startTime).String(),
-			"time":    time.Now().Format(time.RFC3339),
-		})
-	}
-}
-
-// setupLogging configures logging to both stdout and file
-func setupLogging(logDir string) error {
-	// Create log directory if not exists
-	if err := os.MkdirAll(logDir, 0755); err != nil {
-		return fmt.Errorf("failed to create log directory: %w", err)
-	}
-
-	// Create log file with date
-	logFileName := fmt.Sprintf("server_%s.log", time.Now().Format("2006-01-02"))
-	logPath := filepath.Join(logDir, logFileName)
-
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-	if err != nil {
-		return fmt.Errorf("failed to open log file: %w", err)
-	}
-
-	// Write to both stdout and file
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	log.SetOutput(multiWriter)
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
-
-	log.Printf("Logging initialized. Log file: %s", logPath)
-	return nil
-}
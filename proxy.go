@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// normalizeBasePath turns "claude/", "/claude/" etc. into "/claude" ("" for root)
+func normalizeBasePath(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// stripBasePath serves the router under basePath
+// Requests without the prefix are still accepted so proxies that strip it work too
+func stripBasePath(basePath string, next http.Handler) http.Handler {
+	if basePath == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == basePath {
+			http.Redirect(w, r, basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, basePath+"/") {
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, basePath)
+			if r.URL.RawPath != "" {
+				r.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, basePath)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// basePathShim rewrites root-relative /api URLs used by the client so the SPA
+// works under a sub-path without rebuilding it
+const basePathShim = `<script>(function(b){
+window.__BASE_PATH__=b;
+function p(u){return typeof u==="string"&&u.indexOf("/api/")===0?b+u:u}
+var f=window.fetch;window.fetch=function(u,o){return f.call(this,p(u),o)};
+var E=window.EventSource;window.EventSource=function(u,o){return new E(p(u),o)};window.EventSource.prototype=E.prototype;
+var W=window.WebSocket;window.WebSocket=function(u,s){if(typeof u==="string"){var m=u.match(/^(wss?:\/\/[^\/]+)(\/api\/.*)$/);if(m)u=m[1]+b+m[2]}return s===undefined?new W(u):new W(u,s)};window.WebSocket.prototype=W.prototype;
+["CONNECTING","OPEN","CLOSING","CLOSED"].forEach(function(k){window.WebSocket[k]=W[k]});
+})(%q);</script>`
+
+// serveIndex serves the SPA entry point, rewriting asset URLs for basePath
+func serveIndex(static fs.FS, basePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data, err := fs.ReadFile(static, "index.html")
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		if basePath == "" {
+			c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+			return
+		}
+		html := string(data)
+		html = strings.ReplaceAll(html, `="/assets/`, `="`+basePath+`/assets/`)
+		html = strings.ReplaceAll(html, `="/favicon`, `="`+basePath+`/favicon`)
+		html = strings.Replace(html, "<head>", "<head>"+fmt.Sprintf(basePathShim, basePath), 1)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+	}
+}
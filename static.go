@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"claude-web-ui/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// hashedAssetRegex matches the content hash the bundler puts in asset names, e.g. index-B3xk9_Qa.js
+var hashedAssetRegex = regexp.MustCompile(`[-.][A-Za-z0-9_-]{8,}\.[a-z0-9]+$`)
+
+// precompressed are the encodings served from a .br or .gz file next to an asset, preferred first
+var precompressed = []struct{ encoding, ext string }{{"br", ".br"}, {"gzip", ".gz"}}
+
+// staticETags caches each file's ETag by name, size, and modification time
+var staticETags sync.Map
+
+// embeddedClient is the client bundle built into the binary
+// Build the client (cd client && bun run build) before `go build` to include it
+//
+//go:embed all:client/dist
+var embeddedClient embed.FS
+
+// clientFS returns the client bundle: staticDir when set, otherwise the embedded copy
+func clientFS(staticDir string) (fs.FS, error) {
+	if staticDir != "" {
+		if _, err := os.Stat(filepath.Join(staticDir, "index.html")); err != nil {
+			return nil, fmt.Errorf("static dir %s has no index.html", staticDir)
+		}
+		log.Printf("Serving client from %s", staticDir)
+		return os.DirFS(staticDir), nil
+	}
+
+	dist, err := fs.Sub(embeddedClient, "client/dist")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		log.Printf("Warning: client bundle not embedded; build the client before the server or use --static-dir")
+	}
+	return dist, nil
+}
+
+// staticETag returns a strong ETag for a file of the bundle, hashing it once
+func staticETag(static fs.FS, name string, info fs.FileInfo) (string, error) {
+	key := fmt.Sprintf("%s|%d|%d", name, info.Size(), info.ModTime().UnixNano())
+	if etag, ok := staticETags.Load(key); ok {
+		return etag.(string), nil
+	}
+	data, err := fs.ReadFile(static, name)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	etag := `"` + hex.EncodeToString(sum[:12]) + `"`
+	staticETags.Store(key, etag)
+	return etag, nil
+}
+
+// acceptsEncoding reports whether the request's Accept-Encoding allows encoding
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(fields[0]), encoding) {
+			continue
+		}
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if weight, err := strconv.ParseFloat(q, 64); err == nil && weight == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// serveStatic serves a file of the bundle with caching headers: files named with a content hash are cached
+// for a year, everything else is revalidated against its ETag. A precompressed .br or .gz variant is sent
+// in its place when the client accepts it. Reports false when there is no such file
+func serveStatic(c *gin.Context, static fs.FS, name string) bool {
+	info, err := fs.Stat(static, name)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	header := c.Writer.Header()
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	header.Set("Content-Type", contentType)
+	if hashedAssetRegex.MatchString(path.Base(name)) {
+		header.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		header.Set("Cache-Control", "no-cache")
+	}
+	header.Add("Vary", "Accept-Encoding")
+
+	served, servedInfo, etagSuffix := name, info, ""
+	for _, variant := range precompressed {
+		if !acceptsEncoding(c.Request, variant.encoding) {
+			continue
+		}
+		if vinfo, err := fs.Stat(static, name+variant.ext); err == nil && !vinfo.IsDir() {
+			served, servedInfo, etagSuffix = name+variant.ext, vinfo, "-"+variant.encoding
+			header.Set("Content-Encoding", variant.encoding)
+			break
+		}
+	}
+	etag, err := staticETag(static, served, servedInfo)
+	if err != nil {
+		return false
+	}
+	header.Set("ETag", strings.TrimSuffix(etag, `"`)+etagSuffix+`"`)
+	data, err := fs.ReadFile(static, served)
+	if err != nil {
+		return false
+	}
+	// The ETag decides freshness; embedded files have no modification time
+	http.ServeContent(c.Writer, c.Request, "", time.Time{}, bytes.NewReader(data))
+	return true
+}
+
+// serveClient serves files from the bundle root (favicon, icons) and falls back
+// to index.html for client-side routes; unknown /api routes get a JSON 404 instead
+func serveClient(static fs.FS, basePath string) gin.HandlerFunc {
+	index := serveIndex(static, basePath)
+	return func(c *gin.Context) {
+		name := strings.TrimPrefix(path.Clean(c.Request.URL.Path), "/")
+		if name == "api" || strings.HasPrefix(name, "api/") {
+			c.JSON(http.StatusNotFound, handlers.NewAPIError(c, handlers.ErrNotFound, "Unknown API route", c.Request.Method+" "+c.Request.URL.Path))
+			return
+		}
+		get := c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead
+		if get && name != "" && name != "index.html" && serveStatic(c, static, name) {
+			return
+		}
+		c.Header("Cache-Control", "no-cache")
+		index(c)
+	}
+}
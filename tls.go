@@ -0,0 +1,152 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"claude-web-ui/handlers"
+
+	"golang.org/x/crypto/acme/autocert"
+	"golang.org/x/net/http2"
+)
+
+// fileExists reports whether path exists
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// ensureSelfSignedCert creates a self-signed certificate for local use if
+// certFile or keyFile is missing
+func ensureSelfSignedCert(certFile, keyFile string) error {
+	if fileExists(certFile) && fileExists(keyFile) {
+		return nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"claude-web-ui"}, CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(2, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" && hostname != "localhost" {
+		template.DNSNames = append(template.DNSNames, hostname)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key: %w", err)
+	}
+
+	for _, path := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPEM, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", keyFile, err)
+	}
+
+	log.Printf("Generated self-signed certificate: %s (valid until %s)", certFile, template.NotAfter.Format("2006-01-02"))
+	return nil
+}
+
+// configureHTTP2 enables HTTP/2 on the TLS listener, or turns it off
+// Browsers cap HTTP/1.1 at six connections per host, which a few SSE streams exhaust
+func configureHTTP2(server *http.Server, opts handlers.HTTPConfig) error {
+	if opts.DisableHTTP2 {
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		protos := server.TLSConfig.NextProtos[:0]
+		for _, proto := range server.TLSConfig.NextProtos {
+			if proto != "h2" {
+				protos = append(protos, proto)
+			}
+		}
+		server.TLSConfig.NextProtos = protos
+		return nil
+	}
+	return http2.ConfigureServer(server, &http2.Server{
+		MaxConcurrentStreams: uint32(opts.MaxConcurrentStreams),
+		IdleTimeout:          server.IdleTimeout,
+	})
+}
+
+// listenAndServe starts the server with the configured TLS mode
+func listenAndServe(server *http.Server, opts handlers.TLSConfig, httpOpts handlers.HTTPConfig) error {
+	if opts.Disabled {
+		log.Printf("Starting HTTP server on http://%s (TLS disabled)", server.Addr)
+		return server.ListenAndServe()
+	}
+
+	if len(opts.AutocertDomains) > 0 {
+		manager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.AutocertDomains...),
+			Cache:      autocert.DirCache(opts.AutocertCache),
+			Email:      opts.AutocertEmail,
+		}
+		server.TLSConfig = manager.TLSConfig()
+		if err := configureHTTP2(server, httpOpts); err != nil {
+			return err
+		}
+
+		// HTTP-01 challenges and HTTP->HTTPS redirects
+		go func() {
+			if err := http.ListenAndServe(":80", manager.HTTPHandler(nil)); err != nil {
+				log.Printf("ACME HTTP challenge listener failed (TLS-ALPN will still be tried): %v", err)
+			}
+		}()
+
+		log.Printf("Starting HTTPS server on https://%s (Let's Encrypt: %s)", server.Addr, strings.Join(opts.AutocertDomains, ", "))
+		return server.ListenAndServeTLS("", "")
+	}
+
+	if err := ensureSelfSignedCert(opts.Cert, opts.Key); err != nil {
+		return err
+	}
+	server.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	if err := configureHTTP2(server, httpOpts); err != nil {
+		return err
+	}
+
+	log.Printf("Starting HTTPS server on https://%s", server.Addr)
+	return server.ListenAndServeTLS(opts.Cert, opts.Key)
+}